@@ -0,0 +1,177 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	elastic2 "gopkg.in/olivere/elastic.v3"
+	elastic5 "gopkg.in/olivere/elastic.v5"
+)
+
+const bulkErrorResponse = `{
+  "took": 3,
+  "errors": true,
+  "items": [
+    {"index": {"_index": "heapster-2017.06.01", "_type": "cpu", "_id": "1", "status": 201}},
+    {"index": {"_index": "heapster-2017.06.01", "_type": "cpu", "_id": "2", "status": 400,
+      "error": {"type": "mapper_parsing_exception", "reason": "failed to parse [CpuMetricsTimestamp]"}}},
+    {"index": {"_index": "heapster-2017.06.01", "_type": "memory", "_id": "3", "status": 429,
+      "error": {"type": "es_rejected_execution_exception", "reason": "queue capacity reached"}}}
+  ]
+}`
+
+// newBulkServer returns a fake ElasticSearch answering to the _bulk API, which sends the
+// body of every bulk request to the returned channel.
+func newBulkServer(t *testing.T) (*httptest.Server, chan string) {
+	bodies := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" {
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Failed to read bulk request: %v", err)
+		}
+		bodies <- string(body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, bulkErrorResponse)
+	}))
+	return server, bodies
+}
+
+func newBulkService(t *testing.T, server *httptest.Server, options string) *ElasticSearchService {
+	uri, err := url.Parse(server.URL + "?sniff=false&healthCheck=false&" + options)
+	if err != nil {
+		t.Fatalf("Error when parsing URL: %v", err)
+	}
+	esSvc, err := CreateElasticSearchService(uri)
+	if err != nil {
+		t.Fatalf("Error when creating service: %v", err)
+	}
+	return esSvc
+}
+
+func receiveBulk(t *testing.T, bodies chan string) []map[string]interface{} {
+	select {
+	case body := <-bodies:
+		var lines []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+			var decoded map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+				t.Fatalf("Invalid line %q of bulk request: %v", line, err)
+			}
+			lines = append(lines, decoded)
+		}
+		return lines
+	case <-time.After(10 * time.Second):
+		t.Fatal("No bulk request sent")
+	}
+	return nil
+}
+
+func TestBulkFlushByCount(t *testing.T) {
+	server, bodies := newBulkServer(t)
+	defer server.Close()
+	esSvc := newBulkService(t, server, "bulkActions=2&bulkFlushInterval=1h")
+	defer esSvc.EsClient.bulkProcessorV5.Close()
+
+	esSvc.EsClient.AddBulkReq("heapster-2017.06.01", "cpu", map[string]interface{}{"value": 1})
+	esSvc.EsClient.AddBulkReq("heapster-2017.06.01", "cpu", map[string]interface{}{"value": 2})
+
+	lines := receiveBulk(t, bodies)
+	if len(lines) != 4 {
+		t.Fatalf("Expected an action and a document line per document, got %v", lines)
+	}
+	for i := 0; i < 4; i += 2 {
+		action, ok := lines[i]["index"].(map[string]interface{})
+		if !ok {
+			t.Fatalf("Expected an index action, got %v", lines[i])
+		}
+		if action["_index"] != "heapster-2017.06.01" || action["_type"] != "cpu" || action["_id"] == "" {
+			t.Errorf("Unexpected index action %v", action)
+		}
+		if lines[i+1]["value"] != float64(i/2+1) {
+			t.Errorf("Unexpected document %v", lines[i+1])
+		}
+	}
+}
+
+func TestBulkFlushBySize(t *testing.T) {
+	server, bodies := newBulkServer(t)
+	defer server.Close()
+	esSvc := newBulkService(t, server, "bulkActions=1000&bulkSize=10&bulkFlushInterval=1h")
+	defer esSvc.EsClient.bulkProcessorV5.Close()
+
+	// Every document alone exceeds the size threshold.
+	esSvc.EsClient.AddBulkReq("heapster-2017.06.01", "cpu", map[string]interface{}{"value": 1})
+	esSvc.EsClient.AddBulkReq("heapster-2017.06.01", "cpu", map[string]interface{}{"value": 2})
+	for i := 0; i < 2; i++ {
+		if lines := receiveBulk(t, bodies); len(lines) != 2 {
+			t.Errorf("Expected a single document per request, got %v", lines)
+		}
+	}
+}
+
+func TestBulkFailures(t *testing.T) {
+	expected := []string{
+		"index heapster-2017.06.01/cpu/2: status 400, mapper_parsing_exception: failed to parse [CpuMetricsTimestamp]",
+		"index heapster-2017.06.01/memory/3: status 429, es_rejected_execution_exception: queue capacity reached",
+	}
+
+	var responseV5 elastic5.BulkResponse
+	if err := json.Unmarshal([]byte(bulkErrorResponse), &responseV5); err != nil {
+		t.Fatalf("Failed to parse bulk response: %v", err)
+	}
+	if failures := bulkFailuresV5(&responseV5); !reflect.DeepEqual(expected, failures) {
+		t.Errorf("Expected failures %v, got %v", expected, failures)
+	}
+
+	var responseV2 elastic2.BulkResponse
+	if err := json.Unmarshal([]byte(bulkErrorResponse), &responseV2); err != nil {
+		t.Fatalf("Failed to parse bulk response: %v", err)
+	}
+	if failures := bulkFailuresV2(&responseV2); !reflect.DeepEqual(expected, failures) {
+		t.Errorf("Expected failures %v, got %v", expected, failures)
+	}
+
+	// Neither a failed nor a partially failed request panics.
+	bulkAfterCB(1, nil, nil, fmt.Errorf("connection refused"))
+	bulkAfterCB(1, make([]elastic5.BulkableRequest, 3), &responseV5, nil)
+	bulkAfterCB_v2(1, nil, nil, fmt.Errorf("connection refused"))
+}
+
+func TestInvalidBulkOptions(t *testing.T) {
+	for _, options := range []string{"bulkActions=0", "bulkActions=many", "bulkSize=-1", "bulkFlushInterval=10", "bulkFlushInterval=0s"} {
+		uri, err := url.Parse("http://localhost:9200?sniff=false&healthCheck=false&" + options)
+		if err != nil {
+			t.Fatalf("Error when parsing URL: %v", err)
+		}
+		if _, err := CreateElasticSearchService(uri); err == nil {
+			t.Errorf("Expected an error for %s", options)
+		}
+	}
+}
@@ -221,13 +221,31 @@ func CreateElasticSearchService(uri *url.URL) (*ElasticSearchService, error) {
 		}
 	}
 
-	bulkWorkers := 5
+	bulk := defaultBulkOptions()
 	if len(opts["bulkWorkers"]) > 0 {
-		bulkWorkers, err = strconv.Atoi(opts["bulkWorkers"][0])
+		bulk.workers, err = strconv.Atoi(opts["bulkWorkers"][0])
 		if err != nil {
 			return nil, errors.New("Failed to parse URL's bulkWorkers value into an int")
 		}
 	}
+	if len(opts["bulkActions"]) > 0 {
+		bulk.actions, err = strconv.Atoi(opts["bulkActions"][0])
+		if err != nil || bulk.actions <= 0 {
+			return nil, errors.New("Failed to parse URL's bulkActions value into a positive int")
+		}
+	}
+	if len(opts["bulkSize"]) > 0 {
+		bulk.size, err = strconv.Atoi(opts["bulkSize"][0])
+		if err != nil || bulk.size <= 0 {
+			return nil, errors.New("Failed to parse URL's bulkSize value into a positive int")
+		}
+	}
+	if len(opts["bulkFlushInterval"]) > 0 {
+		bulk.flushInterval, err = time.ParseDuration(opts["bulkFlushInterval"][0])
+		if err != nil || bulk.flushInterval <= 0 {
+			return nil, errors.New("Failed to parse URL's bulkFlushInterval value into a positive duration")
+		}
+	}
 
 	pipeline := ""
 	if len(opts["pipeline"]) > 0 {
@@ -236,9 +254,9 @@ func CreateElasticSearchService(uri *url.URL) (*ElasticSearchService, error) {
 
 	switch version {
 	case 2:
-		esSvc.EsClient, err = newEsClientV2(startupFnsV2, bulkWorkers)
+		esSvc.EsClient, err = newEsClientV2(startupFnsV2, bulk)
 	case 5:
-		esSvc.EsClient, err = newEsClientV5(startupFnsV5, bulkWorkers, pipeline)
+		esSvc.EsClient, err = newEsClientV5(startupFnsV5, bulk, pipeline)
 	default:
 		return nil, UnsupportedVersion{}
 	}
@@ -23,6 +23,13 @@ import (
 	"time"
 )
 
+const (
+	defaultBulkWorkers       = 5
+	defaultBulkActions       = 1000
+	defaultBulkSize          = 2 << 20
+	defaultBulkFlushInterval = 10 * time.Second
+)
+
 type UnsupportedVersion struct{}
 
 func (UnsupportedVersion) Error() string {
@@ -38,39 +45,59 @@ type esClient struct {
 	pipeline        string
 }
 
+// bulkOptions sets when the documents added to the bulk processor are sent to the _bulk
+// API, whichever threshold is reached first.
+type bulkOptions struct {
+	workers int
+	// Number of documents.
+	actions int
+	// Size of the request body in bytes.
+	size          int
+	flushInterval time.Duration
+}
+
+func defaultBulkOptions() bulkOptions {
+	return bulkOptions{
+		workers:       defaultBulkWorkers,
+		actions:       defaultBulkActions,
+		size:          defaultBulkSize,
+		flushInterval: defaultBulkFlushInterval,
+	}
+}
+
 func NewMockClient() *esClient {
 	return &esClient{}
 }
-func newEsClientV5(startupFns []elastic5.ClientOptionFunc, bulkWorkers int, pipeline string) (*esClient, error) {
+func newEsClientV5(startupFns []elastic5.ClientOptionFunc, bulk bulkOptions, pipeline string) (*esClient, error) {
 	client, err := elastic5.NewClient(startupFns...)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to an ElasticSearch Client: %v", err)
 	}
 	bps, err := client.BulkProcessor().
 		Name("ElasticSearchWorker").
-		Workers(bulkWorkers).
+		Workers(bulk.workers).
 		After(bulkAfterCB).
-		BulkActions(1000).               // commit if # requests >= 1000
-		BulkSize(2 << 20).               // commit if size of requests >= 2 MB
-		FlushInterval(10 * time.Second). // commit every 10s
+		BulkActions(bulk.actions).
+		BulkSize(bulk.size).
+		FlushInterval(bulk.flushInterval).
 		Do(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("Failed to an ElasticSearch Bulk Processor: %v", err)
 	}
 	return &esClient{version: 5, clientV5: client, bulkProcessorV5: bps, pipeline: pipeline}, nil
 }
-func newEsClientV2(startupFns []elastic2.ClientOptionFunc, bulkWorkers int) (*esClient, error) {
+func newEsClientV2(startupFns []elastic2.ClientOptionFunc, bulk bulkOptions) (*esClient, error) {
 	client, err := elastic2.NewClient(startupFns...)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to an ElasticSearch Client: %v", err)
 	}
 	bps, err := client.BulkProcessor().
 		Name("ElasticSearchWorker").
-		Workers(bulkWorkers).
+		Workers(bulk.workers).
 		After(bulkAfterCB_v2).
-		BulkActions(1000).               // commit if # requests >= 1000
-		BulkSize(2 << 20).               // commit if size of requests >= 2 MB
-		FlushInterval(10 * time.Second). // commit every 10s
+		BulkActions(bulk.actions).
+		BulkSize(bulk.size).
+		FlushInterval(bulk.flushInterval).
 		Do()
 	if err != nil {
 		return nil, fmt.Errorf("Failed to an ElasticSearch Bulk Processor: %v", err)
@@ -159,33 +186,63 @@ func (es *esClient) FlushBulk() error {
 	}
 }
 
-func bulkAfterCB_v2(_ int64, _ []elastic2.BulkableRequest, response *elastic2.BulkResponse, err error) {
+func bulkAfterCB_v2(_ int64, requests []elastic2.BulkableRequest, response *elastic2.BulkResponse, err error) {
 	if err != nil {
 		glog.Warningf("Failed to execute bulk operation to ElasticSearch: %v", err)
+		return
 	}
-
-	if response.Errors {
-		for _, list := range response.Items {
-			for name, itm := range list {
-				if itm.Error != nil {
-					glog.V(3).Infof("Failed to execute bulk operation to ElasticSearch on %s: %v", name, itm.Error)
-				}
-			}
-		}
+	if response != nil && response.Errors {
+		logBulkFailures(len(requests), bulkFailuresV2(response))
 	}
 }
-func bulkAfterCB(_ int64, _ []elastic5.BulkableRequest, response *elastic5.BulkResponse, err error) {
+func bulkAfterCB(_ int64, requests []elastic5.BulkableRequest, response *elastic5.BulkResponse, err error) {
 	if err != nil {
 		glog.Warningf("Failed to execute bulk operation to ElasticSearch: %v", err)
+		return
+	}
+	if response != nil && response.Errors {
+		logBulkFailures(len(requests), bulkFailuresV5(response))
 	}
+}
 
-	if response.Errors {
-		for _, list := range response.Items {
-			for name, itm := range list {
-				if itm.Error != nil {
-					glog.V(3).Infof("Failed to execute bulk operation to ElasticSearch on %s: %v", name, itm.Error)
-				}
+// bulkFailuresV2 describes the documents of a bulk request that ElasticSearch rejected.
+// The other documents of the request were stored.
+func bulkFailuresV2(response *elastic2.BulkResponse) []string {
+	var failures []string
+	for _, list := range response.Items {
+		for action, item := range list {
+			if item.Error != nil {
+				failures = append(failures, bulkFailure(action, item.Index, item.Type, item.Id, item.Status, item.Error.Type, item.Error.Reason))
 			}
 		}
 	}
+	return failures
+}
+
+// bulkFailuresV5 describes the documents of a bulk request that ElasticSearch rejected.
+// The other documents of the request were stored.
+func bulkFailuresV5(response *elastic5.BulkResponse) []string {
+	var failures []string
+	for _, list := range response.Items {
+		for action, item := range list {
+			if item.Error != nil {
+				failures = append(failures, bulkFailure(action, item.Index, item.Type, item.Id, item.Status, item.Error.Type, item.Error.Reason))
+			}
+		}
+	}
+	return failures
+}
+
+func bulkFailure(action, index, typeName, id string, status int, errorType, reason string) string {
+	return fmt.Sprintf("%s %s/%s/%s: status %d, %s: %s", action, index, typeName, id, status, errorType, reason)
+}
+
+func logBulkFailures(documents int, failures []string) {
+	if len(failures) == 0 {
+		return
+	}
+	glog.Warningf("ElasticSearch rejected %d of %d documents of a bulk request, e.g. %s", len(failures), documents, failures[0])
+	for _, failure := range failures {
+		glog.V(2).Infof("ElasticSearch rejected document: %s", failure)
+	}
 }
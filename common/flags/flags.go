@@ -25,9 +25,15 @@ import (
 type Uri struct {
 	Key string
 	Val url.URL
+	// The value as given, if it references environment variables. It is shown instead of
+	// the expanded value, which may contain the secrets of the variables.
+	template string
 }
 
 func (u *Uri) String() string {
+	if u.template != "" {
+		return fmt.Sprintf("%s:%s", u.Key, u.template)
+	}
 	val := u.Val.String()
 	if val == "" {
 		return fmt.Sprintf("%s", u.Key)
@@ -42,16 +48,44 @@ func (u *Uri) Set(value string) error {
 	}
 	u.Key = s[0]
 	if len(s) > 1 && s[1] != "" {
-		e := os.ExpandEnv(s[1])
+		e, err := expandEnv(s[1])
+		if err != nil {
+			return fmt.Errorf("invalid uri '%s': %v", value, err)
+		}
 		uri, err := url.Parse(e)
 		if err != nil {
+			if e != s[1] {
+				// The error would contain the expanded value.
+				return fmt.Errorf("invalid uri '%s' after expanding the environment variables", value)
+			}
 			return err
 		}
 		u.Val = *uri
+		if e != s[1] {
+			u.template = s[1]
+		}
 	}
 	return nil
 }
 
+// expandEnv replaces ${var} and $var with the value of the environment variable. Unlike
+// os.ExpandEnv, it fails if a variable is not set, rather than silently dropping it from
+// e.g. the credentials.
+func expandEnv(value string) (string, error) {
+	missing := []string{}
+	expanded := os.Expand(value, func(name string) string {
+		envValue, found := os.LookupEnv(name)
+		if !found {
+			missing = append(missing, name)
+		}
+		return envValue
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
 type Uris []Uri
 
 func (us *Uris) String() string {
@@ -22,7 +22,8 @@ import (
 )
 
 type PointSavedToInfluxdb struct {
-	Ponit influxdb.Point
+	Ponit    influxdb.Point
+	Database string
 }
 
 type FakeInfluxDBClient struct {
@@ -35,7 +36,7 @@ func NewFakeInfluxDBClient() *FakeInfluxDBClient {
 
 func (client *FakeInfluxDBClient) Write(bps influxdb.BatchPoints) (*influxdb.Response, error) {
 	for _, pnt := range bps.Points {
-		client.Pnts = append(client.Pnts, PointSavedToInfluxdb{pnt})
+		client.Pnts = append(client.Pnts, PointSavedToInfluxdb{Ponit: pnt, Database: bps.Database})
 	}
 	return nil, nil
 }
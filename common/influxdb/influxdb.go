@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"k8s.io/heapster/version"
@@ -44,6 +45,21 @@ type InfluxdbConfig struct {
 	ClusterName           string
 	DisableCounterMetrics bool
 	Concurrency           int
+	Precision             string
+	// Window over which the gauges are averaged into additional downsampled measurements, 0 to disable.
+	DownsampleWindow time.Duration
+	// Metrics that are downsampled, all gauges if empty.
+	DownsampleMetrics []string
+	// Databases of the metric set types that are not written to DbName, e.g. node -> k8s_nodes.
+	DbNameByType map[string]string
+}
+
+// Maps user facing precision values to the ones understood by the InfluxDB client.
+var supportedPrecisions = map[string]string{
+	"ns": "n",
+	"us": "u",
+	"ms": "ms",
+	"s":  "s",
 }
 
 func NewClient(c InfluxdbConfig) (InfluxdbClient, error) {
@@ -73,6 +89,23 @@ func NewClient(c InfluxdbConfig) (InfluxdbClient, error) {
 	return client, nil
 }
 
+// parseDbNameByType parses the databases of the metric set types given as
+// <type>:<database>,<type>:<database>,...
+func parseDbNameByType(value string) (map[string]string, error) {
+	dbNameByType := make(map[string]string)
+	for _, mapping := range strings.Split(value, ",") {
+		parts := strings.SplitN(mapping, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("failed to parse `db_by_type` flag - invalid mapping %q, expected <type>:<database>", mapping)
+		}
+		if _, found := dbNameByType[parts[0]]; found {
+			return nil, fmt.Errorf("failed to parse `db_by_type` flag - type %q is mapped more than once", parts[0])
+		}
+		dbNameByType[parts[0]] = parts[1]
+	}
+	return dbNameByType, nil
+}
+
 func BuildConfig(uri *url.URL) (*InfluxdbConfig, error) {
 	config := InfluxdbConfig{
 		User:                  "root",
@@ -153,5 +186,36 @@ func BuildConfig(uri *url.URL) (*InfluxdbConfig, error) {
 		config.Concurrency = concurrency
 	}
 
+	if len(opts["precision"]) >= 1 {
+		precision, found := supportedPrecisions[opts["precision"][0]]
+		if !found {
+			return nil, fmt.Errorf("unsupported `precision` flag %q - must be one of ns, us, ms or s", opts["precision"][0])
+		}
+		config.Precision = precision
+	}
+
+	if len(opts["downsample"]) >= 1 {
+		window, err := time.ParseDuration(opts["downsample"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `downsample` flag - %v", err)
+		}
+		if window < time.Second || window%time.Second != 0 {
+			return nil, fmt.Errorf("`downsample` flag has to be a whole number of seconds, got %v", window)
+		}
+		config.DownsampleWindow = window
+	}
+
+	if len(opts["downsample_metrics"]) >= 1 {
+		config.DownsampleMetrics = strings.Split(opts["downsample_metrics"][0], ",")
+	}
+
+	if len(opts["db_by_type"]) >= 1 {
+		dbNameByType, err := parseDbNameByType(opts["db_by_type"][0])
+		if err != nil {
+			return nil, err
+		}
+		config.DbNameByType = dbNameByType
+	}
+
 	return &config, nil
 }
@@ -89,10 +89,7 @@ func setupProducer(sinkBrokerHosts []string, topic string, brokerConf kafka.Brok
 	defer broker.Close()
 
 	//create kafka producer
-	conf := kafka.NewProducerConf()
-	conf.RequiredAcks = proto.RequiredAcksLocal
-	conf.Compression = compression
-	producer := broker.Producer(conf)
+	producer := broker.Producer(newProducerConf(compression))
 
 	// create RoundRobinProducer with the default producer.
 	count, err := broker.PartitionCount(topic)
@@ -105,6 +102,13 @@ func setupProducer(sinkBrokerHosts []string, topic string, brokerConf kafka.Brok
 	return sinkProducer, nil
 }
 
+func newProducerConf(compression proto.Compression) kafka.ProducerConf {
+	conf := kafka.NewProducerConf()
+	conf.RequiredAcks = proto.RequiredAcksLocal
+	conf.Compression = compression
+	return conf
+}
+
 func getTopic(opts map[string][]string, topicType string) (string, error) {
 	var topic string
 	switch topicType {
@@ -133,8 +137,13 @@ func getCompression(opts map[string][]string) (proto.Compression, error) {
 		return proto.CompressionNone, nil
 	case "gzip":
 		return proto.CompressionGzip, nil
+	case "snappy":
+		return proto.CompressionSnappy, nil
+	case "lz4", "zstd":
+		// Not supported by the message format of the Kafka client.
+		return proto.CompressionNone, fmt.Errorf("Compression '%s' is not supported by the Kafka client. Use none, gzip or snappy", comp)
 	default:
-		return proto.CompressionNone, fmt.Errorf("Compression '%s' is illegal. Use none or gzip", comp)
+		return proto.CompressionNone, fmt.Errorf("Compression '%s' is illegal. Use none, gzip or snappy", comp)
 	}
 }
 
@@ -0,0 +1,50 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/optiopay/kafka/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProducerCompression(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		expected proto.Compression
+	}{
+		{"", proto.CompressionNone},
+		{"compression=none", proto.CompressionNone},
+		{"compression=gzip", proto.CompressionGzip},
+		{"compression=snappy", proto.CompressionSnappy},
+	} {
+		opts, err := url.ParseQuery(tc.query)
+		assert.NoError(t, err)
+		compression, err := getCompression(opts)
+		assert.NoError(t, err, tc.query)
+		conf := newProducerConf(compression)
+		assert.Equal(t, tc.expected, conf.Compression, tc.query)
+		assert.Equal(t, int16(proto.RequiredAcksLocal), conf.RequiredAcks)
+	}
+
+	for _, query := range []string{"compression=lz4", "compression=zstd", "compression=brotli"} {
+		opts, err := url.ParseQuery(query)
+		assert.NoError(t, err)
+		_, err = getCompression(opts)
+		assert.Error(t, err, query)
+	}
+}
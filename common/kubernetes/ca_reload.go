@@ -0,0 +1,156 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	kube_rest "k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+// Minimum time between two reads of the CA file, unless a request failed.
+const defaultCAReloadInterval = time.Minute
+
+// caReloadingTransport sends the requests to the API server through a transport trusting
+// the current content of the CA file. The file is read again at most once per interval,
+// and right after a failed request, so that a rotated CA is picked up without a restart.
+// The transport built by the client is used until the content of the file changes.
+type caReloadingTransport struct {
+	caFile   string
+	tls      kube_rest.TLSClientConfig
+	interval time.Duration
+
+	lock sync.Mutex
+	// Content of the CA file trusted by rt.
+	caData    []byte
+	rt        http.RoundTripper
+	lastCheck time.Time
+	// Whether the file has to be read before the next request.
+	recheck bool
+}
+
+func newCAReloadingTransport(tls kube_rest.TLSClientConfig, rt http.RoundTripper, interval time.Duration) *caReloadingTransport {
+	caData, err := ioutil.ReadFile(tls.CAFile)
+	if err != nil {
+		glog.Errorf("Failed to read the CA file %s: %v", tls.CAFile, err)
+	}
+	return &caReloadingTransport{
+		caFile:    tls.CAFile,
+		tls:       tls,
+		interval:  interval,
+		caData:    caData,
+		rt:        rt,
+		lastCheck: time.Now(),
+	}
+}
+
+func (this *caReloadingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := this.transport().RoundTrip(req)
+	if err != nil {
+		this.lock.Lock()
+		this.recheck = true
+		this.lock.Unlock()
+	}
+	return resp, err
+}
+
+// transport returns the transport to use, rebuilt if the content of the CA file changed.
+// An unreadable or invalid CA file keeps the current transport.
+func (this *caReloadingTransport) transport() http.RoundTripper {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	now := time.Now()
+	if !this.recheck && now.Sub(this.lastCheck) < this.interval {
+		return this.rt
+	}
+	this.recheck = false
+	this.lastCheck = now
+
+	caData, err := ioutil.ReadFile(this.caFile)
+	if err != nil {
+		glog.Errorf("Failed to read the CA file %s: %v", this.caFile, err)
+		return this.rt
+	}
+	if bytes.Equal(caData, this.caData) {
+		return this.rt
+	}
+	rt, err := this.newTransport(caData)
+	if err != nil {
+		glog.Errorf("Failed to reload the CA file %s, keeping the previous CA: %v", this.caFile, err)
+		return this.rt
+	}
+	glog.Infof("Reloaded the CA file %s", this.caFile)
+	this.caData = caData
+	this.rt = rt
+	return this.rt
+}
+
+// newTransport returns a transport like the ones of the client, trusting the given CA.
+func (this *caReloadingTransport) newTransport(caData []byte) (http.RoundTripper, error) {
+	// A partially written file would otherwise silently trust nothing.
+	if !x509.NewCertPool().AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no valid certificate found")
+	}
+	tlsConfig, err := transport.TLSConfigFor(&transport.Config{
+		TLS: transport.TLSConfig{
+			CAData:   caData,
+			CertFile: this.tls.CertFile,
+			CertData: this.tls.CertData,
+			KeyFile:  this.tls.KeyFile,
+			KeyData:  this.tls.KeyData,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return utilnet.SetTransportDefaults(&http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     tlsConfig,
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+	}), nil
+}
+
+// reloadCA makes the clients created from the config pick up the changes of its CA file.
+// The CA data set in the config takes precedence over the file and is never reloaded.
+func reloadCA(config *kube_rest.Config, interval time.Duration) {
+	if config.CAFile == "" || len(config.CAData) > 0 || config.Insecure {
+		return
+	}
+	tls := config.TLSClientConfig
+	wrapTransport := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		// The transport trusting the CA is the innermost one, as it is replaced on reload.
+		var reloading http.RoundTripper = newCAReloadingTransport(tls, rt, interval)
+		if wrapTransport != nil {
+			reloading = wrapTransport(reloading)
+		}
+		return reloading
+	}
+}
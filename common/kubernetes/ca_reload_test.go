@@ -0,0 +1,194 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kube_rest "k8s.io/client-go/rest"
+)
+
+// selfSignedCert returns a certificate for 127.0.0.1 that is its own CA, and its PEM encoding.
+func selfSignedCert(t *testing.T, name string) (tls.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// rotatingServer is a TLS server whose certificate can be replaced.
+type rotatingServer struct {
+	*httptest.Server
+	lock sync.Mutex
+	cert tls.Certificate
+}
+
+func newRotatingServer(cert tls.Certificate) *rotatingServer {
+	server := &rotatingServer{cert: cert}
+	server.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.TLS = &tls.Config{
+		// Called for every handshake, unlike GetCertificate without SNI.
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			server.lock.Lock()
+			defer server.lock.Unlock()
+			return &tls.Config{Certificates: []tls.Certificate{server.cert}}, nil
+		},
+	}
+	server.StartTLS()
+	return server
+}
+
+func (this *rotatingServer) rotate(cert tls.Certificate) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.cert = cert
+	// Existing connections keep the old certificate.
+	this.CloseClientConnections()
+}
+
+func get(client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func TestReloadCAAfterRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ca_reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	oldCert, oldCA := selfSignedCert(t, "old-ca")
+	newCert, newCA := selfSignedCert(t, "new-ca")
+	server := newRotatingServer(oldCert)
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, ioutil.WriteFile(caFile, oldCA, 0644))
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+    certificate-authority: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+current-context: test
+`, server.URL, caFile)
+	authFile := filepath.Join(dir, "kubeconfig")
+	require.NoError(t, ioutil.WriteFile(authFile, []byte(kubeconfig), 0644))
+
+	uri, err := url.Parse("?inClusterConfig=false&auth=" + authFile)
+	require.NoError(t, err)
+	config, err := GetKubeClientConfig(uri)
+	require.NoError(t, err)
+	rt, err := kube_rest.TransportFor(config)
+	require.NoError(t, err)
+	client := &http.Client{Transport: rt}
+	require.NoError(t, get(client, server.URL))
+
+	// The server switches to a certificate of the new CA before the CA file is updated.
+	server.rotate(newCert)
+	assert.Error(t, get(client, server.URL))
+
+	// The failed request makes the next one read the CA file again.
+	require.NoError(t, ioutil.WriteFile(caFile, append(oldCA, newCA...), 0644))
+	assert.NoError(t, get(client, server.URL))
+}
+
+func TestCAReloadingTransportInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ca_reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	_, oldCA := selfSignedCert(t, "old-ca")
+	_, newCA := selfSignedCert(t, "new-ca")
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, ioutil.WriteFile(caFile, oldCA, 0644))
+
+	initial := &http.Transport{}
+	reloading := newCAReloadingTransport(kube_rest.TLSClientConfig{CAFile: caFile}, initial, time.Hour)
+	assert.Equal(t, initial, reloading.transport())
+
+	// The file is not read again within the interval.
+	require.NoError(t, ioutil.WriteFile(caFile, newCA, 0644))
+	assert.Equal(t, initial, reloading.transport())
+
+	reloading.lastCheck = time.Now().Add(-2 * time.Hour)
+	reloaded := reloading.transport()
+	assert.NotEqual(t, initial, reloaded)
+	assert.Equal(t, newCA, reloading.caData)
+
+	// An invalid CA keeps the current transport.
+	require.NoError(t, ioutil.WriteFile(caFile, []byte("not a certificate"), 0644))
+	reloading.recheck = true
+	assert.Equal(t, reloaded, reloading.transport())
+	assert.Equal(t, newCA, reloading.caData)
+}
+
+func TestReloadCAOnlyWithCAFile(t *testing.T) {
+	config := &kube_rest.Config{}
+	reloadCA(config, time.Minute)
+	assert.Nil(t, config.WrapTransport)
+
+	config = &kube_rest.Config{TLSClientConfig: kube_rest.TLSClientConfig{CAFile: "ca.crt"}, Insecure: true}
+	reloadCA(config, time.Minute)
+	assert.Nil(t, config.WrapTransport)
+
+	config = &kube_rest.Config{TLSClientConfig: kube_rest.TLSClientConfig{CAFile: "ca.crt"}}
+	reloadCA(config, time.Minute)
+	assert.NotNil(t, config.WrapTransport)
+}
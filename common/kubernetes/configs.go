@@ -34,6 +34,7 @@ const (
 	defaultUseServiceAccount  = false
 	defaultServiceAccountFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 	defaultInClusterConfig    = true
+	defaultReloadCA           = true
 )
 
 func getConfigOverrides(uri *url.URL) (*kubeClientCmd.ConfigOverrides, error) {
@@ -146,6 +147,17 @@ func GetKubeClientConfig(uri *url.URL) (*kube_rest.Config, error) {
 		}
 	}
 
+	reloadCAFile := defaultReloadCA
+	if len(opts["reloadCA"]) >= 1 {
+		reloadCAFile, err = strconv.ParseBool(opts["reloadCA"][0])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if reloadCAFile {
+		reloadCA(kubeConfig, defaultCAReloadInterval)
+	}
+
 	kubeConfig.ContentType = "application/vnd.kubernetes.protobuf"
 
 	return kubeConfig, nil
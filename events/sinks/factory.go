@@ -25,6 +25,7 @@ import (
 	"k8s.io/heapster/events/sinks/influxdb"
 	"k8s.io/heapster/events/sinks/kafka"
 	"k8s.io/heapster/events/sinks/log"
+	"k8s.io/heapster/events/sinks/metrics"
 	"k8s.io/heapster/events/sinks/riemann"
 
 	"github.com/golang/glog"
@@ -49,6 +50,8 @@ func (this *SinkFactory) Build(uri flags.Uri) (core.EventSink, error) {
 		return riemann.CreateRiemannSink(&uri.Val)
 	case "honeycomb":
 		return honeycomb.NewHoneycombSink(&uri.Val)
+	case "metrics":
+		return metrics.CreateMetricsSink()
 	default:
 		return nil, fmt.Errorf("Sink not recognized: %s", uri.Key)
 	}
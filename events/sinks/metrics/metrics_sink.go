@@ -0,0 +1,107 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/heapster/events/core"
+)
+
+// Time after which an event that was not updated is forgotten. Kubernetes keeps the events
+// for an hour by default, so an update arriving later is counted as a new event.
+const eventRetention = time.Hour
+
+var (
+	// The number of occurrences of the exported events.
+	eventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "eventer",
+			Name:      "events_total",
+			Help:      "The number of occurrences of the Kubernetes events, by namespace, reason and type.",
+		},
+		[]string{"namespace", "reason", "type"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(eventsTotal)
+}
+
+type seenEvent struct {
+	count    int32
+	lastSeen time.Time
+}
+
+// MetricsSink counts the events on the /metrics endpoint of the Eventer, so that the event
+// rates can be graphed and alerted on. Kubernetes folds repeated events into a single event
+// with an increasing count, so every update only adds the occurrences since the previous one.
+type MetricsSink struct {
+	lock sync.Mutex
+	// Latest count of every event, by UID.
+	seen map[types.UID]seenEvent
+}
+
+func (this *MetricsSink) Name() string {
+	return "MetricsSink"
+}
+
+func (this *MetricsSink) Stop() {
+	// Do nothing.
+}
+
+func (this *MetricsSink) ExportEvents(batch *core.EventBatch) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for _, event := range batch.Events {
+		if occurrences := this.newOccurrences(event, batch.Timestamp); occurrences > 0 {
+			eventsTotal.WithLabelValues(event.Namespace, event.Reason, event.Type).Add(float64(occurrences))
+		}
+	}
+	for uid, seen := range this.seen {
+		if batch.Timestamp.Sub(seen.lastSeen) > eventRetention {
+			delete(this.seen, uid)
+		}
+	}
+}
+
+// newOccurrences returns the number of occurrences of the event since it was last seen.
+func (this *MetricsSink) newOccurrences(event *kube_api.Event, timestamp time.Time) int32 {
+	count := event.Count
+	if count < 1 {
+		count = 1
+	}
+	previous, found := this.seen[event.UID]
+	this.seen[event.UID] = seenEvent{count: count, lastSeen: timestamp}
+	if !found {
+		return count
+	}
+	if count < previous.count {
+		// The event was recreated with the same UID, which should not happen.
+		return count
+	}
+	return count - previous.count
+}
+
+func CreateMetricsSink() (*MetricsSink, error) {
+	return &MetricsSink{
+		seen: make(map[types.UID]seenEvent),
+	}, nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+
+	"k8s.io/heapster/events/core"
+)
+
+func event(uid, namespace, reason, eventType string, count int32) *kube_api.Event {
+	return &kube_api.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:       types.UID(uid),
+			Namespace: namespace,
+		},
+		Reason: reason,
+		Type:   eventType,
+		Count:  count,
+	}
+}
+
+func eventCount(t *testing.T, namespace, reason, eventType string) float64 {
+	metric := &dto.Metric{}
+	require.NoError(t, eventsTotal.WithLabelValues(namespace, reason, eventType).Write(metric))
+	return metric.GetCounter().GetValue()
+}
+
+func TestCountEventsByReasonAndType(t *testing.T) {
+	sink, err := CreateMetricsSink()
+	require.NoError(t, err)
+	now := time.Now()
+	sink.ExportEvents(&core.EventBatch{
+		Timestamp: now,
+		Events: []*kube_api.Event{
+			event("1", "count-ns", "BackOff", kube_api.EventTypeWarning, 1),
+			event("2", "count-ns", "BackOff", kube_api.EventTypeWarning, 3),
+			event("3", "count-ns", "Pulled", kube_api.EventTypeNormal, 1),
+			event("4", "count-ns", "Pulled", kube_api.EventTypeNormal, 0),
+		},
+	})
+	assert.Equal(t, float64(4), eventCount(t, "count-ns", "BackOff", kube_api.EventTypeWarning))
+	assert.Equal(t, float64(2), eventCount(t, "count-ns", "Pulled", kube_api.EventTypeNormal))
+	assert.Equal(t, float64(0), eventCount(t, "count-ns", "Pulled", kube_api.EventTypeWarning))
+
+	// Updates of a folded event only add the new occurrences.
+	sink.ExportEvents(&core.EventBatch{
+		Timestamp: now.Add(time.Minute),
+		Events: []*kube_api.Event{
+			event("2", "count-ns", "BackOff", kube_api.EventTypeWarning, 5),
+			event("3", "count-ns", "Pulled", kube_api.EventTypeNormal, 1),
+		},
+	})
+	assert.Equal(t, float64(6), eventCount(t, "count-ns", "BackOff", kube_api.EventTypeWarning))
+	assert.Equal(t, float64(2), eventCount(t, "count-ns", "Pulled", kube_api.EventTypeNormal))
+}
+
+func TestForgetOldEvents(t *testing.T) {
+	sink, err := CreateMetricsSink()
+	require.NoError(t, err)
+	now := time.Now()
+	sink.ExportEvents(&core.EventBatch{
+		Timestamp: now,
+		Events:    []*kube_api.Event{event("1", "forget-ns", "BackOff", kube_api.EventTypeWarning, 2)},
+	})
+	sink.ExportEvents(&core.EventBatch{
+		Timestamp: now.Add(30 * time.Minute),
+		Events:    []*kube_api.Event{event("2", "forget-ns", "BackOff", kube_api.EventTypeWarning, 1)},
+	})
+	sink.ExportEvents(&core.EventBatch{Timestamp: now.Add(2 * time.Hour)})
+	assert.Empty(t, sink.seen)
+	assert.Equal(t, float64(3), eventCount(t, "forget-ns", "BackOff", kube_api.EventTypeWarning))
+}
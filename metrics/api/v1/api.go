@@ -145,6 +145,8 @@ func convertMetricDescriptor(md core.MetricDescriptor) types.MetricDescriptor {
 		result.Units = "ns"
 	case core.UnitsMillicores:
 		result.Units = "millicores"
+	case core.UnitsSeconds:
+		result.Units = "s"
 	}
 	return result
 }
@@ -0,0 +1,100 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/golang/protobuf/proto"
+	"github.com/ugorji/go/codec"
+
+	"k8s.io/heapster/metrics/api/v1/types"
+)
+
+// Compact encodings of the model API responses, served to the clients sending them in
+// their Accept header.
+const (
+	MIME_PROTOBUF = "application/x-protobuf"
+	MIME_MSGPACK  = "application/x-msgpack"
+)
+
+var errNotDecodable = errors.New("request bodies can only be decoded from JSON")
+
+func init() {
+	restful.RegisterEntityAccessor(MIME_PROTOBUF, protobufEntityAccess{})
+	restful.RegisterEntityAccessor(MIME_MSGPACK, msgpackEntityAccess{})
+}
+
+// toProtoMessage converts a response of the model API to its message of model.proto.
+func toProtoMessage(value interface{}) (proto.Message, error) {
+	switch v := value.(type) {
+	case types.MetricResult:
+		return v.ToProto(), nil
+	case types.MetricResultList:
+		return v.ToProto(), nil
+	case []string:
+		return &types.ProtoStringList{Items: v}, nil
+	}
+	return nil, fmt.Errorf("no protocol buffer message for %T", value)
+}
+
+type protobufEntityAccess struct{}
+
+func (protobufEntityAccess) Read(req *restful.Request, v interface{}) error {
+	return errNotDecodable
+}
+
+func (protobufEntityAccess) Write(resp *restful.Response, status int, v interface{}) error {
+	if v == nil {
+		resp.WriteHeader(status)
+		return nil
+	}
+	message, err := toProtoMessage(v)
+	if err != nil {
+		resp.WriteHeader(http.StatusNotAcceptable)
+		return err
+	}
+	data, err := proto.Marshal(message)
+	if err != nil {
+		resp.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+	resp.Header().Set(restful.HEADER_ContentType, MIME_PROTOBUF)
+	resp.WriteHeader(status)
+	_, err = resp.Write(data)
+	return err
+}
+
+// The msgpack encoding uses the JSON field names.
+var msgpackHandle = &codec.MsgpackHandle{}
+
+type msgpackEntityAccess struct{}
+
+func (msgpackEntityAccess) Read(req *restful.Request, v interface{}) error {
+	return errNotDecodable
+}
+
+func (msgpackEntityAccess) Write(resp *restful.Response, status int, v interface{}) error {
+	if v == nil {
+		resp.WriteHeader(status)
+		return nil
+	}
+	resp.Header().Set(restful.HEADER_ContentType, MIME_MSGPACK)
+	resp.WriteHeader(status)
+	return codec.NewEncoder(resp, msgpackHandle).Encode(v)
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+
+	"k8s.io/heapster/metrics/api/v1/types"
+	"k8s.io/heapster/metrics/core"
+	metricsink "k8s.io/heapster/metrics/sinks/metric"
+)
+
+func encodingTestServer(now time.Time) *httptest.Server {
+	metricSink := metricsink.NewMetricSink(time.Hour, time.Hour, []string{})
+	for i, timestamp := range []time.Time{now.Add(-20 * time.Second), now.Add(-10 * time.Second)} {
+		metricSink.ExportData(&core.DataBatch{
+			Timestamp: timestamp,
+			MetricSets: map[string]*core.MetricSet{
+				core.ClusterKey(): {
+					Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeCluster},
+					MetricValues: map[string]core.MetricValue{
+						core.MetricCpuUsageRate.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: int64(100 + i)},
+						"custom/ratio":               {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 0.5},
+					},
+				},
+			},
+		})
+	}
+	container := restful.NewContainer()
+	NewApi(false, metricSink, nil, false).RegisterModel(container)
+	return httptest.NewServer(container)
+}
+
+func get(t *testing.T, url, accept string) []byte {
+	request, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err)
+	request.Header.Set("Accept", accept)
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, accept, response.Header.Get("Content-Type"))
+	body, err := ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+	return body
+}
+
+func TestModelEncodings(t *testing.T) {
+	now := time.Now().Round(time.Second)
+	server := encodingTestServer(now)
+	defer server.Close()
+	start := "?start=" + now.Add(-time.Minute).Format(time.RFC3339)
+
+	for _, path := range []string{"/api/v1/model/metrics/cpu/usage_rate", "/api/v1/model/metrics/custom/ratio"} {
+		var expected types.MetricResult
+		require.NoError(t, json.Unmarshal(get(t, server.URL+path+start, restful.MIME_JSON), &expected))
+		require.Len(t, expected.Metrics, 2, path)
+
+		message := &types.ProtoMetricResult{}
+		require.NoError(t, proto.Unmarshal(get(t, server.URL+path+start, MIME_PROTOBUF), message))
+		assertMetricResult(t, expected, message.ToMetricResult())
+
+		var decoded types.MetricResult
+		require.NoError(t, codec.NewDecoderBytes(get(t, server.URL+path+start, MIME_MSGPACK), msgpackHandle).Decode(&decoded))
+		assertMetricResult(t, expected, decoded)
+	}
+
+	names := &types.ProtoStringList{}
+	require.NoError(t, proto.Unmarshal(get(t, server.URL+"/api/v1/model/metrics/", MIME_PROTOBUF), names))
+	sort.Strings(names.Items)
+	assert.Equal(t, []string{"cpu/usage_rate", "custom/ratio"}, names.Items)
+}
+
+// assertMetricResult compares the results, ignoring the locations of the timestamps.
+func assertMetricResult(t *testing.T, expected, actual types.MetricResult) {
+	assert.True(t, expected.LatestTimestamp.Equal(actual.LatestTimestamp))
+	require.Len(t, actual.Metrics, len(expected.Metrics))
+	for i, point := range expected.Metrics {
+		assert.True(t, point.Timestamp.Equal(actual.Metrics[i].Timestamp))
+		assert.Equal(t, point.Value, actual.Metrics[i].Value)
+		assert.Equal(t, point.FloatValue, actual.Metrics[i].FloatValue)
+	}
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	now := time.Unix(1500000000, 123456789).UTC()
+	floatValue := 1.5
+	list := types.MetricResultList{
+		Items: []types.MetricResult{
+			{
+				Metrics: []types.MetricPoint{
+					{Timestamp: now.Add(-time.Minute), Value: 10},
+					{Timestamp: now, FloatValue: &floatValue},
+				},
+				LatestTimestamp: now,
+			},
+			{
+				Metrics:         []types.MetricPoint{},
+				LatestTimestamp: time.Unix(0, 0).UTC(),
+			},
+		},
+	}
+	message, err := toProtoMessage(list)
+	require.NoError(t, err)
+	data, err := proto.Marshal(message)
+	require.NoError(t, err)
+	decoded := &types.ProtoMetricResultList{}
+	require.NoError(t, proto.Unmarshal(data, decoded))
+	assert.Equal(t, list, decoded.ToMetricResultList())
+
+	_, err = toProtoMessage(types.StatsResponse{})
+	assert.Error(t, err)
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	floatValue := 0.25
+	result := types.MetricResult{
+		Metrics:         []types.MetricPoint{{Timestamp: time.Unix(1500000000, 0).UTC(), Value: 7, FloatValue: &floatValue}},
+		LatestTimestamp: time.Unix(1500000000, 0).UTC(),
+	}
+	var buffer bytes.Buffer
+	require.NoError(t, codec.NewEncoder(&buffer, msgpackHandle).Encode(result))
+	// The field names are the JSON ones.
+	assert.Contains(t, buffer.String(), "latestTimestamp")
+
+	var decoded types.MetricResult
+	require.NoError(t, codec.NewDecoderBytes(buffer.Bytes(), msgpackHandle).Decode(&decoded))
+	assertMetricResult(t, result, decoded)
+}
@@ -268,7 +268,7 @@ func (a *Api) RegisterModel(container *restful.Container) {
 	ws.Path("/api/v1/model").
 		Doc("Root endpoint of the stats model").
 		Consumes("*/*").
-		Produces(restful.MIME_JSON)
+		Produces(restful.MIME_JSON, MIME_PROTOBUF, MIME_MSGPACK)
 
 	addClusterMetricsRoutes(a, ws)
 
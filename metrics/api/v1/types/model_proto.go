@@ -0,0 +1,127 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// Protocol buffer messages of model.proto, converted from and to the JSON types.
+
+type ProtoMetricPoint struct {
+	Timestamp  *timestamp.Timestamp `protobuf:"bytes,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	Value      *uint64              `protobuf:"varint,2,opt,name=value" json:"value,omitempty"`
+	FloatValue *float64             `protobuf:"fixed64,3,opt,name=float_value,json=floatValue" json:"float_value,omitempty"`
+}
+
+func (m *ProtoMetricPoint) Reset()         { *m = ProtoMetricPoint{} }
+func (m *ProtoMetricPoint) String() string { return proto.CompactTextString(m) }
+func (*ProtoMetricPoint) ProtoMessage()    {}
+
+func (m *ProtoMetricPoint) GetValue() uint64 {
+	if m != nil && m.Value != nil {
+		return *m.Value
+	}
+	return 0
+}
+
+type ProtoMetricResult struct {
+	Metrics         []*ProtoMetricPoint  `protobuf:"bytes,1,rep,name=metrics" json:"metrics,omitempty"`
+	LatestTimestamp *timestamp.Timestamp `protobuf:"bytes,2,opt,name=latest_timestamp,json=latestTimestamp" json:"latest_timestamp,omitempty"`
+}
+
+func (m *ProtoMetricResult) Reset()         { *m = ProtoMetricResult{} }
+func (m *ProtoMetricResult) String() string { return proto.CompactTextString(m) }
+func (*ProtoMetricResult) ProtoMessage()    {}
+
+type ProtoMetricResultList struct {
+	Items []*ProtoMetricResult `protobuf:"bytes,1,rep,name=items" json:"items,omitempty"`
+}
+
+func (m *ProtoMetricResultList) Reset()         { *m = ProtoMetricResultList{} }
+func (m *ProtoMetricResultList) String() string { return proto.CompactTextString(m) }
+func (*ProtoMetricResultList) ProtoMessage()    {}
+
+type ProtoStringList struct {
+	Items []string `protobuf:"bytes,1,rep,name=items" json:"items,omitempty"`
+}
+
+func (m *ProtoStringList) Reset()         { *m = ProtoStringList{} }
+func (m *ProtoStringList) String() string { return proto.CompactTextString(m) }
+func (*ProtoStringList) ProtoMessage()    {}
+
+func toProtoTimestamp(t time.Time) *timestamp.Timestamp {
+	return &timestamp.Timestamp{Seconds: t.Unix(), Nanos: int32(t.Nanosecond())}
+}
+
+func fromProtoTimestamp(t *timestamp.Timestamp) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return time.Unix(t.Seconds, int64(t.Nanos)).UTC()
+}
+
+func (this *MetricResult) ToProto() *ProtoMetricResult {
+	result := &ProtoMetricResult{
+		Metrics:         make([]*ProtoMetricPoint, 0, len(this.Metrics)),
+		LatestTimestamp: toProtoTimestamp(this.LatestTimestamp),
+	}
+	for _, point := range this.Metrics {
+		result.Metrics = append(result.Metrics, &ProtoMetricPoint{
+			Timestamp:  toProtoTimestamp(point.Timestamp),
+			Value:      proto.Uint64(point.Value),
+			FloatValue: point.FloatValue,
+		})
+	}
+	return result
+}
+
+func (this *ProtoMetricResult) ToMetricResult() MetricResult {
+	result := MetricResult{
+		Metrics:         make([]MetricPoint, 0, len(this.Metrics)),
+		LatestTimestamp: fromProtoTimestamp(this.LatestTimestamp),
+	}
+	for _, point := range this.Metrics {
+		result.Metrics = append(result.Metrics, MetricPoint{
+			Timestamp:  fromProtoTimestamp(point.Timestamp),
+			Value:      point.GetValue(),
+			FloatValue: point.FloatValue,
+		})
+	}
+	return result
+}
+
+func (this *MetricResultList) ToProto() *ProtoMetricResultList {
+	result := &ProtoMetricResultList{
+		Items: make([]*ProtoMetricResult, 0, len(this.Items)),
+	}
+	for i := range this.Items {
+		result.Items = append(result.Items, this.Items[i].ToProto())
+	}
+	return result
+}
+
+func (this *ProtoMetricResultList) ToMetricResultList() MetricResultList {
+	result := MetricResultList{
+		Items: make([]MetricResult, 0, len(this.Items)),
+	}
+	for _, item := range this.Items {
+		result.Items = append(result.Items, item.ToMetricResult())
+	}
+	return result
+}
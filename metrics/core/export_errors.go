@@ -0,0 +1,57 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"sync"
+)
+
+// ExportErrorReporter is implemented by the sinks reporting whether their exports failed.
+type ExportErrorReporter interface {
+	// TakeExportError returns the first write error since the previous call, if any.
+	TakeExportError() error
+}
+
+// ExportErrors records the write errors of a sink. Sinks embed it and call
+// RecordExportError in their write paths to implement ExportErrorReporter.
+type ExportErrors struct {
+	lock sync.Mutex
+	err  error
+}
+
+func (this *ExportErrors) RecordExportError(err error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.err == nil {
+		this.err = err
+	}
+}
+
+func (this *ExportErrors) TakeExportError() error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	err := this.err
+	this.err = nil
+	return err
+}
+
+// TakeExportError returns the write error of the last export of the sink, or nil if
+// the export succeeded or the sink does not report errors.
+func TakeExportError(sink DataSink) error {
+	if reporter, ok := sink.(ExportErrorReporter); ok {
+		return reporter.TakeExportError()
+	}
+	return nil
+}
@@ -169,3 +169,12 @@ type AsHistoricalSource interface {
 	// Historical returns the historical data access interface for this sink
 	Historical() HistoricalSource
 }
+
+// HistoricalSourceOf returns the historical data access interface of the sink, or nil if
+// the sink does not support historical access.
+func HistoricalSourceOf(sink DataSink) HistoricalSource {
+	if asHistSource, ok := sink.(AsHistoricalSource); ok {
+		return asHistSource.Historical()
+	}
+	return nil
+}
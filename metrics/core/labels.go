@@ -27,6 +27,11 @@ var (
 	MetricSetTypeNamespace       = "ns"
 	MetricSetTypeNode            = "node"
 	MetricSetTypeCluster         = "cluster"
+	MetricSetTypeLabelGroup      = "label_group"
+	MetricSetTypeWorkload        = "workload"
+	MetricSetTypeZone            = "zone"
+	MetricSetTypeRegion          = "region"
+	MetricSetTypeService         = "service"
 
 	LabelPodId = LabelDescriptor{
 		Key:         "pod_id",
@@ -72,6 +77,22 @@ var (
 		Key:         "container_base_image",
 		Description: "User-defined image name that is run inside the container",
 	}
+	LabelContainerImage = LabelDescriptor{
+		Key:         "container_image",
+		Description: "Full image of the container, including the tag or digest, if the base image is normalized",
+	}
+	LabelAppVersion = LabelDescriptor{
+		Key:         "app_version",
+		Description: "Version of the container, from the tag or digest of its image",
+	}
+	LabelContainerType = LabelDescriptor{
+		Key:         "container_type",
+		Description: "Type of the container in its pod: init, app or ephemeral",
+	}
+	LabelPriorityClass = LabelDescriptor{
+		Key:         "priority_class",
+		Description: "Name of the priority class of the pod",
+	}
 	// The label is populated only for GCM
 	LabelCustomMetricName = LabelDescriptor{
 		Key:         "custom_metric_name",
@@ -89,6 +110,46 @@ var (
 		Key:         "schedulable",
 		Description: "Node schedulable status.",
 	}
+	LabelContainerRuntime = LabelDescriptor{
+		Key:         "container_runtime",
+		Description: "Container runtime name and version of the node, e.g. docker://1.13.1.",
+	}
+	LabelWorkloadKind = LabelDescriptor{
+		Key:         "workload_kind",
+		Description: "Kind of the workload controlling the pod, e.g. Deployment.",
+	}
+	LabelWorkloadName = LabelDescriptor{
+		Key:         "workload_name",
+		Description: "Name of the workload controlling the pod.",
+	}
+	LabelZone = LabelDescriptor{
+		Key:         "zone",
+		Description: "Zone of the node, from its topology labels.",
+	}
+	LabelRegion = LabelDescriptor{
+		Key:         "region",
+		Description: "Region of the node, from its topology labels.",
+	}
+	LabelAgeBucket = LabelDescriptor{
+		Key:         "age_bucket",
+		Description: "Time since the container was started, one of <5m, <1h, <1d or >=1d.",
+	}
+	LabelFlapping = LabelDescriptor{
+		Key:         "flapping",
+		Description: "Whether the container restarts faster than the flapping threshold.",
+	}
+	LabelPvcName = LabelDescriptor{
+		Key:         "pvc_name",
+		Description: "Name of the persistent volume claim backing the filesystem.",
+	}
+	LabelServiceName = LabelDescriptor{
+		Key:         "service_name",
+		Description: "Name of the service backed by the pods.",
+	}
+	LabelResourceName = LabelDescriptor{
+		Key:         "resource_name",
+		Description: "Name of the extended resource, e.g. nvidia.com/gpu.",
+	}
 	LabelVolumeName = LabelDescriptor{
 		Key:         "volume_name",
 		Description: "The name of the volume.",
@@ -141,6 +202,10 @@ var customMetricLabels = []LabelDescriptor{
 	LabelCustomMetricName,
 }
 
+var extendedResourceLabels = []LabelDescriptor{
+	LabelResourceName,
+}
+
 var acceleratorLabels = []LabelDescriptor{
 	LabelAcceleratorMake,
 	LabelAcceleratorModel,
@@ -32,6 +32,7 @@ var StandardMetrics = []Metric{
 	MetricMemoryUsage,
 	MetricMemoryRSS,
 	MetricMemoryCache,
+	MetricMemorySwap,
 	MetricMemoryWorkingSet,
 	MetricMemoryPageFaults,
 	MetricMemoryMajorPageFaults,
@@ -70,6 +71,110 @@ var RateMetricsMapping = map[string]Metric{
 	MetricDiskIORead.MetricDescriptor.Name:            MetricDiskIOReadRate,
 	MetricDiskIOWrite.MetricDescriptor.Name:           MetricDiskIOWriteRate}
 
+// Filesystem usage of a node, split by the root filesystem and the filesystem
+// holding the container images. Provided by Kubelet/cadvisor.
+var NodeFilesystemMetrics = []Metric{
+	MetricNodeFsUsage,
+	MetricNodeFsLimit,
+	MetricNodeImageFsUsage,
+	MetricNodeImageFsLimit,
+}
+
+// TCP connections of the pod network namespace. Provided by the kubelet source if
+// cadvisor collects the TCP stats.
+var PodNetworkMetrics = []Metric{
+	MetricPodNetworkTcpConnections,
+}
+
+// Describe the quality of the data reported by a node. Provided by the kubelet source.
+var NodeHealthMetrics = []Metric{
+	MetricNodeClockSkew,
+	MetricNodeKubeletReachable,
+}
+
+// CPU scheduler statistics of a container. Provided by the kubelet source if
+// reported by cadvisor.
+var ContainerSchedulerMetrics = []Metric{
+	MetricContainerCpuWaitTime,
+	MetricContainerCpuThrottledPeriods,
+}
+
+// Container statistics only served by the v2 cadvisor API. Provided by the kubelet
+// source when collecting from it.
+var ContainerCadvisorV2Metrics = []Metric{
+	MetricContainerCpuInstantUsageRate,
+	MetricContainerInodesUsed,
+}
+
+// Health of the control plane, set on the cluster metric set. Provided by the
+// API server source if enabled.
+var ApiServerMetrics = []Metric{
+	MetricApiServerRequestCount,
+	MetricApiServerRequestErrorCount,
+	MetricApiServerInflightRequests,
+	MetricEtcdObjectCount,
+}
+
+// Computed by processors based on other metrics and the state of previous batches.
+var DerivedMetrics = []Metric{
+	MetricContainerUptimeSeconds,
+	MetricContainerAvailability,
+	MetricContainerOOMRisk,
+	MetricContainerOOMRiskSustained,
+	MetricPodNetworkRxRate,
+	MetricPodNetworkTxRate,
+	MetricNamespacePodCountDelta,
+	MetricClusterPodCoverage,
+	MetricContainerCpuStealRatio,
+	MetricContainerCpuUsageNodePct,
+	MetricContainerCpuUsagePerCore,
+	MetricContainerCpuRequestEfficiency,
+	MetricContainerMemoryRequestEfficiency,
+	MetricNamespaceFairShareOverage,
+	MetricContainerCpuUsagePeak,
+	MetricContainerMemoryWorkingSetPeak,
+	MetricContainerRestartVelocity,
+	MetricNodeMemoryPressure,
+	MetricNodeDiskPressure,
+	MetricNodeCpuLimitOversubscription,
+	MetricNodeMemoryLimitOversubscription,
+	MetricPodNetworkTcpConnectionRate,
+	MetricContainerMemoryRequestHeadroom,
+	MetricContainerTimeOverLimitPct,
+	MetricContainerMemoryGrowthRate,
+	MetricContainerMemoryGrowthSustained,
+	MetricNamespaceContainersTotal,
+	MetricNamespaceContainersWithoutLimits,
+	MetricNamespaceContainersByAge,
+	MetricNamespacePodsThrottledPct,
+	MetricWorkloadCpuBurstRatio,
+	MetricWorkloadMemoryBurstRatio,
+	MetricContainerInfo,
+	MetricContainerOverprovisioned,
+	MetricContainerWasteCpuCores,
+	MetricNodeContainerCount,
+	MetricNodePodCount,
+	MetricContainerAvailabilityPct,
+	MetricNodeCordonedSince,
+	MetricNodeSchedulableTransitions,
+	MetricClusterCpuUsage,
+	MetricClusterCpuCapacity,
+	MetricClusterCpuUtilization,
+	MetricClusterMemoryUsage,
+	MetricClusterMemoryCapacity,
+	MetricClusterMemoryUtilization,
+	MetricClusterNodeCount,
+	MetricWorkloadReplicaSpreadNodes,
+	MetricWorkloadReplicaSpreadZones,
+	MetricContainerCpuUsageP50,
+	MetricContainerCpuUsageP95,
+	MetricContainerCpuUsageP99,
+	MetricNodeSystemOverheadCpuPct,
+	MetricNodeSystemOverheadMemoryPct,
+	MetricPodNetworkTxTotalBytesRate,
+	MetricPodPriority,
+}
+
 var LabeledMetrics = []Metric{
 	MetricDiskIORead,
 	MetricDiskIOReadRate,
@@ -83,6 +188,8 @@ var LabeledMetrics = []Metric{
 	MetricAcceleratorMemoryTotal,
 	MetricAcceleratorMemoryUsed,
 	MetricAcceleratorDutyCycle,
+	MetricContainerExtendedResourceRequest,
+	MetricContainerExtendedResourceLimit,
 }
 
 var NodeAutoscalingMetrics = []Metric{
@@ -112,6 +219,10 @@ var FilesystemMetrics = []Metric{
 	MetricFilesystemUsage,
 	MetricFilesystemInodes,
 	MetricFilesystemInodesFree,
+	MetricNodeFsUsage,
+	MetricNodeFsLimit,
+	MetricNodeImageFsUsage,
+	MetricNodeImageFsLimit,
 }
 var MemoryMetrics = []Metric{
 	MetricMemoryLimit,
@@ -168,8 +279,8 @@ func MetricFamilyForName(metricName string) MetricFamily {
 	return MetricFamilyGeneral
 }
 
-var AllMetrics = append(append(append(append(StandardMetrics, AdditionalMetrics...), RateMetrics...), LabeledMetrics...),
-	NodeAutoscalingMetrics...)
+var AllMetrics = append(append(append(append(append(append(append(append(append(append(append(StandardMetrics, AdditionalMetrics...), RateMetrics...), LabeledMetrics...),
+	NodeAutoscalingMetrics...), NodeFilesystemMetrics...), NodeHealthMetrics...), ContainerSchedulerMetrics...), ContainerCadvisorV2Metrics...), PodNetworkMetrics...), ApiServerMetrics...), DerivedMetrics...)
 
 // Definition of Standard Metrics.
 var MetricUptime = Metric{
@@ -179,6 +290,7 @@ var MetricUptime = Metric{
 		Type:        MetricCumulative,
 		ValueType:   ValueInt64,
 		Units:       UnitsMilliseconds,
+		NonAdditive: true,
 	},
 	HasValue: func(spec *cadvisor.ContainerSpec) bool {
 		return !spec.CreationTime.IsZero()
@@ -277,6 +389,25 @@ var MetricMemoryRSS = Metric{
 	},
 }
 
+var MetricMemorySwap = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "memory/swap",
+		Description: "Swap memory",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+	},
+	HasValue: func(spec *cadvisor.ContainerSpec) bool {
+		return spec.HasMemory
+	},
+	GetValue: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) MetricValue {
+		return MetricValue{
+			ValueType:  ValueInt64,
+			MetricType: MetricGauge,
+			IntValue:   int64(stat.Memory.Swap)}
+	},
+}
+
 var MetricMemoryWorkingSet = Metric{
 	MetricDescriptor: MetricDescriptor{
 		Name:        "memory/working_set",
@@ -589,6 +720,7 @@ var MetricNodeCpuUtilization = Metric{
 		Type:        MetricGauge,
 		ValueType:   ValueFloat,
 		Units:       UnitsCount,
+		NonAdditive: true,
 	},
 }
 
@@ -599,6 +731,7 @@ var MetricNodeMemoryUtilization = Metric{
 		Type:        MetricGauge,
 		ValueType:   ValueFloat,
 		Units:       UnitsCount,
+		NonAdditive: true,
 	},
 }
 
@@ -622,263 +755,1036 @@ var MetricNodeMemoryReservation = Metric{
 	},
 }
 
-// Labeled metrics
-
-var MetricFilesystemUsage = Metric{
+var MetricNodeFsUsage = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "filesystem/usage",
-		Description: "Total number of bytes consumed on a filesystem",
+		Name:        "node/fs_usage",
+		Description: "Number of bytes consumed on the root filesystem of a node",
 		Type:        MetricGauge,
 		ValueType:   ValueInt64,
 		Units:       UnitsBytes,
-		Labels:      metricLabels,
-	},
-	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
-		return spec.HasFilesystem
-	},
-	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
-		result := make([]LabeledMetric, 0, len(stat.Filesystem))
-		for _, fs := range stat.Filesystem {
-			result = append(result, LabeledMetric{
-				Name: "filesystem/usage",
-				Labels: map[string]string{
-					LabelResourceID.Key: fs.Device,
-				},
-				MetricValue: MetricValue{
-					ValueType:  ValueInt64,
-					MetricType: MetricGauge,
-					IntValue:   int64(fs.Usage),
-				},
-			})
-		}
-		return result
 	},
 }
 
-var MetricFilesystemLimit = Metric{
+var MetricNodeFsLimit = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "filesystem/limit",
-		Description: "The total size of filesystem in bytes",
+		Name:        "node/fs_limit",
+		Description: "The total size of the root filesystem of a node in bytes",
 		Type:        MetricGauge,
 		ValueType:   ValueInt64,
 		Units:       UnitsBytes,
-		Labels:      metricLabels,
-	},
-	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
-		return spec.HasFilesystem
-	},
-	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
-		result := make([]LabeledMetric, 0, len(stat.Filesystem))
-		for _, fs := range stat.Filesystem {
-			result = append(result, LabeledMetric{
-				Name: "filesystem/limit",
-				Labels: map[string]string{
-					LabelResourceID.Key: fs.Device,
-				},
-				MetricValue: MetricValue{
-					ValueType:  ValueInt64,
-					MetricType: MetricGauge,
-					IntValue:   int64(fs.Limit),
-				},
-			})
-		}
-		return result
 	},
 }
 
-var MetricFilesystemAvailable = Metric{
+var MetricNodeImageFsUsage = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "filesystem/available",
-		Description: "The number of available bytes remaining in a the filesystem",
+		Name:        "node/imagefs_usage",
+		Description: "Number of bytes consumed on the filesystem holding the container images of a node",
 		Type:        MetricGauge,
 		ValueType:   ValueInt64,
 		Units:       UnitsBytes,
-		Labels:      metricLabels,
-	},
-	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
-		return spec.HasFilesystem
-	},
-	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
-		result := make([]LabeledMetric, 0, len(stat.Filesystem))
-		for _, fs := range stat.Filesystem {
-			result = append(result, LabeledMetric{
-				Name: "filesystem/available",
-				Labels: map[string]string{
-					LabelResourceID.Key: fs.Device,
-				},
-				MetricValue: MetricValue{
-					ValueType:  ValueInt64,
-					MetricType: MetricGauge,
-					IntValue:   int64(fs.Available),
-				},
-			})
-		}
-		return result
 	},
 }
 
-var MetricFilesystemInodes = Metric{
+var MetricNodeImageFsLimit = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "filesystem/inodes",
-		Description: "Total number of inodes on a filesystem",
+		Name:        "node/imagefs_limit",
+		Description: "The total size of the filesystem holding the container images of a node in bytes",
 		Type:        MetricGauge,
 		ValueType:   ValueInt64,
 		Units:       UnitsBytes,
-		Labels:      metricLabels,
-	},
-	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
-		return spec.HasFilesystem
-	},
-	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
-		result := []LabeledMetric{}
-		for _, fs := range stat.Filesystem {
-			if fs.HasInodes {
-				result = append(result, LabeledMetric{
-					Name: "filesystem/inodes",
-					Labels: map[string]string{
-						LabelResourceID.Key: fs.Device,
-					},
-					MetricValue: MetricValue{
-						ValueType:  ValueInt64,
-						MetricType: MetricGauge,
-						IntValue:   int64(fs.Inodes),
-					},
-				})
-			}
-		}
-		return result
 	},
 }
 
-var MetricFilesystemInodesFree = Metric{
+// Definition of Derived Metrics.
+var MetricContainerUptimeSeconds = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "filesystem/inodes_free",
-		Description: "Free number of inodes on a filesystem",
+		Name:        "container/uptime_seconds",
+		Description: "Number of seconds since the container was (re)started",
 		Type:        MetricGauge,
 		ValueType:   ValueInt64,
-		Units:       UnitsBytes,
-		Labels:      metricLabels,
-	},
-	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
-		return spec.HasFilesystem
+		Units:       UnitsSeconds,
+		NonAdditive: true,
 	},
-	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
-		result := []LabeledMetric{}
-		for _, fs := range stat.Filesystem {
-			if fs.HasInodes {
-				result = append(result, LabeledMetric{
-					Name: "filesystem/inodes_free",
-					Labels: map[string]string{
-						LabelResourceID.Key: fs.Device,
-					},
-					MetricValue: MetricValue{
-						ValueType:  ValueInt64,
-						MetricType: MetricGauge,
-						IntValue:   int64(fs.InodesFree),
-					},
-				})
-			}
-		}
-		return result
+}
+
+var MetricContainerAvailabilityPct = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/availability_pct",
+		Description: "Percentage of the window during which the container was running without restarts, and ready if readiness is taken into account",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
 	},
 }
 
-var MetricAcceleratorMemoryTotal = Metric{
+var MetricContainerInfo = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "accelerator/memory_total",
-		Description: "Total accelerator memory (in bytes)",
-		Labels:      acceleratorLabels,
+		Name:        "container/info",
+		Description: "Always 1, carries the image and version labels of the container",
 		Type:        MetricGauge,
 		ValueType:   ValueInt64,
-		Units:       UnitsBytes,
+		Units:       UnitsCount,
+		NonAdditive: true,
 	},
-	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
-		if len(stat.Accelerators) == 0 {
-			return false
-		}
+}
 
-		return true
+var MetricContainerAvailability = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/availability",
+		Description: "Share of the availability window during which the container was running, adjusted for restarts",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
 	},
-	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
-		result := make([]LabeledMetric, 0, len(stat.Accelerators))
-		for _, ac := range stat.Accelerators {
-			result = append(result, LabeledMetric{
-				Name: "accelerator/memory_total",
-				Labels: map[string]string{
-					LabelAcceleratorMake.Key:  ac.Make,
-					LabelAcceleratorModel.Key: ac.Model,
-					LabelAcceleratorID.Key:    ac.ID,
-				},
-				MetricValue: MetricValue{
-					ValueType:  ValueInt64,
-					MetricType: MetricGauge,
-					IntValue:   int64(ac.MemoryTotal),
-				},
-			})
-		}
-		return result
+}
+
+var MetricContainerOOMRisk = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/oom_risk",
+		Description: "Memory working set as a share of the memory limit, 0 if the container has no limit",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
 	},
 }
 
-var MetricAcceleratorMemoryUsed = Metric{
+var MetricContainerOOMRiskSustained = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "accelerator/memory_used",
-		Description: "Total accelerator memory allocated (in bytes)",
-		Labels:      acceleratorLabels,
+		Name:        "container/oom_risk_sustained",
+		Description: "1 if the OOM risk stayed above the threshold for the whole window, 0 otherwise",
 		Type:        MetricGauge,
 		ValueType:   ValueInt64,
-		Units:       UnitsBytes,
+		Units:       UnitsCount,
+		NonAdditive: true,
 	},
-	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
-		if len(stat.Accelerators) == 0 {
-			return false
-		}
+}
 
-		return true
-	},
-	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
-		result := make([]LabeledMetric, 0, len(stat.Accelerators))
-		for _, ac := range stat.Accelerators {
-			result = append(result, LabeledMetric{
-				Name: "accelerator/memory_used",
-				Labels: map[string]string{
-					LabelAcceleratorMake.Key:  ac.Make,
-					LabelAcceleratorModel.Key: ac.Model,
-					LabelAcceleratorID.Key:    ac.ID,
-				},
-				MetricValue: MetricValue{
-					ValueType:  ValueInt64,
-					MetricType: MetricGauge,
-					IntValue:   int64(ac.MemoryUsed),
-				},
-			})
-		}
-		return result
+var MetricContainerMemoryGrowthRate = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/memory_growth_rate_bytes_per_hour",
+		Description: "Slope of the linear trend of the memory working set of the container over the memory growth window",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsBytes,
 	},
 }
 
-var MetricAcceleratorDutyCycle = Metric{
+var MetricContainerMemoryGrowthSustained = Metric{
 	MetricDescriptor: MetricDescriptor{
-		Name:        "accelerator/duty_cycle",
-		Description: "Percent of time over the past sample period (10s) during which the accelerator was actively processing",
-		Labels:      acceleratorLabels,
+		Name:        "container/memory_growth_sustained",
+		Description: "1 if the memory working set of the container grew steadily over the memory growth window, 0 otherwise",
 		Type:        MetricGauge,
 		ValueType:   ValueInt64,
 		Units:       UnitsCount,
+		NonAdditive: true,
 	},
-	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
-		if len(stat.Accelerators) == 0 {
-			return false
-		}
+}
 
-		return true
+var MetricPodNetworkRxRate = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "pod/network_rx_rate",
+		Description: "Rate of bytes received over the pod network in bytes per second",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
 	},
-	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
-		result := make([]LabeledMetric, 0, len(stat.Accelerators))
-		for _, ac := range stat.Accelerators {
-			result = append(result, LabeledMetric{
-				Name: "accelerator/duty_cycle",
-				Labels: map[string]string{
+}
+
+var MetricPodNetworkTxRate = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "pod/network_tx_rate",
+		Description: "Rate of bytes transmitted over the pod network in bytes per second",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricPodNetworkTxTotalBytesRate = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "pod/network_tx_total_bytes_rate",
+		Description: "Rate of bytes transmitted over the pod network to all destinations, within the cluster or not, in bytes per second",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricPodPriority = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "pod/priority",
+		Description: "Scheduling priority of the pod, resolved from its priority class",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricPodNetworkTcpConnections = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "pod/network_tcp_connections",
+		Description: "Number of TCP connections of the pod network in any state but listening",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricPodNetworkTcpConnectionRate = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "pod/network_tcp_connection_rate",
+		Description: "Increase of the number of TCP connections of the pod network per second since the previous collection",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricNodeClockSkew = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/clock_skew_seconds",
+		Description: "Difference between the clock of the node and the Heapster clock in seconds",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsSeconds,
+		NonAdditive: true,
+	},
+}
+
+var MetricNodeKubeletReachable = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/kubelet_reachable",
+		Description: "1 if the Kubelet of the node passed the health check before the scrape, 0 if its scrape was skipped",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricNodeMemoryPressure = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/memory_pressure",
+		Description: "1 if the available memory of the node is below the eviction threshold or the node reports memory pressure, 0 otherwise",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricNodeDiskPressure = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/disk_pressure",
+		Description: "1 if the available space on the root filesystem of the node is below the eviction threshold or the node reports disk pressure, 0 otherwise",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricNodeCordonedSince = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/cordoned_since",
+		Description: "Time since the epoch at which the node was cordoned, only while it is unschedulable",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsSeconds,
+		NonAdditive: true,
+	},
+}
+
+var MetricNodeSchedulableTransitions = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/schedulable_transitions",
+		Description: "Number of times the node was cordoned or uncordoned",
+		Type:        MetricCumulative,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricNodeContainerCount = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/container_count",
+		Description: "Number of pod containers on the node",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricNodePodCount = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/pod_count",
+		Description: "Number of pods on the node",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricNodeCpuLimitOversubscription = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/cpu_limit_oversubscription",
+		Description: "Sum of the CPU limits of the pods of the node divided by its allocatable CPU",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricNodeMemoryLimitOversubscription = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/memory_limit_oversubscription",
+		Description: "Sum of the memory limits of the pods of the node divided by its allocatable memory",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricNamespaceContainersTotal = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "namespace/containers_total",
+		Description: "Number of containers in the namespace whose limits are known",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricNamespaceContainersWithoutLimits = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "namespace/containers_without_limits",
+		Description: "Number of containers in the namespace missing a CPU or a memory limit",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricNamespaceContainersByAge = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "namespace/containers_by_age",
+		Description: "Number of containers in the namespace started within the age bucket",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		Labels:      []LabelDescriptor{LabelAgeBucket},
+	},
+}
+
+var MetricNamespacePodCountDelta = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "namespace/pod_count_delta",
+		Description: "Change of the number of pods in the namespace since the previous collection",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricContainerCpuUsagePeak = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/cpu_usage_peak",
+		Description: "Maximum CPU usage rate of the container in millicores over the peak usage window",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricContainerCpuUsageP50 = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/cpu_usage_p50",
+		Description: "50th percentile of the CPU usage rate of the container in millicores over the percentiles window",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricContainerCpuUsageP95 = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/cpu_usage_p95",
+		Description: "95th percentile of the CPU usage rate of the container in millicores over the percentiles window",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricContainerCpuUsageP99 = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/cpu_usage_p99",
+		Description: "99th percentile of the CPU usage rate of the container in millicores over the percentiles window",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricContainerMemoryWorkingSetPeak = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/memory_working_set_peak",
+		Description: "Maximum memory working set of the container over the peak usage window",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+	},
+}
+
+var MetricContainerRestartVelocity = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/restart_velocity",
+		Description: "Number of restarts of the container per hour over the restart velocity window",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricNamespaceFairShareOverage = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "namespace/fair_share_overage",
+		Description: "Share of the cluster capacity by which the usage of the dominant resource of the namespace exceeds its fair share",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricNamespacePodsThrottledPct = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "namespace/pods_throttled_pct",
+		Description: "Percentage of the pods throttled by their CPU limits since the previous collection",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricClusterPodCoverage = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "cluster/pod_coverage_pct",
+		Description: "Percentage of the running pods for which metrics were collected",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricClusterCpuUsage = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "cluster/cpu_usage",
+		Description: "Cpu usage rate of the nodes of the cluster, in millicores",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricClusterCpuCapacity = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "cluster/cpu_capacity",
+		Description: "Cpu capacity of the nodes of the cluster, in millicores",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricClusterCpuUtilization = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "cluster/cpu_utilization",
+		Description: "Cpu usage as a share of the cpu capacity of the nodes of the cluster",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricClusterMemoryUsage = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "cluster/memory_usage",
+		Description: "Memory usage of the nodes of the cluster",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+	},
+}
+
+var MetricClusterMemoryCapacity = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "cluster/memory_capacity",
+		Description: "Memory capacity of the nodes of the cluster",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+	},
+}
+
+var MetricClusterMemoryUtilization = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "cluster/memory_utilization",
+		Description: "Memory usage as a share of the memory capacity of the nodes of the cluster",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricClusterNodeCount = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "cluster/node_count",
+		Description: "Number of nodes summed up in the cluster usage and capacity",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricContainerCpuWaitTime = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/cpu_wait_time",
+		Description: "Cumulative time the container spent waiting on a run queue for a CPU",
+		Type:        MetricCumulative,
+		ValueType:   ValueInt64,
+		Units:       UnitsNanoseconds,
+	},
+}
+
+var MetricContainerCpuThrottledPeriods = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/cpu_throttled_periods",
+		Description: "Cumulative number of CFS periods in which the container was throttled by its CPU limit",
+		Type:        MetricCumulative,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricContainerCpuInstantUsageRate = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/cpu_instant_usage_rate",
+		Description: "CPU usage on all cores in millicores, computed by cadvisor between its last two samples",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricContainerInodesUsed = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/inodes_used",
+		Description: "Number of inodes used by the container",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricContainerCpuStealRatio = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/cpu_steal_ratio",
+		Description: "Share of the time the container was runnable that it spent waiting for a CPU since the previous scrape",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricNodeSystemOverheadCpuPct = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/system_overhead_cpu_pct",
+		Description: "CPU usage rate of the system containers of the node as a percentage of the CPU usage rate of the node",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricNodeSystemOverheadMemoryPct = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "node/system_overhead_memory_pct",
+		Description: "Memory working set of the system containers of the node as a percentage of the memory working set of the node",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricContainerCpuUsageNodePct = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/cpu_usage_node_pct",
+		Description: "CPU usage rate of the container as a percentage of the CPU capacity of its node",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricContainerCpuUsagePerCore = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/cpu_usage_per_core",
+		Description: "CPU usage rate of the container in cores divided by the number of cores of its node, from 0 to 1",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricContainerCpuRequestEfficiency = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/cpu_request_efficiency",
+		Description: "CPU usage rate divided by the CPU request",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricContainerOverprovisioned = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/overprovisioned",
+		Description: "1 if the peak CPU usage of the container stays far below its CPU request, 0 otherwise",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricContainerWasteCpuCores = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/waste_cpu_cores",
+		Description: "CPU cores requested beyond the peak CPU usage",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricWorkloadCpuBurstRatio = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "workload/cpu_burst_ratio",
+		Description: "CPU limits of the containers of the workload divided by their CPU requests",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricWorkloadMemoryBurstRatio = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "workload/memory_burst_ratio",
+		Description: "Memory limits of the containers of the workload divided by their memory requests",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricWorkloadReplicaSpreadNodes = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "workload/replica_spread_nodes",
+		Description: "Number of distinct nodes the pods of the workload run on",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricWorkloadReplicaSpreadZones = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "workload/replica_spread_zones",
+		Description: "Number of distinct zones the pods of the workload run in",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricContainerMemoryRequestEfficiency = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/memory_request_efficiency",
+		Description: "Memory usage divided by the memory request",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricContainerMemoryRequestHeadroom = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/memory_request_headroom",
+		Description: "Memory request minus the memory working set, negative if the working set exceeds the request",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+	},
+}
+
+var MetricContainerTimeOverLimitPct = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/time_over_limit_pct",
+		Description: "Percentage of the time over the window during which the CPU usage rate or the memory working set exceeded the threshold share of the limit",
+		Type:        MetricGauge,
+		ValueType:   ValueFloat,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+}
+
+var MetricApiServerRequestCount = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "apiserver/request_count",
+		Description: "Cumulative number of requests served by the API server",
+		Type:        MetricCumulative,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricApiServerRequestErrorCount = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "apiserver/request_error_count",
+		Description: "Cumulative number of requests answered by the API server with a 5xx status code",
+		Type:        MetricCumulative,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricApiServerInflightRequests = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "apiserver/inflight_requests",
+		Description: "Number of requests currently being served by the API server",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricEtcdObjectCount = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "etcd/object_count",
+		Description: "Number of objects stored in etcd",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+// Labeled metrics
+
+var MetricFilesystemUsage = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "filesystem/usage",
+		Description: "Total number of bytes consumed on a filesystem",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+		Labels:      metricLabels,
+	},
+	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
+		return spec.HasFilesystem
+	},
+	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
+		result := make([]LabeledMetric, 0, len(stat.Filesystem))
+		for _, fs := range stat.Filesystem {
+			result = append(result, LabeledMetric{
+				Name: "filesystem/usage",
+				Labels: map[string]string{
+					LabelResourceID.Key: fs.Device,
+				},
+				MetricValue: MetricValue{
+					ValueType:  ValueInt64,
+					MetricType: MetricGauge,
+					IntValue:   int64(fs.Usage),
+				},
+			})
+		}
+		return result
+	},
+}
+
+var MetricFilesystemLimit = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "filesystem/limit",
+		Description: "The total size of filesystem in bytes",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+		Labels:      metricLabels,
+	},
+	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
+		return spec.HasFilesystem
+	},
+	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
+		result := make([]LabeledMetric, 0, len(stat.Filesystem))
+		for _, fs := range stat.Filesystem {
+			result = append(result, LabeledMetric{
+				Name: "filesystem/limit",
+				Labels: map[string]string{
+					LabelResourceID.Key: fs.Device,
+				},
+				MetricValue: MetricValue{
+					ValueType:  ValueInt64,
+					MetricType: MetricGauge,
+					IntValue:   int64(fs.Limit),
+				},
+			})
+		}
+		return result
+	},
+}
+
+var MetricFilesystemAvailable = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "filesystem/available",
+		Description: "The number of available bytes remaining in a the filesystem",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+		Labels:      metricLabels,
+	},
+	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
+		return spec.HasFilesystem
+	},
+	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
+		result := make([]LabeledMetric, 0, len(stat.Filesystem))
+		for _, fs := range stat.Filesystem {
+			result = append(result, LabeledMetric{
+				Name: "filesystem/available",
+				Labels: map[string]string{
+					LabelResourceID.Key: fs.Device,
+				},
+				MetricValue: MetricValue{
+					ValueType:  ValueInt64,
+					MetricType: MetricGauge,
+					IntValue:   int64(fs.Available),
+				},
+			})
+		}
+		return result
+	},
+}
+
+var MetricFilesystemInodes = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "filesystem/inodes",
+		Description: "Total number of inodes on a filesystem",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+		Labels:      metricLabels,
+	},
+	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
+		return spec.HasFilesystem
+	},
+	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
+		result := []LabeledMetric{}
+		for _, fs := range stat.Filesystem {
+			if fs.HasInodes {
+				result = append(result, LabeledMetric{
+					Name: "filesystem/inodes",
+					Labels: map[string]string{
+						LabelResourceID.Key: fs.Device,
+					},
+					MetricValue: MetricValue{
+						ValueType:  ValueInt64,
+						MetricType: MetricGauge,
+						IntValue:   int64(fs.Inodes),
+					},
+				})
+			}
+		}
+		return result
+	},
+}
+
+var MetricFilesystemInodesFree = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "filesystem/inodes_free",
+		Description: "Free number of inodes on a filesystem",
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+		Labels:      metricLabels,
+	},
+	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
+		return spec.HasFilesystem
+	},
+	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
+		result := []LabeledMetric{}
+		for _, fs := range stat.Filesystem {
+			if fs.HasInodes {
+				result = append(result, LabeledMetric{
+					Name: "filesystem/inodes_free",
+					Labels: map[string]string{
+						LabelResourceID.Key: fs.Device,
+					},
+					MetricValue: MetricValue{
+						ValueType:  ValueInt64,
+						MetricType: MetricGauge,
+						IntValue:   int64(fs.InodesFree),
+					},
+				})
+			}
+		}
+		return result
+	},
+}
+
+// Set by the pod based enricher from the pod specs, for every resource other than CPU,
+// memory and storage.
+var MetricContainerExtendedResourceRequest = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/extended_resource_request",
+		Description: "Requested amount of an extended resource, e.g. a number of GPUs",
+		Labels:      extendedResourceLabels,
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricContainerExtendedResourceLimit = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "container/extended_resource_limit",
+		Description: "Limit of an extended resource, e.g. a number of GPUs",
+		Labels:      extendedResourceLabels,
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+	},
+}
+
+var MetricAcceleratorMemoryTotal = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "accelerator/memory_total",
+		Description: "Total accelerator memory (in bytes)",
+		Labels:      acceleratorLabels,
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+	},
+	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
+		if len(stat.Accelerators) == 0 {
+			return false
+		}
+
+		return true
+	},
+	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
+		result := make([]LabeledMetric, 0, len(stat.Accelerators))
+		for _, ac := range stat.Accelerators {
+			result = append(result, LabeledMetric{
+				Name: "accelerator/memory_total",
+				Labels: map[string]string{
+					LabelAcceleratorMake.Key:  ac.Make,
+					LabelAcceleratorModel.Key: ac.Model,
+					LabelAcceleratorID.Key:    ac.ID,
+				},
+				MetricValue: MetricValue{
+					ValueType:  ValueInt64,
+					MetricType: MetricGauge,
+					IntValue:   int64(ac.MemoryTotal),
+				},
+			})
+		}
+		return result
+	},
+}
+
+var MetricAcceleratorMemoryUsed = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "accelerator/memory_used",
+		Description: "Total accelerator memory allocated (in bytes)",
+		Labels:      acceleratorLabels,
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsBytes,
+	},
+	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
+		if len(stat.Accelerators) == 0 {
+			return false
+		}
+
+		return true
+	},
+	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
+		result := make([]LabeledMetric, 0, len(stat.Accelerators))
+		for _, ac := range stat.Accelerators {
+			result = append(result, LabeledMetric{
+				Name: "accelerator/memory_used",
+				Labels: map[string]string{
+					LabelAcceleratorMake.Key:  ac.Make,
+					LabelAcceleratorModel.Key: ac.Model,
+					LabelAcceleratorID.Key:    ac.ID,
+				},
+				MetricValue: MetricValue{
+					ValueType:  ValueInt64,
+					MetricType: MetricGauge,
+					IntValue:   int64(ac.MemoryUsed),
+				},
+			})
+		}
+		return result
+	},
+}
+
+var MetricAcceleratorDutyCycle = Metric{
+	MetricDescriptor: MetricDescriptor{
+		Name:        "accelerator/duty_cycle",
+		Description: "Percent of time over the past sample period (10s) during which the accelerator was actively processing",
+		Labels:      acceleratorLabels,
+		Type:        MetricGauge,
+		ValueType:   ValueInt64,
+		Units:       UnitsCount,
+		NonAdditive: true,
+	},
+	HasLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) bool {
+		if len(stat.Accelerators) == 0 {
+			return false
+		}
+
+		return true
+	},
+	GetLabeledMetric: func(spec *cadvisor.ContainerSpec, stat *cadvisor.ContainerStats) []LabeledMetric {
+		result := make([]LabeledMetric, 0, len(stat.Accelerators))
+		for _, ac := range stat.Accelerators {
+			result = append(result, LabeledMetric{
+				Name: "accelerator/duty_cycle",
+				Labels: map[string]string{
 					LabelAcceleratorMake.Key:  ac.Make,
 					LabelAcceleratorModel.Key: ac.Model,
 					LabelAcceleratorID.Key:    ac.ID,
@@ -1021,6 +1927,10 @@ type MetricDescriptor struct {
 	Type      MetricType `json:"type,omitempty"`
 	ValueType ValueType  `json:"value_type,omitempty"`
 	Units     UnitsType  `json:"units,omitempty"`
+
+	// Whether the values of the metric, e.g. ratios, percentages, flags or quantiles, can
+	// not be summed up across entities. They are averaged instead.
+	NonAdditive bool `json:"non_additive,omitempty"`
 }
 
 // Metric represents a resource usage stat metric.
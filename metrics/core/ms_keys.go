@@ -15,7 +15,10 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
+	"strconv"
 )
 
 // MetricsSet keys inside of DataBatch. The structure of the returned string is
@@ -46,3 +49,45 @@ func NodeContainerKey(node, container string) string {
 func ClusterKey() string {
 	return "cluster"
 }
+
+func WorkloadKey(namespace, kind, name string) string {
+	return fmt.Sprintf("namespace:%s/workload:%s/%s", namespace, kind, name)
+}
+
+func ServiceKey(namespace, name string) string {
+	return fmt.Sprintf("namespace:%s/service:%s", namespace, name)
+}
+
+func ZoneKey(zone string) string {
+	return fmt.Sprintf("zone:%s", zone)
+}
+
+func RegionKey(region string) string {
+	return fmt.Sprintf("region:%s", region)
+}
+
+func LabelGroupKey(label, value string) string {
+	return fmt.Sprintf("label:%s/value:%s", label, value)
+}
+
+// LabelsKey returns a key unique for the given labels, whatever the order in which they
+// were set. Aggregations of labeled metrics have to key them with it, or the same labels
+// may end up in different buckets. The names and values are quoted so that separators in
+// them can not make different labels collide.
+func LabelsKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var key bytes.Buffer
+	for i, name := range names {
+		if i > 0 {
+			key.WriteByte(',')
+		}
+		key.WriteString(strconv.Quote(name))
+		key.WriteByte('=')
+		key.WriteString(strconv.Quote(labels[name]))
+	}
+	return key.String()
+}
@@ -68,6 +68,8 @@ const (
 	UnitsNanoseconds
 	// A metric in millicores.
 	UnitsMillicores
+	// A metric in seconds.
+	UnitsSeconds
 )
 
 func (self *UnitsType) String() string {
@@ -80,6 +82,8 @@ func (self *UnitsType) String() string {
 		return "ns"
 	case UnitsMillicores:
 		return "millicores"
+	case UnitsSeconds:
+		return "s"
 	}
 	return ""
 }
@@ -129,6 +133,16 @@ type MetricSet struct {
 	MetricValues     map[string]MetricValue
 	Labels           map[string]string
 	LabeledMetrics   []LabeledMetric
+	// Samples holds the values of the metrics at the other times of the scrape window,
+	// oldest first, if the source was asked for all of its samples. MetricValues and
+	// ScrapeTime keep the single sample processed by Heapster.
+	Samples []MetricSample
+}
+
+// MetricSample holds the values of the metrics of a metric set at a given time.
+type MetricSample struct {
+	Timestamp    time.Time
+	MetricValues map[string]MetricValue
 }
 
 type DataBatch struct {
@@ -162,3 +176,12 @@ type DataProcessor interface {
 	Name() string
 	Process(*DataBatch) (*DataBatch, error)
 }
+
+// StatelessDataProcessor is implemented by the data processors that handle every metric set
+// of a batch on its own, without looking at the other metric sets or keeping state between
+// batches. They may be run concurrently over disjoint parts of a batch.
+type StatelessDataProcessor interface {
+	DataProcessor
+	// Stateless only marks the processor and is never called.
+	Stateless()
+}
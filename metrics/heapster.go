@@ -24,9 +24,11 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/golang/glog"
@@ -50,8 +52,11 @@ import (
 	"k8s.io/heapster/metrics/processors"
 	"k8s.io/heapster/metrics/sinks"
 	metricsink "k8s.io/heapster/metrics/sinks/metric"
+	promsink "k8s.io/heapster/metrics/sinks/prometheus"
 	"k8s.io/heapster/metrics/sources"
+	"k8s.io/heapster/metrics/sources/kubelet"
 	"k8s.io/heapster/metrics/util"
+	util_metrics "k8s.io/heapster/metrics/util/metrics"
 	"k8s.io/heapster/version"
 )
 
@@ -60,6 +65,15 @@ func main() {
 	opt.AddFlags(pflag.CommandLine)
 
 	flag.InitFlags()
+	if opt.ConfigFile != "" {
+		config, err := options.LoadConfig(opt.ConfigFile)
+		if err != nil {
+			glog.Fatal(err)
+		}
+		if err := opt.ApplyConfig(config, pflag.CommandLine); err != nil {
+			glog.Fatal(err)
+		}
+	}
 
 	if opt.Version {
 		fmt.Println(version.VersionInfo())
@@ -80,19 +94,21 @@ func main() {
 	if err := validateFlags(opt); err != nil {
 		glog.Fatal(err)
 	}
+	setHistogramBucketsOrDie(opt)
 
 	kubernetesUrl, err := getKubernetesAddress(opt.Sources)
 	if err != nil {
 		glog.Fatalf("Failed to get kubernetes address: %v", err)
 	}
-	sourceManager := createSourceManagerOrDie(opt.Sources)
-	sinkManager, metricSink, historicalSource := createAndInitSinksOrDie(opt.Sinks, opt.HistoricalSource, opt.SinkExportDataTimeout, opt.DisableMetricSink)
+	scrapeIntervals := parseScrapeIntervalsOrDie(opt)
+	sourceManager := createSourceManagerOrDie(opt.Sources, scrapeIntervals)
+	sinkManager, metricSink, historicalSource := createAndInitSinksOrDie(opt.Sinks, opt.HistoricalSource, opt.SinkExportDataTimeout, opt.DisableMetricSink, opt.MetricTransforms)
 
 	podLister, nodeLister := getListersOrDie(kubernetesUrl)
-	dataProcessors := createDataProcessorsOrDie(kubernetesUrl, podLister, labelCopier)
+	dataProcessors := createDataProcessorsOrDie(kubernetesUrl, podLister, nodeLister, labelCopier, rateRetention(opt, scrapeIntervals), opt)
 
 	man, err := manager.NewManager(sourceManager, dataProcessors, sinkManager,
-		opt.MetricResolution, manager.DefaultScrapeOffset, manager.DefaultMaxParallelism)
+		opt.MetricResolution, opt.ScrapeOffset, manager.DefaultMaxParallelism, opt.MaxSinkQueueDepth, opt.ProcessingWorkers)
 	if err != nil {
 		glog.Fatalf("Failed to create main manager: %v", err)
 	}
@@ -104,18 +120,25 @@ func main() {
 	}
 
 	mux := http.NewServeMux()
-	promHandler := prometheus.Handler()
+	// Serves the OpenMetrics format, with the exemplars of the Prometheus sink, to the clients asking for it.
+	promHandler := promsink.NewHandler(prometheus.Handler())
 	handler := setupHandlers(metricSink, podLister, nodeLister, historicalSource, opt.DisableMetricExport)
 	healthz.InstallHandler(mux, healthzChecker(metricSink))
 
 	addr := net.JoinHostPort(opt.Ip, strconv.Itoa(opt.Port))
 	glog.Infof("Starting heapster on port %d", opt.Port)
 
+	if opt.DisableSelfMetrics {
+		promHandler = nil
+	}
+
 	if len(opt.TLSCertFile) > 0 && len(opt.TLSKeyFile) > 0 {
 		startSecureServing(opt, handler, promHandler, mux, addr)
 	} else {
 		mux.Handle("/", handler)
-		mux.Handle("/metrics", promHandler)
+		if promHandler != nil {
+			mux.Handle("/metrics", promHandler)
+		}
 
 		glog.Fatal(http.ListenAndServe(addr, mux))
 	}
@@ -151,14 +174,18 @@ func startSecureServing(opt *options.HeapsterRunOptions, handler http.Handler, p
 		}
 		handler = authPprofHandler
 
-		authPromHandler, err := newAuthHandler(opt, promHandler)
-		if err != nil {
-			glog.Fatalf("Failed to create authorized prometheus handler: %v", err)
+		if promHandler != nil {
+			authPromHandler, err := newAuthHandler(opt, promHandler)
+			if err != nil {
+				glog.Fatalf("Failed to create authorized prometheus handler: %v", err)
+			}
+			promHandler = authPromHandler
 		}
-		promHandler = authPromHandler
 	}
 	mux.Handle("/", handler)
-	mux.Handle("/metrics", promHandler)
+	if promHandler != nil {
+		mux.Handle("/metrics", promHandler)
+	}
 
 	// If allowed users is set, then we need to enable Client Authentication
 	if len(opt.AllowedUsers) > 0 {
@@ -173,7 +200,7 @@ func startSecureServing(opt *options.HeapsterRunOptions, handler http.Handler, p
 	}
 }
 
-func createSourceManagerOrDie(src flags.Uris) core.MetricsSource {
+func createSourceManagerOrDie(src flags.Uris, scrapeIntervals []sources.ScrapeInterval) core.MetricsSource {
 	if len(src) != 1 {
 		glog.Fatal("Wrong number of sources specified")
 	}
@@ -182,22 +209,38 @@ func createSourceManagerOrDie(src flags.Uris) core.MetricsSource {
 	if err != nil {
 		glog.Fatalf("Failed to create source provide: %v", err)
 	}
-	sourceManager, err := sources.NewSourceManager(sourceProvider, sources.DefaultMetricsScrapeTimeout)
+	sourceManager, err := sources.NewScheduledSourceManager(sourceProvider, sources.DefaultMetricsScrapeTimeout, scrapeIntervals)
 	if err != nil {
 		glog.Fatalf("Failed to create source manager: %v", err)
 	}
 	return sourceManager
 }
 
-func createAndInitSinksOrDie(sinkAddresses flags.Uris, historicalSource string, sinkExportDataTimeout time.Duration, disableMetricSink bool) (core.DataSink, *metricsink.MetricSink, core.HistoricalSource) {
+func createAndInitSinksOrDie(sinkAddresses flags.Uris, historicalSource string, sinkExportDataTimeout time.Duration, disableMetricSink bool,
+	metricTransforms []string) (core.DataSink, *metricsink.MetricSink, core.HistoricalSource) {
 	sinksFactory := sinks.NewSinkFactory()
-	metricSink, sinkList, histSource := sinksFactory.BuildAll(sinkAddresses, historicalSource, disableMetricSink)
+	metricSink, sinkList, histSource, err := sinksFactory.BuildAll(sinkAddresses, historicalSource, disableMetricSink)
+	if err != nil {
+		glog.Fatalf("Failed to create sinks: %v", err)
+	}
 	if metricSink == nil && !disableMetricSink {
 		glog.Fatal("Failed to create metric sink")
 	}
 	if histSource == nil && len(historicalSource) > 0 {
 		glog.Fatal("Failed to use a sink as a historical metrics source")
 	}
+	transforms, err := sinks.ParseMetricTransforms(metricTransforms)
+	if err != nil {
+		glog.Fatalf("Failed to parse metric transforms: %v", err)
+	}
+	if len(transforms) > 0 {
+		// The metric sink backs the APIs and must keep the original values.
+		for i, sink := range sinkList {
+			if sink != core.DataSink(metricSink) {
+				sinkList[i] = sinks.NewTransformingSink(sink, transforms)
+			}
+		}
+	}
 	for _, sink := range sinkList {
 		glog.Infof("Starting with %s", sink.Name())
 	}
@@ -230,17 +273,136 @@ func createKubeClientOrDie(kubernetesUrl *url.URL) *kube_client.Clientset {
 	return kube_client.NewForConfigOrDie(kubeConfig)
 }
 
-func createDataProcessorsOrDie(kubernetesUrl *url.URL, podLister v1listers.PodLister, labelCopier *util.LabelCopier) []core.DataProcessor {
-	dataProcessors := []core.DataProcessor{
-		// Convert cumulative to rate
-		processors.NewRateCalculator(core.RateMetricsMapping),
+func setHistogramBucketsOrDie(opt *options.HeapsterRunOptions) {
+	// Validated with the other flags.
+	buckets, _ := util_metrics.ParseBuckets(opt.HistogramBuckets)
+	if err := kubelet.SetRequestDurationBuckets(buckets); err != nil {
+		glog.Fatalf("Failed to set the histogram buckets: %v", err)
+	}
+}
+
+func parseScrapeIntervalsOrDie(opt *options.HeapsterRunOptions) []sources.ScrapeInterval {
+	scrapeIntervals := make([]sources.ScrapeInterval, 0, len(opt.NodeScrapeIntervals))
+	for _, spec := range opt.NodeScrapeIntervals {
+		interval, err := sources.ParseScrapeInterval(spec)
+		if err != nil {
+			glog.Fatalf("Invalid --node_scrape_interval: %v", err)
+		}
+		if interval.Interval < opt.MetricResolution {
+			glog.Fatalf("Node scrape interval %q is shorter than --metric_resolution %v", spec, opt.MetricResolution)
+		}
+		scrapeIntervals = append(scrapeIntervals, interval)
 	}
+	return scrapeIntervals
+}
+
+// rateRetention returns for how long the rate calculators keep the samples of the nodes
+// that are not scraped in every cycle.
+func rateRetention(opt *options.HeapsterRunOptions, scrapeIntervals []sources.ScrapeInterval) time.Duration {
+	var retention time.Duration
+	for _, interval := range scrapeIntervals {
+		if interval.Interval > retention {
+			retention = interval.Interval
+		}
+	}
+	if retention == 0 {
+		return 0
+	}
+	// Leaves room for the scrape times of the samples to lag behind the scrape cycles.
+	return retention + opt.MetricResolution
+}
+
+func createDataProcessorsOrDie(kubernetesUrl *url.URL, podLister v1listers.PodLister, nodeLister v1listers.NodeLister,
+	labelCopier *util.LabelCopier, retention time.Duration, opt *options.HeapsterRunOptions) []core.DataProcessor {
+	// Convert cumulative to rate
+	var rateCalculator core.DataProcessor = processors.NewRateCalculator(core.RateMetricsMapping, retention)
+	if opt.RateWindowSamples > 0 {
+		if opt.RateWindowSamples < 2 {
+			glog.Fatalf("--rate_window_samples has to be at least 2, got %d", opt.RateWindowSamples)
+		}
+		rateCalculator = processors.NewWindowRateCalculator(core.RateMetricsMapping, opt.RateWindowSamples, retention)
+	}
+	dataProcessors := []core.DataProcessor{}
+	// Filter the namespaces first to save the processing of the dropped metric sets.
+	if len(opt.NamespaceAllowlist) > 0 {
+		dataProcessors = append(dataProcessors, processors.NewNamespaceFilter(opt.NamespaceAllowlist, true))
+	} else if len(opt.NamespaceDenylist) > 0 {
+		dataProcessors = append(dataProcessors, processors.NewNamespaceFilter(opt.NamespaceDenylist, false))
+	}
+	if len(opt.MetricMaxValues) > 0 {
+		maxValues, err := processors.ParseMetricMaxValues(opt.MetricMaxValues)
+		if err != nil {
+			glog.Fatalf("Failed to create ValueBoundsFilter: %v", err)
+		}
+		// Must run before the rate calculator, a glitch of a cumulative metric would result in a bogus rate.
+		dataProcessors = append(dataProcessors, &processors.ValueBoundsFilter{MaxValues: maxValues})
+	}
+	dataProcessors = append(dataProcessors,
+		rateCalculator,
+		// Must run before the pod aggregator sums up the container network rates.
+		processors.NewPodNetworkRateCalculator(),
+		processors.NewPodConnectionChurnCalculator())
 
 	podBasedEnricher, err := processors.NewPodBasedEnricher(podLister, labelCopier)
 	if err != nil {
 		glog.Fatalf("Failed to create PodBasedEnricher: %v", err)
 	}
 	dataProcessors = append(dataProcessors, podBasedEnricher)
+	if opt.AggregateByTopology {
+		// Labels the pod metric sets created by the pod based enricher, before the pod
+		// aggregator copies the labels to the pods created from their containers.
+		dataProcessors = append(dataProcessors, processors.NewTopologyEnricher(nodeLister))
+	}
+	if opt.ContainerVersionInfo {
+		// Parses the tag before the normalizer strips it.
+		dataProcessors = append(dataProcessors, &processors.ContainerVersionEnricher{})
+	}
+	if opt.NormalizeContainerImage {
+		// The pod based enricher sets the image of the containers missing in the batch.
+		dataProcessors = append(dataProcessors, processors.NewContainerImageNormalizer(!opt.DropFullContainerImage))
+	}
+	if opt.LabelPvcName {
+		dataProcessors = append(dataProcessors, processors.NewPvcEnricher(podLister))
+	}
+	if opt.LabelContainerType {
+		dataProcessors = append(dataProcessors, processors.NewContainerTypeEnricher(podLister))
+	}
+	if opt.LabelPodPriority {
+		// The pod lister drops the priority of the pods, which is read from the raw pods instead.
+		priorityLister, err := util.GetPodPriorityLister(createKubeClientOrDie(kubernetesUrl))
+		if err != nil {
+			glog.Fatalf("Failed to create PodPriorityLister: %v", err)
+		}
+		dataProcessors = append(dataProcessors, processors.NewPodPriorityEnricher(priorityLister))
+	}
+
+	// Uptime depends on the restart count provided by the pod based enricher.
+	dataProcessors = append(dataProcessors, processors.NewContainerUptimeCalculator(opt.AvailabilityWindow))
+	if opt.AvailabilityPctWindow > 0 {
+		var readinessLister v1listers.PodLister
+		if opt.AvailabilityReadiness {
+			readinessLister = podLister
+		}
+		dataProcessors = append(dataProcessors, processors.NewAvailabilityCalculator(opt.AvailabilityPctWindow, readinessLister))
+	}
+	if opt.RestartVelocityWindow > 0 {
+		dataProcessors = append(dataProcessors, processors.NewRestartVelocityCalculator(opt.RestartVelocityWindow, opt.FlappingThreshold))
+	}
+	// OOM risk depends on the memory limits provided by the pod based enricher.
+	dataProcessors = append(dataProcessors, processors.NewOOMRiskCalculator(float32(opt.OOMRiskThreshold), opt.OOMRiskWindow))
+	if opt.PeakUsageWindow > 0 {
+		dataProcessors = append(dataProcessors, processors.NewPeakUsageCalculator(opt.PeakUsageWindow))
+	}
+	if opt.CpuPercentilesWindow > 0 {
+		dataProcessors = append(dataProcessors, processors.NewCpuPercentileCalculator(opt.CpuPercentilesWindow))
+	}
+	if opt.MemoryGrowthWindow > 0 {
+		dataProcessors = append(dataProcessors, processors.NewMemoryGrowthCalculator(opt.MemoryGrowthWindow))
+	}
+	if opt.TimeOverLimitWindow > 0 {
+		dataProcessors = append(dataProcessors, processors.NewTimeOverLimitCalculator(opt.TimeOverLimitThreshold, opt.TimeOverLimitWindow))
+	}
+	dataProcessors = append(dataProcessors, processors.NewCpuStealCalculator())
 
 	namespaceBasedEnricher, err := processors.NewNamespaceBasedEnricher(kubernetesUrl)
 	if err != nil {
@@ -267,6 +429,9 @@ func createDataProcessorsOrDie(kubernetesUrl *url.URL, podLister v1listers.PodLi
 
 	dataProcessors = append(dataProcessors,
 		processors.NewPodAggregator(),
+		&processors.WorkloadAggregator{
+			MetricsToAggregate: metricsToAggregate,
+		},
 		&processors.NamespaceAggregator{
 			MetricsToAggregate: metricsToAggregate,
 		},
@@ -275,16 +440,117 @@ func createDataProcessorsOrDie(kubernetesUrl *url.URL, podLister v1listers.PodLi
 		},
 		&processors.ClusterAggregator{
 			MetricsToAggregate: metricsToAggregate,
+		},
+		processors.NewNamespacePodCountDeltaCalculator(),
+		&processors.LimitCoverageCalculator{},
+		processors.NewPodCoverageCalculator(podLister),
+		processors.NewPodsThrottledCalculator(),
+		&processors.NodeDensityCalculator{},
+		&processors.EgressRateCalculator{})
+	if opt.ContainerAgeBuckets {
+		dataProcessors = append(dataProcessors, &processors.ContainerAgeCalculator{})
+	}
+	if opt.AggregateByService {
+		serviceAggregator, err := processors.NewServiceAggregator(kubernetesUrl, metricsToAggregate,
+			opt.ServiceAttribution == processors.ServiceAttributionFractional)
+		if err != nil {
+			glog.Fatalf("Failed to create ServiceAggregator: %v", err)
+		}
+		dataProcessors = append(dataProcessors, serviceAggregator)
+	}
+	if opt.AggregateByTopology {
+		// Sums up the node metrics provided by the node aggregator.
+		dataProcessors = append(dataProcessors, &processors.TopologyAggregator{
+			MetricsToAggregate: metricsToAggregate,
 		})
+	}
+
+	for _, labelAggregation := range opt.LabelAggregations {
+		parts := strings.SplitN(labelAggregation, ":", 2)
+		if len(parts) == 2 && parts[1] != "avg" {
+			glog.Fatalf("Invalid label aggregation %q - only the avg mode is supported", labelAggregation)
+		}
+		// The label has to be copied from the pod labels by the pod based enricher.
+		if !labelCopier.Stores(parts[0]) {
+			glog.Fatalf("Label %q used for aggregation has to be stored with --store_label", parts[0])
+		}
+		dataProcessors = append(dataProcessors, &processors.LabelAggregator{
+			Label:              parts[0],
+			MetricsToAggregate: metricsToAggregate,
+			Average:            len(parts) == 2,
+		})
+	}
 
 	nodeAutoscalingEnricher, err := processors.NewNodeAutoscalingEnricher(kubernetesUrl, labelCopier)
 	if err != nil {
 		glog.Fatalf("Failed to create NodeAutoscalingEnricher: %v", err)
 	}
 	dataProcessors = append(dataProcessors, nodeAutoscalingEnricher)
+	memoryThreshold, err := processors.ParseEvictionThreshold(opt.EvictionMemoryAvailable)
+	if err != nil {
+		glog.Fatalf("Failed to create NodePressureCalculator: %v", err)
+	}
+	diskThreshold, err := processors.ParseEvictionThreshold(opt.EvictionNodeFsAvailable)
+	if err != nil {
+		glog.Fatalf("Failed to create NodePressureCalculator: %v", err)
+	}
+	dataProcessors = append(dataProcessors, processors.NewNodePressureCalculator(nodeLister, memoryThreshold, diskThreshold))
+	dataProcessors = append(dataProcessors, processors.NewNodeCordonTracker())
+	dataProcessors = append(dataProcessors, &processors.SystemOverheadCalculator{})
+	// Depend on the node capacity provided by the node autoscaling enricher.
+	dataProcessors = append(dataProcessors,
+		&processors.ContainerNodeCpuCalculator{},
+		&processors.NodeOversubscriptionCalculator{},
+		&processors.ClusterUtilizationCalculator{})
+	// Depend on the requests provided by the pod based enricher and on the workload metric sets.
+	dataProcessors = append(dataProcessors,
+		&processors.RequestEfficiencyCalculator{},
+		&processors.MemoryHeadroomCalculator{AggregateWorkloads: opt.WorkloadMemoryHeadroom},
+		&processors.BurstRatioCalculator{})
+	// Depends on the workload metric sets, and on the zones set by the topology enricher.
+	dataProcessors = append(dataProcessors, &processors.ReplicaSpreadCalculator{})
+	if opt.OverprovisionThreshold > 0 {
+		// Depends on the peak usage calculator as well.
+		dataProcessors = append(dataProcessors, &processors.OverprovisioningCalculator{
+			Threshold: opt.OverprovisionThreshold,
+		})
+	}
+	if opt.NamespaceFairShare {
+		weights, err := processors.ParseFairShareWeights(opt.FairShareWeights)
+		if err != nil {
+			glog.Fatalf("Failed to create FairShareCalculator: %v", err)
+		}
+		// Depends on the node allocatable resources provided by the node autoscaling enricher.
+		dataProcessors = append(dataProcessors, &processors.FairShareCalculator{Weights: weights})
+	}
+
+	if opt.DisableContainerMetrics {
+		// Has to run after all the processors reading the container metric sets.
+		dataProcessors = append(dataProcessors, processors.NewContainerCollapser())
+	}
+	if opt.MetricFilterConfig != "" {
+		// Runs last so that the dropped metrics can still be used to derive other ones.
+		metricFilter, err := processors.NewMetricFilter(opt.MetricFilterConfig)
+		if err != nil {
+			glog.Fatalf("Failed to create MetricFilter: %v", err)
+		}
+		go reloadOnSignal(metricFilter)
+		dataProcessors = append(dataProcessors, metricFilter)
+	}
 	return dataProcessors
 }
 
+// reloadOnSignal reloads the metric filter configuration every time Heapster receives SIGHUP.
+func reloadOnSignal(metricFilter *processors.MetricFilter) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		if err := metricFilter.Reload(); err != nil {
+			glog.Errorf("Failed to reload the metric filter, keeping the current configuration: %v", err)
+		}
+	}
+}
+
 const (
 	minMetricsCount = 1
 	maxMetricsDelay = 3 * time.Minute
@@ -334,12 +600,48 @@ func validateFlags(opt *options.HeapsterRunOptions) error {
 	if opt.MetricResolution < 5*time.Second {
 		return fmt.Errorf("metric resolution should not be less than 5 seconds - %d", opt.MetricResolution)
 	}
+	if opt.ScrapeOffset < 0 || opt.ScrapeOffset >= opt.MetricResolution {
+		return fmt.Errorf("scrape offset has to be at least 0 and shorter than the metric resolution - %v", opt.ScrapeOffset)
+	}
 	if (len(opt.TLSCertFile) > 0 && len(opt.TLSKeyFile) == 0) || (len(opt.TLSCertFile) == 0 && len(opt.TLSKeyFile) > 0) {
 		return fmt.Errorf("both TLS certificate & key are required to enable TLS serving")
 	}
 	if len(opt.TLSClientCAFile) > 0 && len(opt.TLSCertFile) == 0 {
 		return fmt.Errorf("client cert authentication requires TLS certificate & key")
 	}
+	if opt.DropFullContainerImage && !opt.NormalizeContainerImage {
+		return fmt.Errorf("--drop_full_container_image requires --normalize_container_image")
+	}
+	if len(opt.NamespaceAllowlist) > 0 && len(opt.NamespaceDenylist) > 0 {
+		return fmt.Errorf("only one of --namespace_allowlist and --namespace_denylist can be set")
+	}
+	if opt.TimeOverLimitWindow > 0 && opt.TimeOverLimitThreshold <= 0 {
+		return fmt.Errorf("--time_over_limit_threshold has to be positive - %v", opt.TimeOverLimitThreshold)
+	}
+	if opt.AvailabilityReadiness && opt.AvailabilityPctWindow <= 0 {
+		return fmt.Errorf("--availability_readiness requires --availability_pct_window")
+	}
+	if opt.OverprovisionThreshold < 0 || opt.OverprovisionThreshold > 1 {
+		return fmt.Errorf("--overprovisioned_threshold has to be between 0 and 1 - %v", opt.OverprovisionThreshold)
+	}
+	if opt.OverprovisionThreshold > 0 && opt.PeakUsageWindow <= 0 {
+		return fmt.Errorf("--overprovisioned_threshold requires --peak_usage_window")
+	}
+	if opt.ServiceAttribution != processors.ServiceAttributionFull && opt.ServiceAttribution != processors.ServiceAttributionFractional {
+		return fmt.Errorf("--service_attribution has to be %s or %s - %q", processors.ServiceAttributionFull, processors.ServiceAttributionFractional, opt.ServiceAttribution)
+	}
+	if len(opt.FairShareWeights) > 0 && !opt.NamespaceFairShare {
+		return fmt.Errorf("--fair_share_weight requires --namespace_fair_share")
+	}
+	if _, err := processors.ParseMetricMaxValues(opt.MetricMaxValues); err != nil {
+		return fmt.Errorf("invalid --metric_max_value: %v", err)
+	}
+	if _, err := util_metrics.ParseBuckets(opt.HistogramBuckets); err != nil {
+		return fmt.Errorf("invalid --histogram_buckets: %v", err)
+	}
+	if opt.ProcessingWorkers < 1 {
+		return fmt.Errorf("--processing_workers has to be at least 1 - %d", opt.ProcessingWorkers)
+	}
 	return nil
 }
 
@@ -24,6 +24,8 @@ import (
 )
 
 const (
+	// Delay of the scrapes after the end of the windows they cover, to let the kubelets
+	// update their stats first.
 	DefaultScrapeOffset   = 5 * time.Second
 	DefaultMaxParallelism = 3
 )
@@ -39,10 +41,26 @@ var (
 		},
 		[]string{"processor"},
 	)
+
+	// Number of scrape cycles skipped because the sinks fell behind.
+	scrapesSkippedBackpressure = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "scrape",
+			Name:      "skipped_backpressure_total",
+			Help:      "Number of scrape cycles skipped because the sink queue depth exceeded the threshold.",
+		},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(processorDuration)
+	prometheus.MustRegister(scrapesSkippedBackpressure)
+}
+
+// queueDepthReporter is implemented by sinks that queue the exported batches, e.g. the sink manager.
+type queueDepthReporter interface {
+	QueueDepth() int
 }
 
 type Manager interface {
@@ -52,26 +70,31 @@ type Manager interface {
 
 type realManager struct {
 	source                 core.MetricsSource
-	processors             []core.DataProcessor
+	stages                 []processingStage
+	processingWorkers      int
 	sink                   core.DataSink
 	resolution             time.Duration
 	scrapeOffset           time.Duration
 	stopChan               chan struct{}
 	housekeepSemaphoreChan chan struct{}
 	housekeepTimeout       time.Duration
+	// Scrapes are skipped while the sink queue depth exceeds it, 0 to disable.
+	maxSinkQueueDepth int
 }
 
 func NewManager(source core.MetricsSource, processors []core.DataProcessor, sink core.DataSink, resolution time.Duration,
-	scrapeOffset time.Duration, maxParallelism int) (Manager, error) {
+	scrapeOffset time.Duration, maxParallelism int, maxSinkQueueDepth int, processingWorkers int) (Manager, error) {
 	manager := realManager{
 		source:                 source,
-		processors:             processors,
+		stages:                 newProcessingStages(processors, processingWorkers),
+		processingWorkers:      processingWorkers,
 		sink:                   sink,
 		resolution:             resolution,
 		scrapeOffset:           scrapeOffset,
 		stopChan:               make(chan struct{}),
 		housekeepSemaphoreChan: make(chan struct{}, maxParallelism),
 		housekeepTimeout:       resolution / 2,
+		maxSinkQueueDepth:      maxSinkQueueDepth,
 	}
 
 	for i := 0; i < maxParallelism; i++ {
@@ -92,10 +115,7 @@ func (rm *realManager) Stop() {
 func (rm *realManager) Housekeep() {
 	for {
 		// Always try to get the newest metrics
-		now := time.Now()
-		start := now.Truncate(rm.resolution)
-		end := start.Add(rm.resolution)
-		timeToNextSync := end.Add(rm.scrapeOffset).Sub(now)
+		start, end, timeToNextSync := nextScrape(time.Now(), rm.resolution, rm.scrapeOffset)
 
 		select {
 		case <-time.After(timeToNextSync):
@@ -107,11 +127,24 @@ func (rm *realManager) Housekeep() {
 	}
 }
 
+// nextScrape returns the window of the next scrape and the time until it is due. The
+// windows are aligned to the resolution and every one is scraped the offset after its end,
+// so that a kubelet housekeeping tick shortly after the end of the window is included.
+func nextScrape(now time.Time, resolution, offset time.Duration) (time.Time, time.Time, time.Duration) {
+	start := now.Add(-offset).Truncate(resolution)
+	end := start.Add(resolution)
+	return start, end, end.Add(offset).Sub(now)
+}
+
 func (rm *realManager) housekeep(start, end time.Time) {
 	if !start.Before(end) {
 		glog.Warningf("Wrong time provided to housekeep start:%s end: %s", start, end)
 		return
 	}
+	if rm.sinkBackpressure() {
+		scrapesSkippedBackpressure.Inc()
+		return
+	}
 
 	select {
 	case <-rm.housekeepSemaphoreChan:
@@ -132,14 +165,10 @@ func (rm *realManager) housekeep(start, end time.Time) {
 			return
 		}
 
-		for _, p := range rm.processors {
-			newData, err := process(p, data)
-			if err == nil {
-				data = newData
-			} else {
-				glog.Errorf("Error in processor: %v", err)
-				return
-			}
+		data, err = runPipeline(rm.stages, rm.processingWorkers, data)
+		if err != nil {
+			glog.Errorf("Error in processor: %v", err)
+			return
 		}
 
 		// Export data to sinks
@@ -147,6 +176,22 @@ func (rm *realManager) housekeep(start, end time.Time) {
 	}(rm)
 }
 
+// sinkBackpressure returns true if the sinks fell too far behind to scrape more data.
+func (rm *realManager) sinkBackpressure() bool {
+	if rm.maxSinkQueueDepth <= 0 {
+		return false
+	}
+	queue, ok := rm.sink.(queueDepthReporter)
+	if !ok {
+		return false
+	}
+	if depth := queue.QueueDepth(); depth > rm.maxSinkQueueDepth {
+		glog.Warningf("Skipping scrape - sink queue depth %d exceeds %d", depth, rm.maxSinkQueueDepth)
+		return true
+	}
+	return false
+}
+
 func process(p core.DataProcessor, data *core.DataBatch) (*core.DataBatch, error) {
 	startTime := time.Now()
 	defer processorDuration.
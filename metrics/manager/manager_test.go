@@ -15,9 +15,14 @@
 package manager
 
 import (
+	"sync"
 	"testing"
 	"time"
 
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"k8s.io/heapster/metrics/core"
 	"k8s.io/heapster/metrics/util"
 )
@@ -27,7 +32,7 @@ func TestFlow(t *testing.T) {
 	sink := util.NewDummySink("sink", time.Millisecond)
 	processor := util.NewDummyDataProcessor(time.Millisecond)
 
-	manager, _ := NewManager(source, []core.DataProcessor{processor}, sink, time.Second, time.Millisecond, 1)
+	manager, _ := NewManager(source, []core.DataProcessor{processor}, sink, time.Second, time.Millisecond, 1, 0, 1)
 	manager.Start()
 
 	// 4-5 cycles
@@ -39,12 +44,36 @@ func TestFlow(t *testing.T) {
 	}
 }
 
+func TestNextScrape(t *testing.T) {
+	base := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		now            time.Duration
+		offset         time.Duration
+		start, end     time.Duration
+		timeToNextSync time.Duration
+	}{
+		// Scraped right at the end of the window.
+		{now: 10 * time.Second, offset: 0, start: 0, end: time.Minute, timeToNextSync: 50 * time.Second},
+		{now: 10 * time.Second, offset: 5 * time.Second, start: 0, end: time.Minute, timeToNextSync: 55 * time.Second},
+		// The previous window is not scraped yet.
+		{now: 3 * time.Second, offset: 5 * time.Second, start: -time.Minute, end: 0, timeToNextSync: 2 * time.Second},
+		{now: 40 * time.Second, offset: 50 * time.Second, start: -time.Minute, end: 0, timeToNextSync: 10 * time.Second},
+		// The previous window was just scraped.
+		{now: 5 * time.Second, offset: 5 * time.Second, start: 0, end: time.Minute, timeToNextSync: time.Minute},
+	} {
+		start, end, timeToNextSync := nextScrape(base.Add(test.now), time.Minute, test.offset)
+		assert.Equal(t, base.Add(test.start), start, "start at %v with offset %v", test.now, test.offset)
+		assert.Equal(t, base.Add(test.end), end, "end at %v with offset %v", test.now, test.offset)
+		assert.Equal(t, test.timeToNextSync, timeToNextSync, "time to next sync at %v with offset %v", test.now, test.offset)
+	}
+}
+
 func TestThrottling(t *testing.T) {
 	source := util.NewDummyMetricsSource("src", time.Millisecond)
 	sink := util.NewDummySink("sink", 4*time.Second)
 	processor := util.NewDummyDataProcessor(5 * time.Millisecond)
 
-	manager, _ := NewManager(source, []core.DataProcessor{processor}, sink, time.Second, time.Millisecond, 1)
+	manager, _ := NewManager(source, []core.DataProcessor{processor}, sink, time.Second, time.Millisecond, 1, 0, 1)
 	manager.Start()
 
 	// 4-5 cycles
@@ -55,3 +84,105 @@ func TestThrottling(t *testing.T) {
 		t.Fatalf("Wrong number of exports executed: %d", sink.GetExportCount())
 	}
 }
+
+// queueSink queues the exported batches until the test exports them with exportOne.
+type queueSink struct {
+	sync.Mutex
+	queued      int
+	exportCount int
+	// Receives a value for every batch passed to the sink.
+	received chan struct{}
+}
+
+func newQueueSink() *queueSink {
+	return &queueSink{received: make(chan struct{}, 10)}
+}
+
+func (this *queueSink) Name() string {
+	return "queue sink"
+}
+
+func (this *queueSink) ExportData(batch *core.DataBatch) {
+	this.Lock()
+	this.queued++
+	this.Unlock()
+	this.received <- struct{}{}
+}
+
+// waitForBatch waits until the sink received a batch from the scrape of the manager.
+func (this *queueSink) waitForBatch(t *testing.T) {
+	select {
+	case <-this.received:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no batch exported")
+	}
+}
+
+func (this *queueSink) exportOne() {
+	this.Lock()
+	defer this.Unlock()
+	this.queued--
+	this.exportCount++
+}
+
+func (this *queueSink) QueueDepth() int {
+	this.Lock()
+	defer this.Unlock()
+	return this.queued
+}
+
+func (this *queueSink) Stop() {}
+
+func skippedScrapes(t *testing.T) float64 {
+	metric := &dto.Metric{}
+	require.NoError(t, scrapesSkippedBackpressure.Write(metric))
+	return metric.GetCounter().GetValue()
+}
+
+func TestBackpressure(t *testing.T) {
+	source := util.NewDummyMetricsSource("src", time.Millisecond)
+	sink := newQueueSink()
+	skippedBefore := skippedScrapes(t)
+	manager, _ := NewManager(source, []core.DataProcessor{}, sink, time.Second, time.Millisecond, 1, 1, 1)
+	rm := manager.(*realManager)
+	end := time.Now()
+	start := end.Add(-time.Second)
+
+	// The first two batches are queued, the following scrapes are skipped until the sink
+	// exports one of them.
+	for i := 0; i < 2; i++ {
+		rm.housekeep(start, end)
+		sink.waitForBatch(t)
+	}
+	rm.housekeep(start, end)
+	rm.housekeep(start, end)
+	assert.Equal(t, float64(2), skippedScrapes(t)-skippedBefore)
+	assert.Equal(t, 2, sink.QueueDepth())
+
+	sink.exportOne()
+	rm.housekeep(start, end)
+	sink.waitForBatch(t)
+	assert.Equal(t, float64(2), skippedScrapes(t)-skippedBefore)
+	assert.Equal(t, 2, sink.QueueDepth())
+}
+
+func TestNoBackpressureForFastSink(t *testing.T) {
+	source := util.NewDummyMetricsSource("src", time.Millisecond)
+	sink := newQueueSink()
+	skippedBefore := skippedScrapes(t)
+	manager, _ := NewManager(source, []core.DataProcessor{}, sink, time.Second, time.Millisecond, 1, 1, 1)
+	rm := manager.(*realManager)
+	end := time.Now()
+	start := end.Add(-time.Second)
+
+	for i := 0; i < 3; i++ {
+		rm.housekeep(start, end)
+		sink.waitForBatch(t)
+		sink.exportOne()
+	}
+	assert.Equal(t, float64(0), skippedScrapes(t)-skippedBefore)
+	assert.Equal(t, 0, sink.QueueDepth())
+	sink.Lock()
+	defer sink.Unlock()
+	assert.Equal(t, 3, sink.exportCount)
+}
@@ -0,0 +1,122 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sync"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// processingStage is a run of consecutive processors. The processors of a sharded stage
+// are stateless, so the batch is split into shards processed concurrently.
+type processingStage struct {
+	processors []core.DataProcessor
+	sharded    bool
+}
+
+// newProcessingStages groups the consecutive stateless processors into sharded stages.
+// The stateful ones, e.g. the aggregators, see the whole batch, one at a time.
+func newProcessingStages(processors []core.DataProcessor, workers int) []processingStage {
+	stages := []processingStage{}
+	for _, p := range processors {
+		_, stateless := p.(core.StatelessDataProcessor)
+		sharded := stateless && workers > 1
+		if last := len(stages) - 1; last >= 0 && sharded && stages[last].sharded {
+			stages[last].processors = append(stages[last].processors, p)
+			continue
+		}
+		stages = append(stages, processingStage{
+			processors: []core.DataProcessor{p},
+			sharded:    sharded,
+		})
+	}
+	return stages
+}
+
+// runPipeline passes the batch through all the stages.
+func runPipeline(stages []processingStage, workers int, data *core.DataBatch) (*core.DataBatch, error) {
+	for _, stage := range stages {
+		var err error
+		if stage.sharded {
+			data, err = processSharded(stage.processors, workers, data)
+		} else {
+			data, err = processAll(stage.processors, data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func processAll(processors []core.DataProcessor, data *core.DataBatch) (*core.DataBatch, error) {
+	for _, p := range processors {
+		newData, err := process(p, data)
+		if err != nil {
+			return nil, err
+		}
+		data = newData
+	}
+	return data, nil
+}
+
+// processSharded splits the metric sets of the batch into a shard per worker, passes every
+// shard through the processors concurrently and merges the results.
+func processSharded(processors []core.DataProcessor, workers int, data *core.DataBatch) (*core.DataBatch, error) {
+	if workers > len(data.MetricSets) {
+		workers = len(data.MetricSets)
+	}
+	if workers <= 1 {
+		return processAll(processors, data)
+	}
+	shards := make([]*core.DataBatch, workers)
+	for i := range shards {
+		shards[i] = &core.DataBatch{
+			Timestamp:  data.Timestamp,
+			MetricSets: make(map[string]*core.MetricSet, len(data.MetricSets)/workers+1),
+		}
+	}
+	i := 0
+	for key, metricSet := range data.MetricSets {
+		shards[i%workers].MetricSets[key] = metricSet
+		i++
+	}
+
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := range shards {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			shards[i], errs[i] = processAll(processors, shards[i])
+		}(i)
+	}
+	wg.Wait()
+
+	result := &core.DataBatch{
+		Timestamp:  data.Timestamp,
+		MetricSets: make(map[string]*core.MetricSet, len(data.MetricSets)),
+	}
+	for i, shard := range shards {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		for key, metricSet := range shard.MetricSets {
+			result.MetricSets[key] = metricSet
+		}
+	}
+	return result, nil
+}
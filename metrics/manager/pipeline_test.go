@@ -0,0 +1,343 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/processors"
+)
+
+// hashingProcessor labels every metric set with a hash of its name, standing in for an enricher.
+type hashingProcessor struct{}
+
+func (this *hashingProcessor) Name() string {
+	return "hashing"
+}
+
+func (this *hashingProcessor) Stateless() {}
+
+func (this *hashingProcessor) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for _, metricSet := range batch.MetricSets {
+		hash := sha256.Sum256([]byte(metricSet.Labels["name"]))
+		for i := 0; i < 50; i++ {
+			hash = sha256.Sum256(hash[:])
+		}
+		metricSet.Labels["hash"] = fmt.Sprintf("%x", hash[:4])
+	}
+	return batch, nil
+}
+
+// droppingProcessor drops the metric sets labeled with drop.
+type droppingProcessor struct{}
+
+func (this *droppingProcessor) Name() string {
+	return "dropping"
+}
+
+func (this *droppingProcessor) Stateless() {}
+
+func (this *droppingProcessor) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for key, metricSet := range batch.MetricSets {
+		if metricSet.Labels["drop"] == "true" {
+			delete(batch.MetricSets, key)
+		}
+	}
+	return batch, nil
+}
+
+// countingProcessor adds a metric set with the number of metric sets in the batch, standing
+// in for an aggregator.
+type countingProcessor struct{}
+
+func (this *countingProcessor) Name() string {
+	return "counting"
+}
+
+func (this *countingProcessor) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	batch.MetricSets[core.ClusterKey()] = &core.MetricSet{
+		Labels: map[string]string{"count": fmt.Sprint(len(batch.MetricSets))},
+	}
+	return batch, nil
+}
+
+func pipelineBatch(size int) *core.DataBatch {
+	batch := &core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: make(map[string]*core.MetricSet, size),
+	}
+	for i := 0; i < size; i++ {
+		name := fmt.Sprintf("pod-%d", i)
+		batch.MetricSets[core.PodKey("ns", name)] = &core.MetricSet{
+			Labels: map[string]string{
+				"name": name,
+				"drop": fmt.Sprint(i%10 == 0),
+			},
+		}
+	}
+	return batch
+}
+
+func pipelineProcessors() []core.DataProcessor {
+	return []core.DataProcessor{&droppingProcessor{}, &hashingProcessor{}, &countingProcessor{}, &hashingProcessor{}}
+}
+
+func TestNewProcessingStages(t *testing.T) {
+	stages := newProcessingStages(pipelineProcessors(), 4)
+	require.Equal(t, 3, len(stages))
+	assert.True(t, stages[0].sharded)
+	assert.Equal(t, 2, len(stages[0].processors))
+	assert.False(t, stages[1].sharded)
+	assert.True(t, stages[2].sharded)
+
+	for _, stage := range newProcessingStages(pipelineProcessors(), 1) {
+		assert.False(t, stage.sharded)
+		assert.Equal(t, 1, len(stage.processors))
+	}
+}
+
+func TestPipelineResultIndependentOfWorkers(t *testing.T) {
+	expected, err := runPipeline(newProcessingStages(pipelineProcessors(), 1), 1, pipelineBatch(1000))
+	require.NoError(t, err)
+	assert.Equal(t, 901, len(expected.MetricSets))
+	// The stateful processor sees the whole batch.
+	assert.Equal(t, "900", expected.MetricSets[core.ClusterKey()].Labels["count"])
+
+	for _, workers := range []int{2, 3, 8, 2000} {
+		batch := pipelineBatch(1000)
+		result, err := runPipeline(newProcessingStages(pipelineProcessors(), workers), workers, batch)
+		require.NoError(t, err)
+		assert.Equal(t, batch.Timestamp, result.Timestamp)
+		assert.Equal(t, expected.MetricSets, result.MetricSets, "%d workers", workers)
+	}
+}
+
+// clusterBatch returns the batch of a small cluster: the 10 pods of 2 containers each of
+// deployment web run on node n1 but for the last 2 on node n2, node n2 also runs the single
+// pod of stateful set db, and every node has a system container. The nodes are in zones z1
+// and z2.
+func clusterBatch() *core.DataBatch {
+	batch := &core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	add := func(key, metricSetType, node string, labels map[string]string) {
+		metricSet := &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: metricSetType,
+				core.LabelNodename.Key:      node,
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricCpuUsageRate.Name: {
+					ValueType:  core.ValueInt64,
+					MetricType: core.MetricGauge,
+					IntValue:   100,
+				},
+				core.MetricMemoryWorkingSet.Name: {
+					ValueType:  core.ValueInt64,
+					MetricType: core.MetricGauge,
+					IntValue:   1000,
+				},
+				core.MetricMemoryUsage.Name: {
+					ValueType:  core.ValueInt64,
+					MetricType: core.MetricGauge,
+					IntValue:   2000,
+				},
+			},
+		}
+		if metricSetType == core.MetricSetTypeNode {
+			for _, capacity := range []core.Metric{core.MetricNodeCpuCapacity, core.MetricNodeMemoryCapacity} {
+				metricSet.MetricValues[capacity.Name] = core.MetricValue{
+					ValueType:  core.ValueFloat,
+					MetricType: core.MetricGauge,
+					FloatValue: 4000,
+				}
+			}
+		}
+		for name, value := range labels {
+			metricSet.Labels[name] = value
+		}
+		batch.MetricSets[key] = metricSet
+	}
+	zones := map[string]string{"n1": "z1", "n2": "z2"}
+	for node, zone := range zones {
+		add(core.NodeKey(node), core.MetricSetTypeNode, node, map[string]string{core.LabelZone.Key: zone})
+		add(core.NodeContainerKey(node, "kubelet"), core.MetricSetTypeSystemContainer, node, nil)
+	}
+	addPod := func(namespace, kind, workload, pod, node string, containers ...string) {
+		labels := map[string]string{
+			core.LabelNamespaceName.Key: namespace,
+			core.LabelPodName.Key:       pod,
+			core.LabelZone.Key:          zones[node],
+		}
+		add(core.PodKey(namespace, pod), core.MetricSetTypePod, node, labels)
+		podMetricSet := batch.MetricSets[core.PodKey(namespace, pod)]
+		podMetricSet.Labels[core.LabelWorkloadKind.Key] = kind
+		podMetricSet.Labels[core.LabelWorkloadName.Key] = workload
+		podMetricSet.MetricValues[core.MetricPodNetworkTxRate.Name] = core.MetricValue{
+			ValueType:  core.ValueFloat,
+			MetricType: core.MetricGauge,
+			FloatValue: 10,
+		}
+		for _, container := range containers {
+			add(core.PodContainerKey(namespace, pod, container), core.MetricSetTypePodContainer, node, labels)
+			containerMetricSet := batch.MetricSets[core.PodContainerKey(namespace, pod, container)]
+			for metric, value := range map[string]int64{
+				core.MetricCpuRequest.Name:            100,
+				core.MetricCpuLimit.Name:              200,
+				core.MetricContainerCpuUsagePeak.Name: 5,
+			} {
+				containerMetricSet.MetricValues[metric] = core.MetricValue{
+					ValueType:  core.ValueInt64,
+					MetricType: core.MetricGauge,
+					IntValue:   value,
+				}
+			}
+		}
+		batch.MetricSets[core.NamespaceKey(namespace)] = &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypeNamespace,
+				core.LabelNamespaceName.Key: namespace,
+			},
+			MetricValues: map[string]core.MetricValue{},
+		}
+		batch.MetricSets[core.WorkloadKey(namespace, kind, workload)] = &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypeWorkload,
+				core.LabelNamespaceName.Key: namespace,
+			},
+			MetricValues: map[string]core.MetricValue{},
+		}
+	}
+	for i := 0; i < 10; i++ {
+		node := "n1"
+		if i >= 8 {
+			node = "n2"
+		}
+		addPod("ns1", "Deployment", "web", fmt.Sprintf("web-%d", i), node, "app", "sidecar")
+	}
+	addPod("ns2", "StatefulSet", "db", "db-0", "n2", "db")
+	return batch
+}
+
+// Processors that look at more than one metric set give the same result whatever the
+// number of workers.
+func TestPipelineWorkersWithProcessors(t *testing.T) {
+	for _, processor := range []core.DataProcessor{
+		&processors.NodeDensityCalculator{},
+		&processors.ClusterUtilizationCalculator{},
+		&processors.ReplicaSpreadCalculator{},
+		&processors.SystemOverheadCalculator{},
+		&processors.EgressRateCalculator{},
+		&processors.BurstRatioCalculator{},
+		&processors.OverprovisioningCalculator{Threshold: 0.1},
+	} {
+		expected, err := runPipeline(newProcessingStages([]core.DataProcessor{processor}, 1), 1, clusterBatch())
+		require.NoError(t, err)
+		for _, workers := range []int{2, 4, 8} {
+			result, err := runPipeline(newProcessingStages([]core.DataProcessor{processor}, workers), workers, clusterBatch())
+			require.NoError(t, err)
+			assert.Equal(t, expected.MetricSets, result.MetricSets, "%s with %d workers", processor.Name(), workers)
+		}
+	}
+
+	batch, err := runPipeline(newProcessingStages([]core.DataProcessor{&processors.NodeDensityCalculator{}}, 4), 4, clusterBatch())
+	require.NoError(t, err)
+	assert.Equal(t, int64(16), batch.MetricSets[core.NodeKey("n1")].MetricValues[core.MetricNodeContainerCount.Name].IntValue)
+	assert.Equal(t, int64(8), batch.MetricSets[core.NodeKey("n1")].MetricValues[core.MetricNodePodCount.Name].IntValue)
+
+	batch, err = runPipeline(newProcessingStages([]core.DataProcessor{&processors.ClusterUtilizationCalculator{}}, 4), 4, clusterBatch())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), batch.MetricSets[core.ClusterKey()].MetricValues[core.MetricClusterNodeCount.Name].IntValue)
+
+	batch, err = runPipeline(newProcessingStages([]core.DataProcessor{&processors.ReplicaSpreadCalculator{}}, 4), 4, clusterBatch())
+	require.NoError(t, err)
+	web := batch.MetricSets[core.WorkloadKey("ns1", "Deployment", "web")].MetricValues
+	assert.Equal(t, int64(2), web[core.MetricWorkloadReplicaSpreadNodes.Name].IntValue)
+	assert.Equal(t, int64(2), web[core.MetricWorkloadReplicaSpreadZones.Name].IntValue)
+
+	batch, err = runPipeline(newProcessingStages([]core.DataProcessor{&processors.SystemOverheadCalculator{}}, 4), 4, clusterBatch())
+	require.NoError(t, err)
+	for _, node := range []string{"n1", "n2"} {
+		assert.InDelta(t, 100, batch.MetricSets[core.NodeKey(node)].MetricValues[core.MetricNodeSystemOverheadCpuPct.Name].FloatValue, 1e-3, node)
+	}
+
+	batch, err = runPipeline(newProcessingStages([]core.DataProcessor{&processors.EgressRateCalculator{}}, 4), 4, clusterBatch())
+	require.NoError(t, err)
+	assert.InDelta(t, 100, batch.MetricSets[core.NamespaceKey("ns1")].MetricValues[core.MetricPodNetworkTxTotalBytesRate.Name].FloatValue, 1e-3)
+
+	batch, err = runPipeline(newProcessingStages([]core.DataProcessor{&processors.BurstRatioCalculator{}}, 4), 4, clusterBatch())
+	require.NoError(t, err)
+	assert.InDelta(t, 2, batch.MetricSets[core.WorkloadKey("ns1", "Deployment", "web")].MetricValues[core.MetricWorkloadCpuBurstRatio.Name].FloatValue, 1e-3)
+
+	batch, err = runPipeline(newProcessingStages([]core.DataProcessor{&processors.OverprovisioningCalculator{Threshold: 0.1}}, 4), 4, clusterBatch())
+	require.NoError(t, err)
+	assert.InDelta(t, 1.9, batch.MetricSets[core.NamespaceKey("ns1")].MetricValues[core.MetricContainerWasteCpuCores.Name].FloatValue, 1e-3)
+}
+
+func TestPipelineShardError(t *testing.T) {
+	failing := &failingProcessor{}
+	_, err := runPipeline(newProcessingStages([]core.DataProcessor{&hashingProcessor{}, failing}, 4), 4, pipelineBatch(100))
+	assert.Error(t, err)
+}
+
+type failingProcessor struct{}
+
+func (this *failingProcessor) Name() string {
+	return "failing"
+}
+
+func (this *failingProcessor) Stateless() {}
+
+func (this *failingProcessor) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels["name"] == "pod-42" {
+			return nil, fmt.Errorf("failed")
+		}
+	}
+	return batch, nil
+}
+
+func benchmarkPipeline(b *testing.B, workers int) {
+	stages := newProcessingStages(pipelineProcessors(), workers)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		batch := pipelineBatch(10000)
+		b.StartTimer()
+		if _, err := runPipeline(stages, workers, batch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPipeline1Worker(b *testing.B) {
+	benchmarkPipeline(b, 1)
+}
+
+func BenchmarkPipeline4Workers(b *testing.B) {
+	benchmarkPipeline(b, 4)
+}
+
+func BenchmarkPipeline8Workers(b *testing.B) {
+	benchmarkPipeline(b, 8)
+}
@@ -0,0 +1,222 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/pflag"
+
+	"k8s.io/heapster/common/flags"
+)
+
+// Config is the content of the file given with --config_file, in YAML or JSON.
+type Config struct {
+	Sources []ConfigUri `json:"sources"`
+	Sinks   []ConfigUri `json:"sinks"`
+	// Values of the other flags by name, e.g. metric_resolution: 30s. Lists are
+	// accepted for the flags that can be repeated.
+	Flags map[string]interface{} `json:"flags"`
+}
+
+// ConfigUri is a source or a sink, equivalent to --source or --sink=<kind>:<url>?<options>.
+type ConfigUri struct {
+	Kind string `json:"kind"`
+	Url  string `json:"url"`
+	// Added to the query of the url. Lists are accepted for the repeated options, e.g. the
+	// brokers of the kafka sink.
+	Options map[string]interface{} `json:"options"`
+}
+
+// Flags that are set in their own sections of the configuration file, or not at all.
+var notConfigurableFlags = []string{"config_file", "source", "sink"}
+
+// LoadConfig reads and validates the configuration file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the configuration file: %v", err)
+	}
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the configuration file %s: %v", path, err)
+	}
+	config := &Config{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %v", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// validate checks the sources, the sinks and the types of the flag values. The flag
+// values themselves are checked when they are applied to the flags.
+func (c *Config) validate() error {
+	if _, err := configUris("source", c.Sources); err != nil {
+		return err
+	}
+	if _, err := configUris("sink", c.Sinks); err != nil {
+		return err
+	}
+	for name, value := range c.Flags {
+		if _, err := configValues(value); err != nil {
+			return fmt.Errorf("invalid value of flag %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// ApplyConfig merges the configuration into the options parsed from the flags of fs. The
+// flags given on the command line take precedence: --source replaces the sources of the
+// configuration, --sink replaces its sinks of the same kind, and the other flags keep
+// their command line value.
+func (h *HeapsterRunOptions) ApplyConfig(config *Config, fs *pflag.FlagSet) error {
+	sources, err := configUris("source", config.Sources)
+	if err != nil {
+		return err
+	}
+	if len(h.Sources) == 0 {
+		h.Sources = sources
+	}
+
+	sinks, err := configUris("sink", config.Sinks)
+	if err != nil {
+		return err
+	}
+	overridden := make(map[string]bool, len(h.Sinks))
+	for _, sink := range h.Sinks {
+		overridden[sink.Key] = true
+	}
+	mergedSinks := flags.Uris{}
+	for _, sink := range sinks {
+		if !overridden[sink.Key] {
+			mergedSinks = append(mergedSinks, sink)
+		}
+	}
+	h.Sinks = append(mergedSinks, h.Sinks...)
+
+	// Sorted for the first error to be deterministic.
+	names := make([]string, 0, len(config.Flags))
+	for name := range config.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flag := fs.Lookup(name)
+		if flag == nil {
+			return fmt.Errorf("unknown flag %q in the configuration file", name)
+		}
+		for _, notConfigurable := range notConfigurableFlags {
+			if fs.Lookup(notConfigurable) == flag {
+				return fmt.Errorf("flag %q can not be set in the flags of the configuration file", name)
+			}
+		}
+		if flag.Changed {
+			continue
+		}
+		values, err := configValues(config.Flags[name])
+		if err != nil {
+			return fmt.Errorf("invalid value of flag %q in the configuration file: %v", name, err)
+		}
+		for _, value := range values {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("invalid value of flag %q in the configuration file: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func configUris(section string, configUris []ConfigUri) (flags.Uris, error) {
+	uris := make(flags.Uris, 0, len(configUris))
+	for i, configUri := range configUris {
+		if configUri.Kind == "" {
+			return nil, fmt.Errorf("missing kind of %s %d", section, i)
+		}
+		// The options are appended to the url as given, for the environment variables of
+		// both to be expanded by the URI, like on the command line.
+		names := make([]string, 0, len(configUri.Options))
+		for name := range configUri.Options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		query := []string{}
+		for _, name := range names {
+			values, err := configValues(configUri.Options[name])
+			if err != nil {
+				return nil, fmt.Errorf("invalid option %q of %s %q: %v", name, section, configUri.Kind, err)
+			}
+			for _, value := range values {
+				query = append(query, queryEscape(name)+"="+queryEscape(value))
+			}
+		}
+		value := configUri.Url
+		if len(query) > 0 {
+			separator := "?"
+			if strings.Contains(value, "?") {
+				separator = "&"
+			}
+			value += separator + strings.Join(query, "&")
+		}
+		var uri flags.Uri
+		if err := uri.Set(configUri.Kind + ":" + value); err != nil {
+			return nil, fmt.Errorf("invalid url of %s %q: %v", section, configUri.Kind, err)
+		}
+		uris = append(uris, uri)
+	}
+	return uris, nil
+}
+
+var envReferenceUnescaper = strings.NewReplacer("%24", "$", "%7B", "{", "%7D", "}")
+
+// queryEscape escapes the option like url.QueryEscape, except for the references to
+// environment variables.
+func queryEscape(option string) string {
+	return envReferenceUnescaper.Replace(url.QueryEscape(option))
+}
+
+// configValues returns the scalar or the list of scalars as strings.
+func configValues(value interface{}) ([]string, error) {
+	list, isList := value.([]interface{})
+	if !isList {
+		list = []interface{}{value}
+	}
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		switch v := item.(type) {
+		case string:
+			values = append(values, v)
+		case bool:
+			values = append(values, strconv.FormatBool(v))
+		case float64:
+			values = append(values, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			return nil, fmt.Errorf("expected a string, number, boolean or a list of them, got %v", item)
+		}
+	}
+	return values, nil
+}
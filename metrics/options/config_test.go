@@ -0,0 +1,168 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/sinks"
+)
+
+const sampleConfig = `
+sources:
+- kind: kubernetes.summary_api
+  url: https://kubernetes.default
+  options:
+    kubeletHttps: true
+    kubeletPort: 10250
+sinks:
+- kind: log
+- kind: loki
+  url: http://loki:3100
+  options:
+    tenant: monitoring
+    timeout: 5s
+flags:
+  metric_resolution: 30s
+  oom_risk_threshold: 0.8
+  store_label:
+  - app
+  - tier
+`
+
+func loadConfig(t *testing.T, content string) (*Config, error) {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "heapster.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	return LoadConfig(path)
+}
+
+func parseFlags(t *testing.T, args ...string) (*HeapsterRunOptions, *pflag.FlagSet) {
+	opt := NewHeapsterRunOptions()
+	fs := pflag.NewFlagSet("heapster", pflag.ContinueOnError)
+	opt.AddFlags(fs)
+	require.NoError(t, fs.Parse(args))
+	return opt, fs
+}
+
+func TestApplySampleConfig(t *testing.T) {
+	config, err := loadConfig(t, sampleConfig)
+	require.NoError(t, err)
+	opt, fs := parseFlags(t)
+	require.NoError(t, opt.ApplyConfig(config, fs))
+
+	require.Len(t, opt.Sources, 1)
+	assert.Equal(t, "kubernetes.summary_api", opt.Sources[0].Key)
+	assert.Equal(t, "https://kubernetes.default?kubeletHttps=true&kubeletPort=10250", opt.Sources[0].Val.String())
+	assert.Equal(t, 30*time.Second, opt.MetricResolution)
+	assert.Equal(t, 0.8, opt.OOMRiskThreshold)
+	assert.Equal(t, []string{"app", "tier"}, opt.StoredLabels)
+
+	metricSink, sinkList, _, err := sinks.NewSinkFactory().BuildAll(opt.Sinks, "", false)
+	require.NoError(t, err)
+	assert.NotNil(t, metricSink)
+	names := []string{}
+	for _, sink := range sinkList {
+		names = append(names, sink.Name())
+	}
+	assert.Len(t, names, 3)
+	assert.Contains(t, names, "Log Sink")
+	assert.Contains(t, names, "Loki Sink")
+}
+
+func TestCommandLineOverridesConfig(t *testing.T) {
+	config, err := loadConfig(t, sampleConfig)
+	require.NoError(t, err)
+	opt, fs := parseFlags(t,
+		"--source=kubernetes:https://kubernetes.default",
+		"--sink=loki:http://other-loki:3100",
+		"--sink=influxdb:http://influxdb:8086",
+		"--metric_resolution=15s")
+	require.NoError(t, opt.ApplyConfig(config, fs))
+
+	require.Len(t, opt.Sources, 1)
+	assert.Equal(t, "kubernetes", opt.Sources[0].Key)
+	require.Len(t, opt.Sinks, 3)
+	assert.Equal(t, "log", opt.Sinks[0].Key)
+	assert.Equal(t, "http://other-loki:3100", opt.Sinks[1].Val.String())
+	assert.Equal(t, "influxdb", opt.Sinks[2].Key)
+	assert.Equal(t, 15*time.Second, opt.MetricResolution)
+	assert.Equal(t, 0.8, opt.OOMRiskThreshold)
+}
+
+func TestConfigExpandsEnv(t *testing.T) {
+	os.Setenv("HEAPSTER_TEST_INFLUXDB_PASSWORD", "s3cr3t")
+	defer os.Unsetenv("HEAPSTER_TEST_INFLUXDB_PASSWORD")
+	config, err := loadConfig(t, `
+sinks:
+- kind: influxdb
+  url: http://monitoring-influxdb:8086?user=root
+  options:
+    pw: ${HEAPSTER_TEST_INFLUXDB_PASSWORD}
+    db: k8s&metrics
+`)
+	require.NoError(t, err)
+	opt, fs := parseFlags(t)
+	require.NoError(t, opt.ApplyConfig(config, fs))
+
+	require.Len(t, opt.Sinks, 1)
+	query := opt.Sinks[0].Val.Query()
+	assert.Equal(t, "root", query.Get("user"))
+	assert.Equal(t, "s3cr3t", query.Get("pw"))
+	assert.Equal(t, "k8s&metrics", query.Get("db"))
+	assert.NotContains(t, opt.Sinks.String(), "s3cr3t")
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, content := range []string{
+		"sinks: [",
+		"sink:\n- kind: log\n",
+		"sinks:\n- kind: log\n  uri: http://log\n",
+		"sinks:\n- url: http://influxdb:8086\n",
+		"sinks: log\n",
+		"sinks:\n- kind: loki\n  url: \"http://loki:3100/%zz\"\n",
+		"flags:\n  store_label:\n  - app: web\n",
+	} {
+		_, err := loadConfig(t, content)
+		assert.Error(t, err, "config %q", content)
+	}
+
+	for _, content := range []string{
+		"flags:\n  no_such_flag: 1\n",
+		"flags:\n  metric_resolution: fast\n",
+		"flags:\n  sink: log\n",
+		"flags:\n  config_file: other.yaml\n",
+	} {
+		config, err := loadConfig(t, content)
+		require.NoError(t, err, "config %q", content)
+		opt, fs := parseFlags(t)
+		assert.Error(t, opt.ApplyConfig(config, fs), "config %q", content)
+	}
+}
+
+func TestMissingConfigFile(t *testing.T) {
+	_, err := LoadConfig("/does/not/exist.yaml")
+	assert.Error(t, err)
+}
@@ -33,25 +33,68 @@ type HeapsterRunOptions struct {
 	// Only to be used to for testing
 	DisableAuthForTesting bool
 
-	MetricResolution      time.Duration
-	EnableAPIServer       bool
-	Port                  int
-	Ip                    string
-	MaxProcs              int
-	TLSCertFile           string
-	TLSKeyFile            string
-	TLSClientCAFile       string
-	AllowedUsers          string
-	Sources               flags.Uris
-	Sinks                 flags.Uris
-	HistoricalSource      string
-	Version               bool
-	LabelSeparator        string
-	IgnoredLabels         []string
-	StoredLabels          []string
-	DisableMetricExport   bool
-	SinkExportDataTimeout time.Duration
-	DisableMetricSink     bool
+	MetricResolution        time.Duration
+	EnableAPIServer         bool
+	Port                    int
+	Ip                      string
+	MaxProcs                int
+	TLSCertFile             string
+	TLSKeyFile              string
+	TLSClientCAFile         string
+	AllowedUsers            string
+	Sources                 flags.Uris
+	Sinks                   flags.Uris
+	HistoricalSource        string
+	ConfigFile              string
+	Version                 bool
+	LabelSeparator          string
+	IgnoredLabels           []string
+	StoredLabels            []string
+	DisableMetricExport     bool
+	DisableSelfMetrics      bool
+	SinkExportDataTimeout   time.Duration
+	DisableMetricSink       bool
+	AvailabilityWindow      time.Duration
+	AvailabilityPctWindow   time.Duration
+	AvailabilityReadiness   bool
+	PeakUsageWindow         time.Duration
+	CpuPercentilesWindow    time.Duration
+	MemoryGrowthWindow      time.Duration
+	RestartVelocityWindow   time.Duration
+	FlappingThreshold       float64
+	MetricTransforms        []string
+	LabelAggregations       []string
+	AggregateByTopology     bool
+	AggregateByService      bool
+	ServiceAttribution      string
+	OOMRiskThreshold        float64
+	OOMRiskWindow           time.Duration
+	TimeOverLimitThreshold  float64
+	OverprovisionThreshold  float64
+	TimeOverLimitWindow     time.Duration
+	EvictionMemoryAvailable string
+	EvictionNodeFsAvailable string
+	RateWindowSamples       int
+	MaxSinkQueueDepth       int
+	ScrapeOffset            time.Duration
+	ProcessingWorkers       int
+	HistogramBuckets        []string
+	DisableContainerMetrics bool
+	NamespaceAllowlist      []string
+	NamespaceDenylist       []string
+	MetricFilterConfig      string
+	NormalizeContainerImage bool
+	DropFullContainerImage  bool
+	ContainerVersionInfo    bool
+	LabelPvcName            bool
+	LabelContainerType      bool
+	LabelPodPriority        bool
+	ContainerAgeBuckets     bool
+	NodeScrapeIntervals     []string
+	NamespaceFairShare      bool
+	WorkloadMemoryHeadroom  bool
+	FairShareWeights        []string
+	MetricMaxValues         []string
 }
 
 func NewHeapsterRunOptions() *HeapsterRunOptions {
@@ -71,7 +114,9 @@ func (h *HeapsterRunOptions) AddFlags(fs *pflag.FlagSet) {
 
 	fs.Var(&h.Sources, "source", "source(s) to watch")
 	fs.Var(&h.Sinks, "sink", "external sink(s) that receive data")
+	fs.StringVar(&h.ConfigFile, "config_file", "", "YAML or JSON file defining the sources, sinks and values of other flags; the flags given on the command line take precedence")
 	fs.DurationVar(&h.MetricResolution, "metric_resolution", 60*time.Second, "The resolution at which heapster will retain metrics.")
+	fs.StringArrayVar(&h.NodeScrapeIntervals, "node_scrape_interval", []string{}, "Scrape the nodes matching a label selector less often than every --metric_resolution, in the form <label selector>:<duration>, e.g. tier=edge:60s; can be repeated, the first match applies")
 
 	// TODO: Revise these flags before Heapster v1.3 and Kubernetes v1.5
 	fs.BoolVar(&h.EnableAPIServer, "api-server", false, "Enable API server for the Metrics API. "+
@@ -90,6 +135,47 @@ func (h *HeapsterRunOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringSliceVar(&h.IgnoredLabels, "ignore_label", []string{}, "ignore this label when joining labels")
 	fs.StringSliceVar(&h.StoredLabels, "store_label", []string{}, "store this label separately from joined labels with the same name (name) or with different name (newName=name)")
 	fs.BoolVar(&h.DisableMetricExport, "disable_export", false, "Disable exporting metrics in api/v1/metric-export")
+	fs.BoolVar(&h.DisableSelfMetrics, "disable_self_metrics", false, "Do not serve the /metrics endpoint with the Prometheus metrics of Heapster itself; Prometheus sinks with the port option are served regardless")
 	fs.DurationVar(&h.SinkExportDataTimeout, "sink_export_data_timeout", 20*time.Second, "Timeout for exporting data to a sink")
+	fs.DurationVar(&h.ScrapeOffset, "scrape_offset", 5*time.Second, "Delay of the scrapes after the end of every --metric_resolution window, to scrape shortly after the kubelet housekeeping updated the stats")
+	fs.IntVar(&h.MaxSinkQueueDepth, "max_sink_queue_depth", 0, "Skip scrapes while a sink has more than this many batches waiting to be exported, 0 to disable")
 	fs.BoolVar(&h.DisableMetricSink, "disable_metric_sink", false, "Disable metric sink")
+	fs.StringSliceVar(&h.MetricTransforms, "sink_metric_transform", []string{}, "scale/offset applied to a metric before it is exported to the external sinks, in the form <metric>:scale=<float>[:offset=<float>][:units=<name>]")
+	fs.StringSliceVar(&h.LabelAggregations, "aggregate_by_label", []string{}, "aggregate pod metrics by the value of this label, in the form <label>[:avg]; the label has to be stored with --store_label")
+	fs.BoolVar(&h.AggregateByTopology, "aggregate_by_topology", false, "Label the metrics with the zone and region of their node and aggregate the node metrics per zone and region")
+	fs.BoolVar(&h.AggregateByService, "aggregate_by_service", false, "Aggregate the pod metrics per service, based on the endpoints of the services")
+	fs.StringVar(&h.ServiceAttribution, "service_attribution", "full", "How the metrics of a pod backing several services are aggregated with --aggregate_by_service: full to count them in every service, fractional to split them evenly among the services")
+	fs.Float64Var(&h.OOMRiskThreshold, "oom_risk_threshold", 0.9, "Share of the memory limit used by the working set above which a container is at risk of being OOM killed")
+	fs.DurationVar(&h.OOMRiskWindow, "oom_risk_window", 15*time.Minute, "Time for which a container has to stay above --oom_risk_threshold to be flagged with container/oom_risk_sustained")
+	fs.Float64Var(&h.TimeOverLimitThreshold, "time_over_limit_threshold", 0.9, "Share of the CPU or memory limit above which the time of a container is counted in container/time_over_limit_pct")
+	fs.DurationVar(&h.TimeOverLimitWindow, "time_over_limit_window", 0, "Window over which container/time_over_limit_pct is computed, e.g. 15m like the model API, 0 to disable")
+	fs.StringVar(&h.EvictionMemoryAvailable, "eviction_memory_available", "100Mi", "Available memory below which a node is flagged with node/memory_pressure, as a quantity or a percentage of the capacity, like the memory.available eviction threshold of the kubelet")
+	fs.StringVar(&h.EvictionNodeFsAvailable, "eviction_nodefs_available", "10%", "Available space on the root filesystem below which a node is flagged with node/disk_pressure, as a quantity or a percentage of the capacity, like the nodefs.available eviction threshold of the kubelet")
+	fs.DurationVar(&h.AvailabilityWindow, "availability_window", 0, "Window over which the restart-adjusted container availability is computed, 0 to disable")
+	fs.DurationVar(&h.AvailabilityPctWindow, "availability_pct_window", 0, "Window over which container/availability_pct is computed from the restarts of the containers, e.g. 15m like the model API, 0 to disable")
+	fs.BoolVar(&h.AvailabilityReadiness, "availability_readiness", false, "Count the time during which a container is not ready as unavailable in container/availability_pct")
+	fs.Float64Var(&h.OverprovisionThreshold, "overprovisioned_threshold", 0, "Share of the CPU request below which the peak CPU usage of a container over --peak_usage_window flags it as container/overprovisioned, 0 to disable")
+	fs.DurationVar(&h.PeakUsageWindow, "peak_usage_window", 0, "Window over which the peak CPU usage rate and memory working set of the containers are computed, 0 to disable")
+	fs.DurationVar(&h.CpuPercentilesWindow, "cpu_percentiles_window", 0, "Window over which the 50th, 95th and 99th percentiles of the CPU usage rate of the containers are computed, e.g. 15m like the model API, 0 to disable")
+	fs.DurationVar(&h.MemoryGrowthWindow, "memory_growth_window", 0, "Window over which the growth of the memory working set of the containers is computed, e.g. 15m like the model API, 0 to disable")
+	fs.DurationVar(&h.RestartVelocityWindow, "restart_velocity_window", 0, "Window over which the restarts per hour of the containers are computed, 0 to disable")
+	fs.Float64Var(&h.FlappingThreshold, "flapping_threshold", 6, "Restarts per hour over --restart_velocity_window above which a container is labeled as flapping")
+	fs.StringSliceVar(&h.NamespaceAllowlist, "namespace_allowlist", []string{}, "Only collect the metrics of pods in these namespaces, all namespaces if empty")
+	fs.StringSliceVar(&h.NamespaceDenylist, "namespace_denylist", []string{}, "Do not collect the metrics of pods in these namespaces; can not be used with --namespace_allowlist")
+	fs.StringVar(&h.MetricFilterConfig, "metric_filter_config", "", "File with the allowlist or denylist of the exported metric names, reloaded on SIGHUP")
+	fs.BoolVar(&h.NormalizeContainerImage, "normalize_container_image", false, "Strip the tag and digest from the container_base_image label and store the full image in the container_image label")
+	fs.BoolVar(&h.DropFullContainerImage, "drop_full_container_image", false, "Do not store the full image in the container_image label when --normalize_container_image is set")
+	fs.BoolVar(&h.ContainerVersionInfo, "container_version_info", false, "Label the containers with the app_version parsed from their image tag and report container/info, always 1, to join other metrics with the versions")
+	fs.BoolVar(&h.LabelPvcName, "label_pvc_name", false, "Label the filesystem metrics of the volumes backed by a persistent volume claim with the claim name in the pvc_name label")
+	fs.BoolVar(&h.LabelContainerType, "label_container_type", false, "Label the containers with their type in their pod, init, app or ephemeral, in the container_type label")
+	fs.BoolVar(&h.LabelPodPriority, "label_pod_priority", false, "Report the scheduling priority of the pods in pod/priority and label them with their priority class in the priority_class label")
+	fs.BoolVar(&h.ContainerAgeBuckets, "container_age_buckets", false, "Label the containers with the age_bucket of the time since they started and count the containers of every namespace per bucket as namespace/containers_by_age")
+	fs.BoolVar(&h.DisableContainerMetrics, "disable_container_metrics", false, "Fold the container metrics into their pods and only export pod level metrics")
+	fs.BoolVar(&h.WorkloadMemoryHeadroom, "workload_memory_headroom", false, "Sum up container/memory_request_headroom of the containers of every workload on the workload")
+	fs.BoolVar(&h.NamespaceFairShare, "namespace_fair_share", false, "Compute how much each namespace exceeds its fair share of the cluster capacity as namespace/fair_share_overage")
+	fs.StringSliceVar(&h.FairShareWeights, "fair_share_weight", []string{}, "Weight of a namespace in the fair share split of the cluster capacity, in the form <namespace>=<weight>; namespaces without a weight have weight 1")
+	fs.IntVar(&h.ProcessingWorkers, "processing_workers", 1, "Number of workers among which the metric sets are split for the stateless processors, e.g. the enrichers; the aggregators always see the whole batch")
+	fs.StringSliceVar(&h.HistogramBuckets, "histogram_buckets", []string{}, "Upper bounds in seconds of the buckets of the latency histograms of Heapster, e.g. heapster_kubelet_request_duration_seconds; the Prometheus client defaults if empty")
+	fs.StringSliceVar(&h.MetricMaxValues, "metric_max_value", []string{}, "Maximum value of a metric, in the form <metric>=<max>, e.g. memory/usage=1e13; larger values, usually cadvisor glitches, and negative ones of metrics that can not be negative are dropped before any processing")
+	fs.IntVar(&h.RateWindowSamples, "rate_window_samples", 0, "Number of samples over which the rates of cumulative metrics are computed, 0 to use the last two scrapes")
 }
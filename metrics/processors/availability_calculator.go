@@ -0,0 +1,142 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/heapster/metrics/core"
+)
+
+// availabilityInterval is the time between two samples of a container, of which up was
+// spent running, and ready if readiness is taken into account.
+type availabilityInterval struct {
+	end      time.Time
+	duration time.Duration
+	up       time.Duration
+}
+
+type availabilityState struct {
+	timestamp    time.Time
+	restartCount int64
+	intervals    []availabilityInterval
+}
+
+// AvailabilityCalculator computes the percentage of the window during which every pod
+// container was available, from the increases of its restart count between the batches:
+// after a restart, only the time since the container started again counts as available.
+// If a pod lister is given, the intervals at the end of which the container is not ready
+// do not count either. Only the observed part of the window is taken into account, so
+// that the percentage is reported from the second batch in which a container is seen on.
+// It has to run after the pod based enricher, which provides the restart counts.
+type AvailabilityCalculator struct {
+	window    time.Duration
+	podLister v1listers.PodLister
+	states    map[string]*availabilityState
+}
+
+func (this *AvailabilityCalculator) Name() string {
+	return "availability_calculator"
+}
+
+func (this *AvailabilityCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	states := make(map[string]*availabilityState)
+	for key, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer {
+			continue
+		}
+		restartCount, found := metricSet.MetricValues[core.MetricRestartCount.Name]
+		if !found {
+			continue
+		}
+		now := metricSet.ScrapeTime
+		if now.IsZero() {
+			now = batch.Timestamp
+		}
+
+		state, found := this.states[key]
+		if !found {
+			state = &availabilityState{timestamp: now, restartCount: restartCount.IntValue}
+		}
+		states[key] = state
+		if now.After(state.timestamp) {
+			duration := now.Sub(state.timestamp)
+			up := duration
+			if restartCount.IntValue > state.restartCount {
+				up = 0
+				if !metricSet.CollectionStartTime.IsZero() && now.Sub(metricSet.CollectionStartTime) < duration {
+					up = now.Sub(metricSet.CollectionStartTime)
+				}
+				if up < 0 {
+					up = 0
+				}
+			}
+			if !this.ready(metricSet) {
+				up = 0
+			}
+			state.intervals = append(state.intervals, availabilityInterval{end: now, duration: duration, up: up})
+			state.timestamp = now
+		}
+		state.restartCount = restartCount.IntValue
+
+		cutoff := now.Add(-this.window)
+		first := 0
+		for first < len(state.intervals) && !state.intervals[first].end.After(cutoff) {
+			first++
+		}
+		state.intervals = state.intervals[first:]
+		if len(state.intervals) == 0 {
+			continue
+		}
+		var duration, up time.Duration
+		for _, interval := range state.intervals {
+			duration += interval.duration
+			up += interval.up
+		}
+		setFloat(metricSet, &core.MetricContainerAvailabilityPct, 100*float32(up)/float32(duration))
+	}
+	// The containers missing in the batch are forgotten.
+	this.states = states
+	return batch, nil
+}
+
+// ready returns whether the container is ready according to the status of its pod. The
+// containers of unknown pods are treated as ready.
+func (this *AvailabilityCalculator) ready(container *core.MetricSet) bool {
+	if this.podLister == nil {
+		return true
+	}
+	pod, err := this.podLister.Pods(container.Labels[core.LabelNamespaceName.Key]).Get(container.Labels[core.LabelPodName.Key])
+	if err != nil || pod == nil {
+		return true
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == container.Labels[core.LabelContainerName.Key] {
+			return status.Ready
+		}
+	}
+	return true
+}
+
+// NewAvailabilityCalculator returns a calculator over the given window, taking the
+// readiness of the containers into account if a pod lister is given.
+func NewAvailabilityCalculator(window time.Duration, podLister v1listers.PodLister) *AvailabilityCalculator {
+	return &AvailabilityCalculator{
+		window:    window,
+		podLister: podLister,
+		states:    make(map[string]*availabilityState),
+	}
+}
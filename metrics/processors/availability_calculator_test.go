@@ -0,0 +1,136 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/heapster/metrics/core"
+)
+
+var availabilityKey = core.PodContainerKey("ns1", "pod1", "app")
+
+func availabilityBatch(now, start time.Time, restartCount int64) *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: now,
+		MetricSets: map[string]*core.MetricSet{
+			availabilityKey: {
+				ScrapeTime:          now,
+				CollectionStartTime: start,
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "pod1",
+					core.LabelContainerName.Key: "app",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricRestartCount.Name: intValue(restartCount),
+				},
+			},
+		},
+	}
+}
+
+func availabilityPct(t *testing.T, calculator *AvailabilityCalculator, batch *core.DataBatch) (float32, bool) {
+	batch, err := calculator.Process(batch)
+	require.NoError(t, err)
+	value, found := batch.MetricSets[availabilityKey].MetricValues[core.MetricContainerAvailabilityPct.Name]
+	return value.FloatValue, found
+}
+
+func TestAvailabilityCalculator(t *testing.T) {
+	calculator := NewAvailabilityCalculator(10*time.Minute, nil)
+	now := time.Now()
+	start := now.Add(-time.Hour)
+
+	// Not reported before a first interval is observed.
+	_, found := availabilityPct(t, calculator, availabilityBatch(now, start, 0))
+	assert.False(t, found)
+
+	pct, found := availabilityPct(t, calculator, availabilityBatch(now.Add(time.Minute), start, 0))
+	assert.True(t, found)
+	assert.InDelta(t, 100, pct, 0.001)
+
+	// Restarted 15 seconds before the scrape, so down for 45 seconds out of 2 minutes.
+	restart := now.Add(2*time.Minute - 15*time.Second)
+	pct, _ = availabilityPct(t, calculator, availabilityBatch(now.Add(2*time.Minute), restart, 1))
+	assert.InDelta(t, 62.5, pct, 0.001)
+
+	pct, _ = availabilityPct(t, calculator, availabilityBatch(now.Add(3*time.Minute), restart, 1))
+	assert.InDelta(t, 75, pct, 0.001)
+
+	// Restarted several times since the previous scrape, only the last start counts.
+	restart = now.Add(4*time.Minute - 30*time.Second)
+	pct, _ = availabilityPct(t, calculator, availabilityBatch(now.Add(4*time.Minute), restart, 3))
+	assert.InDelta(t, 100*(60+15+60+30)/240.0, pct, 0.001)
+
+	// The container missing in a batch is forgotten.
+	_, err := calculator.Process(&core.DataBatch{Timestamp: now.Add(5 * time.Minute), MetricSets: map[string]*core.MetricSet{}})
+	require.NoError(t, err)
+	_, found = availabilityPct(t, calculator, availabilityBatch(now.Add(6*time.Minute), restart, 3))
+	assert.False(t, found)
+}
+
+func TestAvailabilityWindow(t *testing.T) {
+	calculator := NewAvailabilityCalculator(2*time.Minute, nil)
+	now := time.Now()
+	start := now.Add(-time.Hour)
+	restart := now.Add(time.Minute - 30*time.Second)
+
+	availabilityPct(t, calculator, availabilityBatch(now, start, 0))
+	pct, _ := availabilityPct(t, calculator, availabilityBatch(now.Add(time.Minute), restart, 1))
+	assert.InDelta(t, 50, pct, 0.001)
+	pct, _ = availabilityPct(t, calculator, availabilityBatch(now.Add(2*time.Minute), restart, 1))
+	assert.InDelta(t, 75, pct, 0.001)
+	// The restart fell out of the window.
+	pct, _ = availabilityPct(t, calculator, availabilityBatch(now.Add(3*time.Minute), restart, 1))
+	assert.InDelta(t, 100, pct, 0.001)
+}
+
+func TestAvailabilityWithReadiness(t *testing.T) {
+	pod := &kube_api.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1"},
+		Status: kube_api.PodStatus{
+			ContainerStatuses: []kube_api.ContainerStatus{{Name: "app", Ready: true}},
+		},
+	}
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	require.NoError(t, store.Add(pod))
+	calculator := NewAvailabilityCalculator(10*time.Minute, v1listers.NewPodLister(store))
+	now := time.Now()
+	start := now.Add(-time.Hour)
+
+	availabilityPct(t, calculator, availabilityBatch(now, start, 0))
+	pct, _ := availabilityPct(t, calculator, availabilityBatch(now.Add(time.Minute), start, 0))
+	assert.InDelta(t, 100, pct, 0.001)
+
+	pod.Status.ContainerStatuses[0].Ready = false
+	require.NoError(t, store.Update(pod))
+	pct, _ = availabilityPct(t, calculator, availabilityBatch(now.Add(2*time.Minute), start, 0))
+	assert.InDelta(t, 50, pct, 0.001)
+
+	// Unknown pods are treated as ready.
+	require.NoError(t, store.Delete(pod))
+	pct, _ = availabilityPct(t, calculator, availabilityBatch(now.Add(3*time.Minute), start, 0))
+	assert.InDelta(t, 100*2/3.0, pct, 0.001)
+}
@@ -0,0 +1,98 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// Request and limit metrics from which the burst ratios are computed.
+var burstRatioMetrics = []struct {
+	request string
+	limit   string
+	ratio   *core.Metric
+}{
+	{core.MetricCpuRequest.Name, core.MetricCpuLimit.Name, &core.MetricWorkloadCpuBurstRatio},
+	{core.MetricMemoryRequest.Name, core.MetricMemoryLimit.Name, &core.MetricWorkloadMemoryBurstRatio},
+}
+
+// BurstRatioCalculator computes how far the containers of every workload are allowed to
+// burst above their requests, as the summed up limits of the containers divided by their
+// summed up requests, for CPU and memory. The ratio is 1 if the limits equal the requests,
+// as for the Guaranteed QoS class. It is not reported if a container of the workload has
+// no request or no limit, as such a workload may burst up to the node capacity. It has to
+// run after the pod based enricher, which sets the requests and limits, and after the
+// workload aggregator.
+type BurstRatioCalculator struct {
+}
+
+// Summed up requests and limits of the containers of a workload. Unbounded is set if a
+// container lacks either of them.
+type burstSums struct {
+	request   int64
+	limit     int64
+	unbounded bool
+}
+
+func (this *BurstRatioCalculator) Name() string {
+	return "burst_ratio_calculator"
+}
+
+func (this *BurstRatioCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	// Keyed by the workload and the burst ratio metric.
+	workloads := make(map[string]map[string]*burstSums)
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer {
+			continue
+		}
+		workloadKey := containerWorkloadKey(batch, metricSet)
+		if workloadKey == "" {
+			continue
+		}
+		ratios, found := workloads[workloadKey]
+		if !found {
+			ratios = make(map[string]*burstSums)
+			workloads[workloadKey] = ratios
+		}
+		for _, metric := range burstRatioMetrics {
+			sums, found := ratios[metric.ratio.Name]
+			if !found {
+				sums = &burstSums{}
+				ratios[metric.ratio.Name] = sums
+			}
+			request := metricSet.MetricValues[metric.request].IntValue
+			limit := metricSet.MetricValues[metric.limit].IntValue
+			if request <= 0 || limit <= 0 {
+				sums.unbounded = true
+				continue
+			}
+			sums.request += request
+			sums.limit += limit
+		}
+	}
+
+	for workloadKey, ratios := range workloads {
+		workload, found := batch.MetricSets[workloadKey]
+		if !found {
+			continue
+		}
+		for _, metric := range burstRatioMetrics {
+			if sums := ratios[metric.ratio.Name]; !sums.unbounded {
+				setFloat(workload, metric.ratio, float32(sums.limit)/float32(sums.request))
+			}
+		}
+	}
+	return batch, nil
+}
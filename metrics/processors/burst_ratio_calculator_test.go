@@ -0,0 +1,107 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func burstContainer(pod string, cpuRequest, cpuLimit, memoryRequest, memoryLimit int64) *core.MetricSet {
+	return &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			core.LabelNamespaceName.Key: "ns1",
+			core.LabelPodName.Key:       pod,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuRequest.Name:    intValue(cpuRequest),
+			core.MetricCpuLimit.Name:      intValue(cpuLimit),
+			core.MetricMemoryRequest.Name: intValue(memoryRequest),
+			core.MetricMemoryLimit.Name:   intValue(memoryLimit),
+		},
+	}
+}
+
+func burstWorkload() *core.MetricSet {
+	return &core.MetricSet{
+		Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeWorkload},
+		MetricValues: map[string]core.MetricValue{},
+	}
+}
+
+func TestBurstRatioCalculator(t *testing.T) {
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			// Limits of twice the CPU request, and equal to the memory request.
+			core.PodContainerKey("ns1", "web-1", "app"):  burstContainer("web-1", 100, 200, 1000, 1000),
+			core.PodContainerKey("ns1", "web-2", "app"):  burstContainer("web-2", 100, 200, 1000, 1000),
+			core.PodKey("ns1", "web-1"):                  efficiencyPod("web-1", "web"),
+			core.PodKey("ns1", "web-2"):                  efficiencyPod("web-2", "web"),
+			core.WorkloadKey("ns1", "Deployment", "web"): burstWorkload(),
+			// Containers with different ratios are summed up.
+			core.PodContainerKey("ns1", "api-1", "app"):     burstContainer("api-1", 300, 500, 1000, 3000),
+			core.PodContainerKey("ns1", "api-1", "sidecar"): burstContainer("api-1", 100, 100, 1000, 1000),
+			core.PodKey("ns1", "api-1"):                     efficiencyPod("api-1", "api"),
+			core.WorkloadKey("ns1", "Deployment", "api"):    burstWorkload(),
+			// No CPU limit.
+			core.PodContainerKey("ns1", "batch-1", "job"):  burstContainer("batch-1", 100, 0, 1000, 1500),
+			core.PodKey("ns1", "batch-1"):                  efficiencyPod("batch-1", "batch"),
+			core.WorkloadKey("ns1", "Deployment", "batch"): burstWorkload(),
+			// Not part of a workload.
+			core.PodContainerKey("ns1", "single", "app"): burstContainer("single", 100, 200, 1000, 1000),
+			core.PodKey("ns1", "single"):                 efficiencyPod("single", ""),
+		},
+	}
+	batch, err := (&BurstRatioCalculator{}).Process(batch)
+	require.NoError(t, err)
+
+	ratio := func(workload string, metric core.Metric) (float32, bool) {
+		value, found := batch.MetricSets[core.WorkloadKey("ns1", "Deployment", workload)].MetricValues[metric.Name]
+		return value.FloatValue, found
+	}
+	for _, tc := range []struct {
+		workload string
+		metric   core.Metric
+		expected float32
+	}{
+		{"web", core.MetricWorkloadCpuBurstRatio, 2},
+		{"web", core.MetricWorkloadMemoryBurstRatio, 1},
+		{"api", core.MetricWorkloadCpuBurstRatio, 1.5},
+		{"api", core.MetricWorkloadMemoryBurstRatio, 2},
+		{"batch", core.MetricWorkloadMemoryBurstRatio, 1.5},
+	} {
+		value, found := ratio(tc.workload, tc.metric)
+		if assert.True(t, found, tc.workload+" "+tc.metric.Name) {
+			assert.InDelta(t, tc.expected, value, 0.001, tc.workload+" "+tc.metric.Name)
+		}
+	}
+	_, found := ratio("batch", core.MetricWorkloadCpuBurstRatio)
+	assert.False(t, found)
+
+	for key, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] == core.MetricSetTypeWorkload {
+			continue
+		}
+		_, found := metricSet.MetricValues[core.MetricWorkloadCpuBurstRatio.Name]
+		assert.False(t, found, key)
+	}
+}
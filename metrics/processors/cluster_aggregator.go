@@ -26,7 +26,11 @@ func (this *ClusterAggregator) Name() string {
 
 func (this *ClusterAggregator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
 	clusterKey := core.ClusterKey()
-	cluster := clusterMetricSet()
+	// The cluster metric set may already hold the metrics of a cluster level source.
+	cluster, found := batch.MetricSets[clusterKey]
+	if !found {
+		cluster = clusterMetricSet()
+	}
 	for _, metricSet := range batch.MetricSets {
 		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; found &&
 			metricSetType == core.MetricSetTypeNamespace {
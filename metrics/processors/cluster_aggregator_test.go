@@ -82,3 +82,36 @@ func TestClusterAggregate(t *testing.T) {
 	assert.True(t, found)
 	assert.Equal(t, int64(30), m3.IntValue)
 }
+
+func TestClusterAggregateKeepsSourceMetrics(t *testing.T) {
+	batch := core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NamespaceKey("ns1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypeNamespace,
+					core.LabelNamespaceName.Key: "ns1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					"m1": intValue(10),
+				},
+			},
+			core.ClusterKey(): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypeCluster,
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricEtcdObjectCount.Name: intValue(500),
+				},
+			},
+		},
+	}
+	processor := ClusterAggregator{
+		MetricsToAggregate: []string{"m1"},
+	}
+	result, err := processor.Process(&batch)
+	assert.NoError(t, err)
+	cluster := result.MetricSets[core.ClusterKey()]
+	assert.Equal(t, int64(10), cluster.MetricValues["m1"].IntValue)
+	assert.Equal(t, int64(500), cluster.MetricValues[core.MetricEtcdObjectCount.Name].IntValue)
+}
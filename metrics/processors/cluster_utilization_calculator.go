@@ -0,0 +1,71 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// ClusterUtilizationCalculator sums up the CPU and memory usage and capacity of the nodes
+// on the cluster metric set, and divides them into the utilization of the cluster. Only
+// the nodes with both the usage and the capacity of both resources are summed up, so that
+// the utilization is not skewed by nodes missing in the batch or not known to the API
+// server, and their number is emitted along to tell a partial coverage. It has to run
+// after the node autoscaling enricher, which sets the capacities.
+type ClusterUtilizationCalculator struct{}
+
+func (this *ClusterUtilizationCalculator) Name() string {
+	return "cluster_utilization_calculator"
+}
+
+func (this *ClusterUtilizationCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	var cpuUsage, memoryUsage, nodes int64
+	var cpuCapacity, memoryCapacity float64
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypeNode {
+			continue
+		}
+		nodeCpuUsage, found := metricSet.MetricValues[core.MetricCpuUsageRate.Name]
+		nodeMemoryUsage, found2 := metricSet.MetricValues[core.MetricMemoryUsage.Name]
+		nodeCpuCapacity, found3 := metricSet.MetricValues[core.MetricNodeCpuCapacity.Name]
+		nodeMemoryCapacity, found4 := metricSet.MetricValues[core.MetricNodeMemoryCapacity.Name]
+		if !found || !found2 || !found3 || !found4 || nodeCpuCapacity.FloatValue <= 0 || nodeMemoryCapacity.FloatValue <= 0 {
+			continue
+		}
+		cpuUsage += nodeCpuUsage.IntValue
+		memoryUsage += nodeMemoryUsage.IntValue
+		cpuCapacity += float64(nodeCpuCapacity.FloatValue)
+		memoryCapacity += float64(nodeMemoryCapacity.FloatValue)
+		nodes++
+	}
+
+	clusterKey := core.ClusterKey()
+	cluster, found := batch.MetricSets[clusterKey]
+	if !found {
+		cluster = clusterMetricSet()
+		batch.MetricSets[clusterKey] = cluster
+	}
+	cluster.MetricValues[core.MetricClusterNodeCount.Name] = intValue(nodes)
+	if nodes == 0 {
+		return batch, nil
+	}
+	cluster.MetricValues[core.MetricClusterCpuUsage.Name] = intValue(cpuUsage)
+	cluster.MetricValues[core.MetricClusterCpuCapacity.Name] = intValue(int64(cpuCapacity))
+	setFloat(cluster, &core.MetricClusterCpuUtilization, float32(float64(cpuUsage)/cpuCapacity))
+	cluster.MetricValues[core.MetricClusterMemoryUsage.Name] = intValue(memoryUsage)
+	cluster.MetricValues[core.MetricClusterMemoryCapacity.Name] = intValue(int64(memoryCapacity))
+	setFloat(cluster, &core.MetricClusterMemoryUtilization, float32(float64(memoryUsage)/memoryCapacity))
+	return batch, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func utilizationNode(cpuUsage, memoryUsage int64, cpuCapacity, memoryCapacity float32) *core.MetricSet {
+	metricSet := &core.MetricSet{
+		Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNode},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuUsageRate.Name: intValue(cpuUsage),
+			core.MetricMemoryUsage.Name:  intValue(memoryUsage),
+		},
+	}
+	if cpuCapacity >= 0 {
+		setFloat(metricSet, &core.MetricNodeCpuCapacity, cpuCapacity)
+	}
+	if memoryCapacity >= 0 {
+		setFloat(metricSet, &core.MetricNodeMemoryCapacity, memoryCapacity)
+	}
+	return metricSet
+}
+
+func TestClusterUtilizationCalculator(t *testing.T) {
+	batch, err := (&ClusterUtilizationCalculator{}).Process(&core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NodeKey("n1"): utilizationNode(1000, 3000, 2000, 8000),
+			core.NodeKey("n2"): utilizationNode(500, 1000, 2000, 8000),
+			// Not known to the API server.
+			core.NodeKey("n3"): utilizationNode(700, 1000, -1, -1),
+			core.NodeKey("n4"): utilizationNode(700, 1000, 2000, -1),
+			core.PodKey("ns1", "pod1"): {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePod},
+				MetricValues: map[string]core.MetricValue{core.MetricCpuUsageRate.Name: intValue(100)},
+			},
+			core.ClusterKey(): clusterMetricSet(),
+		},
+	})
+	require.NoError(t, err)
+
+	cluster := batch.MetricSets[core.ClusterKey()].MetricValues
+	assert.Equal(t, int64(2), cluster[core.MetricClusterNodeCount.Name].IntValue)
+	assert.Equal(t, int64(1500), cluster[core.MetricClusterCpuUsage.Name].IntValue)
+	assert.Equal(t, int64(4000), cluster[core.MetricClusterCpuCapacity.Name].IntValue)
+	assert.InDelta(t, 0.375, cluster[core.MetricClusterCpuUtilization.Name].FloatValue, 1e-6)
+	assert.Equal(t, int64(4000), cluster[core.MetricClusterMemoryUsage.Name].IntValue)
+	assert.Equal(t, int64(16000), cluster[core.MetricClusterMemoryCapacity.Name].IntValue)
+	assert.InDelta(t, 0.25, cluster[core.MetricClusterMemoryUtilization.Name].FloatValue, 1e-6)
+}
+
+func TestClusterUtilizationWithoutNodes(t *testing.T) {
+	batch, err := (&ClusterUtilizationCalculator{}).Process(&core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: map[string]*core.MetricSet{},
+	})
+	require.NoError(t, err)
+
+	cluster := batch.MetricSets[core.ClusterKey()].MetricValues
+	assert.Equal(t, int64(0), cluster[core.MetricClusterNodeCount.Name].IntValue)
+	_, found := cluster[core.MetricClusterCpuUtilization.Name]
+	assert.False(t, found)
+}
@@ -0,0 +1,96 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// Age buckets of the containers, by upper bound of the age, the last one open.
+var ageBuckets = []struct {
+	name  string
+	upper time.Duration
+}{
+	{"<5m", 5 * time.Minute},
+	{"<1h", time.Hour},
+	{"<1d", 24 * time.Hour},
+	{">=1d", 0},
+}
+
+// ContainerAgeCalculator labels every container with the bucket of the time since it was
+// started, so since its creation or last restart, and counts the containers of every
+// namespace per bucket. Containers whose start time is unknown are skipped. It has to run
+// after the namespace aggregator.
+type ContainerAgeCalculator struct {
+}
+
+func (this *ContainerAgeCalculator) Name() string {
+	return "container_age_calculator"
+}
+
+func (this *ContainerAgeCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	counts := make(map[string][]int64)
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer || metricSet.CollectionStartTime.IsZero() {
+			continue
+		}
+		now := metricSet.ScrapeTime
+		if now.IsZero() {
+			now = batch.Timestamp
+		}
+		bucket := ageBucket(now.Sub(metricSet.CollectionStartTime))
+		metricSet.Labels[core.LabelAgeBucket.Key] = ageBuckets[bucket].name
+
+		namespaceName := metricSet.Labels[core.LabelNamespaceName.Key]
+		namespaceCounts, found := counts[namespaceName]
+		if !found {
+			namespaceCounts = make([]int64, len(ageBuckets))
+			counts[namespaceName] = namespaceCounts
+		}
+		namespaceCounts[bucket]++
+	}
+
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypeNamespace {
+			continue
+		}
+		namespaceCounts, found := counts[metricSet.Labels[core.LabelNamespaceName.Key]]
+		if !found {
+			namespaceCounts = make([]int64, len(ageBuckets))
+		}
+		// Every bucket is reported, so that an emptied bucket drops to zero.
+		for i, bucket := range ageBuckets {
+			metricSet.LabeledMetrics = append(metricSet.LabeledMetrics, core.LabeledMetric{
+				Name:        core.MetricNamespaceContainersByAge.Name,
+				Labels:      map[string]string{core.LabelAgeBucket.Key: bucket.name},
+				MetricValue: intValue(namespaceCounts[i]),
+			})
+		}
+	}
+	return batch, nil
+}
+
+// ageBucket returns the index of the bucket of the age. Ages below zero, due to clock
+// skew, fall into the first bucket.
+func ageBucket(age time.Duration) int {
+	for i, bucket := range ageBuckets[:len(ageBuckets)-1] {
+		if age < bucket.upper {
+			return i
+		}
+	}
+	return len(ageBuckets) - 1
+}
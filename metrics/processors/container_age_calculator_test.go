@@ -0,0 +1,108 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestAgeBucket(t *testing.T) {
+	for age, expected := range map[time.Duration]string{
+		-time.Second:        "<5m",
+		0:                   "<5m",
+		4 * time.Minute:     "<5m",
+		5 * time.Minute:     "<1h",
+		59 * time.Minute:    "<1h",
+		time.Hour:           "<1d",
+		23 * time.Hour:      "<1d",
+		24 * time.Hour:      ">=1d",
+		30 * 24 * time.Hour: ">=1d",
+	} {
+		assert.Equal(t, expected, ageBuckets[ageBucket(age)].name, "age %v", age)
+	}
+}
+
+func agedContainer(namespace string, started time.Time) *core.MetricSet {
+	return &core.MetricSet{
+		CollectionStartTime: started,
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			core.LabelNamespaceName.Key: namespace,
+		},
+		MetricValues: map[string]core.MetricValue{},
+	}
+}
+
+// containersByAge returns the namespace/containers_by_age values of the metric set by bucket.
+func containersByAge(metricSet *core.MetricSet) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, metric := range metricSet.LabeledMetrics {
+		if metric.Name == core.MetricNamespaceContainersByAge.Name {
+			counts[metric.Labels[core.LabelAgeBucket.Key]] = metric.IntValue
+		}
+	}
+	return counts
+}
+
+func TestContainerAgeCalculator(t *testing.T) {
+	now := time.Now()
+	batch := &core.DataBatch{
+		Timestamp: now,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "c1"): agedContainer("ns1", now.Add(-time.Minute)),
+			core.PodContainerKey("ns1", "pod2", "c1"): agedContainer("ns1", now.Add(-2*time.Minute)),
+			core.PodContainerKey("ns1", "pod3", "c1"): agedContainer("ns1", now.Add(-48*time.Hour)),
+			core.PodContainerKey("ns2", "pod1", "c1"): agedContainer("ns2", now.Add(-2*time.Hour)),
+			core.PodContainerKey("ns2", "pod2", "c1"): agedContainer("ns2", time.Time{}),
+			core.NamespaceKey("ns1"): {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNamespace, core.LabelNamespaceName.Key: "ns1"},
+				MetricValues: map[string]core.MetricValue{},
+			},
+			core.NamespaceKey("ns2"): {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNamespace, core.LabelNamespaceName.Key: "ns2"},
+				MetricValues: map[string]core.MetricValue{},
+			},
+			core.NamespaceKey("ns3"): {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNamespace, core.LabelNamespaceName.Key: "ns3"},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	}
+	// The scrape time of a container takes precedence over the batch timestamp.
+	batch.MetricSets[core.PodContainerKey("ns1", "pod3", "c1")].ScrapeTime = now.Add(-47 * time.Hour)
+
+	batch, err := (&ContainerAgeCalculator{}).Process(batch)
+	require.NoError(t, err)
+
+	for key, expected := range map[string]string{
+		core.PodContainerKey("ns1", "pod1", "c1"): "<5m",
+		core.PodContainerKey("ns1", "pod2", "c1"): "<5m",
+		core.PodContainerKey("ns1", "pod3", "c1"): "<1d",
+		core.PodContainerKey("ns2", "pod1", "c1"): "<1d",
+	} {
+		assert.Equal(t, expected, batch.MetricSets[key].Labels[core.LabelAgeBucket.Key], key)
+	}
+	assert.NotContains(t, batch.MetricSets[core.PodContainerKey("ns2", "pod2", "c1")].Labels, core.LabelAgeBucket.Key)
+
+	assert.Equal(t, map[string]int64{"<5m": 2, "<1h": 0, "<1d": 1, ">=1d": 0}, containersByAge(batch.MetricSets[core.NamespaceKey("ns1")]))
+	assert.Equal(t, map[string]int64{"<5m": 0, "<1h": 0, "<1d": 1, ">=1d": 0}, containersByAge(batch.MetricSets[core.NamespaceKey("ns2")]))
+	assert.Equal(t, map[string]int64{"<5m": 0, "<1h": 0, "<1d": 0, ">=1d": 0}, containersByAge(batch.MetricSets[core.NamespaceKey("ns3")]))
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// ContainerCollapser folds the pod container metric sets into their pod metric sets
+// and removes them from the batch, so that only pod level metrics are exported.
+// It has to run after all the processors reading the container metric sets.
+//
+// The metrics already set on the pod, by the source or by the pod aggregator, are
+// kept. The remaining container metrics, including the cumulative ones, are summed
+// up, except for the non additive ones like availability which are averaged. The collection start
+// time of the pod is set to the latest one of its containers, so that the restart of
+// any container starts the summed up cumulative metrics over.
+type ContainerCollapser struct {
+	averagedMetrics map[string]struct{}
+}
+
+type collapsedValue struct {
+	value core.MetricValue
+	count int
+}
+
+type collapsedPod struct {
+	metricSet *core.MetricSet
+	values    map[string]*collapsedValue
+	// Keyed by the metric name and labels.
+	labeledValues map[string]*collapsedValue
+	labeled       map[string]core.LabeledMetric
+	// Labeled metrics already set on the pod.
+	podLabeled map[string]struct{}
+}
+
+func (this *ContainerCollapser) Name() string {
+	return "container_collapser"
+}
+
+func (this *ContainerCollapser) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	pods := make(map[string]*collapsedPod)
+	for key, metricSet := range batch.MetricSets {
+		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypePodContainer {
+			continue
+		}
+		delete(batch.MetricSets, key)
+
+		podName, found := metricSet.Labels[core.LabelPodName.Key]
+		ns, found2 := metricSet.Labels[core.LabelNamespaceName.Key]
+		if !found || !found2 {
+			glog.Errorf("No namespace and/or pod info in container %s: %v", key, metricSet.Labels)
+			continue
+		}
+		podKey := core.PodKey(ns, podName)
+		pod, found := pods[podKey]
+		if !found {
+			podSet, found := batch.MetricSets[podKey]
+			if !found {
+				podSet = podMetricSet(metricSet.Labels)
+				podSet.ScrapeTime = metricSet.ScrapeTime
+				batch.MetricSets[podKey] = podSet
+			}
+			pod = newCollapsedPod(podSet)
+			pods[podKey] = pod
+		}
+		if err := this.collapse(metricSet, pod); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pod := range pods {
+		this.apply(pod)
+	}
+	return batch, nil
+}
+
+func newCollapsedPod(metricSet *core.MetricSet) *collapsedPod {
+	pod := &collapsedPod{
+		metricSet:     metricSet,
+		values:        make(map[string]*collapsedValue),
+		labeledValues: make(map[string]*collapsedValue),
+		labeled:       make(map[string]core.LabeledMetric),
+		podLabeled:    make(map[string]struct{}),
+	}
+	for _, labeledMetric := range metricSet.LabeledMetrics {
+		pod.podLabeled[labeledMetric.Name] = struct{}{}
+	}
+	return pod
+}
+
+func (this *ContainerCollapser) collapse(container *core.MetricSet, pod *collapsedPod) error {
+	if container.CollectionStartTime.After(pod.metricSet.CollectionStartTime) {
+		pod.metricSet.CollectionStartTime = container.CollectionStartTime
+	}
+	for metricName, metricValue := range container.MetricValues {
+		_, averaged := this.averagedMetrics[metricName]
+		if _, found := pod.metricSet.MetricValues[metricName]; found && !averaged {
+			continue
+		}
+		if err := collapseValue(pod.values, metricName, metricName, metricValue); err != nil {
+			return err
+		}
+	}
+	for _, labeledMetric := range container.LabeledMetrics {
+		if _, found := pod.podLabeled[labeledMetric.Name]; found {
+			continue
+		}
+		key := labeledMetric.Name + "|" + core.LabelsKey(labeledMetric.Labels)
+		if err := collapseValue(pod.labeledValues, key, labeledMetric.Name, labeledMetric.MetricValue); err != nil {
+			return err
+		}
+		pod.labeled[key] = labeledMetric
+	}
+	return nil
+}
+
+func collapseValue(values map[string]*collapsedValue, key, metricName string, metricValue core.MetricValue) error {
+	collapsed, found := values[key]
+	if !found {
+		values[key] = &collapsedValue{value: metricValue, count: 1}
+		return nil
+	}
+	if collapsed.value.ValueType != metricValue.ValueType {
+		return fmt.Errorf("ContainerCollapser: inconsistent type in %s", metricName)
+	}
+	switch metricValue.ValueType {
+	case core.ValueInt64:
+		collapsed.value.IntValue += metricValue.IntValue
+	case core.ValueFloat:
+		collapsed.value.FloatValue += metricValue.FloatValue
+	default:
+		return fmt.Errorf("ContainerCollapser: type not supported in %s", metricName)
+	}
+	collapsed.count++
+	return nil
+}
+
+func (this *ContainerCollapser) average(metricName string, collapsed *collapsedValue) core.MetricValue {
+	value := collapsed.value
+	if _, found := this.averagedMetrics[metricName]; found {
+		value.IntValue /= int64(collapsed.count)
+		value.FloatValue /= float32(collapsed.count)
+	}
+	return value
+}
+
+func (this *ContainerCollapser) apply(pod *collapsedPod) {
+	for metricName, collapsed := range pod.values {
+		pod.metricSet.MetricValues[metricName] = this.average(metricName, collapsed)
+	}
+	for key, collapsed := range pod.labeledValues {
+		labeledMetric := pod.labeled[key]
+		labeledMetric.MetricValue = this.average(labeledMetric.Name, collapsed)
+		pod.metricSet.LabeledMetrics = append(pod.metricSet.LabeledMetrics, labeledMetric)
+	}
+}
+
+func NewContainerCollapser() *ContainerCollapser {
+	averaged := make(map[string]struct{})
+	for _, metric := range core.AllMetrics {
+		if metric.NonAdditive {
+			averaged[metric.Name] = struct{}{}
+		}
+	}
+	return &ContainerCollapser{
+		averagedMetrics: averaged,
+	}
+}
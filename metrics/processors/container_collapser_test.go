@@ -0,0 +1,253 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func collapserContainer(pod, container string, startTime time.Time, usage, cpu int64, availability float32, fsUsage int64) *core.MetricSet {
+	return &core.MetricSet{
+		CollectionStartTime: startTime,
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			core.LabelPodName.Key:       pod,
+			core.LabelNamespaceName.Key: "ns1",
+			core.LabelContainerName.Key: container,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricMemoryUsage.Name: intValue(usage),
+			core.MetricCpuUsage.Name: {
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricCumulative,
+				IntValue:   cpu,
+			},
+			core.MetricContainerAvailability.Name: {
+				ValueType:  core.ValueFloat,
+				MetricType: core.MetricGauge,
+				FloatValue: availability,
+			},
+		},
+		LabeledMetrics: []core.LabeledMetric{
+			{
+				Name:        core.MetricFilesystemUsage.Name,
+				Labels:      map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+				MetricValue: intValue(fsUsage),
+			},
+		},
+	}
+}
+
+func TestContainerCollapser(t *testing.T) {
+	older := time.Now().Add(-time.Hour)
+	newer := time.Now().Add(-time.Minute)
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "c1"): collapserContainer("pod1", "c1", older, 100, 1000, 1, 10),
+			core.PodContainerKey("ns1", "pod1", "c2"): collapserContainer("pod1", "c2", newer, 200, 3000, 0.5, 20),
+			core.PodContainerKey("ns1", "pod2", "c1"): collapserContainer("pod2", "c1", older, 50, 500, 1, 5),
+			core.NodeKey("node1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypeNode,
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryUsage.Name: intValue(1000),
+				},
+			},
+		},
+	}
+
+	batch, err := NewPodAggregator().Process(batch)
+	require.NoError(t, err)
+	batch, err = NewContainerCollapser().Process(batch)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, len(batch.MetricSets))
+	for _, metricSet := range batch.MetricSets {
+		assert.NotEqual(t, core.MetricSetTypePodContainer, metricSet.Labels[core.LabelMetricSetType.Key])
+	}
+	assert.Equal(t, int64(1000), batch.MetricSets[core.NodeKey("node1")].MetricValues[core.MetricMemoryUsage.Name].IntValue)
+
+	pod1 := batch.MetricSets[core.PodKey("ns1", "pod1")]
+	require.NotNil(t, pod1)
+	// Gauges summed up by the pod aggregator are kept as they are.
+	assert.Equal(t, int64(300), pod1.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	// Cumulative metrics are summed up and start over with the latest container.
+	cpu := pod1.MetricValues[core.MetricCpuUsage.Name]
+	assert.Equal(t, core.MetricCumulative, cpu.MetricType)
+	assert.Equal(t, int64(4000), cpu.IntValue)
+	assert.True(t, newer.Equal(pod1.CollectionStartTime))
+	// Ratios are averaged.
+	assert.InDelta(t, 0.75, pod1.MetricValues[core.MetricContainerAvailability.Name].FloatValue, 1e-6)
+	require.Equal(t, 1, len(pod1.LabeledMetrics))
+	assert.Equal(t, core.MetricFilesystemUsage.Name, pod1.LabeledMetrics[0].Name)
+	assert.Equal(t, "/dev/sda1", pod1.LabeledMetrics[0].Labels[core.LabelResourceID.Key])
+	assert.Equal(t, int64(30), pod1.LabeledMetrics[0].IntValue)
+
+	pod2 := batch.MetricSets[core.PodKey("ns1", "pod2")]
+	require.NotNil(t, pod2)
+	assert.Equal(t, int64(50), pod2.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	assert.Equal(t, int64(500), pod2.MetricValues[core.MetricCpuUsage.Name].IntValue)
+	assert.InDelta(t, 1, pod2.MetricValues[core.MetricContainerAvailability.Name].FloatValue, 1e-6)
+}
+
+func TestContainerCollapserKeepsPodMetrics(t *testing.T) {
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "c1"): collapserContainer("pod1", "c1", time.Now(), 100, 1000, 1, 10),
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelPodName.Key:       "pod1",
+					core.LabelNamespaceName.Key: "ns1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsage.Name: {
+						ValueType:  core.ValueInt64,
+						MetricType: core.MetricCumulative,
+						IntValue:   5000,
+					},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:        core.MetricFilesystemUsage.Name,
+						Labels:      map[string]string{core.LabelResourceID.Key: "/dev/sda2"},
+						MetricValue: intValue(99),
+					},
+				},
+			},
+		},
+	}
+
+	batch, err := NewContainerCollapser().Process(batch)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(batch.MetricSets))
+	pod := batch.MetricSets[core.PodKey("ns1", "pod1")]
+	// Metrics reported for the pod itself take precedence over the container ones.
+	assert.Equal(t, int64(5000), pod.MetricValues[core.MetricCpuUsage.Name].IntValue)
+	assert.Equal(t, int64(100), pod.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	require.Equal(t, 1, len(pod.LabeledMetrics))
+	assert.Equal(t, int64(99), pod.LabeledMetrics[0].IntValue)
+}
+
+// labelsInOrder returns the labels set in the given order.
+func labelsInOrder(pairs ...string) map[string]string {
+	labels := make(map[string]string)
+	for i := 0; i < len(pairs); i += 2 {
+		labels[pairs[i]] = pairs[i+1]
+	}
+	return labels
+}
+
+func TestContainerCollapserLabelOrder(t *testing.T) {
+	c1 := collapserContainer("pod1", "c1", time.Now(), 100, 1000, 1, 10)
+	c1.LabeledMetrics[0].Labels = labelsInOrder(core.LabelResourceID.Key, "/dev/sda1", core.LabelPvcName.Key, "data")
+	c2 := collapserContainer("pod1", "c2", time.Now(), 100, 1000, 1, 20)
+	c2.LabeledMetrics[0].Labels = labelsInOrder(core.LabelPvcName.Key, "data", core.LabelResourceID.Key, "/dev/sda1")
+	// Joined naively, these labels would have the same key as the ones above.
+	c3 := collapserContainer("pod1", "c3", time.Now(), 100, 1000, 1, 40)
+	c3.LabeledMetrics[0].Labels = labelsInOrder(core.LabelResourceID.Key, "/dev/sda1,"+core.LabelPvcName.Key+"=data")
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "c1"): c1,
+			core.PodContainerKey("ns1", "pod1", "c2"): c2,
+			core.PodContainerKey("ns1", "pod1", "c3"): c3,
+		},
+	}
+
+	batch, err := NewPodAggregator().Process(batch)
+	require.NoError(t, err)
+	batch, err = NewContainerCollapser().Process(batch)
+	require.NoError(t, err)
+
+	pod := batch.MetricSets[core.PodKey("ns1", "pod1")]
+	require.Equal(t, 2, len(pod.LabeledMetrics))
+	usage := make(map[string]int64)
+	for _, metric := range pod.LabeledMetrics {
+		usage[core.LabelsKey(metric.Labels)] = metric.IntValue
+	}
+	assert.Equal(t, int64(30), usage[core.LabelsKey(c1.LabeledMetrics[0].Labels)])
+	assert.Equal(t, int64(40), usage[core.LabelsKey(c3.LabeledMetrics[0].Labels)])
+}
+
+func TestContainerCollapserAveragesNonAdditiveMetrics(t *testing.T) {
+	averaged := []core.Metric{
+		core.MetricContainerCpuUsageNodePct,
+		core.MetricContainerCpuUsagePerCore,
+		core.MetricContainerTimeOverLimitPct,
+		core.MetricContainerMemoryGrowthSustained,
+		core.MetricContainerInfo,
+		core.MetricContainerOverprovisioned,
+		core.MetricContainerAvailabilityPct,
+		core.MetricContainerCpuUsageP50,
+		core.MetricContainerCpuUsageP95,
+		core.MetricContainerCpuUsageP99,
+	}
+	summed := []core.Metric{
+		core.MetricCpuUsageRate,
+		core.MetricMemoryWorkingSet,
+		core.MetricContainerWasteCpuCores,
+	}
+	container := func(name string, value int64) *core.MetricSet {
+		metricSet := collapserContainer("pod1", name, time.Now(), 0, 0, 1, 0)
+		for _, metric := range append(averaged, summed...) {
+			metricValue := core.MetricValue{ValueType: metric.ValueType, MetricType: metric.Type}
+			if metric.ValueType == core.ValueFloat {
+				metricValue.FloatValue = float32(value)
+			} else {
+				metricValue.IntValue = value
+			}
+			metricSet.MetricValues[metric.Name] = metricValue
+		}
+		return metricSet
+	}
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "c1"): container("c1", 2),
+			core.PodContainerKey("ns1", "pod1", "c2"): container("c2", 4),
+		},
+	}
+
+	batch, err := NewContainerCollapser().Process(batch)
+	require.NoError(t, err)
+	pod := batch.MetricSets[core.PodKey("ns1", "pod1")]
+	require.NotNil(t, pod)
+	for _, metrics := range []struct {
+		metrics  []core.Metric
+		expected float64
+	}{
+		{averaged, 3},
+		{summed, 6},
+	} {
+		for _, metric := range metrics.metrics {
+			value := pod.MetricValues[metric.Name]
+			if metric.ValueType == core.ValueFloat {
+				assert.InDelta(t, metrics.expected, value.FloatValue, 1e-6, metric.Name)
+			} else {
+				assert.Equal(t, int64(metrics.expected), value.IntValue, metric.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"strings"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// ContainerImageNormalizer strips the tag and the digest from the container_base_image
+// label, leaving only the repository, e.g. gcr.io/project/app, to keep the number of
+// distinct label values low. The full image is moved to the container_image label,
+// unless it is dropped.
+type ContainerImageNormalizer struct {
+	keepFullImage bool
+}
+
+func (this *ContainerImageNormalizer) Name() string {
+	return "container_image_normalizer"
+}
+
+func (this *ContainerImageNormalizer) Stateless() {}
+
+func (this *ContainerImageNormalizer) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for _, metricSet := range batch.MetricSets {
+		image, found := metricSet.Labels[core.LabelContainerBaseImage.Key]
+		if !found {
+			continue
+		}
+		metricSet.Labels[core.LabelContainerBaseImage.Key] = imageRepository(image)
+		if this.keepFullImage {
+			metricSet.Labels[core.LabelContainerImage.Key] = image
+		}
+	}
+	return batch, nil
+}
+
+// imageRepository returns the image without the tag and the digest. The registry host
+// is kept, as the same name can be used in different registries.
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	// A colon before the last slash separates the port of the registry, not a tag.
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+func NewContainerImageNormalizer(keepFullImage bool) *ContainerImageNormalizer {
+	return &ContainerImageNormalizer{
+		keepFullImage: keepFullImage,
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+const imageDigest = "sha256:2f1e06f6c4fb4cd2fb8a1c6ee4a3d0e5d8a8a16a4f8d6d5a1c2b3e4f5a6b7c8d"
+
+func TestImageRepository(t *testing.T) {
+	for image, expected := range map[string]string{
+		"nginx":                                    "nginx",
+		"nginx:1.13":                               "nginx",
+		"library/nginx:latest":                     "library/nginx",
+		"gcr.io/project/app:v1.2.3":                "gcr.io/project/app",
+		"gcr.io/project/app@" + imageDigest:        "gcr.io/project/app",
+		"gcr.io/project/app:v1@" + imageDigest:     "gcr.io/project/app",
+		"registry:5000/team/app":                   "registry:5000/team/app",
+		"registry:5000/team/app:v2":                "registry:5000/team/app",
+		"registry:5000/team/app:v2@" + imageDigest: "registry:5000/team/app",
+		"": "",
+	} {
+		assert.Equal(t, expected, imageRepository(image), "image %q", image)
+	}
+}
+
+func imageBatch(image string) *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "c1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key:      core.MetricSetTypePodContainer,
+					core.LabelContainerBaseImage.Key: image,
+				},
+				MetricValues: map[string]core.MetricValue{},
+			},
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+				},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	}
+}
+
+func TestContainerImageNormalizerKeepFullImage(t *testing.T) {
+	image := "gcr.io/project/app:v1@" + imageDigest
+	batch, err := NewContainerImageNormalizer(true).Process(imageBatch(image))
+	assert.NoError(t, err)
+
+	container := batch.MetricSets[core.PodContainerKey("ns1", "pod1", "c1")]
+	assert.Equal(t, "gcr.io/project/app", container.Labels[core.LabelContainerBaseImage.Key])
+	assert.Equal(t, image, container.Labels[core.LabelContainerImage.Key])
+
+	pod := batch.MetricSets[core.PodKey("ns1", "pod1")]
+	assert.NotContains(t, pod.Labels, core.LabelContainerBaseImage.Key)
+	assert.NotContains(t, pod.Labels, core.LabelContainerImage.Key)
+}
+
+func TestContainerImageNormalizerDropFullImage(t *testing.T) {
+	batch, err := NewContainerImageNormalizer(false).Process(imageBatch("nginx:1.13"))
+	assert.NoError(t, err)
+
+	container := batch.MetricSets[core.PodContainerKey("ns1", "pod1", "c1")]
+	assert.Equal(t, "nginx", container.Labels[core.LabelContainerBaseImage.Key])
+	assert.NotContains(t, container.Labels, core.LabelContainerImage.Key)
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"github.com/golang/glog"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// ContainerNodeCpuCalculator computes the CPU usage of every container as a percentage
+// of the CPU capacity of its node, and per core of the node, a 0 to 1 utilization that
+// is comparable between nodes of different sizes. It has to run after the node
+// autoscaling enricher, which sets the node capacity. Containers of nodes without a
+// known capacity are skipped.
+type ContainerNodeCpuCalculator struct {
+}
+
+func (this *ContainerNodeCpuCalculator) Name() string {
+	return "container_node_cpu_calculator"
+}
+
+func (this *ContainerNodeCpuCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for key, metricSet := range batch.MetricSets {
+		metricSetType := metricSet.Labels[core.LabelMetricSetType.Key]
+		if metricSetType != core.MetricSetTypePodContainer && metricSetType != core.MetricSetTypeSystemContainer {
+			continue
+		}
+		usage, found := metricSet.MetricValues[core.MetricCpuUsageRate.Name]
+		if !found {
+			continue
+		}
+		nodeName := metricSet.Labels[core.LabelNodename.Key]
+		node, found := batch.MetricSets[core.NodeKey(nodeName)]
+		if nodeName == "" || !found {
+			glog.V(4).Infof("Skipping node CPU percentage of %s - no metrics of node %q", key, nodeName)
+			continue
+		}
+		capacity, found := node.MetricValues[core.MetricNodeCpuCapacity.Name]
+		if !found || capacity.FloatValue <= 0 {
+			glog.V(4).Infof("Skipping node CPU percentage of %s - unknown CPU capacity of node %q", key, nodeName)
+			continue
+		}
+		// Both the usage rate and the capacity are in millicores.
+		setFloat(metricSet, &core.MetricContainerCpuUsageNodePct, 100*float32(usage.IntValue)/capacity.FloatValue)
+		// The same utilization as a ratio, for the backends preferring ratios to percentages.
+		cores := capacity.FloatValue / 1000
+		setFloat(metricSet, &core.MetricContainerCpuUsagePerCore, float32(usage.IntValue)/1000/cores)
+	}
+	return batch, nil
+}
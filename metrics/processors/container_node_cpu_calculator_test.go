@@ -0,0 +1,110 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestContainerNodeCpuCalculator(t *testing.T) {
+	container := func(metricSetType, node string, usageRate int64) *core.MetricSet {
+		return &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: metricSetType,
+				core.LabelNodename.Key:      node,
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricCpuUsageRate.Name: intValue(usageRate),
+			},
+		}
+	}
+	node := func(capacity float32) *core.MetricSet {
+		metricSet := &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypeNode,
+			},
+			MetricValues: map[string]core.MetricValue{},
+		}
+		setFloat(metricSet, &core.MetricNodeCpuCapacity, capacity)
+		return metricSet
+	}
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NodeKey("node1"):                     node(4000),
+			core.NodeKey("node2"):                     node(0),
+			core.NodeKey("node6"):                     node(16000),
+			core.PodContainerKey("ns1", "pod1", "c1"): container(core.MetricSetTypePodContainer, "node1", 1000),
+			core.NodeContainerKey("node1", "kubelet"): container(core.MetricSetTypeSystemContainer, "node1", 200),
+			core.PodContainerKey("ns1", "pod2", "c1"): container(core.MetricSetTypePodContainer, "node2", 1000),
+			core.PodContainerKey("ns1", "pod3", "c1"): container(core.MetricSetTypePodContainer, "node3", 1000),
+			core.PodContainerKey("ns1", "pod4", "c1"): container(core.MetricSetTypePodContainer, "", 1000),
+			core.PodContainerKey("ns1", "pod6", "c1"): container(core.MetricSetTypePodContainer, "node6", 1000),
+			core.PodContainerKey("ns1", "pod5", "c1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+					core.LabelNodename.Key:      "node1",
+				},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	}
+
+	calculator := &ContainerNodeCpuCalculator{}
+	batch, err := calculator.Process(batch)
+	assert.NoError(t, err)
+
+	for key, expected := range map[string]float32{
+		core.PodContainerKey("ns1", "pod1", "c1"): 25,
+		core.NodeContainerKey("node1", "kubelet"): 5,
+		core.PodContainerKey("ns1", "pod6", "c1"): 6.25,
+	} {
+		value, found := batch.MetricSets[key].MetricValues[core.MetricContainerCpuUsageNodePct.Name]
+		if assert.True(t, found, key) {
+			assert.Equal(t, core.MetricGauge, value.MetricType)
+			assert.InDelta(t, expected, value.FloatValue, 1e-6, key)
+		}
+	}
+	// The same usage of one core is a quarter of the 4 cores of node1, but a sixteenth of
+	// the 16 cores of node6.
+	for key, expected := range map[string]float32{
+		core.PodContainerKey("ns1", "pod1", "c1"): 0.25,
+		core.NodeContainerKey("node1", "kubelet"): 0.05,
+		core.PodContainerKey("ns1", "pod6", "c1"): 0.0625,
+	} {
+		value, found := batch.MetricSets[key].MetricValues[core.MetricContainerCpuUsagePerCore.Name]
+		if assert.True(t, found, key) {
+			assert.InDelta(t, expected, value.FloatValue, 1e-6, key)
+		}
+	}
+	// Node without capacity, unknown node, no node label and no CPU usage.
+	for _, key := range []string{
+		core.PodContainerKey("ns1", "pod2", "c1"),
+		core.PodContainerKey("ns1", "pod3", "c1"),
+		core.PodContainerKey("ns1", "pod4", "c1"),
+		core.PodContainerKey("ns1", "pod5", "c1"),
+		core.NodeKey("node1"),
+	} {
+		_, found := batch.MetricSets[key].MetricValues[core.MetricContainerCpuUsageNodePct.Name]
+		assert.False(t, found, key)
+		_, found = batch.MetricSets[key].MetricValues[core.MetricContainerCpuUsagePerCore.Name]
+		assert.False(t, found, key)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"github.com/golang/glog"
+
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/heapster/metrics/core"
+)
+
+const (
+	ContainerTypeInit      = "init"
+	ContainerTypeApp       = "app"
+	ContainerTypeEphemeral = "ephemeral"
+)
+
+// ContainerTypeEnricher labels the containers with their container_type in the spec of
+// their pod, as cAdvisor does not tell the init containers apart, so that they can be
+// excluded from the usage of the app containers. The ephemeral containers are the ones
+// missing in the spec: they are added to running pods, in a field not known to this
+// version of the API.
+type ContainerTypeEnricher struct {
+	podLister v1listers.PodLister
+}
+
+func (this *ContainerTypeEnricher) Name() string {
+	return "container_type_enricher"
+}
+
+func (this *ContainerTypeEnricher) Stateless() {}
+
+func (this *ContainerTypeEnricher) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer {
+			continue
+		}
+		namespace := metricSet.Labels[core.LabelNamespaceName.Key]
+		podName := metricSet.Labels[core.LabelPodName.Key]
+		pod, err := this.podLister.Pods(namespace).Get(podName)
+		if err != nil || pod == nil {
+			glog.V(3).Infof("Failed to get pod %s from cache: %v", core.PodKey(namespace, podName), err)
+			continue
+		}
+		metricSet.Labels[core.LabelContainerType.Key] = containerType(pod, metricSet.Labels[core.LabelContainerName.Key])
+	}
+	return batch, nil
+}
+
+func containerType(pod *kube_api.Pod, containerName string) string {
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == containerName {
+			return ContainerTypeInit
+		}
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name == containerName {
+			return ContainerTypeApp
+		}
+	}
+	return ContainerTypeEphemeral
+}
+
+func NewContainerTypeEnricher(podLister v1listers.PodLister) *ContainerTypeEnricher {
+	return &ContainerTypeEnricher{
+		podLister: podLister,
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/heapster/metrics/core"
+)
+
+func typedContainer(pod, container string) *core.MetricSet {
+	return &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			core.LabelNamespaceName.Key: "ns1",
+			core.LabelPodName.Key:       pod,
+			core.LabelContainerName.Key: container,
+		},
+		MetricValues: map[string]core.MetricValue{},
+	}
+}
+
+func TestContainerTypeEnricher(t *testing.T) {
+	pod := &kube_api.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1"},
+		Spec: kube_api.PodSpec{
+			InitContainers: []kube_api.Container{{Name: "migrate"}},
+			Containers:     []kube_api.Container{{Name: "app"}, {Name: "sidecar"}},
+		},
+	}
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	require.NoError(t, store.Add(pod))
+
+	batch, err := NewContainerTypeEnricher(v1listers.NewPodLister(store)).Process(&core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "migrate"):  typedContainer("pod1", "migrate"),
+			core.PodContainerKey("ns1", "pod1", "app"):      typedContainer("pod1", "app"),
+			core.PodContainerKey("ns1", "pod1", "sidecar"):  typedContainer("pod1", "sidecar"),
+			core.PodContainerKey("ns1", "pod1", "debugger"): typedContainer("pod1", "debugger"),
+			core.PodContainerKey("ns1", "unknown", "app"):   typedContainer("unknown", "app"),
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "pod1",
+				},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	containerType := func(key string) (string, bool) {
+		value, found := batch.MetricSets[key].Labels[core.LabelContainerType.Key]
+		return value, found
+	}
+	for container, expected := range map[string]string{
+		"migrate":  ContainerTypeInit,
+		"app":      ContainerTypeApp,
+		"sidecar":  ContainerTypeApp,
+		"debugger": ContainerTypeEphemeral,
+	} {
+		value, found := containerType(core.PodContainerKey("ns1", "pod1", container))
+		assert.True(t, found, container)
+		assert.Equal(t, expected, value, container)
+	}
+	_, found := containerType(core.PodContainerKey("ns1", "unknown", "app"))
+	assert.False(t, found)
+	_, found = containerType(core.PodKey("ns1", "pod1"))
+	assert.False(t, found)
+}
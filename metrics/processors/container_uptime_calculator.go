@@ -0,0 +1,115 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+type restartSample struct {
+	timestamp    time.Time
+	restartCount int64
+}
+
+// ContainerUptimeCalculator computes the uptime of every container based on its
+// collection start time. If an availability window is configured, it also keeps
+// track of the restart counts seen within the window and emits the share of the
+// window during which the container was running.
+type ContainerUptimeCalculator struct {
+	availabilityWindow time.Duration
+	restarts           map[string][]restartSample
+}
+
+func (this *ContainerUptimeCalculator) Name() string {
+	return "container_uptime_calculator"
+}
+
+func (this *ContainerUptimeCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	seen := make(map[string]struct{})
+	for key, metricSet := range batch.MetricSets {
+		metricSetType := metricSet.Labels[core.LabelMetricSetType.Key]
+		if metricSetType != core.MetricSetTypePodContainer && metricSetType != core.MetricSetTypeSystemContainer {
+			continue
+		}
+		if metricSet.CollectionStartTime.IsZero() {
+			glog.V(4).Infof("Skipping uptime calculation for %s - unknown creation time", key)
+			continue
+		}
+
+		now := metricSet.ScrapeTime
+		if now.IsZero() {
+			now = batch.Timestamp
+		}
+		uptime := now.Sub(metricSet.CollectionStartTime)
+		if uptime < 0 {
+			uptime = 0
+		}
+		metricSet.MetricValues[core.MetricContainerUptimeSeconds.Name] = intValue(int64(uptime / time.Second))
+
+		if this.availabilityWindow <= 0 {
+			continue
+		}
+		restartCount, found := metricSet.MetricValues[core.MetricRestartCount.Name]
+		if !found {
+			continue
+		}
+		seen[key] = struct{}{}
+		samples := this.addSample(key, restartSample{timestamp: now, restartCount: restartCount.IntValue})
+
+		oldest := samples[0]
+		observed := now.Sub(oldest.timestamp)
+		if observed <= 0 {
+			continue
+		}
+		availability := float32(1)
+		if restartCount.IntValue > oldest.restartCount && uptime < observed {
+			// The container restarted within the window, so only the time since
+			// the last restart is treated as available.
+			availability = float32(uptime) / float32(observed)
+		}
+		setFloat(metricSet, &core.MetricContainerAvailability, availability)
+	}
+
+	for key := range this.restarts {
+		if _, found := seen[key]; !found {
+			delete(this.restarts, key)
+		}
+	}
+	return batch, nil
+}
+
+// addSample records the given sample and drops the ones that fell out of the window.
+func (this *ContainerUptimeCalculator) addSample(key string, sample restartSample) []restartSample {
+	samples := append(this.restarts[key], sample)
+	cutoff := sample.timestamp.Add(-this.availabilityWindow)
+	first := 0
+	for first < len(samples)-1 && samples[first].timestamp.Before(cutoff) {
+		first++
+	}
+	samples = samples[first:]
+	this.restarts[key] = samples
+	return samples
+}
+
+func NewContainerUptimeCalculator(availabilityWindow time.Duration) *ContainerUptimeCalculator {
+	return &ContainerUptimeCalculator{
+		availabilityWindow: availabilityWindow,
+		restarts:           make(map[string][]restartSample),
+	}
+}
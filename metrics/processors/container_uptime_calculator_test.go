@@ -0,0 +1,112 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func uptimeBatch(now, started time.Time, restarts int64) *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: now,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "c1"): {
+				CollectionStartTime: started,
+				ScrapeTime:          now,
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricRestartCount.Name: intValue(restarts),
+				},
+			},
+			core.PodContainerKey("ns1", "pod1", "c2"): {
+				ScrapeTime: now,
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+				},
+				MetricValues: map[string]core.MetricValue{},
+			},
+			core.PodKey("ns1", "pod1"): {
+				CollectionStartTime: started,
+				ScrapeTime:          now,
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+				},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	}
+}
+
+func TestContainerUptime(t *testing.T) {
+	now := time.Now()
+	processor := NewContainerUptimeCalculator(0)
+
+	batch, err := processor.Process(uptimeBatch(now, now.Add(-90*time.Second), 0))
+	assert.NoError(t, err)
+
+	container := batch.MetricSets[core.PodContainerKey("ns1", "pod1", "c1")]
+	uptime, found := container.MetricValues[core.MetricContainerUptimeSeconds.Name]
+	assert.True(t, found)
+	assert.Equal(t, int64(90), uptime.IntValue)
+	_, found = container.MetricValues[core.MetricContainerAvailability.Name]
+	assert.False(t, found)
+
+	// Containers with unknown creation time are skipped.
+	_, found = batch.MetricSets[core.PodContainerKey("ns1", "pod1", "c2")].MetricValues[core.MetricContainerUptimeSeconds.Name]
+	assert.False(t, found)
+
+	// Only containers get the uptime.
+	_, found = batch.MetricSets[core.PodKey("ns1", "pod1")].MetricValues[core.MetricContainerUptimeSeconds.Name]
+	assert.False(t, found)
+}
+
+func TestContainerAvailability(t *testing.T) {
+	start := time.Now()
+	created := start.Add(-time.Hour)
+	processor := NewContainerUptimeCalculator(10 * time.Minute)
+	key := core.PodContainerKey("ns1", "pod1", "c1")
+
+	// No history yet.
+	batch, err := processor.Process(uptimeBatch(start, created, 1))
+	assert.NoError(t, err)
+	_, found := batch.MetricSets[key].MetricValues[core.MetricContainerAvailability.Name]
+	assert.False(t, found)
+
+	// No restarts within the window.
+	batch, err = processor.Process(uptimeBatch(start.Add(2*time.Minute), created, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, float32(1), batch.MetricSets[key].MetricValues[core.MetricContainerAvailability.Name].FloatValue)
+
+	// Restarted a minute ago, observed for four minutes.
+	now := start.Add(4 * time.Minute)
+	batch, err = processor.Process(uptimeBatch(now, now.Add(-time.Minute), 2))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(60), batch.MetricSets[key].MetricValues[core.MetricContainerUptimeSeconds.Name].IntValue)
+	assert.InDelta(t, 0.25, batch.MetricSets[key].MetricValues[core.MetricContainerAvailability.Name].FloatValue, 0.001)
+
+	// The samples from before the restart fall out of the window.
+	_, err = processor.Process(uptimeBatch(start.Add(15*time.Minute), start.Add(3*time.Minute), 2))
+	assert.NoError(t, err)
+	batch, err = processor.Process(uptimeBatch(start.Add(20*time.Minute), start.Add(3*time.Minute), 2))
+	assert.NoError(t, err)
+	assert.Equal(t, float32(1), batch.MetricSets[key].MetricValues[core.MetricContainerAvailability.Name].FloatValue)
+}
@@ -0,0 +1,67 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"strings"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// ContainerVersionEnricher labels the containers with the app_version taken from their
+// image and sets container/info to 1, so that the usage of the containers can be joined
+// with their versions, e.g. to spot the pods of a workload still running an old version.
+// It has to run after the pod based enricher, which sets the images, and before the image
+// normalizer, which strips the tags.
+type ContainerVersionEnricher struct {
+}
+
+func (this *ContainerVersionEnricher) Name() string {
+	return "container_version_enricher"
+}
+
+func (this *ContainerVersionEnricher) Stateless() {}
+
+func (this *ContainerVersionEnricher) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer {
+			continue
+		}
+		image := metricSet.Labels[core.LabelContainerBaseImage.Key]
+		if image == "" {
+			continue
+		}
+		metricSet.Labels[core.LabelAppVersion.Key] = imageVersion(image)
+		metricSet.MetricValues[core.MetricContainerInfo.Name] = intValue(1)
+	}
+	return batch, nil
+}
+
+// imageVersion returns the tag of the image, or its digest if it is pinned by digest only.
+// An image without either runs the latest tag.
+func imageVersion(image string) string {
+	digest := ""
+	if i := strings.Index(image, "@"); i >= 0 {
+		image, digest = image[:i], image[i+1:]
+	}
+	// A colon before the last slash separates the port of the registry, not a tag.
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	if digest != "" {
+		return digest
+	}
+	return "latest"
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestImageVersion(t *testing.T) {
+	for image, expected := range map[string]string{
+		"nginx":                                 "latest",
+		"nginx:1.19":                            "1.19",
+		"library/nginx:1.19-alpine":             "1.19-alpine",
+		"gcr.io/project/app:v1.2.3":             "v1.2.3",
+		"localhost:5000/app":                    "latest",
+		"localhost:5000/team/app:2017.10":       "2017.10",
+		"gcr.io/project/app@sha256:0123abcd":    "sha256:0123abcd",
+		"gcr.io/project/app:v2@sha256:0123abcd": "v2",
+	} {
+		assert.Equal(t, expected, imageVersion(image), image)
+	}
+}
+
+func TestContainerVersionEnricher(t *testing.T) {
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "app"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key:      core.MetricSetTypePodContainer,
+					core.LabelContainerBaseImage.Key: "gcr.io/project/app:v1.2",
+				},
+				MetricValues: map[string]core.MetricValue{},
+			},
+			// The image is not known.
+			core.PodContainerKey("ns1", "pod1", "sidecar"): {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePodContainer},
+				MetricValues: map[string]core.MetricValue{},
+			},
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key:      core.MetricSetTypePod,
+					core.LabelContainerBaseImage.Key: "gcr.io/project/app:v1.2",
+				},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	}
+	batch, err := (&ContainerVersionEnricher{}).Process(batch)
+	require.NoError(t, err)
+
+	container := batch.MetricSets[core.PodContainerKey("ns1", "pod1", "app")]
+	assert.Equal(t, "v1.2", container.Labels[core.LabelAppVersion.Key])
+	assert.Equal(t, intValue(1), container.MetricValues[core.MetricContainerInfo.Name])
+
+	for _, key := range []string{core.PodContainerKey("ns1", "pod1", "sidecar"), core.PodKey("ns1", "pod1")} {
+		_, found := batch.MetricSets[key].Labels[core.LabelAppVersion.Key]
+		assert.False(t, found, key)
+		_, found = batch.MetricSets[key].MetricValues[core.MetricContainerInfo.Name]
+		assert.False(t, found, key)
+	}
+}
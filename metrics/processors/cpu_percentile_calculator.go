@@ -0,0 +1,188 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+const (
+	// Maximum relative error of the estimated percentiles.
+	percentileRelativeAccuracy = 0.01
+	// Maximum number of samples kept per container. The oldest ones are dropped first,
+	// which only shortens the window below a resolution of about a second.
+	maxPercentileSamples = 1024
+	// Bucket of the samples of 0, which do not fit the logarithmic buckets.
+	zeroBucket = math.MinInt32
+)
+
+var (
+	percentileGamma    = (1 + percentileRelativeAccuracy) / (1 - percentileRelativeAccuracy)
+	percentileLogGamma = math.Log(percentileGamma)
+)
+
+// Percentiles emitted, as a fraction of the samples.
+var cpuPercentiles = []struct {
+	quantile float64
+	metric   *core.Metric
+}{
+	{0.5, &core.MetricContainerCpuUsageP50},
+	{0.95, &core.MetricContainerCpuUsageP95},
+	{0.99, &core.MetricContainerCpuUsageP99},
+}
+
+type sketchSample struct {
+	timestamp time.Time
+	bucket    int
+}
+
+// quantileSketch estimates the quantiles of the samples within a window with a relative
+// error of at most percentileRelativeAccuracy. The samples are counted in logarithmic
+// buckets, bucket i holding the values in (gamma^(i-1), gamma^i], so the memory used by
+// the counts grows with the range of the values rather than with the number of samples.
+// Only the bucket of every sample is kept to remove it once it leaves the window.
+type quantileSketch struct {
+	samples []sketchSample
+	counts  map[int]int
+}
+
+func newQuantileSketch() *quantileSketch {
+	return &quantileSketch{counts: make(map[int]int)}
+}
+
+func (this *quantileSketch) add(timestamp time.Time, value int64) {
+	if len(this.samples) >= maxPercentileSamples {
+		this.drop(1)
+	}
+	bucket := zeroBucket
+	if value > 0 {
+		bucket = int(math.Ceil(math.Log(float64(value)) / percentileLogGamma))
+	}
+	this.samples = append(this.samples, sketchSample{timestamp: timestamp, bucket: bucket})
+	this.counts[bucket]++
+}
+
+// expire drops the samples taken at or before the given time.
+func (this *quantileSketch) expire(before time.Time) {
+	i := 0
+	for i < len(this.samples) && !this.samples[i].timestamp.After(before) {
+		i++
+	}
+	this.drop(i)
+}
+
+// drop drops the n oldest samples.
+func (this *quantileSketch) drop(n int) {
+	for _, sample := range this.samples[:n] {
+		this.counts[sample.bucket]--
+		if this.counts[sample.bucket] == 0 {
+			delete(this.counts, sample.bucket)
+		}
+	}
+	this.samples = this.samples[n:]
+}
+
+// quantile returns the estimated value below which the given fraction of the samples lie.
+func (this *quantileSketch) quantile(q float64) int64 {
+	buckets := make([]int, 0, len(this.counts))
+	for bucket := range this.counts {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+	rank := int(q * float64(len(this.samples)-1))
+	seen := 0
+	for _, bucket := range buckets {
+		seen += this.counts[bucket]
+		if seen > rank {
+			if bucket == zeroBucket {
+				return 0
+			}
+			// The value with the same relative error to both bounds of the bucket.
+			return int64(math.Floor(2*math.Pow(percentileGamma, float64(bucket))/(percentileGamma+1) + 0.5))
+		}
+	}
+	return 0
+}
+
+type cpuPercentileWindow struct {
+	collectionStartTime time.Time
+	sketch              *quantileSketch
+}
+
+// CpuPercentileCalculator emits the 50th, 95th and 99th percentiles of the CPU usage
+// rate of every pod container over the window ending with the current batch. The window
+// starts over when the container restarts. It has to run after the rate calculator,
+// which provides the CPU usage rate.
+type CpuPercentileCalculator struct {
+	window     time.Duration
+	containers map[string]*cpuPercentileWindow
+}
+
+func (this *CpuPercentileCalculator) Name() string {
+	return "cpu_percentile_calculator"
+}
+
+func (this *CpuPercentileCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for key, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer {
+			continue
+		}
+		usage, found := metricSet.MetricValues[core.MetricCpuUsageRate.Name]
+		if !found {
+			continue
+		}
+		now := metricSet.ScrapeTime
+		if now.IsZero() {
+			now = batch.Timestamp
+		}
+		window, found := this.containers[key]
+		if !found || !window.collectionStartTime.Equal(metricSet.CollectionStartTime) {
+			window = &cpuPercentileWindow{
+				collectionStartTime: metricSet.CollectionStartTime,
+				sketch:              newQuantileSketch(),
+			}
+			this.containers[key] = window
+		}
+		// A sample not newer than the latest one, e.g. carried forward from an earlier
+		// cycle, is not counted again.
+		if n := len(window.sketch.samples); n == 0 || now.After(window.sketch.samples[n-1].timestamp) {
+			window.sketch.add(now, usage.IntValue)
+			window.sketch.expire(now.Add(-this.window))
+		}
+		for _, percentile := range cpuPercentiles {
+			metricSet.MetricValues[percentile.metric.Name] = intValue(window.sketch.quantile(percentile.quantile))
+		}
+	}
+
+	// Forget the containers without samples within the window, e.g. deleted ones.
+	for key, window := range this.containers {
+		window.sketch.expire(batch.Timestamp.Add(-this.window))
+		if len(window.sketch.samples) == 0 {
+			delete(this.containers, key)
+		}
+	}
+	return batch, nil
+}
+
+func NewCpuPercentileCalculator(window time.Duration) *CpuPercentileCalculator {
+	return &CpuPercentileCalculator{
+		window:     window,
+		containers: make(map[string]*cpuPercentileWindow),
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func percentileBatch(timestamp, started time.Time, usage int64) *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "app"): {
+				ScrapeTime:          timestamp,
+				CollectionStartTime: started,
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsageRate.Name: intValue(usage),
+				},
+			},
+		},
+	}
+}
+
+// exactQuantile returns the sample at the same rank as the estimated quantile.
+func exactQuantile(sorted []int64, q float64) int64 {
+	return sorted[int(q*float64(len(sorted)-1))]
+}
+
+func TestQuantileSketchAccuracy(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	for name, sample := range map[string]func() int64{
+		"uniform":   func() int64 { return 1 + random.Int63n(1000) },
+		"lognormal": func() int64 { return int64(math.Exp(5+random.NormFloat64())) + 1 },
+	} {
+		sketch := newQuantileSketch()
+		start := time.Now()
+		values := make([]int64, 0, maxPercentileSamples)
+		for i := 0; i < maxPercentileSamples; i++ {
+			value := sample()
+			values = append(values, value)
+			sketch.add(start.Add(time.Duration(i)*time.Second), value)
+		}
+		sort.Sort(int64Slice(values))
+
+		for _, q := range []float64{0.5, 0.95, 0.99} {
+			exact := float64(exactQuantile(values, q))
+			estimate := float64(sketch.quantile(q))
+			// Within the relative accuracy, plus the rounding to an integer.
+			assert.InDelta(t, exact, estimate, exact*percentileRelativeAccuracy+0.5, "%s quantile %v", name, q)
+		}
+	}
+}
+
+type int64Slice []int64
+
+func (s int64Slice) Len() int           { return len(s) }
+func (s int64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s int64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func TestQuantileSketchBounded(t *testing.T) {
+	sketch := newQuantileSketch()
+	start := time.Now()
+	for i := 0; i < 3*maxPercentileSamples; i++ {
+		// Only the last samples, all 1000, are kept.
+		value := int64(1)
+		if i >= 2*maxPercentileSamples {
+			value = 1000
+		}
+		sketch.add(start.Add(time.Duration(i)*time.Second), value)
+	}
+	assert.Len(t, sketch.samples, maxPercentileSamples)
+	assert.InDelta(t, 1000, sketch.quantile(0.5), 10)
+
+	sketch.add(start, 0)
+	assert.Equal(t, int64(0), sketch.quantile(0))
+}
+
+func TestCpuPercentileCalculator(t *testing.T) {
+	calculator := NewCpuPercentileCalculator(100 * time.Minute)
+	key := core.PodContainerKey("ns1", "pod1", "app")
+	start := time.Now().Truncate(time.Minute)
+	started := start.Add(-time.Hour)
+
+	// Usage of 1 to 100 millicores, one sample a minute.
+	var batch *core.DataBatch
+	for i := 0; i < 100; i++ {
+		var err error
+		batch, err = calculator.Process(percentileBatch(start.Add(time.Duration(i)*time.Minute), started, int64(i+1)))
+		require.NoError(t, err)
+	}
+	values := batch.MetricSets[key].MetricValues
+	assert.InDelta(t, 50, values[core.MetricContainerCpuUsageP50.Name].IntValue, 1)
+	assert.InDelta(t, 95, values[core.MetricContainerCpuUsageP95.Name].IntValue, 1)
+	assert.InDelta(t, 99, values[core.MetricContainerCpuUsageP99.Name].IntValue, 1)
+	assert.Equal(t, core.MetricGauge, values[core.MetricContainerCpuUsageP99.Name].MetricType)
+
+	// The first half of the samples left the window.
+	batch, err := calculator.Process(percentileBatch(start.Add(150*time.Minute), started, 100))
+	require.NoError(t, err)
+	assert.InDelta(t, 76, batch.MetricSets[key].MetricValues[core.MetricContainerCpuUsageP50.Name].IntValue, 1)
+
+	// The container restarted, the window starts over.
+	batch, err = calculator.Process(percentileBatch(start.Add(151*time.Minute), start.Add(151*time.Minute), 5))
+	require.NoError(t, err)
+	values = batch.MetricSets[key].MetricValues
+	assert.Equal(t, int64(5), values[core.MetricContainerCpuUsageP50.Name].IntValue)
+	assert.Equal(t, int64(5), values[core.MetricContainerCpuUsageP99.Name].IntValue)
+	assert.Len(t, calculator.containers[key].sketch.samples, 1)
+
+	// Deleted containers are forgotten once their samples left the window.
+	_, err = calculator.Process(&core.DataBatch{Timestamp: start.Add(300 * time.Minute), MetricSets: map[string]*core.MetricSet{}})
+	require.NoError(t, err)
+	assert.Empty(t, calculator.containers)
+}
+
+func TestCpuPercentileCalculatorRepeatedSample(t *testing.T) {
+	calculator := NewCpuPercentileCalculator(100 * time.Minute)
+	key := core.PodContainerKey("ns1", "pod1", "app")
+	start := time.Now().Truncate(time.Minute)
+	started := start.Add(-time.Hour)
+
+	_, err := calculator.Process(percentileBatch(start, started, 1))
+	require.NoError(t, err)
+	// The same sample, carried forward to the next cycle, is counted once.
+	for i := 0; i < 2; i++ {
+		batch, err := calculator.Process(percentileBatch(start.Add(time.Minute), started, 100))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), batch.MetricSets[key].MetricValues[core.MetricContainerCpuUsageP50.Name].IntValue)
+	}
+	assert.Len(t, calculator.containers[key].sketch.samples, 2)
+}
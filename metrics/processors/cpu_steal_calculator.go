@@ -0,0 +1,114 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+type cpuWaitSample struct {
+	collectionStartTime time.Time
+	scrapeTime          time.Time
+	usage               int64
+	waitTime            int64
+	// Ratio computed for the sample, if any.
+	ratio    float32
+	hasRatio bool
+}
+
+// isRepeatOf returns whether the sample is the previous one again, e.g. of a node scraped
+// less often than in every cycle.
+func (this cpuWaitSample) isRepeatOf(previous cpuWaitSample) bool {
+	return !this.scrapeTime.IsZero() && this.scrapeTime.Equal(previous.scrapeTime) &&
+		this.collectionStartTime.Equal(previous.collectionStartTime)
+}
+
+// CpuStealCalculator derives the share of time that a container spent waiting for a
+// CPU while runnable, based on the increase of its cumulative CPU usage and run queue
+// wait time since the previous batch. A high ratio points to CPU contention with other
+// workloads on the node.
+type CpuStealCalculator struct {
+	previous map[string]cpuWaitSample
+}
+
+func (this *CpuStealCalculator) Name() string {
+	return "cpu_steal_calculator"
+}
+
+func (this *CpuStealCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	current := make(map[string]cpuWaitSample)
+	for key, metricSet := range batch.MetricSets {
+		metricSetType := metricSet.Labels[core.LabelMetricSetType.Key]
+		if metricSetType != core.MetricSetTypePodContainer && metricSetType != core.MetricSetTypeSystemContainer {
+			continue
+		}
+		waitTime, found := metricSet.MetricValues[core.MetricContainerCpuWaitTime.Name]
+		if !found {
+			continue
+		}
+		usage, found := metricSet.MetricValues[core.MetricCpuUsage.Name]
+		if !found {
+			continue
+		}
+		sample := cpuWaitSample{
+			collectionStartTime: metricSet.CollectionStartTime,
+			scrapeTime:          metricSet.ScrapeTime,
+			usage:               usage.IntValue,
+			waitTime:            waitTime.IntValue,
+		}
+		current[key] = sample
+
+		previous, found := this.previous[key]
+		if !found {
+			continue
+		}
+		if sample.isRepeatOf(previous) {
+			current[key] = previous
+			if previous.hasRatio {
+				setFloat(metricSet, &core.MetricContainerCpuStealRatio, previous.ratio)
+			}
+			continue
+		}
+		if !sample.collectionStartTime.Equal(previous.collectionStartTime) {
+			glog.V(4).Infof("Skipping CPU steal ratio for %s - the container was restarted", key)
+			continue
+		}
+		usageDelta := sample.usage - previous.usage
+		waitDelta := sample.waitTime - previous.waitTime
+		if usageDelta < 0 || waitDelta < 0 {
+			glog.V(4).Infof("Skipping CPU steal ratio for %s - counters decreased", key)
+			continue
+		}
+		ratio := float32(0)
+		if usageDelta+waitDelta > 0 {
+			ratio = float32(waitDelta) / float32(usageDelta+waitDelta)
+		}
+		setFloat(metricSet, &core.MetricContainerCpuStealRatio, ratio)
+		sample.ratio, sample.hasRatio = ratio, true
+		current[key] = sample
+	}
+	this.previous = current
+	return batch, nil
+}
+
+func NewCpuStealCalculator() *CpuStealCalculator {
+	return &CpuStealCalculator{
+		previous: make(map[string]cpuWaitSample),
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func cpuWaitMetricSet(metricSetType string, started time.Time, usage, waitTime int64) *core.MetricSet {
+	return &core.MetricSet{
+		CollectionStartTime: started,
+		Labels:              map[string]string{core.LabelMetricSetType.Key: metricSetType},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuUsage.Name: {
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricCumulative,
+				IntValue:   usage,
+			},
+			core.MetricContainerCpuWaitTime.Name: {
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricCumulative,
+				IntValue:   waitTime,
+			},
+		},
+	}
+}
+
+func TestCpuStealCalculator(t *testing.T) {
+	started := time.Now().Add(-time.Hour)
+	restarted := time.Now().Add(-time.Minute)
+	calculator := NewCpuStealCalculator()
+
+	first := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			"noisy":     cpuWaitMetricSet(core.MetricSetTypePodContainer, started, 1000, 100),
+			"quiet":     cpuWaitMetricSet(core.MetricSetTypeSystemContainer, started, 1000, 100),
+			"idle":      cpuWaitMetricSet(core.MetricSetTypePodContainer, started, 1000, 100),
+			"restarted": cpuWaitMetricSet(core.MetricSetTypePodContainer, started, 1000, 100),
+			"node":      cpuWaitMetricSet(core.MetricSetTypeNode, started, 1000, 100),
+		},
+	}
+	first, err := calculator.Process(first)
+	assert.NoError(t, err)
+	for key, metricSet := range first.MetricSets {
+		_, found := metricSet.MetricValues[core.MetricContainerCpuStealRatio.Name]
+		assert.False(t, found, key)
+	}
+
+	noWait := cpuWaitMetricSet(core.MetricSetTypePodContainer, started, 1500, 0)
+	delete(noWait.MetricValues, core.MetricContainerCpuWaitTime.Name)
+	second := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			"noisy":     cpuWaitMetricSet(core.MetricSetTypePodContainer, started, 1600, 700),
+			"quiet":     cpuWaitMetricSet(core.MetricSetTypeSystemContainer, started, 1900, 200),
+			"idle":      cpuWaitMetricSet(core.MetricSetTypePodContainer, started, 1000, 100),
+			"restarted": cpuWaitMetricSet(core.MetricSetTypePodContainer, restarted, 50, 10),
+			"node":      cpuWaitMetricSet(core.MetricSetTypeNode, started, 2000, 200),
+			"new":       cpuWaitMetricSet(core.MetricSetTypePodContainer, started, 1000, 100),
+			"no_wait":   noWait,
+		},
+	}
+	second, err = calculator.Process(second)
+	assert.NoError(t, err)
+
+	expected := map[string]float32{
+		"noisy": 0.5,
+		"quiet": 0.1,
+		"idle":  0,
+	}
+	for key, metricSet := range second.MetricSets {
+		value, found := metricSet.MetricValues[core.MetricContainerCpuStealRatio.Name]
+		expectedRatio, expectedFound := expected[key]
+		if assert.Equal(t, expectedFound, found, key) && found {
+			assert.InDelta(t, expectedRatio, value.FloatValue, 1e-6, key)
+			assert.Equal(t, core.MetricGauge, value.MetricType)
+		}
+	}
+}
+
+func TestCpuStealCalculatorCarriedSample(t *testing.T) {
+	started := time.Now().Add(-time.Hour)
+	scraped := time.Now().Add(-time.Minute)
+	calculator := NewCpuStealCalculator()
+	sample := func(scrapeTime time.Time, usage, waitTime int64) *core.DataBatch {
+		metricSet := cpuWaitMetricSet(core.MetricSetTypePodContainer, started, usage, waitTime)
+		metricSet.ScrapeTime = scrapeTime
+		return &core.DataBatch{
+			Timestamp:  time.Now(),
+			MetricSets: map[string]*core.MetricSet{"container": metricSet},
+		}
+	}
+
+	_, err := calculator.Process(sample(scraped, 1000, 100))
+	assert.NoError(t, err)
+	// The sample of a node skipped in a cycle is carried forward by the source manager.
+	for i := 0; i < 3; i++ {
+		batch, err := calculator.Process(sample(scraped.Add(30*time.Second), 1600, 700))
+		assert.NoError(t, err)
+		value, found := batch.MetricSets["container"].MetricValues[core.MetricContainerCpuStealRatio.Name]
+		if assert.True(t, found, "cycle %d", i) {
+			assert.InDelta(t, 0.5, value.FloatValue, 1e-6, "cycle %d", i)
+		}
+	}
+	batch, err := calculator.Process(sample(scraped.Add(60*time.Second), 2500, 800))
+	assert.NoError(t, err)
+	value := batch.MetricSets["container"].MetricValues[core.MetricContainerCpuStealRatio.Name]
+	assert.InDelta(t, 0.1, value.FloatValue, 1e-6)
+}
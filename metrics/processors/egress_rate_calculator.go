@@ -0,0 +1,54 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// EgressRateCalculator reports the rate of bytes transmitted over the network of every
+// pod and sums them up per namespace, to estimate the egress charges of the namespaces.
+// The network stats of the kubelet do not tell the traffic leaving the cluster from the
+// traffic between pods, so the rate covers all the destinations and is an upper bound of
+// the charged egress. It has to run after the pod network rate calculator, which provides
+// the transmit rate of the pod network, and after the namespace aggregator.
+type EgressRateCalculator struct {
+}
+
+func (this *EgressRateCalculator) Name() string {
+	return "egress_rate_calculator"
+}
+
+func (this *EgressRateCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	namespaces := make(map[string]float32)
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePod {
+			continue
+		}
+		txRate, found := metricSet.MetricValues[core.MetricPodNetworkTxRate.Name]
+		if !found {
+			continue
+		}
+		setFloat(metricSet, &core.MetricPodNetworkTxTotalBytesRate, txRate.FloatValue)
+		namespaces[metricSet.Labels[core.LabelNamespaceName.Key]] += txRate.FloatValue
+	}
+
+	for namespace, txRate := range namespaces {
+		if metricSet, found := batch.MetricSets[core.NamespaceKey(namespace)]; found {
+			setFloat(metricSet, &core.MetricPodNetworkTxTotalBytesRate, txRate)
+		}
+	}
+	return batch, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func egressPod(namespace, pod string, started, scrapeTime time.Time, tx int64) *core.MetricSet {
+	return &core.MetricSet{
+		CollectionStartTime: started,
+		ScrapeTime:          scrapeTime,
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePod,
+			core.LabelNamespaceName.Key: namespace,
+			core.LabelPodName.Key:       pod,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricNetworkTx.Name: {
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricCumulative,
+				IntValue:   tx,
+			},
+		},
+	}
+}
+
+func egressBatch(started, timestamp time.Time, tx map[string]int64) *core.DataBatch {
+	batch := &core.DataBatch{
+		Timestamp:  timestamp,
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	for key, value := range tx {
+		// Keyed by namespace/pod.
+		namespace, pod := key[:3], key[4:]
+		batch.MetricSets[core.PodKey(namespace, pod)] = egressPod(namespace, pod, started, timestamp, value)
+	}
+	return batch
+}
+
+func TestEgressRateCalculator(t *testing.T) {
+	now := time.Now()
+	started := now.Add(-time.Hour)
+	rateCalculator := NewRateCalculator(core.RateMetricsMapping, 0)
+	_, err := rateCalculator.Process(egressBatch(started, now.Add(-10*time.Second), map[string]int64{
+		"ns1/web-1": 1000,
+		"ns1/web-2": 5000,
+		"ns2/db":    0,
+	}))
+	require.NoError(t, err)
+	batch, err := rateCalculator.Process(egressBatch(started, now, map[string]int64{
+		"ns1/web-1": 3000,
+		"ns1/web-2": 6000,
+		"ns2/db":    10000,
+		// New pod, without a rate yet.
+		"ns3/job": 100,
+	}))
+	require.NoError(t, err)
+	for _, processor := range []core.DataProcessor{
+		NewPodNetworkRateCalculator(),
+		&NamespaceAggregator{},
+		&EgressRateCalculator{},
+	} {
+		batch, err = processor.Process(batch)
+		require.NoError(t, err)
+	}
+
+	egress := func(key string) (float32, bool) {
+		value, found := batch.MetricSets[key].MetricValues[core.MetricPodNetworkTxTotalBytesRate.Name]
+		return value.FloatValue, found
+	}
+	for key, expected := range map[string]float32{
+		core.PodKey("ns1", "web-1"): 200,
+		core.PodKey("ns1", "web-2"): 100,
+		core.PodKey("ns2", "db"):    1000,
+		core.NamespaceKey("ns1"):    300,
+		core.NamespaceKey("ns2"):    1000,
+	} {
+		value, found := egress(key)
+		assert.True(t, found, key)
+		assert.InDelta(t, expected, value, 1e-3, key)
+	}
+	for _, key := range []string{core.PodKey("ns3", "job"), core.NamespaceKey("ns3")} {
+		_, found := egress(key)
+		assert.False(t, found, key)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// FairShareCalculator emits how much each namespace exceeds its fair share of the cluster.
+// The allocatable CPU and memory of all the nodes are split between the namespaces of the
+// batch in proportion to their weights, 1 unless configured otherwise. The overage is the
+// share of the cluster used by the dominant resource of the namespace, the one of which it
+// uses the largest part, minus the share of the namespace, or 0 if it is within its share.
+type FairShareCalculator struct {
+	Weights map[string]float64
+}
+
+func (this *FairShareCalculator) Name() string {
+	return "fair_share_calculator"
+}
+
+func (this *FairShareCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	var cpuCapacity, memoryCapacity float64
+	namespaces := []*core.MetricSet{}
+	for _, metricSet := range batch.MetricSets {
+		switch metricSet.Labels[core.LabelMetricSetType.Key] {
+		case core.MetricSetTypeNode:
+			cpuCapacity += float64(metricSet.MetricValues[core.MetricNodeCpuAllocatable.Name].FloatValue)
+			memoryCapacity += float64(metricSet.MetricValues[core.MetricNodeMemoryAllocatable.Name].FloatValue)
+		case core.MetricSetTypeNamespace:
+			namespaces = append(namespaces, metricSet)
+		}
+	}
+	if cpuCapacity <= 0 && memoryCapacity <= 0 {
+		return batch, nil
+	}
+
+	var totalWeight float64
+	for _, namespace := range namespaces {
+		totalWeight += this.weight(namespace.Labels[core.LabelNamespaceName.Key])
+	}
+	for _, namespace := range namespaces {
+		var dominantShare float64
+		if cpuCapacity > 0 {
+			dominantShare = float64(getInt(namespace, &core.MetricCpuUsageRate)) / cpuCapacity
+		}
+		if memoryCapacity > 0 {
+			if memoryShare := float64(getInt(namespace, &core.MetricMemoryUsage)) / memoryCapacity; memoryShare > dominantShare {
+				dominantShare = memoryShare
+			}
+		}
+		fairShare := this.weight(namespace.Labels[core.LabelNamespaceName.Key]) / totalWeight
+		overage := dominantShare - fairShare
+		if overage < 0 {
+			overage = 0
+		}
+		setFloat(namespace, &core.MetricNamespaceFairShareOverage, float32(overage))
+	}
+	return batch, nil
+}
+
+func (this *FairShareCalculator) weight(namespace string) float64 {
+	if weight, found := this.Weights[namespace]; found {
+		return weight
+	}
+	return 1
+}
+
+// ParseFairShareWeights parses namespace weights given as <namespace>=<weight>.
+func ParseFairShareWeights(specs []string) (map[string]float64, error) {
+	weights := make(map[string]float64, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid fair share weight %q - expected <namespace>=<weight>", spec)
+		}
+		weight, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fair share weight %q - %v", spec, err)
+		}
+		if weight <= 0 {
+			return nil, fmt.Errorf("fair share weight %q has to be positive", spec)
+		}
+		weights[parts[0]] = weight
+	}
+	return weights, nil
+}
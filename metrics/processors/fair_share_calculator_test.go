@@ -0,0 +1,117 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func fairShareNode(cpuAllocatable, memoryAllocatable float32) *core.MetricSet {
+	metricSet := &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypeNode,
+		},
+		MetricValues: map[string]core.MetricValue{},
+	}
+	setFloat(metricSet, &core.MetricNodeCpuAllocatable, cpuAllocatable)
+	setFloat(metricSet, &core.MetricNodeMemoryAllocatable, memoryAllocatable)
+	return metricSet
+}
+
+func fairShareNamespace(namespace string, cpuUsage, memoryUsage int64) *core.MetricSet {
+	return &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypeNamespace,
+			core.LabelNamespaceName.Key: namespace,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuUsageRate.Name: intValue(cpuUsage),
+			core.MetricMemoryUsage.Name:  intValue(memoryUsage),
+		},
+	}
+}
+
+func fairShareBatch() *core.DataBatch {
+	// 4000 millicores and 8000 bytes allocatable in total.
+	return &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NodeKey("n1"):           fairShareNode(1000, 2000),
+			core.NodeKey("n2"):           fairShareNode(3000, 6000),
+			core.NamespaceKey("prod"):    fairShareNamespace("prod", 2400, 2000),
+			core.NamespaceKey("dev"):     fairShareNamespace("dev", 400, 4000),
+			core.NamespaceKey("staging"): fairShareNamespace("staging", 200, 800),
+		},
+	}
+}
+
+func fairShareOverage(t *testing.T, batch *core.DataBatch, namespace string) float32 {
+	value, found := batch.MetricSets[core.NamespaceKey(namespace)].MetricValues[core.MetricNamespaceFairShareOverage.Name]
+	require.True(t, found, "overage of %s", namespace)
+	assert.Equal(t, core.MetricGauge, value.MetricType)
+	return value.FloatValue
+}
+
+func TestFairShareCalculatorEqualSplit(t *testing.T) {
+	batch, err := (&FairShareCalculator{}).Process(fairShareBatch())
+	require.NoError(t, err)
+
+	// Every namespace gets a third of the cluster. prod uses 60% of the CPU, dev half of the memory.
+	assert.InDelta(t, 0.6-1.0/3, fairShareOverage(t, batch, "prod"), 0.0001)
+	assert.InDelta(t, 0.5-1.0/3, fairShareOverage(t, batch, "dev"), 0.0001)
+	assert.InDelta(t, 0, fairShareOverage(t, batch, "staging"), 0.0001)
+}
+
+func TestFairShareCalculatorWeightedShares(t *testing.T) {
+	weights, err := ParseFairShareWeights([]string{"prod=3", "dev=0.5"})
+	require.NoError(t, err)
+	batch, err := (&FairShareCalculator{Weights: weights}).Process(fairShareBatch())
+	require.NoError(t, err)
+
+	// The total weight is 4.5 with staging at the default weight of 1.
+	assert.InDelta(t, 0, fairShareOverage(t, batch, "prod"), 0.0001)
+	assert.InDelta(t, 0.5-0.5/4.5, fairShareOverage(t, batch, "dev"), 0.0001)
+	assert.InDelta(t, 0, fairShareOverage(t, batch, "staging"), 0.0001)
+}
+
+func TestFairShareCalculatorWithoutCapacity(t *testing.T) {
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NamespaceKey("prod"): fairShareNamespace("prod", 2400, 2000),
+		},
+	}
+	batch, err := (&FairShareCalculator{}).Process(batch)
+	require.NoError(t, err)
+	_, found := batch.MetricSets[core.NamespaceKey("prod")].MetricValues[core.MetricNamespaceFairShareOverage.Name]
+	assert.False(t, found)
+}
+
+func TestParseFairShareWeights(t *testing.T) {
+	weights, err := ParseFairShareWeights([]string{"prod=2", "dev=0.25"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"prod": 2, "dev": 0.25}, weights)
+
+	for _, spec := range []string{"prod", "=2", "prod=", "prod=heavy", "prod=0", "prod=-1"} {
+		_, err := ParseFairShareWeights([]string{spec})
+		assert.Error(t, err, "spec %q", spec)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// LabelAggregator rolls up pod metrics by the value of the given metric set label,
+// e.g. an application label stored by the pod based enricher. Pods without the label
+// are skipped. The groups are exported as label_group metric sets.
+type LabelAggregator struct {
+	Label              string
+	MetricsToAggregate []string
+	// If set, the metrics are averaged over the pods of the group that report them
+	// instead of being summed up.
+	Average bool
+}
+
+func (this *LabelAggregator) Name() string {
+	return fmt.Sprintf("label_aggregator:%s", this.Label)
+}
+
+func (this *LabelAggregator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	groups := make(map[string]*core.MetricSet)
+	// Number of pods reporting every metric, per group.
+	counts := make(map[string]map[string]int64)
+	for _, metricSet := range batch.MetricSets {
+		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypePod {
+			continue
+		}
+		value := metricSet.Labels[this.Label]
+		if value == "" {
+			continue
+		}
+
+		groupKey := core.LabelGroupKey(this.Label, value)
+		group, found := groups[groupKey]
+		if !found {
+			group = this.labelGroupMetricSet(value)
+			groups[groupKey] = group
+			counts[groupKey] = make(map[string]int64)
+		}
+		if err := aggregate(metricSet, group, this.MetricsToAggregate); err != nil {
+			return nil, err
+		}
+		for _, metricName := range this.MetricsToAggregate {
+			if _, found := metricSet.MetricValues[metricName]; found {
+				counts[groupKey][metricName]++
+			}
+		}
+	}
+
+	for key, group := range groups {
+		if this.Average {
+			for metricName, count := range counts[key] {
+				metricValue := group.MetricValues[metricName]
+				if metricValue.ValueType == core.ValueInt64 {
+					metricValue.IntValue /= count
+				} else {
+					metricValue.FloatValue /= float32(count)
+				}
+				group.MetricValues[metricName] = metricValue
+			}
+		}
+		batch.MetricSets[key] = group
+	}
+	return batch, nil
+}
+
+func (this *LabelAggregator) labelGroupMetricSet(value string) *core.MetricSet {
+	return &core.MetricSet{
+		MetricValues: make(map[string]core.MetricValue),
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypeLabelGroup,
+			this.Label:                  value,
+		},
+	}
+}
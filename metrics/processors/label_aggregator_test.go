@@ -0,0 +1,107 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func labeledPodMetricSet(namespace, app string, cpu float32, memory int64) *core.MetricSet {
+	labels := map[string]string{
+		core.LabelMetricSetType.Key: core.MetricSetTypePod,
+		core.LabelNamespaceName.Key: namespace,
+	}
+	if app != "" {
+		labels["app"] = app
+	}
+	return &core.MetricSet{
+		Labels: labels,
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuUsageRate.Name: floatValue(cpu),
+			core.MetricMemoryUsage.Name:  intValue(memory),
+		},
+	}
+}
+
+func labelAggregatorBatch() *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "web-1"): labeledPodMetricSet("ns1", "web", 1, 100),
+			core.PodKey("ns1", "web-2"): labeledPodMetricSet("ns1", "web", 2, 300),
+			core.PodKey("ns2", "web-3"): labeledPodMetricSet("ns2", "web", 3, 500),
+			core.PodKey("ns1", "db-1"):  labeledPodMetricSet("ns1", "db", 4, 1000),
+			core.PodKey("ns1", "other"): labeledPodMetricSet("ns1", "", 5, 2000),
+			core.PodContainerKey("ns1", "web-1", "c1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+					"app":                       "web",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryUsage.Name: intValue(100),
+				},
+			},
+		},
+	}
+}
+
+func TestLabelAggregatorSum(t *testing.T) {
+	aggregator := &LabelAggregator{
+		Label:              "app",
+		MetricsToAggregate: []string{core.MetricCpuUsageRate.Name, core.MetricMemoryUsage.Name},
+	}
+	batch, err := aggregator.Process(labelAggregatorBatch())
+	assert.NoError(t, err)
+	assert.Equal(t, 8, len(batch.MetricSets))
+
+	web, found := batch.MetricSets[core.LabelGroupKey("app", "web")]
+	if assert.True(t, found) {
+		assert.Equal(t, core.MetricSetTypeLabelGroup, web.Labels[core.LabelMetricSetType.Key])
+		assert.Equal(t, "web", web.Labels["app"])
+		assert.Equal(t, float32(6), web.MetricValues[core.MetricCpuUsageRate.Name].FloatValue)
+		assert.Equal(t, int64(900), web.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	}
+	db, found := batch.MetricSets[core.LabelGroupKey("app", "db")]
+	if assert.True(t, found) {
+		assert.Equal(t, float32(4), db.MetricValues[core.MetricCpuUsageRate.Name].FloatValue)
+		assert.Equal(t, int64(1000), db.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	}
+}
+
+func TestLabelAggregatorAverage(t *testing.T) {
+	aggregator := &LabelAggregator{
+		Label:              "app",
+		MetricsToAggregate: []string{core.MetricCpuUsageRate.Name, core.MetricMemoryUsage.Name},
+		Average:            true,
+	}
+	batch, err := aggregator.Process(labelAggregatorBatch())
+	assert.NoError(t, err)
+
+	web, found := batch.MetricSets[core.LabelGroupKey("app", "web")]
+	if assert.True(t, found) {
+		assert.Equal(t, float32(2), web.MetricValues[core.MetricCpuUsageRate.Name].FloatValue)
+		assert.Equal(t, int64(300), web.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	}
+	db, found := batch.MetricSets[core.LabelGroupKey("app", "db")]
+	if assert.True(t, found) {
+		assert.Equal(t, float32(4), db.MetricValues[core.MetricCpuUsageRate.Name].FloatValue)
+		assert.Equal(t, int64(1000), db.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// Number of containers of a namespace and of those missing a limit.
+type limitCounts struct {
+	total         int64
+	withoutLimits int64
+}
+
+// LimitCoverageCalculator counts the containers of every namespace, and those missing
+// a CPU or a memory limit. Only the containers whose limits were set by the pod based
+// enricher are counted, a zero limit meaning that none is set. It has to run after the
+// namespace aggregator.
+type LimitCoverageCalculator struct {
+}
+
+func (this *LimitCoverageCalculator) Name() string {
+	return "limit_coverage_calculator"
+}
+
+func (this *LimitCoverageCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	counts := make(map[string]*limitCounts)
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer {
+			continue
+		}
+		cpuLimit, found := metricSet.MetricValues[core.MetricCpuLimit.Name]
+		memoryLimit, found2 := metricSet.MetricValues[core.MetricMemoryLimit.Name]
+		if !found || !found2 {
+			continue
+		}
+		namespaceName := metricSet.Labels[core.LabelNamespaceName.Key]
+		namespaceCounts, found := counts[namespaceName]
+		if !found {
+			namespaceCounts = &limitCounts{}
+			counts[namespaceName] = namespaceCounts
+		}
+		namespaceCounts.total++
+		if cpuLimit.IntValue <= 0 || memoryLimit.IntValue <= 0 {
+			namespaceCounts.withoutLimits++
+		}
+	}
+
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypeNamespace {
+			continue
+		}
+		namespaceCounts, found := counts[metricSet.Labels[core.LabelNamespaceName.Key]]
+		if !found {
+			namespaceCounts = &limitCounts{}
+		}
+		metricSet.MetricValues[core.MetricNamespaceContainersTotal.Name] = intValue(namespaceCounts.total)
+		metricSet.MetricValues[core.MetricNamespaceContainersWithoutLimits.Name] = intValue(namespaceCounts.withoutLimits)
+	}
+	return batch, nil
+}
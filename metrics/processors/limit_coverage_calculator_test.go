@@ -0,0 +1,80 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func limitContainer(namespace string, cpuLimit, memoryLimit int64) *core.MetricSet {
+	return &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			core.LabelNamespaceName.Key: namespace,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuLimit.Name:    intValue(cpuLimit),
+			core.MetricMemoryLimit.Name: intValue(memoryLimit),
+		},
+	}
+}
+
+func limitNamespace(namespace string) *core.MetricSet {
+	return &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypeNamespace,
+			core.LabelNamespaceName.Key: namespace,
+		},
+		MetricValues: map[string]core.MetricValue{},
+	}
+}
+
+func TestLimitCoverageCalculator(t *testing.T) {
+	notEnriched := limitContainer("ns1", 0, 0)
+	notEnriched.MetricValues = map[string]core.MetricValue{}
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "limited"):    limitContainer("ns1", 500, 1000),
+			core.PodContainerKey("ns1", "pod1", "no-cpu"):     limitContainer("ns1", 0, 1000),
+			core.PodContainerKey("ns1", "pod1", "no-memory"):  limitContainer("ns1", 500, 0),
+			core.PodContainerKey("ns1", "pod2", "unlimited"):  limitContainer("ns1", 0, 0),
+			core.PodContainerKey("ns1", "pod2", "not-in-api"): notEnriched,
+			core.PodContainerKey("ns2", "pod3", "limited"):    limitContainer("ns2", 100, 100),
+			core.PodContainerKey("ns3", "pod4", "unlimited"):  limitContainer("ns3", 0, 0),
+			core.NamespaceKey("ns1"):                          limitNamespace("ns1"),
+			core.NamespaceKey("ns2"):                          limitNamespace("ns2"),
+			core.NamespaceKey("empty"):                        limitNamespace("empty"),
+		},
+	}
+	batch, err := (&LimitCoverageCalculator{}).Process(batch)
+	require.NoError(t, err)
+
+	for namespace, expected := range map[string][2]int64{
+		"ns1":   {4, 3},
+		"ns2":   {1, 0},
+		"empty": {0, 0},
+	} {
+		metricSet := batch.MetricSets[core.NamespaceKey(namespace)]
+		assert.Equal(t, expected[0], metricSet.MetricValues[core.MetricNamespaceContainersTotal.Name].IntValue, namespace)
+		assert.Equal(t, expected[1], metricSet.MetricValues[core.MetricNamespaceContainersWithoutLimits.Name].IntValue, namespace)
+	}
+}
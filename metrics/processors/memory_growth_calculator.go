@@ -0,0 +1,148 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// Coefficient of determination of the linear trend of the working set above which its
+// growth is steady enough to be sustained.
+const sustainedGrowthFit = 0.9
+
+type workingSetSample struct {
+	timestamp  time.Time
+	workingSet int64
+}
+
+// workingSetWindow holds the working set samples of a container within the window, since
+// the container last started.
+type workingSetWindow struct {
+	started time.Time
+	samples []workingSetSample
+}
+
+// MemoryGrowthCalculator fits a linear trend to the memory working set of every pod
+// container over the window ending with the current batch, and emits its slope in bytes
+// per hour. The growth is flagged as sustained, e.g. because of a memory leak, if it is
+// positive, the samples cover at least half of the window and the trend explains most of
+// their variation. The window starts over when the container restarts.
+type MemoryGrowthCalculator struct {
+	window time.Duration
+	// Samples by container key.
+	windows map[string]*workingSetWindow
+}
+
+func (this *MemoryGrowthCalculator) Name() string {
+	return "memory_growth_calculator"
+}
+
+func (this *MemoryGrowthCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	seen := make(map[string]struct{})
+	for key, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer {
+			continue
+		}
+		workingSet, found := metricSet.MetricValues[core.MetricMemoryWorkingSet.Name]
+		if !found {
+			continue
+		}
+		now := metricSet.ScrapeTime
+		if now.IsZero() {
+			now = batch.Timestamp
+		}
+		seen[key] = struct{}{}
+		window, found := this.windows[key]
+		if !found || !window.started.Equal(metricSet.CollectionStartTime) {
+			window = &workingSetWindow{started: metricSet.CollectionStartTime}
+			this.windows[key] = window
+		}
+		window.add(workingSetSample{timestamp: now, workingSet: workingSet.IntValue}, this.window)
+		if len(window.samples) < 2 {
+			continue
+		}
+
+		slope, fit := window.trend()
+		setFloat(metricSet, &core.MetricContainerMemoryGrowthRate, float32(slope))
+		span := window.samples[len(window.samples)-1].timestamp.Sub(window.samples[0].timestamp)
+		sustained := int64(0)
+		if slope > 0 && fit >= sustainedGrowthFit && span >= this.window/2 {
+			sustained = 1
+		}
+		metricSet.MetricValues[core.MetricContainerMemoryGrowthSustained.Name] = intValue(sustained)
+	}
+
+	for key := range this.windows {
+		if _, found := seen[key]; !found {
+			delete(this.windows, key)
+		}
+	}
+	return batch, nil
+}
+
+// add records the sample and drops the ones that fell out of the window. A sample not newer
+// than the latest one, e.g. carried forward from an earlier cycle, is ignored.
+func (this *workingSetWindow) add(sample workingSetSample, window time.Duration) {
+	if n := len(this.samples); n > 0 && !sample.timestamp.After(this.samples[n-1].timestamp) {
+		return
+	}
+	samples := append(this.samples, sample)
+	cutoff := sample.timestamp.Add(-window)
+	first := 0
+	for first < len(samples)-1 && samples[first].timestamp.Before(cutoff) {
+		first++
+	}
+	this.samples = samples[first:]
+}
+
+// trend returns the slope in bytes per hour of the least squares line through the samples,
+// and its coefficient of determination, 0 for a flat series.
+func (this *workingSetWindow) trend() (float64, float64) {
+	origin := this.samples[0].timestamp
+	var meanX, meanY float64
+	for _, sample := range this.samples {
+		meanX += sample.timestamp.Sub(origin).Hours()
+		meanY += float64(sample.workingSet)
+	}
+	n := float64(len(this.samples))
+	meanX /= n
+	meanY /= n
+
+	var sxy, sxx, syy float64
+	for _, sample := range this.samples {
+		dx := sample.timestamp.Sub(origin).Hours() - meanX
+		dy := float64(sample.workingSet) - meanY
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0
+	}
+	slope := sxy / sxx
+	if syy == 0 {
+		return slope, 0
+	}
+	return slope, sxy * sxy / (sxx * syy)
+}
+
+func NewMemoryGrowthCalculator(window time.Duration) *MemoryGrowthCalculator {
+	return &MemoryGrowthCalculator{
+		window:  window,
+		windows: make(map[string]*workingSetWindow),
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func growthBatch(timestamp, started time.Time, workingSets map[string]int64) *core.DataBatch {
+	batch := &core.DataBatch{
+		Timestamp:  timestamp,
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	for container, workingSet := range workingSets {
+		batch.MetricSets[core.PodContainerKey("ns1", "pod1", container)] = &core.MetricSet{
+			CollectionStartTime: started,
+			ScrapeTime:          timestamp,
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricMemoryWorkingSet.Name: intValue(workingSet),
+			},
+		}
+	}
+	return batch
+}
+
+// growth returns the growth rate and whether it is sustained, or false if not reported.
+func growth(batch *core.DataBatch, container string) (float32, int64, bool) {
+	metricSet := batch.MetricSets[core.PodContainerKey("ns1", "pod1", container)]
+	rate, found := metricSet.MetricValues[core.MetricContainerMemoryGrowthRate.Name]
+	if !found {
+		return 0, 0, false
+	}
+	return rate.FloatValue, metricSet.MetricValues[core.MetricContainerMemoryGrowthSustained.Name].IntValue, true
+}
+
+func TestMemoryGrowthCalculator(t *testing.T) {
+	calculator := NewMemoryGrowthCalculator(10 * time.Minute)
+	start := time.Now()
+	started := start.Add(-time.Hour)
+
+	batch, err := calculator.Process(growthBatch(start, started, map[string]int64{"leaking": 100 * mi, "flat": 100 * mi}))
+	require.NoError(t, err)
+	_, _, found := growth(batch, "leaking")
+	assert.False(t, found)
+
+	// The leaking container grows by 1Mi a minute, with some noise.
+	noise := []int64{0, 10000, -5000, 8000, -10000, 3000, 0, -2000, 6000, 1000, -4000, 0}
+	for i := 1; i <= 11; i++ {
+		now := start.Add(time.Duration(i) * time.Minute)
+		batch, err = calculator.Process(growthBatch(now, started, map[string]int64{
+			"leaking": 100*mi + int64(i)*mi + noise[i],
+			"flat":    100 * mi,
+		}))
+		require.NoError(t, err)
+
+		rate, sustained, found := growth(batch, "leaking")
+		require.True(t, found)
+		assert.InDelta(t, 60*mi, rate, 3*mi, "minute %d", i)
+		// The samples cover half of the window after 5 minutes.
+		if i < 5 {
+			assert.Equal(t, int64(0), sustained, "minute %d", i)
+		} else {
+			assert.Equal(t, int64(1), sustained, "minute %d", i)
+		}
+
+		rate, sustained, found = growth(batch, "flat")
+		require.True(t, found)
+		assert.Equal(t, float32(0), rate)
+		assert.Equal(t, int64(0), sustained)
+	}
+
+	// A restart starts the window over.
+	now := start.Add(12 * time.Minute)
+	batch, err = calculator.Process(growthBatch(now, now.Add(-time.Second), map[string]int64{"leaking": 10 * mi}))
+	require.NoError(t, err)
+	_, _, found = growth(batch, "leaking")
+	assert.False(t, found)
+	// The flat container is gone and forgotten.
+	assert.Len(t, calculator.windows, 1)
+}
+
+func TestMemoryGrowthNotSustainedWhenErratic(t *testing.T) {
+	calculator := NewMemoryGrowthCalculator(10 * time.Minute)
+	start := time.Now()
+	workingSets := []int64{100, 300, 120, 280, 110, 320, 130, 290, 140, 330, 150}
+	var batch *core.DataBatch
+	for i, workingSet := range workingSets {
+		var err error
+		batch, err = calculator.Process(growthBatch(start.Add(time.Duration(i)*time.Minute), start, map[string]int64{"c1": workingSet * mi}))
+		require.NoError(t, err)
+	}
+	rate, sustained, found := growth(batch, "c1")
+	require.True(t, found)
+	assert.True(t, rate > 0)
+	assert.Equal(t, int64(0), sustained)
+}
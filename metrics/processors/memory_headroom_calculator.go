@@ -0,0 +1,62 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// MemoryHeadroomCalculator computes the memory request of every container minus its
+// working set, negative for the containers using more than they requested. Containers
+// without a request are skipped. If AggregateWorkloads is set, the headroom of the
+// containers of a workload is summed up on the workload. It has to run after the pod
+// based enricher, which sets the requests, and after the workload aggregator.
+type MemoryHeadroomCalculator struct {
+	AggregateWorkloads bool
+}
+
+func (this *MemoryHeadroomCalculator) Name() string {
+	return "memory_headroom_calculator"
+}
+
+func (this *MemoryHeadroomCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	workloads := make(map[string]int64)
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer {
+			continue
+		}
+		workingSet, found := metricSet.MetricValues[core.MetricMemoryWorkingSet.Name]
+		request, found2 := metricSet.MetricValues[core.MetricMemoryRequest.Name]
+		if !found || !found2 || request.IntValue <= 0 {
+			continue
+		}
+		headroom := request.IntValue - workingSet.IntValue
+		metricSet.MetricValues[core.MetricContainerMemoryRequestHeadroom.Name] = intValue(headroom)
+
+		if !this.AggregateWorkloads {
+			continue
+		}
+		if workloadKey := containerWorkloadKey(batch, metricSet); workloadKey != "" {
+			workloads[workloadKey] += headroom
+		}
+	}
+
+	for workloadKey, headroom := range workloads {
+		if workload, found := batch.MetricSets[workloadKey]; found {
+			workload.MetricValues[core.MetricContainerMemoryRequestHeadroom.Name] = intValue(headroom)
+		}
+	}
+	return batch, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func headroomContainer(pod string, workingSet, request int64) *core.MetricSet {
+	metricSet := &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			core.LabelNamespaceName.Key: "ns1",
+			core.LabelPodName.Key:       pod,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricMemoryWorkingSet.Name: intValue(workingSet),
+		},
+	}
+	if request >= 0 {
+		metricSet.MetricValues[core.MetricMemoryRequest.Name] = intValue(request)
+	}
+	return metricSet
+}
+
+func headroomBatch() *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "web-1", "app"):     headroomContainer("web-1", 300, 1000),
+			core.PodContainerKey("ns1", "web-1", "sidecar"): headroomContainer("web-1", 100, -1),
+			core.PodContainerKey("ns1", "web-2", "app"):     headroomContainer("web-2", 1200, 1000),
+			core.PodContainerKey("ns1", "batch", "job"):     headroomContainer("batch", 100, 0),
+			core.PodKey("ns1", "web-1"):                     efficiencyPod("web-1", "web"),
+			core.PodKey("ns1", "web-2"):                     efficiencyPod("web-2", "web"),
+			core.WorkloadKey("ns1", "Deployment", "web"): {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeWorkload},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	}
+}
+
+func TestMemoryHeadroomCalculator(t *testing.T) {
+	batch, err := (&MemoryHeadroomCalculator{}).Process(headroomBatch())
+	require.NoError(t, err)
+
+	headroom := func(key string) (int64, bool) {
+		value, found := batch.MetricSets[key].MetricValues[core.MetricContainerMemoryRequestHeadroom.Name]
+		return value.IntValue, found
+	}
+	value, found := headroom(core.PodContainerKey("ns1", "web-1", "app"))
+	assert.True(t, found)
+	assert.Equal(t, int64(700), value)
+	// Over its request.
+	value, found = headroom(core.PodContainerKey("ns1", "web-2", "app"))
+	assert.True(t, found)
+	assert.Equal(t, int64(-200), value)
+
+	for _, key := range []string{
+		core.PodContainerKey("ns1", "web-1", "sidecar"),
+		core.PodContainerKey("ns1", "batch", "job"),
+		core.WorkloadKey("ns1", "Deployment", "web"),
+	} {
+		_, found := headroom(key)
+		assert.False(t, found, key)
+	}
+}
+
+func TestMemoryHeadroomPerWorkload(t *testing.T) {
+	batch, err := (&MemoryHeadroomCalculator{AggregateWorkloads: true}).Process(headroomBatch())
+	require.NoError(t, err)
+
+	value, found := batch.MetricSets[core.WorkloadKey("ns1", "Deployment", "web")].MetricValues[core.MetricContainerMemoryRequestHeadroom.Name]
+	assert.True(t, found)
+	assert.Equal(t, int64(500), value.IntValue)
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	"github.com/golang/glog"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// MetricFilterConfig is the content of the metric filter configuration file, in YAML or JSON.
+type MetricFilterConfig struct {
+	// Names of the only metrics that are kept, all metrics if empty.
+	MetricAllowlist []string `json:"metricAllowlist"`
+	// Names of the metrics that are dropped.
+	MetricDenylist []string `json:"metricDenylist"`
+}
+
+type metricFilterRules struct {
+	allow map[string]struct{}
+	deny  map[string]struct{}
+}
+
+func (this *metricFilterRules) keep(metricName string) bool {
+	if _, found := this.deny[metricName]; found {
+		return false
+	}
+	if len(this.allow) == 0 {
+		return true
+	}
+	_, found := this.allow[metricName]
+	return found
+}
+
+// MetricFilter drops the metrics, including the labeled ones, that are not allowed by
+// the configuration file. The file is read again by Reload, and the new configuration
+// applies from the next batch on. A batch is always processed with a single configuration.
+type MetricFilter struct {
+	path  string
+	lock  sync.RWMutex
+	rules *metricFilterRules
+}
+
+func (this *MetricFilter) Name() string {
+	return "metric_filter"
+}
+
+func (this *MetricFilter) Stateless() {}
+
+func (this *MetricFilter) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	this.lock.RLock()
+	rules := this.rules
+	this.lock.RUnlock()
+
+	for _, metricSet := range batch.MetricSets {
+		for metricName := range metricSet.MetricValues {
+			if !rules.keep(metricName) {
+				delete(metricSet.MetricValues, metricName)
+			}
+		}
+		if len(metricSet.LabeledMetrics) == 0 {
+			continue
+		}
+		labeledMetrics := make([]core.LabeledMetric, 0, len(metricSet.LabeledMetrics))
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			if rules.keep(labeledMetric.Name) {
+				labeledMetrics = append(labeledMetrics, labeledMetric)
+			}
+		}
+		metricSet.LabeledMetrics = labeledMetrics
+	}
+	return batch, nil
+}
+
+// Reload reads the configuration file again. If it can not be read or is invalid, the
+// current configuration is kept.
+func (this *MetricFilter) Reload() error {
+	rules, err := readMetricFilterRules(this.path)
+	if err != nil {
+		return err
+	}
+	this.lock.Lock()
+	this.rules = rules
+	this.lock.Unlock()
+	glog.Infof("Reloaded the metric filter configuration from %s", this.path)
+	return nil
+}
+
+func readMetricFilterRules(path string) (*metricFilterRules, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the metric filter configuration: %v", err)
+	}
+	config := MetricFilterConfig{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse the metric filter configuration %s: %v", path, err)
+	}
+	if len(config.MetricAllowlist) > 0 && len(config.MetricDenylist) > 0 {
+		return nil, fmt.Errorf("only one of metricAllowlist and metricDenylist can be set in %s", path)
+	}
+	rules := &metricFilterRules{
+		allow: make(map[string]struct{}, len(config.MetricAllowlist)),
+		deny:  make(map[string]struct{}, len(config.MetricDenylist)),
+	}
+	for _, name := range config.MetricAllowlist {
+		rules.allow[name] = struct{}{}
+	}
+	for _, name := range config.MetricDenylist {
+		rules.deny[name] = struct{}{}
+	}
+	return rules, nil
+}
+
+// NewMetricFilter returns a filter configured by the given file.
+func NewMetricFilter(path string) (*MetricFilter, error) {
+	rules, err := readMetricFilterRules(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MetricFilter{
+		path:  path,
+		rules: rules,
+	}, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func metricFilterBatch() *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsageRate.Name: {ValueType: core.ValueInt64, IntValue: 100},
+					core.MetricMemoryUsage.Name:  {ValueType: core.ValueInt64, IntValue: 200},
+					core.MetricUptime.Name:       {ValueType: core.ValueInt64, IntValue: 300},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:        core.MetricFilesystemUsage.Name,
+						Labels:      map[string]string{core.LabelResourceID.Key: "/"},
+						MetricValue: core.MetricValue{ValueType: core.ValueInt64, IntValue: 400},
+					},
+				},
+			},
+		},
+	}
+}
+
+func metricNames(metricSet *core.MetricSet) []string {
+	names := []string{}
+	for name := range metricSet.MetricValues {
+		names = append(names, name)
+	}
+	for _, labeledMetric := range metricSet.LabeledMetrics {
+		names = append(names, labeledMetric.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeMetricFilterConfig(t *testing.T, path, content string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestMetricFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metric_filter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+
+	writeMetricFilterConfig(t, path, "metricDenylist:\n- uptime\n- filesystem/usage\n")
+	filter, err := NewMetricFilter(path)
+	require.NoError(t, err)
+	batch, err := filter.Process(metricFilterBatch())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cpu/usage_rate", "memory/usage"}, metricNames(batch.MetricSets[core.PodKey("ns1", "pod1")]))
+
+	// The new configuration takes effect with the next batch.
+	writeMetricFilterConfig(t, path, `{"metricAllowlist": ["memory/usage", "filesystem/usage"]}`)
+	require.NoError(t, filter.Reload())
+	batch, err = filter.Process(metricFilterBatch())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"filesystem/usage", "memory/usage"}, metricNames(batch.MetricSets[core.PodKey("ns1", "pod1")]))
+
+	// An invalid configuration keeps the current one.
+	writeMetricFilterConfig(t, path, "metricAllowlist: [uptime]\nmetricDenylist: [uptime]\n")
+	assert.Error(t, filter.Reload())
+	os.Remove(path)
+	assert.Error(t, filter.Reload())
+	batch, err = filter.Process(metricFilterBatch())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"filesystem/usage", "memory/usage"}, metricNames(batch.MetricSets[core.PodKey("ns1", "pod1")]))
+}
+
+func TestMetricFilterEmptyConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metric_filter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+
+	writeMetricFilterConfig(t, path, "")
+	filter, err := NewMetricFilter(path)
+	require.NoError(t, err)
+	batch, err := filter.Process(metricFilterBatch())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cpu/usage_rate", "filesystem/usage", "memory/usage", "uptime"}, metricNames(batch.MetricSets[core.PodKey("ns1", "pod1")]))
+
+	_, err = NewMetricFilter(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestMetricFilterConcurrentReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metric_filter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+
+	writeMetricFilterConfig(t, path, "metricDenylist: [uptime]\n")
+	filter, err := NewMetricFilter(path)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, filter.Reload())
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		batch, err := filter.Process(metricFilterBatch())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"cpu/usage_rate", "filesystem/usage", "memory/usage"}, metricNames(batch.MetricSets[core.PodKey("ns1", "pod1")]))
+	}
+	wg.Wait()
+}
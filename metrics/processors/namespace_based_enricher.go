@@ -37,6 +37,8 @@ func (this *NamespaceBasedEnricher) Name() string {
 	return "namespace_based_enricher"
 }
 
+func (this *NamespaceBasedEnricher) Stateless() {}
+
 func (this *NamespaceBasedEnricher) Process(batch *core.DataBatch) (*core.DataBatch, error) {
 	for _, ms := range batch.MetricSets {
 		this.addNamespaceInfo(ms)
@@ -0,0 +1,65 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"github.com/golang/glog"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// NamespaceFilter drops the metric sets of the namespaces that are not collected.
+// With an allowlist only the listed namespaces are collected, with a denylist all but
+// the listed ones. Metric sets without a namespace, e.g. of nodes and system
+// containers, are always kept.
+type NamespaceFilter struct {
+	namespaces map[string]struct{}
+	allow      bool
+}
+
+func (this *NamespaceFilter) Name() string {
+	return "namespace_filter"
+}
+
+func (this *NamespaceFilter) Stateless() {}
+
+func (this *NamespaceFilter) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	dropped := 0
+	for key, metricSet := range batch.MetricSets {
+		namespace := metricSet.Labels[core.LabelNamespaceName.Key]
+		if namespace == "" {
+			continue
+		}
+		if _, found := this.namespaces[namespace]; found != this.allow {
+			delete(batch.MetricSets, key)
+			dropped++
+		}
+	}
+	glog.V(4).Infof("Dropped %d metric sets of filtered out namespaces", dropped)
+	return batch, nil
+}
+
+// NewNamespaceFilter returns a filter collecting only the given namespaces if allow is
+// true, or all but the given namespaces otherwise.
+func NewNamespaceFilter(namespaces []string, allow bool) *NamespaceFilter {
+	filter := &NamespaceFilter{
+		namespaces: make(map[string]struct{}, len(namespaces)),
+		allow:      allow,
+	}
+	for _, namespace := range namespaces {
+		filter.namespaces[namespace] = struct{}{}
+	}
+	return filter
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func namespaceFilterBatch() *core.DataBatch {
+	podContainer := func(ns string) *core.MetricSet {
+		return &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+				core.LabelNamespaceName.Key: ns,
+			},
+			MetricValues: map[string]core.MetricValue{},
+		}
+	}
+	return &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("team-a", "pod1", "c1"):     podContainer("team-a"),
+			core.PodContainerKey("team-b", "pod1", "c1"):     podContainer("team-b"),
+			core.PodContainerKey("kube-system", "dns", "c1"): podContainer("kube-system"),
+			core.NodeKey("node1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypeNode,
+				},
+				MetricValues: map[string]core.MetricValue{},
+			},
+			core.NodeContainerKey("node1", "kubelet"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypeSystemContainer,
+				},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	}
+}
+
+func batchKeys(batch *core.DataBatch) []string {
+	keys := make([]string, 0, len(batch.MetricSets))
+	for key := range batch.MetricSets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestNamespaceFilterAllow(t *testing.T) {
+	filter := NewNamespaceFilter([]string{"team-a", "kube-system"}, true)
+	batch, err := filter.Process(namespaceFilterBatch())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		core.PodContainerKey("kube-system", "dns", "c1"),
+		core.PodContainerKey("team-a", "pod1", "c1"),
+		core.NodeKey("node1"),
+		core.NodeContainerKey("node1", "kubelet"),
+	}, batchKeys(batch))
+}
+
+func TestNamespaceFilterDeny(t *testing.T) {
+	filter := NewNamespaceFilter([]string{"kube-system"}, false)
+	batch, err := filter.Process(namespaceFilterBatch())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		core.PodContainerKey("team-a", "pod1", "c1"),
+		core.PodContainerKey("team-b", "pod1", "c1"),
+		core.NodeKey("node1"),
+		core.NodeContainerKey("node1", "kubelet"),
+	}, batchKeys(batch))
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// NamespacePodCountDeltaCalculator emits the difference between the number of pods
+// in every namespace and the number seen in the previous batch. Namespaces seen for
+// the first time report zero. It has to run after the namespace aggregator.
+type NamespacePodCountDeltaCalculator struct {
+	previousCounts map[string]int64
+}
+
+func (this *NamespacePodCountDeltaCalculator) Name() string {
+	return "namespace_pod_count_delta_calculator"
+}
+
+func (this *NamespacePodCountDeltaCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	counts := make(map[string]int64)
+	for _, metricSet := range batch.MetricSets {
+		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypePod {
+			continue
+		}
+		if namespaceName, found := metricSet.Labels[core.LabelNamespaceName.Key]; found {
+			counts[namespaceName]++
+		}
+	}
+
+	for _, metricSet := range batch.MetricSets {
+		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypeNamespace {
+			continue
+		}
+		namespaceName := metricSet.Labels[core.LabelNamespaceName.Key]
+		count := counts[namespaceName]
+		var delta int64
+		if previous, found := this.previousCounts[namespaceName]; found {
+			delta = count - previous
+		}
+		metricSet.MetricValues[core.MetricNamespacePodCountDelta.Name] = intValue(delta)
+	}
+
+	this.previousCounts = counts
+	return batch, nil
+}
+
+func NewNamespacePodCountDeltaCalculator() *NamespacePodCountDeltaCalculator {
+	return &NamespacePodCountDeltaCalculator{
+		previousCounts: make(map[string]int64),
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// podCountBatch builds a batch with the given number of pods in every namespace.
+func podCountBatch(podCounts map[string]int) *core.DataBatch {
+	batch := &core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	for namespace, count := range podCounts {
+		batch.MetricSets[core.NamespaceKey(namespace)] = namespaceMetricSet(namespace, "")
+		for i := 0; i < count; i++ {
+			podName := fmt.Sprintf("pod%d", i)
+			batch.MetricSets[core.PodKey(namespace, podName)] = &core.MetricSet{
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: namespace,
+					core.LabelPodName.Key:       podName,
+				},
+				MetricValues: map[string]core.MetricValue{},
+			}
+		}
+	}
+	return batch
+}
+
+func TestNamespacePodCountDelta(t *testing.T) {
+	calculator := NewNamespacePodCountDeltaCalculator()
+	for i, tc := range []struct {
+		podCounts map[string]int
+		expected  map[string]int64
+	}{
+		{
+			// The first cycle has nothing to compare with.
+			podCounts: map[string]int{"ns1": 3, "ns2": 1},
+			expected:  map[string]int64{"ns1": 0, "ns2": 0},
+		},
+		{
+			podCounts: map[string]int{"ns1": 5, "ns2": 0},
+			expected:  map[string]int64{"ns1": 2, "ns2": -1},
+		},
+		{
+			podCounts: map[string]int{"ns1": 2, "ns2": 0, "ns3": 4},
+			expected:  map[string]int64{"ns1": -3, "ns2": 0, "ns3": 0},
+		},
+		{
+			podCounts: map[string]int{"ns1": 2, "ns3": 6},
+			expected:  map[string]int64{"ns1": 0, "ns3": 2},
+		},
+	} {
+		batch, err := calculator.Process(podCountBatch(tc.podCounts))
+		assert.NoError(t, err)
+		for namespace, expected := range tc.expected {
+			metricSet := batch.MetricSets[core.NamespaceKey(namespace)]
+			delta, found := metricSet.MetricValues[core.MetricNamespacePodCountDelta.Name]
+			if assert.True(t, found, "cycle %d: missing delta for %s", i, namespace) {
+				assert.Equal(t, expected, delta.IntValue, "cycle %d: %s", i, namespace)
+			}
+		}
+	}
+}
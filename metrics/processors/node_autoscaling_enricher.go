@@ -37,6 +37,8 @@ func (this *NodeAutoscalingEnricher) Name() string {
 	return "node_autoscaling_enricher"
 }
 
+func (this *NodeAutoscalingEnricher) Stateless() {}
+
 func (this *NodeAutoscalingEnricher) Process(batch *core.DataBatch) (*core.DataBatch, error) {
 	nodes, err := this.nodeLister.List(labels.Everything())
 	if err != nil {
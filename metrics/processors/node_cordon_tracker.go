@@ -0,0 +1,101 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// Time after which the state of a node missing in the batches is forgotten, so that a
+// failed scrape does not reset it but deleted nodes do not pile up.
+const nodeCordonRetention = time.Hour
+
+type nodeCordonState struct {
+	schedulable   bool
+	cordonedSince time.Time
+	transitions   int64
+	lastSeen      time.Time
+}
+
+// NodeCordonTracker follows the schedulable label of the nodes across the batches. It
+// emits the time since which a node is cordoned and the number of changes of its
+// schedulability since the node was first seen. A node already cordoned when first seen
+// is reported as cordoned since then.
+type NodeCordonTracker struct {
+	nodes map[string]*nodeCordonState
+}
+
+func (this *NodeCordonTracker) Name() string {
+	return "node_cordon_tracker"
+}
+
+func (this *NodeCordonTracker) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for key, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypeNode {
+			continue
+		}
+		label, found := metricSet.Labels[core.LabelNodeSchedulable.Key]
+		if !found {
+			continue
+		}
+		now := metricSet.ScrapeTime
+		if now.IsZero() {
+			now = batch.Timestamp
+		}
+		schedulable := label != "false"
+
+		state, found := this.nodes[key]
+		if !found {
+			state = &nodeCordonState{schedulable: schedulable}
+			if !schedulable {
+				state.cordonedSince = now
+			}
+			this.nodes[key] = state
+		} else if state.schedulable != schedulable {
+			state.schedulable = schedulable
+			state.transitions++
+			if schedulable {
+				state.cordonedSince = time.Time{}
+			} else {
+				state.cordonedSince = now
+			}
+		}
+		state.lastSeen = now
+
+		metricSet.MetricValues[core.MetricNodeSchedulableTransitions.Name] = core.MetricValue{
+			ValueType:  core.ValueInt64,
+			MetricType: core.MetricCumulative,
+			IntValue:   state.transitions,
+		}
+		if !state.cordonedSince.IsZero() {
+			metricSet.MetricValues[core.MetricNodeCordonedSince.Name] = intValue(state.cordonedSince.Unix())
+		}
+	}
+
+	for key, state := range this.nodes {
+		if batch.Timestamp.Sub(state.lastSeen) > nodeCordonRetention {
+			delete(this.nodes, key)
+		}
+	}
+	return batch, nil
+}
+
+func NewNodeCordonTracker() *NodeCordonTracker {
+	return &NodeCordonTracker{
+		nodes: make(map[string]*nodeCordonState),
+	}
+}
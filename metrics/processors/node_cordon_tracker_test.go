@@ -0,0 +1,108 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func cordonBatch(timestamp time.Time, schedulable map[string]string) *core.DataBatch {
+	batch := &core.DataBatch{
+		Timestamp:  timestamp,
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	for node, label := range schedulable {
+		batch.MetricSets[core.NodeKey(node)] = &core.MetricSet{
+			ScrapeTime: timestamp,
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key:   core.MetricSetTypeNode,
+				core.LabelNodeSchedulable.Key: label,
+			},
+			MetricValues: map[string]core.MetricValue{},
+		}
+	}
+	return batch
+}
+
+// cordonState returns the time at which the node was cordoned, zero if it is not, and
+// its number of transitions.
+func cordonState(t *testing.T, batch *core.DataBatch, node string) (time.Time, int64) {
+	metricSet := batch.MetricSets[core.NodeKey(node)]
+	transitions, found := metricSet.MetricValues[core.MetricNodeSchedulableTransitions.Name]
+	require.True(t, found)
+	assert.Equal(t, core.MetricCumulative, transitions.MetricType)
+	since, found := metricSet.MetricValues[core.MetricNodeCordonedSince.Name]
+	if !found {
+		return time.Time{}, transitions.IntValue
+	}
+	return time.Unix(since.IntValue, 0), transitions.IntValue
+}
+
+func TestNodeCordonTracker(t *testing.T) {
+	tracker := NewNodeCordonTracker()
+	now := time.Unix(1500000000, 0)
+	at := func(minutes int) time.Time {
+		return now.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	batch, err := tracker.Process(cordonBatch(at(0), map[string]string{"node1": "true", "node2": "false"}))
+	require.NoError(t, err)
+	since, transitions := cordonState(t, batch, "node1")
+	assert.True(t, since.IsZero())
+	assert.Equal(t, int64(0), transitions)
+	// Cordoned before it was first seen.
+	since, transitions = cordonState(t, batch, "node2")
+	assert.Equal(t, at(0), since)
+	assert.Equal(t, int64(0), transitions)
+
+	// node1 is cordoned and node2 uncordoned.
+	batch, err = tracker.Process(cordonBatch(at(1), map[string]string{"node1": "false", "node2": "true"}))
+	require.NoError(t, err)
+	since, transitions = cordonState(t, batch, "node1")
+	assert.Equal(t, at(1), since)
+	assert.Equal(t, int64(1), transitions)
+	since, transitions = cordonState(t, batch, "node2")
+	assert.True(t, since.IsZero())
+	assert.Equal(t, int64(1), transitions)
+
+	// The cordon time stays while node1 is cordoned, also across a failed scrape.
+	_, err = tracker.Process(cordonBatch(at(2), map[string]string{"node2": "true"}))
+	require.NoError(t, err)
+	batch, err = tracker.Process(cordonBatch(at(3), map[string]string{"node1": "false", "node2": "true"}))
+	require.NoError(t, err)
+	since, transitions = cordonState(t, batch, "node1")
+	assert.Equal(t, at(1), since)
+	assert.Equal(t, int64(1), transitions)
+
+	// Uncordoned and cordoned again.
+	_, err = tracker.Process(cordonBatch(at(4), map[string]string{"node1": "true"}))
+	require.NoError(t, err)
+	batch, err = tracker.Process(cordonBatch(at(5), map[string]string{"node1": "false"}))
+	require.NoError(t, err)
+	since, transitions = cordonState(t, batch, "node1")
+	assert.Equal(t, at(5), since)
+	assert.Equal(t, int64(3), transitions)
+
+	// Nodes missing for too long are forgotten.
+	_, err = tracker.Process(cordonBatch(at(5).Add(2*time.Hour), map[string]string{}))
+	require.NoError(t, err)
+	assert.Empty(t, tracker.nodes)
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// NodeDensityCalculator counts the pod containers and the pods of every node, by their
+// nodename label, to track how densely the nodes are packed. Nodes without pods report
+// zero. It has to run after the pod based enricher, which creates the metric sets of the
+// containers missing in the batch, and after the pod aggregator, which creates the pods.
+type NodeDensityCalculator struct {
+}
+
+func (this *NodeDensityCalculator) Name() string {
+	return "node_density_calculator"
+}
+
+func (this *NodeDensityCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	containers := make(map[string]int64)
+	pods := make(map[string]int64)
+	for _, metricSet := range batch.MetricSets {
+		nodename := metricSet.Labels[core.LabelNodename.Key]
+		if nodename == "" {
+			continue
+		}
+		switch metricSet.Labels[core.LabelMetricSetType.Key] {
+		case core.MetricSetTypePodContainer:
+			containers[nodename]++
+		case core.MetricSetTypePod:
+			pods[nodename]++
+		}
+	}
+
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypeNode {
+			continue
+		}
+		nodename := metricSet.Labels[core.LabelNodename.Key]
+		metricSet.MetricValues[core.MetricNodeContainerCount.Name] = intValue(containers[nodename])
+		metricSet.MetricValues[core.MetricNodePodCount.Name] = intValue(pods[nodename])
+	}
+	return batch, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func densityMetricSet(metricSetType, nodename string) *core.MetricSet {
+	metricSet := &core.MetricSet{
+		Labels:       map[string]string{core.LabelMetricSetType.Key: metricSetType},
+		MetricValues: map[string]core.MetricValue{},
+	}
+	if nodename != "" {
+		metricSet.Labels[core.LabelNodename.Key] = nodename
+	}
+	return metricSet
+}
+
+func TestNodeDensityCalculator(t *testing.T) {
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NodeKey("node1"):                       densityMetricSet(core.MetricSetTypeNode, "node1"),
+			core.NodeKey("node2"):                       densityMetricSet(core.MetricSetTypeNode, "node2"),
+			core.NodeKey("empty"):                       densityMetricSet(core.MetricSetTypeNode, "empty"),
+			core.PodKey("ns1", "pod1"):                  densityMetricSet(core.MetricSetTypePod, "node1"),
+			core.PodContainerKey("ns1", "pod1", "c1"):   densityMetricSet(core.MetricSetTypePodContainer, "node1"),
+			core.PodContainerKey("ns1", "pod1", "c2"):   densityMetricSet(core.MetricSetTypePodContainer, "node1"),
+			core.PodKey("ns1", "pod2"):                  densityMetricSet(core.MetricSetTypePod, "node1"),
+			core.PodContainerKey("ns1", "pod2", "c1"):   densityMetricSet(core.MetricSetTypePodContainer, "node1"),
+			core.PodKey("ns2", "pod3"):                  densityMetricSet(core.MetricSetTypePod, "node2"),
+			core.PodContainerKey("ns2", "pod3", "c1"):   densityMetricSet(core.MetricSetTypePodContainer, "node2"),
+			core.NodeContainerKey("node2", "kubelet"):   densityMetricSet(core.MetricSetTypeSystemContainer, "node2"),
+			core.PodKey("ns2", "pending"):               densityMetricSet(core.MetricSetTypePod, ""),
+			core.PodContainerKey("ns2", "pending", "c"): densityMetricSet(core.MetricSetTypePodContainer, ""),
+			core.NamespaceKey("ns1"):                    densityMetricSet(core.MetricSetTypeNamespace, ""),
+		},
+	}
+	batch, err := (&NodeDensityCalculator{}).Process(batch)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		node       string
+		containers int64
+		pods       int64
+	}{
+		{"node1", 3, 2},
+		// System containers are not counted.
+		{"node2", 1, 1},
+		{"empty", 0, 0},
+	} {
+		node := batch.MetricSets[core.NodeKey(tc.node)]
+		assert.Equal(t, intValue(tc.containers), node.MetricValues[core.MetricNodeContainerCount.Name], tc.node)
+		assert.Equal(t, intValue(tc.pods), node.MetricValues[core.MetricNodePodCount.Name], tc.node)
+	}
+	_, found := batch.MetricSets[core.NamespaceKey("ns1")].MetricValues[core.MetricNodePodCount.Name]
+	assert.False(t, found)
+}
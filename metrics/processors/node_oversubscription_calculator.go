@@ -0,0 +1,51 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// NodeOversubscriptionCalculator computes the sum of the limits of the pods of every node
+// divided by the allocatable resources of the node, above 1 for an oversubscribed node.
+// The limits are set on the pods from the pod lister by the pod based enricher and summed
+// up per node by the node aggregator, and the allocatable resources are set by the node
+// autoscaling enricher, so it has to run after them. Nodes without allocatable resources
+// are skipped.
+type NodeOversubscriptionCalculator struct {
+}
+
+func (this *NodeOversubscriptionCalculator) Name() string {
+	return "node_oversubscription_calculator"
+}
+
+func (this *NodeOversubscriptionCalculator) Stateless() {}
+
+func (this *NodeOversubscriptionCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypeNode {
+			continue
+		}
+		if allocatable, found := metricSet.MetricValues[core.MetricNodeCpuAllocatable.Name]; found && allocatable.FloatValue > 0 {
+			limit := getInt(metricSet, &core.MetricCpuLimit)
+			setFloat(metricSet, &core.MetricNodeCpuLimitOversubscription, float32(limit)/allocatable.FloatValue)
+		}
+		if allocatable, found := metricSet.MetricValues[core.MetricNodeMemoryAllocatable.Name]; found && allocatable.FloatValue > 0 {
+			limit := getInt(metricSet, &core.MetricMemoryLimit)
+			setFloat(metricSet, &core.MetricNodeMemoryLimitOversubscription, float32(limit)/allocatable.FloatValue)
+		}
+	}
+	return batch, nil
+}
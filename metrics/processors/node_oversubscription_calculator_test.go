@@ -0,0 +1,70 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func oversubscriptionNode(cpuLimit, memoryLimit int64, cpuAllocatable, memoryAllocatable float32) *core.MetricSet {
+	metricSet := &core.MetricSet{
+		Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNode},
+		MetricValues: map[string]core.MetricValue{},
+	}
+	if cpuLimit >= 0 {
+		metricSet.MetricValues[core.MetricCpuLimit.Name] = intValue(cpuLimit)
+	}
+	if memoryLimit >= 0 {
+		metricSet.MetricValues[core.MetricMemoryLimit.Name] = intValue(memoryLimit)
+	}
+	setFloat(metricSet, &core.MetricNodeCpuAllocatable, cpuAllocatable)
+	setFloat(metricSet, &core.MetricNodeMemoryAllocatable, memoryAllocatable)
+	return metricSet
+}
+
+func TestNodeOversubscriptionCalculator(t *testing.T) {
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NodeKey("oversubscribed"):  oversubscriptionNode(6000, 12*mi, 4000, 8*mi),
+			core.NodeKey("undersubscribed"): oversubscriptionNode(1000, 2*mi, 4000, 8*mi),
+			// No pod with a limit on the node.
+			core.NodeKey("empty"):   oversubscriptionNode(-1, -1, 4000, 8*mi),
+			core.NodeKey("unknown"): oversubscriptionNode(1000, 2*mi, 0, 0),
+		},
+	}
+	batch, err := (&NodeOversubscriptionCalculator{}).Process(batch)
+	require.NoError(t, err)
+
+	for node, expected := range map[string][2]float32{
+		"oversubscribed":  {1.5, 1.5},
+		"undersubscribed": {0.25, 0.25},
+		"empty":           {0, 0},
+	} {
+		metricSet := batch.MetricSets[core.NodeKey(node)]
+		assert.InDelta(t, expected[0], metricSet.MetricValues[core.MetricNodeCpuLimitOversubscription.Name].FloatValue, 1e-6, node)
+		assert.InDelta(t, expected[1], metricSet.MetricValues[core.MetricNodeMemoryLimitOversubscription.Name].FloatValue, 1e-6, node)
+	}
+
+	unknown := batch.MetricSets[core.NodeKey("unknown")]
+	assert.NotContains(t, unknown.MetricValues, core.MetricNodeCpuLimitOversubscription.Name)
+	assert.NotContains(t, unknown.MetricValues, core.MetricNodeMemoryLimitOversubscription.Name)
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/heapster/metrics/core"
+)
+
+// EvictionThreshold is the minimum available amount of a resource, given either in bytes
+// or as a share of the capacity, like the eviction thresholds of the kubelet.
+type EvictionThreshold struct {
+	Bytes int64
+	// Share of the capacity in [0, 1], used if positive.
+	Share float64
+}
+
+// ParseEvictionThreshold parses a threshold given as a quantity, e.g. 100Mi, or as a
+// percentage of the capacity, e.g. 10%.
+func ParseEvictionThreshold(spec string) (EvictionThreshold, error) {
+	if strings.HasSuffix(spec, "%") {
+		percentage, err := strconv.ParseFloat(strings.TrimSuffix(spec, "%"), 64)
+		if err != nil {
+			return EvictionThreshold{}, fmt.Errorf("invalid eviction threshold %q - %v", spec, err)
+		}
+		if percentage <= 0 || percentage > 100 {
+			return EvictionThreshold{}, fmt.Errorf("eviction threshold %q has to be in (0%%, 100%%]", spec)
+		}
+		return EvictionThreshold{Share: percentage / 100}, nil
+	}
+	quantity, err := resource.ParseQuantity(spec)
+	if err != nil {
+		return EvictionThreshold{}, fmt.Errorf("invalid eviction threshold %q - %v", spec, err)
+	}
+	if quantity.Sign() <= 0 {
+		return EvictionThreshold{}, fmt.Errorf("eviction threshold %q has to be positive", spec)
+	}
+	return EvictionThreshold{Bytes: quantity.Value()}, nil
+}
+
+// below returns whether the available amount is below the threshold.
+func (this EvictionThreshold) below(available, capacity int64) bool {
+	if this.Share > 0 {
+		return float64(available) < this.Share*float64(capacity)
+	}
+	return available < this.Bytes
+}
+
+// NodePressureCalculator flags the nodes about to be under memory or disk pressure, by
+// comparing their available memory and root filesystem space with the eviction thresholds
+// of the kubelet. A node is also flagged when the kubelet already reports the condition.
+type NodePressureCalculator struct {
+	nodeLister      v1listers.NodeLister
+	memoryThreshold EvictionThreshold
+	diskThreshold   EvictionThreshold
+}
+
+func (this *NodePressureCalculator) Name() string {
+	return "node_pressure_calculator"
+}
+
+func (this *NodePressureCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	nodes, err := this.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	nodesByName := make(map[string]*kube_api.Node, len(nodes))
+	for _, node := range nodes {
+		nodesByName[node.Name] = node
+	}
+
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypeNode {
+			continue
+		}
+		node := nodesByName[metricSet.Labels[core.LabelNodename.Key]]
+
+		memoryPressure := hasCondition(node, kube_api.NodeMemoryPressure)
+		// Like the kubelet, the available memory is the capacity minus the working set.
+		if workingSet, found := metricSet.MetricValues[core.MetricMemoryWorkingSet.Name]; found {
+			capacity := getInt(metricSet, &core.MetricMemoryLimit)
+			if node != nil {
+				if nodeCapacity, found := node.Status.Capacity[kube_api.ResourceMemory]; found {
+					capacity = nodeCapacity.Value()
+				}
+			}
+			if capacity > 0 && this.memoryThreshold.below(capacity-workingSet.IntValue, capacity) {
+				memoryPressure = true
+			}
+		}
+		setPressure(metricSet, &core.MetricNodeMemoryPressure, memoryPressure)
+
+		diskPressure := hasCondition(node, kube_api.NodeDiskPressure)
+		capacity := getInt(metricSet, &core.MetricNodeFsLimit)
+		if usage, found := metricSet.MetricValues[core.MetricNodeFsUsage.Name]; found && capacity > 0 {
+			if this.diskThreshold.below(capacity-usage.IntValue, capacity) {
+				diskPressure = true
+			}
+		}
+		setPressure(metricSet, &core.MetricNodeDiskPressure, diskPressure)
+	}
+	return batch, nil
+}
+
+func hasCondition(node *kube_api.Node, conditionType kube_api.NodeConditionType) bool {
+	if node == nil {
+		return false
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == kube_api.ConditionTrue
+		}
+	}
+	return false
+}
+
+func setPressure(metricSet *core.MetricSet, metric *core.Metric, pressure bool) {
+	value := int64(0)
+	if pressure {
+		value = 1
+	}
+	metricSet.MetricValues[metric.Name] = intValue(value)
+}
+
+func NewNodePressureCalculator(nodeLister v1listers.NodeLister, memoryThreshold, diskThreshold EvictionThreshold) *NodePressureCalculator {
+	return &NodePressureCalculator{
+		nodeLister:      nodeLister,
+		memoryThreshold: memoryThreshold,
+		diskThreshold:   diskThreshold,
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+const mi = 1024 * 1024
+
+func pressureNode(name string, memoryCapacity int64, conditions ...kube_api.NodeConditionType) *kube_api.Node {
+	node := &kube_api.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: kube_api.NodeStatus{
+			Capacity: kube_api.ResourceList{
+				kube_api.ResourceMemory: *resource.NewQuantity(memoryCapacity, resource.BinarySI),
+			},
+		},
+	}
+	for _, condition := range conditions {
+		node.Status.Conditions = append(node.Status.Conditions, kube_api.NodeCondition{
+			Type:   condition,
+			Status: kube_api.ConditionTrue,
+		})
+	}
+	return node
+}
+
+func pressureMetricSet(nodeName string, workingSet, fsUsage, fsLimit int64) *core.MetricSet {
+	return &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypeNode,
+			core.LabelNodename.Key:      nodeName,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricMemoryWorkingSet.Name: intValue(workingSet),
+			core.MetricNodeFsUsage.Name:      intValue(fsUsage),
+			core.MetricNodeFsLimit.Name:      intValue(fsLimit),
+		},
+	}
+}
+
+func pressure(t *testing.T, calculator *NodePressureCalculator, metricSet *core.MetricSet) (int64, int64) {
+	batch := &core.DataBatch{MetricSets: map[string]*core.MetricSet{"node:n1": metricSet}}
+	_, err := calculator.Process(batch)
+	require.NoError(t, err)
+	return metricSet.MetricValues[core.MetricNodeMemoryPressure.Name].IntValue,
+		metricSet.MetricValues[core.MetricNodeDiskPressure.Name].IntValue
+}
+
+func TestParseEvictionThreshold(t *testing.T) {
+	threshold, err := ParseEvictionThreshold("100Mi")
+	require.NoError(t, err)
+	assert.Equal(t, EvictionThreshold{Bytes: 100 * mi}, threshold)
+
+	threshold, err = ParseEvictionThreshold("10%")
+	require.NoError(t, err)
+	assert.Equal(t, EvictionThreshold{Share: 0.1}, threshold)
+
+	for _, spec := range []string{"", "abc", "0", "-1Gi", "0%", "101%", "x%"} {
+		_, err := ParseEvictionThreshold(spec)
+		assert.Error(t, err, "spec %q", spec)
+	}
+}
+
+func TestNodeMemoryPressureCrossingThreshold(t *testing.T) {
+	calculator := NewNodePressureCalculator(topologyNodeLister(pressureNode("n1", 1000*mi)),
+		EvictionThreshold{Bytes: 100 * mi}, EvictionThreshold{Share: 0.1})
+
+	memory, _ := pressure(t, calculator, pressureMetricSet("n1", 899*mi, 0, 100))
+	assert.Equal(t, int64(0), memory)
+
+	memory, _ = pressure(t, calculator, pressureMetricSet("n1", 901*mi, 0, 100))
+	assert.Equal(t, int64(1), memory)
+}
+
+func TestNodeDiskPressureCrossingThreshold(t *testing.T) {
+	calculator := NewNodePressureCalculator(topologyNodeLister(pressureNode("n1", 1000*mi)),
+		EvictionThreshold{Bytes: 100 * mi}, EvictionThreshold{Share: 0.1})
+
+	_, disk := pressure(t, calculator, pressureMetricSet("n1", 0, 89, 100))
+	assert.Equal(t, int64(0), disk)
+
+	_, disk = pressure(t, calculator, pressureMetricSet("n1", 0, 91, 100))
+	assert.Equal(t, int64(1), disk)
+}
+
+func TestNodePressureFromConditions(t *testing.T) {
+	node := pressureNode("n1", 1000*mi, kube_api.NodeMemoryPressure, kube_api.NodeDiskPressure)
+	calculator := NewNodePressureCalculator(topologyNodeLister(node),
+		EvictionThreshold{Bytes: 100 * mi}, EvictionThreshold{Share: 0.1})
+
+	memory, disk := pressure(t, calculator, pressureMetricSet("n1", 0, 0, 100))
+	assert.Equal(t, int64(1), memory)
+	assert.Equal(t, int64(1), disk)
+}
+
+func TestNodePressureWithoutNode(t *testing.T) {
+	calculator := NewNodePressureCalculator(topologyNodeLister(),
+		EvictionThreshold{Bytes: 100 * mi}, EvictionThreshold{Share: 0.1})
+
+	// The machine memory reported by cadvisor is used as capacity.
+	metricSet := pressureMetricSet("n1", 950*mi, 0, 100)
+	metricSet.MetricValues[core.MetricMemoryLimit.Name] = intValue(1000 * mi)
+	memory, disk := pressure(t, calculator, metricSet)
+	assert.Equal(t, int64(1), memory)
+	assert.Equal(t, int64(0), disk)
+
+	// Without capacity, the node is not flagged.
+	memory, _ = pressure(t, calculator, pressureMetricSet("n1", 950*mi, 0, 100))
+	assert.Equal(t, int64(0), memory)
+}
@@ -0,0 +1,89 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// OOMRiskCalculator emits the memory working set of every pod container as a share of
+// its memory limit, and flags the containers that stayed above the threshold for the
+// whole window as likely OOM candidates. It has to run after the pod based enricher,
+// which provides the memory limits.
+type OOMRiskCalculator struct {
+	threshold float32
+	window    time.Duration
+	// Time since which the risk of every container has been above the threshold.
+	aboveSince map[string]time.Time
+}
+
+func (this *OOMRiskCalculator) Name() string {
+	return "oom_risk_calculator"
+}
+
+func (this *OOMRiskCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	seen := make(map[string]struct{})
+	for key, metricSet := range batch.MetricSets {
+		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypePodContainer {
+			continue
+		}
+		workingSet, found := metricSet.MetricValues[core.MetricMemoryWorkingSet.Name]
+		if !found {
+			continue
+		}
+
+		risk := float32(0)
+		// A missing or zero limit means the container is unbounded.
+		if limit, found := metricSet.MetricValues[core.MetricMemoryLimit.Name]; found && limit.IntValue > 0 {
+			risk = float32(workingSet.IntValue) / float32(limit.IntValue)
+		}
+		setFloat(metricSet, &core.MetricContainerOOMRisk, risk)
+
+		now := metricSet.ScrapeTime
+		if now.IsZero() {
+			now = batch.Timestamp
+		}
+		sustained := int64(0)
+		if risk > this.threshold {
+			seen[key] = struct{}{}
+			since, found := this.aboveSince[key]
+			if !found {
+				since = now
+				this.aboveSince[key] = since
+			}
+			if now.Sub(since) >= this.window {
+				sustained = 1
+			}
+		}
+		metricSet.MetricValues[core.MetricContainerOOMRiskSustained.Name] = intValue(sustained)
+	}
+
+	for key := range this.aboveSince {
+		if _, found := seen[key]; !found {
+			delete(this.aboveSince, key)
+		}
+	}
+	return batch, nil
+}
+
+func NewOOMRiskCalculator(threshold float32, window time.Duration) *OOMRiskCalculator {
+	return &OOMRiskCalculator{
+		threshold:  threshold,
+		window:     window,
+		aboveSince: make(map[string]time.Time),
+	}
+}
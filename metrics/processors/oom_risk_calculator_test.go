@@ -0,0 +1,72 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func oomRiskBatch(timestamp time.Time, workingSet, limit int64) *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "c1"): {
+				ScrapeTime: timestamp,
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryWorkingSet.Name: intValue(workingSet),
+					core.MetricMemoryLimit.Name:      intValue(limit),
+				},
+			},
+		},
+	}
+}
+
+func TestOOMRiskCalculator(t *testing.T) {
+	calculator := NewOOMRiskCalculator(0.9, 2*time.Minute)
+	start := time.Now()
+	for i, tc := range []struct {
+		offset     time.Duration
+		workingSet int64
+		limit      int64
+		risk       float32
+		sustained  int64
+	}{
+		{offset: 0, workingSet: 500, limit: 1000, risk: 0.5, sustained: 0},
+		{offset: time.Minute, workingSet: 950, limit: 1000, risk: 0.95, sustained: 0},
+		{offset: 2 * time.Minute, workingSet: 960, limit: 1000, risk: 0.96, sustained: 0},
+		// Above the threshold for the whole window.
+		{offset: 3 * time.Minute, workingSet: 990, limit: 1000, risk: 0.99, sustained: 1},
+		// Dropping below the threshold resets the window.
+		{offset: 4 * time.Minute, workingSet: 800, limit: 1000, risk: 0.8, sustained: 0},
+		{offset: 5 * time.Minute, workingSet: 950, limit: 1000, risk: 0.95, sustained: 0},
+		// Unbounded container.
+		{offset: 8 * time.Minute, workingSet: 950, limit: 0, risk: 0, sustained: 0},
+	} {
+		batch, err := calculator.Process(oomRiskBatch(start.Add(tc.offset), tc.workingSet, tc.limit))
+		assert.NoError(t, err)
+		metricSet := batch.MetricSets[core.PodContainerKey("ns1", "pod1", "c1")]
+		assert.InDelta(t, tc.risk, metricSet.MetricValues[core.MetricContainerOOMRisk.Name].FloatValue, 0.0001, "step %d", i)
+		assert.Equal(t, tc.sustained, metricSet.MetricValues[core.MetricContainerOOMRiskSustained.Name].IntValue, "step %d", i)
+	}
+	assert.Empty(t, calculator.aboveSince)
+}
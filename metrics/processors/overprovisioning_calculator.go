@@ -0,0 +1,70 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// OverprovisioningCalculator flags the pod containers whose peak CPU usage stays below
+// Threshold times their CPU request, e.g. 0.1 for the containers never using more than
+// a tenth of their request, and emits the CPU cores requested beyond the peak usage as
+// waste. The waste of the containers is summed up on their namespaces and workloads.
+// Containers without a request or without a peak are skipped. It has to run after the
+// peak usage calculator and after the namespace and workload aggregators.
+type OverprovisioningCalculator struct {
+	Threshold float64
+}
+
+func (this *OverprovisioningCalculator) Name() string {
+	return "overprovisioning_calculator"
+}
+
+func (this *OverprovisioningCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	// Waste in millicores by namespace or workload key.
+	waste := make(map[string]int64)
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer {
+			continue
+		}
+		peak, found := metricSet.MetricValues[core.MetricContainerCpuUsagePeak.Name]
+		request, found2 := metricSet.MetricValues[core.MetricCpuRequest.Name]
+		if !found || !found2 || request.IntValue <= 0 {
+			continue
+		}
+		overprovisioned := int64(0)
+		if float64(peak.IntValue) < this.Threshold*float64(request.IntValue) {
+			overprovisioned = 1
+		}
+		metricSet.MetricValues[core.MetricContainerOverprovisioned.Name] = intValue(overprovisioned)
+
+		containerWaste := request.IntValue - peak.IntValue
+		if containerWaste < 0 {
+			containerWaste = 0
+		}
+		setFloat(metricSet, &core.MetricContainerWasteCpuCores, float32(containerWaste)/1000)
+		waste[core.NamespaceKey(metricSet.Labels[core.LabelNamespaceName.Key])] += containerWaste
+		if workloadKey := containerWorkloadKey(batch, metricSet); workloadKey != "" {
+			waste[workloadKey] += containerWaste
+		}
+	}
+
+	for key, millicores := range waste {
+		if aggregate, found := batch.MetricSets[key]; found {
+			setFloat(aggregate, &core.MetricContainerWasteCpuCores, float32(millicores)/1000)
+		}
+	}
+	return batch, nil
+}
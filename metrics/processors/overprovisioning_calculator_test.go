@@ -0,0 +1,96 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func overprovisioningContainer(pod string, request, peak int64) *core.MetricSet {
+	metricSet := &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			core.LabelNamespaceName.Key: "ns1",
+			core.LabelPodName.Key:       pod,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuRequest.Name: intValue(request),
+		},
+	}
+	if peak >= 0 {
+		metricSet.MetricValues[core.MetricContainerCpuUsagePeak.Name] = intValue(peak)
+	}
+	return metricSet
+}
+
+func TestOverprovisioningCalculator(t *testing.T) {
+	workloadKey := core.WorkloadKey("ns1", "Deployment", "web")
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			// Idle.
+			core.PodContainerKey("ns1", "web-1", "app"): overprovisioningContainer("web-1", 2000, 50),
+			// Busy, above its request.
+			core.PodContainerKey("ns1", "web-2", "app"): overprovisioningContainer("web-2", 500, 700),
+			// Using a fifth of its request.
+			core.PodContainerKey("ns1", "batch", "job"): overprovisioningContainer("batch", 1000, 200),
+			// No request or no peak yet.
+			core.PodContainerKey("ns1", "batch", "sidecar"): overprovisioningContainer("batch", 0, 10),
+			core.PodContainerKey("ns1", "web-1", "sidecar"): overprovisioningContainer("web-1", 100, -1),
+			core.PodKey("ns1", "web-1"):                     efficiencyPod("web-1", "web"),
+			core.PodKey("ns1", "web-2"):                     efficiencyPod("web-2", "web"),
+			core.PodKey("ns1", "batch"):                     efficiencyPod("batch", ""),
+			workloadKey: {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeWorkload},
+				MetricValues: map[string]core.MetricValue{},
+			},
+			core.NamespaceKey("ns1"): {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNamespace},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	}
+	batch, err := (&OverprovisioningCalculator{Threshold: 0.1}).Process(batch)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		key             string
+		overprovisioned int64
+		waste           float32
+	}{
+		{core.PodContainerKey("ns1", "web-1", "app"), 1, 1.95},
+		{core.PodContainerKey("ns1", "web-2", "app"), 0, 0},
+		{core.PodContainerKey("ns1", "batch", "job"), 0, 0.8},
+	} {
+		metricSet := batch.MetricSets[tc.key]
+		assert.Equal(t, tc.overprovisioned, metricSet.MetricValues[core.MetricContainerOverprovisioned.Name].IntValue, tc.key)
+		assert.InDelta(t, tc.waste, metricSet.MetricValues[core.MetricContainerWasteCpuCores.Name].FloatValue, 0.001, tc.key)
+	}
+	for _, key := range []string{core.PodContainerKey("ns1", "batch", "sidecar"), core.PodContainerKey("ns1", "web-1", "sidecar")} {
+		_, found := batch.MetricSets[key].MetricValues[core.MetricContainerOverprovisioned.Name]
+		assert.False(t, found, key)
+		_, found = batch.MetricSets[key].MetricValues[core.MetricContainerWasteCpuCores.Name]
+		assert.False(t, found, key)
+	}
+
+	assert.InDelta(t, 1.95, batch.MetricSets[workloadKey].MetricValues[core.MetricContainerWasteCpuCores.Name].FloatValue, 0.001)
+	assert.InDelta(t, 2.75, batch.MetricSets[core.NamespaceKey("ns1")].MetricValues[core.MetricContainerWasteCpuCores.Name].FloatValue, 0.001)
+}
@@ -0,0 +1,125 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// Metrics of which the peak is tracked, mapped to the emitted peak metrics.
+var peakMetrics = map[string]*core.Metric{
+	core.MetricCpuUsageRate.Name:     &core.MetricContainerCpuUsagePeak,
+	core.MetricMemoryWorkingSet.Name: &core.MetricContainerMemoryWorkingSetPeak,
+}
+
+type peakSample struct {
+	timestamp time.Time
+	value     int64
+}
+
+// peakWindow is a monotonic deque of the samples within the window. Every sample is
+// larger than the ones after it, as a sample followed by a larger one can never be the
+// peak again. The peak is the first sample, and each sample is added and dropped once.
+type peakWindow struct {
+	samples []peakSample
+}
+
+func (this *peakWindow) add(timestamp time.Time, value int64) {
+	i := len(this.samples)
+	for i > 0 && this.samples[i-1].value <= value {
+		i--
+	}
+	this.samples = append(this.samples[:i], peakSample{timestamp: timestamp, value: value})
+}
+
+// expire drops the samples taken at or before the given time.
+func (this *peakWindow) expire(before time.Time) {
+	i := 0
+	for i < len(this.samples) && !this.samples[i].timestamp.After(before) {
+		i++
+	}
+	this.samples = this.samples[i:]
+}
+
+func (this *peakWindow) peak() int64 {
+	return this.samples[0].value
+}
+
+// PeakUsageCalculator emits the maximum CPU usage rate and memory working set of every
+// pod container over the window ending with the current batch. It has to run after the
+// rate calculator, which provides the CPU usage rate.
+type PeakUsageCalculator struct {
+	window time.Duration
+	// Samples by container key and metric name.
+	windows map[string]map[string]*peakWindow
+}
+
+func (this *PeakUsageCalculator) Name() string {
+	return "peak_usage_calculator"
+}
+
+func (this *PeakUsageCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for key, metricSet := range batch.MetricSets {
+		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypePodContainer {
+			continue
+		}
+		now := metricSet.ScrapeTime
+		if now.IsZero() {
+			now = batch.Timestamp
+		}
+		for metricName, peakMetric := range peakMetrics {
+			value, found := metricSet.MetricValues[metricName]
+			if !found {
+				continue
+			}
+			windows, found := this.windows[key]
+			if !found {
+				windows = make(map[string]*peakWindow)
+				this.windows[key] = windows
+			}
+			window, found := windows[metricName]
+			if !found {
+				window = &peakWindow{}
+				windows[metricName] = window
+			}
+			window.add(now, value.IntValue)
+			window.expire(now.Add(-this.window))
+			metricSet.MetricValues[peakMetric.Name] = intValue(window.peak())
+		}
+	}
+
+	// Forget the containers without samples within the window, e.g. deleted ones.
+	for key, windows := range this.windows {
+		for metricName, window := range windows {
+			window.expire(batch.Timestamp.Add(-this.window))
+			if len(window.samples) == 0 {
+				delete(windows, metricName)
+			}
+		}
+		if len(windows) == 0 {
+			delete(this.windows, key)
+		}
+	}
+	return batch, nil
+}
+
+func NewPeakUsageCalculator(window time.Duration) *PeakUsageCalculator {
+	return &PeakUsageCalculator{
+		window:  window,
+		windows: make(map[string]map[string]*peakWindow),
+	}
+}
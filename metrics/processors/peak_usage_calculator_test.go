@@ -0,0 +1,101 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func peakUsageBatch(timestamp time.Time, containers map[string][2]int64) *core.DataBatch {
+	batch := &core.DataBatch{
+		Timestamp:  timestamp,
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	for container, usage := range containers {
+		batch.MetricSets[core.PodContainerKey("ns1", "pod1", container)] = &core.MetricSet{
+			ScrapeTime: timestamp,
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricCpuUsageRate.Name:     intValue(usage[0]),
+				core.MetricMemoryWorkingSet.Name: intValue(usage[1]),
+			},
+		}
+	}
+	return batch
+}
+
+func TestPeakUsageCalculatorSlidingWindow(t *testing.T) {
+	calculator := NewPeakUsageCalculator(3 * time.Minute)
+	key := core.PodContainerKey("ns1", "pod1", "app")
+	start := time.Now().Truncate(time.Minute)
+
+	// CPU usage rate and memory working set scraped every minute, with the expected peaks
+	// over the last three minutes.
+	for i, step := range []struct {
+		cpu, memory         int64
+		cpuPeak, memoryPeak int64
+	}{
+		{cpu: 100, memory: 500, cpuPeak: 100, memoryPeak: 500},
+		{cpu: 300, memory: 400, cpuPeak: 300, memoryPeak: 500},
+		{cpu: 200, memory: 300, cpuPeak: 300, memoryPeak: 500},
+		// The first sample left the window.
+		{cpu: 100, memory: 200, cpuPeak: 300, memoryPeak: 400},
+		// The peak left the window, the next largest sample is the new peak.
+		{cpu: 50, memory: 100, cpuPeak: 200, memoryPeak: 300},
+		{cpu: 150, memory: 600, cpuPeak: 150, memoryPeak: 600},
+		{cpu: 10, memory: 10, cpuPeak: 150, memoryPeak: 600},
+	} {
+		batch, err := calculator.Process(peakUsageBatch(start.Add(time.Duration(i)*time.Minute), map[string][2]int64{
+			"app": {step.cpu, step.memory},
+		}))
+		require.NoError(t, err)
+		metricSet := batch.MetricSets[key]
+		assert.Equal(t, step.cpuPeak, metricSet.MetricValues[core.MetricContainerCpuUsagePeak.Name].IntValue, "cpu peak at step %d", i)
+		assert.Equal(t, step.memoryPeak, metricSet.MetricValues[core.MetricContainerMemoryWorkingSetPeak.Name].IntValue, "memory peak at step %d", i)
+		assert.Equal(t, core.MetricGauge, metricSet.MetricValues[core.MetricContainerCpuUsagePeak.Name].MetricType)
+		// Only the samples that can still become the peak are kept.
+		assert.True(t, len(calculator.windows[key][core.MetricCpuUsageRate.Name].samples) <= 3)
+	}
+}
+
+func TestPeakUsageCalculatorForgetsContainers(t *testing.T) {
+	calculator := NewPeakUsageCalculator(2 * time.Minute)
+	start := time.Now()
+
+	_, err := calculator.Process(peakUsageBatch(start, map[string][2]int64{"app": {100, 100}, "sidecar": {10, 10}}))
+	require.NoError(t, err)
+	assert.Len(t, calculator.windows, 2)
+
+	// The sidecar is missing for a scrape, its peak is kept within the window.
+	_, err = calculator.Process(peakUsageBatch(start.Add(time.Minute), map[string][2]int64{"app": {100, 100}}))
+	require.NoError(t, err)
+	assert.Len(t, calculator.windows, 2)
+	batch, err := calculator.Process(peakUsageBatch(start.Add(90*time.Second), map[string][2]int64{"app": {100, 100}, "sidecar": {5, 5}}))
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), batch.MetricSets[core.PodContainerKey("ns1", "pod1", "sidecar")].MetricValues[core.MetricContainerCpuUsagePeak.Name].IntValue)
+
+	_, err = calculator.Process(peakUsageBatch(start.Add(4*time.Minute), map[string][2]int64{"app": {100, 100}}))
+	require.NoError(t, err)
+	assert.Len(t, calculator.windows, 1)
+	assert.Contains(t, calculator.windows, core.PodContainerKey("ns1", "pod1", "app"))
+}
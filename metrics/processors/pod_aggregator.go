@@ -29,6 +29,8 @@ var LabelsToPopulate = []core.LabelDescriptor{
 	core.LabelPodNamespaceUID,
 	core.LabelHostname,
 	core.LabelHostID,
+	core.LabelZone,
+	core.LabelRegion,
 }
 
 type PodAggregator struct {
@@ -61,7 +63,7 @@ func (this *PodAggregator) Process(batch *core.DataBatch) (*core.DataBatch, erro
 			pod, found = newPods[podKey]
 			if !found {
 				glog.V(2).Infof("Pod not found adding %s", podKey)
-				pod = this.podMetricSet(metricSet.Labels)
+				pod = podMetricSet(metricSet.Labels)
 				newPods[podKey] = pod
 			}
 		}
@@ -98,7 +100,7 @@ func (this *PodAggregator) Process(batch *core.DataBatch) (*core.DataBatch, erro
 	return batch, nil
 }
 
-func (this *PodAggregator) podMetricSet(labels map[string]string) *core.MetricSet {
+func podMetricSet(labels map[string]string) *core.MetricSet {
 	newLabels := map[string]string{
 		core.LabelMetricSetType.Key: core.MetricSetTypePod,
 	}
@@ -16,6 +16,8 @@ package processors
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/golang/glog"
 
@@ -26,6 +28,12 @@ import (
 	"k8s.io/heapster/metrics/core"
 )
 
+// Local ephemeral storage, not known to the vendored API yet.
+const resourceEphemeralStorage kube_api.ResourceName = "ephemeral-storage"
+
+// Label set by the Deployment controller on the pods of its ReplicaSets.
+const podTemplateHashLabel = "pod-template-hash"
+
 type PodBasedEnricher struct {
 	podLister   v1listers.PodLister
 	labelCopier *util.LabelCopier
@@ -137,6 +145,10 @@ func (this *PodBasedEnricher) addPodInfo(key string, podMs *core.MetricSet, pod
 		podMs.EntityCreateTime = pod.Status.StartTime.Time
 	}
 	this.labelCopier.Copy(pod.Labels, podMs.Labels)
+	if kind, name := podWorkload(pod); kind != "" {
+		podMs.Labels[core.LabelWorkloadKind.Key] = kind
+		podMs.Labels[core.LabelWorkloadName.Key] = name
+	}
 
 	// Add cpu/mem requests and limits to containers
 	for _, container := range pod.Spec.Containers {
@@ -169,6 +181,26 @@ func (this *PodBasedEnricher) addPodInfo(key string, podMs *core.MetricSet, pod
 	}
 }
 
+// podWorkload returns the kind and name of the workload controlling the pod, as given by
+// its controller owner reference. Pods of a ReplicaSet created by a Deployment are
+// attributed to the Deployment, whose name is the ReplicaSet name without the
+// pod-template-hash suffix.
+func podWorkload(pod *kube_api.Pod) (string, string) {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			continue
+		}
+		if owner.Kind == "ReplicaSet" {
+			hash := pod.Labels[podTemplateHashLabel]
+			if hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+				return "Deployment", strings.TrimSuffix(owner.Name, "-"+hash)
+			}
+		}
+		return owner.Kind, owner.Name
+	}
+	return "", ""
+}
+
 func updateContainerResourcesAndLimits(metricSet *core.MetricSet, container kube_api.Container) {
 	requests := container.Resources.Requests
 	if val, found := requests[kube_api.ResourceCPU]; found {
@@ -193,6 +225,31 @@ func updateContainerResourcesAndLimits(metricSet *core.MetricSet, container kube
 	} else {
 		metricSet.MetricValues[core.MetricMemoryLimit.Name] = intValue(0)
 	}
+
+	addExtendedResources(metricSet, &core.MetricContainerExtendedResourceRequest, requests)
+	addExtendedResources(metricSet, &core.MetricContainerExtendedResourceLimit, limits)
+}
+
+// addExtendedResources adds a labeled metric per resource of the list other than CPU,
+// memory and storage, e.g. GPUs exposed by a device plugin or hugepages.
+func addExtendedResources(metricSet *core.MetricSet, metric *core.Metric, resources kube_api.ResourceList) {
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		switch name {
+		case kube_api.ResourceCPU, kube_api.ResourceMemory, kube_api.ResourceStorage, resourceEphemeralStorage:
+			continue
+		}
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		quantity := resources[kube_api.ResourceName(name)]
+		metricSet.LabeledMetrics = append(metricSet.LabeledMetrics, core.LabeledMetric{
+			Name:        metric.Name,
+			Labels:      map[string]string{core.LabelResourceName.Key: name},
+			MetricValue: intValue(quantity.Value()),
+		})
+	}
 }
 
 func intValue(value int64) core.MetricValue {
@@ -170,3 +170,201 @@ func checkLimits(t *testing.T, ms *core.MetricSet, cpu, mem int64) {
 	assert.True(t, found)
 	assert.Equal(t, mem, memVal.IntValue)
 }
+
+func TestPodEnricherExtendedResources(t *testing.T) {
+	pod := kube_api.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "trainer",
+			Namespace: "ns1",
+		},
+		Spec: kube_api.PodSpec{
+			Containers: []kube_api.Container{
+				{
+					Name: "train",
+					Resources: kube_api.ResourceRequirements{
+						Requests: kube_api.ResourceList{
+							kube_api.ResourceCPU:     *resource.NewMilliQuantity(100, resource.DecimalSI),
+							"nvidia.com/gpu":         *resource.NewQuantity(2, resource.DecimalSI),
+							"hugepages-2Mi":          *resource.NewQuantity(4*2*1024*1024, resource.BinarySI),
+							resourceEphemeralStorage: *resource.NewQuantity(1024, resource.BinarySI),
+						},
+						Limits: kube_api.ResourceList{
+							kube_api.ResourceMemory: *resource.NewQuantity(3333, resource.DecimalSI),
+							"nvidia.com/gpu":        *resource.NewQuantity(2, resource.DecimalSI),
+						},
+					},
+				},
+				{
+					Name: "sidecar",
+				},
+			},
+		},
+	}
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	store.Add(&pod)
+	labelCopier, err := util.NewLabelCopier(",", []string{}, []string{})
+	assert.NoError(t, err)
+	podBasedEnricher, err := NewPodBasedEnricher(v1listers.NewPodLister(store), labelCopier)
+	assert.NoError(t, err)
+
+	batch, err := podBasedEnricher.Process(&core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "trainer"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelPodName.Key:       "trainer",
+					core.LabelNamespaceName.Key: "ns1",
+				},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	containerMs, found := batch.MetricSets[core.PodContainerKey("ns1", "trainer", "train")]
+	if assert.True(t, found) {
+		assert.Equal(t, []core.LabeledMetric{
+			{
+				Name:        core.MetricContainerExtendedResourceRequest.Name,
+				Labels:      map[string]string{core.LabelResourceName.Key: "hugepages-2Mi"},
+				MetricValue: intValue(8 * 1024 * 1024),
+			},
+			{
+				Name:        core.MetricContainerExtendedResourceRequest.Name,
+				Labels:      map[string]string{core.LabelResourceName.Key: "nvidia.com/gpu"},
+				MetricValue: intValue(2),
+			},
+			{
+				Name:        core.MetricContainerExtendedResourceLimit.Name,
+				Labels:      map[string]string{core.LabelResourceName.Key: "nvidia.com/gpu"},
+				MetricValue: intValue(2),
+			},
+		}, containerMs.LabeledMetrics)
+	}
+	containerMs, found = batch.MetricSets[core.PodContainerKey("ns1", "trainer", "sidecar")]
+	if assert.True(t, found) {
+		assert.Empty(t, containerMs.LabeledMetrics)
+	}
+}
+
+func TestPodEnricherWorkloadLabels(t *testing.T) {
+	controller := true
+	pods := []*kube_api.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web-5d4f8b7c9-abcde",
+				Namespace: "ns1",
+				Labels:    map[string]string{podTemplateHashLabel: "5d4f8b7c9"},
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "ReplicaSet", Name: "web-5d4f8b7c9", Controller: &controller},
+				},
+			},
+			Spec: kube_api.PodSpec{Containers: []kube_api.Container{{Name: "app"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web-5d4f8b7c9-fghij",
+				Namespace: "ns1",
+				Labels:    map[string]string{podTemplateHashLabel: "5d4f8b7c9"},
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "ReplicaSet", Name: "web-5d4f8b7c9", Controller: &controller},
+				},
+			},
+			Spec: kube_api.PodSpec{Containers: []kube_api.Container{{Name: "app"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "db-0",
+				Namespace: "ns1",
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "StatefulSet", Name: "db", Controller: &controller},
+				},
+			},
+			Spec: kube_api.PodSpec{Containers: []kube_api.Container{{Name: "db"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "standalone-rs-xyz",
+				Namespace: "ns1",
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "ReplicaSet", Name: "standalone-rs", Controller: &controller},
+				},
+			},
+			Spec: kube_api.PodSpec{Containers: []kube_api.Container{{Name: "app"}}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "bare",
+				Namespace: "ns1",
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: "ConfigMap", Name: "not-a-controller"},
+				},
+			},
+			Spec: kube_api.PodSpec{Containers: []kube_api.Container{{Name: "app"}}},
+		},
+	}
+
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	podLister := v1listers.NewPodLister(store)
+	batch := &core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	for _, pod := range pods {
+		store.Add(pod)
+		container := pod.Spec.Containers[0].Name
+		batch.MetricSets[core.PodContainerKey(pod.Namespace, pod.Name, container)] = &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+				core.LabelPodName.Key:       pod.Name,
+				core.LabelNamespaceName.Key: pod.Namespace,
+				core.LabelContainerName.Key: container,
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricCpuUsageRate.Name: intValue(100),
+			},
+		}
+	}
+	labelCopier, err := util.NewLabelCopier(",", []string{}, []string{})
+	assert.NoError(t, err)
+
+	podBasedEnricher := PodBasedEnricher{podLister: podLister, labelCopier: labelCopier}
+	batch, err = podBasedEnricher.Process(batch)
+	assert.NoError(t, err)
+	podAggregator := PodAggregator{}
+	batch, err = podAggregator.Process(batch)
+	assert.NoError(t, err)
+	workloadAggregator := WorkloadAggregator{MetricsToAggregate: []string{core.MetricCpuUsageRate.Name}}
+	batch, err = workloadAggregator.Process(batch)
+	assert.NoError(t, err)
+
+	podMs := batch.MetricSets[core.PodKey("ns1", "web-5d4f8b7c9-abcde")]
+	if assert.NotNil(t, podMs) {
+		assert.Equal(t, "Deployment", podMs.Labels[core.LabelWorkloadKind.Key])
+		assert.Equal(t, "web", podMs.Labels[core.LabelWorkloadName.Key])
+	}
+	podMs = batch.MetricSets[core.PodKey("ns1", "bare")]
+	if assert.NotNil(t, podMs) {
+		assert.NotContains(t, podMs.Labels, core.LabelWorkloadKind.Key)
+		assert.NotContains(t, podMs.Labels, core.LabelWorkloadName.Key)
+	}
+
+	for key, cpu := range map[string]int64{
+		core.WorkloadKey("ns1", "Deployment", "web"):           200,
+		core.WorkloadKey("ns1", "StatefulSet", "db"):           100,
+		core.WorkloadKey("ns1", "ReplicaSet", "standalone-rs"): 100,
+	} {
+		workloadMs, found := batch.MetricSets[key]
+		if assert.True(t, found, key) {
+			assert.Equal(t, cpu, workloadMs.MetricValues[core.MetricCpuUsageRate.Name].IntValue, key)
+		}
+	}
+	workloads := 0
+	for _, ms := range batch.MetricSets {
+		if ms.Labels[core.LabelMetricSetType.Key] == core.MetricSetTypeWorkload {
+			workloads++
+		}
+	}
+	assert.Equal(t, 3, workloads)
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+type tcpConnectionSample struct {
+	collectionStartTime time.Time
+	scrapeTime          time.Time
+	connections         int64
+	// Rate computed for the sample, if any.
+	rate    float32
+	hasRate bool
+}
+
+func (this tcpConnectionSample) isRepeatOf(previous tcpConnectionSample) bool {
+	return !this.scrapeTime.IsZero() && this.scrapeTime.Equal(previous.scrapeTime) &&
+		this.collectionStartTime.Equal(previous.collectionStartTime)
+}
+
+// PodConnectionChurnCalculator derives the rate at which the pods open TCP connections
+// from the increase of their connection count since the previous batch. Closed connections
+// stay in TIME_WAIT for a while, so a client opening connections unboundedly shows up
+// as a sustained positive rate even if it closes them.
+type PodConnectionChurnCalculator struct {
+	previous map[string]tcpConnectionSample
+}
+
+func (this *PodConnectionChurnCalculator) Name() string {
+	return "pod_connection_churn_calculator"
+}
+
+func (this *PodConnectionChurnCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	current := make(map[string]tcpConnectionSample)
+	for key, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePod {
+			continue
+		}
+		connections, found := metricSet.MetricValues[core.MetricPodNetworkTcpConnections.Name]
+		if !found {
+			continue
+		}
+		sample := tcpConnectionSample{
+			collectionStartTime: metricSet.CollectionStartTime,
+			scrapeTime:          metricSet.ScrapeTime,
+			connections:         connections.IntValue,
+		}
+		current[key] = sample
+
+		previous, found := this.previous[key]
+		if !found {
+			continue
+		}
+		if sample.isRepeatOf(previous) {
+			current[key] = previous
+			if previous.hasRate {
+				setFloat(metricSet, &core.MetricPodNetworkTcpConnectionRate, previous.rate)
+			}
+			continue
+		}
+		if !sample.collectionStartTime.Equal(previous.collectionStartTime) {
+			glog.V(4).Infof("Skipping TCP connection rate for %s - the pod was recreated", key)
+			continue
+		}
+		elapsed := sample.scrapeTime.Sub(previous.scrapeTime).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		rate := float32(0)
+		if delta := sample.connections - previous.connections; delta > 0 {
+			rate = float32(float64(delta) / elapsed)
+		}
+		setFloat(metricSet, &core.MetricPodNetworkTcpConnectionRate, rate)
+		sample.rate, sample.hasRate = rate, true
+		current[key] = sample
+	}
+	this.previous = current
+	return batch, nil
+}
+
+func NewPodConnectionChurnCalculator() *PodConnectionChurnCalculator {
+	return &PodConnectionChurnCalculator{
+		previous: make(map[string]tcpConnectionSample),
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func tcpMetricSet(metricSetType string, started, scraped time.Time, connections int64) *core.MetricSet {
+	return &core.MetricSet{
+		CollectionStartTime: started,
+		ScrapeTime:          scraped,
+		Labels:              map[string]string{core.LabelMetricSetType.Key: metricSetType},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricPodNetworkTcpConnections.Name: intValue(connections),
+		},
+	}
+}
+
+func TestPodConnectionChurnCalculator(t *testing.T) {
+	started := time.Now().Add(-time.Hour)
+	recreated := time.Now().Add(-time.Minute)
+	now := time.Now()
+	calculator := NewPodConnectionChurnCalculator()
+
+	first := &core.DataBatch{
+		Timestamp: now,
+		MetricSets: map[string]*core.MetricSet{
+			"storm":     tcpMetricSet(core.MetricSetTypePod, started, now, 100),
+			"steady":    tcpMetricSet(core.MetricSetTypePod, started, now, 20),
+			"draining":  tcpMetricSet(core.MetricSetTypePod, started, now, 50),
+			"recreated": tcpMetricSet(core.MetricSetTypePod, started, now, 500),
+			"container": tcpMetricSet(core.MetricSetTypePodContainer, started, now, 100),
+		},
+	}
+	first, err := calculator.Process(first)
+	require.NoError(t, err)
+	for key, metricSet := range first.MetricSets {
+		_, found := metricSet.MetricValues[core.MetricPodNetworkTcpConnectionRate.Name]
+		assert.False(t, found, key)
+	}
+
+	later := now.Add(time.Minute)
+	second := &core.DataBatch{
+		Timestamp: later,
+		MetricSets: map[string]*core.MetricSet{
+			"storm":     tcpMetricSet(core.MetricSetTypePod, started, later, 700),
+			"steady":    tcpMetricSet(core.MetricSetTypePod, started, later, 20),
+			"draining":  tcpMetricSet(core.MetricSetTypePod, started, later, 10),
+			"recreated": tcpMetricSet(core.MetricSetTypePod, recreated, later, 5),
+			"container": tcpMetricSet(core.MetricSetTypePodContainer, started, later, 700),
+			"new":       tcpMetricSet(core.MetricSetTypePod, started, later, 100),
+		},
+	}
+	second, err = calculator.Process(second)
+	require.NoError(t, err)
+
+	assert.InDelta(t, 10, second.MetricSets["storm"].MetricValues[core.MetricPodNetworkTcpConnectionRate.Name].FloatValue, 1e-6)
+	assert.Equal(t, float32(0), second.MetricSets["steady"].MetricValues[core.MetricPodNetworkTcpConnectionRate.Name].FloatValue)
+	// Fewer connections is not a negative churn.
+	assert.Equal(t, float32(0), second.MetricSets["draining"].MetricValues[core.MetricPodNetworkTcpConnectionRate.Name].FloatValue)
+	for _, key := range []string{"recreated", "container", "new"} {
+		_, found := second.MetricSets[key].MetricValues[core.MetricPodNetworkTcpConnectionRate.Name]
+		assert.False(t, found, key)
+	}
+}
+
+func TestPodConnectionChurnSameScrape(t *testing.T) {
+	started := time.Now().Add(-time.Hour)
+	now := time.Now()
+	calculator := NewPodConnectionChurnCalculator()
+
+	// A node that was not scraped again reports the previous sample.
+	for i := 0; i < 2; i++ {
+		batch := &core.DataBatch{
+			Timestamp:  now.Add(time.Duration(i) * time.Minute),
+			MetricSets: map[string]*core.MetricSet{"pod": tcpMetricSet(core.MetricSetTypePod, started, now, 100)},
+		}
+		_, err := calculator.Process(batch)
+		require.NoError(t, err)
+		_, found := batch.MetricSets["pod"].MetricValues[core.MetricPodNetworkTcpConnectionRate.Name]
+		assert.False(t, found)
+	}
+}
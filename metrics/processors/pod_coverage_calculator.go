@@ -0,0 +1,76 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"github.com/golang/glog"
+
+	"k8s.io/apimachinery/pkg/labels"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/heapster/metrics/core"
+)
+
+// PodCoverageCalculator emits the percentage of the running pods known to the API server
+// that have a pod metric set in the batch. A drop of the coverage indicates that metrics
+// could not be collected from some nodes.
+type PodCoverageCalculator struct {
+	podLister v1listers.PodLister
+}
+
+func (this *PodCoverageCalculator) Name() string {
+	return "pod_coverage_calculator"
+}
+
+func (this *PodCoverageCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	pods, err := this.podLister.List(labels.Everything())
+	if err != nil {
+		// Missing coverage is not a reason to drop the whole batch.
+		glog.Errorf("Failed to list pods for coverage calculation: %v", err)
+		return batch, nil
+	}
+
+	var expected, collected int
+	for _, pod := range pods {
+		// Metrics are only available for pods running on a node.
+		if pod.Spec.NodeName == "" || pod.Status.Phase != kube_api.PodRunning {
+			continue
+		}
+		expected++
+		if _, found := batch.MetricSets[core.PodKey(pod.Namespace, pod.Name)]; found {
+			collected++
+		}
+	}
+
+	coverage := float32(100)
+	if expected > 0 {
+		coverage = 100 * float32(collected) / float32(expected)
+	}
+
+	clusterKey := core.ClusterKey()
+	cluster, found := batch.MetricSets[clusterKey]
+	if !found {
+		cluster = clusterMetricSet()
+		batch.MetricSets[clusterKey] = cluster
+	}
+	setFloat(cluster, &core.MetricClusterPodCoverage, coverage)
+	return batch, nil
+}
+
+func NewPodCoverageCalculator(podLister v1listers.PodLister) *PodCoverageCalculator {
+	return &PodCoverageCalculator{
+		podLister: podLister,
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func coveragePod(namespace, name, node string, phase kube_api.PodPhase) *kube_api.Pod {
+	return &kube_api.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Spec: kube_api.PodSpec{
+			NodeName: node,
+		},
+		Status: kube_api.PodStatus{
+			Phase: phase,
+		},
+	}
+}
+
+func coverageBatch(podKeys ...string) *core.DataBatch {
+	batch := &core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	for _, key := range podKeys {
+		batch.MetricSets[key] = &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePod,
+			},
+			MetricValues: map[string]core.MetricValue{},
+		}
+	}
+	return batch
+}
+
+func TestPodCoverageCalculator(t *testing.T) {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	calculator := NewPodCoverageCalculator(v1listers.NewPodLister(store))
+
+	// No running pods.
+	batch, err := calculator.Process(coverageBatch())
+	assert.NoError(t, err)
+	assert.Equal(t, float32(100), batch.MetricSets[core.ClusterKey()].MetricValues[core.MetricClusterPodCoverage.Name].FloatValue)
+
+	store.Add(coveragePod("ns1", "pod1", "node1", kube_api.PodRunning))
+	store.Add(coveragePod("ns1", "pod2", "node1", kube_api.PodRunning))
+	store.Add(coveragePod("ns2", "pod3", "node2", kube_api.PodRunning))
+	store.Add(coveragePod("ns2", "pod4", "node2", kube_api.PodRunning))
+	// Not expected to have metrics.
+	store.Add(coveragePod("ns2", "pending", "", kube_api.PodPending))
+	store.Add(coveragePod("ns2", "succeeded", "node2", kube_api.PodSucceeded))
+
+	for _, tc := range []struct {
+		podKeys  []string
+		expected float32
+	}{
+		{
+			podKeys:  []string{core.PodKey("ns1", "pod1"), core.PodKey("ns1", "pod2"), core.PodKey("ns2", "pod3"), core.PodKey("ns2", "pod4")},
+			expected: 100,
+		},
+		{
+			// Metrics from node2 are missing.
+			podKeys:  []string{core.PodKey("ns1", "pod1"), core.PodKey("ns1", "pod2")},
+			expected: 50,
+		},
+		{
+			// Pods unknown to the API server do not count.
+			podKeys:  []string{core.PodKey("ns1", "pod1"), core.PodKey("ns1", "deleted"), core.PodKey("ns2", "succeeded")},
+			expected: 25,
+		},
+		{
+			podKeys:  []string{},
+			expected: 0,
+		},
+	} {
+		batch := coverageBatch(tc.podKeys...)
+		cluster := clusterMetricSet()
+		batch.MetricSets[core.ClusterKey()] = cluster
+
+		batch, err := calculator.Process(batch)
+		assert.NoError(t, err)
+		assert.Equal(t, cluster, batch.MetricSets[core.ClusterKey()])
+		coverage, found := cluster.MetricValues[core.MetricClusterPodCoverage.Name]
+		if assert.True(t, found) {
+			assert.Equal(t, tc.expected, coverage.FloatValue, "%v", tc.podKeys)
+		}
+	}
+}
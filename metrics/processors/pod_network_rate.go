@@ -0,0 +1,52 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+var podNetworkRateMapping = map[string]core.Metric{
+	core.MetricNetworkRxRate.Name: core.MetricPodNetworkRxRate,
+	core.MetricNetworkTxRate.Name: core.MetricPodNetworkTxRate,
+}
+
+// PodNetworkRateCalculator exposes the network rates of the pod network namespace, as reported
+// by the infra container, under pod level metric names. It has to run after the rate
+// calculator but before the pod aggregator sums up the per-container network rates, so the
+// containers sharing the pod network are not counted twice.
+type PodNetworkRateCalculator struct{}
+
+func (this *PodNetworkRateCalculator) Name() string {
+	return "pod_network_rate_calculator"
+}
+
+func (this *PodNetworkRateCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for _, metricSet := range batch.MetricSets {
+		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypePod {
+			continue
+		}
+		for sourceName, targetMetric := range podNetworkRateMapping {
+			if value, found := metricSet.MetricValues[sourceName]; found {
+				metricSet.MetricValues[targetMetric.Name] = value
+			}
+		}
+	}
+	return batch, nil
+}
+
+func NewPodNetworkRateCalculator() *PodNetworkRateCalculator {
+	return &PodNetworkRateCalculator{}
+}
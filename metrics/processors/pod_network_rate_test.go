@@ -0,0 +1,91 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func floatValue(value float32) core.MetricValue {
+	return core.MetricValue{
+		ValueType:  core.ValueFloat,
+		MetricType: core.MetricGauge,
+		FloatValue: value,
+	}
+}
+
+func TestPodNetworkRateCalculator(t *testing.T) {
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			// Built from the infra container.
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelPodName.Key:       "pod1",
+					core.LabelNamespaceName.Key: "ns1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricNetworkRxRate.Name: floatValue(100),
+					core.MetricNetworkTxRate.Name: floatValue(50),
+				},
+			},
+			core.PodContainerKey("ns1", "pod1", "c1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+					core.LabelPodName.Key:       "pod1",
+					core.LabelNamespaceName.Key: "ns1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricNetworkRxRate.Name: floatValue(100),
+					core.MetricNetworkTxRate.Name: floatValue(50),
+				},
+			},
+			// No infra container reported for this pod.
+			core.PodContainerKey("ns1", "pod2", "c1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+					core.LabelPodName.Key:       "pod2",
+					core.LabelNamespaceName.Key: "ns1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricNetworkRxRate.Name: floatValue(10),
+				},
+			},
+		},
+	}
+
+	batch, err := NewPodNetworkRateCalculator().Process(batch)
+	assert.NoError(t, err)
+	batch, err = NewPodAggregator().Process(batch)
+	assert.NoError(t, err)
+
+	pod := batch.MetricSets[core.PodKey("ns1", "pod1")]
+	assert.Equal(t, float32(100), pod.MetricValues[core.MetricPodNetworkRxRate.Name].FloatValue)
+	assert.Equal(t, float32(50), pod.MetricValues[core.MetricPodNetworkTxRate.Name].FloatValue)
+
+	container := batch.MetricSets[core.PodContainerKey("ns1", "pod1", "c1")]
+	_, found := container.MetricValues[core.MetricPodNetworkRxRate.Name]
+	assert.False(t, found)
+
+	pod2 := batch.MetricSets[core.PodKey("ns1", "pod2")]
+	_, found = pod2.MetricValues[core.MetricPodNetworkRxRate.Name]
+	assert.False(t, found)
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"github.com/golang/glog"
+
+	"k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/util"
+)
+
+// PodPriorityEnricher reports the scheduling priority of the pods and labels them with
+// their priority class, so that the usage of the critical pods can be told from the one
+// of the preemptible pods. Pods without a priority class get no label, and no metric if
+// pod priority is not enabled in the cluster.
+type PodPriorityEnricher struct {
+	priorityLister util.PodPriorityLister
+}
+
+func (this *PodPriorityEnricher) Name() string {
+	return "pod_priority_enricher"
+}
+
+func (this *PodPriorityEnricher) Stateless() {}
+
+func (this *PodPriorityEnricher) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePod {
+			continue
+		}
+		namespace := metricSet.Labels[core.LabelNamespaceName.Key]
+		podName := metricSet.Labels[core.LabelPodName.Key]
+		pod, err := this.priorityLister.Get(namespace, podName)
+		if err != nil {
+			glog.V(3).Infof("Failed to get the priority of pod %s from cache: %v", core.PodKey(namespace, podName), err)
+			continue
+		}
+		if pod.Spec.PriorityClassName != "" {
+			metricSet.Labels[core.LabelPriorityClass.Key] = pod.Spec.PriorityClassName
+		}
+		if pod.Spec.Priority != nil {
+			metricSet.MetricValues[core.MetricPodPriority.Name] = core.MetricValue{
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricGauge,
+				IntValue:   int64(*pod.Spec.Priority),
+			}
+		}
+	}
+	return batch, nil
+}
+
+func NewPodPriorityEnricher(priorityLister util.PodPriorityLister) *PodPriorityEnricher {
+	return &PodPriorityEnricher{
+		priorityLister: priorityLister,
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/util"
+)
+
+type fakePodPriorityLister map[string]*util.PodPriority
+
+func (this fakePodPriorityLister) Get(namespace, name string) (*util.PodPriority, error) {
+	pod, found := this[core.PodKey(namespace, name)]
+	if !found {
+		return nil, fmt.Errorf("pod %s/%s not found", namespace, name)
+	}
+	return pod, nil
+}
+
+func priorityPod(name string, priority *int32, priorityClassName string) *util.PodPriority {
+	return &util.PodPriority{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns1"},
+		Spec:       util.PodPrioritySpec{Priority: priority, PriorityClassName: priorityClassName},
+	}
+}
+
+func prioritizedPod(name string) *core.MetricSet {
+	return &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePod,
+			core.LabelNamespaceName.Key: "ns1",
+			core.LabelPodName.Key:       name,
+		},
+		MetricValues: map[string]core.MetricValue{},
+	}
+}
+
+func TestPodPriorityEnricher(t *testing.T) {
+	high, zero := int32(1000000), int32(0)
+	lister := fakePodPriorityLister{
+		core.PodKey("ns1", "critical"): priorityPod("critical", &high, "high"),
+		core.PodKey("ns1", "default"):  priorityPod("default", &zero, ""),
+		core.PodKey("ns1", "disabled"): priorityPod("disabled", nil, ""),
+	}
+	container := typedContainer("critical", "app")
+
+	batch, err := NewPodPriorityEnricher(lister).Process(&core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "critical"):                 prioritizedPod("critical"),
+			core.PodKey("ns1", "default"):                  prioritizedPod("default"),
+			core.PodKey("ns1", "disabled"):                 prioritizedPod("disabled"),
+			core.PodKey("ns1", "unknown"):                  prioritizedPod("unknown"),
+			core.PodContainerKey("ns1", "critical", "app"): container,
+		},
+	})
+	assert.NoError(t, err)
+
+	critical := batch.MetricSets[core.PodKey("ns1", "critical")]
+	assert.Equal(t, "high", critical.Labels[core.LabelPriorityClass.Key])
+	assert.Equal(t, int64(1000000), critical.MetricValues[core.MetricPodPriority.Name].IntValue)
+
+	def := batch.MetricSets[core.PodKey("ns1", "default")]
+	assert.NotContains(t, def.Labels, core.LabelPriorityClass.Key)
+	if assert.Contains(t, def.MetricValues, core.MetricPodPriority.Name) {
+		assert.Equal(t, int64(0), def.MetricValues[core.MetricPodPriority.Name].IntValue)
+	}
+
+	for _, name := range []string{"disabled", "unknown"} {
+		pod := batch.MetricSets[core.PodKey("ns1", name)]
+		assert.NotContains(t, pod.Labels, core.LabelPriorityClass.Key, name)
+		assert.NotContains(t, pod.MetricValues, core.MetricPodPriority.Name, name)
+	}
+
+	assert.NotContains(t, container.Labels, core.LabelPriorityClass.Key)
+	assert.NotContains(t, container.MetricValues, core.MetricPodPriority.Name)
+}
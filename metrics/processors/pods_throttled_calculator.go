@@ -0,0 +1,140 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+type throttlingSample struct {
+	collectionStartTime time.Time
+	scrapeTime          time.Time
+	throttledPeriods    int64
+	// Whether the container was throttled since its previous sample, if known.
+	throttled      bool
+	knownThrottled bool
+}
+
+func (this throttlingSample) isRepeatOf(previous throttlingSample) bool {
+	return !this.scrapeTime.IsZero() && this.scrapeTime.Equal(previous.scrapeTime) &&
+		this.collectionStartTime.Equal(previous.collectionStartTime)
+}
+
+type throttledPods struct {
+	total     int
+	throttled int
+}
+
+// PodsThrottledCalculator computes the percentage of the pods of every namespace, and of
+// the cluster, that were throttled by their CPU limits since the previous batch, i.e. that
+// have a container whose number of throttled CFS periods increased. Only the pods whose
+// containers were all seen in the previous batch without restarting are counted. It has
+// to run after the namespace and cluster aggregators, which create the metric sets.
+type PodsThrottledCalculator struct {
+	previous map[string]throttlingSample
+}
+
+func (this *PodsThrottledCalculator) Name() string {
+	return "pods_throttled_calculator"
+}
+
+func (this *PodsThrottledCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	current := make(map[string]throttlingSample)
+	// Whether a pod was throttled, by pod key. Pods with a container lacking a previous
+	// sample are left out.
+	pods := make(map[string]bool)
+	unknown := make(map[string]bool)
+	for key, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer {
+			continue
+		}
+		throttledPeriods, found := metricSet.MetricValues[core.MetricContainerCpuThrottledPeriods.Name]
+		if !found {
+			continue
+		}
+		sample := throttlingSample{
+			collectionStartTime: metricSet.CollectionStartTime,
+			scrapeTime:          metricSet.ScrapeTime,
+			throttledPeriods:    throttledPeriods.IntValue,
+		}
+		current[key] = sample
+
+		podKey := core.PodKey(metricSet.Labels[core.LabelNamespaceName.Key], metricSet.Labels[core.LabelPodName.Key])
+		previous, found := this.previous[key]
+		if found && sample.isRepeatOf(previous) {
+			sample = previous
+			current[key] = sample
+		} else if !found || !sample.collectionStartTime.Equal(previous.collectionStartTime) ||
+			sample.throttledPeriods < previous.throttledPeriods {
+			unknown[podKey] = true
+			continue
+		} else {
+			sample.throttled, sample.knownThrottled = sample.throttledPeriods > previous.throttledPeriods, true
+			current[key] = sample
+		}
+		if !sample.knownThrottled {
+			unknown[podKey] = true
+			continue
+		}
+		pods[podKey] = pods[podKey] || sample.throttled
+	}
+	this.previous = current
+
+	namespaces := make(map[string]*throttledPods)
+	cluster := &throttledPods{}
+	for podKey, throttled := range pods {
+		if unknown[podKey] {
+			continue
+		}
+		pod, found := batch.MetricSets[podKey]
+		if !found {
+			continue
+		}
+		namespaceKey := core.NamespaceKey(pod.Labels[core.LabelNamespaceName.Key])
+		namespace, found := namespaces[namespaceKey]
+		if !found {
+			namespace = &throttledPods{}
+			namespaces[namespaceKey] = namespace
+		}
+		for _, count := range []*throttledPods{namespace, cluster} {
+			count.total++
+			if throttled {
+				count.throttled++
+			}
+		}
+	}
+
+	for namespaceKey, count := range namespaces {
+		if namespace, found := batch.MetricSets[namespaceKey]; found {
+			setFloat(namespace, &core.MetricNamespacePodsThrottledPct, count.percentage())
+		}
+	}
+	if clusterMetricSet, found := batch.MetricSets[core.ClusterKey()]; found && cluster.total > 0 {
+		setFloat(clusterMetricSet, &core.MetricNamespacePodsThrottledPct, cluster.percentage())
+	}
+	return batch, nil
+}
+
+func (this *throttledPods) percentage() float32 {
+	return 100 * float32(this.throttled) / float32(this.total)
+}
+
+func NewPodsThrottledCalculator() *PodsThrottledCalculator {
+	return &PodsThrottledCalculator{
+		previous: make(map[string]throttlingSample),
+	}
+}
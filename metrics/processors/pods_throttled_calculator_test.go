@@ -0,0 +1,138 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func throttlingContainer(namespace, pod string, start time.Time, throttledPeriods int64) *core.MetricSet {
+	return &core.MetricSet{
+		CollectionStartTime: start,
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			core.LabelNamespaceName.Key: namespace,
+			core.LabelPodName.Key:       pod,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricContainerCpuThrottledPeriods.Name: {
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricCumulative,
+				IntValue:   throttledPeriods,
+			},
+		},
+	}
+}
+
+func throttlingBatch(start time.Time, throttledPeriods map[string]int64) *core.DataBatch {
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.ClusterKey(): {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeCluster},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	}
+	for _, namespace := range []string{"ns1", "ns2"} {
+		batch.MetricSets[core.NamespaceKey(namespace)] = &core.MetricSet{
+			Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNamespace},
+			MetricValues: map[string]core.MetricValue{},
+		}
+	}
+	for container, periods := range throttledPeriods {
+		var namespace, pod, name string
+		switch container {
+		case "a1", "a2":
+			namespace, pod, name = "ns1", "a", container
+		case "b":
+			namespace, pod, name = "ns1", "b", "main"
+		case "c":
+			namespace, pod, name = "ns1", "c", "main"
+		case "d":
+			namespace, pod, name = "ns1", "d", "main"
+		case "e":
+			namespace, pod, name = "ns2", "e", "main"
+		}
+		batch.MetricSets[core.PodContainerKey(namespace, pod, name)] = throttlingContainer(namespace, pod, start, periods)
+		batch.MetricSets[core.PodKey(namespace, pod)] = &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePod,
+				core.LabelNamespaceName.Key: namespace,
+				core.LabelPodName.Key:       pod,
+			},
+			MetricValues: map[string]core.MetricValue{},
+		}
+	}
+	return batch
+}
+
+func throttledPct(batch *core.DataBatch, key string) (float32, bool) {
+	value, found := batch.MetricSets[key].MetricValues[core.MetricNamespacePodsThrottledPct.Name]
+	return value.FloatValue, found
+}
+
+func TestPodsThrottledCalculator(t *testing.T) {
+	calculator := NewPodsThrottledCalculator()
+	start := time.Now().Add(-time.Hour)
+
+	batch, err := calculator.Process(throttlingBatch(start, map[string]int64{
+		"a1": 0, "a2": 10, "b": 5, "c": 0, "e": 3,
+	}))
+	require.NoError(t, err)
+	// No previous sample yet.
+	_, found := throttledPct(batch, core.NamespaceKey("ns1"))
+	assert.False(t, found)
+	_, found = throttledPct(batch, core.ClusterKey())
+	assert.False(t, found)
+
+	batch, err = calculator.Process(throttlingBatch(start, map[string]int64{
+		// Pod a is throttled through its second container only, b and c are not
+		// throttled and d is new.
+		"a1": 0, "a2": 12, "b": 5, "c": 0, "d": 7, "e": 4,
+	}))
+	require.NoError(t, err)
+	pct, found := throttledPct(batch, core.NamespaceKey("ns1"))
+	assert.True(t, found)
+	assert.InDelta(t, 100.0/3, pct, 0.001)
+	pct, found = throttledPct(batch, core.NamespaceKey("ns2"))
+	assert.True(t, found)
+	assert.InDelta(t, 100, pct, 0.001)
+	pct, found = throttledPct(batch, core.ClusterKey())
+	assert.True(t, found)
+	assert.InDelta(t, 50, pct, 0.001)
+}
+
+func TestPodsThrottledAfterRestart(t *testing.T) {
+	calculator := NewPodsThrottledCalculator()
+	start := time.Now().Add(-time.Hour)
+
+	_, err := calculator.Process(throttlingBatch(start, map[string]int64{"b": 5, "c": 8}))
+	require.NoError(t, err)
+	// The container of pod c restarted, so its counter started over.
+	batch := throttlingBatch(start, map[string]int64{"b": 6, "c": 1})
+	batch.MetricSets[core.PodContainerKey("ns1", "c", "main")].CollectionStartTime = start.Add(time.Minute)
+	batch, err = calculator.Process(batch)
+	require.NoError(t, err)
+	pct, found := throttledPct(batch, core.NamespaceKey("ns1"))
+	assert.True(t, found)
+	assert.InDelta(t, 100, pct, 0.001)
+}
@@ -0,0 +1,126 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"strings"
+
+	"github.com/golang/glog"
+
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/heapster/metrics/core"
+)
+
+// Prefix of the resource_id of the volume filesystem metrics reported by the summary source.
+const volumeResourcePrefix = "Volume:"
+
+// PvcEnricher labels the filesystem metrics of the volumes backed by a persistent volume
+// claim with the name of the claim. The volume of a metric is found by its resource_id,
+// either the volume name reported by the summary source or, for a container, the path the
+// volume is mounted at.
+type PvcEnricher struct {
+	podLister v1listers.PodLister
+}
+
+func (this *PvcEnricher) Name() string {
+	return "pvc_enricher"
+}
+
+func (this *PvcEnricher) Stateless() {}
+
+func (this *PvcEnricher) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for _, metricSet := range batch.MetricSets {
+		metricSetType := metricSet.Labels[core.LabelMetricSetType.Key]
+		if metricSetType != core.MetricSetTypePod && metricSetType != core.MetricSetTypePodContainer {
+			continue
+		}
+		if !hasFilesystemMetrics(metricSet) {
+			continue
+		}
+		namespace := metricSet.Labels[core.LabelNamespaceName.Key]
+		podName := metricSet.Labels[core.LabelPodName.Key]
+		pod, err := this.podLister.Pods(namespace).Get(podName)
+		if err != nil || pod == nil {
+			glog.V(3).Infof("Failed to get pod %s from cache: %v", core.PodKey(namespace, podName), err)
+			continue
+		}
+		claims := volumeClaims(pod, metricSet.Labels[core.LabelContainerName.Key])
+		if len(claims) == 0 {
+			continue
+		}
+		for i := range metricSet.LabeledMetrics {
+			metric := &metricSet.LabeledMetrics[i]
+			if !strings.HasPrefix(metric.Name, "filesystem/") {
+				continue
+			}
+			claim, found := claims[metric.Labels[core.LabelResourceID.Key]]
+			if !found {
+				continue
+			}
+			// The sources share the labels between the metrics of a filesystem.
+			labels := make(map[string]string, len(metric.Labels)+1)
+			for name, value := range metric.Labels {
+				labels[name] = value
+			}
+			labels[core.LabelPvcName.Key] = claim
+			metric.Labels = labels
+		}
+	}
+	return batch, nil
+}
+
+func hasFilesystemMetrics(metricSet *core.MetricSet) bool {
+	for _, metric := range metricSet.LabeledMetrics {
+		if strings.HasPrefix(metric.Name, "filesystem/") {
+			return true
+		}
+	}
+	return false
+}
+
+// volumeClaims returns the claim names of the persistent volume claims of the pod by the
+// resource_id of their filesystem metrics. The mount paths are only known for a container.
+func volumeClaims(pod *kube_api.Pod, containerName string) map[string]string {
+	claims := make(map[string]string)
+	byVolume := make(map[string]string)
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		byVolume[volume.Name] = volume.PersistentVolumeClaim.ClaimName
+		claims[volumeResourcePrefix+volume.Name] = volume.PersistentVolumeClaim.ClaimName
+	}
+	if containerName == "" || len(byVolume) == 0 {
+		return claims
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name != containerName {
+			continue
+		}
+		for _, mount := range container.VolumeMounts {
+			if claim, found := byVolume[mount.Name]; found {
+				claims[mount.MountPath] = claim
+			}
+		}
+	}
+	return claims
+}
+
+func NewPvcEnricher(podLister v1listers.PodLister) *PvcEnricher {
+	return &PvcEnricher{
+		podLister: podLister,
+	}
+}
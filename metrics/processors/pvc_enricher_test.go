@@ -0,0 +1,144 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func pvcPod() *kube_api.Pod {
+	return &kube_api.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns1",
+			Name:      "db-0",
+		},
+		Spec: kube_api.PodSpec{
+			Volumes: []kube_api.Volume{
+				{
+					Name: "data",
+					VolumeSource: kube_api.VolumeSource{
+						PersistentVolumeClaim: &kube_api.PersistentVolumeClaimVolumeSource{ClaimName: "data-db-0"},
+					},
+				},
+				{
+					Name:         "cache",
+					VolumeSource: kube_api.VolumeSource{EmptyDir: &kube_api.EmptyDirVolumeSource{}},
+				},
+			},
+			Containers: []kube_api.Container{
+				{
+					Name: "db",
+					VolumeMounts: []kube_api.VolumeMount{
+						{Name: "data", MountPath: "/var/lib/db"},
+						{Name: "cache", MountPath: "/cache"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func filesystemMetrics(resourceIDs ...string) []core.LabeledMetric {
+	metrics := []core.LabeledMetric{}
+	for _, resourceID := range resourceIDs {
+		// The metrics of a filesystem share their labels, like in the sources.
+		labels := map[string]string{core.LabelResourceID.Key: resourceID}
+		for _, name := range []string{core.MetricFilesystemUsage.Name, core.MetricFilesystemLimit.Name} {
+			metrics = append(metrics, core.LabeledMetric{
+				Name:        name,
+				Labels:      labels,
+				MetricValue: intValue(1024),
+			})
+		}
+	}
+	return metrics
+}
+
+func pvcBatch() *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "db-0"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "db-0",
+				},
+				MetricValues:   map[string]core.MetricValue{},
+				LabeledMetrics: filesystemMetrics("Volume:data", "Volume:cache"),
+			},
+			core.PodContainerKey("ns1", "db-0", "db"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "db-0",
+					core.LabelContainerName.Key: "db",
+				},
+				MetricValues:   map[string]core.MetricValue{},
+				LabeledMetrics: filesystemMetrics("/var/lib/db", "/cache", "/dev/sda1"),
+			},
+			// Unknown to the pod lister.
+			core.PodKey("ns1", "web-0"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "web-0",
+				},
+				MetricValues:   map[string]core.MetricValue{},
+				LabeledMetrics: filesystemMetrics("Volume:data"),
+			},
+		},
+	}
+}
+
+// pvcNames returns the pvc_name label of the labeled metrics by their resource_id.
+func pvcNames(metricSet *core.MetricSet) map[string]string {
+	names := make(map[string]string)
+	for _, metric := range metricSet.LabeledMetrics {
+		names[metric.Labels[core.LabelResourceID.Key]] = metric.Labels[core.LabelPvcName.Key]
+	}
+	return names
+}
+
+func TestPvcEnricher(t *testing.T) {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	require.NoError(t, store.Add(pvcPod()))
+	batch, err := NewPvcEnricher(v1listers.NewPodLister(store)).Process(pvcBatch())
+	require.NoError(t, err)
+
+	pod := batch.MetricSets[core.PodKey("ns1", "db-0")]
+	assert.Equal(t, map[string]string{"Volume:data": "data-db-0", "Volume:cache": ""}, pvcNames(pod))
+	for _, metric := range pod.LabeledMetrics {
+		_, found := metric.Labels[core.LabelPvcName.Key]
+		assert.Equal(t, metric.Labels[core.LabelResourceID.Key] == "Volume:data", found, "%s %v", metric.Name, metric.Labels)
+	}
+
+	container := batch.MetricSets[core.PodContainerKey("ns1", "db-0", "db")]
+	assert.Equal(t, map[string]string{"/var/lib/db": "data-db-0", "/cache": "", "/dev/sda1": ""}, pvcNames(container))
+
+	unknown := batch.MetricSets[core.PodKey("ns1", "web-0")]
+	assert.Equal(t, map[string]string{"Volume:data": ""}, pvcNames(unknown))
+}
@@ -15,6 +15,8 @@
 package processors
 
 import (
+	"time"
+
 	"k8s.io/heapster/metrics/core"
 
 	"github.com/golang/glog"
@@ -23,6 +25,9 @@ import (
 type RateCalculator struct {
 	rateMetricsMapping map[string]core.Metric
 	previousBatch      *core.DataBatch
+	// Metric sets missing in a batch, e.g. of nodes scraped less often than in every
+	// cycle, are kept for the rates of the next batches for up to this long.
+	retention time.Duration
 }
 
 func (this *RateCalculator) Name() string {
@@ -46,6 +51,11 @@ func (this *RateCalculator) Process(batch *core.DataBatch) (*core.DataBatch, err
 		if !found {
 			continue
 		}
+		if newMs.ScrapeTime.Equal(oldMs.ScrapeTime) && newMs.CollectionStartTime.Equal(oldMs.CollectionStartTime) {
+			// The same sample again, e.g. of a node scraped less often than in every cycle.
+			this.copyRates(oldMs, newMs)
+			continue
+		}
 		if !newMs.ScrapeTime.After(oldMs.ScrapeTime) {
 			// New must be strictly after old.
 			glog.V(4).Infof("Skipping rate calculations for %s - new batch (%s) was not scraped strictly after old batch (%s)", key, newMs.ScrapeTime, oldMs.ScrapeTime)
@@ -66,8 +76,10 @@ func (this *RateCalculator) Process(batch *core.DataBatch) (*core.DataBatch, err
 					foundNew, foundOld = false, false
 					if itemNew.Name == metricName {
 						metricValNew, foundNew = itemNew.MetricValue, true
+						// The rate of every device is computed from its own previous value.
+						labels := core.LabelsKey(itemNew.Labels)
 						for _, itemOld := range oldMs.LabeledMetrics {
-							if itemOld.Name == metricName {
+							if itemOld.Name == metricName && core.LabelsKey(itemOld.Labels) == labels {
 								metricValOld, foundOld = itemOld.MetricValue, true
 								break
 							}
@@ -123,12 +135,54 @@ func (this *RateCalculator) Process(batch *core.DataBatch) (*core.DataBatch, err
 			}
 		}
 	}
-	this.previousBatch = batch
+	this.previousBatch = this.retain(batch)
 	return batch, nil
 }
 
-func NewRateCalculator(metrics map[string]core.Metric) *RateCalculator {
+// copyRates sets the rates computed for the old metric set on the new one.
+func (this *RateCalculator) copyRates(oldMs, newMs *core.MetricSet) {
+	for _, targetMetric := range this.rateMetricsMapping {
+		if value, found := oldMs.MetricValues[targetMetric.Name]; found {
+			newMs.MetricValues[targetMetric.Name] = value
+		}
+	}
+	for _, labeledMetric := range oldMs.LabeledMetrics {
+		for _, targetMetric := range this.rateMetricsMapping {
+			if labeledMetric.Name == targetMetric.Name {
+				newMs.LabeledMetrics = append(newMs.LabeledMetrics, labeledMetric)
+				break
+			}
+		}
+	}
+}
+
+// retain returns the batch to compute the next rates from, extended with the metric sets
+// of the previous batch missing in this one that are still within the retention.
+func (this *RateCalculator) retain(batch *core.DataBatch) *core.DataBatch {
+	if this.retention <= 0 {
+		return batch
+	}
+	retained := &core.DataBatch{
+		Timestamp:  batch.Timestamp,
+		MetricSets: make(map[string]*core.MetricSet, len(batch.MetricSets)),
+	}
+	for key, metricSet := range batch.MetricSets {
+		retained.MetricSets[key] = metricSet
+	}
+	for key, metricSet := range this.previousBatch.MetricSets {
+		if _, found := retained.MetricSets[key]; found {
+			continue
+		}
+		if batch.Timestamp.Sub(metricSet.ScrapeTime) <= this.retention {
+			retained.MetricSets[key] = metricSet
+		}
+	}
+	return retained
+}
+
+func NewRateCalculator(metrics map[string]core.Metric, retention time.Duration) *RateCalculator {
 	return &RateCalculator{
 		rateMetricsMapping: metrics,
+		retention:          retention,
 	}
 }
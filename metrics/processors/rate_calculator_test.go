@@ -80,7 +80,7 @@ func TestRateCalculator(t *testing.T) {
 		},
 	}
 
-	procesor := NewRateCalculator(core.RateMetricsMapping)
+	procesor := NewRateCalculator(core.RateMetricsMapping, 0)
 	procesor.Process(prev)
 	procesor.Process(current)
 
@@ -91,3 +91,127 @@ func TestRateCalculator(t *testing.T) {
 	assert.InEpsilon(t, 13, cpuRate.IntValue, 2)
 	assert.InEpsilon(t, 2, txeRate.FloatValue, 0.1)
 }
+
+func TestRateCalculatorLabeledMetrics(t *testing.T) {
+	key := core.NodeKey("node1")
+	now := time.Now()
+	diskBatch := func(timestamp time.Time, sda, sdb int64) *core.DataBatch {
+		device := func(name string, value int64) core.LabeledMetric {
+			return core.LabeledMetric{
+				Name:   core.MetricDiskIORead.Name,
+				Labels: map[string]string{core.LabelResourceID.Key: name},
+				MetricValue: core.MetricValue{
+					ValueType:  core.ValueInt64,
+					MetricType: core.MetricCumulative,
+					IntValue:   value,
+				},
+			}
+		}
+		return &core.DataBatch{
+			Timestamp: timestamp,
+			MetricSets: map[string]*core.MetricSet{
+				key: {
+					CollectionStartTime: now.Add(-time.Hour),
+					ScrapeTime:          timestamp,
+					Labels: map[string]string{
+						core.LabelMetricSetType.Key: core.MetricSetTypeNode,
+					},
+					MetricValues: map[string]core.MetricValue{},
+					LabeledMetrics: []core.LabeledMetric{
+						device("/dev/sda", sda),
+						device("/dev/sdb", sdb),
+					},
+				},
+			},
+		}
+	}
+
+	processor := NewRateCalculator(core.RateMetricsMapping, 0)
+	processor.Process(diskBatch(now.Add(-10*time.Second), 1000, 5000))
+	current := diskBatch(now, 2000, 5500)
+	// The devices are listed in another order than in the previous batch.
+	labeled := current.MetricSets[key].LabeledMetrics
+	labeled[0], labeled[1] = labeled[1], labeled[0]
+	processor.Process(current)
+
+	rates := make(map[string]float32)
+	for _, metric := range current.MetricSets[key].LabeledMetrics {
+		if metric.Name == core.MetricDiskIOReadRate.Name {
+			rates[metric.Labels[core.LabelResourceID.Key]] = metric.FloatValue
+		}
+	}
+	assert.InDelta(t, 100, rates["/dev/sda"], 1e-3)
+	assert.InDelta(t, 50, rates["/dev/sdb"], 1e-3)
+}
+
+func TestRateCalculatorRetention(t *testing.T) {
+	key := core.NodeKey("edge")
+	now := time.Now()
+	nodeBatch := func(timestamp time.Time, cpuUsage int64) *core.DataBatch {
+		return &core.DataBatch{
+			Timestamp: timestamp,
+			MetricSets: map[string]*core.MetricSet{
+				key: {
+					CollectionStartTime: now.Add(-time.Hour),
+					ScrapeTime:          timestamp,
+					Labels: map[string]string{
+						core.LabelMetricSetType.Key: core.MetricSetTypeNode,
+					},
+					MetricValues: map[string]core.MetricValue{
+						core.MetricCpuUsage.MetricDescriptor.Name: {
+							ValueType:  core.ValueInt64,
+							MetricType: core.MetricCumulative,
+							IntValue:   cpuUsage,
+						},
+					},
+				},
+			},
+		}
+	}
+	emptyBatch := func(timestamp time.Time) *core.DataBatch {
+		return &core.DataBatch{
+			Timestamp:  timestamp,
+			MetricSets: map[string]*core.MetricSet{},
+		}
+	}
+
+	// The node is scraped every 30s, in every third cycle.
+	processor := NewRateCalculator(core.RateMetricsMapping, 30*time.Second)
+	processor.Process(nodeBatch(now.Add(-30*time.Second), 0))
+	processor.Process(emptyBatch(now.Add(-20 * time.Second)))
+	processor.Process(emptyBatch(now.Add(-10 * time.Second)))
+	current := nodeBatch(now, 30*1e9)
+	processor.Process(current)
+	assert.Equal(t, int64(1000), current.MetricSets[key].MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+
+	// Without retention, the node is forgotten when missing in a batch.
+	processor = NewRateCalculator(core.RateMetricsMapping, 0)
+	processor.Process(nodeBatch(now.Add(-30*time.Second), 0))
+	processor.Process(emptyBatch(now.Add(-20 * time.Second)))
+	current = nodeBatch(now, 30*1e9)
+	processor.Process(current)
+	assert.NotContains(t, current.MetricSets[key].MetricValues, core.MetricCpuUsageRate.Name)
+
+	// The sample carried forward by the source manager in the cycles in which the node is
+	// skipped keeps its rate.
+	processor = NewRateCalculator(core.RateMetricsMapping, 30*time.Second)
+	processor.Process(nodeBatch(now.Add(-30*time.Second), 0))
+	processor.Process(nodeBatch(now.Add(-20*time.Second), 10*1e9))
+	for _, timestamp := range []time.Time{now.Add(-10 * time.Second), now} {
+		carried := nodeBatch(now.Add(-20*time.Second), 10*1e9)
+		carried.Timestamp = timestamp
+		processor.Process(carried)
+		assert.Equal(t, int64(1000), carried.MetricSets[key].MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+	}
+	current = nodeBatch(now.Add(10*time.Second), 40*1e9)
+	processor.Process(current)
+	assert.Equal(t, int64(1000), current.MetricSets[key].MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+
+	// Metric sets older than the retention are forgotten.
+	processor = NewRateCalculator(core.RateMetricsMapping, 30*time.Second)
+	processor.Process(nodeBatch(now.Add(-40*time.Second), 0))
+	processor.Process(emptyBatch(now.Add(-5 * time.Second)))
+	current = nodeBatch(now, 30*1e9)
+	processor.Process(current)
+	assert.NotContains(t, current.MetricSets[key].MetricValues, core.MetricCpuUsageRate.Name)
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// ReplicaSpreadCalculator counts the distinct nodes and zones the pods of every workload
+// run on, so that workloads whose replicas share a single node or zone stand out. The
+// zones are only counted with the zone labels of the topology enricher. It has to run
+// after the workload aggregator.
+type ReplicaSpreadCalculator struct{}
+
+func (this *ReplicaSpreadCalculator) Name() string {
+	return "replica_spread_calculator"
+}
+
+func (this *ReplicaSpreadCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	nodes := make(map[string]map[string]bool)
+	zones := make(map[string]map[string]bool)
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePod {
+			continue
+		}
+		namespace := metricSet.Labels[core.LabelNamespaceName.Key]
+		kind := metricSet.Labels[core.LabelWorkloadKind.Key]
+		name := metricSet.Labels[core.LabelWorkloadName.Key]
+		if namespace == "" || kind == "" || name == "" {
+			continue
+		}
+		workloadKey := core.WorkloadKey(namespace, kind, name)
+		if node := metricSet.Labels[core.LabelNodename.Key]; node != "" {
+			if nodes[workloadKey] == nil {
+				nodes[workloadKey] = make(map[string]bool)
+			}
+			nodes[workloadKey][node] = true
+		}
+		if zone := metricSet.Labels[core.LabelZone.Key]; zone != "" {
+			if zones[workloadKey] == nil {
+				zones[workloadKey] = make(map[string]bool)
+			}
+			zones[workloadKey][zone] = true
+		}
+	}
+
+	for workloadKey, workloadNodes := range nodes {
+		if workload, found := batch.MetricSets[workloadKey]; found {
+			workload.MetricValues[core.MetricWorkloadReplicaSpreadNodes.Name] = intValue(int64(len(workloadNodes)))
+		}
+	}
+	for workloadKey, workloadZones := range zones {
+		if workload, found := batch.MetricSets[workloadKey]; found {
+			workload.MetricValues[core.MetricWorkloadReplicaSpreadZones.Name] = intValue(int64(len(workloadZones)))
+		}
+	}
+	return batch, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func spreadPod(pod, workload, node, zone string) *core.MetricSet {
+	metricSet := efficiencyPod(pod, workload)
+	metricSet.Labels[core.LabelNodename.Key] = node
+	if zone != "" {
+		metricSet.Labels[core.LabelZone.Key] = zone
+	}
+	return metricSet
+}
+
+func spreadWorkload() *core.MetricSet {
+	return &core.MetricSet{
+		Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeWorkload},
+		MetricValues: map[string]core.MetricValue{},
+	}
+}
+
+func TestReplicaSpreadCalculator(t *testing.T) {
+	web := core.WorkloadKey("ns1", "Deployment", "web")
+	db := core.WorkloadKey("ns1", "Deployment", "db")
+	batch, err := (&ReplicaSpreadCalculator{}).Process(&core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "web-1"): spreadPod("web-1", "web", "n1", "zone-a"),
+			core.PodKey("ns1", "web-2"): spreadPod("web-2", "web", "n2", "zone-a"),
+			core.PodKey("ns1", "web-3"): spreadPod("web-3", "web", "n3", "zone-b"),
+			core.PodKey("ns1", "web-4"): spreadPod("web-4", "web", "n3", "zone-b"),
+			// All the replicas on a single node, without zone labels.
+			core.PodKey("ns1", "db-1"): spreadPod("db-1", "db", "n1", ""),
+			core.PodKey("ns1", "db-2"): spreadPod("db-2", "db", "n1", ""),
+			core.PodKey("ns1", "bare"): spreadPod("bare", "", "n2", "zone-a"),
+			web:                        spreadWorkload(),
+			db:                         spreadWorkload(),
+		},
+	})
+	require.NoError(t, err)
+
+	spread := func(key string, metric core.Metric) (int64, bool) {
+		value, found := batch.MetricSets[key].MetricValues[metric.Name]
+		return value.IntValue, found
+	}
+	value, found := spread(web, core.MetricWorkloadReplicaSpreadNodes)
+	assert.True(t, found)
+	assert.Equal(t, int64(3), value)
+	value, found = spread(web, core.MetricWorkloadReplicaSpreadZones)
+	assert.True(t, found)
+	assert.Equal(t, int64(2), value)
+
+	value, found = spread(db, core.MetricWorkloadReplicaSpreadNodes)
+	assert.True(t, found)
+	assert.Equal(t, int64(1), value)
+	_, found = spread(db, core.MetricWorkloadReplicaSpreadZones)
+	assert.False(t, found)
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// Usage and request metrics from which the efficiency metrics are computed.
+var requestEfficiencyMetrics = []struct {
+	usage      string
+	request    string
+	efficiency *core.Metric
+}{
+	{core.MetricCpuUsageRate.Name, core.MetricCpuRequest.Name, &core.MetricContainerCpuRequestEfficiency},
+	{core.MetricMemoryUsage.Name, core.MetricMemoryRequest.Name, &core.MetricContainerMemoryRequestEfficiency},
+}
+
+// RequestEfficiencyCalculator computes the usage of every container divided by its
+// request, for CPU and memory. Containers without a request are skipped. The workload
+// efficiency is the summed up usage of the containers with a request divided by their
+// summed up requests. It has to run after the pod based enricher, which sets the requests,
+// and after the workload aggregator.
+type RequestEfficiencyCalculator struct {
+}
+
+// Summed up usage and requests of the containers of a workload.
+type requestSums struct {
+	usage   int64
+	request int64
+}
+
+func (this *RequestEfficiencyCalculator) Name() string {
+	return "request_efficiency_calculator"
+}
+
+func (this *RequestEfficiencyCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	// Keyed by the workload and the efficiency metric.
+	workloads := make(map[string]map[string]*requestSums)
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer {
+			continue
+		}
+		workloadKey := containerWorkloadKey(batch, metricSet)
+		for _, metric := range requestEfficiencyMetrics {
+			usage, found := metricSet.MetricValues[metric.usage]
+			request, found2 := metricSet.MetricValues[metric.request]
+			if !found || !found2 || request.IntValue <= 0 {
+				continue
+			}
+			setFloat(metricSet, metric.efficiency, float32(usage.IntValue)/float32(request.IntValue))
+
+			if workloadKey == "" {
+				continue
+			}
+			requests, found := workloads[workloadKey]
+			if !found {
+				requests = make(map[string]*requestSums)
+				workloads[workloadKey] = requests
+			}
+			sums, found := requests[metric.efficiency.Name]
+			if !found {
+				sums = &requestSums{}
+				requests[metric.efficiency.Name] = sums
+			}
+			sums.usage += usage.IntValue
+			sums.request += request.IntValue
+		}
+	}
+
+	for workloadKey, requests := range workloads {
+		workload, found := batch.MetricSets[workloadKey]
+		if !found {
+			continue
+		}
+		for _, metric := range requestEfficiencyMetrics {
+			if sums, found := requests[metric.efficiency.Name]; found {
+				setFloat(workload, metric.efficiency, float32(sums.usage)/float32(sums.request))
+			}
+		}
+	}
+	return batch, nil
+}
+
+// containerWorkloadKey returns the key of the workload of the container, taken from the
+// labels of its pod, or an empty string if the pod is not part of a workload.
+func containerWorkloadKey(batch *core.DataBatch, container *core.MetricSet) string {
+	namespace := container.Labels[core.LabelNamespaceName.Key]
+	pod, found := batch.MetricSets[core.PodKey(namespace, container.Labels[core.LabelPodName.Key])]
+	if !found {
+		return ""
+	}
+	kind := pod.Labels[core.LabelWorkloadKind.Key]
+	name := pod.Labels[core.LabelWorkloadName.Key]
+	if namespace == "" || kind == "" || name == "" {
+		return ""
+	}
+	return core.WorkloadKey(namespace, kind, name)
+}
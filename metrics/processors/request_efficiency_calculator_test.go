@@ -0,0 +1,112 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func efficiencyContainer(pod string, cpuUsage, cpuRequest, memoryUsage, memoryRequest int64) *core.MetricSet {
+	metricSet := &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			core.LabelNamespaceName.Key: "ns1",
+			core.LabelPodName.Key:       pod,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuUsageRate.Name: intValue(cpuUsage),
+			core.MetricMemoryUsage.Name:  intValue(memoryUsage),
+		},
+	}
+	if cpuRequest > 0 {
+		metricSet.MetricValues[core.MetricCpuRequest.Name] = intValue(cpuRequest)
+	}
+	if memoryRequest >= 0 {
+		metricSet.MetricValues[core.MetricMemoryRequest.Name] = intValue(memoryRequest)
+	}
+	return metricSet
+}
+
+func efficiencyPod(pod, workload string) *core.MetricSet {
+	metricSet := &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePod,
+			core.LabelNamespaceName.Key: "ns1",
+			core.LabelPodName.Key:       pod,
+		},
+		MetricValues: map[string]core.MetricValue{},
+	}
+	if workload != "" {
+		metricSet.Labels[core.LabelWorkloadKind.Key] = "Deployment"
+		metricSet.Labels[core.LabelWorkloadName.Key] = workload
+	}
+	return metricSet
+}
+
+func TestRequestEfficiencyCalculator(t *testing.T) {
+	workloadKey := core.WorkloadKey("ns1", "Deployment", "web")
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "web-1", "app"):     efficiencyContainer("web-1", 50, 100, 300, 1000),
+			core.PodContainerKey("ns1", "web-1", "sidecar"): efficiencyContainer("web-1", 40, 0, 100, 0),
+			core.PodContainerKey("ns1", "web-2", "app"):     efficiencyContainer("web-2", 250, 100, 900, 1000),
+			core.PodContainerKey("ns1", "batch", "job"):     efficiencyContainer("batch", 100, 200, 100, -1),
+			core.PodKey("ns1", "web-1"):                     efficiencyPod("web-1", "web"),
+			core.PodKey("ns1", "web-2"):                     efficiencyPod("web-2", "web"),
+			core.PodKey("ns1", "batch"):                     efficiencyPod("batch", ""),
+			workloadKey: {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypeWorkload,
+				},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	}
+	batch, err := (&RequestEfficiencyCalculator{}).Process(batch)
+	assert.NoError(t, err)
+
+	cpuEfficiency := core.MetricContainerCpuRequestEfficiency.Name
+	memoryEfficiency := core.MetricContainerMemoryRequestEfficiency.Name
+
+	app := batch.MetricSets[core.PodContainerKey("ns1", "web-1", "app")]
+	assert.InDelta(t, 0.5, app.MetricValues[cpuEfficiency].FloatValue, 0.0001)
+	assert.InDelta(t, 0.3, app.MetricValues[memoryEfficiency].FloatValue, 0.0001)
+	assert.Equal(t, core.MetricGauge, app.MetricValues[cpuEfficiency].MetricType)
+
+	// Missing and zero requests are skipped.
+	sidecar := batch.MetricSets[core.PodContainerKey("ns1", "web-1", "sidecar")]
+	assert.NotContains(t, sidecar.MetricValues, cpuEfficiency)
+	assert.NotContains(t, sidecar.MetricValues, memoryEfficiency)
+	job := batch.MetricSets[core.PodContainerKey("ns1", "batch", "job")]
+	assert.InDelta(t, 0.5, job.MetricValues[cpuEfficiency].FloatValue, 0.0001)
+	assert.NotContains(t, job.MetricValues, memoryEfficiency)
+
+	overused := batch.MetricSets[core.PodContainerKey("ns1", "web-2", "app")]
+	assert.InDelta(t, 2.5, overused.MetricValues[cpuEfficiency].FloatValue, 0.0001)
+
+	// The workload sums up the containers with requests only: (50+250)/(100+100) and (300+900)/(1000+1000).
+	workload := batch.MetricSets[workloadKey]
+	assert.InDelta(t, 1.5, workload.MetricValues[cpuEfficiency].FloatValue, 0.0001)
+	assert.InDelta(t, 0.6, workload.MetricValues[memoryEfficiency].FloatValue, 0.0001)
+
+	// Pods are left alone.
+	assert.Empty(t, batch.MetricSets[core.PodKey("ns1", "web-1")].MetricValues)
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// RestartVelocityCalculator emits the number of restarts per hour of every pod container
+// over the window ending with the current batch, and labels the containers restarting
+// faster than the threshold as flapping. It has to run after the pod based enricher,
+// which provides the restart counts.
+type RestartVelocityCalculator struct {
+	window time.Duration
+	// Restarts per hour above which a container is flapping.
+	threshold float64
+	// Restart counts within the window, by container key.
+	samples map[string][]restartSample
+}
+
+func (this *RestartVelocityCalculator) Name() string {
+	return "restart_velocity_calculator"
+}
+
+func (this *RestartVelocityCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	seen := make(map[string]struct{})
+	for key, metricSet := range batch.MetricSets {
+		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypePodContainer {
+			continue
+		}
+		restartCount, found := metricSet.MetricValues[core.MetricRestartCount.Name]
+		if !found {
+			continue
+		}
+		now := metricSet.ScrapeTime
+		if now.IsZero() {
+			now = batch.Timestamp
+		}
+		seen[key] = struct{}{}
+		samples := this.addSample(key, restartSample{timestamp: now, restartCount: restartCount.IntValue})
+		if len(samples) < 2 {
+			continue
+		}
+
+		velocity := float64(restartsIn(samples)) / this.window.Hours()
+		setFloat(metricSet, &core.MetricContainerRestartVelocity, float32(velocity))
+		if velocity > this.threshold {
+			metricSet.Labels[core.LabelFlapping.Key] = "true"
+		} else {
+			metricSet.Labels[core.LabelFlapping.Key] = "false"
+		}
+	}
+
+	for key := range this.samples {
+		if _, found := seen[key]; !found {
+			delete(this.samples, key)
+		}
+	}
+	return batch, nil
+}
+
+// addSample records the given sample and drops the ones that fell out of the window. A
+// sample not newer than the latest one, e.g. carried forward from an earlier cycle, is ignored.
+func (this *RestartVelocityCalculator) addSample(key string, sample restartSample) []restartSample {
+	samples := this.samples[key]
+	if n := len(samples); n > 0 && !sample.timestamp.After(samples[n-1].timestamp) {
+		return samples
+	}
+	samples = append(samples, sample)
+	cutoff := sample.timestamp.Add(-this.window)
+	first := 0
+	for first < len(samples)-1 && samples[first].timestamp.Before(cutoff) {
+		first++
+	}
+	samples = samples[first:]
+	this.samples[key] = samples
+	return samples
+}
+
+// restartsIn returns the number of restarts between the first and the last sample. The
+// restart count starts over from 0 when the container is recreated, e.g. with its pod, in
+// which case the restarts of the new container are counted.
+func restartsIn(samples []restartSample) int64 {
+	restarts := int64(0)
+	for i := 1; i < len(samples); i++ {
+		if delta := samples[i].restartCount - samples[i-1].restartCount; delta >= 0 {
+			restarts += delta
+		} else {
+			restarts += samples[i].restartCount
+		}
+	}
+	return restarts
+}
+
+// NewRestartVelocityCalculator returns a calculator of the restarts per hour over the given
+// window, flagging the containers restarting more than threshold times per hour.
+func NewRestartVelocityCalculator(window time.Duration, threshold float64) *RestartVelocityCalculator {
+	return &RestartVelocityCalculator{
+		window:    window,
+		threshold: threshold,
+		samples:   make(map[string][]restartSample),
+	}
+}
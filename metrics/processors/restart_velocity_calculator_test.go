@@ -0,0 +1,110 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func restartBatch(timestamp time.Time, restartCounts map[string]int64) *core.DataBatch {
+	batch := &core.DataBatch{
+		Timestamp:  timestamp,
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	for container, restartCount := range restartCounts {
+		batch.MetricSets[core.PodContainerKey("ns1", "pod1", container)] = &core.MetricSet{
+			ScrapeTime: timestamp,
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricRestartCount.Name: intValue(restartCount),
+			},
+		}
+	}
+	return batch
+}
+
+func TestRestartVelocity(t *testing.T) {
+	calculator := NewRestartVelocityCalculator(time.Hour, 5)
+	now := time.Now()
+	var batch *core.DataBatch
+	// The crashing container restarts every 5 minutes, the other one once.
+	for i := 0; i <= 12; i++ {
+		crashing := int64(i)
+		stable := int64(0)
+		if i >= 6 {
+			stable = 1
+		}
+		var err error
+		batch, err = calculator.Process(restartBatch(now.Add(time.Duration(i)*5*time.Minute),
+			map[string]int64{"crashing": crashing, "stable": stable}))
+		assert.NoError(t, err)
+		if i == 0 {
+			for _, metricSet := range batch.MetricSets {
+				assert.NotContains(t, metricSet.MetricValues, core.MetricContainerRestartVelocity.Name)
+				assert.NotContains(t, metricSet.Labels, core.LabelFlapping.Key)
+			}
+		}
+	}
+
+	crashing := batch.MetricSets[core.PodContainerKey("ns1", "pod1", "crashing")]
+	assert.Equal(t, float32(12), crashing.MetricValues[core.MetricContainerRestartVelocity.Name].FloatValue)
+	assert.Equal(t, "true", crashing.Labels[core.LabelFlapping.Key])
+	stable := batch.MetricSets[core.PodContainerKey("ns1", "pod1", "stable")]
+	assert.Equal(t, float32(1), stable.MetricValues[core.MetricContainerRestartVelocity.Name].FloatValue)
+	assert.Equal(t, "false", stable.Labels[core.LabelFlapping.Key])
+
+	// The restarts older than the window are not counted anymore.
+	for i := 13; i <= 24; i++ {
+		var err error
+		batch, err = calculator.Process(restartBatch(now.Add(time.Duration(i)*5*time.Minute),
+			map[string]int64{"crashing": 12, "stable": 1}))
+		assert.NoError(t, err)
+	}
+	crashing = batch.MetricSets[core.PodContainerKey("ns1", "pod1", "crashing")]
+	assert.Equal(t, float32(0), crashing.MetricValues[core.MetricContainerRestartVelocity.Name].FloatValue)
+	assert.Equal(t, "false", crashing.Labels[core.LabelFlapping.Key])
+}
+
+func TestRestartVelocityCounterReset(t *testing.T) {
+	calculator := NewRestartVelocityCalculator(time.Hour, 5)
+	now := time.Now()
+	// The pod is recreated after 10 restarts, and its new container restarts 3 times.
+	for i, restartCount := range []int64{4, 10, 1, 3} {
+		batch, err := calculator.Process(restartBatch(now.Add(time.Duration(i)*time.Minute),
+			map[string]int64{"c1": restartCount}))
+		assert.NoError(t, err)
+		if i == 3 {
+			c1 := batch.MetricSets[core.PodContainerKey("ns1", "pod1", "c1")]
+			assert.Equal(t, float32(9), c1.MetricValues[core.MetricContainerRestartVelocity.Name].FloatValue)
+			assert.Equal(t, "true", c1.Labels[core.LabelFlapping.Key])
+		}
+	}
+}
+
+func TestRestartVelocityForgetsContainers(t *testing.T) {
+	calculator := NewRestartVelocityCalculator(time.Hour, 5)
+	now := time.Now()
+	calculator.Process(restartBatch(now, map[string]int64{"c1": 1, "c2": 1}))
+	calculator.Process(restartBatch(now.Add(time.Minute), map[string]int64{"c1": 1}))
+	assert.Equal(t, 1, len(calculator.samples))
+	assert.Contains(t, calculator.samples, core.PodContainerKey("ns1", "pod1", "c1"))
+}
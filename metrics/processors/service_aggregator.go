@@ -0,0 +1,181 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	kube_client "k8s.io/client-go/kubernetes"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+	kube_config "k8s.io/heapster/common/kubernetes"
+	"k8s.io/heapster/metrics/core"
+)
+
+const (
+	// The usage of a pod is counted in full in every service it backs.
+	ServiceAttributionFull = "full"
+	// The usage of a pod is split evenly among the services it backs, so that the
+	// services add up to the usage of their pods.
+	ServiceAttributionFractional = "fractional"
+)
+
+// ServiceAggregator rolls up pod metrics per service, into metric sets of type service.
+// The pods backing a service are taken from its endpoints, ready or not. It has to run
+// after the pod aggregator.
+type ServiceAggregator struct {
+	endpointsLister    v1listers.EndpointsLister
+	MetricsToAggregate []string
+	// Whether the metrics of a pod backing several services are split among them.
+	Fractional bool
+}
+
+func (this *ServiceAggregator) Name() string {
+	return "service_aggregator"
+}
+
+func (this *ServiceAggregator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	podServices, err := this.podServices()
+	if err != nil {
+		return nil, err
+	}
+
+	services := make(map[string]*core.MetricSet)
+	for key, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePod {
+			continue
+		}
+		names := podServices[key]
+		if len(names) == 0 {
+			continue
+		}
+		source := metricSet
+		if this.Fractional && len(names) > 1 {
+			source = metricShare(metricSet, this.MetricsToAggregate, len(names))
+		}
+		namespace := metricSet.Labels[core.LabelNamespaceName.Key]
+		for _, name := range names {
+			serviceKey := core.ServiceKey(namespace, name)
+			service, found := services[serviceKey]
+			if !found {
+				service = serviceMetricSet(metricSet.Labels, name)
+				services[serviceKey] = service
+			}
+			if err := aggregate(source, service, this.MetricsToAggregate); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for key, service := range services {
+		batch.MetricSets[key] = service
+	}
+	return batch, nil
+}
+
+// podServices returns the names of the services backed by every pod, by pod key.
+func (this *ServiceAggregator) podServices() (map[string][]string, error) {
+	endpoints, err := this.endpointsLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list endpoints: %v", err)
+	}
+	podServices := make(map[string][]string)
+	for _, service := range endpoints {
+		// A pod is listed once per subset it belongs to.
+		pods := make(map[string]bool)
+		for _, subset := range service.Subsets {
+			for _, addresses := range [][]kube_api.EndpointAddress{subset.Addresses, subset.NotReadyAddresses} {
+				for _, address := range addresses {
+					if address.TargetRef == nil || address.TargetRef.Kind != "Pod" {
+						continue
+					}
+					namespace := address.TargetRef.Namespace
+					if namespace == "" {
+						namespace = service.Namespace
+					}
+					pods[core.PodKey(namespace, address.TargetRef.Name)] = true
+				}
+			}
+		}
+		for pod := range pods {
+			podServices[pod] = append(podServices[pod], service.Name)
+		}
+	}
+	return podServices, nil
+}
+
+// metricShare returns a metric set with the given metrics of the pod divided by the
+// number of services it backs.
+func metricShare(pod *core.MetricSet, metrics []string, services int) *core.MetricSet {
+	share := &core.MetricSet{MetricValues: make(map[string]core.MetricValue, len(metrics))}
+	for _, metricName := range metrics {
+		value, found := pod.MetricValues[metricName]
+		if !found {
+			continue
+		}
+		switch value.ValueType {
+		case core.ValueInt64:
+			value.IntValue = int64(float64(value.IntValue)/float64(services) + 0.5)
+		case core.ValueFloat:
+			value.FloatValue /= float32(services)
+		}
+		share.MetricValues[metricName] = value
+	}
+	return share
+}
+
+func serviceMetricSet(podLabels map[string]string, name string) *core.MetricSet {
+	labels := map[string]string{
+		core.LabelMetricSetType.Key: core.MetricSetTypeService,
+		core.LabelServiceName.Key:   name,
+	}
+	for _, label := range []string{
+		core.LabelNamespaceName.Key,
+		core.LabelPodNamespaceUID.Key,
+	} {
+		if value, found := podLabels[label]; found {
+			labels[label] = value
+		}
+	}
+	return &core.MetricSet{
+		MetricValues: make(map[string]core.MetricValue),
+		Labels:       labels,
+	}
+}
+
+func NewServiceAggregator(url *url.URL, metricsToAggregate []string, fractional bool) (*ServiceAggregator, error) {
+	kubeConfig, err := kube_config.GetKubeClientConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	kubeClient := kube_client.NewForConfigOrDie(kubeConfig)
+
+	// watch endpoints
+	lw := cache.NewListWatchFromClient(kubeClient.Core().RESTClient(), "endpoints", kube_api.NamespaceAll, fields.Everything())
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	reflector := cache.NewReflector(lw, &kube_api.Endpoints{}, store, time.Hour)
+	reflector.Run()
+
+	return &ServiceAggregator{
+		endpointsLister:    v1listers.NewEndpointsLister(store),
+		MetricsToAggregate: metricsToAggregate,
+		Fractional:         fractional,
+	}, nil
+}
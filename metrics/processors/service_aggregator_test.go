@@ -0,0 +1,141 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func serviceEndpoints(name string, ready []string, notReady []string) *kube_api.Endpoints {
+	addresses := func(pods []string) []kube_api.EndpointAddress {
+		result := []kube_api.EndpointAddress{}
+		for _, pod := range pods {
+			result = append(result, kube_api.EndpointAddress{
+				IP:        "10.0.0.1",
+				TargetRef: &kube_api.ObjectReference{Kind: "Pod", Namespace: "ns1", Name: pod},
+			})
+		}
+		return result
+	}
+	return &kube_api.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: name},
+		Subsets: []kube_api.EndpointSubset{
+			{Addresses: addresses(ready), NotReadyAddresses: addresses(notReady)},
+			// The same pods exposed on other ports.
+			{Addresses: addresses(ready)},
+		},
+	}
+}
+
+func servicePod(name string, cpu int64, memory float32) *core.MetricSet {
+	return &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key:   core.MetricSetTypePod,
+			core.LabelNamespaceName.Key:   "ns1",
+			core.LabelPodNamespaceUID.Key: "ns1-uid",
+			core.LabelPodName.Key:         name,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuUsageRate.Name: intValue(cpu),
+			core.MetricMemoryUsage.Name:  {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: memory},
+		},
+	}
+}
+
+func serviceBatch() *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "web-1"):   servicePod("web-1", 100, 10),
+			core.PodKey("ns1", "web-2"):   servicePod("web-2", 200, 20),
+			core.PodKey("ns1", "shared"):  servicePod("shared", 301, 30),
+			core.PodKey("ns1", "unbound"): servicePod("unbound", 1000, 100),
+			core.PodContainerKey("ns1", "web-1", "app"): {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePodContainer},
+				MetricValues: map[string]core.MetricValue{core.MetricCpuUsageRate.Name: intValue(100)},
+			},
+		},
+	}
+}
+
+func serviceAggregator(t *testing.T, fractional bool) *ServiceAggregator {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	require.NoError(t, store.Add(serviceEndpoints("web", []string{"web-1", "shared"}, []string{"web-2"})))
+	require.NoError(t, store.Add(serviceEndpoints("api", []string{"shared"}, nil)))
+	// Endpoints not backed by pods.
+	require.NoError(t, store.Add(&kube_api.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "external"},
+		Subsets:    []kube_api.EndpointSubset{{Addresses: []kube_api.EndpointAddress{{IP: "192.168.0.1"}}}},
+	}))
+	return &ServiceAggregator{
+		endpointsLister:    v1listers.NewEndpointsLister(store),
+		MetricsToAggregate: []string{core.MetricCpuUsageRate.Name, core.MetricMemoryUsage.Name},
+		Fractional:         fractional,
+	}
+}
+
+func TestServiceAggregator(t *testing.T) {
+	batch, err := serviceAggregator(t, false).Process(serviceBatch())
+	require.NoError(t, err)
+
+	web, found := batch.MetricSets[core.ServiceKey("ns1", "web")]
+	require.True(t, found)
+	assert.Equal(t, map[string]string{
+		core.LabelMetricSetType.Key:   core.MetricSetTypeService,
+		core.LabelNamespaceName.Key:   "ns1",
+		core.LabelPodNamespaceUID.Key: "ns1-uid",
+		core.LabelServiceName.Key:     "web",
+	}, web.Labels)
+	// The pods are counted once, even though they are listed in several subsets.
+	assert.Equal(t, int64(601), web.MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+	assert.Equal(t, float32(60), web.MetricValues[core.MetricMemoryUsage.Name].FloatValue)
+
+	// The shared pod is counted in full in both services.
+	api, found := batch.MetricSets[core.ServiceKey("ns1", "api")]
+	require.True(t, found)
+	assert.Equal(t, int64(301), api.MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+	assert.Equal(t, float32(30), api.MetricValues[core.MetricMemoryUsage.Name].FloatValue)
+
+	_, found = batch.MetricSets[core.ServiceKey("ns1", "external")]
+	assert.False(t, found)
+	// The pod metric sets are left alone.
+	assert.Equal(t, int64(301), batch.MetricSets[core.PodKey("ns1", "shared")].MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+}
+
+func TestServiceAggregatorFractional(t *testing.T) {
+	batch, err := serviceAggregator(t, true).Process(serviceBatch())
+	require.NoError(t, err)
+
+	web := batch.MetricSets[core.ServiceKey("ns1", "web")]
+	api := batch.MetricSets[core.ServiceKey("ns1", "api")]
+	require.NotNil(t, web)
+	require.NotNil(t, api)
+	// The shared pod is split between the services.
+	assert.Equal(t, int64(451), web.MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+	assert.Equal(t, float32(45), web.MetricValues[core.MetricMemoryUsage.Name].FloatValue)
+	assert.Equal(t, int64(151), api.MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+	assert.Equal(t, float32(15), api.MetricValues[core.MetricMemoryUsage.Name].FloatValue)
+	assert.Equal(t, int64(301), batch.MetricSets[core.PodKey("ns1", "shared")].MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"github.com/golang/glog"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+type systemOverhead struct {
+	cpu, memory int64
+}
+
+// SystemOverheadCalculator computes the share of the CPU and memory usage of every node
+// that goes to its system containers, e.g. the kubelet and the container runtime, rather
+// than to the pods. The CPU usage rate and the memory working set of the system containers
+// of a node are summed up and divided by those of the node. It has to run after the rate
+// calculator, which provides the CPU usage rates.
+type SystemOverheadCalculator struct {
+}
+
+func (this *SystemOverheadCalculator) Name() string {
+	return "system_overhead_calculator"
+}
+
+func (this *SystemOverheadCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	nodes := make(map[string]*systemOverhead)
+	for _, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypeSystemContainer {
+			continue
+		}
+		nodeName := metricSet.Labels[core.LabelNodename.Key]
+		if nodeName == "" {
+			continue
+		}
+		overhead, found := nodes[nodeName]
+		if !found {
+			overhead = &systemOverhead{}
+			nodes[nodeName] = overhead
+		}
+		overhead.cpu += metricSet.MetricValues[core.MetricCpuUsageRate.Name].IntValue
+		overhead.memory += metricSet.MetricValues[core.MetricMemoryWorkingSet.Name].IntValue
+	}
+
+	for nodeName, overhead := range nodes {
+		node, found := batch.MetricSets[core.NodeKey(nodeName)]
+		if !found {
+			glog.V(4).Infof("Skipping system overhead of node %q - no metrics of the node", nodeName)
+			continue
+		}
+		if usage, found := node.MetricValues[core.MetricCpuUsageRate.Name]; found && usage.IntValue > 0 {
+			setFloat(node, &core.MetricNodeSystemOverheadCpuPct, 100*float32(overhead.cpu)/float32(usage.IntValue))
+		}
+		if workingSet, found := node.MetricValues[core.MetricMemoryWorkingSet.Name]; found && workingSet.IntValue > 0 {
+			setFloat(node, &core.MetricNodeSystemOverheadMemoryPct, 100*float32(overhead.memory)/float32(workingSet.IntValue))
+		}
+	}
+	return batch, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func overheadMetricSet(metricSetType, node string, cpu, workingSet int64) *core.MetricSet {
+	return &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: metricSetType,
+			core.LabelNodename.Key:      node,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuUsageRate.Name:     intValue(cpu),
+			core.MetricMemoryWorkingSet.Name: intValue(workingSet),
+		},
+	}
+}
+
+func TestSystemOverheadCalculator(t *testing.T) {
+	batch, err := (&SystemOverheadCalculator{}).Process(&core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NodeKey("n1"):                           overheadMetricSet(core.MetricSetTypeNode, "n1", 2000, 8000),
+			core.NodeContainerKey("n1", "kubelet"):       overheadMetricSet(core.MetricSetTypeSystemContainer, "n1", 100, 400),
+			core.NodeContainerKey("n1", "docker-daemon"): overheadMetricSet(core.MetricSetTypeSystemContainer, "n1", 200, 800),
+			core.PodContainerKey("ns1", "pod1", "app"):   overheadMetricSet(core.MetricSetTypePodContainer, "n1", 1500, 6000),
+			// Without system containers.
+			core.NodeKey("n2"): overheadMetricSet(core.MetricSetTypeNode, "n2", 1000, 1000),
+			// Idle node.
+			core.NodeKey("n3"):                     overheadMetricSet(core.MetricSetTypeNode, "n3", 0, 1000),
+			core.NodeContainerKey("n3", "kubelet"): overheadMetricSet(core.MetricSetTypeSystemContainer, "n3", 0, 500),
+			// Node without metrics.
+			core.NodeContainerKey("n4", "kubelet"): overheadMetricSet(core.MetricSetTypeSystemContainer, "n4", 10, 10),
+		},
+	})
+	require.NoError(t, err)
+
+	n1 := batch.MetricSets[core.NodeKey("n1")].MetricValues
+	assert.InDelta(t, 15, n1[core.MetricNodeSystemOverheadCpuPct.Name].FloatValue, 1e-4)
+	assert.InDelta(t, 15, n1[core.MetricNodeSystemOverheadMemoryPct.Name].FloatValue, 1e-4)
+	assert.Equal(t, core.MetricGauge, n1[core.MetricNodeSystemOverheadCpuPct.Name].MetricType)
+
+	n2 := batch.MetricSets[core.NodeKey("n2")].MetricValues
+	assert.NotContains(t, n2, core.MetricNodeSystemOverheadCpuPct.Name)
+	assert.NotContains(t, n2, core.MetricNodeSystemOverheadMemoryPct.Name)
+
+	n3 := batch.MetricSets[core.NodeKey("n3")].MetricValues
+	assert.NotContains(t, n3, core.MetricNodeSystemOverheadCpuPct.Name)
+	assert.InDelta(t, 50, n3[core.MetricNodeSystemOverheadMemoryPct.Name].FloatValue, 1e-4)
+
+	// The metrics are only set on nodes.
+	for key, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypeNode {
+			assert.NotContains(t, metricSet.MetricValues, core.MetricNodeSystemOverheadCpuPct.Name, key)
+		}
+	}
+}
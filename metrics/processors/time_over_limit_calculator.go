@@ -0,0 +1,138 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// limitInterval is the time between two samples of a container, over the threshold if
+// the later sample was.
+type limitInterval struct {
+	end      time.Time
+	duration time.Duration
+	over     bool
+}
+
+type limitHistory struct {
+	// Time of the latest sample.
+	last time.Time
+	// Intervals ending within the window, oldest first.
+	intervals []limitInterval
+}
+
+// expire drops the intervals ending at or before the given time.
+func (this *limitHistory) expire(before time.Time) {
+	i := 0
+	for i < len(this.intervals) && !this.intervals[i].end.After(before) {
+		i++
+	}
+	this.intervals = this.intervals[i:]
+}
+
+// overPct returns the percentage of the time within the window starting at the given
+// time that was over the threshold. The interval crossing the start of the window only
+// counts with its part within the window.
+func (this *limitHistory) overPct(start time.Time) float32 {
+	var total, over time.Duration
+	for _, interval := range this.intervals {
+		duration := interval.duration
+		if withinWindow := interval.end.Sub(start); withinWindow < duration {
+			duration = withinWindow
+		}
+		total += duration
+		if interval.over {
+			over += duration
+		}
+	}
+	if total <= 0 {
+		return 0
+	}
+	return 100 * float32(over) / float32(total)
+}
+
+// TimeOverLimitCalculator emits the percentage of the time over the window ending with
+// the current batch during which the CPU usage rate or the memory working set of every
+// pod container exceeded the threshold share of its limit. Each sample accounts for the
+// time since the previous sample of the container. A resource without a limit never
+// exceeds it, so a container without limits reports 0. It has to run after the rate
+// calculator and the pod based enricher, which provide the usage rate and the limits.
+type TimeOverLimitCalculator struct {
+	threshold float64
+	window    time.Duration
+	// History by container key.
+	histories map[string]*limitHistory
+}
+
+func (this *TimeOverLimitCalculator) Name() string {
+	return "time_over_limit_calculator"
+}
+
+func (this *TimeOverLimitCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for key, metricSet := range batch.MetricSets {
+		if metricSet.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePodContainer {
+			continue
+		}
+		now := metricSet.ScrapeTime
+		if now.IsZero() {
+			now = batch.Timestamp
+		}
+		over := this.exceeds(metricSet, &core.MetricCpuUsageRate, &core.MetricCpuLimit) ||
+			this.exceeds(metricSet, &core.MetricMemoryWorkingSet, &core.MetricMemoryLimit)
+
+		history, found := this.histories[key]
+		if !found {
+			history = &limitHistory{}
+			this.histories[key] = history
+		}
+		if !history.last.IsZero() && now.After(history.last) {
+			history.intervals = append(history.intervals, limitInterval{end: now, duration: now.Sub(history.last), over: over})
+		}
+		if now.After(history.last) {
+			history.last = now
+		}
+		history.expire(now.Add(-this.window))
+		setFloat(metricSet, &core.MetricContainerTimeOverLimitPct, history.overPct(now.Add(-this.window)))
+	}
+
+	// Forget the containers without samples within the window, e.g. deleted ones.
+	for key, history := range this.histories {
+		if !history.last.After(batch.Timestamp.Add(-this.window)) {
+			delete(this.histories, key)
+		}
+	}
+	return batch, nil
+}
+
+// exceeds returns whether the usage exceeds the threshold share of the limit. Zero and
+// missing limits are unbounded.
+func (this *TimeOverLimitCalculator) exceeds(metricSet *core.MetricSet, usageMetric, limitMetric *core.Metric) bool {
+	usage, found := metricSet.MetricValues[usageMetric.Name]
+	limit, found2 := metricSet.MetricValues[limitMetric.Name]
+	if !found || !found2 || limit.IntValue <= 0 {
+		return false
+	}
+	return float64(usage.IntValue) > this.threshold*float64(limit.IntValue)
+}
+
+func NewTimeOverLimitCalculator(threshold float64, window time.Duration) *TimeOverLimitCalculator {
+	return &TimeOverLimitCalculator{
+		threshold: threshold,
+		window:    window,
+		histories: make(map[string]*limitHistory),
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// overLimitContainer returns a container with the given CPU usage rate and memory working set,
+// and the given limits unless negative.
+func overLimitContainer(timestamp time.Time, cpu, cpuLimit, memory, memoryLimit int64) *core.MetricSet {
+	metricSet := &core.MetricSet{
+		ScrapeTime: timestamp,
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuUsageRate.Name:     intValue(cpu),
+			core.MetricMemoryWorkingSet.Name: intValue(memory),
+		},
+	}
+	if cpuLimit >= 0 {
+		metricSet.MetricValues[core.MetricCpuLimit.Name] = intValue(cpuLimit)
+	}
+	if memoryLimit >= 0 {
+		metricSet.MetricValues[core.MetricMemoryLimit.Name] = intValue(memoryLimit)
+	}
+	return metricSet
+}
+
+func timeOverLimit(t *testing.T, batch *core.DataBatch, key string) float32 {
+	value, found := batch.MetricSets[key].MetricValues[core.MetricContainerTimeOverLimitPct.Name]
+	require.True(t, found, key)
+	return value.FloatValue
+}
+
+func TestTimeOverLimitCalculator(t *testing.T) {
+	calculator := NewTimeOverLimitCalculator(0.9, 4*time.Minute)
+	key := core.PodContainerKey("ns1", "pod1", "app")
+	start := time.Now().Truncate(time.Minute)
+
+	// Scraped every minute, with a CPU limit of 1000m and a memory limit of 1000 bytes.
+	for i, step := range []struct {
+		cpu, memory int64
+		expected    float32
+	}{
+		// No time has passed yet.
+		{cpu: 950, memory: 100, expected: 0},
+		// Under both limits for a minute.
+		{cpu: 500, memory: 100, expected: 0},
+		// CPU over 90% of the limit for a minute.
+		{cpu: 950, memory: 100, expected: 50},
+		// Memory over 90% of the limit for a minute.
+		{cpu: 500, memory: 950, expected: 200.0 / 3},
+		// At the threshold.
+		{cpu: 900, memory: 900, expected: 50},
+		// The first minute drops out of the window.
+		{cpu: 500, memory: 100, expected: 50},
+		{cpu: 500, memory: 100, expected: 25},
+		{cpu: 500, memory: 100, expected: 0},
+	} {
+		timestamp := start.Add(time.Duration(i) * time.Minute)
+		batch := &core.DataBatch{
+			Timestamp:  timestamp,
+			MetricSets: map[string]*core.MetricSet{key: overLimitContainer(timestamp, step.cpu, 1000, step.memory, 1000)},
+		}
+		batch, err := calculator.Process(batch)
+		require.NoError(t, err)
+		assert.InDelta(t, step.expected, timeOverLimit(t, batch, key), 1e-4, "step %d", i)
+	}
+}
+
+func TestTimeOverLimitIrregularScrapes(t *testing.T) {
+	calculator := NewTimeOverLimitCalculator(0.9, 10*time.Minute)
+	key := core.PodContainerKey("ns1", "pod1", "app")
+	start := time.Now().Truncate(time.Minute)
+
+	for _, step := range []struct {
+		offset time.Duration
+		cpu    int64
+	}{
+		{0, 100},
+		// Over the limit for 3 minutes, then under it for 1 minute.
+		{3 * time.Minute, 950},
+		{4 * time.Minute, 100},
+	} {
+		timestamp := start.Add(step.offset)
+		batch := &core.DataBatch{
+			Timestamp:  timestamp,
+			MetricSets: map[string]*core.MetricSet{key: overLimitContainer(timestamp, step.cpu, 1000, 0, 1000)},
+		}
+		_, err := calculator.Process(batch)
+		require.NoError(t, err)
+	}
+	timestamp := start.Add(5 * time.Minute)
+	batch, err := calculator.Process(&core.DataBatch{
+		Timestamp:  timestamp,
+		MetricSets: map[string]*core.MetricSet{key: overLimitContainer(timestamp, 100, 1000, 0, 1000)},
+	})
+	require.NoError(t, err)
+	assert.InDelta(t, 60, timeOverLimit(t, batch, key), 1e-4)
+}
+
+func TestTimeOverLimitWithoutLimits(t *testing.T) {
+	calculator := NewTimeOverLimitCalculator(0.9, 15*time.Minute)
+	start := time.Now().Truncate(time.Minute)
+	unbounded := core.PodContainerKey("ns1", "pod1", "unbounded")
+	zeroLimits := core.PodContainerKey("ns1", "pod1", "zero")
+	memoryOnly := core.PodContainerKey("ns1", "pod1", "memory-only")
+
+	var batch *core.DataBatch
+	for i := 0; i < 3; i++ {
+		timestamp := start.Add(time.Duration(i) * time.Minute)
+		var err error
+		batch, err = calculator.Process(&core.DataBatch{
+			Timestamp: timestamp,
+			MetricSets: map[string]*core.MetricSet{
+				unbounded:  overLimitContainer(timestamp, 5000, -1, 5000, -1),
+				zeroLimits: overLimitContainer(timestamp, 5000, 0, 5000, 0),
+				// Only the memory limit counts, the CPU usage is unbounded.
+				memoryOnly: overLimitContainer(timestamp, 5000, -1, 100, 1000),
+				core.PodKey("ns1", "pod1"): {
+					Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePod},
+					MetricValues: map[string]core.MetricValue{},
+				},
+			},
+		})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, float32(0), timeOverLimit(t, batch, unbounded))
+	assert.Equal(t, float32(0), timeOverLimit(t, batch, zeroLimits))
+	assert.Equal(t, float32(0), timeOverLimit(t, batch, memoryOnly))
+	_, found := batch.MetricSets[core.PodKey("ns1", "pod1")].MetricValues[core.MetricContainerTimeOverLimitPct.Name]
+	assert.False(t, found)
+}
+
+func TestTimeOverLimitForgetsDeletedContainers(t *testing.T) {
+	calculator := NewTimeOverLimitCalculator(0.9, 2*time.Minute)
+	start := time.Now().Truncate(time.Minute)
+	key := core.PodContainerKey("ns1", "pod1", "app")
+	_, err := calculator.Process(&core.DataBatch{
+		Timestamp:  start,
+		MetricSets: map[string]*core.MetricSet{key: overLimitContainer(start, 950, 1000, 0, 1000)},
+	})
+	require.NoError(t, err)
+	assert.Len(t, calculator.histories, 1)
+
+	_, err = calculator.Process(&core.DataBatch{
+		Timestamp:  start.Add(3 * time.Minute),
+		MetricSets: map[string]*core.MetricSet{},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, calculator.histories)
+}
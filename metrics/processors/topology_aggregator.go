@@ -0,0 +1,76 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// TopologyAggregator rolls up node metrics by the zone and region set by the topology
+// enricher into zone and region metric sets. Nodes without a zone or region are skipped.
+type TopologyAggregator struct {
+	MetricsToAggregate []string
+}
+
+func (this *TopologyAggregator) Name() string {
+	return "topology_aggregator"
+}
+
+func (this *TopologyAggregator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	groups := make(map[string]*core.MetricSet)
+	for _, metricSet := range batch.MetricSets {
+		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypeNode {
+			continue
+		}
+		region := metricSet.Labels[core.LabelRegion.Key]
+		if zone := metricSet.Labels[core.LabelZone.Key]; zone != "" {
+			zoneLabels := map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypeZone,
+				core.LabelZone.Key:          zone,
+			}
+			if region != "" {
+				zoneLabels[core.LabelRegion.Key] = region
+			}
+			if err := this.aggregateInto(groups, core.ZoneKey(zone), zoneLabels, metricSet); err != nil {
+				return nil, err
+			}
+		}
+		if region != "" {
+			regionLabels := map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypeRegion,
+				core.LabelRegion.Key:        region,
+			}
+			if err := this.aggregateInto(groups, core.RegionKey(region), regionLabels, metricSet); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for key, group := range groups {
+		batch.MetricSets[key] = group
+	}
+	return batch, nil
+}
+
+func (this *TopologyAggregator) aggregateInto(groups map[string]*core.MetricSet, key string, labels map[string]string, node *core.MetricSet) error {
+	group, found := groups[key]
+	if !found {
+		group = &core.MetricSet{
+			MetricValues: make(map[string]core.MetricValue),
+			Labels:       labels,
+		}
+		groups[key] = group
+	}
+	return aggregate(node, group, this.MetricsToAggregate)
+}
@@ -0,0 +1,58 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestTopologyAggregator(t *testing.T) {
+	batch, err := NewTopologyEnricher(topologyNodes()).Process(topologyBatch())
+	assert.NoError(t, err)
+	aggregator := &TopologyAggregator{
+		MetricsToAggregate: []string{core.MetricCpuUsageRate.Name, core.MetricMemoryUsage.Name},
+	}
+	batch, err = aggregator.Process(batch)
+	assert.NoError(t, err)
+	// Two zones and a region on top of the 8 metric sets.
+	assert.Equal(t, 11, len(batch.MetricSets))
+
+	zoneB, found := batch.MetricSets[core.ZoneKey("us-east1-b")]
+	if assert.True(t, found) {
+		assert.Equal(t, core.MetricSetTypeZone, zoneB.Labels[core.LabelMetricSetType.Key])
+		assert.Equal(t, "us-east1-b", zoneB.Labels[core.LabelZone.Key])
+		assert.Equal(t, "us-east1", zoneB.Labels[core.LabelRegion.Key])
+		// Only the nodes are summed up, not their pods and containers.
+		assert.Equal(t, float32(3), zoneB.MetricValues[core.MetricCpuUsageRate.Name].FloatValue)
+		assert.Equal(t, int64(300), zoneB.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	}
+	zoneC, found := batch.MetricSets[core.ZoneKey("us-east1-c")]
+	if assert.True(t, found) {
+		assert.Equal(t, float32(4), zoneC.MetricValues[core.MetricCpuUsageRate.Name].FloatValue)
+		assert.Equal(t, int64(400), zoneC.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	}
+	region, found := batch.MetricSets[core.RegionKey("us-east1")]
+	if assert.True(t, found) {
+		assert.Equal(t, core.MetricSetTypeRegion, region.Labels[core.LabelMetricSetType.Key])
+		assert.Equal(t, "us-east1", region.Labels[core.LabelRegion.Key])
+		assert.NotContains(t, region.Labels, core.LabelZone.Key)
+		assert.Equal(t, float32(7), region.MetricValues[core.MetricCpuUsageRate.Name].FloatValue)
+		assert.Equal(t, int64(700), region.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/heapster/metrics/core"
+)
+
+// Node labels holding the zone and region, the GA ones first.
+var (
+	zoneNodeLabels   = []string{"topology.kubernetes.io/zone", "failure-domain.beta.kubernetes.io/zone"}
+	regionNodeLabels = []string{"topology.kubernetes.io/region", "failure-domain.beta.kubernetes.io/region"}
+)
+
+// TopologyEnricher copies the zone and region of the nodes onto the node, system container,
+// pod and container metric sets, based on their nodename label.
+type TopologyEnricher struct {
+	nodeLister v1listers.NodeLister
+}
+
+func (this *TopologyEnricher) Name() string {
+	return "topology_enricher"
+}
+
+func (this *TopologyEnricher) Stateless() {}
+
+func (this *TopologyEnricher) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	nodes, err := this.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	zones := make(map[string]string, len(nodes))
+	regions := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		if zone := firstLabel(node.Labels, zoneNodeLabels); zone != "" {
+			zones[node.Name] = zone
+		}
+		if region := firstLabel(node.Labels, regionNodeLabels); region != "" {
+			regions[node.Name] = region
+		}
+	}
+
+	for _, metricSet := range batch.MetricSets {
+		switch metricSet.Labels[core.LabelMetricSetType.Key] {
+		case core.MetricSetTypeNode, core.MetricSetTypeSystemContainer, core.MetricSetTypePod, core.MetricSetTypePodContainer:
+		default:
+			continue
+		}
+		nodeName := metricSet.Labels[core.LabelNodename.Key]
+		if zone, found := zones[nodeName]; found {
+			metricSet.Labels[core.LabelZone.Key] = zone
+		}
+		if region, found := regions[nodeName]; found {
+			metricSet.Labels[core.LabelRegion.Key] = region
+		}
+	}
+	return batch, nil
+}
+
+func firstLabel(nodeLabels map[string]string, keys []string) string {
+	for _, key := range keys {
+		if value := nodeLabels[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func NewTopologyEnricher(nodeLister v1listers.NodeLister) *TopologyEnricher {
+	return &TopologyEnricher{
+		nodeLister: nodeLister,
+	}
+}
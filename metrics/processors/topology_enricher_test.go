@@ -0,0 +1,112 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func topologyNodeLister(nodes ...*kube_api.Node) v1listers.NodeLister {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range nodes {
+		store.Add(node)
+	}
+	return v1listers.NewNodeLister(store)
+}
+
+func topologyNode(name string, nodeLabels map[string]string) *kube_api.Node {
+	return &kube_api.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: nodeLabels,
+		},
+	}
+}
+
+func topologyMetricSet(metricSetType, nodeName string, cpu float32, memory int64) *core.MetricSet {
+	return &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: metricSetType,
+			core.LabelNodename.Key:      nodeName,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuUsageRate.Name: floatValue(cpu),
+			core.MetricMemoryUsage.Name:  intValue(memory),
+		},
+	}
+}
+
+func topologyBatch() *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NodeKey("n1"):                        topologyMetricSet(core.MetricSetTypeNode, "n1", 1, 100),
+			core.NodeKey("n2"):                        topologyMetricSet(core.MetricSetTypeNode, "n2", 2, 200),
+			core.NodeKey("n3"):                        topologyMetricSet(core.MetricSetTypeNode, "n3", 4, 400),
+			core.NodeKey("n4"):                        topologyMetricSet(core.MetricSetTypeNode, "n4", 8, 800),
+			core.PodKey("ns1", "pod1"):                topologyMetricSet(core.MetricSetTypePod, "n1", 0.5, 50),
+			core.PodContainerKey("ns1", "pod1", "c1"): topologyMetricSet(core.MetricSetTypePodContainer, "n1", 0.5, 50),
+			core.NodeContainerKey("n3", "kubelet"):    topologyMetricSet(core.MetricSetTypeSystemContainer, "n3", 0.1, 10),
+			core.NamespaceKey("ns1"):                  {Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNamespace}},
+		},
+	}
+}
+
+func topologyNodes() v1listers.NodeLister {
+	return topologyNodeLister(
+		topologyNode("n1", map[string]string{
+			"topology.kubernetes.io/zone":   "us-east1-b",
+			"topology.kubernetes.io/region": "us-east1",
+		}),
+		topologyNode("n2", map[string]string{
+			"topology.kubernetes.io/zone":   "us-east1-b",
+			"topology.kubernetes.io/region": "us-east1",
+		}),
+		// Nodes of older clusters only have the beta labels.
+		topologyNode("n3", map[string]string{
+			"failure-domain.beta.kubernetes.io/zone":   "us-east1-c",
+			"failure-domain.beta.kubernetes.io/region": "us-east1",
+		}),
+		topologyNode("n4", nil),
+	)
+}
+
+func TestTopologyEnricher(t *testing.T) {
+	batch, err := NewTopologyEnricher(topologyNodes()).Process(topologyBatch())
+	assert.NoError(t, err)
+
+	for key, zone := range map[string]string{
+		core.NodeKey("n1"):                        "us-east1-b",
+		core.NodeKey("n3"):                        "us-east1-c",
+		core.PodKey("ns1", "pod1"):                "us-east1-b",
+		core.PodContainerKey("ns1", "pod1", "c1"): "us-east1-b",
+		core.NodeContainerKey("n3", "kubelet"):    "us-east1-c",
+	} {
+		assert.Equal(t, zone, batch.MetricSets[key].Labels[core.LabelZone.Key], key)
+		assert.Equal(t, "us-east1", batch.MetricSets[key].Labels[core.LabelRegion.Key], key)
+	}
+	assert.NotContains(t, batch.MetricSets[core.NodeKey("n4")].Labels, core.LabelZone.Key)
+	assert.NotContains(t, batch.MetricSets[core.NodeKey("n4")].Labels, core.LabelRegion.Key)
+	assert.NotContains(t, batch.MetricSets[core.NamespaceKey("ns1")].Labels, core.LabelZone.Key)
+}
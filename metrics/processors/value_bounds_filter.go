@@ -0,0 +1,138 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+var droppedOutOfBoundsValues = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "heapster",
+		Subsystem: "processor",
+		Name:      "out_of_bounds_values_total",
+		Help:      "The number of metric values dropped because they exceeded the maximum configured for the metric or were negative.",
+	},
+	[]string{"metric"},
+)
+
+func init() {
+	prometheus.MustRegister(droppedOutOfBoundsValues)
+}
+
+// ValueBoundsFilter drops the values of the metrics exceeding the maximum configured for
+// them, e.g. the memory usage of 2^64 bytes reported by cadvisor after a counter glitch,
+// so that a single bogus sample does not skew the rates and the aggregates. Such a value
+// may also have wrapped around to a negative one in the conversion to int64, so negative
+// values of the bounded metrics that can not be negative are dropped as well. It has to run
+// before the rate calculator.
+type ValueBoundsFilter struct {
+	MaxValues map[string]float64
+}
+
+func (this *ValueBoundsFilter) Name() string {
+	return "value_bounds_filter"
+}
+
+func (this *ValueBoundsFilter) Stateless() {}
+
+func (this *ValueBoundsFilter) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	for key, metricSet := range batch.MetricSets {
+		for name, value := range metricSet.MetricValues {
+			if this.outOfBounds(name, value) {
+				glog.V(2).Infof("Dropping %s of %s - %v is out of bounds, maximum %v", name, key, value.GetValue(), this.MaxValues[name])
+				delete(metricSet.MetricValues, name)
+				droppedOutOfBoundsValues.WithLabelValues(name).Inc()
+			}
+		}
+		labeledMetrics := metricSet.LabeledMetrics[:0]
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			if this.outOfBounds(labeledMetric.Name, labeledMetric.MetricValue) {
+				glog.V(2).Infof("Dropping %s of %s - %v is out of bounds, maximum %v", labeledMetric.Name, key, labeledMetric.GetValue(), this.MaxValues[labeledMetric.Name])
+				droppedOutOfBoundsValues.WithLabelValues(labeledMetric.Name).Inc()
+				continue
+			}
+			labeledMetrics = append(labeledMetrics, labeledMetric)
+		}
+		metricSet.LabeledMetrics = labeledMetrics
+		for _, sample := range metricSet.Samples {
+			for name, value := range sample.MetricValues {
+				if this.outOfBounds(name, value) {
+					delete(sample.MetricValues, name)
+					droppedOutOfBoundsValues.WithLabelValues(name).Inc()
+				}
+			}
+		}
+	}
+	return batch, nil
+}
+
+func (this *ValueBoundsFilter) outOfBounds(name string, value core.MetricValue) bool {
+	maxValue, found := this.MaxValues[name]
+	if !found {
+		return false
+	}
+	var v float64
+	switch value.ValueType {
+	case core.ValueInt64:
+		v = float64(value.IntValue)
+	case core.ValueFloat:
+		v = float64(value.FloatValue)
+	default:
+		return false
+	}
+	return v > maxValue || v < 0 && nonNegativeMetrics[name]
+}
+
+// Names of the metrics whose values can not be negative: the cumulative ones and the
+// amounts of bytes, time or CPU.
+var nonNegativeMetrics = func() map[string]bool {
+	names := make(map[string]bool)
+	for _, metric := range core.AllMetrics {
+		switch {
+		case metric.Type == core.MetricCumulative,
+			metric.Units == core.UnitsBytes,
+			metric.Units == core.UnitsMilliseconds,
+			metric.Units == core.UnitsNanoseconds,
+			metric.Units == core.UnitsMillicores:
+			names[metric.Name] = true
+		}
+	}
+	return names
+}()
+
+// ParseMetricMaxValues parses the maximum values of the metrics, in the form <metric>=<max>.
+func ParseMetricMaxValues(specs []string) (map[string]float64, error) {
+	maxValues := make(map[string]float64, len(specs))
+	for _, spec := range specs {
+		i := strings.LastIndex(spec, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid metric maximum %q - expected <metric>=<max>", spec)
+		}
+		maxValue, err := strconv.ParseFloat(spec[i+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric maximum %q - %v", spec, err)
+		}
+		maxValues[spec[:i]] = maxValue
+	}
+	return maxValues, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	cadvisor_api "github.com/google/cadvisor/info/v1"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func droppedValues(t *testing.T, metric string) float64 {
+	counter := &dto.Metric{}
+	require.NoError(t, droppedOutOfBoundsValues.WithLabelValues(metric).Write(counter))
+	return counter.GetCounter().GetValue()
+}
+
+func TestValueBoundsFilter(t *testing.T) {
+	now := time.Now()
+	glitch := int64(math.MaxInt64 - 1000)
+	key := core.PodContainerKey("ns1", "pod1", "app")
+	batch := &core.DataBatch{
+		Timestamp: now,
+		MetricSets: map[string]*core.MetricSet{
+			key: {
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePodContainer},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryUsage.Name:      intValue(glitch),
+					core.MetricMemoryWorkingSet.Name: intValue(500),
+					core.MetricCpuUsage.Name:         {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: glitch},
+					"custom/load":                    {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 1e12},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{Name: core.MetricFilesystemUsage.Name, Labels: map[string]string{core.LabelResourceID.Key: "/dev/sda1"}, MetricValue: intValue(glitch)},
+					{Name: core.MetricFilesystemUsage.Name, Labels: map[string]string{core.LabelResourceID.Key: "/dev/sdb1"}, MetricValue: intValue(2048)},
+				},
+				Samples: []core.MetricSample{{
+					Timestamp: now.Add(-10 * time.Second),
+					MetricValues: map[string]core.MetricValue{
+						core.MetricMemoryUsage.Name: intValue(glitch),
+					},
+				}},
+			},
+		},
+	}
+	maxValues, err := ParseMetricMaxValues([]string{
+		"memory/usage=1e13",
+		"memory/working_set=1000",
+		"cpu/usage=1e18",
+		"custom/load=1000",
+		"filesystem/usage=1e13",
+	})
+	require.NoError(t, err)
+	memoryDropped := droppedValues(t, core.MetricMemoryUsage.Name)
+	filesystemDropped := droppedValues(t, core.MetricFilesystemUsage.Name)
+
+	batch, err = (&ValueBoundsFilter{MaxValues: maxValues}).Process(batch)
+	require.NoError(t, err)
+
+	metricSet := batch.MetricSets[key]
+	assert.Equal(t, []string{core.MetricFilesystemUsage.Name, core.MetricMemoryWorkingSet.Name}, metricNames(metricSet))
+	require.Len(t, metricSet.LabeledMetrics, 1)
+	assert.Equal(t, "/dev/sdb1", metricSet.LabeledMetrics[0].Labels[core.LabelResourceID.Key])
+	assert.Empty(t, metricSet.Samples[0].MetricValues)
+	// Both the value and the sample of memory/usage are counted.
+	assert.Equal(t, memoryDropped+2, droppedValues(t, core.MetricMemoryUsage.Name))
+	assert.Equal(t, filesystemDropped+1, droppedValues(t, core.MetricFilesystemUsage.Name))
+}
+
+func TestValueBoundsFilterWrappedValues(t *testing.T) {
+	// A usage close to 2^64 wraps around to a negative value in the conversion to int64.
+	spec := &cadvisor_api.ContainerSpec{HasMemory: true}
+	stats := &cadvisor_api.ContainerStats{Memory: cadvisor_api.MemoryStats{Usage: math.MaxUint64 - 1000, WorkingSet: 500}}
+	memoryUsage := core.MetricMemoryUsage.GetValue(spec, stats)
+	require.True(t, memoryUsage.IntValue < 0)
+
+	key := core.NodeKey("node1")
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			key: {
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeNode},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryUsage.Name:      memoryUsage,
+					core.MetricMemoryWorkingSet.Name: core.MetricMemoryWorkingSet.GetValue(spec, stats),
+					// Metrics that may be negative are kept.
+					core.MetricNodeClockSkew.Name: {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: -2},
+					"custom/delta":                {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: -5},
+				},
+			},
+		},
+	}
+	maxValues, err := ParseMetricMaxValues([]string{
+		"memory/usage=1e13",
+		"memory/working_set=1e13",
+		"node/clock_skew_seconds=3600",
+		"custom/delta=100",
+	})
+	require.NoError(t, err)
+	memoryDropped := droppedValues(t, core.MetricMemoryUsage.Name)
+
+	batch, err = (&ValueBoundsFilter{MaxValues: maxValues}).Process(batch)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"custom/delta", core.MetricMemoryWorkingSet.Name, core.MetricNodeClockSkew.Name}, metricNames(batch.MetricSets[key]))
+	assert.Equal(t, memoryDropped+1, droppedValues(t, core.MetricMemoryUsage.Name))
+}
+
+func TestParseMetricMaxValues(t *testing.T) {
+	maxValues, err := ParseMetricMaxValues([]string{"memory/usage=1e13", "custom/a=b=2.5"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"memory/usage": 1e13, "custom/a=b": 2.5}, maxValues)
+
+	for _, spec := range []string{"memory/usage", "=10", "memory/usage=lots"} {
+		_, err := ParseMetricMaxValues([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
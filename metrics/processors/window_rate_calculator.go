@@ -0,0 +1,192 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+type rateSample struct {
+	timestamp time.Time
+	value     int64
+}
+
+// rateWindow is a ring buffer holding the latest samples of a cumulative metric.
+type rateWindow struct {
+	collectionStartTime time.Time
+	samples             []rateSample
+	// Index of the oldest sample once the buffer is full.
+	next  int
+	count int
+	// Rate computed for the newest sample, if any.
+	rate    core.MetricValue
+	hasRate bool
+}
+
+func newRateWindow(size int, collectionStartTime time.Time) *rateWindow {
+	return &rateWindow{
+		collectionStartTime: collectionStartTime,
+		samples:             make([]rateSample, size),
+	}
+}
+
+func (this *rateWindow) add(sample rateSample) {
+	this.samples[this.next] = sample
+	this.next = (this.next + 1) % len(this.samples)
+	if this.count < len(this.samples) {
+		this.count++
+	}
+}
+
+func (this *rateWindow) oldest() rateSample {
+	if this.count < len(this.samples) {
+		return this.samples[0]
+	}
+	return this.samples[this.next]
+}
+
+func (this *rateWindow) newest() rateSample {
+	return this.samples[(this.next+len(this.samples)-1)%len(this.samples)]
+}
+
+// WindowRateCalculator is an alternative to RateCalculator that computes the rates
+// of cumulative metrics over the last N samples instead of the last two, which
+// smooths out the jitter of the scrape intervals. The rate is the difference between
+// the newest and the oldest sample of the window divided by the time between them.
+type WindowRateCalculator struct {
+	rateMetricsMapping map[string]core.Metric
+	windowSize         int
+	// Windows of the metrics missing in a batch, e.g. of nodes scraped less often than in
+	// every cycle, are kept for up to this long after their newest sample.
+	retention time.Duration
+	// Sample windows by metric set key, metric name and metric labels.
+	windows map[string]*rateWindow
+}
+
+func (this *WindowRateCalculator) Name() string {
+	return "window rate calculator"
+}
+
+func (this *WindowRateCalculator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	seen := make(map[string]struct{})
+	for key, metricSet := range batch.MetricSets {
+		for metricName, value := range metricSet.MetricValues {
+			targetMetric, found := this.rateMetricsMapping[metricName]
+			if !found {
+				continue
+			}
+			windowKey := key + "|" + metricName
+			seen[windowKey] = struct{}{}
+			if rate, found := this.rate(windowKey, metricSet, metricName, targetMetric, value); found {
+				metricSet.MetricValues[targetMetric.Name] = rate
+			}
+		}
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			targetMetric, found := this.rateMetricsMapping[labeledMetric.Name]
+			if !found {
+				continue
+			}
+			windowKey := key + "|" + labeledMetric.Name + "|" + core.LabelsKey(labeledMetric.Labels)
+			seen[windowKey] = struct{}{}
+			if rate, found := this.rate(windowKey, metricSet, labeledMetric.Name, targetMetric, labeledMetric.MetricValue); found {
+				metricSet.LabeledMetrics = append(metricSet.LabeledMetrics, core.LabeledMetric{
+					Name:        targetMetric.Name,
+					Labels:      labeledMetric.Labels,
+					MetricValue: rate,
+				})
+			}
+		}
+	}
+
+	for windowKey, window := range this.windows {
+		if _, found := seen[windowKey]; found {
+			continue
+		}
+		if this.retention <= 0 || batch.Timestamp.Sub(window.newest().timestamp) > this.retention {
+			delete(this.windows, windowKey)
+		}
+	}
+	return batch, nil
+}
+
+// rate records the value in the window of the metric and returns the rate over the window,
+// or false if the window holds a single sample.
+func (this *WindowRateCalculator) rate(windowKey string, metricSet *core.MetricSet, metricName string,
+	targetMetric core.Metric, value core.MetricValue) (core.MetricValue, bool) {
+	sample := rateSample{timestamp: metricSet.ScrapeTime, value: value.IntValue}
+	window, found := this.windows[windowKey]
+	if found && !metricSet.CollectionStartTime.Equal(window.collectionStartTime) {
+		glog.V(4).Infof("Resetting rate window of %s - different collection start time", windowKey)
+		found = false
+	} else if found && sample.value < window.newest().value {
+		glog.V(4).Infof("Resetting rate window of %s - the counter was reset", windowKey)
+		found = false
+	}
+	if !found {
+		window = newRateWindow(this.windowSize, metricSet.CollectionStartTime)
+		this.windows[windowKey] = window
+	}
+	if window.count > 0 && sample == window.newest() {
+		// A sample carried forward from an earlier cycle keeps the rate computed for it then.
+		return window.rate, window.hasRate
+	}
+	if window.count > 0 && !sample.timestamp.After(window.newest().timestamp) {
+		glog.V(4).Infof("Skipping sample of %s - not scraped after the previous one", windowKey)
+		return core.MetricValue{}, false
+	}
+	window.add(sample)
+	window.rate, window.hasRate = windowRate(window, metricName, targetMetric)
+	return window.rate, window.hasRate
+}
+
+// windowRate returns the rate over the window, or false if the window holds a single sample.
+func windowRate(window *rateWindow, metricName string, targetMetric core.Metric) (core.MetricValue, bool) {
+	if window.count < 2 {
+		return core.MetricValue{}, false
+	}
+
+	oldest, newest := window.oldest(), window.newest()
+	delta := newest.value - oldest.value
+	elapsed := newest.timestamp.UnixNano() - oldest.timestamp.UnixNano()
+	if metricName == core.MetricCpuUsage.Name {
+		// cpu/usage values are in nanoseconds; the rate is in millicores.
+		return core.MetricValue{
+			ValueType:  core.ValueInt64,
+			MetricType: core.MetricGauge,
+			IntValue:   1000 * delta / elapsed,
+		}, true
+	}
+	if targetMetric.ValueType != core.ValueFloat {
+		return core.MetricValue{}, false
+	}
+	return core.MetricValue{
+		ValueType:  core.ValueFloat,
+		MetricType: core.MetricGauge,
+		FloatValue: 1e9 * float32(delta) / float32(elapsed),
+	}, true
+}
+
+func NewWindowRateCalculator(metrics map[string]core.Metric, windowSize int, retention time.Duration) *WindowRateCalculator {
+	return &WindowRateCalculator{
+		rateMetricsMapping: metrics,
+		windowSize:         windowSize,
+		retention:          retention,
+		windows:            make(map[string]*rateWindow),
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func cumulativeValue(value int64) core.MetricValue {
+	return core.MetricValue{
+		ValueType:  core.ValueInt64,
+		MetricType: core.MetricCumulative,
+		IntValue:   value,
+	}
+}
+
+func windowRateBatch(scrapeTime, started time.Time, cpu, rx, diskRead int64) *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: scrapeTime,
+		MetricSets: map[string]*core.MetricSet{
+			"pod": {
+				ScrapeTime:          scrapeTime,
+				CollectionStartTime: started,
+				Labels:              map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePod},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsage.Name:  cumulativeValue(cpu),
+					core.MetricNetworkRx.Name: cumulativeValue(rx),
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:        core.MetricDiskIORead.Name,
+						Labels:      map[string]string{core.LabelResourceID.Key: "sda"},
+						MetricValue: cumulativeValue(diskRead),
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestWindowRateCalculator(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+	started := start.Add(-time.Hour)
+	calculator := NewWindowRateCalculator(core.RateMetricsMapping, 3, 0)
+
+	for i, tc := range []struct {
+		offset   time.Duration
+		cpu      int64
+		rx       int64
+		diskRead int64
+		// Expected rates, negative if none is expected.
+		cpuRate      int64
+		rxRate       float32
+		diskReadRate float32
+	}{
+		{0, 0, 0, 0, -1, -1, -1},
+		// Two samples - same as the rate between consecutive scrapes.
+		{10 * time.Second, 10e9, 1000, 100, 1000, 100, 10},
+		{20 * time.Second, 30e9, 3000, 300, 1500, 150, 15},
+		// The window slides, the first sample is dropped.
+		{30 * time.Second, 40e9, 4000, 400, 1500, 150, 15},
+		// The scrape interval jitters, but the rate stays smooth.
+		{45 * time.Second, 55e9, 5500, 550, 1000, 100, 10},
+		{50 * time.Second, 60e9, 6000, 600, 1000, 100, 10},
+	} {
+		batch, err := calculator.Process(windowRateBatch(start.Add(tc.offset), started, tc.cpu, tc.rx, tc.diskRead))
+		assert.NoError(t, err)
+		metricSet := batch.MetricSets["pod"]
+
+		cpuRate, found := metricSet.MetricValues[core.MetricCpuUsageRate.Name]
+		if assert.Equal(t, tc.cpuRate >= 0, found, "sample %d", i) && found {
+			assert.Equal(t, tc.cpuRate, cpuRate.IntValue, "sample %d", i)
+		}
+		rxRate, found := metricSet.MetricValues[core.MetricNetworkRxRate.Name]
+		if assert.Equal(t, tc.rxRate >= 0, found, "sample %d", i) && found {
+			assert.InDelta(t, tc.rxRate, rxRate.FloatValue, 1e-3, "sample %d", i)
+		}
+		if tc.diskReadRate >= 0 {
+			if assert.Equal(t, 2, len(metricSet.LabeledMetrics), "sample %d", i) {
+				diskRate := metricSet.LabeledMetrics[1]
+				assert.Equal(t, core.MetricDiskIOReadRate.Name, diskRate.Name)
+				assert.Equal(t, "sda", diskRate.Labels[core.LabelResourceID.Key])
+				assert.InDelta(t, tc.diskReadRate, diskRate.FloatValue, 1e-3, "sample %d", i)
+			}
+		} else {
+			assert.Equal(t, 1, len(metricSet.LabeledMetrics), "sample %d", i)
+		}
+	}
+}
+
+func TestWindowRateCalculatorResets(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+	started := start.Add(-time.Hour)
+	calculator := NewWindowRateCalculator(core.RateMetricsMapping, 5, 0)
+
+	process := func(offset time.Duration, started time.Time, cpu int64) (int64, bool) {
+		batch, err := calculator.Process(windowRateBatch(start.Add(offset), started, cpu, 0, 0))
+		assert.NoError(t, err)
+		rate, found := batch.MetricSets["pod"].MetricValues[core.MetricCpuUsageRate.Name]
+		return rate.IntValue, found
+	}
+
+	process(0, started, 0)
+	rate, found := process(10*time.Second, started, 10e9)
+	assert.True(t, found)
+	assert.Equal(t, int64(1000), rate)
+
+	// A sample that is not newer than the previous one is ignored.
+	_, found = process(10*time.Second, started, 20e9)
+	assert.False(t, found)
+
+	// The counter is reset, e.g. the container was restarted in place.
+	_, found = process(20*time.Second, started, 1e9)
+	assert.False(t, found)
+	rate, found = process(30*time.Second, started, 3e9)
+	assert.True(t, found)
+	assert.Equal(t, int64(200), rate)
+
+	// The container was recreated.
+	_, found = process(40*time.Second, start, 50e9)
+	assert.False(t, found)
+	rate, found = process(50*time.Second, start, 55e9)
+	assert.True(t, found)
+	assert.Equal(t, int64(500), rate)
+
+	// Windows of metric sets missing from a batch are dropped.
+	_, err := calculator.Process(&core.DataBatch{Timestamp: start.Add(time.Minute), MetricSets: map[string]*core.MetricSet{}})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(calculator.windows))
+}
+
+func TestWindowRateCalculatorRetention(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+	started := start.Add(-time.Hour)
+	empty := func(timestamp time.Time) *core.DataBatch {
+		return &core.DataBatch{Timestamp: timestamp, MetricSets: map[string]*core.MetricSet{}}
+	}
+
+	// The pod is scraped every 30s, in every third cycle.
+	calculator := NewWindowRateCalculator(core.RateMetricsMapping, 2, 30*time.Second)
+	calculator.Process(windowRateBatch(start, started, 0, 0, 0))
+	calculator.Process(empty(start.Add(10 * time.Second)))
+	calculator.Process(empty(start.Add(20 * time.Second)))
+	batch, err := calculator.Process(windowRateBatch(start.Add(30*time.Second), started, 30*1e9, 3000, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), batch.MetricSets["pod"].MetricValues[core.MetricCpuUsageRate.Name].IntValue)
+	assert.Equal(t, float32(100), batch.MetricSets["pod"].MetricValues[core.MetricNetworkRxRate.Name].FloatValue)
+
+	// Windows older than the retention are dropped.
+	calculator.Process(empty(start.Add(61 * time.Second)))
+	batch, err = calculator.Process(windowRateBatch(start.Add(70*time.Second), started, 70*1e9, 7000, 0))
+	assert.NoError(t, err)
+	assert.NotContains(t, batch.MetricSets["pod"].MetricValues, core.MetricCpuUsageRate.Name)
+}
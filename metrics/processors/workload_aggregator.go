@@ -0,0 +1,80 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"k8s.io/heapster/metrics/core"
+)
+
+// WorkloadAggregator rolls up pod metrics per namespace and workload, based on the
+// workload kind and name labels of the pod metric sets. It has to run after the pod
+// aggregator and after the labels are set. Pods without workload labels are skipped.
+type WorkloadAggregator struct {
+	MetricsToAggregate []string
+}
+
+func (this *WorkloadAggregator) Name() string {
+	return "workload_aggregator"
+}
+
+func (this *WorkloadAggregator) Process(batch *core.DataBatch) (*core.DataBatch, error) {
+	workloads := make(map[string]*core.MetricSet)
+	for _, metricSet := range batch.MetricSets {
+		if metricSetType, found := metricSet.Labels[core.LabelMetricSetType.Key]; !found || metricSetType != core.MetricSetTypePod {
+			continue
+		}
+		namespace := metricSet.Labels[core.LabelNamespaceName.Key]
+		kind := metricSet.Labels[core.LabelWorkloadKind.Key]
+		name := metricSet.Labels[core.LabelWorkloadName.Key]
+		if namespace == "" || kind == "" || name == "" {
+			continue
+		}
+
+		key := core.WorkloadKey(namespace, kind, name)
+		workload, found := workloads[key]
+		if !found {
+			workload = workloadMetricSet(metricSet.Labels)
+			workloads[key] = workload
+		}
+		if err := aggregate(metricSet, workload, this.MetricsToAggregate); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, workload := range workloads {
+		batch.MetricSets[key] = workload
+	}
+	return batch, nil
+}
+
+func workloadMetricSet(podLabels map[string]string) *core.MetricSet {
+	labels := map[string]string{
+		core.LabelMetricSetType.Key: core.MetricSetTypeWorkload,
+	}
+	for _, label := range []string{
+		core.LabelNamespaceName.Key,
+		core.LabelPodNamespaceUID.Key,
+		core.LabelWorkloadKind.Key,
+		core.LabelWorkloadName.Key,
+	} {
+		if value, found := podLabels[label]; found {
+			labels[label] = value
+		}
+	}
+	return &core.MetricSet{
+		MetricValues: make(map[string]core.MetricValue),
+		Labels:       labels,
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package processors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+func workloadPodMetricSet(namespace, kind, name string, cpu float32, memory int64) *core.MetricSet {
+	labels := map[string]string{
+		core.LabelMetricSetType.Key:   core.MetricSetTypePod,
+		core.LabelNamespaceName.Key:   namespace,
+		core.LabelPodNamespaceUID.Key: namespace + "-uid",
+	}
+	if kind != "" {
+		labels[core.LabelWorkloadKind.Key] = kind
+		labels[core.LabelWorkloadName.Key] = name
+	}
+	return &core.MetricSet{
+		Labels: labels,
+		MetricValues: map[string]core.MetricValue{
+			core.MetricCpuUsageRate.Name: floatValue(cpu),
+			core.MetricMemoryUsage.Name:  intValue(memory),
+		},
+	}
+}
+
+func TestWorkloadAggregator(t *testing.T) {
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "web-a"):  workloadPodMetricSet("ns1", "Deployment", "web", 1, 100),
+			core.PodKey("ns1", "web-b"):  workloadPodMetricSet("ns1", "Deployment", "web", 2, 200),
+			core.PodKey("ns1", "db-0"):   workloadPodMetricSet("ns1", "StatefulSet", "db", 4, 1000),
+			core.PodKey("ns1", "web-0"):  workloadPodMetricSet("ns1", "StatefulSet", "web", 8, 3000),
+			core.PodKey("ns2", "web-c"):  workloadPodMetricSet("ns2", "Deployment", "web", 16, 5000),
+			core.PodKey("ns1", "orphan"): workloadPodMetricSet("ns1", "", "", 32, 7000),
+			core.PodContainerKey("ns1", "web-a", "c1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelWorkloadKind.Key:  "Deployment",
+					core.LabelWorkloadName.Key:  "web",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryUsage.Name: intValue(100),
+				},
+			},
+		},
+	}
+
+	aggregator := &WorkloadAggregator{
+		MetricsToAggregate: []string{core.MetricCpuUsageRate.Name, core.MetricMemoryUsage.Name},
+	}
+	batch, err := aggregator.Process(batch)
+	assert.NoError(t, err)
+	assert.Equal(t, 11, len(batch.MetricSets))
+
+	for _, expected := range []struct {
+		namespace string
+		kind      string
+		name      string
+		cpu       float32
+		memory    int64
+	}{
+		{"ns1", "Deployment", "web", 3, 300},
+		{"ns1", "StatefulSet", "db", 4, 1000},
+		{"ns1", "StatefulSet", "web", 8, 3000},
+		{"ns2", "Deployment", "web", 16, 5000},
+	} {
+		workload, found := batch.MetricSets[core.WorkloadKey(expected.namespace, expected.kind, expected.name)]
+		if !assert.True(t, found, expected.kind+"/"+expected.name) {
+			continue
+		}
+		assert.Equal(t, map[string]string{
+			core.LabelMetricSetType.Key:   core.MetricSetTypeWorkload,
+			core.LabelNamespaceName.Key:   expected.namespace,
+			core.LabelPodNamespaceUID.Key: expected.namespace + "-uid",
+			core.LabelWorkloadKind.Key:    expected.kind,
+			core.LabelWorkloadName.Key:    expected.name,
+		}, workload.Labels)
+		assert.Equal(t, expected.cpu, workload.MetricValues[core.MetricCpuUsageRate.Name].FloatValue)
+		assert.Equal(t, expected.memory, workload.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	}
+}
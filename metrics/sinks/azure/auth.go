@@ -0,0 +1,135 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Resource for which the tokens are requested.
+	monitoringResource = "https://monitoring.azure.com/"
+	// Tokens are refreshed this long before they expire.
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// tokenSource returns bearer tokens for the Azure Monitor ingestion API.
+type tokenSource interface {
+	Token() (string, error)
+}
+
+// tokenResponse is the subset of the Azure AD / managed identity token response used by the sink.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresOn   string `json:"expires_on"`
+}
+
+// cachingTokenSource caches the token returned by fetch until it is about to expire.
+type cachingTokenSource struct {
+	sync.Mutex
+	fetch   func() (*tokenResponse, error)
+	token   string
+	expires time.Time
+}
+
+func (ts *cachingTokenSource) Token() (string, error) {
+	ts.Lock()
+	defer ts.Unlock()
+
+	if ts.token != "" && time.Now().Add(tokenRefreshMargin).Before(ts.expires) {
+		return ts.token, nil
+	}
+	resp, err := ts.fetch()
+	if err != nil {
+		return "", err
+	}
+	if resp.AccessToken == "" {
+		return "", fmt.Errorf("empty access token received")
+	}
+	expiresOn, err := strconv.ParseInt(resp.ExpiresOn, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse token expiration %q - %v", resp.ExpiresOn, err)
+	}
+	ts.token = resp.AccessToken
+	ts.expires = time.Unix(expiresOn, 0)
+	return ts.token, nil
+}
+
+// newManagedIdentityTokenSource requests tokens from the instance metadata service of the node.
+func newManagedIdentityTokenSource(client *http.Client, endpoint, clientID string) tokenSource {
+	return &cachingTokenSource{
+		fetch: func() (*tokenResponse, error) {
+			params := url.Values{}
+			params.Set("api-version", "2018-02-01")
+			params.Set("resource", monitoringResource)
+			if clientID != "" {
+				params.Set("client_id", clientID)
+			}
+			req, err := http.NewRequest("GET", endpoint+"?"+params.Encode(), nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Metadata", "true")
+			return doTokenRequest(client, req)
+		},
+	}
+}
+
+// newServicePrincipalTokenSource requests tokens from Azure AD using client credentials.
+func newServicePrincipalTokenSource(client *http.Client, endpoint, tenantID, clientID, clientSecret string) tokenSource {
+	return &cachingTokenSource{
+		fetch: func() (*tokenResponse, error) {
+			form := url.Values{}
+			form.Set("grant_type", "client_credentials")
+			form.Set("client_id", clientID)
+			form.Set("client_secret", clientSecret)
+			form.Set("resource", monitoringResource)
+			req, err := http.NewRequest("POST", strings.TrimSuffix(endpoint, "/")+"/"+tenantID+"/oauth2/token", strings.NewReader(form.Encode()))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return doTokenRequest(client, req)
+		},
+	}
+}
+
+func doTokenRequest(client *http.Client, req *http.Request) (*tokenResponse, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("failed to parse token response - %v", err)
+	}
+	return &token, nil
+}
@@ -0,0 +1,357 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/heapster/metrics/core"
+)
+
+const (
+	authManagedIdentity   = "msi"
+	authServicePrincipal  = "sp"
+	defaultNamespace      = "heapster"
+	defaultBatchSize      = 100
+	defaultMSIEndpoint    = "http://169.254.169.254/metadata/identity/oauth2/token"
+	defaultAADEndpoint    = "https://login.microsoftonline.com"
+	clientSecretEnvVar    = "AZURE_CLIENT_SECRET"
+	requestTimeout        = 30 * time.Second
+	maxDimensions         = 10
+	maxDimensionValueSize = 256
+)
+
+var defaultDimensions = []string{
+	core.LabelMetricSetType.Key,
+	core.LabelNamespaceName.Key,
+	core.LabelPodName.Key,
+	core.LabelContainerName.Key,
+	core.LabelNodename.Key,
+	core.LabelResourceID.Key,
+}
+
+type azureConfig struct {
+	region       string
+	resourceID   string
+	endpoint     string
+	namespace    string
+	dimensions   []string
+	batchSize    int
+	auth         string
+	tenantID     string
+	clientID     string
+	clientSecret string
+	msiEndpoint  string
+	aadEndpoint  string
+}
+
+// Custom metric payload accepted by the Azure Monitor ingestion API.
+type customMetric struct {
+	Time string           `json:"time"`
+	Data customMetricData `json:"data"`
+}
+
+type customMetricData struct {
+	BaseData customMetricBaseData `json:"baseData"`
+}
+
+type customMetricBaseData struct {
+	Metric    string         `json:"metric"`
+	Namespace string         `json:"namespace"`
+	DimNames  []string       `json:"dimNames,omitempty"`
+	Series    []customSeries `json:"series"`
+}
+
+type customSeries struct {
+	DimValues []string `json:"dimValues,omitempty"`
+	Min       float64  `json:"min"`
+	Max       float64  `json:"max"`
+	Sum       float64  `json:"sum"`
+	Count     int      `json:"count"`
+}
+
+type azureSink struct {
+	sync.Mutex
+	core.ExportErrors
+	config     azureConfig
+	client     *http.Client
+	tokens     tokenSource
+	ingestPath string
+}
+
+func (sink *azureSink) Name() string {
+	return "Azure Monitor Sink"
+}
+
+func (sink *azureSink) Stop() {
+	// Do nothing.
+}
+
+func (sink *azureSink) ExportData(dataBatch *core.DataBatch) {
+	sink.Lock()
+	defer sink.Unlock()
+
+	timestamp := dataBatch.Timestamp.UTC().Format(time.RFC3339)
+	for _, metric := range sink.buildMetrics(dataBatch) {
+		for start := 0; start < len(metric.Series); start += sink.config.batchSize {
+			end := start + sink.config.batchSize
+			if end > len(metric.Series) {
+				end = len(metric.Series)
+			}
+			payload := customMetric{
+				Time: timestamp,
+				Data: customMetricData{
+					BaseData: customMetricBaseData{
+						Metric:    metric.Metric,
+						Namespace: metric.Namespace,
+						DimNames:  metric.DimNames,
+						Series:    metric.Series[start:end],
+					},
+				},
+			}
+			if err := sink.send(&payload); err != nil {
+				glog.Errorf("Failed to send metric %s to Azure Monitor: %v", metric.Metric, err)
+				sink.RecordExportError(err)
+			}
+		}
+	}
+}
+
+// buildMetrics groups the points of the batch by the metric name and by the set of
+// dimensions present, as every series of a single request has to share the dimension names.
+func (sink *azureSink) buildMetrics(dataBatch *core.DataBatch) []*customMetricBaseData {
+	grouped := make(map[string]*customMetricBaseData)
+	add := func(name string, labels map[string]string, value core.MetricValue) {
+		var floatValue float64
+		switch value.ValueType {
+		case core.ValueInt64:
+			floatValue = float64(value.IntValue)
+		case core.ValueFloat:
+			floatValue = float64(value.FloatValue)
+		default:
+			return
+		}
+		dimNames, dimValues := sink.dimensions(labels)
+		key := name + "|" + strings.Join(dimNames, ",")
+		metric, found := grouped[key]
+		if !found {
+			metric = &customMetricBaseData{
+				Metric:    name,
+				Namespace: sink.config.namespace,
+				DimNames:  dimNames,
+			}
+			grouped[key] = metric
+		}
+		metric.Series = append(metric.Series, customSeries{
+			DimValues: dimValues,
+			Min:       floatValue,
+			Max:       floatValue,
+			Sum:       floatValue,
+			Count:     1,
+		})
+	}
+
+	for _, metricSet := range dataBatch.MetricSets {
+		for metricName, metricValue := range metricSet.MetricValues {
+			add(metricName, metricSet.Labels, metricValue)
+		}
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			labels := make(map[string]string, len(metricSet.Labels)+len(labeledMetric.Labels))
+			for k, v := range metricSet.Labels {
+				labels[k] = v
+			}
+			for k, v := range labeledMetric.Labels {
+				labels[k] = v
+			}
+			add(labeledMetric.Name, labels, labeledMetric.MetricValue)
+		}
+	}
+
+	keys := make([]string, 0, len(grouped))
+	for key := range grouped {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]*customMetricBaseData, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, grouped[key])
+	}
+	return result
+}
+
+// dimensions returns the configured dimensions that have a non-empty value in the given labels.
+func (sink *azureSink) dimensions(labels map[string]string) ([]string, []string) {
+	names := make([]string, 0, len(sink.config.dimensions))
+	values := make([]string, 0, len(sink.config.dimensions))
+	for _, dimension := range sink.config.dimensions {
+		value := labels[dimension]
+		if value == "" {
+			continue
+		}
+		if len(value) > maxDimensionValueSize {
+			value = value[:maxDimensionValueSize]
+		}
+		names = append(names, dimension)
+		values = append(values, value)
+	}
+	return names, values
+}
+
+func (sink *azureSink) send(payload *customMetric) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	token, err := sink.tokens.Token()
+	if err != nil {
+		return fmt.Errorf("failed to get access token - %v", err)
+	}
+	req, err := http.NewRequest("POST", sink.config.endpoint+sink.ingestPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := sink.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+func buildConfig(uri *url.URL) (*azureConfig, error) {
+	opts := uri.Query()
+	config := &azureConfig{
+		namespace:    defaultNamespace,
+		dimensions:   defaultDimensions,
+		batchSize:    defaultBatchSize,
+		auth:         authManagedIdentity,
+		clientSecret: os.Getenv(clientSecretEnvVar),
+		msiEndpoint:  defaultMSIEndpoint,
+		aadEndpoint:  defaultAADEndpoint,
+	}
+
+	if len(opts["region"]) >= 1 {
+		config.region = opts["region"][0]
+	}
+	if len(opts["resourceId"]) >= 1 {
+		config.resourceID = opts["resourceId"][0]
+	}
+	if config.resourceID == "" {
+		return nil, errors.New("`resourceId` flag is required")
+	}
+	if !strings.HasPrefix(config.resourceID, "/") {
+		config.resourceID = "/" + config.resourceID
+	}
+	if len(opts["endpoint"]) >= 1 {
+		config.endpoint = strings.TrimSuffix(opts["endpoint"][0], "/")
+	} else if config.region != "" {
+		config.endpoint = fmt.Sprintf("https://%s.monitoring.azure.com", config.region)
+	} else {
+		return nil, errors.New("either `region` or `endpoint` flag is required")
+	}
+	if len(opts["namespace"]) >= 1 {
+		config.namespace = opts["namespace"][0]
+	}
+	if len(opts["dimensions"]) >= 1 {
+		config.dimensions = strings.Split(opts["dimensions"][0], ",")
+	}
+	if len(config.dimensions) > maxDimensions {
+		return nil, fmt.Errorf("at most %d dimensions are supported, got %d", maxDimensions, len(config.dimensions))
+	}
+	if len(opts["batchSize"]) >= 1 {
+		batchSize, err := strconv.Atoi(opts["batchSize"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `batchSize` flag - %v", err)
+		}
+		if batchSize <= 0 {
+			return nil, errors.New("`batchSize` flag can only be positive")
+		}
+		config.batchSize = batchSize
+	}
+	if len(opts["auth"]) >= 1 {
+		config.auth = opts["auth"][0]
+	}
+	if len(opts["tenantId"]) >= 1 {
+		config.tenantID = opts["tenantId"][0]
+	}
+	if len(opts["clientId"]) >= 1 {
+		config.clientID = opts["clientId"][0]
+	}
+	// TODO: use more secure way to pass the secret.
+	if len(opts["clientSecret"]) >= 1 {
+		config.clientSecret = opts["clientSecret"][0]
+	}
+	if len(opts["msiEndpoint"]) >= 1 {
+		config.msiEndpoint = opts["msiEndpoint"][0]
+	}
+	if len(opts["aadEndpoint"]) >= 1 {
+		config.aadEndpoint = opts["aadEndpoint"][0]
+	}
+
+	switch config.auth {
+	case authManagedIdentity:
+	case authServicePrincipal:
+		if config.tenantID == "" || config.clientID == "" || config.clientSecret == "" {
+			return nil, errors.New("service principal authentication requires `tenantId`, `clientId` and `clientSecret`")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported `auth` flag %q - must be one of %s or %s", config.auth, authManagedIdentity, authServicePrincipal)
+	}
+	return config, nil
+}
+
+func NewAzureSink(uri *url.URL) (core.DataSink, error) {
+	config, err := buildConfig(uri)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: requestTimeout}
+
+	var tokens tokenSource
+	if config.auth == authServicePrincipal {
+		tokens = newServicePrincipalTokenSource(client, config.aadEndpoint, config.tenantID, config.clientID, config.clientSecret)
+	} else {
+		tokens = newManagedIdentityTokenSource(client, config.msiEndpoint, config.clientID)
+	}
+
+	glog.Infof("created Azure Monitor sink with options: endpoint:%s resourceId:%s namespace:%s auth:%s",
+		config.endpoint, config.resourceID, config.namespace, config.auth)
+	return &azureSink{
+		config:     *config,
+		client:     client,
+		tokens:     tokens,
+		ingestPath: config.resourceID + "/metrics",
+	}, nil
+}
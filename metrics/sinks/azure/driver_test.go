@@ -0,0 +1,238 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/heapster/metrics/core"
+)
+
+type fakeAzureServer struct {
+	sync.Mutex
+	*httptest.Server
+	tokenRequests int
+	authHeaders   []string
+	paths         []string
+	payloads      []customMetric
+}
+
+func newFakeAzureServer(t *testing.T) *fakeAzureServer {
+	fake := &fakeAzureServer{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.Lock()
+		defer fake.Unlock()
+		if r.URL.Path == "/tenant1/oauth2/token" {
+			assert.NoError(t, r.ParseForm())
+			assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			assert.Equal(t, "client1", r.PostForm.Get("client_id"))
+			assert.Equal(t, "secret1", r.PostForm.Get("client_secret"))
+			fake.tokenRequests++
+			fmt.Fprintf(w, `{"access_token":"token1","expires_on":"%d"}`, time.Now().Add(time.Hour).Unix())
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		var payload customMetric
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		fake.paths = append(fake.paths, r.URL.Path)
+		fake.authHeaders = append(fake.authHeaders, r.Header.Get("Authorization"))
+		fake.payloads = append(fake.payloads, payload)
+	}))
+	return fake
+}
+
+func newTestSink(t *testing.T, server *fakeAzureServer, extraOpts string) core.DataSink {
+	uri, err := url.Parse(fmt.Sprintf("azure:?endpoint=%s&aadEndpoint=%s&resourceId=/subscriptions/s1/clusters/c1&auth=sp&tenantId=tenant1&clientId=client1&clientSecret=secret1%s",
+		server.URL, server.URL, extraOpts))
+	assert.NoError(t, err)
+	sink, err := NewAzureSink(uri)
+	assert.NoError(t, err)
+	return sink
+}
+
+func testBatch(timestamp time.Time) *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "pod1",
+					core.LabelPodId.Key:         "uid1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryUsage.Name: {
+						ValueType:  core.ValueInt64,
+						MetricType: core.MetricGauge,
+						IntValue:   1024,
+					},
+				},
+			},
+			core.PodKey("ns1", "pod2"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "pod2",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryUsage.Name: {
+						ValueType:  core.ValueInt64,
+						MetricType: core.MetricGauge,
+						IntValue:   2048,
+					},
+				},
+			},
+			core.NodeKey("node1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypeNode,
+					core.LabelNodename.Key:      "node1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricNodeCpuUtilization.Name: {
+						ValueType:  core.ValueFloat,
+						MetricType: core.MetricGauge,
+						FloatValue: 0.5,
+					},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:   core.MetricFilesystemUsage.Name,
+						Labels: map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+						MetricValue: core.MetricValue{
+							ValueType:  core.ValueInt64,
+							MetricType: core.MetricGauge,
+							IntValue:   4096,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestExportDataPayload(t *testing.T) {
+	server := newFakeAzureServer(t)
+	defer server.Close()
+	sink := newTestSink(t, server, "&namespace=k8s")
+
+	timestamp := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	sink.ExportData(testBatch(timestamp))
+	sink.ExportData(testBatch(timestamp.Add(time.Minute)))
+
+	server.Lock()
+	defer server.Unlock()
+	// The token is cached between requests.
+	assert.Equal(t, 1, server.tokenRequests)
+	assert.Equal(t, 6, len(server.payloads))
+	for i := range server.payloads {
+		assert.Equal(t, "/subscriptions/s1/clusters/c1/metrics", server.paths[i])
+		assert.Equal(t, "Bearer token1", server.authHeaders[i])
+	}
+
+	payloads := make(map[string]customMetricBaseData)
+	for _, payload := range server.payloads[:3] {
+		assert.Equal(t, "2017-10-01T12:00:00Z", payload.Time)
+		assert.Equal(t, "k8s", payload.Data.BaseData.Namespace)
+		payloads[payload.Data.BaseData.Metric] = payload.Data.BaseData
+	}
+
+	memory := payloads[core.MetricMemoryUsage.Name]
+	assert.Equal(t, []string{"type", "namespace_name", "pod_name"}, memory.DimNames)
+	assert.Equal(t, 2, len(memory.Series))
+	for _, series := range memory.Series {
+		assert.Equal(t, len(memory.DimNames), len(series.DimValues))
+		assert.Equal(t, 1, series.Count)
+		assert.Equal(t, series.Min, series.Sum)
+		assert.Equal(t, series.Max, series.Sum)
+	}
+
+	cpu := payloads[core.MetricNodeCpuUtilization.Name]
+	assert.Equal(t, []string{"type", "nodename"}, cpu.DimNames)
+	assert.Equal(t, []customSeries{{DimValues: []string{"node", "node1"}, Min: 0.5, Max: 0.5, Sum: 0.5, Count: 1}}, cpu.Series)
+
+	fs := payloads[core.MetricFilesystemUsage.Name]
+	assert.Equal(t, []string{"type", "nodename", "resource_id"}, fs.DimNames)
+	assert.Equal(t, []string{"node", "node1", "/dev/sda1"}, fs.Series[0].DimValues)
+	assert.Equal(t, float64(4096), fs.Series[0].Sum)
+}
+
+func TestExportDataBatchSize(t *testing.T) {
+	server := newFakeAzureServer(t)
+	defer server.Close()
+	sink := newTestSink(t, server, "&batchSize=1&dimensions=namespace_name,pod_name")
+
+	batch := testBatch(time.Now())
+	delete(batch.MetricSets, core.NodeKey("node1"))
+	sink.ExportData(batch)
+
+	server.Lock()
+	defer server.Unlock()
+	assert.Equal(t, 2, len(server.payloads))
+	for _, payload := range server.payloads {
+		assert.Equal(t, []string{"namespace_name", "pod_name"}, payload.Data.BaseData.DimNames)
+		assert.Equal(t, 1, len(payload.Data.BaseData.Series))
+	}
+}
+
+func TestBuildConfig(t *testing.T) {
+	for _, rawUri := range []string{
+		"azure:?region=westeurope",
+		"azure:?resourceId=/subscriptions/s1",
+		"azure:?region=westeurope&resourceId=/subscriptions/s1&auth=sp&tenantId=t1",
+		"azure:?region=westeurope&resourceId=/subscriptions/s1&auth=basic",
+		"azure:?region=westeurope&resourceId=/subscriptions/s1&batchSize=0",
+		"azure:?region=westeurope&resourceId=/subscriptions/s1&dimensions=a,b,c,d,e,f,g,h,i,j,k",
+	} {
+		uri, err := url.Parse(rawUri)
+		assert.NoError(t, err)
+		_, err = buildConfig(uri)
+		assert.Error(t, err, rawUri)
+	}
+
+	uri, err := url.Parse("azure:?region=westeurope&resourceId=subscriptions/s1")
+	assert.NoError(t, err)
+	config, err := buildConfig(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://westeurope.monitoring.azure.com", config.endpoint)
+	assert.Equal(t, "/subscriptions/s1", config.resourceID)
+	assert.Equal(t, authManagedIdentity, config.auth)
+	assert.Equal(t, defaultDimensions, config.dimensions)
+}
+
+func TestManagedIdentityToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.Header.Get("Metadata"))
+		assert.Equal(t, monitoringResource, r.URL.Query().Get("resource"))
+		assert.Equal(t, "identity1", r.URL.Query().Get("client_id"))
+		fmt.Fprintf(w, `{"access_token":"msitoken","expires_on":"%d"}`, time.Now().Add(time.Hour).Unix())
+	}))
+	defer server.Close()
+
+	tokens := newManagedIdentityTokenSource(http.DefaultClient, server.URL, "identity1")
+	token, err := tokens.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "msitoken", token)
+}
@@ -0,0 +1,198 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clickhouse
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/heapster/metrics/core"
+)
+
+// fakeClickhouse records the queries posted to the HTTP interface.
+type fakeClickhouse struct {
+	sync.Mutex
+	t       *testing.T
+	params  []url.Values
+	headers []http.Header
+	rows    [][]map[string]interface{}
+	// Queries containing this string fail.
+	failOn string
+}
+
+func (this *fakeClickhouse) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	this.Lock()
+	defer this.Unlock()
+	params := req.URL.Query()
+	if this.failOn != "" && strings.Contains(params.Get("query"), this.failOn) {
+		http.Error(w, "Code: 60. DB::Exception: Table default.k8s doesn't exist", http.StatusNotFound)
+		return
+	}
+	rows := []map[string]interface{}{}
+	scanner := bufio.NewScanner(req.Body)
+	for scanner.Scan() {
+		var r map[string]interface{}
+		require.NoError(this.t, json.Unmarshal(scanner.Bytes(), &r))
+		rows = append(rows, r)
+	}
+	this.params = append(this.params, params)
+	this.headers = append(this.headers, req.Header)
+	this.rows = append(this.rows, rows)
+}
+
+func newTestSink(t *testing.T, query string) (*clickhouseSink, *fakeClickhouse, func()) {
+	fake := &fakeClickhouse{t: t}
+	server := httptest.NewServer(fake)
+	uri, err := url.Parse(strings.Replace(server.URL, "http://", "http://heapster:secret@", 1) + "/metrics?" + query)
+	require.NoError(t, err)
+	sink, err := NewClickhouseSink(uri)
+	require.NoError(t, err)
+	return sink.(*clickhouseSink), fake, server.Close
+}
+
+func testBatch(timestamp time.Time) *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				ScrapeTime: timestamp.Add(-time.Second),
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelPodName.Key:       "pod1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					"cpu/usage_rate": {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 10},
+					"memory/usage":   {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 1.5},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:        "filesystem/usage",
+						Labels:      map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+						MetricValue: core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 2048},
+					},
+				},
+			},
+			core.ClusterKey(): {
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeCluster},
+				MetricValues: map[string]core.MetricValue{
+					"cpu/usage_rate": {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 100},
+				},
+			},
+		},
+	}
+}
+
+func TestInsertBatch(t *testing.T) {
+	sink, fake, stop := newTestSink(t, "table=k8s")
+	defer stop()
+	sink.ExportData(testBatch(time.Date(2017, 7, 14, 2, 40, 0, 123000000, time.UTC)))
+	require.NoError(t, sink.TakeExportError())
+
+	require.Len(t, fake.params, 1)
+	assert.Equal(t, url.Values{
+		"database":              {"metrics"},
+		"query":                 {"INSERT INTO k8s (timestamp, metric, value, labels) FORMAT JSONEachRow"},
+		"async_insert":          {"1"},
+		"wait_for_async_insert": {"1"},
+	}, fake.params[0])
+	assert.Equal(t, "heapster", fake.headers[0].Get("X-ClickHouse-User"))
+	assert.Equal(t, "secret", fake.headers[0].Get("X-ClickHouse-Key"))
+	assert.Equal(t, []map[string]interface{}{
+		{
+			"timestamp": "2017-07-14 02:40:00.123",
+			"metric":    "cpu/usage_rate",
+			"value":     float64(100),
+			"labels":    map[string]interface{}{"type": "cluster"},
+		},
+		{
+			"timestamp": "2017-07-14 02:39:59.123",
+			"metric":    "cpu/usage_rate",
+			"value":     float64(10),
+			"labels":    map[string]interface{}{"pod_name": "pod1", "type": "pod"},
+		},
+		{
+			"timestamp": "2017-07-14 02:39:59.123",
+			"metric":    "memory/usage",
+			"value":     1.5,
+			"labels":    map[string]interface{}{"pod_name": "pod1", "type": "pod"},
+		},
+		{
+			"timestamp": "2017-07-14 02:39:59.123",
+			"metric":    "filesystem/usage",
+			"value":     float64(2048),
+			"labels":    map[string]interface{}{"pod_name": "pod1", "resource_id": "/dev/sda1", "type": "pod"},
+		},
+	}, fake.rows[0])
+}
+
+func TestInsertInBatchesOfRows(t *testing.T) {
+	sink, fake, stop := newTestSink(t, "batchSize=3&asyncInsert=false")
+	defer stop()
+	sink.ExportData(testBatch(time.Now()))
+	require.NoError(t, sink.TakeExportError())
+
+	require.Len(t, fake.rows, 2)
+	assert.Len(t, fake.rows[0], 3)
+	assert.Len(t, fake.rows[1], 1)
+	assert.Equal(t, "INSERT INTO heapster_metrics (timestamp, metric, value, labels) FORMAT JSONEachRow", fake.params[0].Get("query"))
+	_, found := fake.params[0]["async_insert"]
+	assert.False(t, found)
+}
+
+func TestInsertFailure(t *testing.T) {
+	sink, fake, stop := newTestSink(t, "table=k8s")
+	defer stop()
+	fake.failOn = "INSERT"
+	sink.ExportData(testBatch(time.Now()))
+
+	err := sink.TakeExportError()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "doesn't exist")
+	}
+}
+
+func TestCreateTable(t *testing.T) {
+	_, fake, stop := newTestSink(t, "table=metrics.k8s&createTable=true")
+	defer stop()
+
+	require.Len(t, fake.params, 1)
+	assert.True(t, strings.HasPrefix(fake.params[0].Get("query"), "CREATE TABLE IF NOT EXISTS metrics.k8s ("), fake.params[0].Get("query"))
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, rawUri := range []string{
+		"tcp://clickhouse:9000",
+		"http:///metrics",
+		"http://clickhouse:8123?table=metrics%3Bdrop",
+		"http://clickhouse:8123?batchSize=0",
+		"http://clickhouse:8123?asyncInsert=maybe",
+		"http://clickhouse:8123?createTable=maybe",
+		"http://clickhouse:8123?timeout=soon",
+	} {
+		uri, err := url.Parse(rawUri)
+		require.NoError(t, err)
+		_, err = NewClickhouseSink(uri)
+		assert.Error(t, err, rawUri)
+	}
+}
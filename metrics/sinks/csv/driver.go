@@ -0,0 +1,237 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csv
+
+import (
+	"bytes"
+	stdcsv "encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/heapster/metrics/core"
+)
+
+var header = []string{"timestamp", "metricset_type", "namespace", "pod", "container", "metric", "value"}
+
+// csvSink writes the metrics either by appending them to a file or by serving the
+// latest batch over HTTP.
+type csvSink struct {
+	sync.Mutex
+	core.ExportErrors
+	file     *os.File
+	writer   *stdcsv.Writer
+	server   *http.Server
+	listener net.Listener
+	latest   []byte
+}
+
+func (sink *csvSink) Name() string {
+	return "CSV Sink"
+}
+
+func (sink *csvSink) Stop() {
+	sink.Lock()
+	defer sink.Unlock()
+	if sink.file != nil {
+		sink.file.Close()
+	}
+	if sink.listener != nil {
+		sink.listener.Close()
+	}
+}
+
+func (sink *csvSink) ExportData(dataBatch *core.DataBatch) {
+	sink.Lock()
+	defer sink.Unlock()
+
+	rows := batchToRows(dataBatch)
+	if sink.writer != nil {
+		if err := writeRows(sink.writer, rows); err != nil {
+			glog.Errorf("Failed to write metrics to %s: %v", sink.file.Name(), err)
+			sink.RecordExportError(err)
+		}
+		return
+	}
+
+	var buffer bytes.Buffer
+	writer := stdcsv.NewWriter(&buffer)
+	if err := writeRows(writer, append([][]string{header}, rows...)); err != nil {
+		glog.Errorf("Failed to encode metrics as CSV: %v", err)
+		sink.RecordExportError(err)
+		return
+	}
+	sink.latest = buffer.Bytes()
+}
+
+func (sink *csvSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sink.Lock()
+	latest := sink.latest
+	sink.Unlock()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	if latest == nil {
+		// Nothing exported yet.
+		w.Write([]byte(strings.Join(header, ",") + "\n"))
+		return
+	}
+	w.Write(latest)
+}
+
+func writeRows(writer *stdcsv.Writer, rows [][]string) error {
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// batchToRows returns a row for every metric of the batch, ordered by the metric set key
+// and by the metric name.
+func batchToRows(dataBatch *core.DataBatch) [][]string {
+	timestamp := dataBatch.Timestamp.UTC().Format(time.RFC3339)
+	keys := make([]string, 0, len(dataBatch.MetricSets))
+	for key := range dataBatch.MetricSets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rows := [][]string{}
+	for _, key := range keys {
+		metricSet := dataBatch.MetricSets[key]
+		prefix := []string{
+			timestamp,
+			metricSet.Labels[core.LabelMetricSetType.Key],
+			metricSet.Labels[core.LabelNamespaceName.Key],
+			metricSet.Labels[core.LabelPodName.Key],
+			metricSet.Labels[core.LabelContainerName.Key],
+		}
+		values := make(map[string]string, len(metricSet.MetricValues)+len(metricSet.LabeledMetrics))
+		for name, value := range metricSet.MetricValues {
+			if formatted, ok := formatValue(value); ok {
+				values[name] = formatted
+			}
+		}
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			if formatted, ok := formatValue(labeledMetric.MetricValue); ok {
+				values[labeledMetricName(labeledMetric.Name, labeledMetric.Labels)] = formatted
+			}
+		}
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			row := make([]string, 0, len(header))
+			row = append(append(row, prefix...), name, values[name])
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// labeledMetricName appends the sorted labels of a labeled metric to its name,
+// e.g. filesystem/usage{resource_id=/dev/sda1}.
+func labeledMetricName(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value core.MetricValue) (string, bool) {
+	switch value.ValueType {
+	case core.ValueInt64:
+		return strconv.FormatInt(value.IntValue, 10), true
+	case core.ValueFloat:
+		return strconv.FormatFloat(float64(value.FloatValue), 'g', -1, 32), true
+	default:
+		return "", false
+	}
+}
+
+// NewCsvSink creates a sink appending the metrics to the file given as the URI path,
+// e.g. csv:/var/log/heapster/metrics.csv, or serving the latest batch on the address
+// given by the `addr` option, e.g. csv:?addr=:8087.
+func NewCsvSink(uri *url.URL) (core.DataSink, error) {
+	opts := uri.Query()
+	path := uri.Path
+	if path == "" {
+		path = uri.Opaque
+	}
+	addr := ""
+	if len(opts["addr"]) >= 1 {
+		addr = opts["addr"][0]
+	}
+	if (path == "") == (addr == "") {
+		return nil, errors.New("exactly one of a file path or the `addr` flag is required")
+	}
+
+	sink := &csvSink{}
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s - %v", path, err)
+		}
+		sink.file = file
+		sink.writer = stdcsv.NewWriter(file)
+		if err := writeHeaderIfEmpty(file, sink.writer); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write the header to %s - %v", path, err)
+		}
+		glog.Infof("created CSV sink writing to %s", path)
+		return sink, nil
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s - %v", addr, err)
+	}
+	sink.listener = listener
+	sink.server = &http.Server{Handler: sink}
+	go func() {
+		if err := sink.server.Serve(listener); err != nil {
+			glog.V(2).Infof("CSV sink stopped serving on %s: %v", addr, err)
+		}
+	}()
+	glog.Infof("created CSV sink serving on %s", listener.Addr())
+	return sink, nil
+}
+
+func writeHeaderIfEmpty(file *os.File, writer *stdcsv.Writer) error {
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		return nil
+	}
+	return writeRows(writer, [][]string{header})
+}
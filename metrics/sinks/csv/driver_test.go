@@ -0,0 +1,160 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csv
+
+import (
+	stdcsv "encoding/csv"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/heapster/metrics/core"
+)
+
+func testBatch() *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", `c,"1"`): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "pod1",
+					core.LabelContainerName.Key: `c,"1"`,
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryUsage.Name:  {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 1024},
+					core.MetricCpuUsageRate.Name: {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 0.25},
+				},
+			},
+			core.NodeKey("node1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypeNode,
+					core.LabelNodename.Key:      "node1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: 5000},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:        core.MetricFilesystemUsage.Name,
+						Labels:      map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+						MetricValue: core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 4096},
+					},
+				},
+			},
+		},
+	}
+}
+
+var expectedRows = [][]string{
+	{"2017-10-01T12:00:00Z", "pod_container", "ns1", "pod1", `c,"1"`, "cpu/usage_rate", "0.25"},
+	{"2017-10-01T12:00:00Z", "pod_container", "ns1", "pod1", `c,"1"`, "memory/usage", "1024"},
+	{"2017-10-01T12:00:00Z", "node", "", "", "", "cpu/usage", "5000"},
+	{"2017-10-01T12:00:00Z", "node", "", "", "", "filesystem/usage{resource_id=/dev/sda1}", "4096"},
+}
+
+func readCsv(t *testing.T, content string) [][]string {
+	rows, err := stdcsv.NewReader(strings.NewReader(content)).ReadAll()
+	require.NoError(t, err)
+	return rows
+}
+
+func TestBatchToRows(t *testing.T) {
+	assert.Equal(t, expectedRows, batchToRows(testBatch()))
+}
+
+func TestFileOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csv_sink")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.csv")
+
+	uri, err := url.Parse("csv:" + path)
+	require.NoError(t, err)
+	sink, err := NewCsvSink(uri)
+	require.NoError(t, err)
+	sink.ExportData(testBatch())
+	sink.Stop()
+
+	// The header is not repeated when appending to an existing file.
+	sink, err = NewCsvSink(uri)
+	require.NoError(t, err)
+	sink.ExportData(testBatch())
+	sink.Stop()
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"c,""1"""`)
+	expected := append(append([][]string{header}, expectedRows...), expectedRows...)
+	assert.Equal(t, expected, readCsv(t, string(content)))
+}
+
+func TestFileOutputError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csv_sink")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	uri, err := url.Parse("csv:" + filepath.Join(dir, "metrics.csv"))
+	require.NoError(t, err)
+	dataSink, err := NewCsvSink(uri)
+	require.NoError(t, err)
+	sink := dataSink.(*csvSink)
+	sink.ExportData(testBatch())
+	assert.NoError(t, sink.TakeExportError())
+
+	sink.file.Close()
+	sink.ExportData(testBatch())
+	assert.Error(t, sink.TakeExportError())
+}
+
+func TestHttpOutput(t *testing.T) {
+	uri, err := url.Parse("csv:?addr=127.0.0.1:0")
+	require.NoError(t, err)
+	sink, err := NewCsvSink(uri)
+	require.NoError(t, err)
+	defer sink.Stop()
+	address := "http://" + sink.(*csvSink).listener.Addr().String() + "/"
+
+	get := func() string {
+		resp, err := http.Get(address)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, "text/csv; charset=utf-8", resp.Header.Get("Content-Type"))
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	assert.Equal(t, [][]string{header}, readCsv(t, get()))
+	sink.ExportData(testBatch())
+	assert.Equal(t, append([][]string{header}, expectedRows...), readCsv(t, get()))
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, rawUri := range []string{"csv:", "csv:/tmp/metrics.csv?addr=:8087", "csv:/nonexistent/dir/metrics.csv"} {
+		uri, err := url.Parse(rawUri)
+		require.NoError(t, err)
+		_, err = NewCsvSink(uri)
+		assert.Error(t, err, rawUri)
+	}
+}
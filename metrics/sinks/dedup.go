@@ -0,0 +1,135 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// Sink URI option enabling the suppression of the gauge values unchanged since they were
+// last written, for at most the given interval.
+const suppressUnchangedOption = "suppressUnchanged"
+
+// parseSuppressUnchanged returns the maximum interval for which an unchanged value is not
+// written, or 0 if the values are always written.
+func parseSuppressUnchanged(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse `%s` flag - %v", suppressUnchangedOption, err)
+	}
+	if interval <= 0 {
+		return 0, fmt.Errorf("`%s` flag has to be positive", suppressUnchangedOption)
+	}
+	return interval, nil
+}
+
+// sentValue is a value last written to the wrapped sink.
+type sentValue struct {
+	value  core.MetricValue
+	sentAt time.Time
+}
+
+// deduplicatingSink passes a copy of every batch to the wrapped sink without the gauge
+// values equal to the ones last written, unless they were written maxInterval or longer
+// ago. Cumulative values are always written. The values of a failed export are written
+// again in the next one.
+type deduplicatingSink struct {
+	core.DataSink
+	sync.Mutex
+	maxInterval  time.Duration
+	sent         map[string]sentValue
+	exportErrors core.ExportErrors
+}
+
+func NewDeduplicatingSink(sink core.DataSink, maxInterval time.Duration) core.DataSink {
+	return &deduplicatingSink{
+		DataSink:    sink,
+		maxInterval: maxInterval,
+		sent:        make(map[string]sentValue),
+	}
+}
+
+func (this *deduplicatingSink) ExportData(batch *core.DataBatch) {
+	this.Lock()
+	defer this.Unlock()
+
+	// Only the values of the batch are kept, so that the metrics gone from the batch are
+	// forgotten, and written again if they come back.
+	sent := make(map[string]sentValue, len(this.sent))
+	deduplicated := &core.DataBatch{
+		Timestamp:  batch.Timestamp,
+		MetricSets: make(map[string]*core.MetricSet, len(batch.MetricSets)),
+	}
+	for key, metricSet := range batch.MetricSets {
+		newMetricSet := *metricSet
+		newMetricSet.MetricValues = make(map[string]core.MetricValue, len(metricSet.MetricValues))
+		for name, value := range metricSet.MetricValues {
+			if this.write(sent, key+"\x00"+name, value, batch.Timestamp) {
+				newMetricSet.MetricValues[name] = value
+			}
+		}
+		newMetricSet.LabeledMetrics = make([]core.LabeledMetric, 0, len(metricSet.LabeledMetrics))
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			if this.write(sent, key+"\x00"+labeledMetricKey(labeledMetric), labeledMetric.MetricValue, batch.Timestamp) {
+				newMetricSet.LabeledMetrics = append(newMetricSet.LabeledMetrics, labeledMetric)
+			}
+		}
+		deduplicated.MetricSets[key] = &newMetricSet
+	}
+
+	this.DataSink.ExportData(deduplicated)
+	if err := core.TakeExportError(this.DataSink); err != nil {
+		this.exportErrors.RecordExportError(err)
+		return
+	}
+	this.sent = sent
+}
+
+// write returns whether the value has to be written, and records it in sent as written
+// at now if so, or as last written if not.
+func (this *deduplicatingSink) write(sent map[string]sentValue, key string, value core.MetricValue, now time.Time) bool {
+	if value.MetricType == core.MetricGauge {
+		if previous, found := this.sent[key]; found && sameValue(previous.value, value) && now.Sub(previous.sentAt) < this.maxInterval {
+			sent[key] = previous
+			return false
+		}
+	}
+	sent[key] = sentValue{value: value, sentAt: now}
+	return true
+}
+
+func (this *deduplicatingSink) TakeExportError() error {
+	return this.exportErrors.TakeExportError()
+}
+
+func (this *deduplicatingSink) Historical() core.HistoricalSource {
+	return core.HistoricalSourceOf(this.DataSink)
+}
+
+func sameValue(a, b core.MetricValue) bool {
+	return a.ValueType == b.ValueType && a.IntValue == b.IntValue && a.FloatValue == b.FloatValue
+}
+
+// labeledMetricKey returns the name of the labeled metric followed by its labels.
+func labeledMetricKey(metric core.LabeledMetric) string {
+	return metric.Name + "\x00" + core.LabelsKey(metric.Labels)
+}
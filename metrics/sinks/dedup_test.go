@@ -0,0 +1,114 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/common/flags"
+	"k8s.io/heapster/metrics/core"
+)
+
+// failingRecordingSink records the batches and fails the exports while fail is set.
+type failingRecordingSink struct {
+	recordingSink
+	core.ExportErrors
+	fail bool
+}
+
+func (this *failingRecordingSink) ExportData(batch *core.DataBatch) {
+	this.recordingSink.ExportData(batch)
+	if this.fail {
+		this.RecordExportError(errors.New("write failed"))
+	}
+}
+
+func TestParseSuppressUnchanged(t *testing.T) {
+	interval, err := parseSuppressUnchanged("")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), interval)
+	interval, err = parseSuppressUnchanged("10m")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, interval)
+	for _, value := range []string{"soon", "0s", "-1m"} {
+		_, err := parseSuppressUnchanged(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestDeduplicatingSink(t *testing.T) {
+	key := core.PodKey("ns1", "pod1")
+	recorder := &failingRecordingSink{}
+	sink := NewDeduplicatingSink(recorder, 5*time.Minute)
+	start := time.Now()
+	export := func(at time.Time, usageRate int64) *core.MetricSet {
+		batch := metricTypeTestBatch()
+		batch.Timestamp = at
+		value := batch.MetricSets[key].MetricValues["cpu/usage_rate"]
+		value.IntValue = usageRate
+		batch.MetricSets[key].MetricValues["cpu/usage_rate"] = value
+		sink.ExportData(batch)
+		return recorder.batches[len(recorder.batches)-1].MetricSets[key]
+	}
+
+	all := []string{"accelerator/duty_cycle", "cpu/usage", "cpu/usage_rate", "filesystem/usage"}
+	assert.Equal(t, all, metricNames(export(start, 10)))
+	// The unchanged gauges are suppressed, the cumulative metrics are always written.
+	assert.Equal(t, []string{"accelerator/duty_cycle", "cpu/usage"}, metricNames(export(start.Add(time.Minute), 10)))
+	assert.Equal(t, []string{"accelerator/duty_cycle", "cpu/usage", "cpu/usage_rate"}, metricNames(export(start.Add(2*time.Minute), 20)))
+	assert.Equal(t, []string{"accelerator/duty_cycle", "cpu/usage"}, metricNames(export(start.Add(4*time.Minute), 20)))
+	// filesystem/usage was last written 5 minutes ago and is written again.
+	assert.Equal(t, []string{"accelerator/duty_cycle", "cpu/usage", "filesystem/usage"}, metricNames(export(start.Add(5*time.Minute), 20)))
+
+	// The values of a failed export are written again.
+	recorder.fail = true
+	assert.Equal(t, []string{"accelerator/duty_cycle", "cpu/usage", "cpu/usage_rate"}, metricNames(export(start.Add(6*time.Minute), 30)))
+	assert.Error(t, core.TakeExportError(sink))
+	recorder.fail = false
+	assert.Equal(t, []string{"accelerator/duty_cycle", "cpu/usage", "cpu/usage_rate"}, metricNames(export(start.Add(7*time.Minute), 30)))
+	assert.NoError(t, core.TakeExportError(sink))
+	assert.Equal(t, []string{"accelerator/duty_cycle", "cpu/usage"}, metricNames(export(start.Add(8*time.Minute), 30)))
+
+	// A metric set missing from a batch is written in full when it comes back.
+	sink.ExportData(&core.DataBatch{Timestamp: start.Add(9 * time.Minute), MetricSets: map[string]*core.MetricSet{}})
+	assert.Equal(t, all, metricNames(export(start.Add(10*time.Minute), 30)))
+}
+
+func TestBuildWithSuppressUnchanged(t *testing.T) {
+	factory := NewSinkFactory()
+
+	uri := flags.Uri{}
+	require.NoError(t, uri.Set("log:?suppressUnchanged=10m&renameMetric=cpu/usage:cpu.usage"))
+	sink, err := factory.Build(uri)
+	require.NoError(t, err)
+	renaming, ok := sink.(*renamingSink)
+	require.True(t, ok)
+	deduplicating, ok := renaming.DataSink.(*deduplicatingSink)
+	require.True(t, ok)
+	assert.Equal(t, 10*time.Minute, deduplicating.maxInterval)
+	assert.Equal(t, "Log Sink", sink.Name())
+
+	for _, rawUri := range []string{"log:?suppressUnchanged=never", "metric:?suppressUnchanged=10m"} {
+		uri := flags.Uri{}
+		require.NoError(t, uri.Set(rawUri))
+		_, err := factory.Build(uri)
+		assert.Error(t, err, rawUri)
+	}
+}
@@ -33,6 +33,7 @@ type elasticSearchSink struct {
 	saveData  SaveDataFunc
 	flushData func() error
 	sync.RWMutex
+	core.ExportErrors
 }
 
 type EsFamilyPoints map[core.MetricFamily][]interface{}
@@ -73,11 +74,13 @@ func (sink *elasticSearchSink) ExportData(dataBatch *core.DataBatch) {
 			err := sink.saveData(dataBatch.Timestamp.UTC(), string(family), dataPoints)
 			if err != nil {
 				glog.Warningf("Failed to export data to ElasticSearch sink: %v", err)
+				sink.RecordExportError(err)
 			}
 		}
 		err := sink.flushData()
 		if err != nil {
 			glog.Warningf("Failed to flushing data to ElasticSearch sink: %v", err)
+			sink.RecordExportError(err)
 		}
 	}
 }
@@ -16,11 +16,15 @@ package sinks
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/golang/glog"
 	"k8s.io/heapster/common/flags"
 	"k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/sinks/azure"
+	"k8s.io/heapster/metrics/sinks/clickhouse"
+	"k8s.io/heapster/metrics/sinks/csv"
 	"k8s.io/heapster/metrics/sinks/elasticsearch"
 	"k8s.io/heapster/metrics/sinks/gcm"
 	"k8s.io/heapster/metrics/sinks/graphite"
@@ -30,18 +34,116 @@ import (
 	"k8s.io/heapster/metrics/sinks/kafka"
 	"k8s.io/heapster/metrics/sinks/librato"
 	logsink "k8s.io/heapster/metrics/sinks/log"
+	"k8s.io/heapster/metrics/sinks/loki"
 	metricsink "k8s.io/heapster/metrics/sinks/metric"
+	"k8s.io/heapster/metrics/sinks/newrelic"
 	"k8s.io/heapster/metrics/sinks/opentsdb"
+	"k8s.io/heapster/metrics/sinks/prometheus"
 	"k8s.io/heapster/metrics/sinks/riemann"
+	"k8s.io/heapster/metrics/sinks/splunk"
 	"k8s.io/heapster/metrics/sinks/stackdriver"
 	"k8s.io/heapster/metrics/sinks/statsd"
 	"k8s.io/heapster/metrics/sinks/wavefront"
+	"k8s.io/heapster/metrics/sinks/webhook"
 )
 
+// Sink URI option making Heapster refuse to start if the sink can not be created. Other
+// sinks failing to initialize are skipped.
+const requiredOption = "required"
+
 type SinkFactory struct {
 }
 
+// isRequired returns whether the `required` option of the sink is set.
+func isRequired(uri flags.Uri) (bool, error) {
+	value := uri.Val.Query().Get(requiredOption)
+	if value == "" {
+		return false, nil
+	}
+	required, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse `%s` flag - %v", requiredOption, err)
+	}
+	return required, nil
+}
+
 func (this *SinkFactory) Build(uri flags.Uri) (core.DataSink, error) {
+	// The metric type filter, the renames, the samples and the suppression of unchanged
+	// values are handled here for all sinks, so they are removed from the options passed
+	// to the sink itself, as is the required option handled by BuildAll.
+	opts := uri.Val.Query()
+	if _, found := opts[requiredOption]; found {
+		opts.Del(requiredOption)
+		uri.Val.RawQuery = opts.Encode()
+	}
+	metricType, err := parseMetricTypeFilter(opts.Get(metricTypeOption))
+	if err != nil {
+		return nil, err
+	}
+	metricRenames, err := parseRenames(renameMetricOption, opts[renameMetricOption])
+	if err != nil {
+		return nil, err
+	}
+	labelRenames, err := parseRenames(renameLabelOption, opts[renameLabelOption])
+	if err != nil {
+		return nil, err
+	}
+	allSamples, err := parseSamples(opts.Get(samplesOption))
+	if err != nil {
+		return nil, err
+	}
+	suppressInterval, err := parseSuppressUnchanged(opts.Get(suppressUnchangedOption))
+	if err != nil {
+		return nil, err
+	}
+	if metricType == nil && len(metricRenames) == 0 && len(labelRenames) == 0 && !allSamples && suppressInterval == 0 {
+		return this.build(uri)
+	}
+	if uri.Key == "metric" {
+		// The metric sink backs the APIs and has to keep all the metrics under their original
+		// names, and all the latest values.
+		option := metricTypeOption
+		switch {
+		case metricType != nil:
+		case len(metricRenames) > 0:
+			option = renameMetricOption
+		case len(labelRenames) > 0:
+			option = renameLabelOption
+		case allSamples:
+			option = samplesOption
+		default:
+			option = suppressUnchangedOption
+		}
+		return nil, fmt.Errorf("`%s` flag is not supported by the metric sink", option)
+	}
+	opts.Del(metricTypeOption)
+	opts.Del(renameMetricOption)
+	opts.Del(renameLabelOption)
+	opts.Del(samplesOption)
+	opts.Del(suppressUnchangedOption)
+	uri.Val.RawQuery = opts.Encode()
+	sink, err := this.build(uri)
+	if err != nil {
+		return nil, err
+	}
+	// The values are compared as written by the sink, after the renames.
+	if suppressInterval > 0 {
+		sink = NewDeduplicatingSink(sink, suppressInterval)
+	}
+	if metricType != nil {
+		sink = NewMetricTypeFilteringSink(sink, *metricType)
+	}
+	if len(metricRenames) > 0 || len(labelRenames) > 0 {
+		sink = NewRenamingSink(sink, metricRenames, labelRenames)
+	}
+	// The samples are expanded first, so that they are filtered and renamed as well.
+	if allSamples {
+		sink = NewSampleExpandingSink(sink)
+	}
+	return sink, nil
+}
+
+func (this *SinkFactory) build(uri flags.Uri) (core.DataSink, error) {
 	switch uri.Key {
 	case "elasticsearch":
 		return elasticsearch.NewElasticSearchSink(&uri.Val)
@@ -66,7 +168,12 @@ func (this *SinkFactory) Build(uri flags.Uri) (core.DataSink, error) {
 	case "metric":
 		return metricsink.NewMetricSink(140*time.Second, 15*time.Minute, []string{
 			core.MetricCpuUsageRate.MetricDescriptor.Name,
-			core.MetricMemoryUsage.MetricDescriptor.Name}), nil
+			core.MetricMemoryUsage.MetricDescriptor.Name,
+			core.MetricContainerCpuUsagePeak.MetricDescriptor.Name,
+			core.MetricContainerMemoryWorkingSetPeak.MetricDescriptor.Name,
+			core.MetricContainerCpuUsageP50.MetricDescriptor.Name,
+			core.MetricContainerCpuUsageP95.MetricDescriptor.Name,
+			core.MetricContainerCpuUsageP99.MetricDescriptor.Name}), nil
 	case "opentsdb":
 		return opentsdb.CreateOpenTSDBSink(&uri.Val)
 	case "wavefront":
@@ -75,36 +182,64 @@ func (this *SinkFactory) Build(uri flags.Uri) (core.DataSink, error) {
 		return riemann.CreateRiemannSink(&uri.Val)
 	case "honeycomb":
 		return honeycomb.NewHoneycombSink(&uri.Val)
+	case "azure":
+		return azure.NewAzureSink(&uri.Val)
+	case "prometheus":
+		return prometheus.NewPrometheusSink(&uri.Val)
+	case "webhook":
+		return webhook.NewWebhookSink(&uri.Val)
+	case "csv":
+		return csv.NewCsvSink(&uri.Val)
+	case "loki":
+		return loki.NewLokiSink(&uri.Val)
+	case "newrelic":
+		return newrelic.NewNewRelicSink(&uri.Val)
+	case "splunk":
+		return splunk.NewSplunkSink(&uri.Val)
+	case "clickhouse":
+		return clickhouse.NewClickhouseSink(&uri.Val)
 	default:
 		return nil, fmt.Errorf("Sink not recognized: %s", uri.Key)
 	}
 }
 
-func (this *SinkFactory) BuildAll(uris flags.Uris, historicalUri string, disableMetricSink bool) (*metricsink.MetricSink, []core.DataSink, core.HistoricalSource) {
+// BuildAll creates the sinks of the URIs. The sinks failing to initialize are logged and
+// skipped, so that the metrics are still written to the others, unless they are required
+// or none of the sinks could be created.
+func (this *SinkFactory) BuildAll(uris flags.Uris, historicalUri string, disableMetricSink bool) (*metricsink.MetricSink, []core.DataSink, core.HistoricalSource, error) {
 	result := make([]core.DataSink, 0, len(uris))
 	var metric *metricsink.MetricSink
 	var historical core.HistoricalSource
+	failed := 0
 	for _, uri := range uris {
+		required, err := isRequired(uri)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid %s sink: %v", uri.Key, err)
+		}
 		sink, err := this.Build(uri)
 		if err != nil {
-			glog.Errorf("Failed to create %s sink: %v", sink.Name(), err)
+			if required {
+				return nil, nil, nil, fmt.Errorf("failed to create required %s sink: %v", uri.Key, err)
+			}
+			glog.Errorf("Failed to create %s sink, skipping it: %v", uri.Key, err)
+			failed++
 			continue
 		}
 		if uri.Key == "metric" {
 			metric = sink.(*metricsink.MetricSink)
 		}
 		if uri.String() == historicalUri {
-			if asHistSource, ok := sink.(core.AsHistoricalSource); ok {
-				historical = asHistSource.Historical()
-			} else {
+			if historical = core.HistoricalSourceOf(sink); historical == nil {
 				glog.Errorf("Sink type %q does not support being used for historical access", uri.Key)
 			}
 		}
 		result = append(result, sink)
 	}
 
+	configuredSinks.WithLabelValues("initialized").Set(float64(len(result)))
+	configuredSinks.WithLabelValues("failed").Set(float64(failed))
 	if len([]flags.Uri(uris)) != 0 && len(result) == 0 {
-		glog.Fatal("No available sink to use")
+		return nil, nil, nil, fmt.Errorf("no available sink to use")
 	}
 
 	if metric == nil && !disableMetricSink {
@@ -121,7 +256,7 @@ func (this *SinkFactory) BuildAll(uris flags.Uris, historicalUri string, disable
 	if len(historicalUri) > 0 && historical == nil {
 		glog.Errorf("Error while initializing historical access: unable to use sink %q as a historical source", historicalUri)
 	}
-	return metric, result, historical
+	return metric, result, historical, nil
 }
 
 func NewSinkFactory() *SinkFactory {
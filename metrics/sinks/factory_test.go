@@ -0,0 +1,95 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/common/flags"
+	"k8s.io/heapster/metrics/core"
+)
+
+func configuredSinksValue(t *testing.T, state string) float64 {
+	metric := &dto.Metric{}
+	require.NoError(t, configuredSinks.WithLabelValues(state).Write(metric))
+	return metric.GetGauge().GetValue()
+}
+
+func parseUris(t *testing.T, rawUris ...string) flags.Uris {
+	uris := flags.Uris{}
+	for _, rawUri := range rawUris {
+		require.NoError(t, uris.Set(rawUri))
+	}
+	return uris
+}
+
+func TestBuildAllSkipsFailedSinks(t *testing.T) {
+	factory := NewSinkFactory()
+	metric, sinkList, _, err := factory.BuildAll(parseUris(t, "unknown", "log"), "", false)
+	require.NoError(t, err)
+	assert.NotNil(t, metric)
+	names := []string{}
+	for _, sink := range sinkList {
+		names = append(names, sink.Name())
+	}
+	assert.Equal(t, []string{"Log Sink", "Metric Sink"}, names)
+	assert.Equal(t, float64(1), configuredSinksValue(t, "initialized"))
+	assert.Equal(t, float64(1), configuredSinksValue(t, "failed"))
+
+	_, _, _, err = factory.BuildAll(parseUris(t, "unknown:?required=true", "log"), "", false)
+	assert.Error(t, err)
+
+	_, _, _, err = factory.BuildAll(parseUris(t, "unknown"), "", false)
+	assert.Error(t, err)
+}
+
+type historicalSink struct {
+	recordingSink
+	core.HistoricalSource
+}
+
+func (this *historicalSink) Historical() core.HistoricalSource {
+	return this
+}
+
+func TestWrappedSinksKeepHistoricalSource(t *testing.T) {
+	wrap := func(sink core.DataSink) core.DataSink {
+		sink = NewMetricTypeFilteringSink(sink, core.MetricGauge)
+		sink = NewRenamingSink(sink, map[string]string{"cpu/usage": "cpu/total"}, nil)
+		sink = NewSampleExpandingSink(sink)
+		sink = NewTransformingSink(sink, map[string]MetricTransform{})
+		return NewDeduplicatingSink(sink, time.Minute)
+	}
+	sink := &historicalSink{}
+	assert.Equal(t, core.HistoricalSource(sink), core.HistoricalSourceOf(wrap(sink)))
+	assert.Nil(t, core.HistoricalSourceOf(wrap(&recordingSink{})))
+}
+
+func TestBuildWithRequired(t *testing.T) {
+	factory := NewSinkFactory()
+	_, sinkList, _, err := factory.BuildAll(parseUris(t, "log:?required=true"), "", true)
+	require.NoError(t, err)
+	require.Len(t, sinkList, 1)
+	assert.Equal(t, "Log Sink", sinkList[0].Name())
+	assert.Equal(t, float64(0), configuredSinksValue(t, "failed"))
+
+	_, _, _, err = factory.BuildAll(parseUris(t, "log:?required=maybe"), "", true)
+	assert.Error(t, err)
+}
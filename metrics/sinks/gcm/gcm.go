@@ -46,6 +46,7 @@ const (
 
 type gcmSink struct {
 	sync.RWMutex
+	core.ExportErrors
 	registered   bool
 	project      string
 	metricFilter MetricFilter
@@ -166,6 +167,7 @@ func (sink *gcmSink) sendRequest(req *gcm.CreateTimeSeriesRequest) {
 	_, err := sink.gcmService.Projects.TimeSeries.Create(fullProjectName(sink.project), req).Do()
 	if err != nil {
 		glog.Errorf("Error while sending request to GCM %v", err)
+		sink.RecordExportError(err)
 	} else {
 		glog.V(4).Infof("Successfully sent %v timeserieses to GCM", len(req.TimeSeries))
 	}
@@ -174,6 +176,7 @@ func (sink *gcmSink) sendRequest(req *gcm.CreateTimeSeriesRequest) {
 func (sink *gcmSink) ExportData(dataBatch *core.DataBatch) {
 	if err := sink.registerAllMetrics(); err != nil {
 		glog.Warningf("Error during metrics registration: %v", err)
+		sink.RecordExportError(err)
 		return
 	}
 
@@ -122,6 +122,7 @@ func (m *graphiteMetric) Metric() graphite.Metric {
 type Sink struct {
 	client graphiteClient
 	sync.RWMutex
+	core.ExportErrors
 }
 
 func NewGraphiteSink(uri *url.URL) (core.DataSink, error) {
@@ -192,6 +193,7 @@ func (s *Sink) ExportData(dataBatch *core.DataBatch) {
 	glog.V(8).Infof("Sending %d events to graphite", len(metrics))
 	if err := s.client.SendMetrics(metrics); err != nil {
 		glog.V(4).Info("Graphite connection error:", err)
+		s.RecordExportError(err)
 		glog.V(2).Info("There were errors sending events to Graphite, reconecting")
 		s.client.Disconnect()
 		s.client.Connect()
@@ -264,6 +264,7 @@ func (h *hawkularSink) sendData(tmhs map[string][]metrics.MetricHeader, wg *sync
 				m = append(m, metrics.Tenant(tenant))
 				if err := h.client.Write(batch, m...); err != nil {
 					glog.Errorf(err.Error())
+					h.RecordExportError(err)
 				}
 			}(p, k)
 		}
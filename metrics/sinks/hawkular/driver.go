@@ -341,6 +341,7 @@ func (h *hawkularSink) init() error {
 
 	h.client = c
 
-	glog.Infof("Initialised Hawkular Sink with parameters %v", p)
+	// The parameters also hold the credentials.
+	glog.Infof("Initialised Hawkular Sink on %s with tenant %s and concurrency %d", h.uri.Host, p.Tenant, p.Concurrency)
 	return nil
 }
@@ -61,6 +61,8 @@ type hawkularSink struct {
 
 	disablePreCaching bool
 	batchSize         int
+
+	core.ExportErrors
 }
 
 func heapsterTypeToHawkularType(t core.MetricType) metrics.MetricType {
@@ -39,6 +39,7 @@ var blacklist = map[string]struct{}{
 type honeycombSink struct {
 	client honeycomb_common.Client
 	sync.Mutex
+	core.ExportErrors
 }
 
 type Point struct {
@@ -74,6 +75,7 @@ func (sink *honeycombSink) ExportData(dataBatch *core.DataBatch) {
 	err := sink.client.SendBatch(batch)
 	if err != nil {
 		glog.Warningf("Failed to send metrics batch: %v", err)
+		sink.RecordExportError(err)
 	}
 }
 
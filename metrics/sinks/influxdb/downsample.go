@@ -0,0 +1,131 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+
+	influxdb "github.com/influxdata/influxdb/client"
+)
+
+// downsampledSeries holds the running average of a series within the current window.
+type downsampledSeries struct {
+	measurement string
+	field       string
+	tags        map[string]string
+	sum         float64
+	count       int
+}
+
+// downsampler averages the points of the downsampled metrics over fixed windows. The
+// averages are written as measurements named after the raw ones with the window as
+// suffix, e.g. cpu/usage_rate_1m, timestamped with the start of the window.
+type downsampler struct {
+	window time.Duration
+	suffix string
+	// Downsampled metrics, all gauges if empty.
+	metrics map[string]struct{}
+
+	windowStart time.Time
+	series      map[string]*downsampledSeries
+}
+
+func newDownsampler(window time.Duration, metrics []string) *downsampler {
+	this := &downsampler{
+		window:  window,
+		suffix:  "_" + formatWindow(window),
+		metrics: make(map[string]struct{}, len(metrics)),
+		series:  make(map[string]*downsampledSeries),
+	}
+	for _, metric := range metrics {
+		this.metrics[metric] = struct{}{}
+	}
+	return this
+}
+
+// formatWindow returns the window in the largest whole unit, e.g. 1m rather than 1m0s.
+func formatWindow(window time.Duration) string {
+	switch {
+	case window%time.Hour == 0:
+		return fmt.Sprintf("%dh", window/time.Hour)
+	case window%time.Minute == 0:
+		return fmt.Sprintf("%dm", window/time.Minute)
+	}
+	return fmt.Sprintf("%ds", window/time.Second)
+}
+
+func (this *downsampler) covers(metricName string, metricType core.MetricType) bool {
+	if len(this.metrics) == 0 {
+		return metricType == core.MetricGauge
+	}
+	_, found := this.metrics[metricName]
+	return found
+}
+
+// advance moves to the window of the given timestamp and returns the averages of the
+// previous window if it is complete.
+func (this *downsampler) advance(timestamp time.Time, precision string) []influxdb.Point {
+	windowStart := timestamp.Truncate(this.window)
+	if !windowStart.After(this.windowStart) {
+		return nil
+	}
+	points := make([]influxdb.Point, 0, len(this.series))
+	for _, series := range this.series {
+		points = append(points, influxdb.Point{
+			Measurement: series.measurement + this.suffix,
+			Tags:        series.tags,
+			Fields: map[string]interface{}{
+				series.field: series.sum / float64(series.count),
+			},
+			Time:      this.windowStart.UTC(),
+			Precision: precision,
+		})
+	}
+	this.windowStart = windowStart
+	this.series = make(map[string]*downsampledSeries)
+	return points
+}
+
+// add adds the value of the raw point to the average of its series.
+func (this *downsampler) add(point influxdb.Point, field string, value interface{}) {
+	var floatValue float64
+	switch v := value.(type) {
+	case int64:
+		floatValue = float64(v)
+	case float64:
+		floatValue = v
+	default:
+		return
+	}
+	key := seriesKey(point.Measurement, field, point.Tags)
+	series, found := this.series[key]
+	if !found {
+		series = &downsampledSeries{
+			measurement: point.Measurement,
+			field:       field,
+			tags:        point.Tags,
+		}
+		this.series[key] = series
+	}
+	series.sum += floatValue
+	series.count++
+}
+
+func seriesKey(measurement, field string, tags map[string]string) string {
+	return measurement + "|" + field + "|" + core.LabelsKey(tags)
+}
@@ -31,12 +31,19 @@ import (
 type influxdbSink struct {
 	client influxdb_common.InfluxdbClient
 	sync.RWMutex
-	c        influxdb_common.InfluxdbConfig
-	dbExists bool
+	core.ExportErrors
+	c influxdb_common.InfluxdbConfig
+
+	// Databases known to exist, guarded by dbLock as the batches are sent concurrently.
+	dbLock   sync.Mutex
+	dbExists map[string]bool
 
 	// wg and conChan will work together to limit concurrent influxDB sink goroutines.
 	wg      sync.WaitGroup
 	conChan chan struct{}
+
+	// Averages the gauges into downsampled measurements, nil if disabled.
+	downsampler *downsampler
 }
 
 var influxdbBlacklistLabels = map[string]struct{}{
@@ -58,7 +65,9 @@ const (
 
 func (sink *influxdbSink) resetConnection() {
 	glog.Infof("Influxdb connection reset")
-	sink.dbExists = false
+	sink.dbLock.Lock()
+	sink.dbExists = make(map[string]bool)
+	sink.dbLock.Unlock()
 	sink.client = nil
 }
 
@@ -66,7 +75,13 @@ func (sink *influxdbSink) ExportData(dataBatch *core.DataBatch) {
 	sink.Lock()
 	defer sink.Unlock()
 
-	dataPoints := make([]influxdb.Point, 0, 0)
+	// Points by database. The default database is always written to, even if empty.
+	dataPoints := map[string][]influxdb.Point{sink.c.DbName: {}}
+	if sink.downsampler != nil {
+		for _, point := range sink.downsampler.advance(dataBatch.Timestamp, sink.c.Precision) {
+			sink.addPoint(dataPoints, point)
+		}
+	}
 	for _, metricSet := range dataBatch.MetricSets {
 		for metricName, metricValue := range metricSet.MetricValues {
 			if sink.c.DisableCounterMetrics {
@@ -102,7 +117,8 @@ func (sink *influxdbSink) ExportData(dataBatch *core.DataBatch) {
 				Fields: map[string]interface{}{
 					fieldName: value,
 				},
-				Time: dataBatch.Timestamp.UTC(),
+				Time:      dataBatch.Timestamp.UTC(),
+				Precision: sink.c.Precision,
 			}
 			for key, value := range metricSet.Labels {
 				if _, exists := influxdbBlacklistLabels[key]; !exists {
@@ -113,12 +129,11 @@ func (sink *influxdbSink) ExportData(dataBatch *core.DataBatch) {
 			}
 
 			point.Tags["cluster_name"] = sink.c.ClusterName
-
-			dataPoints = append(dataPoints, point)
-			if len(dataPoints) >= maxSendBatchSize {
-				sink.concurrentSendData(dataPoints)
-				dataPoints = make([]influxdb.Point, 0, 0)
+			if sink.downsampler != nil && sink.downsampler.covers(metricName, metricValue.MetricType) {
+				sink.downsampler.add(point, fieldName, value)
 			}
+
+			sink.addPoint(dataPoints, point)
 		}
 
 		for _, labeledMetric := range metricSet.LabeledMetrics {
@@ -155,7 +170,8 @@ func (sink *influxdbSink) ExportData(dataBatch *core.DataBatch) {
 				Fields: map[string]interface{}{
 					fieldName: value,
 				},
-				Time: dataBatch.Timestamp.UTC(),
+				Time:      dataBatch.Timestamp.UTC(),
+				Precision: sink.c.Precision,
 			}
 
 			for key, value := range metricSet.Labels {
@@ -173,50 +189,74 @@ func (sink *influxdbSink) ExportData(dataBatch *core.DataBatch) {
 				}
 			}
 			point.Tags["cluster_name"] = sink.c.ClusterName
-
-			dataPoints = append(dataPoints, point)
-			if len(dataPoints) >= maxSendBatchSize {
-				sink.concurrentSendData(dataPoints)
-				dataPoints = make([]influxdb.Point, 0, 0)
+			if sink.downsampler != nil && sink.downsampler.covers(labeledMetric.Name, labeledMetric.MetricType) {
+				sink.downsampler.add(point, fieldName, value)
 			}
+
+			sink.addPoint(dataPoints, point)
 		}
 	}
-	if len(dataPoints) >= 0 {
-		sink.concurrentSendData(dataPoints)
+	for database, points := range dataPoints {
+		sink.concurrentSendData(database, points)
 	}
 
 	sink.wg.Wait()
 }
 
-func (sink *influxdbSink) concurrentSendData(dataPoints []influxdb.Point) {
+// database returns the database of the point, the one of its metric set type if configured.
+func (sink *influxdbSink) database(point influxdb.Point) string {
+	if database, found := sink.c.DbNameByType[point.Tags[core.LabelMetricSetType.Key]]; found {
+		return database
+	}
+	return sink.c.DbName
+}
+
+// addPoint adds the point to the points of its database, and sends them once there are enough.
+func (sink *influxdbSink) addPoint(dataPoints map[string][]influxdb.Point, point influxdb.Point) {
+	database := sink.database(point)
+	dataPoints[database] = append(dataPoints[database], point)
+	if len(dataPoints[database]) >= maxSendBatchSize {
+		sink.concurrentSendData(database, dataPoints[database])
+		dataPoints[database] = make([]influxdb.Point, 0, 0)
+	}
+}
+
+func (sink *influxdbSink) concurrentSendData(database string, dataPoints []influxdb.Point) {
 	sink.wg.Add(1)
 	// use the channel to block until there's less than the maximum number of concurrent requests running
 	sink.conChan <- struct{}{}
 	go func(dataPoints []influxdb.Point) {
-		sink.sendData(dataPoints)
+		sink.sendData(database, dataPoints)
 	}(dataPoints)
 }
 
-func (sink *influxdbSink) sendData(dataPoints []influxdb.Point) {
+func (sink *influxdbSink) sendData(database string, dataPoints []influxdb.Point) {
 	defer func() {
 		// empty an item from the channel so the next waiting request can run
 		<-sink.conChan
 		sink.wg.Done()
 	}()
 
-	if err := sink.createDatabase(); err != nil {
+	if err := sink.createDatabase(database); err != nil {
 		glog.Errorf("Failed to create influxdb: %v", err)
+		sink.RecordExportError(err)
 		return
 	}
 	bp := influxdb.BatchPoints{
 		Points:          dataPoints,
-		Database:        sink.c.DbName,
+		Database:        database,
 		RetentionPolicy: "default",
+		Precision:       sink.c.Precision,
 	}
 
 	start := time.Now()
-	if _, err := sink.client.Write(bp); err != nil {
+	_, err := sink.client.Write(bp)
+	if err != nil {
+		bp.Points, err = sink.retryPartialWrite(bp, err)
+	}
+	if err != nil {
 		glog.Errorf("InfluxDB write failed: %v", err)
+		sink.RecordExportError(err)
 		if strings.Contains(err.Error(), dbNotFoundError) {
 			sink.resetConnection()
 		} else if _, _, err := sink.client.Ping(); err != nil {
@@ -226,7 +266,32 @@ func (sink *influxdbSink) sendData(dataPoints []influxdb.Point) {
 		return
 	}
 	end := time.Now()
-	glog.V(4).Infof("Exported %d data to influxDB in %s", len(dataPoints), end.Sub(start))
+	glog.V(4).Infof("Exported %d data to influxDB database %q in %s", len(bp.Points), database, end.Sub(start))
+}
+
+// retryPartialWrite writes again the points of a batch that failed with a partial write,
+// without the points InfluxDB rejected. InfluxDB stores the accepted points of a partial
+// write, which the retry overwrites with the same values. It returns the points written
+// and the error of the retry, or the original error if the points rejected are unknown.
+func (sink *influxdbSink) retryPartialWrite(bp influxdb.BatchPoints, err error) ([]influxdb.Point, error) {
+	rejection, ok := parsePartialWrite(err)
+	if !ok {
+		return bp.Points, err
+	}
+	kept, dropped := rejection.split(bp.Points)
+	if len(dropped) == 0 {
+		return bp.Points, err
+	}
+	glog.Warningf("InfluxDB rejected %d of %d points, retrying without them: %v", len(dropped), len(bp.Points), err)
+	for _, point := range dropped {
+		glog.V(2).Infof("Dropped point rejected by InfluxDB: %s", point.MarshalString())
+	}
+	if len(kept) == 0 {
+		return kept, nil
+	}
+	bp.Points = kept
+	_, err = sink.client.Write(bp)
+	return kept, err
 }
 
 func (sink *influxdbSink) Name() string {
@@ -249,35 +314,40 @@ func (sink *influxdbSink) ensureClient() error {
 	return nil
 }
 
-func (sink *influxdbSink) createDatabase() error {
+func (sink *influxdbSink) createDatabase(database string) error {
 	if err := sink.ensureClient(); err != nil {
 		return err
 	}
 
-	if sink.dbExists {
+	sink.dbLock.Lock()
+	defer sink.dbLock.Unlock()
+	if sink.dbExists[database] {
 		return nil
 	}
 	q := influxdb.Query{
-		Command: fmt.Sprintf(`CREATE DATABASE %s WITH NAME "default"`, sink.c.DbName),
+		Command: fmt.Sprintf(`CREATE DATABASE %s WITH NAME "default"`, database),
 	}
 
 	if resp, err := sink.client.Query(q); err != nil {
 		if !(resp != nil && resp.Err != nil && strings.Contains(resp.Err.Error(), "already exists")) {
-			err := sink.createRetentionPolicy()
+			err := sink.createRetentionPolicy(database)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
-	sink.dbExists = true
-	glog.Infof("Created database %q on influxDB server at %q", sink.c.DbName, sink.c.Host)
+	if sink.dbExists == nil {
+		sink.dbExists = make(map[string]bool)
+	}
+	sink.dbExists[database] = true
+	glog.Infof("Created database %q on influxDB server at %q", database, sink.c.Host)
 	return nil
 }
 
-func (sink *influxdbSink) createRetentionPolicy() error {
+func (sink *influxdbSink) createRetentionPolicy(database string) error {
 	q := influxdb.Query{
-		Command: fmt.Sprintf(`CREATE RETENTION POLICY "default" ON %s DURATION %s REPLICATION 1 DEFAULT`, sink.c.DbName, sink.c.RetentionPolicy),
+		Command: fmt.Sprintf(`CREATE RETENTION POLICY "default" ON %s DURATION %s REPLICATION 1 DEFAULT`, database, sink.c.RetentionPolicy),
 	}
 
 	if resp, err := sink.client.Query(q); err != nil {
@@ -286,7 +356,7 @@ func (sink *influxdbSink) createRetentionPolicy() error {
 		}
 	}
 
-	glog.Infof("Created retention policy 'default' in database %q on influxDB server at %q", sink.c.DbName, sink.c.Host)
+	glog.Infof("Created retention policy 'default' in database %q on influxDB server at %q", database, sink.c.Host)
 	return nil
 }
 
@@ -296,11 +366,15 @@ func new(c influxdb_common.InfluxdbConfig) core.DataSink {
 	if err != nil {
 		glog.Errorf("issues while creating an InfluxDB sink: %v, will retry on use", err)
 	}
-	return &influxdbSink{
+	sink := &influxdbSink{
 		client:  client, // can be nil
 		c:       c,
 		conChan: make(chan struct{}, c.Concurrency),
 	}
+	if c.DownsampleWindow > 0 {
+		sink.downsampler = newDownsampler(c.DownsampleWindow, c.DownsampleMetrics)
+	}
+	return sink
 }
 
 func CreateInfluxdbSink(uri *url.URL) (core.DataSink, error) {
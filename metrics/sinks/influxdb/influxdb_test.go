@@ -17,12 +17,17 @@ package influxdb
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"net/http/httptest"
 	"net/url"
 
+	influxdb "github.com/influxdata/influxdb/client"
 	influx_models "github.com/influxdata/influxdb/models"
 	"github.com/stretchr/testify/assert"
 	util "k8s.io/client-go/util/testing"
@@ -173,6 +178,119 @@ func TestCreateInfluxdbSink(t *testing.T) {
 	assert.Equal(t, sink.Name(), "InfluxDB Sink")
 }
 
+func TestInfluxdbSinkPrecision(t *testing.T) {
+	timestamp := time.Unix(1500000000, 123456789)
+	tests := []struct {
+		precision      string
+		expectedParam  string
+		expectedSuffix string
+	}{
+		{"", "", " 1500000000123456789"},
+		{"ns", "n", " 1500000000123456789"},
+		{"us", "u", " 1500000000123456"},
+		{"ms", "ms", " 1500000000123"},
+		{"s", "s", " 1500000000"},
+	}
+
+	for _, test := range tests {
+		var lock sync.Mutex
+		var precisionParam, body string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/write" {
+				data, err := ioutil.ReadAll(r.Body)
+				assert.NoError(t, err)
+				lock.Lock()
+				precisionParam = r.URL.Query().Get("precision")
+				body = strings.TrimSpace(string(data))
+				lock.Unlock()
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"results":[{}]}`)
+		}))
+
+		rawUri := server.URL
+		if test.precision != "" {
+			rawUri += "?precision=" + test.precision
+		}
+		uri, err := url.Parse(rawUri)
+		assert.NoError(t, err)
+		sink, err := CreateInfluxdbSink(uri)
+		assert.NoError(t, err)
+
+		sink.ExportData(&core.DataBatch{
+			Timestamp: timestamp,
+			MetricSets: map[string]*core.MetricSet{
+				"pod1": {
+					Labels: map[string]string{core.LabelPodName.Key: "pod1"},
+					MetricValues: map[string]core.MetricValue{
+						core.MetricMemoryUsage.Name: {
+							ValueType:  core.ValueInt64,
+							MetricType: core.MetricGauge,
+							IntValue:   123,
+						},
+					},
+				},
+			},
+		})
+		server.Close()
+
+		lock.Lock()
+		assert.Equal(t, test.expectedParam, precisionParam, "precision %q", test.precision)
+		assert.True(t, strings.HasSuffix(body, test.expectedSuffix), "precision %q: unexpected line %q", test.precision, body)
+		lock.Unlock()
+	}
+}
+
+func TestHistoricalWithPrecision(t *testing.T) {
+	timestamp := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Path != "/query" {
+			return
+		}
+		// Like InfluxDB, return epoch timestamps if an epoch is requested.
+		var responseTime interface{} = timestamp.Format(time.RFC3339)
+		if r.URL.Query().Get("epoch") != "" {
+			responseTime = timestamp.Unix()
+		}
+		response, err := json.Marshal(map[string]interface{}{
+			"results": []interface{}{map[string]interface{}{
+				"series": []interface{}{map[string]interface{}{
+					"name":    "cpu/usage_rate",
+					"columns": []string{"time", "value"},
+					"values":  [][]interface{}{{responseTime, 100}},
+				}},
+			}},
+		})
+		assert.NoError(t, err)
+		w.Write(response)
+	}))
+	defer server.Close()
+
+	uri, err := url.Parse(server.URL + "?precision=s")
+	assert.NoError(t, err)
+	dataSink, err := CreateInfluxdbSink(uri)
+	assert.NoError(t, err)
+	sink := dataSink.(*influxdbSink)
+
+	key := core.HistoricalKey{ObjectType: core.MetricSetTypePod, NamespaceName: "ns1", PodName: "pod1"}
+	values, err := sink.GetMetric("cpu/usage_rate", []core.HistoricalKey{key}, timestamp.Add(-time.Minute), timestamp.Add(time.Minute))
+	assert.NoError(t, err)
+	if assert.Len(t, values[key], 1) {
+		assert.Equal(t, timestamp, values[key][0].Timestamp)
+		assert.Equal(t, int64(100), values[key][0].IntValue)
+	}
+}
+
+func TestInfluxdbSinkInvalidPrecision(t *testing.T) {
+	uri, err := url.Parse("influxdb:?precision=m")
+	assert.NoError(t, err)
+	_, err = CreateInfluxdbSink(uri)
+	assert.Error(t, err)
+}
+
 func makeRow(results [][]string) influx_models.Row {
 	resRow := influx_models.Row{
 		Values: make([][]interface{}, len(results)),
@@ -582,3 +700,154 @@ func TestSanitizers(t *testing.T) {
 		}
 	}
 }
+
+func TestStoreDataDownsampled(t *testing.T) {
+	client := influxdb_common.NewFakeInfluxDBClient()
+	config := influxdb_common.Config
+	config.DownsampleWindow = time.Minute
+	sink := &influxdbSink{
+		client:      client,
+		c:           config,
+		conChan:     make(chan struct{}, config.Concurrency),
+		downsampler: newDownsampler(config.DownsampleWindow, config.DownsampleMetrics),
+	}
+
+	batch := func(timestamp time.Time, cpuRate int64, cpuUsage int64) *core.DataBatch {
+		return &core.DataBatch{
+			Timestamp: timestamp,
+			MetricSets: map[string]*core.MetricSet{
+				"node:n1": {
+					Labels: map[string]string{core.LabelNodename.Key: "n1"},
+					MetricValues: map[string]core.MetricValue{
+						core.MetricCpuUsageRate.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: cpuRate},
+						core.MetricCpuUsage.Name:     {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: cpuUsage},
+					},
+					LabeledMetrics: []core.LabeledMetric{
+						{
+							Name:        core.MetricFilesystemUsage.Name,
+							Labels:      map[string]string{core.LabelResourceID.Key: "/"},
+							MetricValue: core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: cpuRate * 10},
+						},
+					},
+				},
+			},
+		}
+	}
+	start := time.Now().Truncate(time.Minute)
+	sink.ExportData(batch(start, 100, 1000))
+	sink.ExportData(batch(start.Add(30*time.Second), 200, 2000))
+	assert.Equal(t, 6, len(client.Pnts), "only the raw points are written within the window")
+
+	// The first batch of the next window completes the previous one.
+	sink.ExportData(batch(start.Add(time.Minute), 400, 3000))
+	points := map[string]influxdb.Point{}
+	for _, saved := range client.Pnts[6:] {
+		points[saved.Ponit.Measurement] = saved.Ponit
+	}
+	assert.Equal(t, 5, len(points))
+	assert.Contains(t, points, core.MetricCpuUsageRate.Name)
+	assert.Contains(t, points, core.MetricCpuUsage.Name)
+	assert.Contains(t, points, core.MetricFilesystemUsage.Name)
+
+	downsampled := points[core.MetricCpuUsageRate.Name+"_1m"]
+	assert.Equal(t, float64(150), downsampled.Fields["value"])
+	assert.Equal(t, start.UTC(), downsampled.Time)
+	assert.Equal(t, "n1", downsampled.Tags[core.LabelNodename.Key])
+	labeled := points[core.MetricFilesystemUsage.Name+"_1m"]
+	assert.Equal(t, float64(1500), labeled.Fields["value"])
+	assert.Equal(t, "/", labeled.Tags[core.LabelResourceID.Key])
+	// Cumulative metrics are not downsampled.
+	assert.NotContains(t, points, core.MetricCpuUsage.Name+"_1m")
+}
+
+func TestDownsampledMetrics(t *testing.T) {
+	sampler := newDownsampler(90*time.Second, []string{core.MetricCpuUsage.Name})
+	assert.Equal(t, "_90s", sampler.suffix)
+	assert.True(t, sampler.covers(core.MetricCpuUsage.Name, core.MetricCumulative))
+	assert.False(t, sampler.covers(core.MetricCpuUsageRate.Name, core.MetricGauge))
+
+	assert.Equal(t, "_2h", newDownsampler(2*time.Hour, nil).suffix)
+	assert.Equal(t, "_5m", newDownsampler(5*time.Minute, nil).suffix)
+}
+
+func TestBuildConfigDownsample(t *testing.T) {
+	uri, err := url.Parse("influxdb:?downsample=5m&downsample_metrics=cpu/usage_rate,memory/usage")
+	assert.NoError(t, err)
+	config, err := influxdb_common.BuildConfig(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, config.DownsampleWindow)
+	assert.Equal(t, []string{"cpu/usage_rate", "memory/usage"}, config.DownsampleMetrics)
+
+	for _, option := range []string{"downsample=5", "downsample=1500ms", "downsample=0s"} {
+		uri, err := url.Parse("influxdb:?" + option)
+		assert.NoError(t, err)
+		_, err = influxdb_common.BuildConfig(uri)
+		assert.Error(t, err, option)
+	}
+}
+
+func TestStoreDataByMetricSetType(t *testing.T) {
+	client := influxdb_common.NewFakeInfluxDBClient()
+	config := influxdb_common.Config
+	config.DbNameByType = map[string]string{
+		core.MetricSetTypeNode: "k8s_nodes",
+		core.MetricSetTypePod:  "k8s_pods",
+	}
+	sink := &influxdbSink{
+		client:  client,
+		c:       config,
+		conChan: make(chan struct{}, config.Concurrency),
+	}
+
+	metricSet := func(metricSetType string) *core.MetricSet {
+		return &core.MetricSet{
+			Labels: map[string]string{core.LabelMetricSetType.Key: metricSetType},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricMemoryUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 100},
+			},
+		}
+	}
+	sink.ExportData(&core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.NodeKey("n1"):                         metricSet(core.MetricSetTypeNode),
+			core.PodKey("ns1", "pod1"):                 metricSet(core.MetricSetTypePod),
+			core.PodContainerKey("ns1", "pod1", "app"): metricSet(core.MetricSetTypePodContainer),
+			core.NamespaceKey("ns1"):                   metricSet(core.MetricSetTypeNamespace),
+		},
+	})
+
+	databases := map[string]string{}
+	for _, saved := range client.Pnts {
+		databases[saved.Ponit.Tags[core.LabelMetricSetType.Key]] = saved.Database
+	}
+	assert.Equal(t, map[string]string{
+		core.MetricSetTypeNode:         "k8s_nodes",
+		core.MetricSetTypePod:          "k8s_pods",
+		core.MetricSetTypePodContainer: "k8s",
+		core.MetricSetTypeNamespace:    "k8s",
+	}, databases)
+	assert.Equal(t, map[string]bool{"k8s": true, "k8s_nodes": true, "k8s_pods": true}, sink.dbExists)
+}
+
+func TestBuildConfigDbByType(t *testing.T) {
+	uri, err := url.Parse("influxdb:?db=k8s&db_by_type=node:k8s_nodes,pod:k8s_pods")
+	assert.NoError(t, err)
+	config, err := influxdb_common.BuildConfig(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, "k8s", config.DbName)
+	assert.Equal(t, map[string]string{"node": "k8s_nodes", "pod": "k8s_pods"}, config.DbNameByType)
+
+	uri, err = url.Parse("influxdb:?db=k8s")
+	assert.NoError(t, err)
+	config, err = influxdb_common.BuildConfig(uri)
+	assert.NoError(t, err)
+	assert.Empty(t, config.DbNameByType)
+
+	for _, option := range []string{"db_by_type=node", "db_by_type=node:", "db_by_type=:k8s_nodes", "db_by_type=node:a,node:b"} {
+		uri, err := url.Parse("influxdb:?" + option)
+		assert.NoError(t, err)
+		_, err = influxdb_common.BuildConfig(uri)
+		assert.Error(t, err, option)
+	}
+}
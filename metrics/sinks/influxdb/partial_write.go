@@ -0,0 +1,118 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	influxdb "github.com/influxdata/influxdb/client"
+)
+
+const partialWriteError = "partial write"
+
+var (
+	// Line of the request that InfluxDB failed to parse.
+	unparsableLineRegexp = regexp.MustCompile(`unable to parse '((?:[^'\\]|\\.)*)'`)
+	// Field whose type differs from the one stored in the shard.
+	fieldTypeConflictRegexp = regexp.MustCompile(`input field "([^"]*)" on measurement "([^"]*)" is type (\w+)`)
+)
+
+// fieldTypeConflict identifies the points rejected because of a field of another type.
+type fieldTypeConflict struct {
+	field       string
+	measurement string
+	fieldType   string
+}
+
+// partialWriteRejection holds the reasons InfluxDB gave for rejecting some of the points
+// of a write.
+type partialWriteRejection struct {
+	unparsableLines map[string]bool
+	conflicts       []fieldTypeConflict
+}
+
+// parsePartialWrite parses the error of a write into the points InfluxDB rejected,
+// returning false if the write did not fail with a partial write error. The client
+// returns the body of the response, {"error": "partial write: ..."}, as error message.
+func parsePartialWrite(err error) (*partialWriteRejection, bool) {
+	message := err.Error()
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal([]byte(message), &body) == nil && body.Error != "" {
+		message = body.Error
+	}
+	if !strings.HasPrefix(message, partialWriteError) {
+		return nil, false
+	}
+
+	rejection := &partialWriteRejection{unparsableLines: make(map[string]bool)}
+	for _, match := range unparsableLineRegexp.FindAllStringSubmatch(message, -1) {
+		rejection.unparsableLines[match[1]] = true
+	}
+	for _, match := range fieldTypeConflictRegexp.FindAllStringSubmatch(message, -1) {
+		rejection.conflicts = append(rejection.conflicts, fieldTypeConflict{
+			field:       match[1],
+			measurement: match[2],
+			fieldType:   match[3],
+		})
+	}
+	return rejection, true
+}
+
+// rejects returns whether the point is one of the rejected ones.
+func (this *partialWriteRejection) rejects(point influxdb.Point) bool {
+	if this.unparsableLines[point.MarshalString()] {
+		return true
+	}
+	for _, conflict := range this.conflicts {
+		if point.Measurement != conflict.measurement {
+			continue
+		}
+		if value, found := point.Fields[conflict.field]; found && fieldType(value) == conflict.fieldType {
+			return true
+		}
+	}
+	return false
+}
+
+// split splits the points into the rejected ones and the others.
+func (this *partialWriteRejection) split(points []influxdb.Point) (kept, dropped []influxdb.Point) {
+	for _, point := range points {
+		if this.rejects(point) {
+			dropped = append(dropped, point)
+		} else {
+			kept = append(kept, point)
+		}
+	}
+	return kept, dropped
+}
+
+// fieldType returns the name InfluxDB gives to the type of a field value.
+func fieldType(value interface{}) string {
+	switch value.(type) {
+	case float32, float64:
+		return "float"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32:
+		return "integer"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package influxdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	influxdb "github.com/influxdata/influxdb/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	influxdb_common "k8s.io/heapster/common/influxdb"
+)
+
+// rejectingInfluxDBClient fails the writes with the given errors, in order, before
+// storing the points.
+type rejectingInfluxDBClient struct {
+	*influxdb_common.FakeInfluxDBClient
+	errors []error
+	writes int
+}
+
+func (this *rejectingInfluxDBClient) Write(bps influxdb.BatchPoints) (*influxdb.Response, error) {
+	this.writes++
+	if len(this.errors) > 0 {
+		err := this.errors[0]
+		this.errors = this.errors[1:]
+		return &influxdb.Response{Err: err}, err
+	}
+	return this.FakeInfluxDBClient.Write(bps)
+}
+
+// influxdbError returns the error the client returns for a response of InfluxDB.
+func influxdbError(message string) error {
+	body, _ := json.Marshal(map[string]string{"error": message})
+	return errors.New(string(body))
+}
+
+func partialWritePoints() []influxdb.Point {
+	now := time.Unix(1500000000, 0)
+	point := func(measurement string, node string, value interface{}) influxdb.Point {
+		return influxdb.Point{
+			Measurement: measurement,
+			Tags:        map[string]string{"nodename": node},
+			Fields:      map[string]interface{}{valueField: value},
+			Time:        now,
+			Precision:   "s",
+		}
+	}
+	return []influxdb.Point{
+		point("cpu/usage", "n1", int64(100)),
+		point("cpu/usage", "n2", 1.5),
+		point("memory/usage", "n1", int64(2048)),
+		point("memory/usage", "n2", int64(4096)),
+	}
+}
+
+func newRejectingSink(errs ...error) (*influxdbSink, *rejectingInfluxDBClient) {
+	client := &rejectingInfluxDBClient{FakeInfluxDBClient: influxdb_common.NewFakeInfluxDBClient(), errors: errs}
+	return &influxdbSink{
+		client:   client,
+		c:        influxdb_common.Config,
+		conChan:  make(chan struct{}, influxdb_common.Config.Concurrency),
+		dbExists: map[string]bool{influxdb_common.Config.DbName: true},
+	}, client
+}
+
+func writtenMeasurements(client *rejectingInfluxDBClient) []string {
+	written := []string{}
+	for _, saved := range client.Pnts {
+		written = append(written, fmt.Sprintf("%s:%s", saved.Ponit.Measurement, saved.Ponit.Tags["nodename"]))
+	}
+	return written
+}
+
+func TestPartialWriteRetried(t *testing.T) {
+	points := partialWritePoints()
+	sink, client := newRejectingSink(influxdbError(fmt.Sprintf(
+		"partial write: unable to parse '%s': invalid number\n"+
+			`field type conflict: input field "value" on measurement "cpu/usage" is type float, already exists as type integer dropped=2`,
+		points[3].MarshalString())))
+
+	sink.concurrentSendData(influxdb_common.Config.DbName, points)
+	sink.wg.Wait()
+
+	assert.Equal(t, 2, client.writes)
+	assert.Equal(t, []string{"cpu/usage:n1", "memory/usage:n1"}, writtenMeasurements(client))
+	assert.NoError(t, sink.TakeExportError())
+}
+
+func TestPartialWriteRetriedOnce(t *testing.T) {
+	points := partialWritePoints()
+	rejected := influxdbError(fmt.Sprintf("partial write: unable to parse '%s': invalid number dropped=1", points[0].MarshalString()))
+	sink, client := newRejectingSink(rejected, rejected)
+
+	sink.concurrentSendData(influxdb_common.Config.DbName, points)
+	sink.wg.Wait()
+
+	assert.Equal(t, 2, client.writes)
+	assert.Empty(t, client.Pnts)
+	assert.Error(t, sink.TakeExportError())
+}
+
+func TestWriteErrorsNotRetried(t *testing.T) {
+	for _, err := range []error{
+		influxdbError("database not found: \"k8s\""),
+		// None of the points is known to be rejected.
+		influxdbError("partial write: points beyond retention policy dropped=4"),
+		errors.New("connection refused"),
+	} {
+		sink, client := newRejectingSink(err)
+		sink.concurrentSendData(influxdb_common.Config.DbName, partialWritePoints())
+		sink.wg.Wait()
+
+		assert.Equal(t, 1, client.writes, err.Error())
+		assert.Empty(t, client.Pnts, err.Error())
+		assert.Error(t, sink.TakeExportError(), err.Error())
+	}
+}
+
+func TestParsePartialWrite(t *testing.T) {
+	rejection, ok := parsePartialWrite(errors.New(`partial write: unable to parse 'cpu/usage,nodename=it\'s value=': missing field value dropped=1`))
+	require.True(t, ok)
+	assert.Equal(t, map[string]bool{`cpu/usage,nodename=it\'s value=`: true}, rejection.unparsableLines)
+	assert.Empty(t, rejection.conflicts)
+
+	_, ok = parsePartialWrite(influxdbError("timeout"))
+	assert.False(t, ok)
+}
@@ -34,6 +34,7 @@ type KafkaSinkPoint struct {
 type kafkaSink struct {
 	kafka_common.KafkaClient
 	sync.RWMutex
+	core.ExportErrors
 }
 
 func (sink *kafkaSink) Name() string {
@@ -57,6 +58,7 @@ func (sink *kafkaSink) ExportData(dataBatch *core.DataBatch) {
 			err := sink.ProduceKafkaMessage(point)
 			if err != nil {
 				glog.Errorf("Failed to produce metric message: %s", err)
+				sink.RecordExportError(err)
 			}
 		}
 		for _, metric := range metricSet.LabeledMetrics {
@@ -78,6 +80,7 @@ func (sink *kafkaSink) ExportData(dataBatch *core.DataBatch) {
 			err := sink.ProduceKafkaMessage(point)
 			if err != nil {
 				glog.Errorf("Failed to produce metric message: %s", err)
+				sink.RecordExportError(err)
 			}
 		}
 	}
@@ -31,6 +31,7 @@ type libratoSink struct {
 	client librato_common.Client
 	sync.RWMutex
 	c librato_common.LibratoConfig
+	core.ExportErrors
 }
 
 const (
@@ -156,6 +157,7 @@ func (sink *libratoSink) sendData(measurements []librato_common.Measurement) {
 	start := time.Now()
 	if err := sink.client.Write(measurements); err != nil {
 		glog.Errorf("Librato write failed: %v", err)
+		sink.RecordExportError(err)
 	}
 	end := time.Now()
 	glog.V(4).Infof("Exported %d data to librato in %s", len(measurements), end.Sub(start))
@@ -0,0 +1,296 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loki
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/heapster/metrics/core"
+)
+
+const (
+	defaultMaxStreams = 100
+	defaultTimeout    = 30 * time.Second
+	pushPath          = "/loki/api/v1/push"
+)
+
+// Metric set labels exported as stream labels, by the name of the stream label. The
+// labels are dropped from the streams in reverse order when there are too many streams.
+var streamLabels = []struct {
+	name  string
+	label string
+}{
+	{"namespace", core.LabelNamespaceName.Key},
+	{"node", core.LabelNodename.Key},
+	{"pod", core.LabelPodName.Key},
+}
+
+// Payload of the Loki push API.
+type pushRequest struct {
+	Streams []stream `json:"streams"`
+}
+
+type stream struct {
+	Stream map[string]string `json:"stream"`
+	// Pairs of the timestamp in nanoseconds, as a string, and the log line.
+	Values [][2]string `json:"values"`
+}
+
+// Log line of a metric set.
+type logLine struct {
+	Labels         map[string]string      `json:"labels"`
+	Metrics        map[string]interface{} `json:"metrics"`
+	LabeledMetrics []labeledMetric        `json:"labeledMetrics,omitempty"`
+}
+
+type labeledMetric struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Value  interface{}       `json:"value"`
+}
+
+type entry struct {
+	timestamp time.Time
+	line      string
+}
+
+type byTimestamp []entry
+
+func (a byTimestamp) Len() int           { return len(a) }
+func (a byTimestamp) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byTimestamp) Less(i, j int) bool { return a[i].timestamp.Before(a[j].timestamp) }
+
+type lokiSink struct {
+	sync.Mutex
+	url        string
+	tenant     string
+	maxStreams int
+	client     *http.Client
+	core.ExportErrors
+}
+
+func (sink *lokiSink) Name() string {
+	return "Loki Sink"
+}
+
+func (sink *lokiSink) Stop() {
+	// Do nothing.
+}
+
+func (sink *lokiSink) ExportData(dataBatch *core.DataBatch) {
+	sink.Lock()
+	defer sink.Unlock()
+
+	request, err := sink.pushRequest(dataBatch)
+	if err != nil {
+		glog.Errorf("Failed to encode metrics for Loki: %v", err)
+		sink.RecordExportError(err)
+		return
+	}
+	if len(request.Streams) == 0 {
+		return
+	}
+	if err := sink.send(request); err != nil {
+		glog.Errorf("Failed to push metrics to Loki at %s: %v", sink.url, err)
+		sink.RecordExportError(err)
+	}
+}
+
+// pushRequest returns the metric sets of the batch as log lines. The stream labels are
+// dropped, pod first, until the batch fits in maxStreams streams. Every log line still
+// holds all the labels of its metric set.
+func (sink *lokiSink) pushRequest(dataBatch *core.DataBatch) (*pushRequest, error) {
+	keys := make([]string, 0, len(dataBatch.MetricSets))
+	for key := range dataBatch.MetricSets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	used := len(streamLabels)
+	for ; used > 0; used-- {
+		if countStreams(dataBatch, used) <= sink.maxStreams {
+			break
+		}
+	}
+	if used < len(streamLabels) {
+		glog.V(2).Infof("Dropped %d stream labels to keep the number of Loki streams under %d", len(streamLabels)-used, sink.maxStreams)
+	}
+
+	streams := make(map[string]*stream)
+	entries := make(map[string][]entry)
+	for _, key := range keys {
+		metricSet := dataBatch.MetricSets[key]
+		labels := streamLabelsOf(metricSet, used)
+		id := streamId(labels)
+		if _, found := streams[id]; !found {
+			streams[id] = &stream{Stream: labels}
+		}
+		line, err := json.Marshal(toLogLine(metricSet))
+		if err != nil {
+			return nil, err
+		}
+		timestamp := metricSet.ScrapeTime
+		if timestamp.IsZero() {
+			timestamp = dataBatch.Timestamp
+		}
+		entries[id] = append(entries[id], entry{timestamp: timestamp, line: string(line)})
+	}
+
+	ids := make([]string, 0, len(streams))
+	for id := range streams {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	request := &pushRequest{Streams: make([]stream, 0, len(ids))}
+	for _, id := range ids {
+		// Loki rejects the entries older than the latest one of the stream.
+		streamEntries := entries[id]
+		sort.Stable(byTimestamp(streamEntries))
+		s := streams[id]
+		for _, e := range streamEntries {
+			s.Values = append(s.Values, [2]string{strconv.FormatInt(e.timestamp.UnixNano(), 10), e.line})
+		}
+		request.Streams = append(request.Streams, *s)
+	}
+	return request, nil
+}
+
+// streamLabelsOf returns the stream labels of the metric set, using the first used stream labels.
+func streamLabelsOf(metricSet *core.MetricSet, used int) map[string]string {
+	labels := map[string]string{
+		"job":  "heapster",
+		"type": metricSet.Labels[core.LabelMetricSetType.Key],
+	}
+	for _, streamLabel := range streamLabels[:used] {
+		if value := metricSet.Labels[streamLabel.label]; value != "" {
+			labels[streamLabel.name] = value
+		}
+	}
+	return labels
+}
+
+func streamId(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, strconv.Quote(name)+"="+strconv.Quote(labels[name]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func countStreams(dataBatch *core.DataBatch, used int) int {
+	ids := make(map[string]struct{})
+	for _, metricSet := range dataBatch.MetricSets {
+		ids[streamId(streamLabelsOf(metricSet, used))] = struct{}{}
+	}
+	return len(ids)
+}
+
+func toLogLine(metricSet *core.MetricSet) logLine {
+	result := logLine{
+		Labels:  metricSet.Labels,
+		Metrics: make(map[string]interface{}, len(metricSet.MetricValues)),
+	}
+	for name, value := range metricSet.MetricValues {
+		result.Metrics[name] = value.GetValue()
+	}
+	for _, metric := range metricSet.LabeledMetrics {
+		result.LabeledMetrics = append(result.LabeledMetrics, labeledMetric{
+			Name:   metric.Name,
+			Labels: metric.Labels,
+			Value:  metric.GetValue(),
+		})
+	}
+	return result
+}
+
+func (sink *lokiSink) send(request *pushRequest) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", sink.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sink.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", sink.tenant)
+	}
+	resp, err := sink.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+func NewLokiSink(uri *url.URL) (core.DataSink, error) {
+	if uri.Scheme != "http" && uri.Scheme != "https" {
+		return nil, errors.New("Loki URL has to use http or https scheme")
+	}
+	opts := uri.Query()
+	maxStreams := defaultMaxStreams
+	if len(opts["maxStreams"]) >= 1 {
+		var err error
+		maxStreams, err = strconv.Atoi(opts["maxStreams"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `maxStreams` flag - %v", err)
+		}
+		if maxStreams <= 0 {
+			return nil, errors.New("`maxStreams` flag can only be positive")
+		}
+	}
+	timeout := defaultTimeout
+	if len(opts["timeout"]) >= 1 {
+		var err error
+		timeout, err = time.ParseDuration(opts["timeout"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `timeout` flag - %v", err)
+		}
+	}
+
+	target := url.URL{Scheme: uri.Scheme, Host: uri.Host, Path: uri.Path}
+	if target.Path == "" || target.Path == "/" {
+		target.Path = pushPath
+	}
+	glog.Infof("created Loki sink with URL %s and at most %d streams", target.String(), maxStreams)
+	return &lokiSink{
+		url:        target.String(),
+		tenant:     opts.Get("tenant"),
+		maxStreams: maxStreams,
+		client:     &http.Client{Timeout: timeout},
+	}, nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loki
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/heapster/metrics/core"
+)
+
+func podMetricSet(namespace, pod, node string, scrapeTime time.Time, memory int64) *core.MetricSet {
+	return &core.MetricSet{
+		ScrapeTime: scrapeTime,
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePod,
+			core.LabelNamespaceName.Key: namespace,
+			core.LabelPodName.Key:       pod,
+			core.LabelNodename.Key:      node,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricMemoryUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: memory},
+		},
+	}
+}
+
+func newTestSink(t *testing.T, uri string) *lokiSink {
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	sink, err := NewLokiSink(parsed)
+	require.NoError(t, err)
+	return sink.(*lokiSink)
+}
+
+func TestPushPayload(t *testing.T) {
+	var request pushRequest
+	var tenant, contentType, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		tenant = req.Header.Get("X-Scope-OrgID")
+		contentType = req.Header.Get("Content-Type")
+		body, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(body, &request))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := newTestSink(t, server.URL+"?tenant=team-a")
+	now := time.Unix(1500000000, 0)
+	sink.ExportData(&core.DataBatch{
+		Timestamp: now,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): podMetricSet("ns1", "pod1", "node1", now, 100),
+			core.ClusterKey(): {
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeCluster},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsageRate.Name: {ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 1.5},
+				},
+			},
+		},
+	})
+
+	assert.Equal(t, "/loki/api/v1/push", path)
+	assert.Equal(t, "team-a", tenant)
+	assert.Equal(t, "application/json", contentType)
+	require.Equal(t, 2, len(request.Streams))
+	assert.Equal(t, map[string]string{"job": "heapster", "type": core.MetricSetTypeCluster}, request.Streams[1].Stream)
+	assert.Equal(t, map[string]string{
+		"job":       "heapster",
+		"type":      core.MetricSetTypePod,
+		"namespace": "ns1",
+		"node":      "node1",
+		"pod":       "pod1",
+	}, request.Streams[0].Stream)
+
+	require.Equal(t, 1, len(request.Streams[1].Values))
+	// Metric sets without a scrape time get the timestamp of the batch.
+	assert.Equal(t, "1500000000000000000", request.Streams[1].Values[0][0])
+	require.Equal(t, 1, len(request.Streams[0].Values))
+	var line logLine
+	require.NoError(t, json.Unmarshal([]byte(request.Streams[0].Values[0][1]), &line))
+	assert.Equal(t, "pod1", line.Labels[core.LabelPodName.Key])
+	assert.Equal(t, float64(100), line.Metrics[core.MetricMemoryUsage.Name])
+}
+
+func TestStreamLabelsBounded(t *testing.T) {
+	now := time.Now()
+	batch := &core.DataBatch{Timestamp: now, MetricSets: map[string]*core.MetricSet{}}
+	// 2 namespaces, 3 nodes and 12 pods.
+	for i := 0; i < 12; i++ {
+		namespace := fmt.Sprintf("ns%d", i%2)
+		pod := fmt.Sprintf("pod%d", i)
+		batch.MetricSets[core.PodKey(namespace, pod)] = podMetricSet(namespace, pod, fmt.Sprintf("node%d", i%3), now, int64(i))
+	}
+
+	for _, tc := range []struct {
+		maxStreams int
+		streams    int
+		labels     []string
+	}{
+		{12, 12, []string{"namespace", "node", "pod"}},
+		{11, 6, []string{"namespace", "node"}},
+		{5, 2, []string{"namespace"}},
+		{1, 1, []string{}},
+	} {
+		sink := &lokiSink{maxStreams: tc.maxStreams}
+		request, err := sink.pushRequest(batch)
+		require.NoError(t, err)
+		assert.Equal(t, tc.streams, len(request.Streams), "maxStreams %d", tc.maxStreams)
+		lines := 0
+		for _, s := range request.Streams {
+			assert.Equal(t, 2+len(tc.labels), len(s.Stream), "maxStreams %d", tc.maxStreams)
+			for _, label := range tc.labels {
+				assert.Contains(t, s.Stream, label)
+			}
+			lines += len(s.Values)
+		}
+		// No metric set is dropped.
+		assert.Equal(t, 12, lines)
+	}
+}
+
+func TestEntriesOrderedByTimestamp(t *testing.T) {
+	now := time.Now()
+	sink := &lokiSink{maxStreams: 1}
+	request, err := sink.pushRequest(&core.DataBatch{
+		Timestamp: now,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns", "a"): podMetricSet("ns", "a", "node", now, 1),
+			core.PodKey("ns", "b"): podMetricSet("ns", "b", "node", now.Add(-time.Second), 2),
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(request.Streams))
+	values := request.Streams[0].Values
+	require.Equal(t, 2, len(values))
+	assert.True(t, values[0][0] < values[1][0])
+}
+
+func TestNewLokiSink(t *testing.T) {
+	sink := newTestSink(t, "http://loki:3100/custom/push?maxStreams=10&timeout=5s")
+	assert.Equal(t, "http://loki:3100/custom/push", sink.url)
+	assert.Equal(t, 10, sink.maxStreams)
+	assert.Equal(t, 5*time.Second, sink.client.Timeout)
+	assert.Equal(t, "", sink.tenant)
+
+	for _, invalid := range []string{"loki:3100", "http://loki:3100?maxStreams=0", "http://loki:3100?timeout=soon"} {
+		uri, err := url.Parse(invalid)
+		require.NoError(t, err)
+		_, err = NewLokiSink(uri)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+	sink := newTestSink(t, server.URL)
+	sink.ExportData(&core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: map[string]*core.MetricSet{core.PodKey("ns", "a"): podMetricSet("ns", "a", "node", time.Now(), 1)},
+	})
+	assert.Error(t, sink.TakeExportError())
+}
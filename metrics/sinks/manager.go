@@ -15,7 +15,9 @@
 package sinks
 
 import (
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
@@ -49,17 +51,57 @@ var (
 		},
 		[]string{"exporter"},
 	)
+
+	// Number of exports to a sink by result, success or failure.
+	sinkWrites = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "sink",
+			Name:      "writes_total",
+			Help:      "Number of exports to a sink by result, success or failure.",
+		},
+		[]string{"sink", "result"},
+	)
+
+	// Last time a sink exported data successfully since unix epoch in seconds.
+	sinkLastSuccessTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "heapster",
+			Subsystem: "sink",
+			Name:      "last_success_timestamp",
+			Help:      "Last time a sink exported data successfully since unix epoch in seconds.",
+		},
+		[]string{"sink"},
+	)
+
+	// Number of configured sinks by state, initialized or failed.
+	configuredSinks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "heapster",
+			Subsystem: "sink",
+			Name:      "count",
+			Help:      "Number of configured sinks by state, initialized or failed.",
+		},
+		[]string{"state"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(lastExportTimestamp)
 	prometheus.MustRegister(exporterDuration)
+	prometheus.MustRegister(sinkWrites)
+	prometheus.MustRegister(sinkLastSuccessTimestamp)
+	prometheus.MustRegister(configuredSinks)
 }
 
 type sinkHolder struct {
 	sink             core.DataSink
 	dataBatchChannel chan *core.DataBatch
 	stopChannel      chan bool
+	// ID of the sink in the sink metrics, unique among the sinks of the manager.
+	id string
+	// Number of batches passed to the manager that the sink has not exported yet.
+	pending *int32
 }
 
 // Sink Manager - a special sink that distributes data to other sinks. It pushes data
@@ -73,18 +115,22 @@ type sinkManager struct {
 
 func NewDataSinkManager(sinks []core.DataSink, exportDataTimeout, stopTimeout time.Duration) (core.DataSink, error) {
 	sinkHolders := []sinkHolder{}
-	for _, sink := range sinks {
+	ids := sinkIds(sinks)
+	for i, sink := range sinks {
 		sh := sinkHolder{
 			sink:             sink,
+			id:               ids[i],
 			dataBatchChannel: make(chan *core.DataBatch),
 			stopChannel:      make(chan bool),
+			pending:          new(int32),
 		}
 		sinkHolders = append(sinkHolders, sh)
 		go func(sh sinkHolder) {
 			for {
 				select {
 				case data := <-sh.dataBatchChannel:
-					export(sh.sink, data)
+					export(sh.sink, sh.id, data)
+					atomic.AddInt32(sh.pending, -1)
 				case isStop := <-sh.stopChannel:
 					glog.V(2).Infof("Stop received: %s", sh.sink.Name())
 					if isStop {
@@ -110,11 +156,13 @@ func (this *sinkManager) ExportData(data *core.DataBatch) {
 		go func(sh sinkHolder, wg *sync.WaitGroup) {
 			defer wg.Done()
 			glog.V(2).Infof("Pushing data to: %s", sh.sink.Name())
+			atomic.AddInt32(sh.pending, 1)
 			select {
 			case sh.dataBatchChannel <- data:
 				glog.V(2).Infof("Data push completed: %s", sh.sink.Name())
 				// everything ok
 			case <-time.After(this.exportDataTimeout):
+				atomic.AddInt32(sh.pending, -1)
 				glog.Warningf("Failed to push data to sink: %s", sh.sink.Name())
 			}
 		}(sh, &wg)
@@ -123,6 +171,17 @@ func (this *sinkManager) ExportData(data *core.DataBatch) {
 	wg.Wait()
 }
 
+// QueueDepth returns the largest number of batches waiting for or being exported by a single sink.
+func (this *sinkManager) QueueDepth() int {
+	depth := 0
+	for _, sh := range this.sinkHolders {
+		if pending := int(atomic.LoadInt32(sh.pending)); pending > depth {
+			depth = pending
+		}
+	}
+	return depth
+}
+
 func (this *sinkManager) Name() string {
 	return "Manager"
 }
@@ -145,7 +204,28 @@ func (this *sinkManager) Stop() {
 	}
 }
 
-func export(s core.DataSink, data *core.DataBatch) {
+// sinkIds returns the IDs of the sinks: their names, numbered from 1 in the order of the
+// sinks if several of them have the same name, e.g. "CSV Sink 1" and "CSV Sink 2".
+func sinkIds(sinks []core.DataSink) []string {
+	count := make(map[string]int)
+	for _, sink := range sinks {
+		count[sink.Name()]++
+	}
+	ids := make([]string, len(sinks))
+	seen := make(map[string]int)
+	for i, sink := range sinks {
+		name := sink.Name()
+		if count[name] == 1 {
+			ids[i] = name
+			continue
+		}
+		seen[name]++
+		ids[i] = fmt.Sprintf("%s %d", name, seen[name])
+	}
+	return ids
+}
+
+func export(s core.DataSink, id string, data *core.DataBatch) {
 	startTime := time.Now()
 
 	defer func() {
@@ -160,4 +240,16 @@ func export(s core.DataSink, data *core.DataBatch) {
 	}()
 
 	s.ExportData(data)
+	recordExportResult(id, core.TakeExportError(s))
+}
+
+// recordExportResult counts the export. Exports of sinks that do not report their write
+// errors are counted as successful.
+func recordExportResult(id string, err error) {
+	if err != nil {
+		sinkWrites.WithLabelValues(id, "failure").Inc()
+		return
+	}
+	sinkWrites.WithLabelValues(id, "success").Inc()
+	sinkLastSuccessTimestamp.WithLabelValues(id).Set(float64(time.Now().Unix()))
 }
@@ -15,9 +15,11 @@
 package sinks
 
 import (
+	"errors"
 	"testing"
 	"time"
 
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 
 	"k8s.io/heapster/metrics/core"
@@ -129,3 +131,90 @@ func TestStop(t *testing.T) {
 	assert.Equal(t, true, sink1.IsStopped())
 	assert.Equal(t, true, sink2.IsStopped())
 }
+
+func TestQueueDepth(t *testing.T) {
+	timeout := 5 * time.Second
+
+	sink1 := util.NewDummySink("s1", time.Second)
+	sink2 := util.NewDummySink("s2", 10*time.Millisecond)
+	manager, _ := NewDataSinkManager([]core.DataSink{sink1, sink2}, timeout, timeout)
+	queue := manager.(*sinkManager)
+	assert.Equal(t, 0, queue.QueueDepth())
+
+	batch := core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	// The first batch is being exported by s1, the second one waits for it.
+	manager.ExportData(&batch)
+	go manager.ExportData(&batch)
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 2, queue.QueueDepth())
+
+	time.Sleep(2500 * time.Millisecond)
+	assert.Equal(t, 0, queue.QueueDepth())
+	assert.Equal(t, 2, sink1.GetExportCount())
+	assert.Equal(t, 2, sink2.GetExportCount())
+}
+
+type flakySink struct {
+	core.ExportErrors
+	fail bool
+}
+
+func (this *flakySink) Name() string {
+	return "flaky"
+}
+
+func (this *flakySink) ExportData(*core.DataBatch) {
+	if this.fail {
+		this.RecordExportError(errors.New("write failed"))
+	}
+}
+
+func (this *flakySink) Stop() {}
+
+func sinkWritesValue(t *testing.T, result string) float64 {
+	metric := &dto.Metric{}
+	assert.NoError(t, sinkWrites.WithLabelValues("flaky", result).Write(metric))
+	return metric.GetCounter().GetValue()
+}
+
+func TestSinkIds(t *testing.T) {
+	sinks := []core.DataSink{&flakySink{}, util.NewDummySink("s1", time.Second), &flakySink{}}
+	assert.Equal(t, []string{"flaky 1", "s1", "flaky 2"}, sinkIds(sinks))
+	assert.Equal(t, []string{"flaky"}, sinkIds(sinks[:1]))
+}
+
+func TestSinkWriteMetrics(t *testing.T) {
+	sink := &flakySink{}
+	batch := &core.DataBatch{
+		Timestamp:  time.Now(),
+		MetricSets: map[string]*core.MetricSet{},
+	}
+	successes := sinkWritesValue(t, "success")
+	failures := sinkWritesValue(t, "failure")
+
+	export(sink, "flaky", batch)
+	assert.Equal(t, successes+1, sinkWritesValue(t, "success"))
+	assert.Equal(t, failures, sinkWritesValue(t, "failure"))
+	lastSuccess := &dto.Metric{}
+	assert.NoError(t, sinkLastSuccessTimestamp.WithLabelValues("flaky").Write(lastSuccess))
+	assert.InDelta(t, float64(time.Now().Unix()), lastSuccess.GetGauge().GetValue(), 1)
+
+	sink.fail = true
+	export(sink, "flaky", batch)
+	assert.Equal(t, successes+1, sinkWritesValue(t, "success"))
+	assert.Equal(t, failures+1, sinkWritesValue(t, "failure"))
+
+	// The errors are reported through the wrapping sinks.
+	filtered := NewMetricTypeFilteringSink(sink, core.MetricGauge)
+	export(filtered, "flaky", batch)
+	assert.Equal(t, successes+1, sinkWritesValue(t, "success"))
+	assert.Equal(t, failures+2, sinkWritesValue(t, "failure"))
+
+	sink.fail = false
+	export(filtered, "flaky", batch)
+	assert.Equal(t, successes+2, sinkWritesValue(t, "success"))
+	assert.Equal(t, failures+2, sinkWritesValue(t, "failure"))
+}
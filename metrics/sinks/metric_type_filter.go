@@ -0,0 +1,88 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"fmt"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// Sink URI option selecting the type of the metrics written by the sink.
+const metricTypeOption = "metricType"
+
+// parseMetricTypeFilter returns the metric type accepted by the sink, or nil if all
+// metrics should be written.
+func parseMetricTypeFilter(value string) (*core.MetricType, error) {
+	var metricType core.MetricType
+	switch value {
+	case "", "all":
+		return nil, nil
+	case "gauge":
+		metricType = core.MetricGauge
+	case "cumulative":
+		metricType = core.MetricCumulative
+	default:
+		return nil, fmt.Errorf("unsupported `%s` flag %q - must be one of gauge, cumulative or all", metricTypeOption, value)
+	}
+	return &metricType, nil
+}
+
+// metricTypeFilteringSink passes a copy of every batch containing only the metrics of
+// the given type to the wrapped sink. The original batch is shared with other sinks and
+// must not be modified.
+type metricTypeFilteringSink struct {
+	core.DataSink
+	metricType core.MetricType
+}
+
+func NewMetricTypeFilteringSink(sink core.DataSink, metricType core.MetricType) core.DataSink {
+	return &metricTypeFilteringSink{
+		DataSink:   sink,
+		metricType: metricType,
+	}
+}
+
+func (this *metricTypeFilteringSink) ExportData(batch *core.DataBatch) {
+	filtered := &core.DataBatch{
+		Timestamp:  batch.Timestamp,
+		MetricSets: make(map[string]*core.MetricSet, len(batch.MetricSets)),
+	}
+	for key, metricSet := range batch.MetricSets {
+		newMetricSet := *metricSet
+		newMetricSet.MetricValues = make(map[string]core.MetricValue, len(metricSet.MetricValues))
+		for name, value := range metricSet.MetricValues {
+			if value.MetricType == this.metricType {
+				newMetricSet.MetricValues[name] = value
+			}
+		}
+		newMetricSet.LabeledMetrics = make([]core.LabeledMetric, 0, len(metricSet.LabeledMetrics))
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			if labeledMetric.MetricType == this.metricType {
+				newMetricSet.LabeledMetrics = append(newMetricSet.LabeledMetrics, labeledMetric)
+			}
+		}
+		filtered.MetricSets[key] = &newMetricSet
+	}
+	this.DataSink.ExportData(filtered)
+}
+
+func (this *metricTypeFilteringSink) TakeExportError() error {
+	return core.TakeExportError(this.DataSink)
+}
+
+func (this *metricTypeFilteringSink) Historical() core.HistoricalSource {
+	return core.HistoricalSourceOf(this.DataSink)
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/common/flags"
+	"k8s.io/heapster/metrics/core"
+)
+
+func metricTypeTestBatch() *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePod},
+				MetricValues: map[string]core.MetricValue{
+					"cpu/usage":      {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: 1000},
+					"cpu/usage_rate": {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 10},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:        "filesystem/usage",
+						Labels:      map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+						MetricValue: core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 2048},
+					},
+					{
+						Name:        "accelerator/duty_cycle",
+						Labels:      map[string]string{core.LabelAcceleratorMake.Key: "nvidia"},
+						MetricValue: core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: 50},
+					},
+				},
+			},
+		},
+	}
+}
+
+func metricNames(metricSet *core.MetricSet) []string {
+	names := []string{}
+	for name := range metricSet.MetricValues {
+		names = append(names, name)
+	}
+	for _, labeledMetric := range metricSet.LabeledMetrics {
+		names = append(names, labeledMetric.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestParseMetricTypeFilter(t *testing.T) {
+	for _, value := range []string{"", "all"} {
+		metricType, err := parseMetricTypeFilter(value)
+		assert.NoError(t, err)
+		assert.Nil(t, metricType)
+	}
+	metricType, err := parseMetricTypeFilter("gauge")
+	assert.NoError(t, err)
+	assert.Equal(t, core.MetricGauge, *metricType)
+	metricType, err = parseMetricTypeFilter("cumulative")
+	assert.NoError(t, err)
+	assert.Equal(t, core.MetricCumulative, *metricType)
+	_, err = parseMetricTypeFilter("rate")
+	assert.Error(t, err)
+}
+
+func TestMetricTypeFilteringSink(t *testing.T) {
+	key := core.PodKey("ns1", "pod1")
+	for metricType, expected := range map[core.MetricType][]string{
+		core.MetricGauge:      {"cpu/usage_rate", "filesystem/usage"},
+		core.MetricCumulative: {"accelerator/duty_cycle", "cpu/usage"},
+	} {
+		batch := metricTypeTestBatch()
+		recorder := &recordingSink{}
+		sink := NewMetricTypeFilteringSink(recorder, metricType)
+		assert.Equal(t, "recording", sink.Name())
+		sink.ExportData(batch)
+
+		assert.Equal(t, 1, len(recorder.batches))
+		assert.Equal(t, batch.Timestamp, recorder.batches[0].Timestamp)
+		metricSet := recorder.batches[0].MetricSets[key]
+		assert.Equal(t, expected, metricNames(metricSet))
+		assert.Equal(t, batch.MetricSets[key].Labels, metricSet.Labels)
+
+		// The original batch is left untouched.
+		assert.Equal(t, 4, len(metricNames(batch.MetricSets[key])))
+	}
+}
+
+func TestBuildWithMetricTypeFilter(t *testing.T) {
+	factory := NewSinkFactory()
+
+	uri := flags.Uri{}
+	assert.NoError(t, uri.Set("log:?metricType=all"))
+	sink, err := factory.Build(uri)
+	assert.NoError(t, err)
+	_, filtered := sink.(*metricTypeFilteringSink)
+	assert.False(t, filtered)
+
+	for _, value := range []string{"gauge", "cumulative"} {
+		uri := flags.Uri{}
+		assert.NoError(t, uri.Set("log:?metricType="+value))
+		sink, err := factory.Build(uri)
+		assert.NoError(t, err)
+		_, filtered := sink.(*metricTypeFilteringSink)
+		assert.True(t, filtered, value)
+	}
+
+	for _, rawUri := range []string{"log:?metricType=rate", "metric:?metricType=gauge"} {
+		uri := flags.Uri{}
+		assert.NoError(t, uri.Set(rawUri))
+		_, err := factory.Build(uri)
+		assert.Error(t, err, rawUri)
+	}
+}
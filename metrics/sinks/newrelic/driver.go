@@ -0,0 +1,334 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package newrelic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/heapster/metrics/core"
+)
+
+const (
+	// The Metric API rejects payloads larger than 1MB after compression.
+	defaultMaxPayloadBytes = 1000000
+	defaultTimeout         = 30 * time.Second
+)
+
+// Metric API endpoints by region.
+var regionEndpoints = map[string]string{
+	"us": "https://metric-api.newrelic.com/metric/v1",
+	"eu": "https://metric-api.eu.newrelic.com/metric/v1",
+}
+
+// Payload of the Metric API, a list of such objects.
+type metricData struct {
+	Common  commonData `json:"common"`
+	Metrics []metric   `json:"metrics"`
+}
+
+type commonData struct {
+	Attributes map[string]string `json:"attributes"`
+}
+
+type metric struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+	// In milliseconds since the epoch, the start of the interval for a count.
+	Timestamp  int64             `json:"timestamp"`
+	IntervalMs int64             `json:"interval.ms,omitempty"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// cumulativeValue is the last value of a cumulative metric, from which the next count is computed.
+type cumulativeValue struct {
+	value     core.MetricValue
+	timestamp time.Time
+}
+
+type newRelicSink struct {
+	sync.Mutex
+	endpoint        string
+	insertKey       string
+	maxPayloadBytes int
+	client          *http.Client
+	cumulatives     map[string]cumulativeValue
+	core.ExportErrors
+}
+
+func (sink *newRelicSink) Name() string {
+	return "New Relic Sink"
+}
+
+func (sink *newRelicSink) Stop() {
+	// Do nothing.
+}
+
+func (sink *newRelicSink) ExportData(dataBatch *core.DataBatch) {
+	sink.Lock()
+	defer sink.Unlock()
+
+	metrics := sink.toMetrics(dataBatch)
+	if len(metrics) == 0 {
+		return
+	}
+	payloads, err := sink.payloads(metrics)
+	if err != nil {
+		glog.Errorf("Failed to encode metrics for New Relic: %v", err)
+		sink.RecordExportError(err)
+		return
+	}
+	for _, payload := range payloads {
+		if err := sink.send(payload); err != nil {
+			glog.Errorf("Failed to send metrics to New Relic at %s: %v", sink.endpoint, err)
+			sink.RecordExportError(err)
+		}
+	}
+}
+
+// toMetrics returns the metrics of the batch, ordered by metric set, with the labels of the
+// metric set and of the labeled metric as attributes. Gauges are sent as gauges, and the
+// cumulative metrics as counts of the increase since the previous batch, so the first
+// value of a cumulative metric and the values after a reset are not sent.
+func (sink *newRelicSink) toMetrics(dataBatch *core.DataBatch) []metric {
+	keys := make([]string, 0, len(dataBatch.MetricSets))
+	for key := range dataBatch.MetricSets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	cumulatives := make(map[string]cumulativeValue, len(sink.cumulatives))
+	metrics := []metric{}
+	for _, key := range keys {
+		metricSet := dataBatch.MetricSets[key]
+		timestamp := metricSet.ScrapeTime
+		if timestamp.IsZero() {
+			timestamp = dataBatch.Timestamp
+		}
+		names := make([]string, 0, len(metricSet.MetricValues))
+		for name := range metricSet.MetricValues {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if m, ok := sink.toMetric(cumulatives, key, name, metricSet.MetricValues[name], metricSet.Labels, timestamp); ok {
+				metrics = append(metrics, m)
+			}
+		}
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			labels := make(map[string]string, len(metricSet.Labels)+len(labeledMetric.Labels))
+			for name, value := range metricSet.Labels {
+				labels[name] = value
+			}
+			for name, value := range labeledMetric.Labels {
+				labels[name] = value
+			}
+			if m, ok := sink.toMetric(cumulatives, key, labeledMetric.Name, labeledMetric.MetricValue, labels, timestamp); ok {
+				metrics = append(metrics, m)
+			}
+		}
+	}
+	sink.cumulatives = cumulatives
+	return metrics
+}
+
+func (sink *newRelicSink) toMetric(cumulatives map[string]cumulativeValue, key string, name string, value core.MetricValue,
+	labels map[string]string, timestamp time.Time) (metric, bool) {
+	if value.ValueType != core.ValueInt64 && value.ValueType != core.ValueFloat {
+		return metric{}, false
+	}
+	m := metric{
+		Name:       strings.Replace(name, "/", ".", -1),
+		Type:       "gauge",
+		Value:      value.GetValue(),
+		Timestamp:  timestamp.UnixNano() / int64(time.Millisecond),
+		Attributes: labels,
+	}
+	if value.MetricType != core.MetricCumulative {
+		return m, true
+	}
+
+	id := seriesId(key, name, labels)
+	previous, found := sink.cumulatives[id]
+	cumulatives[id] = cumulativeValue{value: value, timestamp: timestamp}
+	if !found || previous.value.ValueType != value.ValueType || !timestamp.After(previous.timestamp) {
+		return metric{}, false
+	}
+	switch value.ValueType {
+	case core.ValueInt64:
+		if value.IntValue < previous.value.IntValue {
+			return metric{}, false
+		}
+		m.Value = value.IntValue - previous.value.IntValue
+	case core.ValueFloat:
+		if value.FloatValue < previous.value.FloatValue {
+			return metric{}, false
+		}
+		m.Value = value.FloatValue - previous.value.FloatValue
+	}
+	m.Type = "count"
+	m.Timestamp = previous.timestamp.UnixNano() / int64(time.Millisecond)
+	m.IntervalMs = int64(timestamp.Sub(previous.timestamp) / time.Millisecond)
+	return m, true
+}
+
+// seriesId identifies a metric of a metric set by its name and labels.
+func seriesId(key string, name string, labels map[string]string) string {
+	labelNames := make([]string, 0, len(labels))
+	for labelName := range labels {
+		labelNames = append(labelNames, labelName)
+	}
+	sort.Strings(labelNames)
+	var id bytes.Buffer
+	id.WriteString(key)
+	id.WriteString("\x00")
+	id.WriteString(name)
+	for _, labelName := range labelNames {
+		id.WriteString("\x00")
+		id.WriteString(labelName)
+		id.WriteString("=")
+		id.WriteString(labels[labelName])
+	}
+	return id.String()
+}
+
+// payloads returns the metrics as gzipped payloads of at most maxPayloadBytes, halving
+// the metrics of a payload until it fits.
+func (sink *newRelicSink) payloads(metrics []metric) ([][]byte, error) {
+	payload, err := encode(metrics)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) <= sink.maxPayloadBytes {
+		return [][]byte{payload}, nil
+	}
+	if len(metrics) == 1 {
+		return nil, fmt.Errorf("metric %s does not fit in a payload of %d bytes", metrics[0].Name, sink.maxPayloadBytes)
+	}
+	first, err := sink.payloads(metrics[:len(metrics)/2])
+	if err != nil {
+		return nil, err
+	}
+	second, err := sink.payloads(metrics[len(metrics)/2:])
+	if err != nil {
+		return nil, err
+	}
+	return append(first, second...), nil
+}
+
+func encode(metrics []metric) ([]byte, error) {
+	body, err := json.Marshal([]metricData{{
+		Common:  commonData{Attributes: map[string]string{"collector.name": "heapster"}},
+		Metrics: metrics,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+func (sink *newRelicSink) send(payload []byte) error {
+	req, err := http.NewRequest("POST", sink.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Api-Key", sink.insertKey)
+	resp, err := sink.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// NewNewRelicSink returns a sink sending the metrics to the Metric API of New Relic. The
+// endpoint is the one of the `region` option, unless the URI holds the URL of another one.
+func NewNewRelicSink(uri *url.URL) (core.DataSink, error) {
+	opts := uri.Query()
+	insertKey := opts.Get("insertKey")
+	if insertKey == "" {
+		return nil, errors.New("`insertKey` flag is required")
+	}
+	region := "us"
+	if len(opts["region"]) >= 1 {
+		region = strings.ToLower(opts["region"][0])
+	}
+	endpoint, found := regionEndpoints[region]
+	if !found {
+		return nil, fmt.Errorf("unsupported `region` flag %q - must be one of us or eu", region)
+	}
+	if uri.Host != "" {
+		if uri.Scheme != "http" && uri.Scheme != "https" {
+			return nil, errors.New("New Relic URL has to use http or https scheme")
+		}
+		target := url.URL{Scheme: uri.Scheme, Host: uri.Host, Path: uri.Path}
+		endpoint = target.String()
+	}
+	maxPayloadBytes := defaultMaxPayloadBytes
+	if len(opts["maxPayloadBytes"]) >= 1 {
+		var err error
+		maxPayloadBytes, err = strconv.Atoi(opts["maxPayloadBytes"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `maxPayloadBytes` flag - %v", err)
+		}
+		if maxPayloadBytes <= 0 || maxPayloadBytes > defaultMaxPayloadBytes {
+			return nil, fmt.Errorf("`maxPayloadBytes` flag has to be between 1 and %d", defaultMaxPayloadBytes)
+		}
+	}
+	timeout := defaultTimeout
+	if len(opts["timeout"]) >= 1 {
+		var err error
+		timeout, err = time.ParseDuration(opts["timeout"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `timeout` flag - %v", err)
+		}
+	}
+
+	glog.Infof("created New Relic sink with endpoint %s", endpoint)
+	return &newRelicSink{
+		endpoint:        endpoint,
+		insertKey:       insertKey,
+		maxPayloadBytes: maxPayloadBytes,
+		client:          &http.Client{Timeout: timeout},
+		cumulatives:     make(map[string]cumulativeValue),
+	}, nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package newrelic
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/heapster/metrics/core"
+)
+
+// fakeMetricApi records the payloads posted to the Metric API.
+type fakeMetricApi struct {
+	sync.Mutex
+	t        *testing.T
+	payloads [][]metricData
+	sizes    []int64
+	headers  []http.Header
+}
+
+func (this *fakeMetricApi) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	this.Lock()
+	defer this.Unlock()
+	reader, err := gzip.NewReader(req.Body)
+	require.NoError(this.t, err)
+	var payload []metricData
+	require.NoError(this.t, json.NewDecoder(reader).Decode(&payload))
+	this.payloads = append(this.payloads, payload)
+	this.sizes = append(this.sizes, req.ContentLength)
+	this.headers = append(this.headers, req.Header)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// metrics returns all the metrics received, by name.
+func (this *fakeMetricApi) metrics() map[string]metric {
+	this.Lock()
+	defer this.Unlock()
+	result := make(map[string]metric)
+	for _, payload := range this.payloads {
+		for _, data := range payload {
+			for _, m := range data.Metrics {
+				result[m.Name] = m
+			}
+		}
+	}
+	return result
+}
+
+func newTestSink(t *testing.T, uri string) *newRelicSink {
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	sink, err := NewNewRelicSink(parsed)
+	require.NoError(t, err)
+	return sink.(*newRelicSink)
+}
+
+func podBatch(timestamp time.Time, cpuUsage int64) *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				ScrapeTime: timestamp,
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "pod1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 100},
+					core.MetricCpuUsage.Name:    {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: cpuUsage},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:        core.MetricFilesystemUsage.Name,
+						Labels:      map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+						MetricValue: core.MetricValue{ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 1.5},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestMetricApiPayload(t *testing.T) {
+	api := &fakeMetricApi{t: t}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	sink := newTestSink(t, server.URL+"/metric/v1?insertKey=secret")
+	now := time.Unix(1500000000, 0)
+	sink.ExportData(podBatch(now, 1000))
+	require.NoError(t, sink.TakeExportError())
+
+	require.Len(t, api.payloads, 1)
+	headers := api.headers[0]
+	assert.Equal(t, "secret", headers.Get("Api-Key"))
+	assert.Equal(t, "gzip", headers.Get("Content-Encoding"))
+	assert.Equal(t, "application/json", headers.Get("Content-Type"))
+	assert.Equal(t, map[string]string{"collector.name": "heapster"}, api.payloads[0][0].Common.Attributes)
+
+	metrics := api.metrics()
+	// The first value of a cumulative metric only starts its count.
+	require.Len(t, metrics, 2)
+	memory := metrics["memory.usage"]
+	assert.Equal(t, "gauge", memory.Type)
+	assert.Equal(t, float64(100), memory.Value)
+	assert.Equal(t, now.Unix()*1000, memory.Timestamp)
+	assert.Equal(t, map[string]string{"type": "pod", "namespace_name": "ns1", "pod_name": "pod1"}, memory.Attributes)
+	filesystem := metrics["filesystem.usage"]
+	assert.Equal(t, 1.5, filesystem.Value)
+	assert.Equal(t, "/dev/sda1", filesystem.Attributes[core.LabelResourceID.Key])
+	assert.Equal(t, "pod1", filesystem.Attributes[core.LabelPodName.Key])
+
+	sink.ExportData(podBatch(now.Add(time.Minute), 1600))
+	require.NoError(t, sink.TakeExportError())
+	cpu, found := api.metrics()["cpu.usage"]
+	require.True(t, found)
+	assert.Equal(t, "count", cpu.Type)
+	assert.Equal(t, float64(600), cpu.Value)
+	assert.Equal(t, now.Unix()*1000, cpu.Timestamp)
+	assert.Equal(t, int64(60000), cpu.IntervalMs)
+
+	// A reset of the cumulative metric is not sent as a negative count.
+	api.payloads = nil
+	sink.ExportData(podBatch(now.Add(2*time.Minute), 100))
+	_, found = api.metrics()["cpu.usage"]
+	assert.False(t, found)
+}
+
+func TestPayloadChunking(t *testing.T) {
+	api := &fakeMetricApi{t: t}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	now := time.Unix(1500000000, 0)
+	batch := &core.DataBatch{Timestamp: now, MetricSets: map[string]*core.MetricSet{}}
+	for i := 0; i < 200; i++ {
+		pod := fmt.Sprintf("pod-%d", i)
+		batch.MetricSets[core.PodKey("ns1", pod)] = &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePod,
+				core.LabelPodName.Key:       pod,
+				core.LabelPodId.Key:         fmt.Sprintf("%08x-uid", i*7919),
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricMemoryUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: int64(i * 7919)},
+			},
+		}
+	}
+
+	sink := newTestSink(t, server.URL+"?insertKey=secret&maxPayloadBytes=2000")
+	sink.ExportData(batch)
+	require.NoError(t, sink.TakeExportError())
+
+	assert.True(t, len(api.payloads) > 1, "%d payloads", len(api.payloads))
+	for _, size := range api.sizes {
+		assert.True(t, size <= 2000, "payload of %d bytes", size)
+	}
+	received := 0
+	for _, payload := range api.payloads {
+		received += len(payload[0].Metrics)
+	}
+	assert.Equal(t, 200, received)
+
+	// A single metric larger than the limit can not be sent.
+	sink = newTestSink(t, server.URL+"?insertKey=secret&maxPayloadBytes=10")
+	sink.ExportData(batch)
+	assert.Error(t, sink.TakeExportError())
+}
+
+func TestFailedRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "invalid key", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	sink := newTestSink(t, server.URL+"?insertKey=wrong")
+	sink.ExportData(podBatch(time.Now(), 1000))
+	err := sink.TakeExportError()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+}
+
+func TestRegionEndpoints(t *testing.T) {
+	sink := newTestSink(t, "?insertKey=secret")
+	assert.Equal(t, "https://metric-api.newrelic.com/metric/v1", sink.endpoint)
+	sink = newTestSink(t, "?insertKey=secret&region=EU")
+	assert.Equal(t, "https://metric-api.eu.newrelic.com/metric/v1", sink.endpoint)
+
+	for _, rawUri := range []string{
+		"",
+		"?insertKey=secret&region=apac",
+		"ftp://example.com?insertKey=secret",
+		"?insertKey=secret&maxPayloadBytes=2000000",
+		"?insertKey=secret&timeout=soon",
+	} {
+		uri, err := url.Parse(rawUri)
+		require.NoError(t, err)
+		_, err = NewNewRelicSink(uri)
+		assert.Error(t, err, rawUri)
+	}
+}
@@ -56,6 +56,7 @@ type openTSDBClient interface {
 type openTSDBSink struct {
 	client openTSDBClient
 	sync.RWMutex
+	core.ExportErrors
 	writeFailures int
 	clusterName   string
 	host          string
@@ -64,6 +65,7 @@ type openTSDBSink struct {
 func (tsdbSink *openTSDBSink) ExportData(data *core.DataBatch) {
 	if err := tsdbSink.client.Ping(); err != nil {
 		glog.Warningf("Failed to ping opentsdb: %v", err)
+		tsdbSink.RecordExportError(err)
 		return
 	}
 	dataPoints := make([]opentsdbclient.DataPoint, 0, batchSize)
@@ -75,6 +77,7 @@ func (tsdbSink *openTSDBSink) ExportData(data *core.DataBatch) {
 				if err != nil {
 					glog.Errorf("failed to write metrics to opentsdb - %v", err)
 					tsdbSink.recordWriteFailure()
+					tsdbSink.RecordExportError(err)
 					return
 				}
 				dataPoints = make([]opentsdbclient.DataPoint, 0, batchSize)
@@ -86,6 +89,7 @@ func (tsdbSink *openTSDBSink) ExportData(data *core.DataBatch) {
 		if err != nil {
 			glog.Errorf("failed to write metrics to opentsdb - %v", err)
 			tsdbSink.recordWriteFailure()
+			tsdbSink.RecordExportError(err)
 			return
 		}
 	}
@@ -0,0 +1,276 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/heapster/metrics/core"
+)
+
+const (
+	defaultPrefix        = "k8s_"
+	defaultExemplarLabel = "trace_id"
+	lastExportHelp       = "Timestamp of the batch exported to Prometheus"
+	createdSuffix        = "_created"
+	createdHelpFormat    = "Time at which %s started counting, in seconds since the epoch"
+)
+
+var metricDescriptions = func() map[string]string {
+	result := make(map[string]string, len(core.AllMetrics))
+	for _, metric := range core.AllMetrics {
+		result[metric.Name] = metric.Description
+	}
+	return result
+}()
+
+// prometheusSink exposes the metrics of the latest batch on the /metrics endpoint of Heapster,
+// along with the metrics of Heapster itself, or on its own port.
+type prometheusSink struct {
+	sync.RWMutex
+	core.ExportErrors
+	prefix         string
+	batch          *core.DataBatch
+	normalizer     *labelNormalizer
+	lastExportDesc *prom.Desc
+	// Metric set label holding the ID of a trace, exported as an exemplar of the
+	// counters of the metric set instead of as a label.
+	exemplarLabel string
+
+	exemplarsLock sync.RWMutex
+	// Exemplars of the latest collection, keyed by the series.
+	exemplars map[string]*exemplar
+
+	// Whether every counter is accompanied by a <name>_created gauge holding the time at
+	// which the counter started, i.e. the creation time of its container.
+	createdSeries bool
+
+	// Server of the /metrics endpoint of the sink, if it has its own port.
+	server   *http.Server
+	listener net.Listener
+	// Error with which the server stopped serving, failing the following exports.
+	serveErr error
+}
+
+func (sink *prometheusSink) Name() string {
+	return "Prometheus Sink"
+}
+
+func (sink *prometheusSink) Stop() {
+	if sink.server != nil {
+		if err := sink.server.Close(); err != nil {
+			glog.Errorf("Failed to stop the Prometheus sink server on %s: %v", sink.listener.Addr(), err)
+		}
+		return
+	}
+	prom.Unregister(sink)
+	activeSinksLock.Lock()
+	defer activeSinksLock.Unlock()
+	delete(activeSinks, sink)
+}
+
+func (sink *prometheusSink) ExportData(dataBatch *core.DataBatch) {
+	sink.Lock()
+	defer sink.Unlock()
+	sink.batch = dataBatch
+	if sink.serveErr != nil {
+		sink.RecordExportError(sink.serveErr)
+	}
+}
+
+// Describe implements prometheus.Collector. The metrics of the batches are not known
+// upfront, so only the export timestamp is described.
+func (sink *prometheusSink) Describe(ch chan<- *prom.Desc) {
+	ch <- sink.lastExportDesc
+}
+
+// Collect implements prometheus.Collector.
+func (sink *prometheusSink) Collect(ch chan<- prom.Metric) {
+	sink.RLock()
+	defer sink.RUnlock()
+	if sink.batch == nil {
+		return
+	}
+	ch <- &namedMetric{
+		Metric:     prom.MustNewConstMetric(sink.lastExportDesc, prom.GaugeValue, float64(sink.batch.Timestamp.Unix())),
+		name:       sink.prefix + "last_export_timestamp_seconds",
+		help:       lastExportHelp,
+		metricType: dto.MetricType_GAUGE,
+	}
+
+	exemplars := make(map[string]*exemplar)
+	for _, metricSet := range sink.batch.MetricSets {
+		labels := metricSet.Labels
+		traceID := labels[sink.exemplarLabel]
+		if traceID != "" {
+			labels = make(map[string]string, len(metricSet.Labels))
+			for k, v := range metricSet.Labels {
+				if k != sink.exemplarLabel {
+					labels[k] = v
+				}
+			}
+		}
+		for name, value := range metricSet.MetricValues {
+			sink.collect(ch, name, labels, value, metricSet.CollectionStartTime, traceID, exemplars)
+		}
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			metricLabels := make(map[string]string, len(labels)+len(labeledMetric.Labels))
+			for k, v := range labels {
+				metricLabels[k] = v
+			}
+			for k, v := range labeledMetric.Labels {
+				metricLabels[k] = v
+			}
+			sink.collect(ch, labeledMetric.Name, metricLabels, labeledMetric.MetricValue, metricSet.CollectionStartTime, traceID, exemplars)
+		}
+	}
+
+	sink.exemplarsLock.Lock()
+	defer sink.exemplarsLock.Unlock()
+	sink.exemplars = exemplars
+}
+
+func (sink *prometheusSink) exemplar(key string) *exemplar {
+	sink.exemplarsLock.RLock()
+	defer sink.exemplarsLock.RUnlock()
+	return sink.exemplars[key]
+}
+
+func (sink *prometheusSink) collect(ch chan<- prom.Metric, name string, labels map[string]string, value core.MetricValue,
+	created time.Time, traceID string, exemplars map[string]*exemplar) {
+	var floatValue float64
+	switch value.ValueType {
+	case core.ValueInt64:
+		floatValue = float64(value.IntValue)
+	case core.ValueFloat:
+		floatValue = float64(value.FloatValue)
+	default:
+		return
+	}
+	valueType := prom.GaugeValue
+	if value.MetricType == core.MetricCumulative {
+		valueType = prom.CounterValue
+	}
+	help, found := metricDescriptions[name]
+	if !found {
+		help = name
+	}
+
+	keys, labelNames := sink.normalizer.normalize(labels)
+	labelValues := make([]string, len(keys))
+	for i, key := range keys {
+		labelValues[i] = labels[key]
+	}
+	fqName := sink.prefix + sanitizeName(name)
+	desc := prom.NewDesc(fqName, help, labelNames, nil)
+	metric, err := prom.NewConstMetric(desc, valueType, floatValue, labelValues...)
+	if err != nil {
+		glog.V(4).Infof("Prometheus sink: skipping metric %s - %v", name, err)
+		return
+	}
+	metricType := dto.MetricType_GAUGE
+	if valueType == prom.CounterValue {
+		metricType = dto.MetricType_COUNTER
+	}
+	ch <- &namedMetric{Metric: metric, name: fqName, help: help, metricType: metricType}
+
+	if sink.createdSeries && valueType == prom.CounterValue && !created.IsZero() {
+		createdName := strings.TrimSuffix(fqName, "_total") + createdSuffix
+		createdHelp := fmt.Sprintf(createdHelpFormat, fqName)
+		createdDesc := prom.NewDesc(createdName, createdHelp, labelNames, nil)
+		createdMetric, err := prom.NewConstMetric(createdDesc, prom.GaugeValue, float64(created.UnixNano())/1e9, labelValues...)
+		if err == nil {
+			ch <- &namedMetric{Metric: createdMetric, name: createdName, help: createdHelp, metricType: dto.MetricType_GAUGE}
+		}
+	}
+
+	// OpenMetrics only allows exemplars on counters.
+	if traceID != "" && valueType == prom.CounterValue {
+		exemplarName := sanitizeName(sink.exemplarLabel)
+		if len(exemplarName)+len(traceID) > maxExemplarLabelsLength {
+			glog.V(4).Infof("Prometheus sink: skipping exemplar of %s - trace ID %q is too long", name, traceID)
+			return
+		}
+		exemplars[seriesKey(fqName, sortedLabelPairs(labelNames, labelValues))] = &exemplar{
+			labelName:  exemplarName,
+			labelValue: traceID,
+			value:      floatValue,
+			timestamp:  sink.batch.Timestamp,
+		}
+	}
+}
+
+func NewPrometheusSink(uri *url.URL) (core.DataSink, error) {
+	opts := uri.Query()
+	prefix := defaultPrefix
+	if len(opts["prefix"]) >= 1 {
+		prefix = sanitizeName(opts["prefix"][0])
+	}
+	exemplarLabel := defaultExemplarLabel
+	if len(opts["exemplarLabel"]) >= 1 {
+		exemplarLabel = opts["exemplarLabel"][0]
+	}
+	port := -1
+	if len(opts["port"]) >= 1 {
+		var err error
+		port, err = strconv.Atoi(opts["port"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `port` flag - %v", err)
+		}
+		if port < 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid `port` flag %d", port)
+		}
+	}
+	createdSeries := false
+	if len(opts["createdSeries"]) >= 1 {
+		var err error
+		createdSeries, err = strconv.ParseBool(opts["createdSeries"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `createdSeries` flag - %v", err)
+		}
+	}
+	sink := &prometheusSink{
+		prefix:         prefix,
+		normalizer:     newLabelNormalizer(),
+		lastExportDesc: prom.NewDesc(prefix+"last_export_timestamp_seconds", lastExportHelp, nil, nil),
+		exemplarLabel:  exemplarLabel,
+		createdSeries:  createdSeries,
+	}
+	if port >= 0 {
+		if err := sink.serve(port); err != nil {
+			return nil, err
+		}
+		glog.Infof("created Prometheus sink with prefix %s and exemplar label %q serving on %s", prefix, exemplarLabel, sink.listener.Addr())
+		return sink, nil
+	}
+	if err := prom.Register(sink); err != nil {
+		return nil, err
+	}
+	activeSinksLock.Lock()
+	activeSinks[sink] = struct{}{}
+	activeSinksLock.Unlock()
+	glog.Infof("created Prometheus sink with prefix %s and exemplar label %q", prefix, exemplarLabel)
+	return sink, nil
+}
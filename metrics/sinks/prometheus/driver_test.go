@@ -0,0 +1,176 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/heapster/metrics/core"
+)
+
+func collect(sink *prometheusSink) map[string]*dto.Metric {
+	ch := make(chan prom.Metric, 100)
+	sink.Collect(ch)
+	close(ch)
+	result := make(map[string]*dto.Metric)
+	for metric := range ch {
+		m := &dto.Metric{}
+		metric.Write(m)
+		desc := metric.Desc().String()
+		name := desc[strings.Index(desc, `fqName: "`)+9:]
+		name = name[:strings.Index(name, `"`)]
+		result[name] = m
+	}
+	return result
+}
+
+func TestExportData(t *testing.T) {
+	uri, err := url.Parse("prometheus:?prefix=test_")
+	assert.NoError(t, err)
+	dataSink, err := NewPrometheusSink(uri)
+	assert.NoError(t, err)
+	defer dataSink.Stop()
+	sink := dataSink.(*prometheusSink)
+
+	assert.Empty(t, collect(sink))
+
+	timestamp := time.Unix(1500000000, 0)
+	sink.ExportData(&core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelPodName.Key:       "pod1",
+					"io.kubernetes.pod.name":    "pod1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsage.Name: {
+						ValueType:  core.ValueInt64,
+						MetricType: core.MetricCumulative,
+						IntValue:   100,
+					},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:   core.MetricFilesystemUsage.Name,
+						Labels: map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+						MetricValue: core.MetricValue{
+							ValueType:  core.ValueFloat,
+							MetricType: core.MetricGauge,
+							FloatValue: 2.5,
+						},
+					},
+				},
+			},
+		},
+	})
+
+	metrics := collect(sink)
+	assert.Equal(t, 3, len(metrics))
+	assert.Equal(t, float64(1500000000), metrics["test_last_export_timestamp_seconds"].GetGauge().GetValue())
+
+	cpu := metrics["test_cpu_usage"]
+	if assert.NotNil(t, cpu) {
+		assert.Equal(t, float64(100), cpu.GetCounter().GetValue())
+		labels := make(map[string]string)
+		for _, label := range cpu.Label {
+			labels[label.GetName()] = label.GetValue()
+		}
+		assert.Equal(t, map[string]string{
+			"io_kubernetes_pod_name": "pod1",
+			"pod_name":               "pod1",
+			"type":                   "pod",
+		}, labels)
+	}
+	fs := metrics["test_filesystem_usage"]
+	if assert.NotNil(t, fs) {
+		assert.Equal(t, 2.5, fs.GetGauge().GetValue())
+		assert.Equal(t, 4, len(fs.Label))
+	}
+}
+
+func TestExportCreatedSeries(t *testing.T) {
+	created := time.Unix(1499990000, 500000000)
+	metricSet := func(collectionStart time.Time) *core.MetricSet {
+		return &core.MetricSet{
+			CollectionStartTime: collectionStart,
+			Labels:              map[string]string{core.LabelPodName.Key: "pod1"},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricCpuUsage.Name:    {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: 100},
+				core.MetricMemoryUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 200},
+			},
+			LabeledMetrics: []core.LabeledMetric{
+				{
+					Name:        core.MetricNetworkRx.Name,
+					Labels:      map[string]string{core.LabelResourceID.Key: "eth0"},
+					MetricValue: core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: 300},
+				},
+			},
+		}
+	}
+
+	for _, test := range []struct {
+		uri             string
+		collectionStart time.Time
+		expected        bool
+	}{
+		{"prometheus:?prefix=created_&createdSeries=true", created, true},
+		{"prometheus:?prefix=created_&createdSeries=true", time.Time{}, false},
+		{"prometheus:?prefix=created_", created, false},
+	} {
+		uri, err := url.Parse(test.uri)
+		assert.NoError(t, err)
+		dataSink, err := NewPrometheusSink(uri)
+		assert.NoError(t, err)
+		sink := dataSink.(*prometheusSink)
+		sink.ExportData(&core.DataBatch{
+			Timestamp:  time.Unix(1500000000, 0),
+			MetricSets: map[string]*core.MetricSet{core.PodKey("ns1", "pod1"): metricSet(test.collectionStart)},
+		})
+
+		metrics := collect(sink)
+		dataSink.Stop()
+		if !test.expected {
+			assert.Equal(t, 4, len(metrics), test.uri)
+			assert.Nil(t, metrics["created_cpu_usage_created"], test.uri)
+			continue
+		}
+		assert.Equal(t, 6, len(metrics))
+		cpu := metrics["created_cpu_usage_created"]
+		if assert.NotNil(t, cpu) {
+			assert.Equal(t, 1499990000.5, cpu.GetGauge().GetValue())
+			assert.Equal(t, 1, len(cpu.Label))
+		}
+		network := metrics["created_network_rx_created"]
+		if assert.NotNil(t, network) {
+			assert.Equal(t, 1499990000.5, network.GetGauge().GetValue())
+			assert.Equal(t, 2, len(network.Label))
+		}
+		// Only the counters have a creation time.
+		assert.Nil(t, metrics["created_memory_usage_created"])
+	}
+
+	uri, err := url.Parse("prometheus:?createdSeries=maybe")
+	assert.NoError(t, err)
+	_, err = NewPrometheusSink(uri)
+	assert.Error(t, err)
+}
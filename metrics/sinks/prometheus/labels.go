@@ -0,0 +1,102 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// sanitizeName replaces the characters not allowed in Prometheus metric and label
+// names, e.g. the dots of io.kubernetes.pod.name or the slashes of cpu/usage_rate,
+// with underscores.
+func sanitizeName(name string) string {
+	result := make([]byte, 0, len(name)+1)
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9':
+			// Names can't start with a digit.
+			if i == 0 {
+				result = append(result, '_')
+			}
+		default:
+			c = '_'
+		}
+		result = append(result, c)
+	}
+	return string(result)
+}
+
+// labelNormalizer maps metric set label keys to valid Prometheus label names.
+// Keys that collide after sanitizing are resolved deterministically: the keys are
+// processed in lexical order and every colliding key gets the first free _<n> suffix.
+type labelNormalizer struct {
+	sync.Mutex
+	// Mappings that have already been logged.
+	logged map[string]string
+}
+
+func newLabelNormalizer() *labelNormalizer {
+	return &labelNormalizer{
+		logged: make(map[string]string),
+	}
+}
+
+// normalize returns the sorted original keys together with their Prometheus names.
+func (this *labelNormalizer) normalize(labels map[string]string) ([]string, []string) {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Keys that are valid as they are take precedence over the sanitized ones.
+	used := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if sanitizeName(key) == key {
+			used[key] = true
+		}
+	}
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		name := sanitizeName(key)
+		if name != key {
+			candidate := name
+			for n := 2; used[candidate]; n++ {
+				candidate = fmt.Sprintf("%s_%d", name, n)
+			}
+			name = candidate
+			used[name] = true
+			this.logMapping(key, name)
+		}
+		names[i] = name
+	}
+	return keys, names
+}
+
+func (this *labelNormalizer) logMapping(key, name string) {
+	this.Lock()
+	defer this.Unlock()
+	if this.logged[key] == name {
+		return
+	}
+	this.logged[key] = name
+	glog.Infof("Prometheus sink: exporting label %q as %q", key, name)
+}
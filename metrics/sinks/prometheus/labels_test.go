@@ -0,0 +1,70 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"pod_name":               "pod_name",
+		"io.kubernetes.pod.name": "io_kubernetes_pod_name",
+		"cpu/usage_rate":         "cpu_usage_rate",
+		"app-kubernetes-io/name": "app_kubernetes_io_name",
+		"2fa":                    "_2fa",
+		"x509":                   "x509",
+		"":                       "",
+	} {
+		assert.Equal(t, expected, sanitizeName(name), name)
+	}
+}
+
+func TestNormalizeLabels(t *testing.T) {
+	normalizer := newLabelNormalizer()
+	keys, names := normalizer.normalize(map[string]string{
+		"pod_name":               "pod1",
+		"io.kubernetes.pod.name": "pod1",
+		"app":                    "web",
+	})
+	assert.Equal(t, []string{"app", "io.kubernetes.pod.name", "pod_name"}, keys)
+	assert.Equal(t, []string{"app", "io_kubernetes_pod_name", "pod_name"}, names)
+}
+
+func TestNormalizeLabelsCollisions(t *testing.T) {
+	normalizer := newLabelNormalizer()
+	labels := map[string]string{
+		"app.name":   "a",
+		"app/name":   "b",
+		"app-name":   "c",
+		"app_name":   "d",
+		"app_name_2": "e",
+	}
+	expectedKeys := []string{"app-name", "app.name", "app/name", "app_name", "app_name_2"}
+	// The valid keys keep their names, the others get suffixes in lexical order.
+	expectedNames := []string{"app_name_3", "app_name_4", "app_name_5", "app_name", "app_name_2"}
+	for i := 0; i < 5; i++ {
+		keys, names := normalizer.normalize(labels)
+		assert.Equal(t, expectedKeys, keys)
+		assert.Equal(t, expectedNames, names)
+	}
+	assert.Equal(t, map[string]string{
+		"app-name": "app_name_3",
+		"app.name": "app_name_4",
+		"app/name": "app_name_5",
+	}, normalizer.logged)
+}
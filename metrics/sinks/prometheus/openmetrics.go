@@ -0,0 +1,349 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	openMetricsContentType = `application/openmetrics-text; version=1.0.0; charset=utf-8`
+	// Format in which the metric families are requested from the wrapped handler.
+	protoDelimitedAccept = `application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited`
+	// Maximum combined length of the exemplar label names and values.
+	maxExemplarLabelsLength = 128
+)
+
+// exemplar links a counter sample to a trace.
+type exemplar struct {
+	labelName  string
+	labelValue string
+	value      float64
+	timestamp  time.Time
+}
+
+// Prometheus sinks whose exemplars are added to the OpenMetrics exposition.
+var (
+	activeSinksLock sync.RWMutex
+	activeSinks     = make(map[*prometheusSink]struct{})
+)
+
+func lookupExemplar(key string) *exemplar {
+	activeSinksLock.RLock()
+	defer activeSinksLock.RUnlock()
+	for sink := range activeSinks {
+		if e := sink.exemplar(key); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// seriesKey identifies a series by the metric name and the label pairs sorted by name.
+func seriesKey(name string, labels []*dto.LabelPair) string {
+	var buf bytes.Buffer
+	buf.WriteString(name)
+	for _, label := range labels {
+		buf.WriteByte('|')
+		buf.WriteString(label.GetName())
+		buf.WriteByte('=')
+		buf.WriteString(label.GetValue())
+	}
+	return buf.String()
+}
+
+func sortedLabelPairs(names, values []string) []*dto.LabelPair {
+	pairs := make([]*dto.LabelPair, len(names))
+	for i := range names {
+		pairs[i] = &dto.LabelPair{Name: &names[i], Value: &values[i]}
+	}
+	sort.Sort(prom.LabelPairSorter(pairs))
+	return pairs
+}
+
+// openMetricsHandler serves the metrics of the wrapped handler in the OpenMetrics text
+// format, including the exemplars of the Prometheus sinks, to the clients asking for it.
+// All the other requests are passed to the wrapped handler.
+type openMetricsHandler struct {
+	handler http.Handler
+}
+
+func NewHandler(handler http.Handler) http.Handler {
+	return &openMetricsHandler{handler: handler}
+}
+
+func (this *openMetricsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !acceptsOpenMetrics(req.Header) {
+		this.handler.ServeHTTP(w, req)
+		return
+	}
+	families, err := this.gather()
+	if err != nil {
+		glog.Errorf("Failed to gather metrics for the OpenMetrics exposition: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", openMetricsContentType)
+	if err := writeOpenMetrics(w, families, lookupExemplar); err != nil {
+		glog.Errorf("Failed to write the OpenMetrics exposition: %v", err)
+	}
+}
+
+func acceptsOpenMetrics(header http.Header) bool {
+	for _, accept := range header["Accept"] {
+		if strings.Contains(accept, "application/openmetrics-text") {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponse records the response of the wrapped handler.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (this *bufferedResponse) Header() http.Header {
+	return this.header
+}
+
+func (this *bufferedResponse) Write(data []byte) (int, error) {
+	return this.body.Write(data)
+}
+
+func (this *bufferedResponse) WriteHeader(status int) {
+	this.status = status
+}
+
+// gather requests the metric families from the wrapped handler in the delimited
+// protocol buffer format.
+func (this *openMetricsHandler) gather() ([]*dto.MetricFamily, error) {
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", protoDelimitedAccept)
+	response := &bufferedResponse{header: make(http.Header)}
+	this.handler.ServeHTTP(response, req)
+	if response.status != 0 && response.status != http.StatusOK {
+		return nil, fmt.Errorf("metrics handler failed with status %d: %s", response.status, response.body.String())
+	}
+
+	families := []*dto.MetricFamily{}
+	decoder := expfmt.NewDecoder(&response.body, expfmt.FmtProtoDelim)
+	for {
+		family := &dto.MetricFamily{}
+		if err := decoder.Decode(family); err == io.EOF {
+			return families, nil
+		} else if err != nil {
+			return nil, err
+		}
+		families = append(families, family)
+	}
+}
+
+// createdFamilies returns the <counter>_created gauge families of the counters, keyed by
+// the name of the counter family without the _total suffix.
+func createdFamilies(families []*dto.MetricFamily) map[string]*dto.MetricFamily {
+	counters := make(map[string]bool)
+	for _, family := range families {
+		if family.GetType() == dto.MetricType_COUNTER {
+			counters[strings.TrimSuffix(family.GetName(), "_total")] = true
+		}
+	}
+	created := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		counter := strings.TrimSuffix(family.GetName(), createdSuffix)
+		if family.GetType() == dto.MetricType_GAUGE && counter != family.GetName() && counters[counter] {
+			created[counter] = family
+		}
+	}
+	return created
+}
+
+// writeOpenMetrics writes the metric families in the OpenMetrics text format. The
+// exemplars returned by lookup for the series keys are attached to the counter samples.
+// The <counter>_created gauges are written as the _created samples of their counters.
+func writeOpenMetrics(out io.Writer, families []*dto.MetricFamily, lookup func(string) *exemplar) error {
+	w := bufio.NewWriter(out)
+	created := createdFamilies(families)
+	for _, family := range families {
+		name := family.GetName()
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			// The _total suffix belongs to the counter samples, not to the family.
+			familyName := strings.TrimSuffix(name, "_total")
+			writeHeader(w, familyName, "counter", family.GetHelp())
+			createdValues := make(map[string]float64)
+			if createdFamily, found := created[familyName]; found {
+				for _, metric := range createdFamily.Metric {
+					createdValues[seriesKey("", metric.Label)] = metric.GetGauge().GetValue()
+				}
+			}
+			for _, metric := range family.Metric {
+				writeSample(w, familyName+"_total", metric.Label, nil, metric.GetCounter().GetValue())
+				if e := lookup(seriesKey(name, metric.Label)); e != nil {
+					writeExemplar(w, e)
+				}
+				w.WriteByte('\n')
+				if value, found := createdValues[seriesKey("", metric.Label)]; found {
+					writeSample(w, familyName+createdSuffix, metric.Label, nil, value)
+					w.WriteByte('\n')
+				}
+			}
+		case dto.MetricType_GAUGE:
+			if created[strings.TrimSuffix(name, createdSuffix)] == family {
+				// Written along with its counter.
+				continue
+			}
+			writeHeader(w, name, "gauge", family.GetHelp())
+			for _, metric := range family.Metric {
+				writeSample(w, name, metric.Label, nil, metric.GetGauge().GetValue())
+				w.WriteByte('\n')
+			}
+		case dto.MetricType_SUMMARY:
+			writeHeader(w, name, "summary", family.GetHelp())
+			for _, metric := range family.Metric {
+				summary := metric.GetSummary()
+				for _, quantile := range summary.Quantile {
+					extra := &dto.LabelPair{Name: stringPtr("quantile"), Value: stringPtr(formatFloat(quantile.GetQuantile()))}
+					writeSample(w, name, metric.Label, extra, quantile.GetValue())
+					w.WriteByte('\n')
+				}
+				writeSample(w, name+"_sum", metric.Label, nil, summary.GetSampleSum())
+				w.WriteByte('\n')
+				writeSample(w, name+"_count", metric.Label, nil, float64(summary.GetSampleCount()))
+				w.WriteByte('\n')
+			}
+		case dto.MetricType_HISTOGRAM:
+			writeHeader(w, name, "histogram", family.GetHelp())
+			for _, metric := range family.Metric {
+				histogram := metric.GetHistogram()
+				infSeen := false
+				for _, bucket := range histogram.Bucket {
+					infSeen = math.IsInf(bucket.GetUpperBound(), 1)
+					extra := &dto.LabelPair{Name: stringPtr("le"), Value: stringPtr(formatFloat(bucket.GetUpperBound()))}
+					writeSample(w, name+"_bucket", metric.Label, extra, float64(bucket.GetCumulativeCount()))
+					w.WriteByte('\n')
+				}
+				// The +Inf bucket is implicit in the Prometheus data model but required by OpenMetrics.
+				if !infSeen {
+					extra := &dto.LabelPair{Name: stringPtr("le"), Value: stringPtr("+Inf")}
+					writeSample(w, name+"_bucket", metric.Label, extra, float64(histogram.GetSampleCount()))
+					w.WriteByte('\n')
+				}
+				writeSample(w, name+"_sum", metric.Label, nil, histogram.GetSampleSum())
+				w.WriteByte('\n')
+				writeSample(w, name+"_count", metric.Label, nil, float64(histogram.GetSampleCount()))
+				w.WriteByte('\n')
+			}
+		default:
+			writeHeader(w, name, "unknown", family.GetHelp())
+			for _, metric := range family.Metric {
+				writeSample(w, name, metric.Label, nil, metric.GetUntyped().GetValue())
+				w.WriteByte('\n')
+			}
+		}
+	}
+	w.WriteString("# EOF\n")
+	return w.Flush()
+}
+
+func writeHeader(w *bufio.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+	if help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, escape(help))
+	}
+}
+
+// writeSample writes a sample without the terminating newline, so that an exemplar can follow.
+func writeSample(w *bufio.Writer, name string, labels []*dto.LabelPair, extra *dto.LabelPair, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extra != nil {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, label.GetName(), label.GetValue())
+		}
+		if extra != nil {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, extra.GetName(), extra.GetValue())
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+}
+
+func writeExemplar(w *bufio.Writer, e *exemplar) {
+	w.WriteString(" # {")
+	writeLabel(w, e.labelName, e.labelValue)
+	w.WriteString("} ")
+	w.WriteString(formatFloat(e.value))
+	if !e.timestamp.IsZero() {
+		w.WriteByte(' ')
+		w.WriteString(strconv.FormatInt(e.timestamp.Unix(), 10))
+	}
+}
+
+func writeLabel(w *bufio.Writer, name, value string) {
+	w.WriteString(name)
+	w.WriteString(`="`)
+	w.WriteString(escape(value))
+	w.WriteByte('"')
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,246 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/heapster/metrics/core"
+)
+
+func labelPair(name, value string) *dto.LabelPair {
+	return &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)}
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	counterType := dto.MetricType_COUNTER
+	gaugeType := dto.MetricType_GAUGE
+	histogramType := dto.MetricType_HISTOGRAM
+	families := []*dto.MetricFamily{
+		{
+			Name: proto.String("k8s_cpu_usage"),
+			Help: proto.String("Cumulative CPU usage"),
+			Type: &counterType,
+			Metric: []*dto.Metric{
+				{
+					Label:   []*dto.LabelPair{labelPair("pod_name", "pod1")},
+					Counter: &dto.Counter{Value: proto.Float64(100)},
+				},
+				{
+					Label:   []*dto.LabelPair{labelPair("pod_name", "pod2")},
+					Counter: &dto.Counter{Value: proto.Float64(200)},
+				},
+			},
+		},
+		{
+			Name:   proto.String("requests_total"),
+			Type:   &counterType,
+			Metric: []*dto.Metric{{Counter: &dto.Counter{Value: proto.Float64(3)}}},
+		},
+		{
+			Name: proto.String("k8s_memory_usage"),
+			Help: proto.String("Memory \"usage\""),
+			Type: &gaugeType,
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{labelPair("pod_name", "pod\n1")},
+					Gauge: &dto.Gauge{Value: proto.Float64(1.5e9)},
+				},
+			},
+		},
+		{
+			Name: proto.String("latency"),
+			Type: &histogramType,
+			Metric: []*dto.Metric{
+				{
+					Histogram: &dto.Histogram{
+						SampleCount: proto.Uint64(4),
+						SampleSum:   proto.Float64(2.5),
+						Bucket: []*dto.Bucket{
+							{UpperBound: proto.Float64(0.5), CumulativeCount: proto.Uint64(3)},
+						},
+					},
+				},
+			},
+		},
+	}
+	exemplars := map[string]*exemplar{
+		seriesKey("k8s_cpu_usage", []*dto.LabelPair{labelPair("pod_name", "pod1")}): {
+			labelName:  "trace_id",
+			labelValue: "4bf92f3577b34da6",
+			value:      100,
+			timestamp:  time.Unix(1500000000, 0),
+		},
+	}
+
+	var buf bytes.Buffer
+	err := writeOpenMetrics(&buf, families, func(key string) *exemplar {
+		return exemplars[key]
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `# TYPE k8s_cpu_usage counter
+# HELP k8s_cpu_usage Cumulative CPU usage
+k8s_cpu_usage_total{pod_name="pod1"} 100 # {trace_id="4bf92f3577b34da6"} 100 1500000000
+k8s_cpu_usage_total{pod_name="pod2"} 200
+# TYPE requests counter
+requests_total 3
+# TYPE k8s_memory_usage gauge
+# HELP k8s_memory_usage Memory \"usage\"
+k8s_memory_usage{pod_name="pod\n1"} 1.5e+09
+# TYPE latency histogram
+latency_bucket{le="0.5"} 3
+latency_bucket{le="+Inf"} 4
+latency_sum 2.5
+latency_count 4
+# EOF
+`, buf.String())
+}
+
+func TestWriteOpenMetricsCreated(t *testing.T) {
+	counterType := dto.MetricType_COUNTER
+	gaugeType := dto.MetricType_GAUGE
+	families := []*dto.MetricFamily{
+		{
+			Name: proto.String("k8s_cpu_usage"),
+			Type: &counterType,
+			Metric: []*dto.Metric{
+				{
+					Label:   []*dto.LabelPair{labelPair("pod_name", "pod1")},
+					Counter: &dto.Counter{Value: proto.Float64(100)},
+				},
+				{
+					Label:   []*dto.LabelPair{labelPair("pod_name", "pod2")},
+					Counter: &dto.Counter{Value: proto.Float64(200)},
+				},
+			},
+		},
+		{
+			Name: proto.String("k8s_cpu_usage_created"),
+			Type: &gaugeType,
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{labelPair("pod_name", "pod1")},
+					Gauge: &dto.Gauge{Value: proto.Float64(1.5e9)},
+				},
+			},
+		},
+		// Not the creation time of a counter.
+		{
+			Name:   proto.String("k8s_pod_created"),
+			Type:   &gaugeType,
+			Metric: []*dto.Metric{{Gauge: &dto.Gauge{Value: proto.Float64(1)}}},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := writeOpenMetrics(&buf, families, func(string) *exemplar { return nil })
+	require.NoError(t, err)
+	assert.Equal(t, `# TYPE k8s_cpu_usage counter
+k8s_cpu_usage_total{pod_name="pod1"} 100
+k8s_cpu_usage_created{pod_name="pod1"} 1.5e+09
+k8s_cpu_usage_total{pod_name="pod2"} 200
+# TYPE k8s_pod_created gauge
+k8s_pod_created 1
+# EOF
+`, buf.String())
+}
+
+func TestFormatFloat(t *testing.T) {
+	assert.Equal(t, "+Inf", formatFloat(math.Inf(1)))
+	assert.Equal(t, "-Inf", formatFloat(math.Inf(-1)))
+	assert.Equal(t, "NaN", formatFloat(math.NaN()))
+	assert.Equal(t, "0.25", formatFloat(0.25))
+}
+
+func TestOpenMetricsHandler(t *testing.T) {
+	uri, err := url.Parse("prometheus:?prefix=exemplar_")
+	require.NoError(t, err)
+	dataSink, err := NewPrometheusSink(uri)
+	require.NoError(t, err)
+	defer dataSink.Stop()
+
+	cpuUsage := func(value int64) map[string]core.MetricValue {
+		return map[string]core.MetricValue{
+			core.MetricCpuUsage.Name: {
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricCumulative,
+				IntValue:   value,
+			},
+			core.MetricMemoryUsage.Name: {
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricGauge,
+				IntValue:   value,
+			},
+		}
+	}
+	dataSink.ExportData(&core.DataBatch{
+		Timestamp: time.Unix(1500000000, 0),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "traced"): {
+				Labels: map[string]string{
+					core.LabelPodName.Key: "traced",
+					"trace_id":            "4bf92f3577b34da6",
+				},
+				MetricValues: cpuUsage(100),
+			},
+			core.PodKey("ns1", "untraced"): {
+				Labels: map[string]string{
+					core.LabelPodName.Key: "untraced",
+				},
+				MetricValues: cpuUsage(200),
+			},
+		},
+	})
+
+	server := httptest.NewServer(NewHandler(prom.UninstrumentedHandler()))
+	defer server.Close()
+	get := func(accept string) (string, string) {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.Header.Get("Content-Type"), string(body)
+	}
+
+	contentType, body := get("application/openmetrics-text; version=1.0.0")
+	assert.Equal(t, openMetricsContentType, contentType)
+	assert.Contains(t, body, `exemplar_cpu_usage_total{pod_name="traced"} 100 # {trace_id="4bf92f3577b34da6"} 100 1500000000`+"\n")
+	assert.Contains(t, body, `exemplar_cpu_usage_total{pod_name="untraced"} 200`+"\n")
+	// Exemplars are only attached to counters.
+	assert.Contains(t, body, `exemplar_memory_usage{pod_name="traced"} 100`+"\n")
+	assert.True(t, strings.HasSuffix(body, "# EOF\n"))
+
+	contentType, body = get("text/plain")
+	assert.True(t, strings.HasPrefix(contentType, "text/plain"), contentType)
+	assert.Contains(t, body, `exemplar_cpu_usage{pod_name="traced"} 100`)
+	assert.NotContains(t, body, "trace_id")
+}
@@ -0,0 +1,135 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/golang/glog"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// namedMetric is a metric of the sink along with the name, help and type of its family,
+// which its Desc does not expose. They are needed to serve the metrics without the
+// Prometheus registry.
+type namedMetric struct {
+	prom.Metric
+	name       string
+	help       string
+	metricType dto.MetricType
+}
+
+type metricFamilies []*dto.MetricFamily
+
+func (f metricFamilies) Len() int           { return len(f) }
+func (f metricFamilies) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f metricFamilies) Less(i, j int) bool { return f[i].GetName() < f[j].GetName() }
+
+// gather returns the metrics of the latest batch as metric families sorted by name.
+func (sink *prometheusSink) gather() ([]*dto.MetricFamily, error) {
+	ch := make(chan prom.Metric, 1000)
+	go func() {
+		sink.Collect(ch)
+		close(ch)
+	}()
+
+	var err error
+	byName := make(map[string]*dto.MetricFamily)
+	for metric := range ch {
+		named, ok := metric.(*namedMetric)
+		if !ok || err != nil {
+			// Drain the channel for Collect to return.
+			continue
+		}
+		m := &dto.Metric{}
+		if err = metric.Write(m); err != nil {
+			continue
+		}
+		family, found := byName[named.name]
+		if !found {
+			family = &dto.MetricFamily{
+				Name: &named.name,
+				Help: &named.help,
+				Type: named.metricType.Enum(),
+			}
+			byName[named.name] = family
+		}
+		family.Metric = append(family.Metric, m)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	families := make(metricFamilies, 0, len(byName))
+	for _, family := range byName {
+		families = append(families, family)
+	}
+	sort.Sort(families)
+	return families, nil
+}
+
+// ServeHTTP serves the metrics of the sink in the format asked for by the client,
+// OpenMetrics with the exemplars included.
+func (sink *prometheusSink) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	families, err := sink.gather()
+	if err != nil {
+		glog.Errorf("Failed to gather the metrics of the Prometheus sink: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if acceptsOpenMetrics(req.Header) {
+		w.Header().Set("Content-Type", openMetricsContentType)
+		if err := writeOpenMetrics(w, families, sink.exemplar); err != nil {
+			glog.Errorf("Failed to write the OpenMetrics exposition: %v", err)
+		}
+		return
+	}
+	format := expfmt.Negotiate(req.Header)
+	w.Header().Set("Content-Type", string(format))
+	encoder := expfmt.NewEncoder(w, format)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			glog.Errorf("Failed to write the metrics of the Prometheus sink: %v", err)
+			return
+		}
+	}
+}
+
+// serve serves the metrics of the sink on the /metrics endpoint of the given port, instead
+// of along with the metrics of Heapster. A port of 0 picks a free port.
+func (sink *prometheusSink) serve(port int) error {
+	listener, err := net.Listen("tcp", net.JoinHostPort("", strconv.Itoa(port)))
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", sink)
+	sink.listener = listener
+	sink.server = &http.Server{Handler: mux}
+	go func() {
+		if err := sink.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			glog.Errorf("Prometheus sink server on %s failed: %v", listener.Addr(), err)
+			sink.Lock()
+			sink.serveErr = err
+			sink.Unlock()
+		}
+	}()
+	return nil
+}
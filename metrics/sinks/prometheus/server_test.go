@@ -0,0 +1,161 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+var selfMetric = prom.NewCounter(prom.CounterOpts{
+	Namespace: "heapster",
+	Subsystem: "test",
+	Name:      "self_metric_total",
+	Help:      "A metric of Heapster itself.",
+})
+
+func init() {
+	prom.MustRegister(selfMetric)
+}
+
+func serverTestBatch() *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: time.Unix(1500000000, 0),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelPodName.Key:       "pod1",
+					defaultExemplarLabel:        "4bf92f3577b34da6a3ce929d0e0e4736",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricCpuUsage.Name:    {ValueType: core.ValueInt64, MetricType: core.MetricCumulative, IntValue: 100},
+					core.MetricMemoryUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 2048},
+				},
+			},
+		},
+	}
+}
+
+func scrape(t *testing.T, handler http.Handler, accept string) string {
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	return recorder.Body.String()
+}
+
+func TestServeOnSeparatePort(t *testing.T) {
+	uri, err := url.Parse("prometheus:?prefix=separate_&port=0")
+	require.NoError(t, err)
+	dataSink, err := NewPrometheusSink(uri)
+	require.NoError(t, err)
+	defer dataSink.Stop()
+	sink := dataSink.(*prometheusSink)
+	sink.ExportData(serverTestBatch())
+	selfMetric.Inc()
+
+	response, err := http.Get("http://" + sink.listener.Addr().String() + "/metrics")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	body, err := ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+	pipeline := string(body)
+	assert.Contains(t, pipeline, "# TYPE separate_cpu_usage counter")
+	assert.Contains(t, pipeline, `separate_cpu_usage{pod_name="pod1",type="pod"} 100`)
+	assert.Contains(t, pipeline, `separate_memory_usage{pod_name="pod1",type="pod"} 2048`)
+	assert.Contains(t, pipeline, "separate_last_export_timestamp_seconds 1.5e+09")
+	assert.NotContains(t, pipeline, "heapster_test_self_metric_total")
+
+	// The self-metrics endpoint of Heapster does not serve the metrics of the sink.
+	self := scrape(t, NewHandler(prom.UninstrumentedHandler()), "")
+	assert.Contains(t, self, "heapster_test_self_metric_total")
+	assert.NotContains(t, self, "separate_")
+}
+
+func TestServerFailureFailsExports(t *testing.T) {
+	uri, err := url.Parse("prometheus:?port=0")
+	require.NoError(t, err)
+	dataSink, err := NewPrometheusSink(uri)
+	require.NoError(t, err)
+	defer dataSink.Stop()
+	sink := dataSink.(*prometheusSink)
+	sink.ExportData(serverTestBatch())
+	assert.NoError(t, sink.TakeExportError())
+
+	// Closing the listener makes the server fail, unlike stopping the sink.
+	sink.listener.Close()
+	failed := false
+	for i := 0; i < 100 && !failed; i++ {
+		time.Sleep(10 * time.Millisecond)
+		sink.ExportData(serverTestBatch())
+		failed = sink.TakeExportError() != nil
+	}
+	assert.True(t, failed)
+	// All the following exports fail.
+	sink.ExportData(serverTestBatch())
+	assert.Error(t, sink.TakeExportError())
+}
+
+func TestServeOnSeparatePortOpenMetrics(t *testing.T) {
+	uri, err := url.Parse("prometheus:?prefix=separate_openmetrics_&port=0")
+	require.NoError(t, err)
+	dataSink, err := NewPrometheusSink(uri)
+	require.NoError(t, err)
+	defer dataSink.Stop()
+	sink := dataSink.(*prometheusSink)
+	sink.ExportData(serverTestBatch())
+
+	body := scrape(t, sink, "application/openmetrics-text; version=1.0.0")
+	assert.Contains(t, body, `separate_openmetrics_cpu_usage_total{pod_name="pod1",type="pod"} 100 # {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"} 100`)
+	assert.Contains(t, body, "# EOF")
+}
+
+func TestSharedEndpointServesBoth(t *testing.T) {
+	uri, err := url.Parse("prometheus:?prefix=shared_")
+	require.NoError(t, err)
+	dataSink, err := NewPrometheusSink(uri)
+	require.NoError(t, err)
+	defer dataSink.Stop()
+	dataSink.ExportData(serverTestBatch())
+	selfMetric.Inc()
+
+	self := scrape(t, NewHandler(prom.UninstrumentedHandler()), "")
+	assert.Contains(t, self, "heapster_test_self_metric_total")
+	assert.Contains(t, self, "shared_memory_usage")
+}
+
+func TestInvalidPort(t *testing.T) {
+	for _, rawUri := range []string{"prometheus:?port=http", "prometheus:?port=70000"} {
+		uri, err := url.Parse(rawUri)
+		require.NoError(t, err)
+		_, err = NewPrometheusSink(uri)
+		assert.Error(t, err, rawUri)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// Sink URI options renaming the metrics and the labels written by the sink, in the form
+// <old name>:<new name>. Both can be repeated.
+const (
+	renameMetricOption = "renameMetric"
+	renameLabelOption  = "renameLabel"
+)
+
+// parseRenames parses the values of a rename option into a map from the old to the new names.
+func parseRenames(option string, specs []string) (map[string]string, error) {
+	renames := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		i := strings.LastIndex(spec, ":")
+		if i <= 0 || i == len(spec)-1 {
+			return nil, fmt.Errorf("invalid `%s` flag %q - expected <old name>:<new name>", option, spec)
+		}
+		oldName, newName := spec[:i], spec[i+1:]
+		if _, found := renames[oldName]; found {
+			return nil, fmt.Errorf("duplicate `%s` flag for %q", option, oldName)
+		}
+		renames[oldName] = newName
+	}
+	return renames, nil
+}
+
+func rename(renames map[string]string, name string) string {
+	if newName, found := renames[name]; found {
+		return newName
+	}
+	return name
+}
+
+func renameLabels(renames map[string]string, labels map[string]string) map[string]string {
+	if len(renames) == 0 || labels == nil {
+		return labels
+	}
+	renamed := make(map[string]string, len(labels))
+	for name, value := range labels {
+		renamed[rename(renames, name)] = value
+	}
+	return renamed
+}
+
+// renamingSink passes a copy of every batch with the metrics and labels renamed to the
+// wrapped sink. The original batch is shared with other sinks and must not be modified.
+type renamingSink struct {
+	core.DataSink
+	metrics map[string]string
+	labels  map[string]string
+}
+
+// NewRenamingSink returns a sink renaming the metrics and the labels, both given as maps
+// from the old to the new names, before they are written by the given sink.
+func NewRenamingSink(sink core.DataSink, metrics, labels map[string]string) core.DataSink {
+	return &renamingSink{
+		DataSink: sink,
+		metrics:  metrics,
+		labels:   labels,
+	}
+}
+
+func (this *renamingSink) ExportData(batch *core.DataBatch) {
+	renamed := &core.DataBatch{
+		Timestamp:  batch.Timestamp,
+		MetricSets: make(map[string]*core.MetricSet, len(batch.MetricSets)),
+	}
+	for key, metricSet := range batch.MetricSets {
+		newMetricSet := *metricSet
+		newMetricSet.Labels = renameLabels(this.labels, metricSet.Labels)
+		newMetricSet.MetricValues = make(map[string]core.MetricValue, len(metricSet.MetricValues))
+		for name, value := range metricSet.MetricValues {
+			newMetricSet.MetricValues[rename(this.metrics, name)] = value
+		}
+		newMetricSet.LabeledMetrics = make([]core.LabeledMetric, 0, len(metricSet.LabeledMetrics))
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			labeledMetric.Name = rename(this.metrics, labeledMetric.Name)
+			labeledMetric.Labels = renameLabels(this.labels, labeledMetric.Labels)
+			newMetricSet.LabeledMetrics = append(newMetricSet.LabeledMetrics, labeledMetric)
+		}
+		renamed.MetricSets[key] = &newMetricSet
+	}
+	this.DataSink.ExportData(renamed)
+}
+
+func (this *renamingSink) TakeExportError() error {
+	return core.TakeExportError(this.DataSink)
+}
+
+func (this *renamingSink) Historical() core.HistoricalSource {
+	return core.HistoricalSourceOf(this.DataSink)
+}
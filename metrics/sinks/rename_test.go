@@ -0,0 +1,106 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/common/flags"
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestParseRenames(t *testing.T) {
+	renames, err := parseRenames(renameMetricOption, []string{"cpu/usage:cpu.usage", "custom/a:b:c"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"cpu/usage": "cpu.usage", "custom/a:b": "c"}, renames)
+
+	for _, spec := range []string{"cpu/usage", ":cpu.usage", "cpu/usage:"} {
+		_, err := parseRenames(renameMetricOption, []string{spec})
+		assert.Error(t, err, spec)
+	}
+	_, err = parseRenames(renameMetricOption, []string{"cpu/usage:a", "cpu/usage:b"})
+	assert.Error(t, err)
+}
+
+func TestRenamingSinkPerSink(t *testing.T) {
+	key := core.PodKey("ns1", "pod1")
+	batch := metricTypeTestBatch()
+	batch.MetricSets[key].Labels[core.LabelNamespaceName.Key] = "ns1"
+
+	graphite := &recordingSink{}
+	renaming := NewRenamingSink(graphite,
+		map[string]string{"cpu/usage": "cpu.usage", "filesystem/usage": "filesystem.usage"},
+		map[string]string{core.LabelNamespaceName.Key: "ns", core.LabelResourceID.Key: "device"})
+	other := &recordingSink{}
+	renaming.ExportData(batch)
+	other.ExportData(batch)
+
+	require.Len(t, graphite.batches, 1)
+	metricSet := graphite.batches[0].MetricSets[key]
+	assert.Equal(t, []string{"accelerator/duty_cycle", "cpu.usage", "cpu/usage_rate", "filesystem.usage"}, metricNames(metricSet))
+	assert.Equal(t, int64(1000), metricSet.MetricValues["cpu.usage"].IntValue)
+	assert.Equal(t, "ns1", metricSet.Labels["ns"])
+	_, found := metricSet.Labels[core.LabelNamespaceName.Key]
+	assert.False(t, found)
+	assert.Equal(t, map[string]string{"device": "/dev/sda1"}, metricSet.LabeledMetrics[0].Labels)
+
+	// The other sinks and the original batch keep the original names.
+	require.Len(t, other.batches, 1)
+	original := other.batches[0].MetricSets[key]
+	assert.Equal(t, []string{"accelerator/duty_cycle", "cpu/usage", "cpu/usage_rate", "filesystem/usage"}, metricNames(original))
+	assert.Equal(t, "ns1", original.Labels[core.LabelNamespaceName.Key])
+	assert.Equal(t, map[string]string{core.LabelResourceID.Key: "/dev/sda1"}, original.LabeledMetrics[0].Labels)
+}
+
+func TestBuildWithRenames(t *testing.T) {
+	factory := NewSinkFactory()
+
+	uri := flags.Uri{}
+	require.NoError(t, uri.Set("log:?renameMetric=cpu/usage:cpu.usage&renameLabel=nodename:node"))
+	sink, err := factory.Build(uri)
+	require.NoError(t, err)
+	renaming, ok := sink.(*renamingSink)
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{"cpu/usage": "cpu.usage"}, renaming.metrics)
+	assert.Equal(t, map[string]string{"nodename": "node"}, renaming.labels)
+	assert.Equal(t, "Log Sink", sink.Name())
+
+	// Renames apply after the metric type filter.
+	uri = flags.Uri{}
+	require.NoError(t, uri.Set("log:?metricType=gauge&renameMetric=cpu/usage_rate:cpu.usage_rate"))
+	sink, err = factory.Build(uri)
+	require.NoError(t, err)
+	renaming, ok = sink.(*renamingSink)
+	require.True(t, ok)
+	_, filtered := renaming.DataSink.(*metricTypeFilteringSink)
+	assert.True(t, filtered)
+
+	uri = flags.Uri{}
+	require.NoError(t, uri.Set("log"))
+	sink, err = factory.Build(uri)
+	require.NoError(t, err)
+	_, ok = sink.(*renamingSink)
+	assert.False(t, ok)
+
+	for _, rawUri := range []string{"log:?renameMetric=cpu/usage", "metric:?renameMetric=cpu/usage:cpu.usage", "metric:?renameLabel=nodename:node"} {
+		uri := flags.Uri{}
+		require.NoError(t, uri.Set(rawUri))
+		_, err := factory.Build(uri)
+		assert.Error(t, err, rawUri)
+	}
+}
@@ -29,6 +29,7 @@ type RiemannSink struct {
 	client riemanngo.Client
 	config riemannCommon.RiemannConfig
 	sync.RWMutex
+	core.ExportErrors
 }
 
 // creates a Riemann sink. Returns a riemannSink
@@ -76,6 +77,7 @@ func appendEvent(events []riemanngo.Event, sink *RiemannSink, host, name string,
 		err := riemannCommon.SendData(sink.client, events)
 		if err != nil {
 			glog.Warningf("Error sending events to Riemann: ", err)
+			sink.RecordExportError(err)
 			// client will reconnect later
 			sink.client = nil
 		}
@@ -94,6 +96,7 @@ func (sink *RiemannSink) ExportData(dataBatch *core.DataBatch) {
 		client, err := riemannCommon.GetRiemannClient(sink.config)
 		if err != nil {
 			glog.Warningf("Riemann sink not connected: %v", err)
+			sink.RecordExportError(err)
 			return
 		}
 		sink.client = client
@@ -130,6 +133,7 @@ func (sink *RiemannSink) ExportData(dataBatch *core.DataBatch) {
 		err := riemannCommon.SendData(sink.client, events)
 		if err != nil {
 			glog.Warningf("Error sending events to Riemann: ", err)
+			sink.RecordExportError(err)
 			// client will reconnect later
 			sink.client = nil
 		}
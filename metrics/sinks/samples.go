@@ -0,0 +1,99 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// Sink URI option selecting whether only the metric values processed by Heapster are
+// written, or also the other samples reported by the sources within the scrape window.
+const samplesOption = "samples"
+
+// parseSamples returns whether all the samples should be written.
+func parseSamples(value string) (bool, error) {
+	switch value {
+	case "", "latest":
+		return false, nil
+	case "all":
+		return true, nil
+	}
+	return false, fmt.Errorf("unsupported `%s` flag %q - must be one of latest or all", samplesOption, value)
+}
+
+type timestamps []time.Time
+
+func (t timestamps) Len() int           { return len(t) }
+func (t timestamps) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t timestamps) Less(i, j int) bool { return t[i].Before(t[j]) }
+
+// sampleExpandingSink passes a batch per timestamp of the samples of the metric sets to
+// the wrapped sink, oldest first, before the batch itself. Each of them holds the metric
+// sets with a sample at that time, with the values of the sample and the batch timestamp
+// and scrape time set to the time of the sample.
+type sampleExpandingSink struct {
+	core.DataSink
+}
+
+func NewSampleExpandingSink(sink core.DataSink) core.DataSink {
+	return &sampleExpandingSink{DataSink: sink}
+}
+
+func (this *sampleExpandingSink) ExportData(batch *core.DataBatch) {
+	batches := map[time.Time]*core.DataBatch{}
+	for key, metricSet := range batch.MetricSets {
+		for _, sample := range metricSet.Samples {
+			timestamp := sample.Timestamp
+			sampleBatch, found := batches[timestamp]
+			if !found {
+				sampleBatch = &core.DataBatch{
+					Timestamp:  timestamp,
+					MetricSets: map[string]*core.MetricSet{},
+				}
+				batches[timestamp] = sampleBatch
+			}
+			sampleBatch.MetricSets[key] = &core.MetricSet{
+				CollectionStartTime: metricSet.CollectionStartTime,
+				EntityCreateTime:    metricSet.EntityCreateTime,
+				ScrapeTime:          timestamp,
+				MetricValues:        sample.MetricValues,
+				Labels:              metricSet.Labels,
+				LabeledMetrics:      []core.LabeledMetric{},
+			}
+		}
+	}
+
+	sorted := make(timestamps, 0, len(batches))
+	for timestamp := range batches {
+		sorted = append(sorted, timestamp)
+	}
+	sort.Sort(sorted)
+	for _, timestamp := range sorted {
+		this.DataSink.ExportData(batches[timestamp])
+	}
+	this.DataSink.ExportData(batch)
+}
+
+func (this *sampleExpandingSink) TakeExportError() error {
+	return core.TakeExportError(this.DataSink)
+}
+
+func (this *sampleExpandingSink) Historical() core.HistoricalSource {
+	return core.HistoricalSourceOf(this.DataSink)
+}
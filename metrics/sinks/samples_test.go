@@ -0,0 +1,109 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/common/flags"
+	"k8s.io/heapster/metrics/core"
+)
+
+func samplesTestBatch(now time.Time) *core.DataBatch {
+	gauge := func(value int64) core.MetricValue {
+		return core.MetricValue{ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: value}
+	}
+	container := func(name string, samples ...core.MetricSample) *core.MetricSet {
+		return &core.MetricSet{
+			ScrapeTime: now,
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePodContainer,
+				core.LabelContainerName.Key: name,
+			},
+			MetricValues: map[string]core.MetricValue{"memory/usage": gauge(400)},
+			Samples:      samples,
+		}
+	}
+	return &core.DataBatch{
+		Timestamp: now,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodContainerKey("ns1", "pod1", "app"): container("app",
+				core.MetricSample{Timestamp: now.Add(-20 * time.Second), MetricValues: map[string]core.MetricValue{"memory/usage": gauge(200)}},
+				core.MetricSample{Timestamp: now.Add(-10 * time.Second), MetricValues: map[string]core.MetricValue{"memory/usage": gauge(300)}}),
+			core.PodContainerKey("ns1", "pod1", "sidecar"): container("sidecar",
+				core.MetricSample{Timestamp: now.Add(-10 * time.Second), MetricValues: map[string]core.MetricValue{"memory/usage": gauge(30)}}),
+			core.PodContainerKey("ns1", "pod1", "other"): container("other"),
+		},
+	}
+}
+
+func TestSampleExpandingSink(t *testing.T) {
+	now := time.Now()
+	batch := samplesTestBatch(now)
+	recorder := &recordingSink{}
+	NewSampleExpandingSink(recorder).ExportData(batch)
+
+	require.Len(t, recorder.batches, 3)
+	app := core.PodContainerKey("ns1", "pod1", "app")
+	sidecar := core.PodContainerKey("ns1", "pod1", "sidecar")
+
+	oldest := recorder.batches[0]
+	assert.Equal(t, now.Add(-20*time.Second), oldest.Timestamp)
+	require.Len(t, oldest.MetricSets, 1)
+	assert.Equal(t, now.Add(-20*time.Second), oldest.MetricSets[app].ScrapeTime)
+	assert.Equal(t, int64(200), oldest.MetricSets[app].MetricValues["memory/usage"].IntValue)
+	assert.Equal(t, "app", oldest.MetricSets[app].Labels[core.LabelContainerName.Key])
+
+	middle := recorder.batches[1]
+	assert.Equal(t, now.Add(-10*time.Second), middle.Timestamp)
+	require.Len(t, middle.MetricSets, 2)
+	assert.Equal(t, int64(300), middle.MetricSets[app].MetricValues["memory/usage"].IntValue)
+	assert.Equal(t, int64(30), middle.MetricSets[sidecar].MetricValues["memory/usage"].IntValue)
+
+	// The batch itself comes last, unchanged.
+	assert.Equal(t, batch, recorder.batches[2])
+	assert.Equal(t, int64(400), recorder.batches[2].MetricSets[app].MetricValues["memory/usage"].IntValue)
+}
+
+func TestBuildWithSamples(t *testing.T) {
+	factory := NewSinkFactory()
+
+	uri := flags.Uri{}
+	require.NoError(t, uri.Set("log:?samples=all&renameMetric=memory/usage:memory.usage"))
+	sink, err := factory.Build(uri)
+	require.NoError(t, err)
+	expanding, ok := sink.(*sampleExpandingSink)
+	require.True(t, ok)
+	_, renamed := expanding.DataSink.(*renamingSink)
+	assert.True(t, renamed)
+
+	uri = flags.Uri{}
+	require.NoError(t, uri.Set("log:?samples=latest"))
+	sink, err = factory.Build(uri)
+	require.NoError(t, err)
+	_, ok = sink.(*sampleExpandingSink)
+	assert.False(t, ok)
+
+	for _, rawUri := range []string{"log:?samples=some", "metric:?samples=all"} {
+		uri := flags.Uri{}
+		require.NoError(t, uri.Set(rawUri))
+		_, err := factory.Build(uri)
+		assert.Error(t, err, rawUri)
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package splunk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/heapster/metrics/core"
+)
+
+const (
+	collectorPath = "/services/collector"
+	// HEC rejects requests larger than its max_content_length, 1MB by default.
+	defaultMaxPayloadBytes = 1000000
+	defaultSource          = "heapster"
+	defaultTimeout         = 30 * time.Second
+)
+
+// event is a metric in the format of the metrics indexes. The dimensions are the other
+// fields.
+type event struct {
+	// In seconds since the epoch, with a millisecond precision.
+	Time       float64                `json:"time"`
+	Event      string                 `json:"event"`
+	Source     string                 `json:"source,omitempty"`
+	Sourcetype string                 `json:"sourcetype,omitempty"`
+	Index      string                 `json:"index,omitempty"`
+	Fields     map[string]interface{} `json:"fields"`
+}
+
+type splunkSink struct {
+	sync.Mutex
+	endpoint        string
+	token           string
+	index           string
+	source          string
+	sourcetype      string
+	gzip            bool
+	maxPayloadBytes int
+	client          *http.Client
+	core.ExportErrors
+}
+
+func (sink *splunkSink) Name() string {
+	return "Splunk Sink"
+}
+
+func (sink *splunkSink) Stop() {
+	// Do nothing.
+}
+
+func (sink *splunkSink) ExportData(dataBatch *core.DataBatch) {
+	sink.Lock()
+	defer sink.Unlock()
+
+	payloads, err := sink.payloads(sink.toEvents(dataBatch))
+	if err != nil {
+		glog.Errorf("Failed to encode metrics for Splunk: %v", err)
+		sink.RecordExportError(err)
+		return
+	}
+	for _, payload := range payloads {
+		if err := sink.send(payload); err != nil {
+			glog.Errorf("Failed to send metrics to Splunk at %s: %v", sink.endpoint, err)
+			sink.RecordExportError(err)
+		}
+	}
+}
+
+// toEvents returns an event per metric of the batch, ordered by metric set, with the
+// labels of the metric set and of the labeled metric as dimensions.
+func (sink *splunkSink) toEvents(dataBatch *core.DataBatch) []event {
+	keys := make([]string, 0, len(dataBatch.MetricSets))
+	for key := range dataBatch.MetricSets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	events := []event{}
+	for _, key := range keys {
+		metricSet := dataBatch.MetricSets[key]
+		timestamp := metricSet.ScrapeTime
+		if timestamp.IsZero() {
+			timestamp = dataBatch.Timestamp
+		}
+		names := make([]string, 0, len(metricSet.MetricValues))
+		for name := range metricSet.MetricValues {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if e, ok := sink.toEvent(name, metricSet.MetricValues[name], timestamp, metricSet.Labels); ok {
+				events = append(events, e)
+			}
+		}
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			if e, ok := sink.toEvent(labeledMetric.Name, labeledMetric.MetricValue, timestamp, metricSet.Labels, labeledMetric.Labels); ok {
+				events = append(events, e)
+			}
+		}
+	}
+	return events
+}
+
+func (sink *splunkSink) toEvent(name string, value core.MetricValue, timestamp time.Time, labels ...map[string]string) (event, bool) {
+	if value.ValueType != core.ValueInt64 && value.ValueType != core.ValueFloat {
+		return event{}, false
+	}
+	fields := map[string]interface{}{}
+	for _, dimensions := range labels {
+		for dimension, dimensionValue := range dimensions {
+			if dimensionValue != "" {
+				fields[dimension] = dimensionValue
+			}
+		}
+	}
+	fields["metric_name"] = strings.Replace(name, "/", ".", -1)
+	fields["_value"] = value.GetValue()
+	return event{
+		Time:       float64(timestamp.UnixNano()/int64(time.Millisecond)) / 1000,
+		Event:      "metric",
+		Source:     sink.source,
+		Sourcetype: sink.sourcetype,
+		Index:      sink.index,
+		Fields:     fields,
+	}, true
+}
+
+// payloads returns the events as newline separated JSON objects, batched into payloads
+// of at most maxPayloadBytes before compression.
+func (sink *splunkSink) payloads(events []event) ([][]byte, error) {
+	payloads := [][]byte{}
+	var payload bytes.Buffer
+	for _, e := range events {
+		encoded, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		if len(encoded)+1 > sink.maxPayloadBytes {
+			return nil, fmt.Errorf("metric %v does not fit in a payload of %d bytes", e.Fields["metric_name"], sink.maxPayloadBytes)
+		}
+		if payload.Len()+len(encoded)+1 > sink.maxPayloadBytes {
+			payloads = append(payloads, payload.Bytes())
+			payload = bytes.Buffer{}
+		}
+		payload.Write(encoded)
+		payload.WriteByte('\n')
+	}
+	if payload.Len() > 0 {
+		payloads = append(payloads, payload.Bytes())
+	}
+	return payloads, nil
+}
+
+func (sink *splunkSink) send(payload []byte) error {
+	body := payload
+	if sink.gzip {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		if _, err := writer.Write(payload); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		body = compressed.Bytes()
+	}
+	req, err := http.NewRequest("POST", sink.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sink.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	req.Header.Set("Authorization", "Splunk "+sink.token)
+	resp, err := sink.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// NewSplunkSink returns a sink sending the metrics to the HTTP Event Collector of Splunk,
+// e.g. https://splunk:8088?token=<TOKEN>. The collector path is used if the URL has none.
+func NewSplunkSink(uri *url.URL) (core.DataSink, error) {
+	if uri.Scheme != "http" && uri.Scheme != "https" {
+		return nil, errors.New("Splunk URL has to use http or https scheme")
+	}
+	if uri.Host == "" {
+		return nil, errors.New("Splunk URL has to have a host")
+	}
+	opts := uri.Query()
+	token := opts.Get("token")
+	if token == "" {
+		return nil, errors.New("`token` flag is required")
+	}
+	target := url.URL{Scheme: uri.Scheme, Host: uri.Host, Path: uri.Path}
+	if target.Path == "" || target.Path == "/" {
+		target.Path = collectorPath
+	}
+	source := defaultSource
+	if len(opts["source"]) >= 1 {
+		source = opts["source"][0]
+	}
+	useGzip := true
+	if len(opts["gzip"]) >= 1 {
+		var err error
+		useGzip, err = strconv.ParseBool(opts["gzip"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `gzip` flag - %v", err)
+		}
+	}
+	maxPayloadBytes := defaultMaxPayloadBytes
+	if len(opts["maxPayloadBytes"]) >= 1 {
+		var err error
+		maxPayloadBytes, err = strconv.Atoi(opts["maxPayloadBytes"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `maxPayloadBytes` flag - %v", err)
+		}
+		if maxPayloadBytes <= 0 {
+			return nil, errors.New("`maxPayloadBytes` flag can only be positive")
+		}
+	}
+	timeout := defaultTimeout
+	if len(opts["timeout"]) >= 1 {
+		var err error
+		timeout, err = time.ParseDuration(opts["timeout"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `timeout` flag - %v", err)
+		}
+	}
+
+	glog.Infof("created Splunk sink with endpoint %s", target.String())
+	return &splunkSink{
+		endpoint:        target.String(),
+		token:           token,
+		index:           opts.Get("index"),
+		source:          source,
+		sourcetype:      opts.Get("sourcetype"),
+		gzip:            useGzip,
+		maxPayloadBytes: maxPayloadBytes,
+		client:          &http.Client{Timeout: timeout},
+	}, nil
+}
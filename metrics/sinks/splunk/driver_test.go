@@ -0,0 +1,239 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package splunk
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/heapster/metrics/core"
+)
+
+// fakeCollector records the requests posted to the HTTP Event Collector.
+type fakeCollector struct {
+	sync.Mutex
+	t        *testing.T
+	paths    []string
+	headers  []http.Header
+	payloads [][]map[string]interface{}
+	sizes    []int
+}
+
+func (this *fakeCollector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	this.Lock()
+	defer this.Unlock()
+	var body io.Reader = req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(req.Body)
+		require.NoError(this.t, err)
+		body = reader
+	}
+	size := 0
+	payload := []map[string]interface{}{}
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		size += len(scanner.Bytes()) + 1
+		var e map[string]interface{}
+		require.NoError(this.t, json.Unmarshal(scanner.Bytes(), &e))
+		payload = append(payload, e)
+	}
+	require.NoError(this.t, scanner.Err())
+	this.paths = append(this.paths, req.URL.Path)
+	this.headers = append(this.headers, req.Header)
+	this.payloads = append(this.payloads, payload)
+	this.sizes = append(this.sizes, size)
+	fmt.Fprint(w, `{"text":"Success","code":0}`)
+}
+
+func newTestSink(t *testing.T, uri string) *splunkSink {
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	sink, err := NewSplunkSink(parsed)
+	require.NoError(t, err)
+	return sink.(*splunkSink)
+}
+
+func podBatch(timestamp time.Time) *core.DataBatch {
+	return &core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				ScrapeTime: timestamp,
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "pod1",
+					core.LabelLabels.Key:        "",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 100},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:        core.MetricFilesystemUsage.Name,
+						Labels:      map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+						MetricValue: core.MetricValue{ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 1.5},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestCollectorEvents(t *testing.T) {
+	collector := &fakeCollector{t: t}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	sink := newTestSink(t, server.URL+"?token=secret&index=k8s_metrics&sourcetype=heapster:metrics")
+	sink.ExportData(podBatch(time.Unix(1500000000, 250000000)))
+	require.NoError(t, sink.TakeExportError())
+
+	require.Len(t, collector.payloads, 1)
+	assert.Equal(t, "/services/collector", collector.paths[0])
+	headers := collector.headers[0]
+	assert.Equal(t, "Splunk secret", headers.Get("Authorization"))
+	assert.Equal(t, "gzip", headers.Get("Content-Encoding"))
+	assert.Equal(t, "application/json", headers.Get("Content-Type"))
+
+	assert.Equal(t, []map[string]interface{}{
+		{
+			"time":       1500000000.25,
+			"event":      "metric",
+			"source":     "heapster",
+			"sourcetype": "heapster:metrics",
+			"index":      "k8s_metrics",
+			"fields": map[string]interface{}{
+				"metric_name":    "memory.usage",
+				"_value":         float64(100),
+				"type":           "pod",
+				"namespace_name": "ns1",
+				"pod_name":       "pod1",
+			},
+		},
+		{
+			"time":       1500000000.25,
+			"event":      "metric",
+			"source":     "heapster",
+			"sourcetype": "heapster:metrics",
+			"index":      "k8s_metrics",
+			"fields": map[string]interface{}{
+				"metric_name":    "filesystem.usage",
+				"_value":         1.5,
+				"type":           "pod",
+				"namespace_name": "ns1",
+				"pod_name":       "pod1",
+				"resource_id":    "/dev/sda1",
+			},
+		},
+	}, collector.payloads[0])
+}
+
+func TestUncompressedEventsWithoutIndex(t *testing.T) {
+	collector := &fakeCollector{t: t}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	sink := newTestSink(t, server.URL+"/services/collector/event?token=secret&gzip=false&source=cluster1")
+	sink.ExportData(podBatch(time.Unix(1500000000, 0)))
+	require.NoError(t, sink.TakeExportError())
+
+	require.Len(t, collector.payloads, 1)
+	assert.Equal(t, "/services/collector/event", collector.paths[0])
+	assert.Equal(t, "", collector.headers[0].Get("Content-Encoding"))
+	e := collector.payloads[0][0]
+	assert.Equal(t, "cluster1", e["source"])
+	_, found := e["index"]
+	assert.False(t, found)
+	_, found = e["sourcetype"]
+	assert.False(t, found)
+}
+
+func TestEventBatching(t *testing.T) {
+	collector := &fakeCollector{t: t}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	batch := &core.DataBatch{Timestamp: time.Unix(1500000000, 0), MetricSets: map[string]*core.MetricSet{}}
+	for i := 0; i < 100; i++ {
+		pod := fmt.Sprintf("pod-%d", i)
+		batch.MetricSets[core.PodKey("ns1", pod)] = &core.MetricSet{
+			Labels: map[string]string{
+				core.LabelMetricSetType.Key: core.MetricSetTypePod,
+				core.LabelPodName.Key:       pod,
+			},
+			MetricValues: map[string]core.MetricValue{
+				core.MetricMemoryUsage.Name: {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: int64(i)},
+			},
+		}
+	}
+
+	sink := newTestSink(t, server.URL+"?token=secret&maxPayloadBytes=1000")
+	sink.ExportData(batch)
+	require.NoError(t, sink.TakeExportError())
+
+	assert.True(t, len(collector.payloads) > 1, "%d payloads", len(collector.payloads))
+	received := 0
+	for i, payload := range collector.payloads {
+		assert.True(t, collector.sizes[i] <= 1000, "payload of %d bytes", collector.sizes[i])
+		received += len(payload)
+	}
+	assert.Equal(t, 100, received)
+
+	// A single event larger than the limit can not be sent.
+	sink = newTestSink(t, server.URL+"?token=secret&maxPayloadBytes=10")
+	sink.ExportData(batch)
+	assert.Error(t, sink.TakeExportError())
+}
+
+func TestFailedRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, `{"text":"Invalid token","code":4}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	sink := newTestSink(t, server.URL+"?token=wrong")
+	sink.ExportData(podBatch(time.Now()))
+	err := sink.TakeExportError()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, rawUri := range []string{
+		"https://splunk:8088",
+		"?token=secret",
+		"ftp://splunk:8088?token=secret",
+		"https://splunk:8088?token=secret&gzip=maybe",
+		"https://splunk:8088?token=secret&maxPayloadBytes=0",
+		"https://splunk:8088?token=secret&timeout=soon",
+	} {
+		uri, err := url.Parse(rawUri)
+		require.NoError(t, err)
+		_, err = NewSplunkSink(uri)
+		assert.Error(t, err, rawUri)
+	}
+}
@@ -55,6 +55,8 @@ type StackdriverSink struct {
 	initialDelaySec       int
 	useOldResourceModel   bool
 	useNewResourceModel   bool
+
+	core.ExportErrors
 }
 
 type metricMetadata struct {
@@ -222,6 +224,7 @@ forloop:
 			// yet another request added to queue
 		case <-timeoutSending:
 			glog.Warningf("Timeout while exporting metrics to Stackdriver. Dropping %d out of %d requests.", len(requests)-i, len(requests))
+			sink.RecordExportError(fmt.Errorf("timeout while exporting metrics, dropped %d out of %d requests", len(requests)-i, len(requests)))
 			// TODO(piosz): consider cancelling requests in flight
 			// Report dropped requests in metrics.
 			for _, req := range requests[i:] {
@@ -279,6 +282,7 @@ func (sink *StackdriverSink) sendOneRequest(req *monitoringpb.CreateTimeSeriesRe
 	var responseCode grpc_codes.Code
 	if err != nil {
 		glog.Warningf("Error while sending request to Stackdriver %v", err)
+		sink.RecordExportError(err)
 		// Convert request to json and log it, but only if logging level is equal to 2 or more.
 		if glog.V(2) {
 			marshalRequestAndLog(func(reqJson []byte) {
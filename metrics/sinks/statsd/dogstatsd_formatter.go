@@ -0,0 +1,83 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/golang/glog"
+	"k8s.io/heapster/metrics/core"
+	"sort"
+	"strings"
+)
+
+// DogstatsdFormatter formats metrics using the DogStatsD tag extension, i.e.
+// <METRIC>:<VALUE>|g[|#<KEY1>:<VAL1>,<KEY2>:<VAL2>...]
+type DogstatsdFormatter struct {
+	nameReplacer *strings.Replacer
+	tagReplacer  *strings.Replacer
+}
+
+func (formatter *DogstatsdFormatter) Format(prefix string, name string, labels map[string]string, customizeLabel CustomizeLabel, metricValue core.MetricValue) (res string, err error) {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("%s%s:%v|g", formatter.nameReplacer.Replace(prefix), formatter.nameReplacer.Replace(name), metricValue.GetValue()))
+
+	expandedLabels := formatter.expandUserLabels(labels)
+	keys := make([]string, 0, len(expandedLabels))
+	for k, v := range expandedLabels {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i == 0 {
+			buffer.WriteString("|#")
+		} else {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(fmt.Sprintf("%s:%s", customizeLabel(formatter.tagReplacer.Replace(k)), formatter.tagReplacer.Replace(expandedLabels[k])))
+	}
+
+	return buffer.String(), nil
+}
+
+func (formatter *DogstatsdFormatter) expandUserLabels(labels map[string]string) map[string]string {
+	res := make(map[string]string)
+	var userLabelStr string
+	for k, v := range labels {
+		if k == core.LabelLabels.Key {
+			userLabelStr = v
+		} else {
+			res[k] = v
+		}
+	}
+	kvPairs := strings.Split(userLabelStr, ",")
+	for _, kvPair := range kvPairs {
+		kv := strings.SplitN(kvPair, ":", 2)
+		if len(kv) == 2 {
+			res[kv[0]] = kv[1]
+		}
+	}
+	return res
+}
+
+func NewDogstatsdFormatter() Formatter {
+	glog.V(2).Info("dogstatsd formatter is created")
+	return &DogstatsdFormatter{
+		nameReplacer: strings.NewReplacer(":", "_", "|", "_", "@", "_"),
+		tagReplacer:  strings.NewReplacer(",", "_", "|", "_", "#", "_"),
+	}
+}
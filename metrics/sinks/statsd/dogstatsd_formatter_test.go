@@ -0,0 +1,56 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/heapster/metrics/core"
+	"testing"
+)
+
+var dogstatsdLabels = map[string]string{
+	"test_tag_1":         "value1",
+	"test_tag_2":         "value2",
+	"test_tag_3":         "",
+	core.LabelLabels.Key: "app:web,tier:front|end",
+}
+
+var dogstatsdMetricValue = core.MetricValue{
+	MetricType: core.MetricGauge,
+	ValueType:  core.ValueFloat,
+	FloatValue: 1.5,
+}
+
+func TestDogstatsdFormatWithoutLabels(t *testing.T) {
+	expectedMsg := "testprefix.cpu/usage_rate:1.5|g"
+
+	formatter := NewDogstatsdFormatter()
+	assert.NotNil(t, formatter)
+
+	msg, err := formatter.Format("testprefix.", "cpu/usage_rate", nil, DefaultLabelStyle, dogstatsdMetricValue)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedMsg, msg)
+}
+
+func TestDogstatsdFormatWithLabels(t *testing.T) {
+	expectedMsg := "testprefix.test_metric:1.5|g|#app:web,testTag1:value1,testTag2:value2,tier:front_end"
+
+	formatter := NewDogstatsdFormatter()
+	assert.NotNil(t, formatter)
+
+	msg, err := formatter.Format("testprefix.", "test:metric", dogstatsdLabels, SnakeToLowerCamel, dogstatsdMetricValue)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedMsg, msg)
+}
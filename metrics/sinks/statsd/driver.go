@@ -35,6 +35,7 @@ type statsdSink struct {
 	formatter Formatter
 	client    statsdClient
 	sync.RWMutex
+	core.ExportErrors
 }
 
 type statsdConfig struct {
@@ -72,6 +73,10 @@ func getConfig(uri *url.URL) (cfg statsdConfig, err error) {
 	if len(opts["protocolType"]) >= 1 {
 		config.protocolType = strings.ToLower(opts["protocolType"][0])
 	}
+	// `format` is an alias of `protocolType`.
+	if len(opts["format"]) >= 1 {
+		config.protocolType = strings.ToLower(opts["format"][0])
+	}
 	if len(opts["prefix"]) >= 1 {
 		config.prefix = opts["prefix"][0]
 	}
@@ -157,6 +162,7 @@ func (sink *statsdSink) ExportData(dataBatch *core.DataBatch) {
 	err = sink.client.send(metrics)
 	if err != nil {
 		glog.Errorf("statsd metrics sink - failed to send some metrics : %v", err)
+		sink.RecordExportError(err)
 	}
 }
 
@@ -116,3 +116,48 @@ func TestDriverExportData(t *testing.T) {
 		assert.Contains(t, res, expectedMsg)
 	}
 }
+
+func TestDriverExportDataDogstatsd(t *testing.T) {
+	url, err := url.Parse("udp://127.0.0.1:4125?format=dogstatsd&allowedLabels=namespace_name,pod_name,resource_id")
+	assert.NoError(t, err)
+
+	client := &dummyStatsdClientImpl{messages: nil}
+	sink, err := NewStatsdSinkWithClient(url, client)
+	assert.NoError(t, err)
+
+	dataBatch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): {
+				Labels: map[string]string{
+					core.LabelMetricSetType.Key: core.MetricSetTypePod,
+					core.LabelNamespaceName.Key: "ns1",
+					core.LabelPodName.Key:       "pod1",
+					core.LabelPodId.Key:         "uid1",
+				},
+				MetricValues: map[string]core.MetricValue{
+					core.MetricMemoryUsage.Name: {
+						ValueType:  core.ValueInt64,
+						MetricType: core.MetricGauge,
+						IntValue:   1024,
+					},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:   core.MetricFilesystemUsage.Name,
+						Labels: map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+						MetricValue: core.MetricValue{
+							ValueType:  core.ValueInt64,
+							MetricType: core.MetricGauge,
+							IntValue:   2048,
+						},
+					},
+				},
+			},
+		},
+	}
+	sink.ExportData(dataBatch)
+
+	assert.Contains(t, client.messages, "memory/usage:1024|g|#namespace_name:ns1,pod_name:pod1")
+	assert.Contains(t, client.messages, "filesystem/usage:2048|g|#namespace_name:ns1,pod_name:pod1,resource_id:/dev/sda1")
+}
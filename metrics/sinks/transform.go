@@ -0,0 +1,139 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+// MetricTransform converts a metric value to value*Scale+Offset. If Units is set,
+// the transformed metric is exported under the original name suffixed with "_<Units>".
+type MetricTransform struct {
+	Scale  float64
+	Offset float64
+	Units  string
+}
+
+// ParseMetricTransforms parses transforms of the form
+// <metric name>:scale=<float>[:offset=<float>][:units=<name>], keyed by the metric name.
+func ParseMetricTransforms(specs []string) (map[string]MetricTransform, error) {
+	result := make(map[string]MetricTransform, len(specs))
+	for _, spec := range specs {
+		parts := strings.Split(spec, ":")
+		name := parts[0]
+		if name == "" {
+			return nil, fmt.Errorf("missing metric name in transform %q", spec)
+		}
+		if _, found := result[name]; found {
+			return nil, fmt.Errorf("duplicate transform for metric %q", name)
+		}
+		transform := MetricTransform{Scale: 1}
+		for _, option := range parts[1:] {
+			kv := strings.SplitN(option, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid option %q in transform %q", option, spec)
+			}
+			var err error
+			switch kv[0] {
+			case "scale":
+				transform.Scale, err = strconv.ParseFloat(kv[1], 64)
+			case "offset":
+				transform.Offset, err = strconv.ParseFloat(kv[1], 64)
+			case "units":
+				transform.Units = kv[1]
+			default:
+				err = fmt.Errorf("unknown option %q", kv[0])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse transform %q - %v", spec, err)
+			}
+		}
+		result[name] = transform
+	}
+	return result, nil
+}
+
+func (this MetricTransform) apply(name string, value core.MetricValue) (string, core.MetricValue) {
+	var original float64
+	switch value.ValueType {
+	case core.ValueInt64:
+		original = float64(value.IntValue)
+	case core.ValueFloat:
+		original = float64(value.FloatValue)
+	default:
+		return name, value
+	}
+	if this.Units != "" {
+		name = name + "_" + this.Units
+	}
+	return name, core.MetricValue{
+		MetricType: value.MetricType,
+		ValueType:  core.ValueFloat,
+		FloatValue: float32(original*this.Scale + this.Offset),
+	}
+}
+
+// transformingSink applies the metric transforms to a copy of every batch before
+// passing it to the wrapped sink. The original batch is shared with other sinks and
+// must not be modified.
+type transformingSink struct {
+	core.DataSink
+	transforms map[string]MetricTransform
+}
+
+func NewTransformingSink(sink core.DataSink, transforms map[string]MetricTransform) core.DataSink {
+	return &transformingSink{
+		DataSink:   sink,
+		transforms: transforms,
+	}
+}
+
+func (this *transformingSink) ExportData(batch *core.DataBatch) {
+	transformed := &core.DataBatch{
+		Timestamp:  batch.Timestamp,
+		MetricSets: make(map[string]*core.MetricSet, len(batch.MetricSets)),
+	}
+	for key, metricSet := range batch.MetricSets {
+		newMetricSet := *metricSet
+		newMetricSet.MetricValues = make(map[string]core.MetricValue, len(metricSet.MetricValues))
+		for name, value := range metricSet.MetricValues {
+			if transform, found := this.transforms[name]; found {
+				name, value = transform.apply(name, value)
+			}
+			newMetricSet.MetricValues[name] = value
+		}
+		newMetricSet.LabeledMetrics = make([]core.LabeledMetric, 0, len(metricSet.LabeledMetrics))
+		for _, labeledMetric := range metricSet.LabeledMetrics {
+			if transform, found := this.transforms[labeledMetric.Name]; found {
+				labeledMetric.Name, labeledMetric.MetricValue = transform.apply(labeledMetric.Name, labeledMetric.MetricValue)
+			}
+			newMetricSet.LabeledMetrics = append(newMetricSet.LabeledMetrics, labeledMetric)
+		}
+		transformed.MetricSets[key] = &newMetricSet
+	}
+	this.DataSink.ExportData(transformed)
+}
+
+func (this *transformingSink) TakeExportError() error {
+	return core.TakeExportError(this.DataSink)
+}
+
+func (this *transformingSink) Historical() core.HistoricalSource {
+	return core.HistoricalSourceOf(this.DataSink)
+}
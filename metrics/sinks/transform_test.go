@@ -0,0 +1,116 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sinks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/heapster/metrics/core"
+)
+
+type recordingSink struct {
+	batches []*core.DataBatch
+}
+
+func (this *recordingSink) Name() string {
+	return "recording"
+}
+
+func (this *recordingSink) ExportData(batch *core.DataBatch) {
+	this.batches = append(this.batches, batch)
+}
+
+func (this *recordingSink) Stop() {}
+
+func TestParseMetricTransforms(t *testing.T) {
+	transforms, err := ParseMetricTransforms([]string{
+		"memory/usage:scale=0.000001:units=mb",
+		"cpu/usage_rate:scale=0.1:offset=-5",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]MetricTransform{
+		"memory/usage":   {Scale: 0.000001, Units: "mb"},
+		"cpu/usage_rate": {Scale: 0.1, Offset: -5},
+	}, transforms)
+
+	for _, spec := range []string{
+		":scale=2",
+		"memory/usage:scale=abc",
+		"memory/usage:scale",
+		"memory/usage:factor=2",
+	} {
+		_, err := ParseMetricTransforms([]string{spec})
+		assert.Error(t, err, spec)
+	}
+	_, err = ParseMetricTransforms([]string{"memory/usage:scale=2", "memory/usage:scale=3"})
+	assert.Error(t, err)
+}
+
+func TestTransformingSink(t *testing.T) {
+	transforms, err := ParseMetricTransforms([]string{
+		"memory/usage:scale=0.001:units=kb",
+		"cpu/usage_rate:scale=0.1:offset=5",
+		"filesystem/usage:scale=2",
+	})
+	assert.NoError(t, err)
+
+	key := core.PodKey("ns1", "pod1")
+	batch := &core.DataBatch{
+		Timestamp: time.Now(),
+		MetricSets: map[string]*core.MetricSet{
+			key: {
+				Labels: map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypePod},
+				MetricValues: map[string]core.MetricValue{
+					"memory/usage":   {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 2048},
+					"cpu/usage_rate": {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 500},
+					"memory/rss":     {ValueType: core.ValueInt64, MetricType: core.MetricGauge, IntValue: 1024},
+				},
+				LabeledMetrics: []core.LabeledMetric{
+					{
+						Name:        "filesystem/usage",
+						Labels:      map[string]string{core.LabelResourceID.Key: "/dev/sda1"},
+						MetricValue: core.MetricValue{ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 1.5},
+					},
+				},
+			},
+		},
+	}
+
+	recorder := &recordingSink{}
+	sink := NewTransformingSink(recorder, transforms)
+	assert.Equal(t, "recording", sink.Name())
+	sink.ExportData(batch)
+
+	assert.Equal(t, 1, len(recorder.batches))
+	metricSet := recorder.batches[0].MetricSets[key]
+
+	_, found := metricSet.MetricValues["memory/usage"]
+	assert.False(t, found)
+	assert.Equal(t, core.MetricValue{ValueType: core.ValueFloat, MetricType: core.MetricGauge, FloatValue: 2.048}, metricSet.MetricValues["memory/usage_kb"])
+	assert.Equal(t, float32(55), metricSet.MetricValues["cpu/usage_rate"].FloatValue)
+	assert.Equal(t, int64(1024), metricSet.MetricValues["memory/rss"].IntValue)
+	assert.Equal(t, "filesystem/usage", metricSet.LabeledMetrics[0].Name)
+	assert.Equal(t, float32(3), metricSet.LabeledMetrics[0].FloatValue)
+	assert.Equal(t, "/dev/sda1", metricSet.LabeledMetrics[0].Labels[core.LabelResourceID.Key])
+
+	// The original batch is left untouched.
+	original := batch.MetricSets[key]
+	assert.Equal(t, int64(2048), original.MetricValues["memory/usage"].IntValue)
+	assert.Equal(t, int64(500), original.MetricValues["cpu/usage_rate"].IntValue)
+	assert.Equal(t, float32(1.5), original.LabeledMetrics[0].FloatValue)
+}
@@ -41,6 +41,7 @@ type wavefrontSink struct {
 	IncludeContainers bool
 	testMode          bool
 	testReceivedLines []string
+	core.ExportErrors
 }
 
 func (wfSink *wavefrontSink) Name() string {
@@ -207,6 +208,7 @@ func (wfSink *wavefrontSink) ExportData(batch *core.DataBatch) {
 	err := wfSink.connect()
 	if err != nil {
 		glog.Warning(err)
+		wfSink.RecordExportError(err)
 	}
 
 	if wfSink.Conn != nil && err == nil {
@@ -0,0 +1,249 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/heapster/metrics/core"
+)
+
+const (
+	defaultBatchSize = 1000
+	defaultTimeout   = 30 * time.Second
+)
+
+// Payload posted to the webhook.
+type webhookBatch struct {
+	Timestamp  time.Time          `json:"timestamp"`
+	MetricSets []webhookMetricSet `json:"metricSets"`
+}
+
+type webhookMetricSet struct {
+	Labels         map[string]string      `json:"labels"`
+	Metrics        map[string]interface{} `json:"metrics"`
+	LabeledMetrics []webhookLabeledMetric `json:"labeledMetrics,omitempty"`
+}
+
+type webhookLabeledMetric struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Value  interface{}       `json:"value"`
+}
+
+// urlTemplate is a webhook URL whose path may contain {<label>} placeholders,
+// filled in with the labels of every metric set.
+type urlTemplate struct {
+	prefix string
+	// Escaped literal parts of the path, interleaved with the placeholders.
+	parts  []string
+	labels []string
+	suffix string
+}
+
+func parseURLTemplate(uri *url.URL) (*urlTemplate, error) {
+	template := &urlTemplate{
+		prefix: uri.Scheme + "://" + uri.Host,
+	}
+	if uri.RawQuery != "" {
+		template.suffix = "?" + uri.RawQuery
+	}
+	path := uri.Path
+	for {
+		start := strings.Index(path, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(path[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated placeholder in %q", uri.Path)
+		}
+		label := path[start+1 : start+end]
+		if label == "" {
+			return nil, fmt.Errorf("empty placeholder in %q", uri.Path)
+		}
+		template.parts = append(template.parts, escapePath(path[:start]))
+		template.labels = append(template.labels, label)
+		path = path[start+end+1:]
+	}
+	template.parts = append(template.parts, escapePath(path))
+	return template, nil
+}
+
+func escapePath(path string) string {
+	return (&url.URL{Path: path}).EscapedPath()
+}
+
+// render returns the URL for the given labels, or false if one of the placeholder labels is missing.
+func (t *urlTemplate) render(labels map[string]string) (string, bool) {
+	var buffer bytes.Buffer
+	buffer.WriteString(t.prefix)
+	for i, label := range t.labels {
+		value := labels[label]
+		if value == "" {
+			return "", false
+		}
+		buffer.WriteString(t.parts[i])
+		buffer.WriteString(url.PathEscape(value))
+	}
+	buffer.WriteString(t.parts[len(t.parts)-1])
+	buffer.WriteString(t.suffix)
+	return buffer.String(), true
+}
+
+type webhookSink struct {
+	sync.Mutex
+	template  *urlTemplate
+	batchSize int
+	client    *http.Client
+	core.ExportErrors
+}
+
+func (sink *webhookSink) Name() string {
+	return "Webhook Sink"
+}
+
+func (sink *webhookSink) Stop() {
+	// Do nothing.
+}
+
+func (sink *webhookSink) ExportData(dataBatch *core.DataBatch) {
+	sink.Lock()
+	defer sink.Unlock()
+
+	// Metric sets are grouped by the rendered URL, so every tenant gets its own requests.
+	grouped := make(map[string][]webhookMetricSet)
+	for key, metricSet := range dataBatch.MetricSets {
+		target, ok := sink.template.render(metricSet.Labels)
+		if !ok {
+			glog.V(4).Infof("Skipping metric set %s - missing labels for the webhook URL", key)
+			continue
+		}
+		grouped[target] = append(grouped[target], toWebhookMetricSet(metricSet))
+	}
+
+	targets := make([]string, 0, len(grouped))
+	for target := range grouped {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		metricSets := grouped[target]
+		for start := 0; start < len(metricSets); start += sink.batchSize {
+			end := start + sink.batchSize
+			if end > len(metricSets) {
+				end = len(metricSets)
+			}
+			payload := &webhookBatch{
+				Timestamp:  dataBatch.Timestamp,
+				MetricSets: metricSets[start:end],
+			}
+			if err := sink.send(target, payload); err != nil {
+				glog.Errorf("Failed to send metrics to webhook %s: %v", target, err)
+				sink.RecordExportError(err)
+			}
+		}
+	}
+}
+
+func toWebhookMetricSet(metricSet *core.MetricSet) webhookMetricSet {
+	result := webhookMetricSet{
+		Labels:  metricSet.Labels,
+		Metrics: make(map[string]interface{}, len(metricSet.MetricValues)),
+	}
+	for name, value := range metricSet.MetricValues {
+		result.Metrics[name] = value.GetValue()
+	}
+	for _, labeledMetric := range metricSet.LabeledMetrics {
+		result.LabeledMetrics = append(result.LabeledMetrics, webhookLabeledMetric{
+			Name:   labeledMetric.Name,
+			Labels: labeledMetric.Labels,
+			Value:  labeledMetric.GetValue(),
+		})
+	}
+	return result
+}
+
+func (sink *webhookSink) send(target string, payload *webhookBatch) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := sink.client.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+func NewWebhookSink(uri *url.URL) (core.DataSink, error) {
+	if uri.Scheme != "http" && uri.Scheme != "https" {
+		return nil, errors.New("webhook URL has to use http or https scheme")
+	}
+
+	// The sink options are removed from the query, the rest is passed to the webhook.
+	target := *uri
+	opts := target.Query()
+	batchSize := defaultBatchSize
+	if len(opts["batchSize"]) >= 1 {
+		var err error
+		batchSize, err = strconv.Atoi(opts["batchSize"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `batchSize` flag - %v", err)
+		}
+		if batchSize <= 0 {
+			return nil, errors.New("`batchSize` flag can only be positive")
+		}
+	}
+	timeout := defaultTimeout
+	if len(opts["timeout"]) >= 1 {
+		var err error
+		timeout, err = time.ParseDuration(opts["timeout"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `timeout` flag - %v", err)
+		}
+	}
+	opts.Del("batchSize")
+	opts.Del("timeout")
+	target.RawQuery = opts.Encode()
+
+	template, err := parseURLTemplate(&target)
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof("created webhook sink with URL %s%s and batch size %d", target.Host, target.Path, batchSize)
+	return &webhookSink{
+		template:  template,
+		batchSize: batchSize,
+		client:    &http.Client{Timeout: timeout},
+	}, nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/heapster/metrics/core"
+)
+
+func TestURLTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		template string
+		labels   map[string]string
+		expected string
+		ok       bool
+	}{
+		{
+			template: "https://collector/metrics",
+			labels:   map[string]string{},
+			expected: "https://collector/metrics",
+			ok:       true,
+		},
+		{
+			template: "https://collector/{namespace_name}/metrics?token=abc",
+			labels:   map[string]string{core.LabelNamespaceName.Key: "ns1"},
+			expected: "https://collector/ns1/metrics?token=abc",
+			ok:       true,
+		},
+		{
+			template: "https://collector:8443/{type}/{namespace_name}",
+			labels:   map[string]string{core.LabelMetricSetType.Key: "pod", core.LabelNamespaceName.Key: "a b/c"},
+			expected: "https://collector:8443/pod/a%20b%2Fc",
+			ok:       true,
+		},
+		{
+			template: "https://collector/{namespace_name}",
+			labels:   map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeCluster},
+			ok:       false,
+		},
+	} {
+		uri, err := url.Parse(tc.template)
+		assert.NoError(t, err)
+		template, err := parseURLTemplate(uri)
+		if !assert.NoError(t, err, tc.template) {
+			continue
+		}
+		rendered, ok := template.render(tc.labels)
+		assert.Equal(t, tc.ok, ok, tc.template)
+		assert.Equal(t, tc.expected, rendered, tc.template)
+	}
+
+	for _, invalid := range []string{"https://collector/{namespace_name", "https://collector/{}"} {
+		uri, err := url.Parse(invalid)
+		assert.NoError(t, err)
+		_, err = parseURLTemplate(uri)
+		assert.Error(t, err, invalid)
+	}
+}
+
+type receivedBatch struct {
+	path    string
+	query   string
+	payload webhookBatch
+}
+
+func podMetricSet(namespace, pod string) *core.MetricSet {
+	return &core.MetricSet{
+		Labels: map[string]string{
+			core.LabelMetricSetType.Key: core.MetricSetTypePod,
+			core.LabelNamespaceName.Key: namespace,
+			core.LabelPodName.Key:       pod,
+		},
+		MetricValues: map[string]core.MetricValue{
+			core.MetricMemoryUsage.Name: {
+				ValueType:  core.ValueInt64,
+				MetricType: core.MetricGauge,
+				IntValue:   1024,
+			},
+		},
+	}
+}
+
+func TestExportDataPerURLBatching(t *testing.T) {
+	var lock sync.Mutex
+	var received []receivedBatch
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		var payload webhookBatch
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		lock.Lock()
+		defer lock.Unlock()
+		received = append(received, receivedBatch{path: r.URL.Path, query: r.URL.RawQuery, payload: payload})
+	}))
+	defer server.Close()
+
+	uri, err := url.Parse(server.URL + "/tenants/{namespace_name}?batchSize=2&token=abc")
+	assert.NoError(t, err)
+	sink, err := NewWebhookSink(uri)
+	assert.NoError(t, err)
+
+	timestamp := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	sink.ExportData(&core.DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*core.MetricSet{
+			core.PodKey("ns1", "pod1"): podMetricSet("ns1", "pod1"),
+			core.PodKey("ns1", "pod2"): podMetricSet("ns1", "pod2"),
+			core.PodKey("ns1", "pod3"): podMetricSet("ns1", "pod3"),
+			core.PodKey("ns2", "pod4"): podMetricSet("ns2", "pod4"),
+			// No namespace, so no tenant to send it to.
+			core.ClusterKey(): {
+				Labels:       map[string]string{core.LabelMetricSetType.Key: core.MetricSetTypeCluster},
+				MetricValues: map[string]core.MetricValue{},
+			},
+		},
+	})
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 3, len(received))
+	sizes := make(map[string][]int)
+	for _, batch := range received {
+		assert.Equal(t, "token=abc", batch.query)
+		assert.True(t, batch.payload.Timestamp.Equal(timestamp))
+		for _, metricSet := range batch.payload.MetricSets {
+			assert.Equal(t, "/tenants/"+metricSet.Labels[core.LabelNamespaceName.Key], batch.path)
+			assert.Equal(t, float64(1024), metricSet.Metrics[core.MetricMemoryUsage.Name])
+		}
+		sizes[batch.path] = append(sizes[batch.path], len(batch.payload.MetricSets))
+	}
+	sort.Ints(sizes["/tenants/ns1"])
+	assert.Equal(t, []int{1, 2}, sizes["/tenants/ns1"])
+	assert.Equal(t, []int{1}, sizes["/tenants/ns2"])
+}
+
+func TestNewWebhookSinkInvalidOptions(t *testing.T) {
+	for _, rawUri := range []string{
+		"ftp://collector/metrics",
+		"https://collector/metrics?batchSize=0",
+		"https://collector/metrics?timeout=abc",
+	} {
+		uri, err := url.Parse(rawUri)
+		assert.NoError(t, err)
+		_, err = NewWebhookSink(uri)
+		assert.Error(t, err, rawUri)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apiserver implements a source scraping a few control-plane health metrics
+// from the Prometheus metrics endpoint of the API server.
+package apiserver
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	kube_rest "k8s.io/client-go/rest"
+	kube_config "k8s.io/heapster/common/kubernetes"
+	. "k8s.io/heapster/metrics/core"
+)
+
+const requestTimeout = 10 * time.Second
+
+// Prometheus metric families read from the API server. Where the name changed between
+// Kubernetes versions, the families are listed from the newest to the oldest name.
+var (
+	requestCountFamilies     = []string{"apiserver_request_total", "apiserver_request_count"}
+	inflightRequestsFamilies = []string{"apiserver_current_inflight_requests"}
+	etcdObjectCountFamilies  = []string{"apiserver_storage_objects", "etcd_object_counts"}
+	processStartTimeFamilies = []string{"process_start_time_seconds"}
+)
+
+type apiServerMetricsSource struct {
+	client *http.Client
+	url    string
+}
+
+func NewApiServerMetricsSource(client *http.Client, url string) MetricsSource {
+	return &apiServerMetricsSource{
+		client: client,
+		url:    url,
+	}
+}
+
+func (this *apiServerMetricsSource) Name() string {
+	return this.String()
+}
+
+func (this *apiServerMetricsSource) String() string {
+	return fmt.Sprintf("apiserver:%s", this.url)
+}
+
+func (this *apiServerMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatch, error) {
+	response, err := this.client.Get(this.url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, fmt.Errorf("request to %s failed - %q, response: %q", this.url, response.Status, string(body))
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the metrics of %s - %v", this.url, err)
+	}
+	metricSet := decodeMetrics(families)
+	metricSet.ScrapeTime = time.Now()
+	glog.V(2).Infof("successfully obtained %d metrics from %s", len(metricSet.MetricValues), this.url)
+	return &DataBatch{
+		Timestamp: end,
+		MetricSets: map[string]*MetricSet{
+			ClusterKey(): metricSet,
+		},
+	}, nil
+}
+
+// decodeMetrics returns a cluster metric set holding the API server metrics found
+// in the given metric families.
+func decodeMetrics(families map[string]*dto.MetricFamily) *MetricSet {
+	metricSet := &MetricSet{
+		MetricValues: map[string]MetricValue{},
+		Labels: map[string]string{
+			LabelMetricSetType.Key: MetricSetTypeCluster,
+		},
+	}
+	if family := findFamily(families, processStartTimeFamilies); family != nil && len(family.Metric) > 0 {
+		startTime := value(family.Metric[0])
+		metricSet.CollectionStartTime = time.Unix(int64(startTime), 0)
+	}
+	if family := findFamily(families, requestCountFamilies); family != nil {
+		var total, errors float64
+		for _, metric := range family.Metric {
+			total += value(metric)
+			if strings.HasPrefix(label(metric, "code"), "5") {
+				errors += value(metric)
+			}
+		}
+		setInt(metricSet, MetricApiServerRequestCount, total)
+		setInt(metricSet, MetricApiServerRequestErrorCount, errors)
+	}
+	if family := findFamily(families, inflightRequestsFamilies); family != nil {
+		setInt(metricSet, MetricApiServerInflightRequests, sum(family))
+	}
+	if family := findFamily(families, etcdObjectCountFamilies); family != nil {
+		setInt(metricSet, MetricEtcdObjectCount, sum(family))
+	}
+	return metricSet
+}
+
+func findFamily(families map[string]*dto.MetricFamily, names []string) *dto.MetricFamily {
+	for _, name := range names {
+		if family, found := families[name]; found {
+			return family
+		}
+	}
+	return nil
+}
+
+func value(metric *dto.Metric) float64 {
+	switch {
+	case metric.Counter != nil:
+		return metric.Counter.GetValue()
+	case metric.Gauge != nil:
+		return metric.Gauge.GetValue()
+	case metric.Untyped != nil:
+		return metric.Untyped.GetValue()
+	}
+	return 0
+}
+
+func label(metric *dto.Metric, name string) string {
+	for _, pair := range metric.Label {
+		if pair.GetName() == name {
+			return pair.GetValue()
+		}
+	}
+	return ""
+}
+
+func sum(family *dto.MetricFamily) float64 {
+	var total float64
+	for _, metric := range family.Metric {
+		total += value(metric)
+	}
+	return total
+}
+
+func setInt(metricSet *MetricSet, metric Metric, count float64) {
+	metricSet.MetricValues[metric.MetricDescriptor.Name] = MetricValue{
+		ValueType:  ValueInt64,
+		MetricType: metric.MetricDescriptor.Type,
+		IntValue:   int64(count),
+	}
+}
+
+// apiServerSourceProvider adds the API server source to the sources of the wrapped provider.
+type apiServerSourceProvider struct {
+	MetricsSourceProvider
+	source MetricsSource
+}
+
+func (this *apiServerSourceProvider) GetMetricsSources() []MetricsSource {
+	return append(this.MetricsSourceProvider.GetMetricsSources(), this.source)
+}
+
+// WithApiServerSource adds the API server source to the given provider if enabled by the
+// `apiServerMetrics` option of the kubernetes source. The API server is reached with the
+// same configuration as the one used to list the nodes.
+func WithApiServerSource(provider MetricsSourceProvider, uri *url.URL) (MetricsSourceProvider, error) {
+	enabled := false
+	if opts := uri.Query(); len(opts["apiServerMetrics"]) >= 1 {
+		var err error
+		enabled, err = strconv.ParseBool(opts["apiServerMetrics"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `apiServerMetrics` flag - %v", err)
+		}
+	}
+	if !enabled {
+		return provider, nil
+	}
+
+	kubeConfig, err := kube_config.GetKubeClientConfig(uri)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := kube_rest.TransportFor(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	host := strings.TrimSuffix(kubeConfig.Host, "/")
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   requestTimeout,
+	}
+	return &apiServerSourceProvider{
+		MetricsSourceProvider: provider,
+		source:                NewApiServerMetricsSource(client, host+"/metrics"),
+	}, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/heapster/metrics/core"
+)
+
+const sampleMetrics = `# HELP apiserver_current_inflight_requests Maximal number of currently used inflight request limit of this apiserver per request kind in last second.
+# TYPE apiserver_current_inflight_requests gauge
+apiserver_current_inflight_requests{requestKind="mutating"} 3
+apiserver_current_inflight_requests{requestKind="readOnly"} 12
+# HELP apiserver_request_count Counter of apiserver requests broken out for each verb, API resource, client, and HTTP response contentType and code.
+# TYPE apiserver_request_count counter
+apiserver_request_count{client="kubectl",code="200",contentType="application/json",resource="pods",verb="LIST"} 1500
+apiserver_request_count{client="kubelet",code="201",contentType="application/json",resource="events",verb="POST"} 480
+apiserver_request_count{client="kubelet",code="500",contentType="application/json",resource="nodes",verb="PATCH"} 7
+apiserver_request_count{client="heapster",code="503",contentType="application/json",resource="nodes",verb="LIST"} 3
+apiserver_request_count{client="kubectl",code="404",contentType="application/json",resource="pods",verb="GET"} 10
+# HELP apiserver_request_latencies Response latency distribution in microseconds for each verb, resource and subresource.
+# TYPE apiserver_request_latencies histogram
+apiserver_request_latencies_bucket{resource="pods",verb="LIST",le="125000"} 1400
+apiserver_request_latencies_bucket{resource="pods",verb="LIST",le="+Inf"} 1500
+apiserver_request_latencies_sum{resource="pods",verb="LIST"} 4.2e+07
+apiserver_request_latencies_count{resource="pods",verb="LIST"} 1500
+# HELP etcd_object_counts Number of stored objects at the time of last check split by kind.
+# TYPE etcd_object_counts gauge
+etcd_object_counts{resource="pods"} 120
+etcd_object_counts{resource="nodes"} 5
+etcd_object_counts{resource="events"} 875
+# HELP process_start_time_seconds Start time of the process since unix epoch in seconds.
+# TYPE process_start_time_seconds gauge
+process_start_time_seconds 1.50691206e+09
+`
+
+func TestScrapeMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/metrics", r.URL.Path)
+		w.Write([]byte(sampleMetrics))
+	}))
+	defer server.Close()
+
+	source := NewApiServerMetricsSource(http.DefaultClient, server.URL+"/metrics")
+	end := time.Now()
+	batch, err := source.ScrapeMetrics(end.Add(-time.Minute), end)
+	require.NoError(t, err)
+	assert.Equal(t, end, batch.Timestamp)
+	require.Equal(t, 1, len(batch.MetricSets))
+
+	cluster := batch.MetricSets[core.ClusterKey()]
+	require.NotNil(t, cluster)
+	assert.Equal(t, core.MetricSetTypeCluster, cluster.Labels[core.LabelMetricSetType.Key])
+	assert.Equal(t, time.Unix(1506912060, 0), cluster.CollectionStartTime)
+	assert.Equal(t, 4, len(cluster.MetricValues))
+
+	requests := cluster.MetricValues[core.MetricApiServerRequestCount.Name]
+	assert.Equal(t, core.MetricCumulative, requests.MetricType)
+	assert.Equal(t, int64(2000), requests.IntValue)
+	assert.Equal(t, int64(10), cluster.MetricValues[core.MetricApiServerRequestErrorCount.Name].IntValue)
+	inflight := cluster.MetricValues[core.MetricApiServerInflightRequests.Name]
+	assert.Equal(t, core.MetricGauge, inflight.MetricType)
+	assert.Equal(t, int64(15), inflight.IntValue)
+	assert.Equal(t, int64(1000), cluster.MetricValues[core.MetricEtcdObjectCount.Name].IntValue)
+}
+
+func TestScrapeMetricsNewNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`# TYPE apiserver_request_total counter
+apiserver_request_total{code="200",resource="pods",verb="LIST"} 40
+apiserver_request_total{code="504",resource="pods",verb="LIST"} 2
+# TYPE apiserver_storage_objects gauge
+apiserver_storage_objects{resource="pods"} 30
+`))
+	}))
+	defer server.Close()
+
+	batch, err := NewApiServerMetricsSource(http.DefaultClient, server.URL+"/metrics").ScrapeMetrics(time.Now(), time.Now())
+	require.NoError(t, err)
+	cluster := batch.MetricSets[core.ClusterKey()]
+	assert.Equal(t, 3, len(cluster.MetricValues))
+	assert.Equal(t, int64(42), cluster.MetricValues[core.MetricApiServerRequestCount.Name].IntValue)
+	assert.Equal(t, int64(2), cluster.MetricValues[core.MetricApiServerRequestErrorCount.Name].IntValue)
+	assert.Equal(t, int64(30), cluster.MetricValues[core.MetricEtcdObjectCount.Name].IntValue)
+}
+
+func TestScrapeMetricsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := NewApiServerMetricsSource(http.DefaultClient, server.URL+"/metrics").ScrapeMetrics(time.Now(), time.Now())
+	assert.Error(t, err)
+}
+
+type fakeProvider struct {
+	sources []core.MetricsSource
+}
+
+func (this *fakeProvider) GetMetricsSources() []core.MetricsSource {
+	return this.sources
+}
+
+func TestWithApiServerSource(t *testing.T) {
+	provider := &fakeProvider{}
+	for _, rawUri := range []string{
+		"https://kubernetes.default",
+		"https://kubernetes.default?apiServerMetrics=false",
+	} {
+		uri, err := url.Parse(rawUri)
+		require.NoError(t, err)
+		result, err := WithApiServerSource(provider, uri)
+		assert.NoError(t, err)
+		assert.Equal(t, provider, result)
+	}
+
+	uri, err := url.Parse("https://kubernetes.default?apiServerMetrics=yes")
+	require.NoError(t, err)
+	_, err = WithApiServerSource(provider, uri)
+	assert.Error(t, err)
+
+	uri, err = url.Parse("https://kubernetes.default?inClusterConfig=false&apiServerMetrics=true")
+	require.NoError(t, err)
+	result, err := WithApiServerSource(provider, uri)
+	require.NoError(t, err)
+	sources := result.GetMetricsSources()
+	require.Equal(t, 1, len(sources))
+	assert.Equal(t, "apiserver:https://kubernetes.default/metrics", sources[0].Name())
+}
@@ -19,6 +19,7 @@ import (
 
 	"k8s.io/heapster/common/flags"
 	"k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/sources/apiserver"
 	"k8s.io/heapster/metrics/sources/kubelet"
 	"k8s.io/heapster/metrics/sources/summary"
 )
@@ -30,10 +31,16 @@ func (this *SourceFactory) Build(uri flags.Uri) (core.MetricsSourceProvider, err
 	switch uri.Key {
 	case "kubernetes":
 		provider, err := kubelet.NewKubeletProvider(&uri.Val)
-		return provider, err
+		if err != nil {
+			return nil, err
+		}
+		return apiserver.WithApiServerSource(provider, &uri.Val)
 	case "kubernetes.summary_api":
 		provider, err := summary.NewSummaryProvider(&uri.Val)
-		return provider, err
+		if err != nil {
+			return nil, err
+		}
+		return apiserver.WithApiServerSource(provider, &uri.Val)
 	default:
 		return nil, fmt.Errorf("Source not recognized: %s", uri.Key)
 	}
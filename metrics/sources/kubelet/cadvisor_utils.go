@@ -15,9 +15,139 @@
 package kubelet
 
 import (
+	"regexp"
+
+	"github.com/golang/glog"
 	cadvisor "github.com/google/cadvisor/info/v1"
+	"k8s.io/heapster/metrics/core"
 )
 
+// Filesystem type reported by cadvisor for the devicemapper thin pool holding the Docker images.
+const devicemapperFsType = "devicemapper"
+
 func isNode(c *cadvisor.ContainerInfo) bool {
 	return c.Name == "/"
 }
+
+// decodeNodeFilesystems splits the filesystems of the node container into the root filesystem and
+// the one holding the container images. cadvisor does not report the mount points, so the root
+// filesystem is recognized by its device matching rootFsDevice, or as the only filesystem not of the
+// devicemapper type if rootFsDevice is nil, and the image filesystem by its devicemapper type, or as
+// the root filesystem if it is the only filesystem of the node. The metrics of the filesystems that
+// can not be identified are not reported.
+func decodeNodeFilesystems(stat *cadvisor.ContainerStats, metrics *core.MetricSet, rootFsDevice *regexp.Regexp, nodename string) {
+	var rootFs, imageFs *cadvisor.FsStats
+	var unclassified []*cadvisor.FsStats
+	for i := range stat.Filesystem {
+		fs := &stat.Filesystem[i]
+		switch {
+		case fs.Type == devicemapperFsType:
+			imageFs = fs
+		case rootFsDevice != nil && rootFs == nil && rootFsDevice.MatchString(fs.Device):
+			rootFs = fs
+		default:
+			unclassified = append(unclassified, fs)
+		}
+	}
+	if rootFsDevice == nil && len(unclassified) == 1 {
+		rootFs = unclassified[0]
+		unclassified = nil
+	}
+	if imageFs == nil && rootFs != nil && len(stat.Filesystem) == 1 {
+		imageFs = rootFs
+	}
+	for _, fs := range unclassified {
+		glog.Warningf("Unable to tell whether filesystem %v of node %s is the root or the image filesystem, set the rootFsDevice option of the source to the root devices", fs.Device, nodename)
+	}
+
+	if rootFs != nil {
+		metrics.MetricValues[core.MetricNodeFsUsage.Name] = bytesValue(rootFs.Usage)
+		metrics.MetricValues[core.MetricNodeFsLimit.Name] = bytesValue(rootFs.Limit)
+	}
+	if imageFs != nil {
+		metrics.MetricValues[core.MetricNodeImageFsUsage.Name] = bytesValue(imageFs.Usage)
+		metrics.MetricValues[core.MetricNodeImageFsLimit.Name] = bytesValue(imageFs.Limit)
+	}
+}
+
+func bytesValue(value uint64) core.MetricValue {
+	return core.MetricValue{
+		ValueType:  core.ValueInt64,
+		MetricType: core.MetricGauge,
+		IntValue:   int64(value),
+	}
+}
+
+// decodeTcpMetrics adds the TCP connections of the pod network, as seen by the infra container,
+// to pod metric sets. The stats are all zero if cadvisor does not collect them.
+func decodeTcpMetrics(stat *cadvisor.ContainerStats, metrics *core.MetricSet) {
+	if metrics.Labels[core.LabelMetricSetType.Key] != core.MetricSetTypePod {
+		return
+	}
+	connections := tcpConnections(stat.Network.Tcp) + tcpConnections(stat.Network.Tcp6)
+	metrics.MetricValues[core.MetricPodNetworkTcpConnections.Name] = core.MetricValue{
+		ValueType:  core.ValueInt64,
+		MetricType: core.MetricGauge,
+		IntValue:   int64(connections),
+	}
+}
+
+// tcpConnections counts the connections in every state but listening.
+func tcpConnections(tcp cadvisor.TcpStat) uint64 {
+	return tcp.Established + tcp.SynSent + tcp.SynRecv + tcp.FinWait1 + tcp.FinWait2 +
+		tcp.TimeWait + tcp.Close + tcp.CloseWait + tcp.LastAck + tcp.Closing
+}
+
+// decodeExtraMetrics adds the metrics decoded from the statistics missing from the
+// cadvisor v1 API types to container metric sets.
+func decodeExtraMetrics(metrics *core.MetricSet, extras *ContainerExtras) {
+	if extras.Schedstat != nil {
+		decodeSchedstatMetrics(metrics, extras.Schedstat)
+	}
+	metricSetType := metrics.Labels[core.LabelMetricSetType.Key]
+	if metricSetType != core.MetricSetTypePodContainer && metricSetType != core.MetricSetTypeSystemContainer {
+		return
+	}
+	if extras.CpuInstUsage != nil {
+		metrics.MetricValues[core.MetricContainerCpuInstantUsageRate.Name] = core.MetricValue{
+			ValueType:  core.ValueInt64,
+			MetricType: core.MetricGauge,
+			// Nanocores to millicores.
+			IntValue: int64(*extras.CpuInstUsage / 1000000),
+		}
+	}
+	if extras.InodeUsage != nil {
+		metrics.MetricValues[core.MetricContainerInodesUsed.Name] = core.MetricValue{
+			ValueType:  core.ValueInt64,
+			MetricType: core.MetricGauge,
+			IntValue:   int64(*extras.InodeUsage),
+		}
+	}
+}
+
+// decodeCpuThrottlingMetrics adds the number of CFS periods in which a container was
+// throttled to container metric sets. It stays zero for containers without a CPU limit.
+func decodeCpuThrottlingMetrics(stat *cadvisor.ContainerStats, metrics *core.MetricSet) {
+	metricSetType := metrics.Labels[core.LabelMetricSetType.Key]
+	if metricSetType != core.MetricSetTypePodContainer && metricSetType != core.MetricSetTypeSystemContainer {
+		return
+	}
+	metrics.MetricValues[core.MetricContainerCpuThrottledPeriods.Name] = core.MetricValue{
+		ValueType:  core.ValueInt64,
+		MetricType: core.MetricCumulative,
+		IntValue:   int64(stat.Cpu.CFS.ThrottledPeriods),
+	}
+}
+
+// decodeSchedstatMetrics adds the CPU run queue wait time to container metric sets.
+func decodeSchedstatMetrics(metrics *core.MetricSet, schedstat *CpuSchedstat) {
+	metricSetType := metrics.Labels[core.LabelMetricSetType.Key]
+	if metricSetType != core.MetricSetTypePodContainer && metricSetType != core.MetricSetTypeSystemContainer {
+		return
+	}
+	metrics.MetricValues[core.MetricContainerCpuWaitTime.Name] = core.MetricValue{
+		ValueType:  core.ValueInt64,
+		MetricType: core.MetricCumulative,
+		IntValue:   int64(schedstat.RunqueueTime),
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"time"
+
+	cadvisor "github.com/google/cadvisor/info/v1"
+)
+
+// The containers of the v2 cadvisor API are decoded into the subset of its schema defined
+// below, as the v2 API types are not vendored. The statistics it shares with the v1 API
+// reuse the v1 types, like cadvisor does.
+
+type v2ContainerInfo struct {
+	Spec  v2ContainerSpec     `json:"spec,omitempty"`
+	Stats []*v2ContainerStats `json:"stats,omitempty"`
+}
+
+type v2ContainerSpec struct {
+	CreationTime     time.Time             `json:"creation_time,omitempty"`
+	Aliases          []string              `json:"aliases,omitempty"`
+	Namespace        string                `json:"namespace,omitempty"`
+	Labels           map[string]string     `json:"labels,omitempty"`
+	Envs             map[string]string     `json:"envs,omitempty"`
+	HasCpu           bool                  `json:"has_cpu"`
+	Cpu              cadvisor.CpuSpec      `json:"cpu,omitempty"`
+	HasMemory        bool                  `json:"has_memory"`
+	Memory           cadvisor.MemorySpec   `json:"memory,omitempty"`
+	HasCustomMetrics bool                  `json:"has_custom_metrics"`
+	CustomMetrics    []cadvisor.MetricSpec `json:"custom_metrics,omitempty"`
+	HasNetwork       bool                  `json:"has_network"`
+	HasFilesystem    bool                  `json:"has_filesystem"`
+	HasDiskIo        bool                  `json:"has_diskio"`
+	Image            string                `json:"image,omitempty"`
+}
+
+type v2ContainerStats struct {
+	Timestamp     time.Time                       `json:"timestamp"`
+	Cpu           *cadvisor.CpuStats              `json:"cpu,omitempty"`
+	CpuInst       *v2CpuInstStats                 `json:"cpu_inst,omitempty"`
+	DiskIo        *cadvisor.DiskIoStats           `json:"diskio,omitempty"`
+	Memory        *cadvisor.MemoryStats           `json:"memory,omitempty"`
+	Network       *v2NetworkStats                 `json:"network,omitempty"`
+	Filesystem    *v2FilesystemStats              `json:"filesystem,omitempty"`
+	Load          *cadvisor.LoadStats             `json:"load_stats,omitempty"`
+	CustomMetrics map[string][]cadvisor.MetricVal `json:"custom_metrics,omitempty"`
+	Accelerators  []cadvisor.AcceleratorStats     `json:"accelerators,omitempty"`
+}
+
+// v2CpuInstStats is the CPU usage rate computed by cadvisor between its last two samples.
+type v2CpuInstStats struct {
+	Usage struct {
+		// Usage rate on all cores in nanocores.
+		Total uint64 `json:"total"`
+	} `json:"usage"`
+}
+
+type v2NetworkStats struct {
+	Interfaces []cadvisor.InterfaceStats `json:"interfaces,omitempty"`
+	Tcp        cadvisor.TcpStat          `json:"tcp"`
+	Tcp6       cadvisor.TcpStat          `json:"tcp6"`
+}
+
+type v2FilesystemStats struct {
+	TotalUsageBytes *uint64 `json:"totalUsageBytes,omitempty"`
+	BaseUsageBytes  *uint64 `json:"baseUsageBytes,omitempty"`
+	// Misspelled in the cadvisor API.
+	InodeUsage *uint64 `json:"containter_inode_usage,omitempty"`
+}
+
+// toV1 converts the container reported under the given name by the v2 API into the v1
+// container info decoded by the source, and returns the statistics of its latest sample
+// that are only reported by the v2 API.
+func (info *v2ContainerInfo) toV1(name string) (*cadvisor.ContainerInfo, *ContainerExtras) {
+	spec := info.Spec
+	containerInfo := &cadvisor.ContainerInfo{
+		ContainerReference: cadvisor.ContainerReference{
+			Name:      name,
+			Aliases:   spec.Aliases,
+			Namespace: spec.Namespace,
+		},
+		Spec: cadvisor.ContainerSpec{
+			CreationTime: spec.CreationTime,
+			Labels:       spec.Labels,
+			Envs:         spec.Envs,
+			HasCpu:       spec.HasCpu,
+			Cpu:          spec.Cpu,
+			HasMemory:    spec.HasMemory,
+			Memory:       spec.Memory,
+			HasNetwork:   spec.HasNetwork,
+			// The v2 API only reports the total usage of the container filesystems, not
+			// the per device statistics of the v1 API.
+			HasFilesystem:    false,
+			HasDiskIo:        spec.HasDiskIo,
+			HasCustomMetrics: spec.HasCustomMetrics,
+			CustomMetrics:    spec.CustomMetrics,
+			Image:            spec.Image,
+		},
+		Stats: make([]*cadvisor.ContainerStats, 0, len(info.Stats)),
+	}
+
+	var latest *v2ContainerStats
+	for _, stats := range info.Stats {
+		if stats == nil {
+			continue
+		}
+		v1Stats := &cadvisor.ContainerStats{
+			Timestamp:     stats.Timestamp,
+			CustomMetrics: stats.CustomMetrics,
+			Accelerators:  stats.Accelerators,
+		}
+		if stats.Cpu != nil {
+			v1Stats.Cpu = *stats.Cpu
+		}
+		if stats.DiskIo != nil {
+			v1Stats.DiskIo = *stats.DiskIo
+		}
+		if stats.Memory != nil {
+			v1Stats.Memory = *stats.Memory
+		}
+		if stats.Network != nil {
+			v1Stats.Network.Interfaces = stats.Network.Interfaces
+			// The v1 API also reports the first interface at the top level.
+			if len(stats.Network.Interfaces) > 0 {
+				v1Stats.Network.InterfaceStats = stats.Network.Interfaces[0]
+			}
+			v1Stats.Network.Tcp = stats.Network.Tcp
+			v1Stats.Network.Tcp6 = stats.Network.Tcp6
+		}
+		if stats.Load != nil {
+			v1Stats.TaskStats = *stats.Load
+		}
+		containerInfo.Stats = append(containerInfo.Stats, v1Stats)
+		// The samples are sorted by time.
+		latest = stats
+	}
+
+	extras := &ContainerExtras{}
+	if latest != nil && latest.CpuInst != nil {
+		usage := latest.CpuInst.Usage.Total
+		extras.CpuInstUsage = &usage
+	}
+	if latest != nil && latest.Filesystem != nil {
+		extras.InodeUsage = latest.Filesystem.InodeUsage
+	}
+	return containerInfo, extras
+}
@@ -0,0 +1,262 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	cadvisor_api "github.com/google/cadvisor/info/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/heapster/metrics/core"
+	kubelet_client "k8s.io/heapster/metrics/sources/kubelet/util"
+)
+
+// A response of the v2.1 stats API of cadvisor, with the node and a container, whose
+// samples are sorted by time.
+const cadvisorV2Response = `{
+  "/": {
+    "spec": {
+      "creation_time": "2017-06-01T10:00:00Z",
+      "has_cpu": true,
+      "cpu": {"limit": 1024, "max_limit": 0, "mask": "0-3"},
+      "has_memory": true,
+      "memory": {"limit": 8589934592, "reservation": 9223372036854771712},
+      "has_network": true,
+      "has_filesystem": true,
+      "has_diskio": true,
+      "has_custom_metrics": false
+    },
+    "stats": [
+      {
+        "timestamp": "TIMESTAMP",
+        "has_cpu": true,
+        "cpu": {"usage": {"total": 9000000000, "user": 6000000000, "system": 3000000000}, "load_average": 0},
+        "cpu_inst": {"usage": {"total": 2000000000, "user": 1500000000, "system": 500000000}},
+        "has_memory": true,
+        "memory": {"usage": 4294967296, "working_set": 2147483648},
+        "has_network": true,
+        "network": {
+          "interfaces": [{"name": "eth0", "rx_bytes": 1000, "tx_bytes": 2000}, {"name": "eth1", "rx_bytes": 100, "tx_bytes": 200}],
+          "tcp": {"Established": 0},
+          "tcp6": {"Established": 0}
+        }
+      }
+    ]
+  },
+  "/kubepods/burstable/pod1234/abcdef": {
+    "spec": {
+      "creation_time": "2017-06-01T11:00:00Z",
+      "aliases": ["k8s_app_web-1_ns1_1234_0", "abcdef"],
+      "namespace": "docker",
+      "labels": {
+        "io.kubernetes.container.name": "app",
+        "io.kubernetes.pod.name": "web-1",
+        "io.kubernetes.pod.namespace": "ns1",
+        "io.kubernetes.pod.uid": "1234"
+      },
+      "has_cpu": true,
+      "cpu": {"limit": 512, "max_limit": 0, "mask": "0-3", "quota": 50000, "period": 100000},
+      "has_memory": true,
+      "memory": {"limit": 268435456},
+      "has_network": false,
+      "has_filesystem": true,
+      "has_diskio": false,
+      "has_custom_metrics": false,
+      "image": "gcr.io/project/app:v1"
+    },
+    "stats": [
+      {
+        "timestamp": "OLDER_TIMESTAMP",
+        "has_cpu": true,
+        "cpu": {"usage": {"total": 1000000000}},
+        "cpu_inst": {"usage": {"total": 100000000}},
+        "has_memory": true,
+        "memory": {"usage": 100000000, "working_set": 50000000},
+        "has_filesystem": true,
+        "filesystem": {"totalUsageBytes": 1000, "baseUsageBytes": 500, "containter_inode_usage": 10}
+      },
+      {
+        "timestamp": "TIMESTAMP",
+        "has_cpu": true,
+        "cpu": {"usage": {"total": 1500000000, "user": 1000000000, "system": 500000000}, "schedstat": {"run_time": 1000, "runqueue_time": 250, "run_periods": 10}},
+        "cpu_inst": {"usage": {"total": 250000000}},
+        "has_memory": true,
+        "memory": {"usage": 120000000, "working_set": 60000000},
+        "has_filesystem": true,
+        "filesystem": {"totalUsageBytes": 2000, "baseUsageBytes": 1500, "containter_inode_usage": 42}
+      }
+    ]
+  }
+}`
+
+func cadvisorV2Server(t *testing.T, timestamp time.Time) *httptest.Server {
+	body := strings.NewReplacer(
+		"OLDER_TIMESTAMP", timestamp.Add(-10*time.Second).Format(time.RFC3339),
+		"TIMESTAMP", timestamp.Format(time.RFC3339)).Replace(cadvisorV2Response)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/api/v2.1/stats/", r.URL.Path)
+		assert.Equal(t, "name", r.URL.Query().Get("type"))
+		assert.Equal(t, "true", r.URL.Query().Get("recursive"))
+		_, err := time.ParseDuration(r.URL.Query().Get("max_age"))
+		assert.NoError(t, err)
+		w.Write([]byte(body))
+	}))
+}
+
+// cadvisorV2Source returns a source collecting from the v2 API of the given server, on
+// another port than the kubelet one.
+func cadvisorV2Source(t *testing.T, server *httptest.Server) *kubeletMetricsSource {
+	serverUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(serverUrl.Host)
+	require.NoError(t, err)
+	cadvisorPort, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return &kubeletMetricsSource{
+		host: Host{IP: net.ParseIP(host), Port: 10255},
+		kubeletClient: &KubeletClient{config: &kubelet_client.KubeletClientConfig{
+			CadvisorApi:  kubelet_client.CadvisorApiV2,
+			CadvisorPort: uint(cadvisorPort),
+		}},
+		nodename: "node1",
+		hostname: "node1",
+	}
+}
+
+func TestStreamAllContainersV2(t *testing.T) {
+	timestamp := time.Now().Truncate(time.Second)
+	server := cadvisorV2Server(t, timestamp)
+	defer server.Close()
+	source := cadvisorV2Source(t, server)
+
+	containers := map[string]*ContainerExtras{}
+	err := source.kubeletClient.StreamAllRawContainers(source.host, timestamp.Add(-time.Minute), timestamp, func(c *cadvisor_api.ContainerInfo, extras *ContainerExtras) {
+		containers[c.Name] = extras
+		// The latest sample is kept, like for the v1 API.
+		require.Len(t, c.Stats, 1)
+		assert.True(t, timestamp.Equal(c.Stats[0].Timestamp), c.Name)
+		if c.Name != "/" {
+			return
+		}
+		assert.True(t, c.Spec.HasNetwork)
+		assert.Len(t, c.Stats[0].Network.Interfaces, 2)
+		assert.Equal(t, uint64(9000000000), c.Stats[0].Cpu.Usage.Total)
+		assert.Equal(t, uint64(2147483648), c.Stats[0].Memory.WorkingSet)
+	})
+	require.NoError(t, err)
+
+	// The containers are named after their first alias.
+	require.Len(t, containers, 2)
+	container := containers["k8s_app_web-1_ns1_1234_0"]
+	require.NotNil(t, container)
+	require.NotNil(t, container.CpuInstUsage)
+	assert.Equal(t, uint64(250000000), *container.CpuInstUsage)
+	require.NotNil(t, container.InodeUsage)
+	assert.Equal(t, uint64(42), *container.InodeUsage)
+	require.NotNil(t, container.Schedstat)
+	assert.Equal(t, uint64(250), container.Schedstat.RunqueueTime)
+	assert.Nil(t, containers["/"].InodeUsage)
+}
+
+func TestStreamAllContainersV2WithoutCredentials(t *testing.T) {
+	tokenDir, err := ioutil.TempDir("", "kubelet-tokens")
+	require.NoError(t, err)
+	defer os.RemoveAll(tokenDir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tokenDir, "node1"), []byte("token-1"), 0600))
+
+	authorization := "unset"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	source := cadvisorV2Source(t, server)
+	kubeletClient, err := NewKubeletClient(&kubelet_client.KubeletClientConfig{
+		BearerToken:  "shared",
+		NodeTokenDir: tokenDir,
+		CadvisorApi:  kubelet_client.CadvisorApiV2,
+		CadvisorPort: source.kubeletClient.config.CadvisorPort,
+	})
+	require.NoError(t, err)
+
+	host := source.host
+	host.NodeName = "node1"
+	err = kubeletClient.StreamAllRawContainers(host, time.Now(), time.Now(), func(*cadvisor_api.ContainerInfo, *ContainerExtras) {})
+	require.NoError(t, err)
+	assert.Empty(t, authorization)
+}
+
+func TestScrapeMetricsV2(t *testing.T) {
+	timestamp := time.Now().Truncate(time.Second)
+	server := cadvisorV2Server(t, timestamp)
+	defer server.Close()
+
+	batch, err := cadvisorV2Source(t, server).ScrapeMetrics(timestamp.Add(-time.Minute), timestamp)
+	require.NoError(t, err)
+
+	node := batch.MetricSets[core.NodeKey("node1")]
+	require.NotNil(t, node)
+	assert.Equal(t, int64(9000000000), node.MetricValues[core.MetricCpuUsage.Name].IntValue)
+	assert.Equal(t, int64(2147483648), node.MetricValues[core.MetricMemoryWorkingSet.Name].IntValue)
+	assert.Equal(t, int64(1100), node.MetricValues[core.MetricNetworkRx.Name].IntValue)
+	// Only set on containers.
+	_, found := node.MetricValues[core.MetricContainerCpuInstantUsageRate.Name]
+	assert.False(t, found)
+
+	container := batch.MetricSets[core.PodContainerKey("ns1", "web-1", "app")]
+	require.NotNil(t, container)
+	assert.Equal(t, "gcr.io/project/app:v1", container.Labels[core.LabelContainerBaseImage.Key])
+	assert.Equal(t, int64(1500000000), container.MetricValues[core.MetricCpuUsage.Name].IntValue)
+	assert.Equal(t, int64(60000000), container.MetricValues[core.MetricMemoryWorkingSet.Name].IntValue)
+	assert.True(t, time.Date(2017, 6, 1, 11, 0, 0, 0, time.UTC).Equal(container.CollectionStartTime))
+	assert.Equal(t, int64(250), container.MetricValues[core.MetricContainerCpuInstantUsageRate.Name].IntValue)
+	assert.Equal(t, int64(42), container.MetricValues[core.MetricContainerInodesUsed.Name].IntValue)
+	assert.Equal(t, int64(250), container.MetricValues[core.MetricContainerCpuWaitTime.Name].IntValue)
+}
+
+func TestGetKubeConfigsWithCadvisorApi(t *testing.T) {
+	uri, err := url.Parse("https://master:6443?inClusterConfig=false&cadvisorApi=v2&cadvisorPort=4195")
+	require.NoError(t, err)
+	_, kubeletConfig, err := GetKubeConfigs(uri)
+	require.NoError(t, err)
+	assert.Equal(t, kubelet_client.CadvisorApiV2, kubeletConfig.CadvisorApi)
+	assert.Equal(t, uint(4195), kubeletConfig.CadvisorPort)
+
+	uri, err = url.Parse("https://master:6443?inClusterConfig=false")
+	require.NoError(t, err)
+	_, kubeletConfig, err = GetKubeConfigs(uri)
+	require.NoError(t, err)
+	assert.Equal(t, kubelet_client.CadvisorApiV1, kubeletConfig.CadvisorApi)
+
+	for _, query := range []string{"cadvisorApi=v3", "cadvisorPort=4195", "cadvisorApi=v2&cadvisorPort=0"} {
+		uri, err = url.Parse("https://master:6443?inClusterConfig=false&" + query)
+		require.NoError(t, err)
+		_, _, err = GetKubeConfigs(uri)
+		assert.Error(t, err, query)
+	}
+}
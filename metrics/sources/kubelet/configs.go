@@ -15,10 +15,14 @@
 package kubelet
 
 import (
+	"fmt"
 	"net/url"
+	"os"
 	"strconv"
+	"time"
 
 	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	kube_client "k8s.io/client-go/rest"
 	kube_config "k8s.io/heapster/common/kubernetes"
 	kubelet_client "k8s.io/heapster/metrics/sources/kubelet/util"
@@ -29,6 +33,7 @@ const (
 
 	defaultKubeletPort        = 10255
 	defaultKubeletHttps       = false
+	defaultCadvisorPort       = 4194
 	defaultUseServiceAccount  = false
 	defaultServiceAccountFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 	defaultInClusterConfig    = true
@@ -58,14 +63,116 @@ func GetKubeConfigs(uri *url.URL) (*kube_client.Config, *kubelet_client.KubeletC
 		}
 	}
 
+	var idleConnTimeout time.Duration
+	if len(opts["kubeletIdleConnTimeout"]) >= 1 {
+		idleConnTimeout, err = time.ParseDuration(opts["kubeletIdleConnTimeout"][0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse `kubeletIdleConnTimeout` flag - %v", err)
+		}
+	}
+
+	var maxConnLifetime time.Duration
+	if len(opts["kubeletMaxConnLifetime"]) >= 1 {
+		maxConnLifetime, err = time.ParseDuration(opts["kubeletMaxConnLifetime"][0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse `kubeletMaxConnLifetime` flag - %v", err)
+		}
+	}
+
+	var maxResponseBytes int64
+	if len(opts["kubeletMaxResponseBytes"]) >= 1 {
+		maxResponseBytes, err = strconv.ParseInt(opts["kubeletMaxResponseBytes"][0], 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse `kubeletMaxResponseBytes` flag - %v", err)
+		}
+		if maxResponseBytes < 0 {
+			return nil, nil, fmt.Errorf("`kubeletMaxResponseBytes` flag can not be negative")
+		}
+	}
+
+	var healthCheckTimeout time.Duration
+	if len(opts["kubeletHealthCheckTimeout"]) >= 1 {
+		healthCheckTimeout, err = time.ParseDuration(opts["kubeletHealthCheckTimeout"][0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse `kubeletHealthCheckTimeout` flag - %v", err)
+		}
+		if healthCheckTimeout < 0 {
+			return nil, nil, fmt.Errorf("`kubeletHealthCheckTimeout` flag can not be negative")
+		}
+	}
+
+	var apiServerProxy *url.URL
+	if len(opts["apiServerProxy"]) >= 1 {
+		useProxy, err := strconv.ParseBool(opts["apiServerProxy"][0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse `apiServerProxy` flag - %v", err)
+		}
+		if useProxy {
+			apiServerProxy, _, err = kube_client.DefaultServerURL(kubeConfig.Host, "", schema.GroupVersion{}, kube_client.IsConfigTransportTLS(*kubeConfig))
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid API server address %q - %v", kubeConfig.Host, err)
+			}
+		}
+	}
+
+	var nodeTokenDir string
+	if len(opts["kubeletTokenDir"]) >= 1 {
+		nodeTokenDir = opts["kubeletTokenDir"][0]
+		if apiServerProxy != nil {
+			return nil, nil, fmt.Errorf("`kubeletTokenDir` flag can not be used with `apiServerProxy`, which authenticates with the API server credentials")
+		}
+		info, err := os.Stat(nodeTokenDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid `kubeletTokenDir` flag - %v", err)
+		}
+		if !info.IsDir() {
+			return nil, nil, fmt.Errorf("`kubeletTokenDir` flag %q is not a directory", nodeTokenDir)
+		}
+	}
+
+	cadvisorApi := kubelet_client.CadvisorApiV1
+	if len(opts["cadvisorApi"]) >= 1 {
+		cadvisorApi = opts["cadvisorApi"][0]
+		if cadvisorApi != kubelet_client.CadvisorApiV1 && cadvisorApi != kubelet_client.CadvisorApiV2 {
+			return nil, nil, fmt.Errorf("invalid `cadvisorApi` flag %q - expected %s or %s", cadvisorApi, kubelet_client.CadvisorApiV1, kubelet_client.CadvisorApiV2)
+		}
+	}
+	cadvisorPort := defaultCadvisorPort
+	if len(opts["cadvisorPort"]) >= 1 {
+		if cadvisorApi != kubelet_client.CadvisorApiV2 {
+			return nil, nil, fmt.Errorf("`cadvisorPort` flag can only be used with `cadvisorApi=%s`", kubelet_client.CadvisorApiV2)
+		}
+		cadvisorPort, err = strconv.Atoi(opts["cadvisorPort"][0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse `cadvisorPort` flag - %v", err)
+		}
+		if cadvisorPort <= 0 || cadvisorPort > 65535 {
+			return nil, nil, fmt.Errorf("invalid `cadvisorPort` flag %d", cadvisorPort)
+		}
+	}
+
 	glog.Infof("Using Kubernetes client with master %q and version %+v\n", kubeConfig.Host, kubeConfig.GroupVersion)
-	glog.Infof("Using kubelet port %d", kubeletPort)
+	if apiServerProxy != nil {
+		glog.Infof("Scraping kubelets through the API server proxy at %s", apiServerProxy)
+	} else {
+		glog.Infof("Using kubelet port %d", kubeletPort)
+	}
 
 	kubeletConfig := &kubelet_client.KubeletClientConfig{
-		Port:            uint(kubeletPort),
-		EnableHttps:     kubeletHttps,
-		TLSClientConfig: kubeConfig.TLSClientConfig,
-		BearerToken:     kubeConfig.BearerToken,
+		Port:               uint(kubeletPort),
+		EnableHttps:        kubeletHttps,
+		TLSClientConfig:    kubeConfig.TLSClientConfig,
+		BearerToken:        kubeConfig.BearerToken,
+		NodeTokenDir:       nodeTokenDir,
+		IdleConnTimeout:    idleConnTimeout,
+		MaxConnLifetime:    maxConnLifetime,
+		MaxResponseBytes:   maxResponseBytes,
+		HealthCheckTimeout: healthCheckTimeout,
+		CadvisorApi:        cadvisorApi,
+		CadvisorPort:       uint(cadvisorPort),
+		APIServerProxy:     apiServerProxy,
+		// Only used with the API server proxy.
+		APIServerInsecure: kubeConfig.Insecure,
 	}
 
 	return kubeConfig, kubeletConfig, nil
@@ -0,0 +1,75 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	. "k8s.io/heapster/metrics/core"
+)
+
+// ProbeKubelet checks the health of the Kubelet before it is scraped, if the health check
+// is enabled. It returns false if the Kubelet failed the check and is not to be scraped
+// in this cycle.
+func ProbeKubelet(client *KubeletClient, host Host) bool {
+	if !client.HealthCheckEnabled() {
+		return true
+	}
+	if err := client.CheckHealth(host); err != nil {
+		glog.Warningf("Skipping the scrape of Kubelet %s - %v", host, err)
+		return false
+	}
+	return true
+}
+
+// UnreachableKubeletBatch returns the batch reporting node/kubelet_reachable of a node
+// whose Kubelet failed the health check. The labels are those of the node metric set.
+func UnreachableKubeletBatch(timestamp time.Time, nodeName string, labels map[string]string) *DataBatch {
+	nodeLabels := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		nodeLabels[k] = v
+	}
+	nodeLabels[LabelMetricSetType.Key] = MetricSetTypeNode
+	return &DataBatch{
+		Timestamp: timestamp,
+		MetricSets: map[string]*MetricSet{
+			NodeKey(nodeName): {
+				ScrapeTime:   timestamp,
+				MetricValues: map[string]MetricValue{MetricNodeKubeletReachable.Name: kubeletReachableValue(0)},
+				Labels:       nodeLabels,
+			},
+		},
+	}
+}
+
+// MarkKubeletReachable sets node/kubelet_reachable on the node metric set of a Kubelet
+// that passed the health check.
+func MarkKubeletReachable(client *KubeletClient, batch *DataBatch, nodeName string) {
+	if !client.HealthCheckEnabled() {
+		return
+	}
+	if node, found := batch.MetricSets[NodeKey(nodeName)]; found {
+		node.MetricValues[MetricNodeKubeletReachable.Name] = kubeletReachableValue(1)
+	}
+}
+
+func kubeletReachableValue(value int64) MetricValue {
+	return MetricValue{
+		MetricType: MetricGauge,
+		ValueType:  ValueInt64,
+		IntValue:   value,
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	cadvisor_api "github.com/google/cadvisor/info/v1"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/heapster/metrics/core"
+)
+
+func serverHost(t *testing.T, server *httptest.Server) Host {
+	serverUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(serverUrl.Host)
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return Host{IP: net.ParseIP(host), Port: portNumber}
+}
+
+func healthCheckedClient() *KubeletClient {
+	return &KubeletClient{healthClient: &http.Client{Timeout: time.Second}}
+}
+
+func TestCheckHealth(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "HEAD", req.Method)
+		assert.Equal(t, "/healthz", req.URL.Path)
+	}))
+	defer healthy.Close()
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer unhealthy.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachableHost := serverHost(t, unreachable)
+	unreachable.Close()
+
+	client := healthCheckedClient()
+	assert.True(t, client.HealthCheckEnabled())
+	assert.NoError(t, client.CheckHealth(serverHost(t, healthy)))
+	assert.Error(t, client.CheckHealth(serverHost(t, unhealthy)))
+	assert.Error(t, client.CheckHealth(unreachableHost))
+	assert.False(t, (&KubeletClient{}).HealthCheckEnabled())
+}
+
+func TestScrapeMetricsReachableKubelet(t *testing.T) {
+	response := map[string]cadvisor_api.ContainerInfo{
+		"/": {
+			ContainerReference: cadvisor_api.ContainerReference{Name: "/"},
+			Spec:               cadvisor_api.ContainerSpec{CreationTime: time.Now(), HasCpu: true},
+			Stats:              []*cadvisor_api.ContainerStats{{Timestamp: time.Now()}},
+		},
+	}
+	data, err := jsoniter.ConfigFastest.Marshal(&response)
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/healthz" {
+			w.Write(data)
+		}
+	}))
+	defer server.Close()
+
+	source := kubeletMetricsSource{
+		host:          serverHost(t, server),
+		kubeletClient: healthCheckedClient(),
+		nodename:      "node-1",
+	}
+	batch, err := source.ScrapeMetrics(time.Now(), time.Now().Add(5*time.Second))
+	require.NoError(t, err)
+	node := batch.MetricSets[core.NodeKey("node-1")]
+	require.NotNil(t, node)
+	assert.Equal(t, int64(1), node.MetricValues[core.MetricNodeKubeletReachable.Name].IntValue)
+	assert.Contains(t, node.MetricValues, core.MetricCpuUsage.Name)
+}
+
+func TestScrapeMetricsUnreachableKubelet(t *testing.T) {
+	scraped := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		scraped = true
+	}))
+	defer server.Close()
+
+	source := kubeletMetricsSource{
+		host:          serverHost(t, server),
+		kubeletClient: healthCheckedClient(),
+		nodename:      "node-1",
+		hostname:      "host-1",
+	}
+	end := time.Now()
+	batch, err := source.ScrapeMetrics(end.Add(-10*time.Second), end)
+	require.NoError(t, err)
+	assert.False(t, scraped, "the stats of a Kubelet failing the health check are not requested")
+	assert.Equal(t, 1, len(batch.MetricSets))
+	node := batch.MetricSets[core.NodeKey("node-1")]
+	require.NotNil(t, node)
+	assert.Equal(t, map[string]core.MetricValue{
+		core.MetricNodeKubeletReachable.Name: {MetricType: core.MetricGauge, ValueType: core.ValueInt64, IntValue: 0},
+	}, node.MetricValues)
+	assert.Equal(t, core.MetricSetTypeNode, node.Labels[core.LabelMetricSetType.Key])
+	assert.Equal(t, "host-1", node.Labels[core.LabelHostname.Key])
+	assert.True(t, end.Equal(node.ScrapeTime))
+}
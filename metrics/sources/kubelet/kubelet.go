@@ -18,7 +18,11 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	. "k8s.io/heapster/metrics/core"
@@ -55,10 +59,21 @@ var (
 		},
 		[]string{"node"},
 	)
+	// The number of containers reported twice by the Kubelet within a single scrape.
+	kubeletDuplicateContainers = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "heapster",
+			Subsystem: "kubelet",
+			Name:      "duplicate_containers_total",
+			Help:      "The number of containers reported more than once by the Kubelet within a single scrape.",
+		},
+		[]string{"node"},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(kubeletRequestLatency)
+	prometheus.MustRegister(kubeletDuplicateContainers)
 }
 
 // Kubelet-provided metrics for pod and system container.
@@ -69,19 +84,42 @@ type kubeletMetricsSource struct {
 	hostname      string
 	hostId        string
 	schedulable   string
+	// Container runtime version reported by the node.
+	containerRuntime string
+	// Samples further than this from the local clock are dropped. Zero disables the check.
+	maxClockSkew time.Duration
+	nodeLabels   map[string]string
+	// Whether all the cadvisor samples of the scrape window are decoded, not just the first one.
+	allStatsSamples bool
+	// Number of goroutines decoding the containers of a scrape. Up to one decodes them
+	// as they are read from the response.
+	decodeWorkers int
+	// Matches the devices of the node root filesystem. Nil if not configured.
+	rootFsDevice *regexp.Regexp
 }
 
-func NewKubeletMetricsSource(host Host, client *KubeletClient, nodeName string, hostName string, hostId string, schedulable string) MetricsSource {
+func NewKubeletMetricsSource(host Host, client *KubeletClient, nodeName string, hostName string, hostId string, schedulable string, containerRuntime string, maxClockSkew time.Duration, nodeLabels map[string]string, allStatsSamples bool, decodeWorkers int, rootFsDevice *regexp.Regexp) MetricsSource {
 	return &kubeletMetricsSource{
-		host:          host,
-		kubeletClient: client,
-		nodename:      nodeName,
-		hostname:      hostName,
-		hostId:        hostId,
-		schedulable:   schedulable,
+		host:             host,
+		kubeletClient:    client,
+		nodename:         nodeName,
+		hostname:         hostName,
+		hostId:           hostId,
+		schedulable:      schedulable,
+		containerRuntime: containerRuntime,
+		maxClockSkew:     maxClockSkew,
+		nodeLabels:       nodeLabels,
+		allStatsSamples:  allStatsSamples,
+		decodeWorkers:    decodeWorkers,
+		rootFsDevice:     rootFsDevice,
 	}
 }
 
+// NodeLabels returns the labels of the scraped node.
+func (this *kubeletMetricsSource) NodeLabels() map[string]string {
+	return this.nodeLabels
+}
+
 func (this *kubeletMetricsSource) Name() string {
 	return this.String()
 }
@@ -122,11 +160,13 @@ func (this *kubeletMetricsSource) decodeMetrics(c *cadvisor.ContainerInfo) (stri
 	if len(c.Stats) == 0 {
 		return "", nil
 	}
+	// cadvisor returns the samples oldest first.
+	latest := c.Stats[len(c.Stats)-1]
 
 	var metricSetKey string
 	cMetrics := &MetricSet{
 		CollectionStartTime: c.Spec.CreationTime,
-		ScrapeTime:          c.Stats[0].Timestamp,
+		ScrapeTime:          latest.Timestamp,
 		MetricValues:        map[string]MetricValue{},
 		Labels: map[string]string{
 			LabelNodename.Key: this.nodename,
@@ -140,6 +180,7 @@ func (this *kubeletMetricsSource) decodeMetrics(c *cadvisor.ContainerInfo) (stri
 		metricSetKey = NodeKey(this.nodename)
 		cMetrics.Labels[LabelMetricSetType.Key] = MetricSetTypeNode
 		cMetrics.Labels[LabelNodeSchedulable.Key] = this.schedulable
+		cMetrics.Labels[LabelContainerRuntime.Key] = this.containerRuntime
 	} else {
 		cName := c.Spec.Labels[kubernetesContainerLabel]
 		ns := c.Spec.Labels[kubernetesPodNamespaceLabel]
@@ -176,24 +217,40 @@ func (this *kubeletMetricsSource) decodeMetrics(c *cadvisor.ContainerInfo) (stri
 
 	for _, metric := range StandardMetrics {
 		if metric.HasValue != nil && metric.HasValue(&c.Spec) {
-			cMetrics.MetricValues[metric.Name] = metric.GetValue(&c.Spec, c.Stats[0])
+			cMetrics.MetricValues[metric.Name] = metric.GetValue(&c.Spec, latest)
 		}
 	}
 
 	for _, metric := range LabeledMetrics {
-		if metric.HasLabeledMetric != nil && metric.HasLabeledMetric(&c.Spec, c.Stats[0]) {
-			labeledMetrics := metric.GetLabeledMetric(&c.Spec, c.Stats[0])
+		if metric.HasLabeledMetric != nil && metric.HasLabeledMetric(&c.Spec, latest) {
+			labeledMetrics := metric.GetLabeledMetric(&c.Spec, latest)
 			cMetrics.LabeledMetrics = append(cMetrics.LabeledMetrics, labeledMetrics...)
 		}
 	}
 
+	if this.allStatsSamples {
+		cMetrics.Samples = decodeSamples(c)
+	}
+
+	if isNode(c) && c.Spec.HasFilesystem {
+		decodeNodeFilesystems(latest, cMetrics, this.rootFsDevice, this.nodename)
+	}
+
+	if c.Spec.HasNetwork {
+		decodeTcpMetrics(latest, cMetrics)
+	}
+
+	if c.Spec.HasCpu {
+		decodeCpuThrottlingMetrics(latest, cMetrics)
+	}
+
 	if !c.Spec.HasCustomMetrics {
 		return metricSetKey, cMetrics
 	}
 
 metricloop:
 	for _, spec := range c.Spec.CustomMetrics {
-		cmValue, ok := c.Stats[0].CustomMetrics[spec.Name]
+		cmValue, ok := latest.CustomMetrics[spec.Name]
 		if !ok || cmValue == nil || len(cmValue) == 0 {
 			continue metricloop
 		}
@@ -233,41 +290,181 @@ metricloop:
 	return metricSetKey, cMetrics
 }
 
-func (this *kubeletMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatch, error) {
-	containers, err := this.scrapeKubelet(this.kubeletClient, this.host, start, end)
-
-	if err != nil {
-		return nil, err
+// decodeSamples returns the standard metrics of all the samples of the container but the
+// latest one, which is decoded into the metric set itself, oldest first.
+func decodeSamples(c *cadvisor.ContainerInfo) []MetricSample {
+	latest := c.Stats[len(c.Stats)-1]
+	samples := make([]MetricSample, 0, len(c.Stats)-1)
+	for _, stats := range c.Stats[:len(c.Stats)-1] {
+		if stats == nil || stats.Timestamp.Equal(latest.Timestamp) {
+			continue
+		}
+		sample := MetricSample{
+			Timestamp:    stats.Timestamp,
+			MetricValues: map[string]MetricValue{},
+		}
+		for _, metric := range StandardMetrics {
+			if metric.HasValue != nil && metric.HasValue(&c.Spec) {
+				sample.MetricValues[metric.Name] = metric.GetValue(&c.Spec, stats)
+			}
+		}
+		samples = append(samples, sample)
 	}
+	sort.Sort(samplesByTime(samples))
+	return samples
+}
+
+type samplesByTime []MetricSample
 
-	glog.V(2).Infof("successfully obtained stats from %s for %v containers", this.host, len(containers))
+func (s samplesByTime) Len() int           { return len(s) }
+func (s samplesByTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s samplesByTime) Less(i, j int) bool { return s[i].Timestamp.Before(s[j].Timestamp) }
 
+func (this *kubeletMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatch, error) {
 	result := &DataBatch{
 		Timestamp:  end,
 		MetricSets: map[string]*MetricSet{},
 	}
+	if !ProbeKubelet(this.kubeletClient, this.host) {
+		return UnreachableKubeletBatch(end, this.nodename, map[string]string{
+			LabelNodename.Key: this.nodename,
+			LabelHostname.Key: this.hostname,
+			LabelHostID.Key:   this.hostId,
+		}), nil
+	}
 
-	for _, c := range containers {
-		name, metrics := this.decodeMetrics(&c)
-		if name == "" || metrics == nil {
+	var decoded []decodedContainer
+	var err error
+	if this.decodeWorkers > 1 {
+		decoded, err = this.scrapeInParallel(start, end)
+	} else {
+		// The containers are decoded one by one as they are read from the response.
+		err = this.scrapeKubelet(this.kubeletClient, this.host, start, end, func(c *cadvisor.ContainerInfo, extras *ContainerExtras) {
+			decoded = append(decoded, this.decodeContainer(len(decoded), c, extras))
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The containers are merged in the order of the response, whatever the order they
+	// were decoded in, so that the same duplicate wins either way.
+	for _, container := range decoded {
+		if container.name == "" || container.metrics == nil {
 			continue
 		}
-		result.MetricSets[name] = metrics
+		// cadvisor may report the same container under several cgroup paths. Keep the newest sample.
+		if existing, found := result.MetricSets[container.name]; found {
+			glog.V(4).Infof("Duplicate container %s (%s) reported by %s", container.name, container.cgroup, this.host)
+			kubeletDuplicateContainers.WithLabelValues(this.hostname).Inc()
+			if !container.metrics.ScrapeTime.After(existing.ScrapeTime) {
+				continue
+			}
+		}
+		result.MetricSets[container.name] = container.metrics
 	}
+	count := len(decoded)
+	MarkKubeletReachable(this.kubeletClient, result, this.nodename)
 
+	glog.V(2).Infof("successfully obtained stats from %s for %v containers", this.host, count)
 	return result, nil
 }
 
-func (this *kubeletMetricsSource) scrapeKubelet(client *KubeletClient, host Host, start, end time.Time) ([]cadvisor.ContainerInfo, error) {
+// decodedContainer is a container of the response of the kubelet, decoded into a metric set.
+type decodedContainer struct {
+	// Position of the container in the response.
+	index   int
+	cgroup  string
+	name    string
+	metrics *MetricSet
+}
+
+type decodedByIndex []decodedContainer
+
+func (d decodedByIndex) Len() int           { return len(d) }
+func (d decodedByIndex) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d decodedByIndex) Less(i, j int) bool { return d[i].index < d[j].index }
+
+func (this *kubeletMetricsSource) decodeContainer(index int, c *cadvisor.ContainerInfo, extras *ContainerExtras) decodedContainer {
+	// Rate calculations rely on the sample timestamps, so samples from a node whose clock
+	// is too far off are unusable.
+	if extras != nil && extras.ClockSkew != nil && this.maxClockSkew > 0 {
+		if skew := *extras.ClockSkew; skew > this.maxClockSkew || skew < -this.maxClockSkew {
+			glog.V(2).Infof("Dropping stats of %s from node %s - clock skew %v exceeds %v", c.Name, this.nodename, skew, this.maxClockSkew)
+			return decodedContainer{index: index, cgroup: c.Name}
+		}
+	}
+	name, metrics := this.decodeMetrics(c)
+	if metrics != nil && extras != nil {
+		decodeExtraMetrics(metrics, extras)
+		if extras.ClockSkew != nil && isNode(c) {
+			metrics.MetricValues[MetricNodeClockSkew.Name] = MetricValue{
+				ValueType:  ValueFloat,
+				MetricType: MetricGauge,
+				FloatValue: float32(extras.ClockSkew.Seconds()),
+			}
+		}
+	}
+	return decodedContainer{index: index, cgroup: c.Name, name: name, metrics: metrics}
+}
+
+// scrapeInParallel hands the containers over to decodeWorkers goroutines as they are read
+// from the response. Every worker collects its own containers, which are returned in the
+// order of the response.
+func (this *kubeletMetricsSource) scrapeInParallel(start, end time.Time) ([]decodedContainer, error) {
+	type job struct {
+		index     int
+		container *cadvisor.ContainerInfo
+		extras    *ContainerExtras
+	}
+	jobs := make(chan job, this.decodeWorkers)
+	results := make([][]decodedContainer, this.decodeWorkers)
+	var wg sync.WaitGroup
+	for worker := range results {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := range jobs {
+				results[worker] = append(results[worker], this.decodeContainer(j.index, j.container, j.extras))
+			}
+		}(worker)
+	}
+
+	count := 0
+	err := this.scrapeKubelet(this.kubeletClient, this.host, start, end, func(c *cadvisor.ContainerInfo, extras *ContainerExtras) {
+		jobs <- job{index: count, container: c, extras: extras}
+		count++
+	})
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := make([]decodedContainer, 0, count)
+	for _, result := range results {
+		decoded = append(decoded, result...)
+	}
+	sort.Sort(decodedByIndex(decoded))
+	return decoded, nil
+}
+
+func (this *kubeletMetricsSource) scrapeKubelet(client *KubeletClient, host Host, start, end time.Time, handle func(*cadvisor.ContainerInfo, *ContainerExtras)) error {
 	startTime := time.Now()
 	defer kubeletRequestLatency.WithLabelValues(this.hostname).Observe(float64(time.Since(startTime)))
-	return client.GetAllRawContainers(host, start, end)
+	defer ObserveRequestDuration(startTime)
+	return client.StreamAllRawContainers(host, start, end, handle)
 }
 
 type kubeletProvider struct {
 	nodeLister    v1listers.NodeLister
 	reflector     *cache.Reflector
 	kubeletClient *KubeletClient
+	maxClockSkew  time.Duration
+	nodeFilter    *NodeFilter
+	// Whether the sources decode all the samples of the scrape window.
+	allStatsSamples bool
+	decodeWorkers   int
+	rootFsDevice    *regexp.Regexp
 }
 
 func (this *kubeletProvider) GetMetricsSources() []MetricsSource {
@@ -283,18 +480,28 @@ func (this *kubeletProvider) GetMetricsSources() []MetricsSource {
 	}
 
 	for _, node := range nodes {
+		if this.nodeFilter.Excludes(node) {
+			glog.V(4).Infof("Skipping control-plane node %s", node.Name)
+			continue
+		}
 		hostname, ip, err := GetNodeHostnameAndIP(node)
 		if err != nil {
 			glog.Errorf("%v", err)
 			continue
 		}
 		sources = append(sources, NewKubeletMetricsSource(
-			Host{IP: ip, Port: this.kubeletClient.GetPort()},
+			Host{IP: ip, Port: this.kubeletClient.GetPort(), NodeName: node.Name},
 			this.kubeletClient,
 			node.Name,
 			hostname,
 			node.Spec.ExternalID,
 			getNodeSchedulableStatus(node),
+			node.Status.NodeInfo.ContainerRuntimeVersion,
+			this.maxClockSkew,
+			node.Labels,
+			this.allStatsSamples,
+			this.decodeWorkers,
+			this.rootFsDevice,
 		))
 	}
 	return sources
@@ -344,10 +551,52 @@ func NewKubeletProvider(uri *url.URL) (MetricsSourceProvider, error) {
 		return nil, err
 	}
 	kubeClient := kube_client.NewForConfigOrDie(kubeConfig)
+
+	opts := uri.Query()
+	var maxClockSkew time.Duration
+	if len(opts["maxClockSkew"]) >= 1 {
+		maxClockSkew, err = time.ParseDuration(opts["maxClockSkew"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `maxClockSkew` flag - %v", err)
+		}
+	}
+	allStatsSamples := false
+	if len(opts["allStatsSamples"]) >= 1 {
+		allStatsSamples, err = strconv.ParseBool(opts["allStatsSamples"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `allStatsSamples` flag - %v", err)
+		}
+	}
+	kubeletConfig.AllStatsSamples = allStatsSamples
 	kubeletClient, err := NewKubeletClient(kubeletConfig)
 	if err != nil {
 		return nil, err
 	}
+	decodeWorkers := 1
+	if len(opts["decodeWorkers"]) >= 1 {
+		decodeWorkers, err = strconv.Atoi(opts["decodeWorkers"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `decodeWorkers` flag - %v", err)
+		}
+		if decodeWorkers <= 0 {
+			return nil, fmt.Errorf("`decodeWorkers` flag can only be positive")
+		}
+	}
+	var rootFsDevice *regexp.Regexp
+	if len(opts["rootFsDevice"]) >= 1 {
+		rootFsDevice, err = regexp.Compile(opts["rootFsDevice"][0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse `rootFsDevice` flag - %v", err)
+		}
+	}
+	nodeFilter, err := GetNodeFilter(uri)
+	if err != nil {
+		return nil, err
+	}
+	watchBackoff, err := util.ParseWatchBackoff(opts)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get nodes to test if the client is configured well. Watch gives less error information.
 	if _, err := kubeClient.Nodes().List(metav1.ListOptions{}); err != nil {
@@ -355,11 +604,16 @@ func NewKubeletProvider(uri *url.URL) (MetricsSourceProvider, error) {
 	}
 
 	// watch nodes
-	nodeLister, reflector, _ := util.GetNodeLister(kubeClient)
+	nodeLister, reflector, _ := util.GetNodeListerWithBackoff(kubeClient, watchBackoff)
 
 	return &kubeletProvider{
-		nodeLister:    nodeLister,
-		reflector:     reflector,
-		kubeletClient: kubeletClient,
+		nodeLister:      nodeLister,
+		reflector:       reflector,
+		kubeletClient:   kubeletClient,
+		maxClockSkew:    maxClockSkew,
+		nodeFilter:      nodeFilter,
+		allStatsSamples: allStatsSamples,
+		decodeWorkers:   decodeWorkers,
+		rootFsDevice:    rootFsDevice,
 	}, nil
 }
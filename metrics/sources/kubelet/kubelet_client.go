@@ -20,11 +20,15 @@ package kubelet
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -34,10 +38,15 @@ import (
 	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
 )
 
+// Size of the buffer used to decode the streamed kubelet responses.
+const streamBufferSize = 64 * 1024
+
 type Host struct {
 	IP       net.IP
 	Port     int
 	Resource string
+	// Name of the node, used to reach the Kubelet through the API server proxy.
+	NodeName string
 }
 
 func (h Host) String() string {
@@ -47,6 +56,10 @@ func (h Host) String() string {
 type KubeletClient struct {
 	config *kubelet_client.KubeletClientConfig
 	client *http.Client
+	// Client of the health check, with a shorter timeout. Nil if the health check is disabled.
+	healthClient *http.Client
+	// Client of the cadvisor v2 API, without the credentials of the kubelets.
+	cadvisorClient *http.Client
 }
 
 type ErrNotFound struct {
@@ -62,20 +75,145 @@ func IsNotFoundError(err error) bool {
 	return isNotFound
 }
 
-func sampleContainerStats(stats []*cadvisor.ContainerStats) []*cadvisor.ContainerStats {
+// CpuSchedstat holds the CPU scheduler statistics of a container, reported by cadvisor
+// as cpu.schedstat. It is not part of the vendored cadvisor API types, so it is decoded
+// separately.
+type CpuSchedstat struct {
+	// Time spent on the CPU in nanoseconds.
+	RunTime uint64 `json:"run_time"`
+	// Time spent waiting on a run queue in nanoseconds.
+	RunqueueTime uint64 `json:"runqueue_time"`
+	// Number of times processes of the container were scheduled.
+	RunPeriods uint64 `json:"run_periods"`
+}
+
+type containerSchedstats struct {
+	Stats []struct {
+		Cpu struct {
+			Schedstat *CpuSchedstat `json:"schedstat"`
+		} `json:"cpu"`
+	} `json:"stats"`
+}
+
+// decodeSchedstat returns the scheduler statistics of the latest sample of the
+// given raw container info, or nil if they are not reported.
+func decodeSchedstat(raw []byte) *CpuSchedstat {
+	if !bytes.Contains(raw, []byte(`"schedstat"`)) {
+		return nil
+	}
+	var stats containerSchedstats
+	if err := jsoniter.ConfigFastest.Unmarshal(raw, &stats); err != nil || len(stats.Stats) == 0 {
+		return nil
+	}
+	return stats.Stats[len(stats.Stats)-1].Cpu.Schedstat
+}
+
+// ContainerExtras holds the statistics of the latest sample of a container that are
+// missing from the vendored cadvisor v1 API types. Fields not reported are nil.
+type ContainerExtras struct {
+	Schedstat *CpuSchedstat
+	// CPU usage rate in nanocores computed by cadvisor. Only reported by the v2 API.
+	CpuInstUsage *uint64
+	// Number of inodes used by the container. Only reported by the v2 API.
+	InodeUsage *uint64
+	// Difference between the clock of the node and the local clock, positive if the node
+	// clock is ahead. Nil if the response has no Date header.
+	ClockSkew *time.Duration
+}
+
+// responseClockSkew estimates the clock skew of the server from the Date header of its
+// response, received within the given time of sending the request. The header only has a
+// one second resolution, so the skew is off by up to half a second plus half the latency.
+func responseClockSkew(response *http.Response, sent, received time.Time) *time.Duration {
+	date, err := http.ParseTime(response.Header.Get("Date"))
+	if err != nil {
+		return nil
+	}
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := date.Add(500 * time.Millisecond).Sub(local)
+	return &skew
+}
+
+// limitedBody fails the reads once more than max bytes of the response body were read.
+type limitedBody struct {
+	reader   io.Reader
+	max      int64
+	read     int64
+	exceeded bool
+}
+
+func newLimitedBody(body io.Reader, max int64) *limitedBody {
+	return &limitedBody{
+		// Allow a single byte over the limit to tell a body of exactly max bytes from a larger one.
+		reader: io.LimitReader(body, max+1),
+		max:    max,
+	}
+}
+
+func (lb *limitedBody) Read(p []byte) (int, error) {
+	n, err := lb.reader.Read(p)
+	lb.read += int64(n)
+	if lb.read > lb.max {
+		lb.exceeded = true
+		return n, fmt.Errorf("response body exceeds %d bytes", lb.max)
+	}
+	return n, err
+}
+
+// responseBody returns the body of the response, limited to the configured maximum size.
+func (self *KubeletClient) responseBody(response *http.Response) (io.Reader, *limitedBody) {
+	if self.config == nil || self.config.MaxResponseBytes <= 0 {
+		return response.Body, nil
+	}
+	limited := newLimitedBody(response.Body, self.config.MaxResponseBytes)
+	return limited, limited
+}
+
+// checkResponseSize returns an error if the response body was larger than allowed.
+func checkResponseSize(limited *limitedBody, req *http.Request) error {
+	if limited == nil || !limited.exceeded {
+		return nil
+	}
+	glog.Errorf("Response from Kubelet at %s exceeds the maximum size of %d bytes", req.URL.Host, limited.max)
+	return fmt.Errorf("response body exceeds the maximum size of %d bytes", limited.max)
+}
+
+// sampleContainerStats returns the latest of the samples, which cadvisor returns oldest
+// first, or all of them if all the samples of the scrape window are requested.
+func (self *KubeletClient) sampleContainerStats(stats []*cadvisor.ContainerStats) []*cadvisor.ContainerStats {
 	if len(stats) == 0 {
 		return []*cadvisor.ContainerStats{}
 	}
+	if self.allStatsSamples() {
+		return stats
+	}
 	return []*cadvisor.ContainerStats{stats[len(stats)-1]}
 }
 
+func (self *KubeletClient) allStatsSamples() bool {
+	return self.config != nil && self.config.AllStatsSamples
+}
+
+// numStats returns the number of samples requested from cadvisor per container, -1 for all
+// the samples of the requested window.
+func (self *KubeletClient) numStats() int {
+	if self.allStatsSamples() {
+		return -1
+	}
+	return 1
+}
+
 func (self *KubeletClient) postRequestAndGetValue(client *http.Client, req *http.Request, value interface{}) error {
 	response, err := client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer response.Body.Close()
-	body, err := ioutil.ReadAll(response.Body)
+	reader, limited := self.responseBody(response)
+	body, err := ioutil.ReadAll(reader)
+	if err := checkResponseSize(limited, req); err != nil {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("failed to read response body - %v", err)
 	}
@@ -99,7 +237,7 @@ func (self *KubeletClient) postRequestAndGetValue(client *http.Client, req *http
 }
 
 func (self *KubeletClient) parseStat(containerInfo *cadvisor.ContainerInfo) *cadvisor.ContainerInfo {
-	containerInfo.Stats = sampleContainerStats(containerInfo.Stats)
+	containerInfo.Stats = self.sampleContainerStats(containerInfo.Stats)
 	if len(containerInfo.Aliases) > 0 {
 		containerInfo.Name = containerInfo.Aliases[0]
 	}
@@ -138,7 +276,34 @@ func (self *KubeletClient) getScheme() string {
 	}
 }
 
+// setNodeToken sets the bearer token of the node on the request, read from the file
+// named after the node in the token directory on every request, so that rotated tokens
+// are picked up. Nodes without a token file are sent the shared token of the config.
+func (self *KubeletClient) setNodeToken(req *http.Request, host Host) error {
+	if self.config == nil || self.config.NodeTokenDir == "" || host.NodeName == "" {
+		return nil
+	}
+	// Node names can not contain path separators, but do not let one escape the directory.
+	if host.NodeName != filepath.Base(host.NodeName) || strings.HasPrefix(host.NodeName, ".") {
+		return fmt.Errorf("invalid node name %q", host.NodeName)
+	}
+	token, err := ioutil.ReadFile(filepath.Join(self.config.NodeTokenDir, host.NodeName))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read the token of node %q - %v", host.NodeName, err)
+	}
+	if token := strings.TrimSpace(string(token)); token != "" {
+		// The transport only sets the shared token on requests without an Authorization header.
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
 func (self *KubeletClient) getUrl(host Host, path string) string {
+	if self.config != nil && self.config.APIServerProxy != nil {
+		return getProxyUrl(self.config.APIServerProxy, host.NodeName, path)
+	}
 	url := url.URL{
 		Scheme: self.getScheme(),
 		Host:   host.String(),
@@ -148,11 +313,46 @@ func (self *KubeletClient) getUrl(host Host, path string) string {
 	return url.String()
 }
 
+// getCadvisorUrl returns the URL of the cadvisor path on the host, whose port is the
+// cadvisor port. cadvisor only serves plain HTTP.
+func (self *KubeletClient) getCadvisorUrl(host Host, path string) string {
+	if self.config != nil && self.config.APIServerProxy != nil {
+		return getProxyUrl(self.config.APIServerProxy, fmt.Sprintf("%s:%d", host.NodeName, host.Port), path)
+	}
+	url := url.URL{
+		Scheme: "http",
+		Host:   host.String(),
+		Path:   path,
+	}
+	return url.String()
+}
+
+// getProxyUrl returns the URL of the Kubelet path on the node proxy of the API server.
+func getProxyUrl(apiServer *url.URL, nodeName string, path string) string {
+	proxyUrl := *apiServer
+	proxyUrl.Path = strings.TrimSuffix(apiServer.Path, "/") + "/api/" + APIVersion + "/nodes/" + nodeName + "/proxy" + path
+	return proxyUrl.String()
+}
+
 // Get stats for all non-Kubernetes containers.
 func (self *KubeletClient) GetAllRawContainers(host Host, start, end time.Time) ([]cadvisor.ContainerInfo, error) {
 	url := self.getUrl(host, "/stats/container/")
 
-	return self.getAllContainers(url, start, end)
+	return self.getAllContainers(host, url, start, end)
+}
+
+// StreamAllRawContainers is like GetAllRawContainers, but passes the containers to
+// handle one by one as they are decoded from the response, so that the whole
+// response is never held in memory.
+// The statistics missing from the v1 API types are passed along in the extras. With the
+// v2 cadvisor API configured, the containers are requested from cadvisor instead.
+func (self *KubeletClient) StreamAllRawContainers(host Host, start, end time.Time, handle func(*cadvisor.ContainerInfo, *ContainerExtras)) error {
+	if self.config != nil && self.config.CadvisorApi == kubelet_client.CadvisorApiV2 {
+		return self.streamAllContainersV2(host, start, end, handle)
+	}
+	url := self.getUrl(host, "/stats/container/")
+
+	return self.streamAllContainers(host, url, start, end, handle)
 }
 
 func (self *KubeletClient) GetSummary(host Host) (*stats.Summary, error) {
@@ -162,6 +362,9 @@ func (self *KubeletClient) GetSummary(host Host) (*stats.Summary, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := self.setNodeToken(req, host); err != nil {
+		return nil, err
+	}
 	summary := &stats.Summary{}
 	client := self.client
 	if client == nil {
@@ -171,47 +374,175 @@ func (self *KubeletClient) GetSummary(host Host) (*stats.Summary, error) {
 	return summary, err
 }
 
+// HealthCheckEnabled returns whether the Kubelets are probed before they are scraped.
+func (self *KubeletClient) HealthCheckEnabled() bool {
+	return self.healthClient != nil
+}
+
+// CheckHealth sends a HEAD request to the /healthz endpoint of the Kubelet.
+func (self *KubeletClient) CheckHealth(host Host) error {
+	req, err := http.NewRequest("HEAD", self.getUrl(host, "/healthz"), nil)
+	if err != nil {
+		return err
+	}
+	if err := self.setNodeToken(req, host); err != nil {
+		return err
+	}
+	client := self.healthClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check failed - %q", response.Status)
+	}
+	return nil
+}
+
 func (self *KubeletClient) GetPort() int {
 	return int(self.config.Port)
 }
 
-func (self *KubeletClient) getAllContainers(url string, start, end time.Time) ([]cadvisor.ContainerInfo, error) {
+func (self *KubeletClient) getAllContainers(host Host, url string, start, end time.Time) ([]cadvisor.ContainerInfo, error) {
+	result := []cadvisor.ContainerInfo{}
+	err := self.streamAllContainers(host, url, start, end, func(containerInfo *cadvisor.ContainerInfo, _ *ContainerExtras) {
+		result = append(result, *containerInfo)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (self *KubeletClient) streamAllContainers(host Host, url string, start, end time.Time, handle func(*cadvisor.ContainerInfo, *ContainerExtras)) error {
 	// Request data from all subcontainers.
 	request := statsRequest{
 		ContainerName: "/",
-		NumStats:      1,
+		NumStats:      self.numStats(),
 		Start:         start,
 		End:           end,
 		Subcontainers: true,
 	}
 	body, err := jsoniter.ConfigFastest.Marshal(request)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if err := self.setNodeToken(req, host); err != nil {
+		return err
+	}
 
-	var containers map[string]cadvisor.ContainerInfo
 	client := self.client
 	if client == nil {
 		client = http.DefaultClient
 	}
-	err = self.postRequestAndGetValue(client, req, &containers)
+	err = self.postRequestAndStreamContainers(client, req, func(field string, raw []byte, skew *time.Duration) error {
+		var containerInfo cadvisor.ContainerInfo
+		if err := jsoniter.ConfigFastest.Unmarshal(raw, &containerInfo); err != nil {
+			return fmt.Errorf("failed to parse container %q - %v", field, err)
+		}
+		handle(self.parseStat(&containerInfo), &ContainerExtras{Schedstat: decodeSchedstat(raw), ClockSkew: skew})
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all container stats from Kubelet URL %q: %v", url, err)
+		return fmt.Errorf("failed to get all container stats from Kubelet URL %q: %v", url, err)
 	}
+	return nil
+}
 
-	result := make([]cadvisor.ContainerInfo, 0, len(containers))
-	for _, containerInfo := range containers {
-		cont := self.parseStat(&containerInfo)
-		if cont != nil {
-			result = append(result, *cont)
+// streamAllContainersV2 requests the containers from the v2 API of the cadvisor of the
+// node, which serves statistics missing from the v1 API of the kubelet.
+func (self *KubeletClient) streamAllContainersV2(host Host, start, end time.Time, handle func(*cadvisor.ContainerInfo, *ContainerExtras)) error {
+	cadvisorHost := host
+	cadvisorHost.Port = int(self.config.CadvisorPort)
+	query := url.Values{}
+	query.Set("type", "name")
+	query.Set("recursive", "true")
+	// The v2 API selects the samples by age rather than by time range.
+	query.Set("max_age", time.Since(start).String())
+	cadvisorUrl := self.getCadvisorUrl(cadvisorHost, "/api/v2.1/stats/") + "?" + query.Encode()
+
+	req, err := http.NewRequest("GET", cadvisorUrl, nil)
+	if err != nil {
+		return err
+	}
+	client := self.cadvisorClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	err = self.postRequestAndStreamContainers(client, req, func(field string, raw []byte, skew *time.Duration) error {
+		var containerInfo v2ContainerInfo
+		if err := jsoniter.ConfigFastest.Unmarshal(raw, &containerInfo); err != nil {
+			return fmt.Errorf("failed to parse container %q - %v", field, err)
 		}
+		v1Info, extras := containerInfo.toV1(field)
+		extras.Schedstat = decodeSchedstat(raw)
+		extras.ClockSkew = skew
+		handle(self.parseStat(v1Info), extras)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get all container stats from cadvisor URL %q: %v", cadvisorUrl, err)
 	}
-	return result, nil
+	return nil
+}
+
+// postRequestAndStreamContainers decodes the map of containers returned by the kubelet
+// one entry at a time and passes the raw JSON of every container to decode.
+func (self *KubeletClient) postRequestAndStreamContainers(client *http.Client, req *http.Request, decode func(field string, raw []byte, skew *time.Duration) error) error {
+	sent := time.Now()
+	response, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	skew := responseClockSkew(response, sent, time.Now())
+	reader, limited := self.responseBody(response)
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(reader)
+		if response.StatusCode == http.StatusNotFound {
+			return &ErrNotFound{req.URL.String()}
+		}
+		return fmt.Errorf("request failed - %q, response: %q", response.Status, string(body))
+	}
+
+	if glog.V(10) {
+		body, err := ioutil.ReadAll(reader)
+		if err := checkResponseSize(limited, req); err != nil {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read response body - %v", err)
+		}
+		glog.Infof("Raw response from Kubelet at %s: %s", req.URL.Host, string(body))
+		reader = bytes.NewReader(body)
+	}
+
+	iter := jsoniter.Parse(jsoniter.ConfigFastest, reader, streamBufferSize)
+	for field := iter.ReadObject(); field != "" && iter.Error == nil; field = iter.ReadObject() {
+		raw := iter.SkipAndReturnBytes()
+		if iter.Error != nil {
+			break
+		}
+		if err := decode(field, raw, skew); err != nil {
+			return err
+		}
+	}
+	if err := checkResponseSize(limited, req); err != nil {
+		return err
+	}
+	if iter.Error != nil && iter.Error != io.EOF {
+		return fmt.Errorf("failed to parse output - %v", iter.Error)
+	}
+	return nil
 }
 
 func NewKubeletClient(kubeletConfig *kubelet_client.KubeletClientConfig) (*KubeletClient, error) {
@@ -220,11 +551,34 @@ func NewKubeletClient(kubeletConfig *kubelet_client.KubeletClientConfig) (*Kubel
 		return nil, err
 	}
 	c := &http.Client{
-		Transport: transport,
+		Transport: newTracingTransport(transport),
 		Timeout:   kubeletConfig.HTTPTimeout,
 	}
+	var healthClient *http.Client
+	if kubeletConfig.HealthCheckTimeout > 0 {
+		healthClient = &http.Client{
+			Transport: c.Transport,
+			Timeout:   kubeletConfig.HealthCheckTimeout,
+		}
+	}
+	// cadvisor does not authenticate its clients and is reached with plain HTTP, so it must
+	// not be sent the kubelet credentials. Through the API server proxy, the requests are
+	// authenticated by the API server, with the credentials of the kubelet client.
+	cadvisorClient := c
+	if kubeletConfig.CadvisorApi == kubelet_client.CadvisorApiV2 && kubeletConfig.APIServerProxy == nil {
+		cadvisorTransport, err := kubelet_client.MakeTransport(kubeletConfig.WithoutCredentials())
+		if err != nil {
+			return nil, err
+		}
+		cadvisorClient = &http.Client{
+			Transport: newTracingTransport(cadvisorTransport),
+			Timeout:   kubeletConfig.HTTPTimeout,
+		}
+	}
 	return &KubeletClient{
-		config: kubeletConfig,
-		client: c,
+		config:         kubeletConfig,
+		client:         c,
+		healthClient:   healthClient,
+		cadvisorClient: cadvisorClient,
 	}, nil
 }
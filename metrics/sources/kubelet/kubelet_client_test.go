@@ -15,7 +15,17 @@
 package kubelet
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
 	"testing"
 	"time"
 
@@ -24,6 +34,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	util "k8s.io/client-go/util/testing"
+	kubelet_client "k8s.io/heapster/metrics/sources/kubelet/util"
 )
 
 func checkContainer(t *testing.T, expected cadvisor_api.ContainerInfo, actual cadvisor_api.ContainerInfo) {
@@ -96,9 +107,437 @@ func TestAllContainers(t *testing.T) {
 	server := httptest.NewServer(&handler)
 	defer server.Close()
 	kubeletClient := KubeletClient{}
-	containers, err := kubeletClient.getAllContainers(server.URL, time.Now(), time.Now().Add(time.Minute))
+	containers, err := kubeletClient.getAllContainers(Host{}, server.URL, time.Now(), time.Now().Add(time.Minute))
 	require.NoError(t, err)
 	require.Len(t, containers, 2)
 	checkContainer(t, rootContainer, containers[0])
 	checkContainer(t, subcontainer, containers[1])
 }
+
+func TestStreamAllContainers(t *testing.T) {
+	for _, tc := range []struct {
+		statusCode int
+		body       string
+		names      []string
+		waitTimes  []uint64
+		err        bool
+	}{
+		{
+			statusCode: 200,
+			body:       `{"/": {"name": "/", "aliases": [], "stats": [{}, {}]}, "/docker/abc": {"name": "/docker/abc", "aliases": ["k8s_c1"]}}`,
+			names:      []string{"/", "k8s_c1"},
+			waitTimes:  []uint64{},
+		},
+		{
+			statusCode: 200,
+			body: `{"/docker/abc": {"name": "/docker/abc", "stats": [{"cpu": {"schedstat": {"runqueue_time": 10}}}, ` +
+				`{"cpu": {"usage": {"total": 100}, "schedstat": {"run_time": 100, "runqueue_time": 25, "run_periods": 5}}}]}}`,
+			names:     []string{"/docker/abc"},
+			waitTimes: []uint64{25},
+		},
+		{
+			statusCode: 200,
+			body:       `{}`,
+			names:      []string{},
+			waitTimes:  []uint64{},
+		},
+		{
+			statusCode: 200,
+			body:       `{"/": {"name": "/"}, "/docker/abc": {"name": `,
+			names:      []string{"/"},
+			waitTimes:  []uint64{},
+			err:        true,
+		},
+		{
+			statusCode: 500,
+			body:       `internal error`,
+			names:      []string{},
+			waitTimes:  []uint64{},
+			err:        true,
+		},
+	} {
+		handler := util.FakeHandler{
+			StatusCode:   tc.statusCode,
+			ResponseBody: tc.body,
+			T:            t,
+		}
+		server := httptest.NewServer(&handler)
+		kubeletClient := KubeletClient{}
+		names := []string{}
+		waitTimes := []uint64{}
+		err := kubeletClient.streamAllContainers(Host{}, server.URL, time.Now(), time.Now().Add(time.Minute), func(containerInfo *cadvisor_api.ContainerInfo, extras *ContainerExtras) {
+			assert.True(t, len(containerInfo.Stats) <= 1)
+			names = append(names, containerInfo.Name)
+			if extras.Schedstat != nil {
+				waitTimes = append(waitTimes, extras.Schedstat.RunqueueTime)
+			}
+		})
+		server.Close()
+		assert.Equal(t, tc.err, err != nil, tc.body)
+		assert.Equal(t, tc.names, names, tc.body)
+		assert.Equal(t, tc.waitTimes, waitTimes, tc.body)
+	}
+}
+
+// BenchmarkAllContainers compares the peak heap usage of decoding a large response
+// at once and of decoding it one container at a time.
+func BenchmarkAllContainers(b *testing.B) {
+	response := make(map[string]cadvisor_api.ContainerInfo)
+	for i := 0; i < 5000; i++ {
+		name := fmt.Sprintf("/kubepods/pod%d/container%d", i, i)
+		response[name] = cadvisor_api.ContainerInfo{
+			ContainerReference: cadvisor_api.ContainerReference{
+				Name:    name,
+				Aliases: []string{fmt.Sprintf("k8s_container%d", i), fmt.Sprintf("%064d", i)},
+			},
+			Spec: cadvisor_api.ContainerSpec{
+				CreationTime: time.Now(),
+				HasCpu:       true,
+				HasMemory:    true,
+				Labels:       map[string]string{kubernetesPodNameLabel: fmt.Sprintf("pod%d", i)},
+			},
+			Stats: []*cadvisor_api.ContainerStats{
+				{
+					Timestamp: time.Now(),
+					Cpu:       cadvisor_api.CpuStats{Usage: cadvisor_api.CpuUsage{PerCpu: make([]uint64, 64)}},
+				},
+			},
+		}
+	}
+	data, err := jsoniter.ConfigFastest.Marshal(&response)
+	require.NoError(b, err)
+	response = nil
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+	kubeletClient := KubeletClient{}
+
+	// measurePeakHeap runs scrape and returns the largest heap size seen by sample,
+	// relative to the heap size before the scrape.
+	measurePeakHeap := func(scrape func(sample func())) float64 {
+		var stats runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		base, peak := stats.HeapAlloc, stats.HeapAlloc
+		count := 0
+		scrape(func() {
+			// Reading the memory stats stops the world, so only sample every 100 containers.
+			if count++; count%100 != 0 {
+				return
+			}
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > peak {
+				peak = stats.HeapAlloc
+			}
+		})
+		return float64(peak - base)
+	}
+
+	// Collect the garbage eagerly, so that the heap size is close to the live data.
+	defer debug.SetGCPercent(debug.SetGCPercent(1))
+
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		peak := float64(0)
+		for i := 0; i < b.N; i++ {
+			peak += measurePeakHeap(func(sample func()) {
+				// The response is read and decoded at once, as done before the streaming decoder.
+				resp, err := http.Post(server.URL, "application/json", nil)
+				require.NoError(b, err)
+				body, err := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				require.NoError(b, err)
+				var containers map[string]cadvisor_api.ContainerInfo
+				require.NoError(b, jsoniter.ConfigFastest.Unmarshal(body, &containers))
+				for _, containerInfo := range containers {
+					kubeletClient.parseStat(&containerInfo)
+					sample()
+				}
+			})
+		}
+		b.ReportMetric(peak/float64(b.N), "peak-heap-B/op")
+	})
+	b.Run("streaming", func(b *testing.B) {
+		b.ReportAllocs()
+		peak := float64(0)
+		for i := 0; i < b.N; i++ {
+			peak += measurePeakHeap(func(sample func()) {
+				err := kubeletClient.streamAllContainers(Host{}, server.URL, time.Now(), time.Now(), func(*cadvisor_api.ContainerInfo, *ContainerExtras) {
+					sample()
+				})
+				require.NoError(b, err)
+			})
+		}
+		b.ReportMetric(peak/float64(b.N), "peak-heap-B/op")
+	})
+}
+
+func TestAllStatsSamples(t *testing.T) {
+	now := time.Now()
+	container := cadvisor_api.ContainerInfo{
+		ContainerReference: cadvisor_api.ContainerReference{
+			Name: "/",
+		},
+		// cadvisor returns the samples oldest first.
+		Stats: []*cadvisor_api.ContainerStats{
+			{Timestamp: now.Add(-20 * time.Second)},
+			{Timestamp: now.Add(-10 * time.Second)},
+			{Timestamp: now},
+		},
+	}
+	var request statsRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		require.NoError(t, jsoniter.NewDecoder(req.Body).Decode(&request))
+		body, err := jsoniter.Marshal(map[string]cadvisor_api.ContainerInfo{"/": container})
+		require.NoError(t, err)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		allStatsSamples bool
+		numStats        int
+		timestamps      []time.Time
+	}{
+		{false, 1, []time.Time{now}},
+		{true, -1, []time.Time{now.Add(-20 * time.Second), now.Add(-10 * time.Second), now}},
+	} {
+		kubeletClient := KubeletClient{
+			config: &kubelet_client.KubeletClientConfig{AllStatsSamples: tc.allStatsSamples},
+			client: http.DefaultClient,
+		}
+		var timestamps []time.Time
+		err := kubeletClient.streamAllContainers(Host{}, server.URL, now.Add(-time.Minute), now, func(c *cadvisor_api.ContainerInfo, _ *ContainerExtras) {
+			for _, stats := range c.Stats {
+				timestamps = append(timestamps, stats.Timestamp)
+			}
+		})
+		require.NoError(t, err)
+		assert.Equal(t, tc.numStats, request.NumStats)
+		require.Len(t, timestamps, len(tc.timestamps))
+		for i, timestamp := range tc.timestamps {
+			assert.True(t, timestamp.Equal(timestamps[i]), "sample %d: %v != %v", i, timestamp, timestamps[i])
+		}
+	}
+}
+
+func TestResponseClockSkew(t *testing.T) {
+	var date string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if date != "" {
+			w.Header().Set("Date", date)
+		}
+		w.Write([]byte(`{"/": {"name": "/", "stats": [{"timestamp": "2017-01-01T00:00:00Z"}]}}`))
+	}))
+	defer server.Close()
+	kubeletClient := KubeletClient{client: http.DefaultClient}
+	skewOf := func() *time.Duration {
+		var skew *time.Duration
+		err := kubeletClient.streamAllContainers(Host{}, server.URL, time.Now(), time.Now(), func(_ *cadvisor_api.ContainerInfo, extras *ContainerExtras) {
+			skew = extras.ClockSkew
+		})
+		require.NoError(t, err)
+		return skew
+	}
+
+	// The server sets the Date header from its own clock.
+	if skew := skewOf(); assert.NotNil(t, skew) {
+		assert.True(t, *skew < 2*time.Second && *skew > -2*time.Second, "skew %v", *skew)
+	}
+	// A node clock an hour ahead, whatever the age of its samples.
+	date = time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if skew := skewOf(); assert.NotNil(t, skew) {
+		assert.InDelta(t, time.Hour.Seconds(), skew.Seconds(), 2)
+	}
+	date = "invalid"
+	assert.Nil(t, skewOf())
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	body := `{"/": {"name": "/"}, "/docker/abc": {"name": "/docker/abc"}}`
+	for _, tc := range []struct {
+		maxResponseBytes int64
+		err              bool
+	}{
+		{maxResponseBytes: 0},
+		{maxResponseBytes: int64(len(body))},
+		{maxResponseBytes: int64(len(body)) - 1, err: true},
+		{maxResponseBytes: 10, err: true},
+	} {
+		handler := util.FakeHandler{
+			StatusCode:   200,
+			ResponseBody: body,
+			T:            t,
+		}
+		server := httptest.NewServer(&handler)
+		kubeletClient := KubeletClient{
+			config: &kubelet_client.KubeletClientConfig{MaxResponseBytes: tc.maxResponseBytes},
+		}
+
+		containers, err := kubeletClient.getAllContainers(Host{}, server.URL, time.Now(), time.Now().Add(time.Minute))
+		if tc.err {
+			assert.Error(t, err, "max %d", tc.maxResponseBytes)
+			assert.Contains(t, err.Error(), "exceeds the maximum size")
+		} else {
+			assert.NoError(t, err, "max %d", tc.maxResponseBytes)
+			assert.Len(t, containers, 2)
+		}
+
+		req, err := http.NewRequest("GET", server.URL, nil)
+		require.NoError(t, err)
+		var value map[string]interface{}
+		err = kubeletClient.postRequestAndGetValue(http.DefaultClient, req, &value)
+		if tc.err {
+			assert.Error(t, err, "max %d", tc.maxResponseBytes)
+		} else {
+			assert.NoError(t, err, "max %d", tc.maxResponseBytes)
+			assert.Len(t, value, 2)
+		}
+		server.Close()
+	}
+}
+
+func TestGetProxyUrl(t *testing.T) {
+	for _, tc := range []struct {
+		apiServer string
+		path      string
+		expected  string
+	}{
+		{"https://10.0.0.1:443", "/stats/summary/", "https://10.0.0.1:443/api/v1/nodes/node-1/proxy/stats/summary/"},
+		{"https://master", "/stats/container/", "https://master/api/v1/nodes/node-1/proxy/stats/container/"},
+		// The path of the API server address is a prefix of all the API paths.
+		{"https://lb.example.com/cluster-a/", "/stats/summary/", "https://lb.example.com/cluster-a/api/v1/nodes/node-1/proxy/stats/summary/"},
+	} {
+		apiServer, err := url.Parse(tc.apiServer)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, getProxyUrl(apiServer, "node-1", tc.path))
+	}
+}
+
+func TestGetSummaryThroughAPIServerProxy(t *testing.T) {
+	var requestPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requestPath = req.URL.Path
+		w.Write([]byte(`{"node": {"nodeName": "node-1"}}`))
+	}))
+	defer server.Close()
+	apiServer, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	kubeletClient := KubeletClient{
+		config: &kubelet_client.KubeletClientConfig{Port: 10255, APIServerProxy: apiServer},
+	}
+
+	summary, err := kubeletClient.GetSummary(Host{IP: net.ParseIP("10.1.2.3"), Port: 10255, NodeName: "node-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "node-1", summary.Node.NodeName)
+	assert.Equal(t, "/api/v1/nodes/node-1/proxy/stats/summary/", requestPath)
+}
+
+func TestGetKubeConfigsWithAPIServerProxy(t *testing.T) {
+	uri, err := url.Parse("https://master:6443?inClusterConfig=false&apiServerProxy=true&insecure=true")
+	require.NoError(t, err)
+	_, kubeletConfig, err := GetKubeConfigs(uri)
+	require.NoError(t, err)
+	require.NotNil(t, kubeletConfig.APIServerProxy)
+	assert.Equal(t, "https://master:6443", kubeletConfig.APIServerProxy.String())
+	assert.True(t, kubeletConfig.APIServerInsecure)
+
+	uri, err = url.Parse("https://master:6443?inClusterConfig=false")
+	require.NoError(t, err)
+	_, kubeletConfig, err = GetKubeConfigs(uri)
+	require.NoError(t, err)
+	assert.Nil(t, kubeletConfig.APIServerProxy)
+
+	uri, err = url.Parse("https://master:6443?inClusterConfig=false&apiServerProxy=maybe")
+	require.NoError(t, err)
+	_, _, err = GetKubeConfigs(uri)
+	assert.Error(t, err)
+}
+
+func TestGetKubeConfigsWithHealthCheck(t *testing.T) {
+	uri, err := url.Parse("https://master:6443?inClusterConfig=false&kubeletHealthCheckTimeout=2s")
+	require.NoError(t, err)
+	_, kubeletConfig, err := GetKubeConfigs(uri)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, kubeletConfig.HealthCheckTimeout)
+
+	for _, timeout := range []string{"fast", "-1s"} {
+		uri, err = url.Parse("https://master:6443?inClusterConfig=false&kubeletHealthCheckTimeout=" + timeout)
+		require.NoError(t, err)
+		_, _, err = GetKubeConfigs(uri)
+		assert.Error(t, err, timeout)
+	}
+}
+
+func TestPerNodeTokens(t *testing.T) {
+	tokenDir, err := ioutil.TempDir("", "kubelet-tokens")
+	require.NoError(t, err)
+	defer os.RemoveAll(tokenDir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tokenDir, "node-1"), []byte("token-1\n"), 0600))
+
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorization = req.Header.Get("Authorization")
+		w.Write([]byte(`{"node": {"nodeName": "node"}}`))
+	}))
+	defer server.Close()
+	serverUrl, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(serverUrl.Port())
+	require.NoError(t, err)
+
+	kubeletClient, err := NewKubeletClient(&kubelet_client.KubeletClientConfig{
+		BearerToken:  "shared",
+		NodeTokenDir: tokenDir,
+	})
+	require.NoError(t, err)
+	host := func(nodeName string) Host {
+		return Host{IP: net.ParseIP("127.0.0.1"), Port: port, NodeName: nodeName}
+	}
+
+	_, err = kubeletClient.GetSummary(host("node-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer token-1", authorization)
+
+	// The token file is read on every request.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tokenDir, "node-1"), []byte("token-2"), 0600))
+	kubeletClient.StreamAllRawContainers(host("node-1"), time.Now(), time.Now(), func(*cadvisor_api.ContainerInfo, *ContainerExtras) {})
+	assert.Equal(t, "Bearer token-2", authorization)
+
+	// Nodes without a token file get the shared token.
+	_, err = kubeletClient.GetSummary(host("node-2"))
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer shared", authorization)
+
+	_, err = kubeletClient.GetSummary(host("../node-1"))
+	assert.Error(t, err)
+}
+
+func TestGetKubeConfigsWithTokenDir(t *testing.T) {
+	tokenDir, err := ioutil.TempDir("", "kubelet-tokens")
+	require.NoError(t, err)
+	defer os.RemoveAll(tokenDir)
+
+	uri, err := url.Parse("https://master:6443?inClusterConfig=false&kubeletTokenDir=" + tokenDir)
+	require.NoError(t, err)
+	_, kubeletConfig, err := GetKubeConfigs(uri)
+	require.NoError(t, err)
+	assert.Equal(t, tokenDir, kubeletConfig.NodeTokenDir)
+
+	uri, err = url.Parse("https://master:6443?inClusterConfig=false")
+	require.NoError(t, err)
+	_, kubeletConfig, err = GetKubeConfigs(uri)
+	require.NoError(t, err)
+	assert.Empty(t, kubeletConfig.NodeTokenDir)
+
+	for _, query := range []string{
+		"kubeletTokenDir=" + filepath.Join(tokenDir, "missing"),
+		"kubeletTokenDir=" + tokenDir + "&apiServerProxy=true",
+	} {
+		uri, err = url.Parse("https://master:6443?inClusterConfig=false&" + query)
+		require.NoError(t, err)
+		_, _, err = GetKubeConfigs(uri)
+		assert.Error(t, err, query)
+	}
+}
@@ -14,8 +14,11 @@
 package kubelet
 
 import (
+	"fmt"
 	"net"
+	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
@@ -23,12 +26,16 @@ import (
 
 	cadvisor_api "github.com/google/cadvisor/info/v1"
 	jsoniter "github.com/json-iterator/go"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
 	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
 	util "k8s.io/client-go/util/testing"
 	"k8s.io/heapster/metrics/core"
+	kubelet_client "k8s.io/heapster/metrics/sources/kubelet/util"
 )
 
 func TestDecodeMetrics1(t *testing.T) {
@@ -298,6 +305,357 @@ func TestDecodeMetrics6(t *testing.T) {
 	assert.Equal(t, metricSet.Labels[core.LabelMetricSetType.Key], core.MetricSetTypeNode)
 }
 
+func TestDecodeMetricsClockSkew(t *testing.T) {
+	kMS := kubeletMetricsSource{
+		nodename:     "test",
+		hostname:     "test-hostname",
+		maxClockSkew: 5 * time.Minute,
+	}
+	// The samples are up to a scrape interval old whatever the clock of the node.
+	container := func(name string) *cadvisor_api.ContainerInfo {
+		return &cadvisor_api.ContainerInfo{
+			ContainerReference: cadvisor_api.ContainerReference{
+				Name: name,
+			},
+			Spec: cadvisor_api.ContainerSpec{
+				CreationTime: time.Now().Add(-time.Hour),
+			},
+			Stats: []*cadvisor_api.ContainerStats{
+				{
+					Timestamp: time.Now().Add(-time.Minute),
+				},
+			},
+		}
+	}
+	extras := func(skew time.Duration) *ContainerExtras {
+		return &ContainerExtras{ClockSkew: &skew}
+	}
+
+	// In-sync node.
+	decoded := kMS.decodeContainer(0, container("/"), extras(-time.Second))
+	assert.Equal(t, "node:test", decoded.name)
+	if skew, found := decoded.metrics.MetricValues[core.MetricNodeClockSkew.Name]; assert.True(t, found) {
+		assert.Equal(t, float32(-1), skew.FloatValue)
+	}
+	decoded = kMS.decodeContainer(0, container("/docker-daemon"), extras(-time.Second))
+	assert.NotNil(t, decoded.metrics)
+	_, hasSkew := decoded.metrics.MetricValues[core.MetricNodeClockSkew.Name]
+	assert.False(t, hasSkew)
+
+	// Skewed node, in both directions.
+	for _, skew := range []time.Duration{time.Hour, -time.Hour} {
+		for _, name := range []string{"/", "/docker-daemon"} {
+			decoded = kMS.decodeContainer(0, container(name), extras(skew))
+			assert.Equal(t, "", decoded.name)
+			assert.Nil(t, decoded.metrics)
+		}
+	}
+
+	// Unknown skew.
+	decoded = kMS.decodeContainer(0, container("/"), &ContainerExtras{})
+	assert.Equal(t, "node:test", decoded.name)
+	_, hasSkew = decoded.metrics.MetricValues[core.MetricNodeClockSkew.Name]
+	assert.False(t, hasSkew)
+
+	// Without the limit the skewed samples are kept and the skew is reported.
+	kMS.maxClockSkew = 0
+	decoded = kMS.decodeContainer(0, container("/"), extras(time.Hour))
+	assert.Equal(t, "node:test", decoded.name)
+	if skew, found := decoded.metrics.MetricValues[core.MetricNodeClockSkew.Name]; assert.True(t, found) {
+		assert.Equal(t, float32(3600), skew.FloatValue)
+	}
+}
+
+func TestDecodeMemoryBreakdown(t *testing.T) {
+	kMS := kubeletMetricsSource{
+		nodename: "test",
+		hostname: "test-hostname",
+	}
+	c1 := cadvisor_api.ContainerInfo{
+		ContainerReference: cadvisor_api.ContainerReference{
+			Name: "k8s_test.testkubelet",
+		},
+		Spec: cadvisor_api.ContainerSpec{
+			CreationTime: time.Now(),
+			HasMemory:    true,
+			Labels: map[string]string{
+				kubernetesContainerLabel: "test",
+				kubernetesPodNameLabel:   "testnamespace/testPodName",
+			},
+		},
+		Stats: []*cadvisor_api.ContainerStats{
+			{
+				Timestamp: time.Now(),
+				Memory: cadvisor_api.MemoryStats{
+					Usage: 700,
+					Cache: 400,
+					RSS:   250,
+					Swap:  50,
+				},
+			},
+		},
+	}
+	_, metricSet := kMS.decodeMetrics(&c1)
+	assert.Equal(t, int64(250), metricSet.MetricValues[core.MetricMemoryRSS.Name].IntValue)
+	assert.Equal(t, int64(400), metricSet.MetricValues[core.MetricMemoryCache.Name].IntValue)
+	assert.Equal(t, int64(50), metricSet.MetricValues[core.MetricMemorySwap.Name].IntValue)
+}
+
+func TestDecodeSchedstatMetrics(t *testing.T) {
+	schedstat := &CpuSchedstat{RunTime: 1000, RunqueueTime: 250, RunPeriods: 10}
+	for _, tc := range []struct {
+		metricSetType string
+		expected      bool
+	}{
+		{core.MetricSetTypePodContainer, true},
+		{core.MetricSetTypeSystemContainer, true},
+		{core.MetricSetTypeNode, false},
+	} {
+		metricSet := &core.MetricSet{
+			Labels:       map[string]string{core.LabelMetricSetType.Key: tc.metricSetType},
+			MetricValues: map[string]core.MetricValue{},
+		}
+		decodeSchedstatMetrics(metricSet, schedstat)
+		value, found := metricSet.MetricValues[core.MetricContainerCpuWaitTime.Name]
+		if assert.Equal(t, tc.expected, found, tc.metricSetType) && found {
+			assert.Equal(t, core.MetricCumulative, value.MetricType)
+			assert.Equal(t, int64(250), value.IntValue)
+		}
+	}
+}
+
+func TestDecodeCpuThrottlingMetrics(t *testing.T) {
+	stat := &cadvisor_api.ContainerStats{
+		Cpu: cadvisor_api.CpuStats{
+			CFS: cadvisor_api.CpuCFS{Periods: 100, ThrottledPeriods: 12, ThrottledTime: 5000},
+		},
+	}
+	for _, tc := range []struct {
+		metricSetType string
+		expected      bool
+	}{
+		{core.MetricSetTypePodContainer, true},
+		{core.MetricSetTypeSystemContainer, true},
+		{core.MetricSetTypePod, false},
+		{core.MetricSetTypeNode, false},
+	} {
+		metricSet := &core.MetricSet{
+			Labels:       map[string]string{core.LabelMetricSetType.Key: tc.metricSetType},
+			MetricValues: map[string]core.MetricValue{},
+		}
+		decodeCpuThrottlingMetrics(stat, metricSet)
+		value, found := metricSet.MetricValues[core.MetricContainerCpuThrottledPeriods.Name]
+		if assert.Equal(t, tc.expected, found, tc.metricSetType) && found {
+			assert.Equal(t, core.MetricCumulative, value.MetricType)
+			assert.Equal(t, int64(12), value.IntValue)
+		}
+	}
+}
+
+func TestDecodeTcpMetrics(t *testing.T) {
+	kMS := kubeletMetricsSource{
+		nodename: "test",
+		hostname: "test-hostname",
+	}
+	c1 := cadvisor_api.ContainerInfo{
+		ContainerReference: cadvisor_api.ContainerReference{
+			Name: "testKubelet",
+		},
+		Spec: cadvisor_api.ContainerSpec{
+			CreationTime: time.Now(),
+			HasNetwork:   true,
+			Labels: map[string]string{
+				kubernetesContainerLabel:    infraContainerName,
+				kubernetesPodNamespaceLabel: "testPodNS",
+				kubernetesPodNameLabel:      "testPodName",
+			},
+		},
+		Stats: []*cadvisor_api.ContainerStats{
+			{
+				Timestamp: time.Now(),
+				Network: cadvisor_api.NetworkStats{
+					Tcp:  cadvisor_api.TcpStat{Established: 10, TimeWait: 5, Listen: 2},
+					Tcp6: cadvisor_api.TcpStat{Established: 3, SynSent: 1, Listen: 1},
+				},
+			},
+		},
+	}
+	metricSetKey, metricSet := kMS.decodeMetrics(&c1)
+	assert.Equal(t, "namespace:testPodNS/pod:testPodName", metricSetKey)
+	assert.Equal(t, int64(19), metricSet.MetricValues[core.MetricPodNetworkTcpConnections.Name].IntValue)
+
+	// Only the infra container reports the connections of the pod network.
+	c1.Spec.Labels[kubernetesContainerLabel] = "testContainer"
+	_, metricSet = kMS.decodeMetrics(&c1)
+	_, found := metricSet.MetricValues[core.MetricPodNetworkTcpConnections.Name]
+	assert.False(t, found)
+}
+
+func TestDecodeAllStatsSamples(t *testing.T) {
+	now := time.Now()
+	memoryStats := func(timestamp time.Time, usage uint64) *cadvisor_api.ContainerStats {
+		return &cadvisor_api.ContainerStats{
+			Timestamp: timestamp,
+			Memory:    cadvisor_api.MemoryStats{Usage: usage},
+		}
+	}
+	c1 := cadvisor_api.ContainerInfo{
+		ContainerReference: cadvisor_api.ContainerReference{
+			Name: "testKubelet",
+		},
+		Spec: cadvisor_api.ContainerSpec{
+			CreationTime: now.Add(-time.Hour),
+			HasMemory:    true,
+			Labels: map[string]string{
+				kubernetesContainerLabel:    "testContainer",
+				kubernetesPodNamespaceLabel: "testPodNS",
+				kubernetesPodNameLabel:      "testPodName",
+			},
+		},
+		// cadvisor returns the samples oldest first.
+		Stats: []*cadvisor_api.ContainerStats{
+			memoryStats(now.Add(-30*time.Second), 100),
+			memoryStats(now.Add(-20*time.Second), 200),
+			memoryStats(now.Add(-10*time.Second), 300),
+			memoryStats(now, 400),
+		},
+	}
+
+	// By default only the latest sample is decoded.
+	kMS := kubeletMetricsSource{
+		nodename: "test",
+		hostname: "test-hostname",
+	}
+	_, metricSet := kMS.decodeMetrics(&c1)
+	assert.Equal(t, int64(400), metricSet.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	assert.Empty(t, metricSet.Samples)
+
+	kMS.allStatsSamples = true
+	_, metricSet = kMS.decodeMetrics(&c1)
+	assert.Equal(t, now, metricSet.ScrapeTime)
+	assert.Equal(t, int64(400), metricSet.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	require.Len(t, metricSet.Samples, 3)
+	for i, expected := range []struct {
+		timestamp time.Time
+		usage     int64
+	}{
+		{now.Add(-30 * time.Second), 100},
+		{now.Add(-20 * time.Second), 200},
+		{now.Add(-10 * time.Second), 300},
+	} {
+		sample := metricSet.Samples[i]
+		assert.Equal(t, expected.timestamp, sample.Timestamp)
+		assert.Equal(t, expected.usage, sample.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+	}
+}
+
+func TestDecodeNodeFilesystems(t *testing.T) {
+	kMS := kubeletMetricsSource{
+		nodename: "test",
+		hostname: "test-hostname",
+	}
+	for _, tc := range []struct {
+		name         string
+		rootFsDevice string
+		filesystems  []cadvisor_api.FsStats
+		expected     map[string]int64
+	}{{
+		name: "shared filesystem",
+		filesystems: []cadvisor_api.FsStats{
+			{Device: "/dev/sda1", Type: "vfs", Usage: 10, Limit: 100},
+		},
+		expected: map[string]int64{
+			core.MetricNodeFsUsage.Name:      10,
+			core.MetricNodeFsLimit.Name:      100,
+			core.MetricNodeImageFsUsage.Name: 10,
+			core.MetricNodeImageFsLimit.Name: 100,
+		},
+	}, {
+		name: "devicemapper image filesystem",
+		filesystems: []cadvisor_api.FsStats{
+			{Device: "docker-thinpool", Type: "devicemapper", Usage: 20, Limit: 200},
+			{Device: "/dev/sda1", Type: "vfs", Usage: 10, Limit: 100},
+		},
+		expected: map[string]int64{
+			core.MetricNodeFsUsage.Name:      10,
+			core.MetricNodeFsLimit.Name:      100,
+			core.MetricNodeImageFsUsage.Name: 20,
+			core.MetricNodeImageFsLimit.Name: 200,
+		},
+	}, {
+		name: "ambiguous filesystems",
+		filesystems: []cadvisor_api.FsStats{
+			{Device: "/dev/sda1", Type: "vfs", Usage: 10, Limit: 100},
+			{Device: "/dev/sdb1", Type: "vfs", Usage: 20, Limit: 200},
+		},
+		expected: map[string]int64{},
+	}, {
+		name:         "root filesystem device",
+		rootFsDevice: "^/dev/sda1$",
+		filesystems: []cadvisor_api.FsStats{
+			{Device: "/dev/sdb1", Type: "vfs", Usage: 20, Limit: 200},
+			{Device: "/dev/sda1", Type: "vfs", Usage: 10, Limit: 100},
+		},
+		expected: map[string]int64{
+			core.MetricNodeFsUsage.Name: 10,
+			core.MetricNodeFsLimit.Name: 100,
+		},
+	}, {
+		name:         "root filesystem device and devicemapper image filesystem",
+		rootFsDevice: "^/dev/sda1$",
+		filesystems: []cadvisor_api.FsStats{
+			{Device: "docker-thinpool", Type: "devicemapper", Usage: 20, Limit: 200},
+			{Device: "/dev/sda1", Type: "vfs", Usage: 10, Limit: 100},
+			{Device: "/dev/sdb1", Type: "vfs", Usage: 30, Limit: 300},
+		},
+		expected: map[string]int64{
+			core.MetricNodeFsUsage.Name:      10,
+			core.MetricNodeFsLimit.Name:      100,
+			core.MetricNodeImageFsUsage.Name: 20,
+			core.MetricNodeImageFsLimit.Name: 200,
+		},
+	}, {
+		name:         "unmatched root filesystem device",
+		rootFsDevice: "^/dev/nvme0n1p1$",
+		filesystems: []cadvisor_api.FsStats{
+			{Device: "docker-thinpool", Type: "devicemapper", Usage: 20, Limit: 200},
+			{Device: "/dev/sda1", Type: "vfs", Usage: 10, Limit: 100},
+		},
+		expected: map[string]int64{
+			core.MetricNodeImageFsUsage.Name: 20,
+			core.MetricNodeImageFsLimit.Name: 200,
+		},
+	}} {
+		kMS.rootFsDevice = nil
+		if tc.rootFsDevice != "" {
+			kMS.rootFsDevice = regexp.MustCompile(tc.rootFsDevice)
+		}
+		c := cadvisor_api.ContainerInfo{
+			ContainerReference: cadvisor_api.ContainerReference{
+				Name: "/",
+			},
+			Spec: cadvisor_api.ContainerSpec{
+				CreationTime:  time.Now(),
+				HasFilesystem: true,
+			},
+			Stats: []*cadvisor_api.ContainerStats{
+				{
+					Timestamp:  time.Now(),
+					Filesystem: tc.filesystems,
+				},
+			},
+		}
+		_, metricSet := kMS.decodeMetrics(&c)
+		for _, metric := range core.NodeFilesystemMetrics {
+			value, found := metricSet.MetricValues[metric.Name]
+			expected, expectedFound := tc.expected[metric.Name]
+			if assert.Equal(t, expectedFound, found, "%s: %s", tc.name, metric.Name) && found {
+				assert.Equal(t, expected, value.IntValue, "%s: %s", tc.name, metric.Name)
+			}
+		}
+	}
+}
+
 var nodes = []kube_api.Node{
 	{
 		ObjectMeta: metav1.ObjectMeta{
@@ -401,6 +759,48 @@ var nodes = []kube_api.Node{
 	},
 }
 
+func TestContainerRuntimeLabel(t *testing.T) {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	store.Add(&kube_api.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "testNode",
+		},
+		Status: kube_api.NodeStatus{
+			Addresses: []kube_api.NodeAddress{
+				{
+					Type:    kube_api.NodeInternalIP,
+					Address: "127.0.0.1",
+				},
+			},
+			NodeInfo: kube_api.NodeSystemInfo{
+				ContainerRuntimeVersion: "docker://1.13.1",
+			},
+		},
+	})
+	provider := &kubeletProvider{
+		nodeLister: v1listers.NewNodeLister(store),
+		kubeletClient: &KubeletClient{
+			config: &kubelet_client.KubeletClientConfig{Port: 10255},
+		},
+	}
+
+	sources := provider.GetMetricsSources()
+	require.Equal(t, 1, len(sources))
+	source := sources[0].(*kubeletMetricsSource)
+	_, metricSet := source.decodeMetrics(&cadvisor_api.ContainerInfo{
+		ContainerReference: cadvisor_api.ContainerReference{
+			Name: "/",
+		},
+		Stats: []*cadvisor_api.ContainerStats{
+			{
+				Timestamp: time.Now(),
+			},
+		},
+	})
+	assert.Equal(t, core.MetricSetTypeNode, metricSet.Labels[core.LabelMetricSetType.Key])
+	assert.Equal(t, "docker://1.13.1", metricSet.Labels[core.LabelContainerRuntime.Key])
+}
+
 func TestGetNodeHostnameAndIP(t *testing.T) {
 	for _, node := range nodes {
 		hostname, ip, err := GetNodeHostnameAndIP(&node)
@@ -522,3 +922,188 @@ func TestGetNodeSchedulableStatus(t *testing.T) {
 		}
 	}
 }
+
+func TestScrapeMetricsDuplicateContainers(t *testing.T) {
+	older := time.Now().Add(-10 * time.Second).Round(time.Second)
+	newer := older.Add(5 * time.Second)
+	container := func(name string, timestamp time.Time, usage uint64) cadvisor_api.ContainerInfo {
+		return cadvisor_api.ContainerInfo{
+			ContainerReference: cadvisor_api.ContainerReference{
+				Name: name,
+			},
+			Spec: cadvisor_api.ContainerSpec{
+				CreationTime: older.Add(-time.Hour),
+				HasMemory:    true,
+				Labels: map[string]string{
+					kubernetesPodNameLabel:      "pod1",
+					kubernetesPodNamespaceLabel: "ns1",
+					kubernetesContainerLabel:    "c1",
+				},
+			},
+			Stats: []*cadvisor_api.ContainerStats{
+				{
+					Timestamp: timestamp,
+					Memory: cadvisor_api.MemoryStats{
+						Usage: usage,
+					},
+				},
+			},
+		}
+	}
+	// The same container under two cgroup paths, with the newer sample listed in either order.
+	for _, decodeWorkers := range []int{1, 4} {
+		for _, response := range []map[string]cadvisor_api.ContainerInfo{
+			{
+				"/docker/abc":                    container("/docker/abc", older, 100),
+				"/system.slice/docker-abc.scope": container("/system.slice/docker-abc.scope", newer, 200),
+			},
+			{
+				"/docker/abc":                    container("/docker/abc", newer, 200),
+				"/system.slice/docker-abc.scope": container("/system.slice/docker-abc.scope", older, 100),
+			},
+		} {
+			data, err := jsoniter.ConfigFastest.Marshal(&response)
+			require.NoError(t, err)
+			handler := util.FakeHandler{
+				StatusCode:   200,
+				RequestBody:  "",
+				ResponseBody: string(data),
+				T:            t,
+			}
+			server := httptest.NewServer(&handler)
+
+			mtrcSrc := kubeletMetricsSource{
+				kubeletClient: &KubeletClient{},
+				hostname:      "duplicate-host",
+				decodeWorkers: decodeWorkers,
+			}
+			split := strings.SplitN(strings.Replace(server.URL, "http://", "", 1), ":", 2)
+			mtrcSrc.host.IP = net.ParseIP(split[0])
+			mtrcSrc.host.Port, err = strconv.Atoi(split[1])
+
+			res, err := mtrcSrc.ScrapeMetrics(time.Now(), time.Now().Add(5*time.Second))
+			server.Close()
+			assert.NoError(t, err)
+			assert.Equal(t, 1, len(res.MetricSets))
+			metricSet := res.MetricSets[core.PodContainerKey("ns1", "pod1", "c1")]
+			if assert.NotNil(t, metricSet) {
+				assert.True(t, newer.Equal(metricSet.ScrapeTime))
+				assert.Equal(t, int64(200), metricSet.MetricValues[core.MetricMemoryUsage.Name].IntValue)
+			}
+		}
+	}
+
+	duplicates := &dto.Metric{}
+	require.NoError(t, kubeletDuplicateContainers.WithLabelValues("duplicate-host").Write(duplicates))
+	assert.Equal(t, float64(4), duplicates.GetCounter().GetValue())
+}
+
+// largeContainerResponse returns a kubelet response with the given number of pod
+// containers. Every tenth container is also reported under a second cgroup path, with the
+// same timestamp and a different memory usage.
+func largeContainerResponse(t testing.TB, containers int) []byte {
+	timestamp := time.Now().Round(time.Second)
+	response := make(map[string]cadvisor_api.ContainerInfo)
+	for i := 0; i < containers; i++ {
+		container := cadvisor_api.ContainerInfo{
+			Spec: cadvisor_api.ContainerSpec{
+				CreationTime: timestamp.Add(-time.Hour),
+				HasCpu:       true,
+				HasMemory:    true,
+				HasNetwork:   true,
+				Labels: map[string]string{
+					kubernetesPodNameLabel:      fmt.Sprintf("pod%d", i),
+					kubernetesPodNamespaceLabel: "ns1",
+					kubernetesContainerLabel:    "c1",
+				},
+			},
+			Stats: []*cadvisor_api.ContainerStats{
+				{
+					Timestamp: timestamp,
+					Cpu:       cadvisor_api.CpuStats{Usage: cadvisor_api.CpuUsage{Total: uint64(i), PerCpu: make([]uint64, 64)}},
+					Memory:    cadvisor_api.MemoryStats{Usage: uint64(i)},
+				},
+			},
+		}
+		container.Name = fmt.Sprintf("/kubepods/pod%d/c1", i)
+		response[container.Name] = container
+		if i%10 == 0 {
+			duplicate := container
+			duplicate.Name = fmt.Sprintf("/system.slice/pod%d-c1.scope", i)
+			duplicate.Stats = []*cadvisor_api.ContainerStats{{Timestamp: timestamp, Memory: cadvisor_api.MemoryStats{Usage: uint64(i + 1)}}}
+			response[duplicate.Name] = duplicate
+		}
+	}
+	data, err := jsoniter.ConfigFastest.Marshal(&response)
+	require.NoError(t, err)
+	return data
+}
+
+func scrapeSource(t testing.TB, serverUrl string, decodeWorkers int) *kubeletMetricsSource {
+	source := &kubeletMetricsSource{
+		kubeletClient: &KubeletClient{},
+		hostname:      "parallel-host",
+		decodeWorkers: decodeWorkers,
+	}
+	split := strings.SplitN(strings.Replace(serverUrl, "http://", "", 1), ":", 2)
+	source.host.IP = net.ParseIP(split[0])
+	port, err := strconv.Atoi(split[1])
+	require.NoError(t, err)
+	source.host.Port = port
+	return source
+}
+
+func TestScrapeMetricsInParallel(t *testing.T) {
+	data := largeContainerResponse(t, 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	// memoryUsage returns the memory usage of the containers scraped with the given number
+	// of workers, which tells the duplicates apart.
+	memoryUsage := func(decodeWorkers int) map[string]int64 {
+		batch, err := scrapeSource(t, server.URL, decodeWorkers).ScrapeMetrics(time.Now(), time.Now())
+		require.NoError(t, err)
+		usage := make(map[string]int64, len(batch.MetricSets))
+		for key, metricSet := range batch.MetricSets {
+			usage[key] = metricSet.MetricValues[core.MetricMemoryUsage.Name].IntValue
+		}
+		return usage
+	}
+	serial := memoryUsage(1)
+	require.Len(t, serial, 1000)
+	for _, decodeWorkers := range []int{2, 8} {
+		// The duplicates are resolved the same way, whatever the order they are decoded in.
+		assert.Equal(t, serial, memoryUsage(decodeWorkers), "%d workers", decodeWorkers)
+	}
+}
+
+func benchmarkScrapeMetrics(b *testing.B, decodeWorkers int) {
+	data := largeContainerResponse(b, 5000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+	source := scrapeSource(b, server.URL, decodeWorkers)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := source.ScrapeMetrics(time.Now(), time.Now()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScrapeMetrics1Worker(b *testing.B) {
+	benchmarkScrapeMetrics(b, 1)
+}
+
+func BenchmarkScrapeMetrics4Workers(b *testing.B) {
+	benchmarkScrapeMetrics(b, 4)
+}
+
+func BenchmarkScrapeMetrics8Workers(b *testing.B) {
+	benchmarkScrapeMetrics(b, 8)
+}
@@ -0,0 +1,103 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	kube_api "k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	controlPlaneNodesInclude = "include"
+	controlPlaneNodesExclude = "exclude"
+)
+
+// Taints and labels marking control-plane nodes, used unless configured otherwise.
+var defaultControlPlaneKeys = []string{
+	"node-role.kubernetes.io/control-plane",
+	"node-role.kubernetes.io/master",
+}
+
+// NodeFilter decides which nodes are scraped. A node is a control-plane node if it
+// has a taint or a label with one of the configured keys.
+type NodeFilter struct {
+	ExcludeControlPlane bool
+	ControlPlaneTaints  []string
+	ControlPlaneLabels  []string
+}
+
+// GetNodeFilter parses the `controlPlaneNodes`, `controlPlaneTaints` and
+// `controlPlaneLabels` source options.
+func GetNodeFilter(uri *url.URL) (*NodeFilter, error) {
+	opts := uri.Query()
+	filter := &NodeFilter{
+		ControlPlaneTaints: defaultControlPlaneKeys,
+		ControlPlaneLabels: defaultControlPlaneKeys,
+	}
+	if len(opts["controlPlaneNodes"]) >= 1 {
+		switch opts["controlPlaneNodes"][0] {
+		case controlPlaneNodesInclude:
+		case controlPlaneNodesExclude:
+			filter.ExcludeControlPlane = true
+		default:
+			return nil, fmt.Errorf("unsupported `controlPlaneNodes` flag %q - must be one of %s or %s",
+				opts["controlPlaneNodes"][0], controlPlaneNodesInclude, controlPlaneNodesExclude)
+		}
+	}
+	if len(opts["controlPlaneTaints"]) >= 1 {
+		filter.ControlPlaneTaints = splitKeys(opts["controlPlaneTaints"][0])
+	}
+	if len(opts["controlPlaneLabels"]) >= 1 {
+		filter.ControlPlaneLabels = splitKeys(opts["controlPlaneLabels"][0])
+	}
+	return filter, nil
+}
+
+func splitKeys(value string) []string {
+	keys := []string{}
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Excludes returns true if the node should not be scraped.
+func (this *NodeFilter) Excludes(node *kube_api.Node) bool {
+	if this == nil || !this.ExcludeControlPlane {
+		return false
+	}
+	return this.isControlPlane(node)
+}
+
+func (this *NodeFilter) isControlPlane(node *kube_api.Node) bool {
+	for _, key := range this.ControlPlaneLabels {
+		if _, found := node.Labels[key]; found {
+			return true
+		}
+	}
+	for _, taint := range node.Spec.Taints {
+		for _, key := range this.ControlPlaneTaints {
+			if taint.Key == key {
+				return true
+			}
+		}
+	}
+	return false
+}
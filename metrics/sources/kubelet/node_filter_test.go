@@ -0,0 +1,126 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+	kubelet_client "k8s.io/heapster/metrics/sources/kubelet/util"
+)
+
+func testNode(name, address string, labels map[string]string, taints ...string) *kube_api.Node {
+	node := &kube_api.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Status: kube_api.NodeStatus{
+			Addresses: []kube_api.NodeAddress{
+				{
+					Type:    kube_api.NodeInternalIP,
+					Address: address,
+				},
+			},
+		},
+	}
+	for _, taint := range taints {
+		node.Spec.Taints = append(node.Spec.Taints, kube_api.Taint{Key: taint, Effect: kube_api.TaintEffectNoSchedule})
+	}
+	return node
+}
+
+func TestGetNodeFilter(t *testing.T) {
+	uri, err := url.Parse("kubernetes:?controlPlaneNodes=exclude&controlPlaneTaints=dedicated,%20infra&controlPlaneLabels=role/master")
+	assert.NoError(t, err)
+	filter, err := GetNodeFilter(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, &NodeFilter{
+		ExcludeControlPlane: true,
+		ControlPlaneTaints:  []string{"dedicated", "infra"},
+		ControlPlaneLabels:  []string{"role/master"},
+	}, filter)
+
+	uri, err = url.Parse("kubernetes:")
+	assert.NoError(t, err)
+	filter, err = GetNodeFilter(uri)
+	assert.NoError(t, err)
+	assert.False(t, filter.ExcludeControlPlane)
+	assert.Equal(t, defaultControlPlaneKeys, filter.ControlPlaneTaints)
+	assert.Equal(t, defaultControlPlaneKeys, filter.ControlPlaneLabels)
+
+	uri, err = url.Parse("kubernetes:?controlPlaneNodes=only")
+	assert.NoError(t, err)
+	_, err = GetNodeFilter(uri)
+	assert.Error(t, err)
+}
+
+func TestNodeFilterExcludes(t *testing.T) {
+	filter := &NodeFilter{
+		ExcludeControlPlane: true,
+		ControlPlaneTaints:  defaultControlPlaneKeys,
+		ControlPlaneLabels:  defaultControlPlaneKeys,
+	}
+	worker := testNode("worker", "10.0.0.1", map[string]string{"node-role.kubernetes.io/worker": ""}, "gpu")
+	tainted := testNode("tainted", "10.0.0.2", nil, "node-role.kubernetes.io/control-plane")
+	labeled := testNode("labeled", "10.0.0.3", map[string]string{"node-role.kubernetes.io/master": ""})
+
+	assert.False(t, filter.Excludes(worker))
+	assert.True(t, filter.Excludes(tainted))
+	assert.True(t, filter.Excludes(labeled))
+
+	filter.ExcludeControlPlane = false
+	assert.False(t, filter.Excludes(tainted))
+	assert.False(t, filter.Excludes(labeled))
+
+	var noFilter *NodeFilter
+	assert.False(t, noFilter.Excludes(tainted))
+}
+
+func TestGetMetricsSourcesControlPlaneNodes(t *testing.T) {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	store.Add(testNode("worker", "10.0.0.1", nil))
+	store.Add(testNode("control-plane", "10.0.0.2", nil, "node-role.kubernetes.io/control-plane"))
+
+	for setting, expected := range map[string][]string{
+		"include": {"control-plane", "worker"},
+		"exclude": {"worker"},
+	} {
+		uri, err := url.Parse("kubernetes:?controlPlaneNodes=" + setting)
+		assert.NoError(t, err)
+		filter, err := GetNodeFilter(uri)
+		assert.NoError(t, err)
+		provider := &kubeletProvider{
+			nodeLister: v1listers.NewNodeLister(store),
+			kubeletClient: &KubeletClient{
+				config: &kubelet_client.KubeletClientConfig{Port: 10255},
+			},
+			nodeFilter: filter,
+		}
+
+		names := []string{}
+		for _, source := range provider.GetMetricsSources() {
+			names = append(names, source.(*kubeletMetricsSource).nodename)
+		}
+		sort.Strings(names)
+		assert.Equal(t, expected, names, setting)
+	}
+}
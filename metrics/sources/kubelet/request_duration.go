@@ -0,0 +1,70 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestDurationLock sync.RWMutex
+	// The Kubelet request durations in seconds, of both the kubelet and the summary sources.
+	// Not split by node, as every node would add a series per bucket.
+	kubeletRequestDuration = newRequestDurationHistogram(prometheus.DefBuckets)
+)
+
+func init() {
+	prometheus.MustRegister(kubeletRequestDuration)
+}
+
+func newRequestDurationHistogram(buckets []float64) prometheus.Histogram {
+	return prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "heapster",
+		Subsystem: "kubelet",
+		Name:      "request_duration_seconds",
+		Help:      "The Kubelet request durations in seconds.",
+		Buckets:   buckets,
+	})
+}
+
+// SetRequestDurationBuckets replaces the histogram of the Kubelet request durations with
+// one using the given bucket upper bounds, which have to be increasing. The default
+// buckets are used if none are given. The recorded durations are dropped.
+func SetRequestDurationBuckets(buckets []float64) error {
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	histogram := newRequestDurationHistogram(buckets)
+
+	requestDurationLock.Lock()
+	defer requestDurationLock.Unlock()
+	prometheus.Unregister(kubeletRequestDuration)
+	if err := prometheus.Register(histogram); err != nil {
+		prometheus.MustRegister(kubeletRequestDuration)
+		return err
+	}
+	kubeletRequestDuration = histogram
+	return nil
+}
+
+// ObserveRequestDuration records the duration of a Kubelet request sent at start.
+func ObserveRequestDuration(start time.Time) {
+	requestDurationLock.RLock()
+	defer requestDurationLock.RUnlock()
+	kubeletRequestDuration.Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRequestDurationBuckets(t *testing.T) {
+	defer SetRequestDurationBuckets(nil)
+	require.NoError(t, SetRequestDurationBuckets([]float64{0.5, 2, 30}))
+	ObserveRequestDuration(time.Now().Add(-time.Second))
+
+	histogram := &dto.Metric{}
+	require.NoError(t, kubeletRequestDuration.Write(histogram))
+	bounds := []float64{}
+	counts := []uint64{}
+	for _, bucket := range histogram.GetHistogram().GetBucket() {
+		bounds = append(bounds, bucket.GetUpperBound())
+		counts = append(counts, bucket.GetCumulativeCount())
+	}
+	assert.Equal(t, []float64{0.5, 2, 30}, bounds)
+	assert.Equal(t, []uint64{0, 1, 1}, counts)
+
+	// The histogram with the configured buckets is the one exported.
+	server := httptest.NewServer(prometheus.UninstrumentedHandler())
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `heapster_kubelet_request_duration_seconds_bucket{le="30"} 1`)
+	assert.NotContains(t, string(body), `heapster_kubelet_request_duration_seconds_bucket{le="0.005"}`)
+}
+
+func TestDefaultRequestDurationBuckets(t *testing.T) {
+	require.NoError(t, SetRequestDurationBuckets(nil))
+	histogram := &dto.Metric{}
+	require.NoError(t, kubeletRequestDuration.Write(histogram))
+	assert.Equal(t, len(prometheus.DefBuckets), len(histogram.GetHistogram().GetBucket()))
+}
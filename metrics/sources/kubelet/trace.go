@@ -0,0 +1,139 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Verbosity at which the timings of every kubelet request are logged.
+const traceVerbosity = 6
+
+// requestTiming holds the durations of the phases of a single kubelet request.
+// Phases that did not happen, e.g. DNS lookups of IP addresses or connecting
+// when a keep-alive connection was reused, are left at zero.
+type requestTiming struct {
+	dns          time.Duration
+	connect      time.Duration
+	tlsHandshake time.Duration
+	firstByte    time.Duration
+	reusedConn   bool
+}
+
+// requestTrace records the timing of a request. The transport may keep dialing in the
+// background once the request got another connection, so the hooks are synchronized
+// with reading the timing.
+type requestTrace struct {
+	lock         sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timing       requestTiming
+}
+
+func (this *requestTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			this.record(func() { this.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			this.record(func() { this.timing.dns = time.Since(this.dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			this.record(func() { this.connectStart = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			this.record(func() { this.timing.connect = time.Since(this.connectStart) })
+		},
+		TLSHandshakeStart: func() {
+			this.record(func() { this.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			this.record(func() { this.timing.tlsHandshake = time.Since(this.tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			this.record(func() { this.timing.reusedConn = info.Reused })
+		},
+		GotFirstResponseByte: func() {
+			this.record(func() { this.timing.firstByte = time.Since(this.start) })
+		},
+	}
+}
+
+func (this *requestTrace) record(update func()) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	update()
+}
+
+// result returns the timing of the request. The dialing and the TLS handshake of a
+// connection that was not used by the request are left out.
+func (this *requestTrace) result() requestTiming {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	timing := this.timing
+	if timing.reusedConn {
+		timing.connect = 0
+		timing.tlsHandshake = 0
+	}
+	return timing
+}
+
+// tracingTransport records the timings of the requests sent through the wrapped
+// transport and passes them to report. Requests are only traced if enabled returns true.
+type tracingTransport struct {
+	transport http.RoundTripper
+	enabled   func() bool
+	report    func(req *http.Request, timing requestTiming, err error)
+}
+
+// newTracingTransport returns a transport logging the timings of the kubelet requests
+// when running with --v=6 or higher.
+func newTracingTransport(transport http.RoundTripper) http.RoundTripper {
+	return &tracingTransport{
+		transport: transport,
+		enabled: func() bool {
+			return bool(glog.V(traceVerbosity))
+		},
+		report: logRequestTrace,
+	}
+}
+
+func (this *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !this.enabled() {
+		return this.transport.RoundTrip(req)
+	}
+	trace := &requestTrace{start: time.Now()}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+	resp, err := this.transport.RoundTrip(req)
+	this.report(req, trace.result(), err)
+	return resp, err
+}
+
+func logRequestTrace(req *http.Request, timing requestTiming, err error) {
+	status := "ok"
+	if err != nil {
+		status = err.Error()
+	}
+	glog.Infof("Kubelet request %s %s: dns=%v connect=%v tls=%v first_byte=%v reused_conn=%v status=%s",
+		req.Method, req.URL.Host, timing.dns, timing.connect, timing.tlsHandshake, timing.firstByte, timing.reusedConn, status)
+}
@@ -0,0 +1,113 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubelet
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracingTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	var timings []requestTiming
+	enabled := true
+	transport := &tracingTransport{
+		transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			// A single connection attempt per request, whatever the addresses of localhost.
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "tcp4", addr)
+			},
+			MaxIdleConnsPerHost: 1,
+		},
+		enabled: func() bool { return enabled },
+		report: func(req *http.Request, timing requestTiming, err error) {
+			assert.NoError(t, err)
+			timings = append(timings, timing)
+		},
+	}
+	client := &http.Client{Transport: transport}
+	// Use a host name so that the DNS lookup is traced as well.
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	get := func() {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		// Read the whole body, so that the connection is reused by the next request.
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	get()
+	require.Equal(t, 1, len(timings))
+	assert.True(t, timings[0].dns > 0)
+	assert.True(t, timings[0].connect > 0)
+	assert.True(t, timings[0].tlsHandshake > 0)
+	assert.True(t, timings[0].firstByte >= 10*time.Millisecond)
+	assert.False(t, timings[0].reusedConn)
+
+	get()
+	require.Equal(t, 2, len(timings))
+	assert.True(t, timings[1].reusedConn)
+	assert.Equal(t, time.Duration(0), timings[1].connect)
+	assert.Equal(t, time.Duration(0), timings[1].tlsHandshake)
+	assert.True(t, timings[1].firstByte >= 10*time.Millisecond)
+
+	enabled = false
+	get()
+	assert.Equal(t, 2, len(timings))
+}
+
+func TestRequestTraceOfReusedConnection(t *testing.T) {
+	trace := &requestTrace{start: time.Now()}
+	hooks := trace.clientTrace()
+	// The request got an idle connection while a dial started for it was still running.
+	hooks.ConnectStart("tcp", "10.0.0.1:10250")
+	hooks.GotConn(httptrace.GotConnInfo{Reused: true})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		hooks.ConnectDone("tcp", "10.0.0.1:10250", nil)
+		hooks.TLSHandshakeStart()
+		hooks.TLSHandshakeDone(tls.ConnectionState{}, nil)
+	}()
+	hooks.GotFirstResponseByte()
+	timing := trace.result()
+	wg.Wait()
+
+	assert.True(t, timing.reusedConn)
+	assert.Equal(t, time.Duration(0), timing.connect)
+	assert.Equal(t, time.Duration(0), timing.tlsHandshake)
+	assert.True(t, trace.result().firstByte > 0)
+}
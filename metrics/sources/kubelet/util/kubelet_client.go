@@ -16,6 +16,8 @@ package client
 
 import (
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
 	utilnet "k8s.io/apimachinery/pkg/util/net"
@@ -23,6 +25,14 @@ import (
 	"k8s.io/client-go/transport"
 )
 
+// Versions of the cadvisor API from which the container statistics are collected.
+const (
+	// The v1 API served by the Kubelet.
+	CadvisorApiV1 = "v1"
+	// The v2 API served by cadvisor on the cadvisor port of the node.
+	CadvisorApiV2 = "v2"
+)
+
 type KubeletClientConfig struct {
 	// Default port - used if no information about Kubelet port can be found in Node.NodeStatus.DaemonEndpoints.
 	Port         uint
@@ -38,11 +48,47 @@ type KubeletClientConfig struct {
 	// Server requires Bearer authentication
 	BearerToken string
 
+	// NodeTokenDir is a directory holding a bearer token file per node, named after the
+	// node, used instead of BearerToken for the nodes having one.
+	NodeTokenDir string
+
 	// HTTPTimeout is used by the client to timeout http requests to Kubelet.
 	HTTPTimeout time.Duration
 
 	// Dial is a custom dialer used for the client
 	Dial utilnet.DialFunc
+
+	// IdleConnTimeout is the time after which idle keep-alive connections to Kubelet are closed.
+	IdleConnTimeout time.Duration
+
+	// MaxConnLifetime is the interval at which all idle connections to Kubelet are closed, so that
+	// connections to a replaced node are not reused forever. They are closed on the first request
+	// after the interval, and connections busy at that moment are closed after the next one.
+	MaxConnLifetime time.Duration
+
+	// MaxResponseBytes is the maximum size of a Kubelet response body. Larger responses are
+	// rejected. 0 means no limit.
+	MaxResponseBytes int64
+
+	// HealthCheckTimeout is the timeout of the /healthz probe sent to Kubelet before every
+	// scrape. Kubelets failing the probe are not scraped. 0 disables the probe.
+	HealthCheckTimeout time.Duration
+
+	// CadvisorApi is the version of the cadvisor API from which the container statistics
+	// are collected, CadvisorApiV1 if empty.
+	CadvisorApi string
+	// CadvisorPort is the port of cadvisor on the nodes, used with CadvisorApiV2.
+	CadvisorPort uint
+
+	// AllStatsSamples requests all the samples of the scrape window from cadvisor, instead
+	// of only the latest one.
+	AllStatsSamples bool
+
+	// APIServerProxy is the URL of the API server through which Kubelets are reached, using
+	// its node proxy and the API server credentials. Kubelets are connected directly if nil.
+	APIServerProxy *url.URL
+	// APIServerInsecure skips the verification of the API server certificate.
+	APIServerInsecure bool
 }
 
 func MakeTransport(config *KubeletClientConfig) (http.RoundTripper, error) {
@@ -52,16 +98,53 @@ func MakeTransport(config *KubeletClientConfig) (http.RoundTripper, error) {
 	}
 
 	rt := http.DefaultTransport
-	if config.Dial != nil || tlsConfig != nil {
-		rt = utilnet.SetOldTransportDefaults(&http.Transport{
+	if config.Dial != nil || tlsConfig != nil || config.IdleConnTimeout > 0 || config.MaxConnLifetime > 0 {
+		t := utilnet.SetOldTransportDefaults(&http.Transport{
 			Dial:            config.Dial,
 			TLSClientConfig: tlsConfig,
+			IdleConnTimeout: config.IdleConnTimeout,
 		})
+		rt = t
+		if config.MaxConnLifetime > 0 {
+			rt = &recyclingTransport{Transport: t, interval: config.MaxConnLifetime, recycled: time.Now()}
+		}
 	}
 
 	return transport.HTTPWrappersForConfig(config.transportConfig(), rt)
 }
 
+// recyclingTransport closes the idle connections of the transport on the first request
+// after each interval. Recycling on requests rather than on a timer leaves nothing running
+// once the transport is no longer used.
+type recyclingTransport struct {
+	*http.Transport
+	interval time.Duration
+
+	lock     sync.Mutex
+	recycled time.Time
+}
+
+func (this *recyclingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	this.lock.Lock()
+	if now := time.Now(); now.Sub(this.recycled) >= this.interval {
+		this.Transport.CloseIdleConnections()
+		this.recycled = now
+	}
+	this.lock.Unlock()
+	return this.Transport.RoundTrip(req)
+}
+
+// WithoutCredentials returns a copy of the config without the client certificate and the
+// bearer tokens, for the servers which are reached with plain HTTP.
+func (c *KubeletClientConfig) WithoutCredentials() *KubeletClientConfig {
+	config := *c
+	config.EnableHttps = false
+	config.TLSClientConfig = restclient.TLSClientConfig{}
+	config.BearerToken = ""
+	config.NodeTokenDir = ""
+	return &config
+}
+
 // transportConfig converts a client config to an appropriate transport config.
 func (c *KubeletClientConfig) transportConfig() *transport.Config {
 	cfg := &transport.Config{
@@ -75,7 +158,10 @@ func (c *KubeletClientConfig) transportConfig() *transport.Config {
 		},
 		BearerToken: c.BearerToken,
 	}
-	if c.EnableHttps && !cfg.HasCA() {
+	if c.APIServerProxy != nil {
+		// The TLS settings are the ones of the API server.
+		cfg.TLS.Insecure = c.APIServerInsecure
+	} else if c.EnableHttps && !cfg.HasCA() {
 		cfg.TLS.Insecure = true
 	}
 	return cfg
@@ -0,0 +1,108 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingServer counts the connections opened by the clients.
+type countingServer struct {
+	sync.Mutex
+	*httptest.Server
+	connections int
+}
+
+func newCountingServer() *countingServer {
+	server := &countingServer{}
+	server.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			server.Lock()
+			server.connections++
+			server.Unlock()
+		}
+	}
+	server.Start()
+	return server
+}
+
+func (server *countingServer) Connections() int {
+	server.Lock()
+	defer server.Unlock()
+	return server.connections
+}
+
+func get(t *testing.T, rt http.RoundTripper, url string) {
+	client := &http.Client{Transport: rt}
+	resp, err := client.Get(url)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+}
+
+func TestMakeTransportIdleConnTimeout(t *testing.T) {
+	server := newCountingServer()
+	defer server.Close()
+
+	rt, err := MakeTransport(&KubeletClientConfig{IdleConnTimeout: 50 * time.Millisecond})
+	assert.NoError(t, err)
+	if transport, ok := rt.(*http.Transport); assert.True(t, ok) {
+		assert.Equal(t, 50*time.Millisecond, transport.IdleConnTimeout)
+	}
+
+	get(t, rt, server.URL)
+	get(t, rt, server.URL)
+	// Back to back requests reuse the connection.
+	assert.Equal(t, 1, server.Connections())
+
+	time.Sleep(200 * time.Millisecond)
+	get(t, rt, server.URL)
+	assert.Equal(t, 2, server.Connections())
+}
+
+func TestMakeTransportMaxConnLifetime(t *testing.T) {
+	server := newCountingServer()
+	defer server.Close()
+
+	rt, err := MakeTransport(&KubeletClientConfig{MaxConnLifetime: 50 * time.Millisecond})
+	assert.NoError(t, err)
+
+	get(t, rt, server.URL)
+	get(t, rt, server.URL)
+	assert.Equal(t, 1, server.Connections())
+
+	time.Sleep(200 * time.Millisecond)
+	get(t, rt, server.URL)
+	assert.Equal(t, 2, server.Connections())
+}
+
+func TestMakeTransportDefault(t *testing.T) {
+	rt, err := MakeTransport(&KubeletClientConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, http.DefaultTransport, rt)
+}
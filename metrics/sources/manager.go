@@ -60,15 +60,24 @@ func init() {
 }
 
 func NewSourceManager(metricsSourceProvider MetricsSourceProvider, metricsScrapeTimeout time.Duration) (MetricsSource, error) {
+	return NewScheduledSourceManager(metricsSourceProvider, metricsScrapeTimeout, nil)
+}
+
+// NewScheduledSourceManager returns a source manager scraping the nodes matching the
+// given intervals less often than in every scrape cycle.
+func NewScheduledSourceManager(metricsSourceProvider MetricsSourceProvider, metricsScrapeTimeout time.Duration,
+	scrapeIntervals []ScrapeInterval) (MetricsSource, error) {
 	return &sourceManager{
 		metricsSourceProvider: metricsSourceProvider,
 		metricsScrapeTimeout:  metricsScrapeTimeout,
+		schedule:              newScrapeSchedule(scrapeIntervals),
 	}, nil
 }
 
 type sourceManager struct {
 	metricsSourceProvider MetricsSourceProvider
 	metricsScrapeTimeout  time.Duration
+	schedule              *scrapeSchedule
 }
 
 func (this *sourceManager) Name() string {
@@ -77,7 +86,7 @@ func (this *sourceManager) Name() string {
 
 func (this *sourceManager) ScrapeMetrics(start, end time.Time) (*DataBatch, error) {
 	glog.V(1).Infof("Scraping metrics start: %s, end: %s", start, end)
-	sources := this.metricsSourceProvider.GetMetricsSources()
+	sources, carried := this.schedule.due(this.metricsSourceProvider.GetMetricsSources(), end)
 
 	responseChannel := make(chan *DataBatch)
 	startTime := time.Now()
@@ -101,6 +110,7 @@ func (this *sourceManager) ScrapeMetrics(start, end time.Time) (*DataBatch, erro
 				glog.Errorf("Error in scraping containers from %s: %v", source.Name(), err)
 				return
 			}
+			this.schedule.record(source, metrics)
 
 			now := time.Now()
 			if !now.Before(timeoutTime) {
@@ -123,6 +133,12 @@ func (this *sourceManager) ScrapeMetrics(start, end time.Time) (*DataBatch, erro
 		Timestamp:  end,
 		MetricSets: map[string]*MetricSet{},
 	}
+	// The metric sets of the sources skipped in this cycle, as last scraped.
+	for _, dataBatch := range carried {
+		for key, value := range dataBatch.MetricSets {
+			response.MetricSets[key] = value
+		}
+	}
 
 	latencies := make([]int, 11)
 
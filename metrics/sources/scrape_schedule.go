@@ -0,0 +1,185 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	. "k8s.io/heapster/metrics/core"
+)
+
+// ScrapeInterval is the interval at which the nodes matching the selector are scraped.
+type ScrapeInterval struct {
+	Selector labels.Selector
+	Interval time.Duration
+}
+
+// ParseScrapeInterval parses an interval given as <label selector>:<duration>, e.g. tier=edge:60s.
+func ParseScrapeInterval(spec string) (ScrapeInterval, error) {
+	i := strings.LastIndex(spec, ":")
+	if i < 0 {
+		return ScrapeInterval{}, fmt.Errorf("invalid scrape interval %q - expected <label selector>:<duration>", spec)
+	}
+	selector, err := labels.Parse(spec[:i])
+	if err != nil {
+		return ScrapeInterval{}, fmt.Errorf("invalid node selector in scrape interval %q - %v", spec, err)
+	}
+	if selector.Empty() {
+		return ScrapeInterval{}, fmt.Errorf("empty node selector in scrape interval %q", spec)
+	}
+	interval, err := time.ParseDuration(spec[i+1:])
+	if err != nil {
+		return ScrapeInterval{}, fmt.Errorf("invalid duration in scrape interval %q - %v", spec, err)
+	}
+	if interval <= 0 {
+		return ScrapeInterval{}, fmt.Errorf("scrape interval %q has to be positive", spec)
+	}
+	return ScrapeInterval{Selector: selector, Interval: interval}, nil
+}
+
+// nodeSource is implemented by the sources scraping a single node.
+type nodeSource interface {
+	NodeLabels() map[string]string
+}
+
+// scrapeSchedule tracks when every source was last scraped, so that the sources of the
+// nodes matching a scrape interval are only scraped once per interval. Sources of other
+// nodes, and sources not bound to a node, are scraped in every cycle.
+// The last batch of every source scraped less often is carried forward into the cycles
+// in which it is skipped, so that the aggregates and the processors comparing batches
+// see all the nodes in every cycle.
+type scrapeSchedule struct {
+	// The first matching interval applies.
+	intervals []ScrapeInterval
+
+	lock sync.Mutex
+	// End of the scrape cycle in which each source was last scraped, by source name.
+	lastScrape map[string]time.Time
+	// Last batch of each source with an interval, by source name.
+	lastBatch map[string]*DataBatch
+}
+
+func newScrapeSchedule(intervals []ScrapeInterval) *scrapeSchedule {
+	return &scrapeSchedule{
+		intervals:  intervals,
+		lastScrape: make(map[string]time.Time),
+		lastBatch:  make(map[string]*DataBatch),
+	}
+}
+
+func (this *scrapeSchedule) interval(source MetricsSource) time.Duration {
+	node, ok := source.(nodeSource)
+	if !ok {
+		return 0
+	}
+	nodeLabels := labels.Set(node.NodeLabels())
+	for _, interval := range this.intervals {
+		if interval.Selector.Matches(nodeLabels) {
+			return interval.Interval
+		}
+	}
+	return 0
+}
+
+// due returns the sources to scrape in the cycle ending at end and records them as scraped,
+// along with copies of the last batches of the skipped sources.
+func (this *scrapeSchedule) due(sources []MetricsSource, end time.Time) ([]MetricsSource, []*DataBatch) {
+	if len(this.intervals) == 0 {
+		return sources, nil
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	result := make([]MetricsSource, 0, len(sources))
+	var carried []*DataBatch
+	lastScrape := make(map[string]time.Time, len(sources))
+	lastBatch := make(map[string]*DataBatch, len(this.lastBatch))
+	for _, source := range sources {
+		name := source.Name()
+		last, found := this.lastScrape[name]
+		interval := this.interval(source)
+		if interval > 0 && found && end.Before(last.Add(interval)) {
+			lastScrape[name] = last
+			if batch, found := this.lastBatch[name]; found {
+				lastBatch[name] = batch
+				carried = append(carried, copyBatch(batch))
+			}
+			continue
+		}
+		// The batch is recorded again once the source is scraped successfully.
+		lastScrape[name] = end
+		result = append(result, source)
+	}
+	// Forget the sources that are gone, e.g. of deleted nodes.
+	this.lastScrape = lastScrape
+	this.lastBatch = lastBatch
+	return result, carried
+}
+
+// record keeps a copy of the batch scraped from the source, if the source is scraped less
+// often than in every cycle. The processors modify the metric sets of the batch in place.
+func (this *scrapeSchedule) record(source MetricsSource, batch *DataBatch) {
+	if len(this.intervals) == 0 || batch == nil || this.interval(source) <= 0 {
+		return
+	}
+	copied := copyBatch(batch)
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.lastBatch[source.Name()] = copied
+}
+
+// copyBatch returns a deep copy of the batch, without the additional samples of its metric
+// sets, which were already exported.
+func copyBatch(batch *DataBatch) *DataBatch {
+	copied := &DataBatch{
+		Timestamp:  batch.Timestamp,
+		MetricSets: make(map[string]*MetricSet, len(batch.MetricSets)),
+	}
+	for key, metricSet := range batch.MetricSets {
+		copied.MetricSets[key] = copyMetricSet(metricSet)
+	}
+	return copied
+}
+
+func copyMetricSet(metricSet *MetricSet) *MetricSet {
+	copied := &MetricSet{
+		CollectionStartTime: metricSet.CollectionStartTime,
+		EntityCreateTime:    metricSet.EntityCreateTime,
+		ScrapeTime:          metricSet.ScrapeTime,
+		MetricValues:        make(map[string]MetricValue, len(metricSet.MetricValues)),
+		Labels:              copyLabels(metricSet.Labels),
+		LabeledMetrics:      make([]LabeledMetric, len(metricSet.LabeledMetrics)),
+	}
+	for name, value := range metricSet.MetricValues {
+		copied.MetricValues[name] = value
+	}
+	for i, labeledMetric := range metricSet.LabeledMetrics {
+		labeledMetric.Labels = copyLabels(labeledMetric.Labels)
+		copied.LabeledMetrics[i] = labeledMetric
+	}
+	return copied
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
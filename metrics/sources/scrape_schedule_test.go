@@ -0,0 +1,187 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/labels"
+
+	. "k8s.io/heapster/metrics/core"
+	"k8s.io/heapster/metrics/util"
+)
+
+type testNodeSource struct {
+	name   string
+	labels map[string]string
+}
+
+func (this *testNodeSource) Name() string {
+	return this.name
+}
+
+func (this *testNodeSource) NodeLabels() map[string]string {
+	return this.labels
+}
+
+func (this *testNodeSource) ScrapeMetrics(start, end time.Time) (*DataBatch, error) {
+	return &DataBatch{
+		Timestamp: end,
+		MetricSets: map[string]*MetricSet{
+			NodeKey(this.name): {
+				ScrapeTime:   end,
+				MetricValues: map[string]MetricValue{},
+				Labels:       map[string]string{LabelMetricSetType.Key: MetricSetTypeNode},
+			},
+		},
+	}, nil
+}
+
+func TestParseScrapeInterval(t *testing.T) {
+	interval, err := ParseScrapeInterval("tier=edge,zone!=a:60s")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, interval.Interval)
+	assert.True(t, interval.Selector.Matches(labels.Set{"tier": "edge", "zone": "b"}))
+	assert.False(t, interval.Selector.Matches(labels.Set{"tier": "edge", "zone": "a"}))
+	assert.False(t, interval.Selector.Matches(labels.Set{"tier": "core"}))
+
+	for _, spec := range []string{"tier=edge", ":60s", "tier=edge:", "tier in edge:60s", "tier=edge:-1s", "tier=edge:0s"} {
+		_, err := ParseScrapeInterval(spec)
+		assert.Error(t, err, "spec %q", spec)
+	}
+}
+
+func TestScrapeIntervalsPerNodeClass(t *testing.T) {
+	edge, err := ParseScrapeInterval("tier=edge:60s")
+	require.NoError(t, err)
+	regional, err := ParseScrapeInterval("tier=regional:30s")
+	require.NoError(t, err)
+	provider := util.NewDummyMetricsSourceProvider(
+		&testNodeSource{name: "core", labels: map[string]string{"tier": "core"}},
+		&testNodeSource{name: "edge", labels: map[string]string{"tier": "edge"}},
+		&testNodeSource{name: "regional", labels: map[string]string{"tier": "regional"}},
+	)
+	manager, err := NewScheduledSourceManager(provider, 3*time.Second, []ScrapeInterval{edge, regional})
+	require.NoError(t, err)
+
+	scrapes := map[string]int{}
+	resolution := 10 * time.Second
+	end := time.Now().Truncate(time.Minute)
+	// Two minutes of scrape cycles.
+	for i := 0; i < 12; i++ {
+		batch, err := manager.ScrapeMetrics(end.Add(-resolution), end)
+		require.NoError(t, err)
+		// The nodes skipped in a cycle are carried forward as last scraped.
+		assert.Len(t, batch.MetricSets, 3)
+		for key, metricSet := range batch.MetricSets {
+			if metricSet.ScrapeTime.Equal(end) {
+				scrapes[key]++
+			}
+		}
+		end = end.Add(resolution)
+	}
+	assert.Equal(t, map[string]int{
+		NodeKey("core"):     12,
+		NodeKey("edge"):     2,
+		NodeKey("regional"): 4,
+	}, scrapes)
+}
+
+func TestScrapeScheduleWithoutIntervals(t *testing.T) {
+	schedule := newScrapeSchedule(nil)
+	sources := []MetricsSource{
+		&testNodeSource{name: "n1", labels: map[string]string{"tier": "edge"}},
+		util.NewDummyMetricsSource("s1", 0),
+	}
+	end := time.Now()
+	for _, cycle := range []time.Time{end, end.Add(time.Second)} {
+		due, carried := schedule.due(sources, cycle)
+		assert.Equal(t, sources, due)
+		assert.Empty(t, carried)
+	}
+}
+
+func TestScrapeScheduleCarriesSkippedBatches(t *testing.T) {
+	edge, err := ParseScrapeInterval("tier=edge:60s")
+	require.NoError(t, err)
+	schedule := newScrapeSchedule([]ScrapeInterval{edge})
+	node := &testNodeSource{name: "n1", labels: map[string]string{"tier": "edge"}}
+	other := &testNodeSource{name: "n2", labels: map[string]string{"tier": "core"}}
+	sources := []MetricsSource{node, other}
+	end := time.Now()
+
+	due, carried := schedule.due(sources, end)
+	assert.Len(t, due, 2)
+	assert.Empty(t, carried)
+	batch, err := node.ScrapeMetrics(end.Add(-10*time.Second), end)
+	require.NoError(t, err)
+	schedule.record(node, batch)
+	otherBatch, err := other.ScrapeMetrics(end.Add(-10*time.Second), end)
+	require.NoError(t, err)
+	schedule.record(other, otherBatch)
+
+	for i := 1; i <= 2; i++ {
+		due, carried = schedule.due(sources, end.Add(time.Duration(i)*10*time.Second))
+		assert.Equal(t, []MetricsSource{other}, due)
+		require.Len(t, carried, 1)
+		metricSet := carried[0].MetricSets[NodeKey("n1")]
+		if assert.NotNil(t, metricSet) {
+			assert.Equal(t, end, metricSet.ScrapeTime)
+			// The processors modify the carried metric sets, but not the recorded batch.
+			assert.False(t, metricSet == batch.MetricSets[NodeKey("n1")])
+			metricSet.MetricValues[MetricCpuUsageRate.Name] = MetricValue{IntValue: int64(i)}
+			metricSet.Labels["modified"] = "true"
+		}
+	}
+	due, carried = schedule.due(sources, end.Add(20*time.Second))
+	require.Len(t, carried, 1)
+	metricSet := carried[0].MetricSets[NodeKey("n1")]
+	assert.Empty(t, metricSet.MetricValues)
+	assert.NotContains(t, metricSet.Labels, "modified")
+
+	// A failed scrape leaves nothing to carry forward.
+	due, carried = schedule.due(sources, end.Add(60*time.Second))
+	assert.Len(t, due, 2)
+	assert.Empty(t, carried)
+	due, carried = schedule.due(sources, end.Add(70*time.Second))
+	assert.Len(t, due, 1)
+	assert.Empty(t, carried)
+}
+
+func TestScrapeScheduleForgetsRemovedSources(t *testing.T) {
+	edge, err := ParseScrapeInterval("tier=edge:60s")
+	require.NoError(t, err)
+	schedule := newScrapeSchedule([]ScrapeInterval{edge})
+	node := &testNodeSource{name: "n1", labels: map[string]string{"tier": "edge"}}
+	end := time.Now()
+
+	due, _ := schedule.due([]MetricsSource{node}, end)
+	assert.Len(t, due, 1)
+	batch, err := node.ScrapeMetrics(end, end)
+	require.NoError(t, err)
+	schedule.record(node, batch)
+	due, carried := schedule.due([]MetricsSource{node}, end.Add(10*time.Second))
+	assert.Len(t, due, 0)
+	assert.Len(t, carried, 1)
+	// The node is gone for a cycle, e.g. it was recreated, and is scraped as soon as it is back.
+	due, carried = schedule.due([]MetricsSource{}, end.Add(20*time.Second))
+	assert.Len(t, due, 0)
+	assert.Len(t, carried, 0)
+	due, _ = schedule.due([]MetricsSource{node}, end.Add(30*time.Second))
+	assert.Len(t, due, 1)
+}
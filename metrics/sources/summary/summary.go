@@ -58,6 +58,9 @@ type NodeInfo struct {
 	HostName       string
 	HostID         string
 	KubeletVersion string
+	// Container runtime name and version, e.g. docker://1.13.1.
+	ContainerRuntime string
+	Labels           map[string]string
 }
 
 // Kubelet-provided metrics for pod and system container.
@@ -73,6 +76,11 @@ func NewSummaryMetricsSource(node NodeInfo, client *kubelet.KubeletClient) Metri
 	}
 }
 
+// NodeLabels returns the labels of the scraped node.
+func (this *summaryMetricsSource) NodeLabels() map[string]string {
+	return this.node.Labels
+}
+
 func (this *summaryMetricsSource) Name() string {
 	return this.String()
 }
@@ -86,10 +94,18 @@ func (this *summaryMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatc
 		Timestamp:  time.Now(),
 		MetricSets: map[string]*MetricSet{},
 	}
+	if !kubelet.ProbeKubelet(this.kubeletClient, this.node.Host) {
+		return kubelet.UnreachableKubeletBatch(result.Timestamp, this.node.NodeName, map[string]string{
+			LabelNodename.Key: this.node.NodeName,
+			LabelHostname.Key: this.node.HostName,
+			LabelHostID.Key:   this.node.HostID,
+		}), nil
+	}
 
 	summary, err := func() (*stats.Summary, error) {
 		startTime := time.Now()
 		defer summaryRequestLatency.WithLabelValues(this.node.HostName).Observe(float64(time.Since(startTime)))
+		defer kubelet.ObserveRequestDuration(startTime)
 		return this.kubeletClient.GetSummary(this.node.Host)
 	}()
 
@@ -98,6 +114,7 @@ func (this *summaryMetricsSource) ScrapeMetrics(start, end time.Time) (*DataBatc
 	}
 
 	result.MetricSets = this.decodeSummary(summary)
+	kubelet.MarkKubeletReachable(this.kubeletClient, result, this.node.NodeName)
 
 	return result, err
 }
@@ -153,12 +170,14 @@ func (this *summaryMetricsSource) decodeNodeStats(metrics map[string]*MetricSet,
 		ScrapeTime:          this.getScrapeTime(node.CPU, node.Memory, node.Network),
 	}
 	nodeMetrics.Labels[LabelMetricSetType.Key] = MetricSetTypeNode
+	nodeMetrics.Labels[LabelContainerRuntime.Key] = this.node.ContainerRuntime
 
 	this.decodeUptime(nodeMetrics, node.StartTime.Time)
 	this.decodeCPUStats(nodeMetrics, node.CPU)
 	this.decodeMemoryStats(nodeMetrics, node.Memory)
 	this.decodeNetworkStats(nodeMetrics, node.Network)
 	this.decodeFsStats(nodeMetrics, RootFsKey, node.Fs)
+	this.decodeNodeFsStats(nodeMetrics, node.Fs, node.Runtime)
 	metrics[NodeKey(node.NodeName)] = nodeMetrics
 
 	for _, container := range node.SystemContainers {
@@ -305,6 +324,21 @@ func (this *summaryMetricsSource) decodeFsStats(metrics *MetricSet, fsKey string
 	this.addLabeledIntMetric(metrics, &MetricFilesystemInodesFree, fsLabels, fs.InodesFree)
 }
 
+// decodeNodeFsStats splits the node filesystem usage into the root filesystem and the image filesystem,
+// the way Kubelet does for eviction.
+func (this *summaryMetricsSource) decodeNodeFsStats(metrics *MetricSet, rootFs *stats.FsStats, runtime *stats.RuntimeStats) {
+	if rootFs != nil {
+		this.addIntMetric(metrics, &MetricNodeFsUsage, rootFs.UsedBytes)
+		this.addIntMetric(metrics, &MetricNodeFsLimit, rootFs.CapacityBytes)
+	}
+	if runtime == nil || runtime.ImageFs == nil {
+		glog.V(9).Infof("missing image fs metrics!")
+		return
+	}
+	this.addIntMetric(metrics, &MetricNodeImageFsUsage, runtime.ImageFs.UsedBytes)
+	this.addIntMetric(metrics, &MetricNodeImageFsLimit, runtime.ImageFs.CapacityBytes)
+}
+
 func (this *summaryMetricsSource) decodeUserDefinedMetrics(metrics *MetricSet, udm []stats.UserDefinedMetric) {
 	for _, metric := range udm {
 		mv := MetricValue{}
@@ -388,6 +422,7 @@ type summaryProvider struct {
 	nodeLister    v1listers.NodeLister
 	reflector     *cache.Reflector
 	kubeletClient *kubelet.KubeletClient
+	nodeFilter    *kubelet.NodeFilter
 }
 
 func (this *summaryProvider) GetMetricsSources() []MetricsSource {
@@ -399,6 +434,10 @@ func (this *summaryProvider) GetMetricsSources() []MetricsSource {
 	}
 
 	for _, node := range nodes {
+		if this.nodeFilter.Excludes(node) {
+			glog.V(4).Infof("Skipping control-plane node %s", node.Name)
+			continue
+		}
 		info, err := this.getNodeInfo(node)
 		if err != nil {
 			glog.Errorf("%v", err)
@@ -423,10 +462,13 @@ func (this *summaryProvider) getNodeInfo(node *kube_api.Node) (NodeInfo, error)
 		HostName: hostname,
 		HostID:   node.Spec.ExternalID,
 		Host: kubelet.Host{
-			IP:   ip,
-			Port: this.kubeletClient.GetPort(),
+			IP:       ip,
+			Port:     this.kubeletClient.GetPort(),
+			NodeName: node.Name,
 		},
-		KubeletVersion: node.Status.NodeInfo.KubeletVersion,
+		KubeletVersion:   node.Status.NodeInfo.KubeletVersion,
+		ContainerRuntime: node.Status.NodeInfo.ContainerRuntimeVersion,
+		Labels:           node.Labels,
 	}
 	return info, nil
 }
@@ -442,12 +484,21 @@ func NewSummaryProvider(uri *url.URL) (MetricsSourceProvider, error) {
 	if err != nil {
 		return nil, err
 	}
+	nodeFilter, err := kubelet.GetNodeFilter(uri)
+	if err != nil {
+		return nil, err
+	}
+	watchBackoff, err := util.ParseWatchBackoff(uri.Query())
+	if err != nil {
+		return nil, err
+	}
 	// watch nodes
-	nodeLister, reflector, _ := util.GetNodeLister(kubeClient)
+	nodeLister, reflector, _ := util.GetNodeListerWithBackoff(kubeClient, watchBackoff)
 
 	return &summaryProvider{
 		nodeLister:    nodeLister,
 		reflector:     reflector,
 		kubeletClient: kubeletClient,
+		nodeFilter:    nodeFilter,
 	}, nil
 }
@@ -18,6 +18,8 @@ import (
 	"encoding/json"
 	"net"
 	"net/http/httptest"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"testing"
@@ -26,9 +28,13 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1listers "k8s.io/client-go/listers/core/v1"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
 	util "k8s.io/client-go/util/testing"
 	"k8s.io/heapster/metrics/core"
 	"k8s.io/heapster/metrics/sources/kubelet"
+	kubelet_client "k8s.io/heapster/metrics/sources/kubelet/util"
 	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
 )
 
@@ -108,10 +114,11 @@ var (
 )
 
 var nodeInfo = NodeInfo{
-	NodeName:       "test",
-	HostName:       "test-hostname",
-	HostID:         "1234567890",
-	KubeletVersion: "1.2",
+	NodeName:         "test",
+	HostName:         "test-hostname",
+	HostID:           "1234567890",
+	KubeletVersion:   "1.2",
+	ContainerRuntime: "containerd://1.0.0",
 }
 
 type fakeSource struct {
@@ -147,6 +154,9 @@ func TestDecodeSummaryMetrics(t *testing.T) {
 				genTestSummaryContainer(stats.SystemContainerMisc, seedMisc),
 			},
 			Fs: genTestSummaryFsStats(seedNode),
+			Runtime: &stats.RuntimeStats{
+				ImageFs: genTestSummaryFsStats(seedRuntime),
+			},
 		},
 		Pods: []stats.PodStats{{
 			PodRef: stats.PodReference{
@@ -353,6 +363,14 @@ func TestDecodeSummaryMetrics(t *testing.T) {
 	}}
 
 	metrics := ms.decodeSummary(&summary)
+	nodeKey := core.NodeKey(nodeInfo.NodeName)
+	if m, ok := metrics[nodeKey]; assert.True(t, ok, "missing metric %q", nodeKey) {
+		assert.Equal(t, "containerd://1.0.0", m.Labels[core.LabelContainerRuntime.Key])
+		checkIntMetric(t, m, nodeKey, core.MetricNodeFsUsage, seedNode+offsetFsUsed)
+		checkIntMetric(t, m, nodeKey, core.MetricNodeFsLimit, seedNode+offsetFsCapacity)
+		checkIntMetric(t, m, nodeKey, core.MetricNodeImageFsUsage, seedRuntime+offsetFsUsed)
+		checkIntMetric(t, m, nodeKey, core.MetricNodeImageFsLimit, seedRuntime+offsetFsCapacity)
+	}
 	for _, e := range expectations {
 		m, ok := metrics[e.key]
 		if !assert.True(t, ok, "missing metric %q", e.key) {
@@ -602,3 +620,50 @@ func TestScrapeSummaryMetrics(t *testing.T) {
 	assert.Nil(t, err, "scrape error")
 	assert.Equal(t, res.MetricSets["node:test"].Labels[core.LabelMetricSetType.Key], core.MetricSetTypeNode)
 }
+
+func TestGetMetricsSourcesControlPlaneNodes(t *testing.T) {
+	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range []*kube_api.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker"},
+			Status: kube_api.NodeStatus{
+				Addresses: []kube_api.NodeAddress{{Type: kube_api.NodeInternalIP, Address: "10.0.0.1"}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "control-plane"},
+			Spec: kube_api.NodeSpec{
+				Taints: []kube_api.Taint{{Key: "node-role.kubernetes.io/control-plane", Effect: kube_api.TaintEffectNoSchedule}},
+			},
+			Status: kube_api.NodeStatus{
+				Addresses: []kube_api.NodeAddress{{Type: kube_api.NodeInternalIP, Address: "10.0.0.2"}},
+			},
+		},
+	} {
+		store.Add(node)
+	}
+	kubeletClient, err := kubelet.NewKubeletClient(&kubelet_client.KubeletClientConfig{Port: 10255})
+	require.NoError(t, err)
+
+	for setting, expected := range map[string][]string{
+		"include": {"control-plane", "worker"},
+		"exclude": {"worker"},
+	} {
+		uri, err := url.Parse("https://kubernetes.default?controlPlaneNodes=" + setting)
+		require.NoError(t, err)
+		filter, err := kubelet.GetNodeFilter(uri)
+		require.NoError(t, err)
+		provider := &summaryProvider{
+			nodeLister:    v1listers.NewNodeLister(store),
+			kubeletClient: kubeletClient,
+			nodeFilter:    filter,
+		}
+
+		names := []string{}
+		for _, source := range provider.GetMetricsSources() {
+			names = append(names, source.(*summaryMetricsSource).node.NodeName)
+		}
+		sort.Strings(names)
+		assert.Equal(t, expected, names, setting)
+	}
+}
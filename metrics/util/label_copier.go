@@ -51,6 +51,16 @@ func (this *LabelCopier) Copy(in map[string]string, out map[string]string) {
 	out[core.LabelLabels.Key] = strings.Join(labels, this.labelSeparator)
 }
 
+// Stores returns true if the given metric label is filled in from a pod label.
+func (this *LabelCopier) Stores(label string) bool {
+	for _, mappedKey := range this.storedLabels {
+		if mappedKey == label {
+			return true
+		}
+	}
+	return false
+}
+
 // makeStoredLabels converts labels into a map for quicker retrieval.
 // Incoming labels, if desired, may contain mappings in format "newName=oldName"
 func makeStoredLabels(labels []string) map[string]string {
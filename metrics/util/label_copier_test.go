@@ -63,6 +63,16 @@ func TestStoredLabels(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestStores(t *testing.T) {
+	labelCopier, err := NewLabelCopier(",", []string{"name", "app=k8s-app"}, []string{})
+	assert.NoError(t, err)
+
+	assert.True(t, labelCopier.Stores("name"))
+	assert.True(t, labelCopier.Stores("app"))
+	assert.False(t, labelCopier.Stores("k8s-app"))
+	assert.False(t, labelCopier.Stores("price"))
+}
+
 func TestIgnoredLabels(t *testing.T) {
 	actual := initializeAndCopy(t,
 		",",
@@ -0,0 +1,40 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ParseBuckets parses the upper bounds of histogram buckets, e.g. ["0.1", "0.5", "2"].
+// The bounds have to be positive and increasing.
+func ParseBuckets(bounds []string) ([]float64, error) {
+	buckets := make([]float64, 0, len(bounds))
+	for _, bound := range bounds {
+		value, err := strconv.ParseFloat(bound, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket bound %q - %v", bound, err)
+		}
+		if value <= 0 {
+			return nil, fmt.Errorf("bucket bound %q has to be positive", bound)
+		}
+		if len(buckets) > 0 && value <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("bucket bounds have to be increasing - %q after %v", bound, buckets[len(buckets)-1])
+		}
+		buckets = append(buckets, value)
+	}
+	return buckets, nil
+}
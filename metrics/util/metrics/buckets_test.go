@@ -0,0 +1,36 @@
+// Copyright 2015 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBuckets(t *testing.T) {
+	buckets, err := ParseBuckets([]string{"0.05", "0.5", "5", "30"})
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.05, 0.5, 5, 30}, buckets)
+
+	buckets, err = ParseBuckets([]string{})
+	assert.NoError(t, err)
+	assert.Empty(t, buckets)
+
+	for _, invalid := range [][]string{{"fast"}, {"0"}, {"-1"}, {"1", "1"}, {"2", "1"}} {
+		_, err := ParseBuckets(invalid)
+		assert.Error(t, err, "%v", invalid)
+	}
+}
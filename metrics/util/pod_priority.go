@@ -0,0 +1,143 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	kube_client "k8s.io/client-go/kubernetes"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PodPriority holds the scheduling priority of a pod. The pod spec of the vendored API
+// predates pod priority and drops its fields, so the pods are decoded from the raw JSON
+// of the API server into this type, which only keeps the metadata and the priority.
+type PodPriority struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              PodPrioritySpec `json:"spec,omitempty"`
+}
+
+type PodPrioritySpec struct {
+	// Priority of the pod, resolved from its priority class by the API server. Nil if
+	// pod priority is not enabled in the cluster.
+	Priority *int32 `json:"priority,omitempty"`
+	// Name of the priority class of the pod, empty for pods without one.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+}
+
+type PodPriorityList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PodPriority `json:"items"`
+}
+
+// PodPriorityLister returns the priority of the pods.
+type PodPriorityLister interface {
+	Get(namespace, name string) (*PodPriority, error)
+}
+
+type podPriorityLister struct {
+	store cache.Store
+}
+
+func (this *podPriorityLister) Get(namespace, name string) (*PodPriority, error) {
+	item, exists, err := this.store.GetByKey(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("pod %s/%s not found", namespace, name)
+	}
+	return item.(*PodPriority), nil
+}
+
+// GetPodPriorityLister returns a lister of the pod priorities, kept up to date by a
+// reflector listing and watching the raw pods.
+func GetPodPriorityLister(kubeClient *kube_client.Clientset) (PodPriorityLister, error) {
+	client := kubeClient.Core().RESTClient()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			raw, err := client.Get().
+				Namespace(kube_api.NamespaceAll).
+				Resource("pods").
+				VersionedParams(&options, metav1.ParameterCodec).
+				DoRaw()
+			if err != nil {
+				return nil, err
+			}
+			list := &PodPriorityList{}
+			if err := json.Unmarshal(raw, list); err != nil {
+				return nil, fmt.Errorf("failed to decode the pods - %v", err)
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.Watch = true
+			body, err := client.Get().
+				Namespace(kube_api.NamespaceAll).
+				Resource("pods").
+				VersionedParams(&options, metav1.ParameterCodec).
+				Stream()
+			if err != nil {
+				return nil, err
+			}
+			return watch.NewStreamWatcher(newPodPriorityDecoder(body)), nil
+		},
+	}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	reflector := cache.NewReflector(NewThrottledListerWatcher(lw, DefaultWatchBackoff), &PodPriority{}, store, time.Hour)
+	reflector.Run()
+	return &podPriorityLister{store: store}, nil
+}
+
+// podPriorityDecoder decodes the events of a raw pod watch.
+type podPriorityDecoder struct {
+	body    io.ReadCloser
+	decoder *json.Decoder
+}
+
+func newPodPriorityDecoder(body io.ReadCloser) *podPriorityDecoder {
+	return &podPriorityDecoder{body: body, decoder: json.NewDecoder(body)}
+}
+
+func (this *podPriorityDecoder) Decode() (watch.EventType, runtime.Object, error) {
+	var event struct {
+		Type   watch.EventType `json:"type"`
+		Object json.RawMessage `json:"object"`
+	}
+	if err := this.decoder.Decode(&event); err != nil {
+		return "", nil, err
+	}
+	var object runtime.Object = &PodPriority{}
+	if event.Type == watch.Error {
+		object = &metav1.Status{}
+	}
+	if err := json.Unmarshal(event.Object, object); err != nil {
+		return "", nil, fmt.Errorf("failed to decode the %s event of a pod - %v", event.Type, err)
+	}
+	return event.Type, object, nil
+}
+
+func (this *podPriorityDecoder) Close() {
+	this.body.Close()
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kube_client "k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+)
+
+const podPriorityListResponse = `{
+  "kind": "PodList",
+  "apiVersion": "v1",
+  "metadata": {"resourceVersion": "10"},
+  "items": [
+    {
+      "metadata": {"name": "critical", "namespace": "kube-system", "resourceVersion": "8"},
+      "spec": {"containers": [{"name": "app"}], "priority": 2000000000, "priorityClassName": "system-cluster-critical"}
+    },
+    {
+      "metadata": {"name": "batch", "namespace": "ns1", "resourceVersion": "9"},
+      "spec": {"containers": [{"name": "app"}], "priority": 0}
+    }
+  ]
+}`
+
+const podPriorityWatchEvent = `{"type": "ADDED", "object": {
+  "metadata": {"name": "web", "namespace": "ns1", "resourceVersion": "11"},
+  "spec": {"containers": [{"name": "app"}], "priority": 1000, "priorityClassName": "high"}
+}}
+`
+
+func TestPodPriorityLister(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/pods" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") != "true" {
+			fmt.Fprint(w, podPriorityListResponse)
+			return
+		}
+		fmt.Fprint(w, podPriorityWatchEvent)
+		w.(http.Flusher).Flush()
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	kubeClient, err := kube_client.NewForConfig(&restclient.Config{Host: server.URL})
+	require.NoError(t, err)
+	lister, err := GetPodPriorityLister(kubeClient)
+	require.NoError(t, err)
+
+	var web *PodPriority
+	for i := 0; i < 100 && web == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		web, _ = lister.Get("ns1", "web")
+	}
+	if assert.NotNil(t, web) && assert.NotNil(t, web.Spec.Priority) {
+		assert.Equal(t, int32(1000), *web.Spec.Priority)
+		assert.Equal(t, "high", web.Spec.PriorityClassName)
+	}
+
+	critical, err := lister.Get("kube-system", "critical")
+	if assert.NoError(t, err) && assert.NotNil(t, critical.Spec.Priority) {
+		assert.Equal(t, int32(2000000000), *critical.Spec.Priority)
+		assert.Equal(t, "system-cluster-critical", critical.Spec.PriorityClassName)
+	}
+	batch, err := lister.Get("ns1", "batch")
+	if assert.NoError(t, err) && assert.NotNil(t, batch.Spec.Priority) {
+		assert.Equal(t, int32(0), *batch.Spec.Priority)
+		assert.Empty(t, batch.Spec.PriorityClassName)
+	}
+	_, err = lister.Get("ns1", "unknown")
+	assert.Error(t, err)
+}
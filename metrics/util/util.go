@@ -24,7 +24,15 @@ import (
 )
 
 func GetNodeLister(kubeClient *kube_client.Clientset) (v1listers.NodeLister, *cache.Reflector, error) {
-	lw := cache.NewListWatchFromClient(kubeClient.Core().RESTClient(), "nodes", kube_api.NamespaceAll, fields.Everything())
+	return GetNodeListerWithBackoff(kubeClient, DefaultWatchBackoff)
+}
+
+// GetNodeListerWithBackoff returns a node lister whose reflector reconnects to the API
+// server with the given backoff.
+func GetNodeListerWithBackoff(kubeClient *kube_client.Clientset, backoff WatchBackoff) (v1listers.NodeLister, *cache.Reflector, error) {
+	lw := NewThrottledListerWatcher(
+		cache.NewListWatchFromClient(kubeClient.Core().RESTClient(), "nodes", kube_api.NamespaceAll, fields.Everything()),
+		backoff)
 	store := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 	nodeLister := v1listers.NewNodeLister(store)
 	reflector := cache.NewReflector(lw, &kube_api.Node{}, store, time.Hour)
@@ -0,0 +1,141 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// WatchBackoff spaces out the list and watch requests a reflector sends to reconnect to
+// the API server. The reflector itself retries every second, which turns into a tight
+// loop against a flaky API server.
+type WatchBackoff struct {
+	// Delay before the request following a failed one, doubled on every consecutive
+	// failure up to Max.
+	Initial time.Duration
+	Max     time.Duration
+	// Average number of requests per second, and the number of requests that can be sent
+	// at once above it.
+	QPS   float32
+	Burst int
+}
+
+var DefaultWatchBackoff = WatchBackoff{
+	Initial: time.Second,
+	Max:     time.Minute,
+	QPS:     1,
+	Burst:   5,
+}
+
+// ParseWatchBackoff returns the backoff set by the watchBackoffInitial, watchBackoffMax,
+// watchReconnectQps and watchReconnectBurst options of a source, the default otherwise.
+func ParseWatchBackoff(opts url.Values) (WatchBackoff, error) {
+	backoff := DefaultWatchBackoff
+	var err error
+	if len(opts["watchBackoffInitial"]) >= 1 {
+		if backoff.Initial, err = time.ParseDuration(opts["watchBackoffInitial"][0]); err != nil {
+			return backoff, fmt.Errorf("failed to parse `watchBackoffInitial` flag - %v", err)
+		}
+		if backoff.Initial <= 0 {
+			return backoff, fmt.Errorf("`watchBackoffInitial` flag can only be positive")
+		}
+	}
+	if len(opts["watchBackoffMax"]) >= 1 {
+		if backoff.Max, err = time.ParseDuration(opts["watchBackoffMax"][0]); err != nil {
+			return backoff, fmt.Errorf("failed to parse `watchBackoffMax` flag - %v", err)
+		}
+	}
+	if backoff.Max < backoff.Initial {
+		return backoff, fmt.Errorf("`watchBackoffMax` flag can not be lower than `watchBackoffInitial` (%v)", backoff.Initial)
+	}
+	if len(opts["watchReconnectQps"]) >= 1 {
+		qps, err := strconv.ParseFloat(opts["watchReconnectQps"][0], 32)
+		if err != nil {
+			return backoff, fmt.Errorf("failed to parse `watchReconnectQps` flag - %v", err)
+		}
+		if qps <= 0 {
+			return backoff, fmt.Errorf("`watchReconnectQps` flag can only be positive")
+		}
+		backoff.QPS = float32(qps)
+	}
+	if len(opts["watchReconnectBurst"]) >= 1 {
+		if backoff.Burst, err = strconv.Atoi(opts["watchReconnectBurst"][0]); err != nil {
+			return backoff, fmt.Errorf("failed to parse `watchReconnectBurst` flag - %v", err)
+		}
+		if backoff.Burst <= 0 {
+			return backoff, fmt.Errorf("`watchReconnectBurst` flag can only be positive")
+		}
+	}
+	return backoff, nil
+}
+
+// Key of the backoff entry, a throttled lister watcher has a single one.
+const watchBackoffID = "reconnect"
+
+// throttledListerWatcher delays the list and watch requests of the wrapped lister watcher
+// by the rate limiter, and after failures by the backoff.
+type throttledListerWatcher struct {
+	lw      cache.ListerWatcher
+	backoff *flowcontrol.Backoff
+	limiter flowcontrol.RateLimiter
+	sleep   func(time.Duration)
+}
+
+func NewThrottledListerWatcher(lw cache.ListerWatcher, backoff WatchBackoff) cache.ListerWatcher {
+	return &throttledListerWatcher{
+		lw:      lw,
+		backoff: flowcontrol.NewBackOff(backoff.Initial, backoff.Max),
+		limiter: flowcontrol.NewTokenBucketRateLimiter(backoff.QPS, backoff.Burst),
+		sleep:   time.Sleep,
+	}
+}
+
+func (this *throttledListerWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	this.wait()
+	result, err := this.lw.List(options)
+	this.done(err)
+	return result, err
+}
+
+func (this *throttledListerWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	this.wait()
+	result, err := this.lw.Watch(options)
+	this.done(err)
+	return result, err
+}
+
+func (this *throttledListerWatcher) wait() {
+	this.limiter.Accept()
+	if delay := this.backoff.Get(watchBackoffID); delay > 0 {
+		this.sleep(delay)
+	}
+}
+
+func (this *throttledListerWatcher) done(err error) {
+	if err != nil {
+		this.backoff.Next(watchBackoffID, this.backoff.Clock.Now())
+	} else {
+		this.backoff.Reset(watchBackoffID)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	kube_api "k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/util/clock"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// failingListerWatcher fails the requests while failing is set.
+type failingListerWatcher struct {
+	failing bool
+	calls   int
+}
+
+func (this *failingListerWatcher) List(options metav1.ListOptions) (runtime.Object, error) {
+	this.calls++
+	if this.failing {
+		return nil, errors.New("connection refused")
+	}
+	return &kube_api.NodeList{}, nil
+}
+
+func (this *failingListerWatcher) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	this.calls++
+	if this.failing {
+		return nil, errors.New("connection refused")
+	}
+	return watch.NewFake(), nil
+}
+
+func TestThrottledListerWatcherBackoff(t *testing.T) {
+	lw := &failingListerWatcher{failing: true}
+	fakeClock := clock.NewFakeClock(time.Now())
+	delays := []time.Duration{}
+	throttled := &throttledListerWatcher{
+		lw:      lw,
+		backoff: flowcontrol.NewFakeBackOff(time.Second, 5*time.Second, fakeClock),
+		limiter: flowcontrol.NewFakeAlwaysRateLimiter(),
+		sleep: func(delay time.Duration) {
+			delays = append(delays, delay)
+			fakeClock.Step(delay)
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := throttled.List(metav1.ListOptions{})
+		assert.Error(t, err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := throttled.Watch(metav1.ListOptions{})
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 5, lw.calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}, delays)
+
+	// A successful request resets the backoff.
+	lw.failing = false
+	_, err := throttled.List(metav1.ListOptions{})
+	require.NoError(t, err)
+	delays = delays[:0]
+	_, err = throttled.Watch(metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, delays)
+}
+
+func TestThrottledListerWatcherRateLimit(t *testing.T) {
+	lw := &failingListerWatcher{}
+	throttled := NewThrottledListerWatcher(lw, WatchBackoff{Initial: time.Second, Max: time.Second, QPS: 50, Burst: 2})
+
+	start := time.Now()
+	for i := 0; i < 7; i++ {
+		_, err := throttled.Watch(metav1.ListOptions{})
+		require.NoError(t, err)
+	}
+	// The burst goes through at once, the 5 other requests at 50 per second.
+	assert.True(t, time.Since(start) >= 90*time.Millisecond, "reconnects were not throttled: %v", time.Since(start))
+	assert.Equal(t, 7, lw.calls)
+}
+
+func TestParseWatchBackoff(t *testing.T) {
+	backoff, err := ParseWatchBackoff(url.Values{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultWatchBackoff, backoff)
+
+	opts, err := url.ParseQuery("watchBackoffInitial=2s&watchBackoffMax=5m&watchReconnectQps=0.5&watchReconnectBurst=3")
+	require.NoError(t, err)
+	backoff, err = ParseWatchBackoff(opts)
+	require.NoError(t, err)
+	assert.Equal(t, WatchBackoff{Initial: 2 * time.Second, Max: 5 * time.Minute, QPS: 0.5, Burst: 3}, backoff)
+
+	for _, query := range []string{
+		"watchBackoffInitial=soon",
+		"watchBackoffInitial=0s",
+		"watchBackoffInitial=2m",
+		"watchBackoffMax=1ms",
+		"watchReconnectQps=0",
+		"watchReconnectBurst=0",
+		"watchReconnectBurst=many",
+	} {
+		opts, err := url.ParseQuery(query)
+		require.NoError(t, err)
+		_, err = ParseWatchBackoff(opts)
+		assert.Error(t, err, query)
+	}
+}